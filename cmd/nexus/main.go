@@ -2,49 +2,85 @@ package main
 
 import (
 	"context"
+	"flag"
+	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"slices"
+	"strconv"
 	"syscall"
 	"time"
 
 	"github.com/oriys/nexus/internal/admin"
 	"github.com/oriys/nexus/internal/auth"
+	"github.com/oriys/nexus/internal/clientip"
 	"github.com/oriys/nexus/internal/config"
+	"github.com/oriys/nexus/internal/connlimit"
 	"github.com/oriys/nexus/internal/health"
+	"github.com/oriys/nexus/internal/logging"
 	"github.com/oriys/nexus/internal/middleware"
 	"github.com/oriys/nexus/internal/plugin"
 	"github.com/oriys/nexus/internal/proxy"
 	"github.com/oriys/nexus/internal/ratelimit"
 	"github.com/oriys/nexus/internal/runtime"
+	"github.com/oriys/nexus/internal/tenant"
+	"github.com/oriys/nexus/internal/watchdog"
 )
 
 func main() {
-	// Initialize structured JSON logger
-	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
+	// Log JSON at info until the configuration is loaded
+	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
 		Level: slog.LevelInfo,
-	}))
-	slog.SetDefault(logger)
+	})))
+
+	configFlag := flag.String("config", "", "configuration `path`, directory or source (default $NEXUS_CONFIG or configs/nexus.yaml)")
+	validatePath := flag.String("validate", "", "check the configuration `path`, directory or source and exit, e.g. in CI")
+	flag.Parse()
+	if *validatePath != "" {
+		os.Exit(validate(*validatePath, os.Stdout, os.Stderr))
+	}
 
-	// Determine config path
-	configPath := os.Getenv("NEXUS_CONFIG")
+	// Determine config path: a file, a directory of *.yaml files, or a
+	// remote source such as etcd://etcd:2379/nexus/config
+	configPath := *configFlag
+	if configPath == "" {
+		configPath = os.Getenv("NEXUS_CONFIG")
+	}
 	if configPath == "" {
 		configPath = "configs/nexus.yaml"
 	}
 
 	// Load configuration
-	loader := config.NewLoader(configPath)
+	loader, err := newLoader(configPath)
+	if err != nil {
+		slog.Error("failed to open config", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
 	cfg, err := loader.Load()
 	if err != nil {
 		slog.Error("failed to load config", slog.String("error", err.Error()))
 		os.Exit(1)
 	}
+	// The loader path omits credentials a source location may carry.
+	configPath = loader.Path()
 	slog.Info("configuration loaded", slog.String("path", configPath))
+	if cfg.Version == "" {
+		slog.Warn("config does not pin a schema version; set version: "+config.SchemaVersion, slog.String("path", configPath))
+	}
+	for _, c := range config.RouteConflicts(cfg) {
+		slog.Warn("route conflict", slog.String("conflict", c))
+	}
+
+	// Log in the configured format and level, masking secrets in
+	// everything logged from here on
+	slog.SetDefault(slog.New(cfg.Redactor().Handler(logging.NewHandler(os.Stdout, &cfg.Logging))))
 
 	// Initialize config version manager
 	versionMgr := config.NewVersionManager(10)
-	rawData, err := os.ReadFile(configPath)
+	rawData, err := loader.Raw()
 	if err != nil {
 		slog.Warn("failed to read raw config for versioning", slog.String("error", err.Error()))
 		rawData = nil
@@ -56,13 +92,12 @@ func main() {
 	upstreamMgr := proxy.NewUpstreamManager()
 
 	// Apply configuration
-	router.Reload(cfg.Routes)
-	upstreamMgr.Reload(cfg.Upstreams)
+	proxy.Apply(router, upstreamMgr, cfg)
 
 	// Initialize runtime config store for V2 DSL
 	configStore := runtime.NewConfigStore()
 	var useV2 bool
-	if len(cfg.RoutesV2) > 0 && len(cfg.Clusters) > 0 {
+	if cfg.UsesV2() {
 		if _, err := runtime.CompileAndStore(cfg, configStore); err != nil {
 			slog.Error("failed to compile v2 config", slog.String("error", err.Error()))
 			os.Exit(1)
@@ -78,12 +113,43 @@ func main() {
 	checker := health.NewChecker()
 
 	// Build middleware chain
+	resolver, err := clientip.NewResolver(cfg.TrustedProxies)
+	if err != nil {
+		slog.Error("invalid trusted_proxies", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
 	middlewares := []middleware.Middleware{
 		middleware.RequestID(),
 		middleware.TraceContext(),
-		middleware.Logging(),
+		middleware.ClientIP(resolver),
+		middleware.Logging(cfg.Logging.Headers...),
+	}
+
+	// Add the IP access list before anything else spends work on a request
+	if cfg.IPACL != nil {
+		acl, err := clientip.NewACL(cfg.IPACL.Allow, cfg.IPACL.Deny)
+		if err != nil {
+			slog.Error("invalid ip_acl", slog.String("error", err.Error()))
+			os.Exit(1)
+		}
+		middlewares = append(middlewares, middleware.IPACL(acl))
+		slog.Info("IP access list enabled",
+			slog.Int("allow", len(cfg.IPACL.Allow)),
+			slog.Int("deny", len(cfg.IPACL.Deny)),
+		)
 	}
 
+	// The maintenance switch is read per request, so changes made through
+	// the admin API or a reload apply at once
+	middlewares = append(middlewares, middleware.Maintenance(func() http.Handler {
+		if cur := loader.Current(); cur != nil {
+			if page := runtime.NewMaintenancePage(cur.Maintenance); page != nil {
+				return page
+			}
+		}
+		return nil
+	}))
+
 	// Add rate limiting middleware if enabled
 	if cfg.RateLimit.Enabled && cfg.RateLimit.Rate > 0 {
 		window := cfg.RateLimit.Window
@@ -107,21 +173,75 @@ func main() {
 		)
 	}
 
+	// Label requests with their tenant once the caller is authenticated.
+	// The tenancy is read per request, like the maintenance switch, so that
+	// reloads change it together with the tenant clusters of V2 routes
+	var tenancies tenant.Settings
+	middlewares = append(middlewares, middleware.Tenant(func() *tenant.Tenancy {
+		if cur := loader.Current(); cur != nil {
+			return tenancies.For(cur.Tenancy)
+		}
+		return nil
+	}))
+	if cfg.Tenancy != nil {
+		slog.Info("tenancy enabled",
+			slog.String("header", cfg.Tenancy.Header),
+			slog.String("claim", cfg.Tenancy.Claim),
+			slog.Int("tenants", len(cfg.Tenancy.Tenants)),
+		)
+	}
+
 	// Build handler with middleware chain
 	var baseHandler http.Handler
+	var pluginChain *plugin.Chain
+	var customPlugins []plugin.Plugin
+	for _, ep := range cfg.ExternalPlugins {
+		customPlugins = append(customPlugins, plugin.NewExternalPlugin(ep))
+	}
+	if cfg.PluginDir != "" {
+		native, err := plugin.LoadNativePlugins(cfg.PluginDir)
+		if err != nil {
+			slog.Error("failed to load native plugins", slog.String("error", err.Error()))
+			os.Exit(1)
+		}
+		for _, p := range native {
+			slog.Info("native plugin loaded", slog.String("plugin", p.Name()), slog.Int("order", p.Order()))
+		}
+		customPlugins = append(customPlugins, native...)
+	}
 	if useV2 {
-		baseHandler = runtime.NewGateway(configStore)
+		// The gateway runs custom plugins between its route matching and
+		// upstream plugins.
+		gateway := runtime.NewGateway(configStore, customPlugins...)
+		pluginChain = gateway.Chain()
+		baseHandler = gateway
 	} else if cfg.PluginMode {
 		// ShenYu-style plugin chain handler
-		pluginChain := plugin.NewChain(
+		plugins := []plugin.Plugin{
 			plugin.NewGlobalLogPlugin(),
 			plugin.NewHttpProxyPlugin(),
-		)
+		}
+		pluginChain = plugin.NewChain(append(plugins, customPlugins...)...)
+		pluginChain.SetResolver(func(r *http.Request) *plugin.RuleData {
+			m, ok := router.Match(r)
+			if !ok {
+				return nil
+			}
+			target, _ := upstreamMgr.GetTarget(m.Upstream)
+			return &plugin.RuleData{Name: m.Route.Name, Upstream: target, Host: m.Route.Host}
+		})
 		baseHandler = pluginChain.Handler()
-		slog.Info("plugin mode enabled")
+		slog.Info("plugin mode enabled", slog.Int("external_plugins", len(cfg.ExternalPlugins)))
 	} else {
 		baseHandler = proxy.NewProxy(router, upstreamMgr)
 	}
+	if pluginChain != nil {
+		if err := pluginChain.CheckSettings(cfg.Plugins); err != nil {
+			slog.Error("invalid plugin settings", slog.String("error", err.Error()))
+			os.Exit(1)
+		}
+		pluginChain.Configure(cfg.Plugins)
+	}
 	handler := middleware.Chain(baseHandler, middlewares...)
 
 	// Create mux with health endpoints
@@ -130,76 +250,224 @@ func main() {
 	mux.Handle("/readyz", checker.ReadyzHandler())
 	mux.Handle("/", handler)
 
-	// Configure server
+	// Configure server; the request limits cover the whole listener,
+	// health endpoints included
 	srv := &http.Server{
-		Addr:         cfg.Server.Listen,
-		Handler:      mux,
-		ReadTimeout:  cfg.Server.ReadTimeout,
-		WriteTimeout: cfg.Server.WriteTimeout,
+		Addr:           cfg.Server.Listen,
+		Handler:        mux,
+		ReadTimeout:    cfg.Server.ReadTimeout,
+		WriteTimeout:   cfg.Server.WriteTimeout,
+		MaxHeaderBytes: cfg.Server.MaxHeaderBytesLimit(),
+	}
+	if cfg.Server.MaxHeaderCount > 0 || cfg.Server.MaxURLLength > 0 {
+		srv.Handler = middleware.RequestLimits(cfg.Server.MaxHeaderCount, cfg.Server.MaxURLLength)(mux)
+	}
+	if cfg.Server.H2C {
+		srv.Protocols = new(http.Protocols)
+		srv.Protocols.SetHTTP1(true)
+		srv.Protocols.SetHTTP2(true)
+		srv.Protocols.SetUnencryptedHTTP2(true)
+	}
+	var listenerACL *clientip.ACL
+	if cfg.Server.IPACL != nil {
+		listenerACL, err = clientip.NewACL(cfg.Server.IPACL.Allow, cfg.Server.IPACL.Deny)
+		if err != nil {
+			slog.Error("invalid server.ip_acl", slog.String("error", err.Error()))
+			os.Exit(1)
+		}
+	}
+	var certStore *runtime.CertStore
+	if cfg.Server.TLS != nil {
+		certStore, err = runtime.NewCertStore(cfg.Server.TLS)
+		if err != nil {
+			slog.Error("invalid server TLS config", slog.String("error", err.Error()))
+			os.Exit(1)
+		}
+		tlsCfg, err := certStore.TLSConfig()
+		if err != nil {
+			slog.Error("invalid server TLS config", slog.String("error", err.Error()))
+			os.Exit(1)
+		}
+		srv.TLSConfig = tlsCfg
+		// net/http offers h2 on its own unless HTTP/2 is off
+		if alpn := cfg.Server.TLS.ALPN; len(alpn) > 0 && !slices.Contains(alpn, "h2") {
+			if srv.Protocols == nil {
+				srv.Protocols = new(http.Protocols)
+				srv.Protocols.SetHTTP1(true)
+			}
+			srv.Protocols.SetHTTP2(false)
+		}
+	}
+
+	// The admin server also applies reloaded configurations, so it exists
+	// even when its API is not served.
+	adminServer := admin.New(loader, versionMgr, router, upstreamMgr)
+	adminServer.SetConfigStore(configStore)
+	if pluginChain != nil {
+		adminServer.SetPluginChain(pluginChain)
 	}
 
 	// Start admin API server if enabled
 	var adminSrv *http.Server
 	if cfg.Admin.Enabled && cfg.Admin.Listen != "" {
-		adminServer := admin.New(loader, versionMgr, router, upstreamMgr)
+		if err := adminServer.SetAuth(cfg.Admin.Auth); err != nil {
+			slog.Error("invalid admin auth config", slog.String("error", err.Error()))
+			os.Exit(1)
+		}
+		if cfg.Admin.Auth == nil {
+			slog.Warn("admin API has no authentication configured; do not expose it beyond trusted networks")
+		}
+		if cfg.Admin.Persist && !loader.IsFile() {
+			slog.Warn("admin.persist is only supported for a config file; admin API changes are lost on restart", slog.String("path", configPath))
+		} else if cfg.Admin.Persist {
+			adminServer.SetPersister(loader)
+			slog.Info("admin API changes are written back to the config file", slog.String("path", configPath))
+		}
 		adminSrv = &http.Server{
 			Addr:    cfg.Admin.Listen,
 			Handler: adminServer.Handler(),
 		}
+		if cfg.Admin.TLS != nil {
+			tlsCfg, err := admin.TLSConfig(cfg.Admin.TLS)
+			if err != nil {
+				slog.Error("invalid admin TLS config", slog.String("error", err.Error()))
+				os.Exit(1)
+			}
+			adminSrv.TLSConfig = tlsCfg
+		}
 		go func() {
-			slog.Info("admin API starting", slog.String("listen", cfg.Admin.Listen))
-			if err := adminSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			slog.Info("admin API starting",
+				slog.String("listen", cfg.Admin.Listen),
+				slog.Bool("tls", adminSrv.TLSConfig != nil),
+			)
+			var err error
+			if adminSrv.TLSConfig != nil {
+				err = adminSrv.ListenAndServeTLS("", "")
+			} else {
+				err = adminSrv.ListenAndServe()
+			}
+			if err != nil && err != http.ErrServerClosed {
 				slog.Error("admin server error", slog.String("error", err.Error()))
 			}
 		}()
 	}
 
+	// setAdminAuth applies the admin auth of a reloaded config.
+	setAdminAuth := func(newCfg *config.Config) {
+		if adminSrv == nil {
+			return
+		}
+		if err := adminServer.SetAuth(newCfg.Admin.Auth); err != nil {
+			slog.Error("failed to reload admin auth config", slog.String("error", err.Error()))
+		}
+	}
+
 	// Start config watcher
 	done := make(chan struct{})
 	go func() {
 		if err := loader.Watch(func(newCfg *config.Config) {
-			router.Reload(newCfg.Routes)
-			upstreamMgr.Reload(newCfg.Upstreams)
-
-			// Recompile V2 config if present
-			if len(newCfg.RoutesV2) > 0 && len(newCfg.Clusters) > 0 {
-				if _, err := runtime.CompileAndStore(newCfg, configStore); err != nil {
-					slog.Error("failed to recompile v2 config", slog.String("error", err.Error()))
-				} else {
-					slog.Info("v2 DSL configuration recompiled")
-				}
-			}
-
-			newRawData, err := os.ReadFile(configPath)
+			newRawData, err := loader.Raw()
 			if err != nil {
 				slog.Warn("failed to read raw config for versioning", slog.String("error", err.Error()))
 				newRawData = nil
 			}
-			versionMgr.Save(newCfg, newRawData)
+			// Routes, upstreams and the V2 runtime are swapped together,
+			// or not at all when the V2 configuration fails to compile.
+			if err := adminServer.Reload(newCfg, newRawData); err != nil {
+				slog.Error("failed to apply reloaded config, keeping current", slog.String("error", err.Error()))
+				return
+			}
+			setAdminAuth(newCfg)
 		}, done); err != nil {
 			slog.Error("config watcher error", slog.String("error", err.Error()))
 		}
 	}()
 
+	// Reload the listener certificates when their files change, and rotate
+	// the session ticket keys
+	if certStore != nil {
+		go func() {
+			if err := certStore.Watch(done); err != nil {
+				slog.Error("failed to watch server certificates", slog.String("error", err.Error()))
+			}
+		}()
+		go certStore.RotateSessionTickets(done)
+	}
+
+	// Degrade the gateway while the process is past the watchdog
+	// thresholds
+	if cfg.Watchdog != nil {
+		wd := watchdog.New(cfg.Watchdog)
+		wd.OnChange(func(reason string) {
+			runtime.SetDegraded(reason != "")
+			checker.SetDegraded(reason)
+		})
+		go wd.Run(done)
+	}
+
 	// Start server
 	go func() {
-		slog.Info("nexus gateway starting", slog.String("listen", cfg.Server.Listen))
+		slog.Info("nexus gateway starting",
+			slog.String("listen", cfg.Server.Listen),
+			slog.Bool("tls", srv.TLSConfig != nil),
+		)
+		addr := srv.Addr
+		if addr == "" {
+			addr = ":http"
+			if srv.TLSConfig != nil {
+				addr = ":https"
+			}
+		}
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			slog.Error("server error", slog.String("error", err.Error()))
+			os.Exit(1)
+		}
+		if listenerACL != nil {
+			ln = listenerACL.Listener(ln)
+		}
+		if limits := cfg.Server.Connections; limits != nil {
+			ln = connlimit.Listener(ln, limits, srv.TLSConfig == nil)
+		}
 		checker.SetReady(true)
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		if srv.TLSConfig != nil {
+			err = srv.ServeTLS(ln, "", "")
+		} else {
+			err = srv.Serve(ln)
+		}
+		if err != nil && err != http.ErrServerClosed {
 			slog.Error("server error", slog.String("error", err.Error()))
 			os.Exit(1)
 		}
 	}()
 
-	// Wait for shutdown signal
+	// Wait for shutdown signal, reloading the config on SIGHUP, which
+	// covers file replacements the watcher does not see.
 	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
 	sig := <-quit
+	for sig == syscall.SIGHUP {
+		slog.Info("SIGHUP received, reloading config", slog.String("path", configPath))
+		if certStore != nil {
+			if err := certStore.Reload(); err != nil {
+				slog.Error("failed to reload server certificates, keeping current", slog.String("error", err.Error()))
+			}
+		}
+		if diff, err := adminServer.ReloadFromLoader(); err != nil {
+			slog.Error("failed to reload config, keeping current", slog.String("error", err.Error()))
+		} else if len(diff) == 0 {
+			slog.Info("config unchanged")
+		} else {
+			setAdminAuth(loader.Current())
+			slog.Info("config reloaded successfully", slog.Int("changes", len(diff)))
+		}
+		sig = <-quit
+	}
 	slog.Info("shutdown signal received", slog.String("signal", sig.String()))
 
 	// Graceful shutdown
 	checker.SetReady(false)
-	close(done) // stop config watcher
+	close(done) // stop config and certificate watchers
 
 	shutdownTimeout := cfg.Server.ShutdownTimeout
 	if shutdownTimeout == 0 {
@@ -220,3 +488,34 @@ func main() {
 	}
 	slog.Info("nexus gateway stopped")
 }
+
+// newLoader returns the loader for a config file, directory or remote
+// source, configured from NEXUS_CONFIG_POLL_INTERVAL and NEXUS_CONFIG_STRICT.
+func newLoader(configPath string) (*config.Loader, error) {
+	loader := config.NewLoader(configPath)
+	if config.IsRemote(configPath) {
+		poll := config.DefaultPollInterval
+		if v := os.Getenv("NEXUS_CONFIG_POLL_INTERVAL"); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid NEXUS_CONFIG_POLL_INTERVAL: %w", err)
+			}
+			poll = d
+		}
+		src, err := config.NewSource(configPath, poll)
+		if err != nil {
+			return nil, fmt.Errorf("open config source: %w", err)
+		}
+		loader = config.NewSourceLoader(src)
+	}
+	// Unknown fields, usually typos, are rejected unless
+	// NEXUS_CONFIG_STRICT=false.
+	if v := os.Getenv("NEXUS_CONFIG_STRICT"); v != "" {
+		strict, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid NEXUS_CONFIG_STRICT: %w", err)
+		}
+		loader.SetStrict(strict)
+	}
+	return loader, nil
+}
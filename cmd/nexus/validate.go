@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/oriys/nexus/internal/config"
+	"github.com/oriys/nexus/internal/runtime"
+)
+
+// validate checks the configuration at path as the gateway would at
+// startup: it is loaded, validated and its V2 routes compiled, but nothing
+// is served. The result is reported on stdout or stderr and the exit code
+// returned, for use in CI pipelines.
+func validate(path string, stdout, stderr io.Writer) int {
+	loader, err := newLoader(path)
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: %v\n", path, err)
+		return 1
+	}
+	cfg, err := loader.Load()
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: %v\n", loader.Path(), err)
+		return 1
+	}
+	if cfg.UsesV2() {
+		if err := runtime.Check(cfg, nil); err != nil {
+			fmt.Fprintf(stderr, "%s: compile v2 config: %v\n", loader.Path(), err)
+			return 1
+		}
+	}
+	if cfg.Version == "" {
+		fmt.Fprintf(stdout, "%s: warning: no schema version pinned, set version: %s\n", loader.Path(), config.SchemaVersion)
+	}
+	for _, c := range config.RouteConflicts(cfg) {
+		fmt.Fprintf(stdout, "%s: warning: %s\n", loader.Path(), c)
+	}
+	fmt.Fprintf(stdout, "%s: configuration is valid\n", loader.Path())
+	return 0
+}
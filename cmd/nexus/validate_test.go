@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const validateTestConfig = `version: v1
+server:
+  listen: ":8080"
+clusters:
+  - name: users
+    endpoints:
+      - url: "http://users:8080"
+routes_v2:
+  - name: users
+    match:
+      path_prefix: /users
+    upstream:
+      cluster: users
+`
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		code    int
+		want    string
+	}{
+		{"valid", validateTestConfig, 0, "configuration is valid"},
+		{"unpinned", strings.TrimPrefix(validateTestConfig, "version: v1\n"), 0, "warning: no schema version pinned"},
+		{"newer schema", strings.Replace(validateTestConfig, "version: v1", "version: v2", 1), 1,
+			`line 1, column 1: version: schema version "v2" is newer than this binary supports (v1)`},
+		{"shadowed route", validateTestConfig + `  - name: admins
+    match:
+      path_prefix: /users/admins
+    upstream:
+      cluster: users
+  - name: all
+    priority: 1
+    match:
+      path_prefix: /
+    upstream:
+      cluster: users
+`, 0, `warning: route_v2 "users": never matches, shadowed by route_v2 "all"`},
+		{"invalid", strings.Replace(validateTestConfig, "cluster: users", "cluster: orders", 1), 1, `references unknown cluster "orders"`},
+		{"compile error", validateTestConfig + `  - name: grpc
+    match:
+      path: /grpc
+    upstream:
+      cluster: users
+      grpc:
+        service: missing.v1.Service
+        method: Get
+        request:
+          mode: json_to_proto
+`, 1, "compile v2 config"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "nexus.yaml")
+			if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			var stdout, stderr bytes.Buffer
+			code := validate(path, &stdout, &stderr)
+			if code != tt.code || !strings.Contains(stdout.String()+stderr.String(), tt.want) {
+				t.Errorf("validate = %d, stdout %q, stderr %q; want %d and %q", code, stdout.String(), stderr.String(), tt.code, tt.want)
+			}
+		})
+	}
+
+	var stderr bytes.Buffer
+	if code := validate(filepath.Join(t.TempDir(), "missing.yaml"), &bytes.Buffer{}, &stderr); code != 1 || !strings.Contains(stderr.String(), "read config file") {
+		t.Errorf("expected a read error, got %d: %s", code, stderr.String())
+	}
+}
+
+// The sample configurations name files relative to the repository root.
+func TestValidate_SampleConfigs(t *testing.T) {
+	t.Chdir(filepath.Join("..", ".."))
+	for _, path := range []string{"configs/nexus.yaml", "configs/nexus-v2.yaml"} {
+		var stdout, stderr bytes.Buffer
+		if code := validate(path, &stdout, &stderr); code != 0 {
+			t.Errorf("%s: validate = %d: %s", path, code, stderr.String())
+		}
+	}
+}
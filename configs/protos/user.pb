@@ -0,0 +1,12 @@
+
+
+
+user.protouser.v1" 
+GetUserRequest
+id (	Rid"@
+User
+id (	Rid
+name (	Rname
+email (	Remail2@
+UserService1
+GetUser.user.v1.GetUserRequest.user.v1.Userbproto3
\ No newline at end of file
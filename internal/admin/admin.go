@@ -3,9 +3,14 @@ package admin
 import (
 	"encoding/json"
 	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
 
 	"github.com/oriys/nexus/internal/config"
+	"github.com/oriys/nexus/internal/plugin"
 	"github.com/oriys/nexus/internal/proxy"
+	"github.com/oriys/nexus/internal/runtime"
 )
 
 // Server is the admin API server.
@@ -15,6 +20,15 @@ type Server struct {
 	router         *proxy.Router
 	upstreamMgr    *proxy.UpstreamManager
 	docStore       *DocStore
+	runtimeStore   *runtime.ConfigStore
+	plugins        *plugin.Chain                 // nil without a plugin chain
+	persister      config.Persister              // nil keeps admin changes in memory
+	protoMu        sync.Mutex                    // serializes descriptor set changes
+	configMu       sync.Mutex                    // serializes configuration changes
+	cutoverMu      sync.Mutex                    // guards cutovers
+	cutovers       map[string]*cutover           // latest blue/green switch by route
+	access         atomic.Pointer[accessControl] // nil leaves the API open
+	routes         []registeredRoute             // in registration order, for the OpenAPI document
 	mux            *http.ServeMux
 }
 
@@ -26,38 +40,164 @@ func New(cl *config.Loader, vm *config.VersionManager, r *proxy.Router, um *prox
 		router:         r,
 		upstreamMgr:    um,
 		docStore:       NewDocStore(),
+		cutovers:       make(map[string]*cutover),
 		mux:            http.NewServeMux(),
 	}
+	// API description (Control Plane)
+	s.handle("GET /api/v1/openapi.json", roleReadOnly, s.getOpenAPI)
+
 	// Config management (Control Plane)
-	s.mux.HandleFunc("GET /api/v1/config", s.getConfig)
-	s.mux.HandleFunc("GET /api/v1/config/versions", s.listVersions)
-	s.mux.HandleFunc("POST /api/v1/config/rollback", s.rollbackConfig)
+	s.handle("GET /api/v1/config", roleReadOnly, s.getConfig)
+	s.handle("GET /api/v1/config/versions", roleReadOnly, s.listVersions)
+	s.handle("POST /api/v1/config/rollback", roleOperator, s.rollbackConfig)
+	s.handle("POST /api/v1/config/reload", roleOperator, s.reloadConfig)
+	// Validation applies nothing, so read-only callers such as CI may use it.
+	s.handle("POST /api/v1/config/validate", roleOperator, s.validateConfig)
+	// Exports carry secrets such as the admin tokens.
+	s.handle("GET /api/v1/config/export", roleOperator, s.exportConfig)
+	s.handle("POST /api/v1/config/import", roleOperator, s.importConfig)
 
 	// Route publishing (Control Plane)
-	s.mux.HandleFunc("GET /api/v1/routes", s.listRoutes)
-	s.mux.HandleFunc("POST /api/v1/routes", s.publishRoute)
-	s.mux.HandleFunc("PUT /api/v1/routes/{name}", s.updateRoute)
-	s.mux.HandleFunc("DELETE /api/v1/routes/{name}", s.deleteRoute)
+	s.handle("GET /api/v1/routes", roleReadOnly, s.listRoutes)
+	s.handle("POST /api/v1/routes", roleOperator, s.publishRoute)
+	s.handle("PUT /api/v1/routes/{name}", roleOperator, s.updateRoute)
+	s.handle("DELETE /api/v1/routes/{name}", roleOperator, s.deleteRoute)
+
+	// V2 route management (Control Plane)
+	s.handle("GET /api/v1/routes-v2", roleReadOnly, s.listRoutesV2)
+	s.handle("GET /api/v1/routes-v2/{name}", roleReadOnly, s.getRouteV2)
+	s.handle("POST /api/v1/routes-v2", roleOperator, s.createRouteV2)
+	s.handle("PUT /api/v1/routes-v2/{name}", roleOperator, s.updateRouteV2)
+	s.handle("DELETE /api/v1/routes-v2/{name}", roleOperator, s.deleteRouteV2)
+	s.handle("GET /api/v1/routes-v2/{name}/switch", roleReadOnly, s.getRouteV2Switch)
+	s.handle("POST /api/v1/routes-v2/{name}/switch", roleOperator, s.switchRouteV2)
+	s.handle("PUT /api/v1/routes-v2/{name}/maintenance", roleOperator, s.setRouteV2Maintenance)
+
+	// Maintenance mode (Control Plane)
+	s.handle("GET /api/v1/maintenance", roleReadOnly, s.getMaintenance)
+	s.handle("PUT /api/v1/maintenance", roleOperator, s.setMaintenance)
 
 	// Upstream management (Control Plane)
-	s.mux.HandleFunc("GET /api/v1/upstreams", s.listUpstreams)
+	s.handle("GET /api/v1/upstreams", roleReadOnly, s.listUpstreams)
+	s.handle("POST /api/v1/upstreams", roleOperator, s.createUpstream)
+	s.handle("PUT /api/v1/upstreams/{name}", roleOperator, s.updateUpstream)
+	s.handle("DELETE /api/v1/upstreams/{name}", roleOperator, s.deleteUpstream)
+
+	// Cluster management (Control Plane)
+	s.handle("GET /api/v1/clusters", roleReadOnly, s.listClusters)
+	s.handle("POST /api/v1/clusters", roleOperator, s.createCluster)
+	s.handle("PUT /api/v1/clusters/{name}", roleOperator, s.updateCluster)
+	s.handle("DELETE /api/v1/clusters/{name}", roleOperator, s.deleteCluster)
+
+	// Plugin chain management (Control Plane)
+	s.handle("GET /api/v1/plugins", roleReadOnly, s.listPlugins)
+	s.handle("PUT /api/v1/plugins/{name}", roleOperator, s.updatePlugin)
+
+	// Log level (Control Plane)
+	s.handle("GET /api/v1/logging", roleReadOnly, s.getLogging)
+	s.handle("PUT /api/v1/logging", roleOperator, s.setLogging)
+
+	// Runtime introspection (Control Plane)
+	s.handle("GET /api/v1/runtime/routes", roleReadOnly, s.getRuntimeRoutes)
+	s.handle("GET /api/v1/runtime/clusters", roleReadOnly, s.getRuntimeClusters)
+
+	// Response cache (Control Plane)
+	s.handle("DELETE /api/v1/cache", roleOperator, s.purgeCache)
 
 	// Documentation publishing (Control Plane)
-	s.mux.HandleFunc("GET /api/v1/docs", s.listDocs)
-	s.mux.HandleFunc("POST /api/v1/docs", s.publishDoc)
-	s.mux.HandleFunc("GET /api/v1/docs/{route}", s.getDoc)
-	s.mux.HandleFunc("DELETE /api/v1/docs/{route}", s.deleteDoc)
+	s.handle("GET /api/v1/docs", roleReadOnly, s.listDocs)
+	s.handle("POST /api/v1/docs", roleOperator, s.publishDoc)
+	s.handle("GET /api/v1/docs/{route}", roleReadOnly, s.getDoc)
+	s.handle("DELETE /api/v1/docs/{route}", roleOperator, s.deleteDoc)
+
+	// gRPC service discovery (Control Plane)
+	s.handle("GET /api/v1/grpc/services", roleReadOnly, s.listGRPCServices)
+
+	// Route match testing (Control Plane)
+	// Matching sends nothing upstream, so read-only callers may use it.
+	s.handle("POST /api/v1/debug/match", roleReadOnly, s.debugMatch)
+
+	// Dubbo provider discovery (Control Plane)
+	s.handle("GET /api/v1/dubbo/services", roleReadOnly, s.listDubboServices)
+	s.handle("GET /api/v1/dubbo/connections", roleReadOnly, s.listDubboConnections)
+
+	// Dubbo invocation testing (Control Plane)
+	s.handle("POST /api/v1/debug/dubbo", roleOperator, s.debugDubbo)
+
+	// GraphQL persisted queries (Control Plane)
+	s.handle("GET /api/v1/graphql/persisted-queries", roleReadOnly, s.getPersistedQueries)
+	s.handle("PUT /api/v1/graphql/persisted-queries", roleOperator, s.syncPersistedQueries)
+
+	// Protobuf descriptor registry (Control Plane)
+	s.handle("GET /api/v1/protos", roleReadOnly, s.listProtos)
+	s.handle("GET /api/v1/protos/{name}", roleReadOnly, s.getProto)
+	s.handle("PUT /api/v1/protos/{name}", roleOperator, s.uploadProto)
+	s.handle("POST /api/v1/protos/{name}/rollback", roleOperator, s.rollbackProto)
+	s.handle("DELETE /api/v1/protos/{name}", roleOperator, s.deleteProto)
+
+	// Prometheus metrics (Control Plane)
+	s.handle("GET /metrics", roleReadOnly, s.getMetrics)
 
 	// Status (Control Plane)
-	s.mux.HandleFunc("GET /api/v1/status", s.getStatus)
+	s.handle("GET /api/v1/status", roleReadOnly, s.getStatus)
 	return s
 }
 
+// SetConfigStore attaches the runtime config store so endpoints that inspect
+// the compiled V2 configuration can serve requests.
+func (s *Server) SetConfigStore(store *runtime.ConfigStore) {
+	s.runtimeStore = store
+}
+
+// SetPersister makes configuration changes made through the admin API be
+// written through p, typically the config.Loader, before they are applied.
+func (s *Server) SetPersister(p config.Persister) {
+	s.persister = p
+}
+
 // Handler returns the HTTP handler for the admin server.
 func (s *Server) Handler() http.Handler {
 	return s.mux
 }
 
+// compiledConfig returns the active compiled configuration, writing a 503
+// response and returning nil when none is available.
+func (s *Server) compiledConfig(w http.ResponseWriter) *runtime.CompiledConfig {
+	if s.runtimeStore == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "runtime configuration not available"})
+		return nil
+	}
+	compiled := s.runtimeStore.Load()
+	if compiled == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "no compiled configuration loaded"})
+		return nil
+	}
+	return compiled
+}
+
+// listGRPCServices handles GET /api/v1/grpc/services, returning the services
+// discovered through server reflection for each gRPC cluster.
+func (s *Server) listGRPCServices(w http.ResponseWriter, r *http.Request) {
+	compiled := s.compiledConfig(w)
+	if compiled == nil {
+		return
+	}
+
+	type clusterServices struct {
+		Cluster  string                `json:"cluster"`
+		Services []runtime.GRPCService `json:"services"`
+	}
+	result := make([]clusterServices, 0)
+	for _, c := range compiled.Clusters {
+		if c.Type != "grpc" || c.GRPC == nil || !c.GRPC.Reflection {
+			continue
+		}
+		result = append(result, clusterServices{Cluster: c.Name, Services: c.Services})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Cluster < result[j].Cluster })
+	writeJSON(w, http.StatusOK, result)
+}
+
 func (s *Server) getConfig(w http.ResponseWriter, r *http.Request) {
 	cfg := s.configLoader.Current()
 	if cfg == nil {
@@ -68,7 +208,7 @@ func (s *Server) getConfig(w http.ResponseWriter, r *http.Request) {
 	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(cfg)
+	json.NewEncoder(w).Encode(config.Redacted(cfg))
 }
 
 func (s *Server) listVersions(w http.ResponseWriter, r *http.Request) {
@@ -92,15 +232,19 @@ func (s *Server) listVersions(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) rollbackConfig(w http.ResponseWriter, r *http.Request) {
-	cfg, err := s.versionManager.Rollback()
-	if err != nil {
+	s.configMu.Lock()
+	defer s.configMu.Unlock()
+	prev := s.versionManager.Previous()
+	if prev == nil {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		json.NewEncoder(w).Encode(map[string]string{"error": "no previous version to rollback to"})
+		return
+	}
+	if _, ok := s.commitConfig(w, prev.Config); !ok {
 		return
 	}
-	s.router.Reload(cfg.Routes)
-	s.upstreamMgr.Reload(cfg.Upstreams)
+	s.versionManager.Rollback()
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{"message": "configuration rolled back successfully"})
@@ -136,5 +280,6 @@ func (s *Server) getStatus(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"status":          "running",
 		"config_versions": s.versionManager.Len(),
+		"stats":           runtime.Snapshot(),
 	})
 }
@@ -6,10 +6,12 @@ import (
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/oriys/nexus/internal/config"
 	"github.com/oriys/nexus/internal/proxy"
+	"github.com/oriys/nexus/internal/runtime"
 )
 
 const testConfig = `server:
@@ -76,6 +78,33 @@ func TestGetConfig(t *testing.T) {
 	}
 }
 
+func TestGetConfig_Redacted(t *testing.T) {
+	cfgPath := filepath.Join(t.TempDir(), "nexus.yaml")
+	data := testConfig + `auth:
+  api_key:
+    enabled: true
+    keys:
+      sk-live-123: billing
+`
+	if err := os.WriteFile(cfgPath, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cl := config.NewLoader(cfgPath)
+	if _, err := cl.Load(); err != nil {
+		t.Fatal(err)
+	}
+	s := New(cl, config.NewVersionManager(10), proxy.NewRouter(), proxy.NewUpstreamManager())
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/config", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if body := w.Body.String(); strings.Contains(body, "sk-live-123") || !strings.Contains(body, "billing") {
+		t.Errorf("API key not redacted: %s", body)
+	}
+}
+
 func TestGetConfig_NoConfig(t *testing.T) {
 	dir := t.TempDir()
 	cfgPath := filepath.Join(dir, "nexus.yaml")
@@ -259,4 +288,66 @@ func TestGetStatus(t *testing.T) {
 	if result["config_versions"].(float64) != 1 {
 		t.Fatalf("expected 1 config version, got %v", result["config_versions"])
 	}
+	stats, ok := result["stats"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected stats, got %v", result["stats"])
+	}
+	if _, ok := stats["routes"].([]interface{}); !ok {
+		t.Fatalf("expected route stats, got %v", stats["routes"])
+	}
+}
+
+func TestListGRPCServices(t *testing.T) {
+	s := setupAdmin(t)
+	store := runtime.NewConfigStore()
+	store.Store(&runtime.CompiledConfig{
+		Clusters: map[string]*runtime.CompiledCluster{
+			"user-grpc": {
+				Name: "user-grpc",
+				Type: "grpc",
+				GRPC: &config.ClusterGRPC{Reflection: true},
+				Services: []runtime.GRPCService{
+					{Name: "user.v1.UserService", Methods: []string{"GetUser", "ListUsers"}},
+				},
+			},
+			"order-grpc": {Name: "order-grpc", Type: "grpc"},
+			"web":        {Name: "web", Type: "http"},
+		},
+	})
+	s.SetConfigStore(store)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/grpc/services", nil)
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	var result []struct {
+		Cluster  string `json:"cluster"`
+		Services []struct {
+			Name    string   `json:"name"`
+			Methods []string `json:"methods"`
+		} `json:"services"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	if len(result) != 1 || result[0].Cluster != "user-grpc" {
+		t.Fatalf("expected only the reflection-enabled cluster, got %+v", result)
+	}
+	if len(result[0].Services) != 1 || len(result[0].Services[0].Methods) != 2 {
+		t.Errorf("unexpected services: %+v", result[0].Services)
+	}
+}
+
+func TestListGRPCServices_NoRuntime(t *testing.T) {
+	s := setupAdmin(t)
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/grpc/services", nil)
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d", w.Code)
+	}
 }
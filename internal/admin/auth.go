@@ -0,0 +1,231 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/oriys/nexus/internal/config"
+)
+
+// role is the access level an admin endpoint requires. Higher roles include
+// the lower ones.
+type role int
+
+const (
+	roleReadOnly role = iota + 1
+	roleOperator
+)
+
+func (r role) String() string {
+	switch r {
+	case roleReadOnly:
+		return config.AdminRoleReadOnly
+	case roleOperator:
+		return config.AdminRoleOperator
+	}
+	return fmt.Sprintf("role(%d)", int(r))
+}
+
+func parseRole(s string) (role, error) {
+	switch s {
+	case config.AdminRoleReadOnly:
+		return roleReadOnly, nil
+	case config.AdminRoleOperator:
+		return roleOperator, nil
+	}
+	return 0, fmt.Errorf("unknown admin role %q", s)
+}
+
+// accessControl enforces the admin authentication settings.
+type accessControl struct {
+	networks []*net.IPNet // empty allows any client address
+	tokens   []adminToken
+	certs    map[string]role // client certificate common name → role
+}
+
+type adminToken struct {
+	name  string
+	token []byte
+	role  role
+}
+
+func newAccessControl(cfg *config.AdminAuthConfig) (*accessControl, error) {
+	ac := &accessControl{certs: make(map[string]role, len(cfg.ClientCerts))}
+	for _, cidr := range cfg.AllowedCIDRs {
+		if !strings.Contains(cidr, "/") {
+			ip := net.ParseIP(cidr)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid allowed CIDR or IP %q", cidr)
+			}
+			bits := 8 * len(ip.To16())
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			ac.networks = append(ac.networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid allowed CIDR %q: %w", cidr, err)
+		}
+		ac.networks = append(ac.networks, network)
+	}
+	for _, t := range cfg.Tokens {
+		r, err := parseRole(t.Role)
+		if err != nil {
+			return nil, fmt.Errorf("token %q: %w", t.Name, err)
+		}
+		ac.tokens = append(ac.tokens, adminToken{name: t.Name, token: []byte(t.Token), role: r})
+	}
+	for _, c := range cfg.ClientCerts {
+		r, err := parseRole(c.Role)
+		if err != nil {
+			return nil, fmt.Errorf("client certificate %q: %w", c.CommonName, err)
+		}
+		ac.certs[c.CommonName] = r
+	}
+	return ac, nil
+}
+
+// SetAuth applies the admin authentication settings. Nil leaves the admin
+// API open. It is safe to call while requests are served, e.g. on reload.
+func (s *Server) SetAuth(cfg *config.AdminAuthConfig) error {
+	if cfg == nil {
+		s.access.Store(nil)
+		return nil
+	}
+	ac, err := newAccessControl(cfg)
+	if err != nil {
+		return err
+	}
+	s.access.Store(ac)
+	return nil
+}
+
+// handle registers h for pattern, requiring callers to hold at least the
+// given role.
+func (s *Server) handle(pattern string, required role, h http.HandlerFunc) {
+	s.routes = append(s.routes, registeredRoute{pattern: pattern, role: required})
+	s.mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		if ac := s.access.Load(); ac != nil && !ac.authorize(w, r, required) {
+			return
+		}
+		h(w, r)
+	})
+}
+
+// authorize checks the client address and identity of r against the
+// required role, writing the error response when access is denied.
+func (ac *accessControl) authorize(w http.ResponseWriter, r *http.Request, required role) bool {
+	if !ac.allowsAddr(r.RemoteAddr) {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "client address not allowed"})
+		return false
+	}
+	if len(ac.tokens) == 0 && len(ac.certs) == 0 {
+		return true
+	}
+	name, granted, err := ac.identify(r)
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="nexus-admin"`)
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": err.Error()})
+		return false
+	}
+	if granted < required {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": name + " is not allowed to change the gateway"})
+		return false
+	}
+	return true
+}
+
+func (ac *accessControl) allowsAddr(remoteAddr string) bool {
+	if len(ac.networks) == 0 {
+		return true
+	}
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, n := range ac.networks {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// identify returns the caller name and role from the bearer token or the
+// verified client certificate of r.
+func (ac *accessControl) identify(r *http.Request) (string, role, error) {
+	if h := r.Header.Get("Authorization"); h != "" {
+		token, ok := strings.CutPrefix(h, "Bearer ")
+		if !ok {
+			return "", 0, errors.New("unsupported authorization scheme, expected Bearer")
+		}
+		// Compare against every token so timing does not reveal matches.
+		var match *adminToken
+		for i := range ac.tokens {
+			if subtle.ConstantTimeCompare([]byte(token), ac.tokens[i].token) == 1 {
+				match = &ac.tokens[i]
+			}
+		}
+		if match == nil {
+			return "", 0, errors.New("invalid token")
+		}
+		return "token " + match.name, match.role, nil
+	}
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		cn := r.TLS.VerifiedChains[0][0].Subject.CommonName
+		if granted, ok := ac.certs[cn]; ok {
+			return "certificate " + cn, granted, nil
+		}
+		return "", 0, fmt.Errorf("client certificate %q is not authorized", cn)
+	}
+	return "", 0, errors.New("authentication required")
+}
+
+// TLSConfig builds the TLS configuration of the admin listener. With a
+// client CA, client certificates are verified when presented but not
+// required, so token callers can still connect.
+func TLSConfig(cfg *config.AdminTLSConfig) (*tls.Config, error) {
+	certPEM, keyPEM := []byte(cfg.Cert), []byte(cfg.Key)
+	var err error
+	if cfg.CertFile != "" {
+		if certPEM, err = os.ReadFile(cfg.CertFile); err != nil {
+			return nil, fmt.Errorf("read admin certificate: %w", err)
+		}
+	}
+	if cfg.KeyFile != "" {
+		if keyPEM, err = os.ReadFile(cfg.KeyFile); err != nil {
+			return nil, fmt.Errorf("read admin key: %w", err)
+		}
+	}
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("load admin certificate: %w", err)
+	}
+	tc := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	if cfg.ClientCAFile != "" {
+		pem, err := os.ReadFile(cfg.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read admin client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("admin client CA %s holds no PEM certificates", cfg.ClientCAFile)
+		}
+		tc.ClientCAs = pool
+		tc.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return tc, nil
+}
@@ -0,0 +1,178 @@
+package admin
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oriys/nexus/internal/config"
+)
+
+func setupAuthAdmin(t *testing.T, cfg *config.AdminAuthConfig) *Server {
+	t.Helper()
+	s := setupAdmin(t)
+	if err := s.SetAuth(cfg); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func serveAdmin(s *Server, req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, req)
+	return w
+}
+
+func TestAuth_Tokens(t *testing.T) {
+	s := setupAuthAdmin(t, &config.AdminAuthConfig{Tokens: []config.AdminToken{
+		{Name: "dashboard", Token: "read-token", Role: config.AdminRoleReadOnly},
+		{Name: "deployer", Token: "op-token", Role: config.AdminRoleOperator},
+	}})
+
+	tests := []struct {
+		method, path, auth string
+		want               int
+	}{
+		{http.MethodGet, "/api/v1/config", "", http.StatusUnauthorized},
+		{http.MethodGet, "/api/v1/config", "Bearer wrong", http.StatusUnauthorized},
+		{http.MethodGet, "/api/v1/config", "Basic cmVhZA==", http.StatusUnauthorized},
+		{http.MethodGet, "/api/v1/config", "Bearer read-token", http.StatusOK},
+		{http.MethodGet, "/api/v1/config", "Bearer op-token", http.StatusOK},
+		{http.MethodDelete, "/api/v1/docs/users", "Bearer read-token", http.StatusForbidden},
+		{http.MethodDelete, "/api/v1/docs/users", "Bearer op-token", http.StatusNotFound},
+		{http.MethodPost, "/api/v1/config/validate", "Bearer read-token", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		if tt.auth != "" {
+			req.Header.Set("Authorization", tt.auth)
+		}
+		w := serveAdmin(s, req)
+		if w.Code != tt.want {
+			t.Errorf("%s %s with %q: expected %d, got %d: %s", tt.method, tt.path, tt.auth, tt.want, w.Code, w.Body)
+		}
+		if w.Code == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("%s %s: 401 without WWW-Authenticate", tt.method, tt.path)
+		}
+	}
+
+	// Config output never includes the tokens.
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/config", nil)
+	req.Header.Set("Authorization", "Bearer read-token")
+	if body := serveAdmin(s, req).Body.String(); strings.Contains(body, "read-token") {
+		t.Errorf("config output leaks tokens: %s", body)
+	}
+
+	// Removing the settings opens the API again.
+	s.SetAuth(nil)
+	if w := serveAdmin(s, httptest.NewRequest(http.MethodGet, "/api/v1/config", nil)); w.Code != http.StatusOK {
+		t.Errorf("expected open access without auth, got %d", w.Code)
+	}
+}
+
+func TestAuth_ClientCertificates(t *testing.T) {
+	s := setupAuthAdmin(t, &config.AdminAuthConfig{ClientCerts: []config.AdminClientCert{
+		{CommonName: "ops", Role: config.AdminRoleOperator},
+		{CommonName: "monitoring", Role: config.AdminRoleReadOnly},
+	}})
+	withCert := func(method, path, cn string) *http.Request {
+		req := httptest.NewRequest(method, path, nil)
+		req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: cn}}}}}
+		return req
+	}
+	if w := serveAdmin(s, withCert(http.MethodDelete, "/api/v1/docs/users", "ops")); w.Code != http.StatusNotFound {
+		t.Errorf("expected operator certificate to reach the handler, got %d", w.Code)
+	}
+	if w := serveAdmin(s, withCert(http.MethodDelete, "/api/v1/docs/users", "monitoring")); w.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for a read-only certificate, got %d", w.Code)
+	}
+	if w := serveAdmin(s, withCert(http.MethodGet, "/api/v1/status", "intruder")); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for an unknown certificate, got %d", w.Code)
+	}
+	// Unverified certificates are ignored.
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/status", nil)
+	req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{{Subject: pkix.Name{CommonName: "ops"}}}}
+	if w := serveAdmin(s, req); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for an unverified certificate, got %d", w.Code)
+	}
+}
+
+func TestAuth_AllowedCIDRs(t *testing.T) {
+	s := setupAuthAdmin(t, &config.AdminAuthConfig{AllowedCIDRs: []string{"10.0.0.0/8", "::1"}})
+	for addr, want := range map[string]int{
+		"10.1.2.3:4567": http.StatusOK,
+		"[::1]:4567":    http.StatusOK,
+		"192.0.2.1:80":  http.StatusForbidden,
+		"garbage":       http.StatusForbidden,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/config", nil)
+		req.RemoteAddr = addr
+		req.Header.Set("X-Forwarded-For", "10.0.0.1")
+		if w := serveAdmin(s, req); w.Code != want {
+			t.Errorf("%s: expected %d, got %d", addr, want, w.Code)
+		}
+	}
+
+	if err := s.SetAuth(&config.AdminAuthConfig{AllowedCIDRs: []string{"10.0.0.0/40"}}); err == nil {
+		t.Error("expected an error for an invalid CIDR")
+	}
+}
+
+func TestTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "nexus-admin"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IsCA:         true,
+		KeyUsage:     x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile := filepath.Join(dir, "admin.crt"), filepath.Join(dir, "admin.key")
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600)
+
+	tc, err := TLSConfig(&config.AdminTLSConfig{CertFile: certFile, KeyFile: keyFile, ClientCAFile: certFile})
+	if err != nil {
+		t.Fatalf("TLSConfig: %v", err)
+	}
+	if len(tc.Certificates) != 1 || tc.ClientCAs == nil || tc.ClientAuth != tls.VerifyClientCertIfGiven {
+		t.Errorf("unexpected TLS config %+v", tc)
+	}
+
+	if _, err := TLSConfig(&config.AdminTLSConfig{CertFile: certFile, KeyFile: keyFile, ClientCAFile: keyFile}); err == nil {
+		t.Error("expected an error for a client CA without certificates")
+	}
+	if _, err := TLSConfig(&config.AdminTLSConfig{CertFile: filepath.Join(dir, "missing.crt"), KeyFile: keyFile}); err == nil {
+		t.Error("expected an error for a missing certificate")
+	}
+
+	keyPEM, _ := os.ReadFile(keyFile)
+	if tc, err := TLSConfig(&config.AdminTLSConfig{CertFile: certFile, Key: string(keyPEM)}); err != nil || len(tc.Certificates) != 1 {
+		t.Errorf("expected an inline key to load, got %v", err)
+	}
+}
@@ -0,0 +1,277 @@
+package admin
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/oriys/nexus/internal/config"
+	"github.com/oriys/nexus/internal/runtime"
+)
+
+// bakeCheckInterval is how often the error rate of a route is checked
+// during its bake window.
+var bakeCheckInterval = time.Second
+
+// cutover is the latest blue/green switch of a route.
+type cutover struct {
+	From    string    `json:"from"`
+	To      string    `json:"to"`
+	Cluster string    `json:"cluster"`
+	Started time.Time `json:"started"`
+	// BakeUntil ends the window in which errors roll the switch back.
+	BakeUntil time.Time `json:"bake_until"`
+	// State is "baking", "completed", "rolled_back" or "superseded" when
+	// the route changed before the window ended.
+	State     string `json:"state"`
+	Responses uint64 `json:"responses"`
+	Errors    uint64 `json:"errors"`
+
+	outcomes     *runtime.UpstreamOutcomes
+	baseResp     uint64 // counts of outcomes when the switch happened
+	baseErrs     uint64
+	maxErrorRate float64
+	minRequests  uint64
+}
+
+// switchRouteV2 handles POST /api/v1/routes-v2/{name}/switch, making the
+// other cluster of a blue/green route active, or the one named by an
+// optional {"to": "blue"|"green"} body. The route is then watched for its
+// bake window and switched back when its error rate exceeds the limit.
+func (s *Server) switchRouteV2(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	var req struct {
+		To string `json:"to"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body: " + err.Error()})
+			return
+		}
+	}
+	switch req.To {
+	case "", "blue", "green":
+	default:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "to must be 'blue' or 'green'"})
+		return
+	}
+
+	s.configMu.Lock()
+	defer s.configMu.Unlock()
+	cfg := s.currentConfig(w)
+	if cfg == nil {
+		return
+	}
+	next, bg := withBlueGreen(cfg, name)
+	if next == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "route '" + name + "' not found"})
+		return
+	}
+	if bg == nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "route '" + name + "' has no upstream.blue_green"})
+		return
+	}
+	from := activeColor(bg)
+	to := req.To
+	if to == "" {
+		to = otherColor(from)
+	}
+	if to == from {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": "route '" + name + "' is already on " + to})
+		return
+	}
+	bg.Active = to
+	if !s.applyConfig(w, next) {
+		return
+	}
+
+	now := time.Now()
+	c := &cutover{
+		From:         from,
+		To:           to,
+		Cluster:      bg.ActiveCluster(),
+		Started:      now,
+		BakeUntil:    now.Add(bg.BakeDuration()),
+		State:        "baking",
+		outcomes:     runtime.Outcomes(name, bg.ActiveCluster()),
+		maxErrorRate: bg.ErrorRateLimit(),
+		minRequests:  uint64(bg.MinRequestCount()),
+	}
+	c.baseResp, c.baseErrs = c.outcomes.Counts()
+	s.cutoverMu.Lock()
+	if prev := s.cutovers[name]; prev != nil && prev.State == "baking" {
+		prev.State = "superseded"
+	}
+	s.cutovers[name] = c
+	s.cutoverMu.Unlock()
+	go s.bake(name, c)
+
+	slog.Info("blue/green switch",
+		slog.String("route", name),
+		slog.String("from", from),
+		slog.String("to", to),
+	)
+	writeJSON(w, http.StatusOK, map[string]any{
+		"message":    "route switched successfully",
+		"name":       name,
+		"active":     to,
+		"cluster":    c.Cluster,
+		"bake_until": c.BakeUntil,
+	})
+}
+
+// getRouteV2Switch handles GET /api/v1/routes-v2/{name}/switch, returning
+// the latest switch of the route and its error counts.
+func (s *Server) getRouteV2Switch(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	s.cutoverMu.Lock()
+	defer s.cutoverMu.Unlock()
+	c := s.cutovers[name]
+	if c == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "route '" + name + "' has not been switched"})
+		return
+	}
+	if c.State == "baking" {
+		c.observe()
+	}
+	writeJSON(w, http.StatusOK, c)
+}
+
+// observe updates the counts of c since the switch. Callers hold cutoverMu.
+func (c *cutover) observe() {
+	resp, errs := c.outcomes.Counts()
+	c.Responses, c.Errors = resp-c.baseResp, errs-c.baseErrs
+}
+
+// failing reports whether the error rate of c exceeds its limit. Callers
+// hold cutoverMu.
+func (c *cutover) failing() bool {
+	return c.Responses >= c.minRequests && c.Responses > 0 &&
+		float64(c.Errors)/float64(c.Responses) > c.maxErrorRate
+}
+
+// bake watches the error rate of a switched route until its bake window
+// ends, switching it back when the rate exceeds the limit.
+func (s *Server) bake(name string, c *cutover) {
+	ticker := time.NewTicker(bakeCheckInterval)
+	defer ticker.Stop()
+	for {
+		<-ticker.C
+		s.cutoverMu.Lock()
+		if c.State != "baking" {
+			s.cutoverMu.Unlock()
+			return
+		}
+		c.observe()
+		failing := c.failing()
+		if !failing && !time.Now().Before(c.BakeUntil) {
+			c.State = "completed"
+		}
+		done := c.State != "baking"
+		s.cutoverMu.Unlock()
+		if failing {
+			s.rollbackSwitch(name, c)
+			return
+		}
+		if done {
+			return
+		}
+	}
+}
+
+// rollbackSwitch makes the cluster c switched away from active again,
+// unless the route changed in the meantime.
+func (s *Server) rollbackSwitch(name string, c *cutover) {
+	s.configMu.Lock()
+	defer s.configMu.Unlock()
+	s.cutoverMu.Lock()
+	defer s.cutoverMu.Unlock()
+	if c.State != "baking" {
+		return
+	}
+	cfg := s.configLoader.Current()
+	var next *config.Config
+	var bg *config.BlueGreen
+	if cfg != nil {
+		next, bg = withBlueGreen(cfg, name)
+	}
+	if bg == nil || activeColor(bg) != c.To {
+		c.State = "superseded"
+		return
+	}
+	bg.Active = c.From
+	var rec responseRecorder
+	data, ok := s.commitConfig(&rec, next)
+	if !ok {
+		slog.Error("blue/green rollback failed",
+			slog.String("route", name),
+			slog.String("error", rec.body.String()),
+		)
+		return
+	}
+	s.versionManager.Save(next, data)
+	c.State = "rolled_back"
+	slog.Warn("blue/green switch rolled back",
+		slog.String("route", name),
+		slog.String("to", c.From),
+		slog.Uint64("responses", c.Responses),
+		slog.Uint64("errors", c.Errors),
+	)
+}
+
+// withBlueGreen returns a copy of cfg whose route name has its own copy of
+// the blue/green settings, which are also returned. The configuration is
+// nil when the route does not exist, the settings when it is not a
+// blue/green route.
+func withBlueGreen(cfg *config.Config, name string) (*config.Config, *config.BlueGreen) {
+	next := *cfg
+	next.RoutesV2 = append([]config.RouteV2(nil), cfg.RoutesV2...)
+	for i := range next.RoutesV2 {
+		route := &next.RoutesV2[i]
+		if route.Name != name {
+			continue
+		}
+		if route.Upstream.BlueGreen == nil {
+			return &next, nil
+		}
+		bg := *route.Upstream.BlueGreen
+		route.Upstream.BlueGreen = &bg
+		return &next, &bg
+	}
+	return nil, nil
+}
+
+func activeColor(bg *config.BlueGreen) string {
+	if bg.Active == "green" {
+		return "green"
+	}
+	return "blue"
+}
+
+func otherColor(color string) string {
+	if color == "green" {
+		return "blue"
+	}
+	return "green"
+}
+
+// responseRecorder keeps the response of a configuration change made
+// outside a request.
+type responseRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) Header() http.Header {
+	if r.header == nil {
+		r.header = make(http.Header)
+	}
+	return r.header
+}
+
+func (r *responseRecorder) WriteHeader(status int) { r.status = status }
+
+func (r *responseRecorder) Write(b []byte) (int, error) { return r.body.Write(b) }
@@ -0,0 +1,148 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/oriys/nexus/internal/config"
+	"github.com/oriys/nexus/internal/proxy"
+	"github.com/oriys/nexus/internal/runtime"
+)
+
+func TestSwitchRouteV2(t *testing.T) {
+	defer func(d time.Duration) { bakeCheckInterval = d }(bakeCheckInterval)
+	bakeCheckInterval = 10 * time.Millisecond
+
+	backend := func(status int, name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+			w.Write([]byte(name))
+		}))
+	}
+	blue, green, healthy := backend(200, "blue"), backend(500, "green"), backend(200, "healthy")
+	defer blue.Close()
+	defer green.Close()
+	defer healthy.Close()
+
+	yml := fmt.Sprintf(`
+server:
+  listen: ":8080"
+clusters:
+  - {name: blue, type: http, endpoints: [{url: %q}]}
+  - {name: green, type: http, endpoints: [{url: %q}]}
+  - {name: healthy, type: http, endpoints: [{url: %q}]}
+routes_v2:
+  - name: orders
+    match: {path_prefix: /orders}
+    upstream:
+      blue_green: {blue: blue, green: green, bake: 1m, min_requests: 3, max_error_rate: 0.5}
+  - name: users
+    match: {path_prefix: /users}
+    upstream:
+      blue_green: {blue: blue, green: healthy, bake: 50ms}
+  - name: plain
+    match: {path_prefix: /plain}
+    upstream: {cluster: blue}
+`, blue.URL, green.URL, healthy.URL)
+	cfgPath := filepath.Join(t.TempDir(), "nexus.yaml")
+	if err := os.WriteFile(cfgPath, []byte(yml), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cl := config.NewLoader(cfgPath)
+	cfg, err := cl.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := runtime.NewConfigStore()
+	if _, err := runtime.CompileAndStore(cfg, store); err != nil {
+		t.Fatal(err)
+	}
+	s := New(cl, config.NewVersionManager(10), proxy.NewRouter(), proxy.NewUpstreamManager())
+	s.SetConfigStore(store)
+	gw := runtime.NewGateway(store)
+	serve := func(path string) string {
+		rec := httptest.NewRecorder()
+		gw.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		return rec.Body.String()
+	}
+	state := func(route string) cutover {
+		var c cutover
+		w := doAdmin(s, http.MethodGet, "/api/v1/routes-v2/"+route+"/switch", "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("switch state: status %d: %s", w.Code, w.Body)
+		}
+		json.NewDecoder(w.Body).Decode(&c)
+		return c
+	}
+
+	for _, tt := range []struct {
+		path, body string
+		want       int
+	}{
+		{"/api/v1/routes-v2/missing/switch", "", http.StatusNotFound},
+		{"/api/v1/routes-v2/plain/switch", "", http.StatusBadRequest},
+		{"/api/v1/routes-v2/orders/switch", `{"to":"red"}`, http.StatusBadRequest},
+		{"/api/v1/routes-v2/orders/switch", `{"to":"blue"}`, http.StatusConflict},
+	} {
+		if w := doAdmin(s, http.MethodPost, tt.path, tt.body); w.Code != tt.want {
+			t.Errorf("POST %s %s: expected %d, got %d: %s", tt.path, tt.body, tt.want, w.Code, w.Body)
+		}
+	}
+	if w := doAdmin(s, http.MethodGet, "/api/v1/routes-v2/orders/switch", ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 before a switch, got %d", w.Code)
+	}
+
+	// A failing green cluster is switched back once enough requests failed.
+	if serve("/orders") != "blue" {
+		t.Fatal("route does not start on blue")
+	}
+	if w := doAdmin(s, http.MethodPost, "/api/v1/routes-v2/orders/switch", ""); w.Code != http.StatusOK {
+		t.Fatalf("switch: status %d: %s", w.Code, w.Body)
+	}
+	if got := serve("/orders"); got != "green" {
+		t.Fatalf("after the switch served by %q", got)
+	}
+	if got := s.configLoader.Current().RoutesV2[0].Upstream.BlueGreen.Active; got != "green" {
+		t.Errorf("active %q in the configuration", got)
+	}
+	serve("/orders")
+	serve("/orders")
+	deadline := time.Now().Add(2 * time.Second)
+	for state("orders").State == "baking" && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if c := state("orders"); c.State != "rolled_back" || c.From != "blue" || c.To != "green" || c.Errors != 3 {
+		t.Fatalf("switch state %+v", c)
+	}
+	if got := serve("/orders"); got != "blue" {
+		t.Errorf("after the rollback served by %q", got)
+	}
+	if got := s.configLoader.Current().RoutesV2[0].Upstream.BlueGreen.Active; got != "blue" {
+		t.Errorf("active %q in the configuration after the rollback", got)
+	}
+	if n := s.versionManager.Len(); n != 2 {
+		t.Errorf("expected the switch and the rollback as versions, got %d", n)
+	}
+
+	// A healthy cluster stays active once the bake window ends.
+	if w := doAdmin(s, http.MethodPost, "/api/v1/routes-v2/users/switch", `{"to":"green"}`); w.Code != http.StatusOK {
+		t.Fatalf("switch: status %d: %s", w.Code, w.Body)
+	}
+	serve("/users")
+	deadline = time.Now().Add(2 * time.Second)
+	for state("users").State == "baking" && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if c := state("users"); c.State != "completed" || c.Responses != 1 || c.Errors != 0 {
+		t.Fatalf("switch state %+v", c)
+	}
+	if got := serve("/users"); got != "healthy" {
+		t.Errorf("after the bake window served by %q", got)
+	}
+}
@@ -0,0 +1,222 @@
+package admin
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/oriys/nexus/internal/config"
+)
+
+// errEmptyDocument is returned for an import without a configuration.
+var errEmptyDocument = errors.New("empty document")
+
+// namedSections are the configuration lists whose entries an import in merge
+// mode matches by name.
+var namedSections = map[string]bool{
+	"upstreams": true,
+	"routes":    true,
+	"listeners": true,
+	"clusters":  true,
+	"routes_v2": true,
+}
+
+// importResult is the response of POST /api/v1/config/import.
+type importResult struct {
+	Mode    string          `json:"mode"`
+	Version int             `json:"version"`
+	Diff    []config.Change `json:"diff"`
+}
+
+// exportConfig handles GET /api/v1/config/export, returning the active
+// configuration as a document that POST /api/v1/config/import accepts: YAML
+// by default, JSON with the file field names when format=json is given or
+// JSON is the accepted type. Exports include the admin tokens, which is why
+// they need the operator role.
+func (s *Server) exportConfig(w http.ResponseWriter, r *http.Request) {
+	cfg := s.currentConfig(w)
+	if cfg == nil {
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format == "" && strings.Contains(r.Header.Get("Accept"), "application/json") {
+		format = "json"
+	}
+	switch format {
+	case "", "yaml":
+		data, err := yaml.Marshal(cfg)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "serialize configuration: " + err.Error()})
+			return
+		}
+		w.Header().Set("Content-Type", "application/yaml")
+		w.WriteHeader(http.StatusOK)
+		w.Write(data)
+	case "json":
+		doc, err := configDocument(cfg)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "serialize configuration: " + err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, doc)
+	default:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "format must be yaml or json"})
+	}
+}
+
+// importConfig handles POST /api/v1/config/import. The body is a
+// configuration document, YAML or JSON with the file field names. With
+// mode=merge, the default, the upstreams, routes, listeners, clusters and
+// routes_v2 it lists are added or replace the entries of the same name, and
+// any other section it sets replaces that section; nothing is removed. With
+// mode=replace the document becomes the whole configuration. The result is
+// validated and applied like any other change.
+func (s *Server) importConfig(w http.ResponseWriter, r *http.Request) {
+	mode := r.URL.Query().Get("mode")
+	if mode == "" {
+		mode = "merge"
+	}
+	if mode != "merge" && mode != "replace" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "mode must be merge or replace"})
+		return
+	}
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxCandidateBytes+1))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "read request body: " + err.Error()})
+		return
+	}
+	if len(data) > maxCandidateBytes {
+		writeJSON(w, http.StatusRequestEntityTooLarge, map[string]string{"error": "configuration too large"})
+		return
+	}
+	var imported config.Config
+	if err := decodeStrict(data, &imported); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid configuration: " + err.Error()})
+		return
+	}
+
+	s.configMu.Lock()
+	defer s.configMu.Unlock()
+	cfg := s.configLoader.Current()
+	next := &imported
+	if mode == "merge" {
+		if cfg == nil {
+			writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "no configuration loaded"})
+			return
+		}
+		if next, err = mergeConfig(cfg, data); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "merge configuration: " + err.Error()})
+			return
+		}
+	}
+	diff, err := config.Diff(cfg, next)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if !s.applyConfig(w, next) {
+		return
+	}
+
+	result := importResult{Mode: mode, Diff: []config.Change{}}
+	if v := s.versionManager.Current(); v != nil {
+		result.Version = v.Version
+	}
+	if diff != nil {
+		result.Diff = diff
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// mergeConfig returns cfg with the configuration document data merged in.
+func mergeConfig(cfg *config.Config, data []byte) (*config.Config, error) {
+	base, err := configDocument(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	for key, value := range doc {
+		entries, isList := value.([]interface{})
+		current, _ := base[key].([]interface{})
+		if namedSections[key] && isList {
+			base[key] = mergeNamed(current, entries)
+		} else {
+			base[key] = value
+		}
+	}
+	merged, err := yaml.Marshal(base)
+	if err != nil {
+		return nil, err
+	}
+	var next config.Config
+	if err := decodeStrict(merged, &next); err != nil {
+		return nil, err
+	}
+	return &next, nil
+}
+
+// mergeNamed replaces the entries of current named like an entry of imported
+// and appends the other imported entries.
+func mergeNamed(current, imported []interface{}) []interface{} {
+	merged := append([]interface{}(nil), current...)
+	index := make(map[string]int, len(merged))
+	for i, entry := range merged {
+		if name := documentName(entry); name != "" {
+			index[name] = i
+		}
+	}
+	for _, entry := range imported {
+		name := documentName(entry)
+		if i, ok := index[name]; ok && name != "" {
+			merged[i] = entry
+			continue
+		}
+		if name != "" {
+			index[name] = len(merged)
+		}
+		merged = append(merged, entry)
+	}
+	return merged
+}
+
+// documentName returns the name of a list entry of a configuration document.
+func documentName(entry interface{}) string {
+	m, _ := entry.(map[string]interface{})
+	name, _ := m["name"].(string)
+	return name
+}
+
+// configDocument converts cfg to a generic document keyed by the file field
+// names.
+func configDocument(cfg *config.Config) (map[string]interface{}, error) {
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	doc := make(map[string]interface{})
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// decodeStrict decodes a configuration document, rejecting unknown fields
+// and empty documents.
+func decodeStrict(data []byte, cfg *config.Config) error {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil {
+		if err == io.EOF {
+			return errEmptyDocument
+		}
+		return err
+	}
+	return nil
+}
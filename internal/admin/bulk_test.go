@@ -0,0 +1,132 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestExportImport_RoundTrip(t *testing.T) {
+	s, _ := setupClusterAdmin(t)
+
+	w := doAdmin(s, http.MethodGet, "/api/v1/config/export", "")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/yaml" {
+		t.Fatalf("expected a YAML export, got %d %s: %s", w.Code, w.Header().Get("Content-Type"), w.Body)
+	}
+	exported := w.Body.String()
+
+	w = doAdmin(s, http.MethodPost, "/api/v1/config/import?mode=replace", exported)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+	var result importResult
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Mode != "replace" || result.Version != 1 || len(result.Diff) != 0 {
+		t.Errorf("unexpected result %+v", result)
+	}
+
+	w = doAdmin(s, http.MethodGet, "/api/v1/config/export?format=json", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := doc["routes_v2"]; !ok {
+		t.Errorf("expected file field names in the JSON export, got %s", w.Body)
+	}
+	// The JSON export is accepted back as is.
+	w = doAdmin(s, http.MethodPost, "/api/v1/config/import?mode=replace", w.Body.String())
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+
+	if w := doAdmin(s, http.MethodGet, "/api/v1/config/export?format=xml", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", w.Code)
+	}
+}
+
+func TestImport_Merge(t *testing.T) {
+	s, store := setupClusterAdmin(t)
+
+	w := doAdmin(s, http.MethodPost, "/api/v1/config/import", `clusters:
+  - name: web
+    endpoints:
+      - url: "http://127.0.0.1:9002"
+  - name: api
+    endpoints:
+      - url: "http://127.0.0.1:9003"
+routes_v2:
+  - name: api
+    match:
+      path_prefix: /api
+    upstream:
+      cluster: api
+logging:
+  level: debug
+`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+	var result importResult
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Mode != "merge" || result.Version != 1 || len(result.Diff) == 0 {
+		t.Errorf("unexpected result %+v", result)
+	}
+
+	cfg := s.configLoader.Current()
+	if len(cfg.Clusters) != 2 || cfg.Clusters[0].Name != "web" || cfg.Clusters[0].Endpoints[0].URL != "http://127.0.0.1:9002" || cfg.Clusters[1].Name != "api" {
+		t.Errorf("unexpected clusters %+v", cfg.Clusters)
+	}
+	if len(cfg.RoutesV2) != 2 || cfg.RoutesV2[0].Name != "web-api" || cfg.RoutesV2[1].Name != "api" {
+		t.Errorf("unexpected routes %+v", cfg.RoutesV2)
+	}
+	if cfg.Logging.Level != "debug" || len(cfg.Routes) != 1 || cfg.Server.Listen != ":8080" {
+		t.Errorf("sections not merged: %+v", cfg)
+	}
+	if store.Load().Clusters["api"] == nil {
+		t.Error("merged configuration not compiled")
+	}
+}
+
+func TestImport_Replace(t *testing.T) {
+	s, store := setupClusterAdmin(t)
+	w := doAdmin(s, http.MethodPost, "/api/v1/config/import?mode=replace", testConfig)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+	if cfg := s.configLoader.Current(); len(cfg.Clusters) != 0 || len(cfg.RoutesV2) != 0 {
+		t.Errorf("expected the V2 configuration to be removed, got %+v", cfg)
+	}
+	if c := store.Load(); len(c.Clusters) != 0 {
+		t.Errorf("expected no compiled clusters, got %v", c.Clusters)
+	}
+}
+
+func TestImport_Errors(t *testing.T) {
+	s, _ := setupClusterAdmin(t)
+	for _, tt := range []struct {
+		query, body string
+		want        int
+	}{
+		{"?mode=upsert", testConfig, http.StatusBadRequest},
+		{"", "", http.StatusBadRequest},
+		{"", "bogus: true\n", http.StatusBadRequest},
+		{"", "routes_v2:\n  - name: broken\n    match:\n      path_prefix: /broken\n    upstream:\n      cluster: missing\n", http.StatusBadRequest},
+		{"?mode=replace", "server:\n  listen: \":8080\"\nclusters: []\nroutes_v2:\n  - name: web-api\n    upstream:\n      cluster: web\n", http.StatusBadRequest},
+		{"", strings.Repeat("#", maxCandidateBytes+1), http.StatusRequestEntityTooLarge},
+	} {
+		if w := doAdmin(s, http.MethodPost, "/api/v1/config/import"+tt.query, tt.body); w.Code != tt.want {
+			t.Errorf("%s %.40q: expected %d, got %d: %s", tt.query, tt.body, tt.want, w.Code, w.Body)
+		}
+	}
+	if s.versionManager.Len() != 0 || len(s.configLoader.Current().RoutesV2) != 1 {
+		t.Error("failed imports changed the configuration")
+	}
+}
@@ -0,0 +1,37 @@
+package admin
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/oriys/nexus/internal/runtime"
+)
+
+// purgeCache handles DELETE /api/v1/cache, removing the responses of the
+// route named by the route query parameter from the response cache, or
+// every cached response without one.
+func (s *Server) purgeCache(w http.ResponseWriter, r *http.Request) {
+	route := r.URL.Query().Get("route")
+	if route != "" && !s.hasRouteV2(route) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "route '" + route + "' not found"})
+		return
+	}
+	n := runtime.PurgeResponseCache(route)
+	slog.Info("response cache purged", slog.String("route", route), slog.Int("purged", n))
+	writeJSON(w, http.StatusOK, map[string]any{"purged": n})
+}
+
+// hasRouteV2 reports whether the current configuration has a V2 route
+// named name.
+func (s *Server) hasRouteV2(name string) bool {
+	cfg := s.configLoader.Current()
+	if cfg == nil {
+		return false
+	}
+	for _, route := range cfg.RoutesV2 {
+		if route.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,24 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestPurgeCache(t *testing.T) {
+	s, _ := setupClusterAdmin(t)
+
+	for _, path := range []string{"/api/v1/cache", "/api/v1/cache?route=web-api"} {
+		w := doAdmin(s, http.MethodDelete, path, "")
+		var resp struct {
+			Purged *int `json:"purged"`
+		}
+		if w.Code != http.StatusOK || json.NewDecoder(w.Body).Decode(&resp) != nil || resp.Purged == nil {
+			t.Errorf("DELETE %s: status %d: %s", path, w.Code, w.Body)
+		}
+	}
+	if w := doAdmin(s, http.MethodDelete, "/api/v1/cache?route=missing", ""); w.Code != http.StatusNotFound {
+		t.Errorf("unknown route: expected 404, got %d", w.Code)
+	}
+}
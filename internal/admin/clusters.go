@@ -0,0 +1,353 @@
+package admin
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/oriys/nexus/internal/config"
+	"github.com/oriys/nexus/internal/logging"
+	"github.com/oriys/nexus/internal/proxy"
+	"github.com/oriys/nexus/internal/runtime"
+)
+
+// maxConfigBodyBytes bounds the upstream and cluster definitions accepted.
+const maxConfigBodyBytes = 1 << 20
+
+// listClusters handles GET /api/v1/clusters.
+func (s *Server) listClusters(w http.ResponseWriter, r *http.Request) {
+	cfg := s.configLoader.Current()
+	if cfg == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "no configuration loaded"})
+		return
+	}
+	clusters := config.Redacted(cfg).Clusters
+	if clusters == nil {
+		clusters = []config.Cluster{}
+	}
+	writeJSON(w, http.StatusOK, clusters)
+}
+
+// createUpstream handles POST /api/v1/upstreams to add an upstream.
+func (s *Server) createUpstream(w http.ResponseWriter, r *http.Request) {
+	var u config.Upstream
+	if !decodeConfigBody(w, r, &u) {
+		return
+	}
+	if u.Name == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "upstream name is required"})
+		return
+	}
+	s.configMu.Lock()
+	defer s.configMu.Unlock()
+	cfg := s.currentConfig(w)
+	if cfg == nil {
+		return
+	}
+	for _, existing := range cfg.Upstreams {
+		if existing.Name == u.Name {
+			writeJSON(w, http.StatusConflict, map[string]string{"error": "upstream with name '" + u.Name + "' already exists"})
+			return
+		}
+	}
+	next := *cfg
+	next.Upstreams = append(append([]config.Upstream(nil), cfg.Upstreams...), u)
+	if !s.applyConfig(w, &next) {
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]string{"message": "upstream created successfully", "name": u.Name})
+}
+
+// updateUpstream handles PUT /api/v1/upstreams/{name} to replace an upstream.
+func (s *Server) updateUpstream(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	var u config.Upstream
+	if !decodeConfigBody(w, r, &u) {
+		return
+	}
+	u.Name = name
+	s.configMu.Lock()
+	defer s.configMu.Unlock()
+	cfg := s.currentConfig(w)
+	if cfg == nil {
+		return
+	}
+	next := *cfg
+	next.Upstreams = append([]config.Upstream(nil), cfg.Upstreams...)
+	found := false
+	for i := range next.Upstreams {
+		if next.Upstreams[i].Name == name {
+			next.Upstreams[i] = u
+			found = true
+			break
+		}
+	}
+	if !found {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "upstream '" + name + "' not found"})
+		return
+	}
+	if !s.applyConfig(w, &next) {
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"message": "upstream updated successfully", "name": name})
+}
+
+// deleteUpstream handles DELETE /api/v1/upstreams/{name}. Upstreams that
+// routes still reference cannot be deleted.
+func (s *Server) deleteUpstream(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	s.configMu.Lock()
+	defer s.configMu.Unlock()
+	cfg := s.currentConfig(w)
+	if cfg == nil {
+		return
+	}
+	next := *cfg
+	next.Upstreams = make([]config.Upstream, 0, len(cfg.Upstreams))
+	for _, existing := range cfg.Upstreams {
+		if existing.Name != name {
+			next.Upstreams = append(next.Upstreams, existing)
+		}
+	}
+	if len(next.Upstreams) == len(cfg.Upstreams) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "upstream '" + name + "' not found"})
+		return
+	}
+	var refs []string
+	for _, route := range cfg.Routes {
+		if route.Upstream == name {
+			refs = append(refs, route.Name)
+		}
+	}
+	if len(refs) > 0 {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": "upstream '" + name + "' is used by routes: " + strings.Join(refs, ", ")})
+		return
+	}
+	if !s.applyConfig(w, &next) {
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"message": "upstream deleted successfully", "name": name})
+}
+
+// createCluster handles POST /api/v1/clusters to add a cluster.
+func (s *Server) createCluster(w http.ResponseWriter, r *http.Request) {
+	var c config.Cluster
+	if !decodeConfigBody(w, r, &c) {
+		return
+	}
+	if c.Name == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "cluster name is required"})
+		return
+	}
+	s.configMu.Lock()
+	defer s.configMu.Unlock()
+	cfg := s.currentConfig(w)
+	if cfg == nil {
+		return
+	}
+	for _, existing := range cfg.Clusters {
+		if existing.Name == c.Name {
+			writeJSON(w, http.StatusConflict, map[string]string{"error": "cluster with name '" + c.Name + "' already exists"})
+			return
+		}
+	}
+	next := *cfg
+	next.Clusters = append(append([]config.Cluster(nil), cfg.Clusters...), c)
+	if !s.applyConfig(w, &next) {
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]string{"message": "cluster created successfully", "name": c.Name})
+}
+
+// updateCluster handles PUT /api/v1/clusters/{name} to replace a cluster.
+// The routes using it are recompiled against the new definition.
+func (s *Server) updateCluster(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	var c config.Cluster
+	if !decodeConfigBody(w, r, &c) {
+		return
+	}
+	c.Name = name
+	s.configMu.Lock()
+	defer s.configMu.Unlock()
+	cfg := s.currentConfig(w)
+	if cfg == nil {
+		return
+	}
+	next := *cfg
+	next.Clusters = append([]config.Cluster(nil), cfg.Clusters...)
+	found := false
+	for i := range next.Clusters {
+		if next.Clusters[i].Name == name {
+			next.Clusters[i] = c
+			found = true
+			break
+		}
+	}
+	if !found {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "cluster '" + name + "' not found"})
+		return
+	}
+	if !s.applyConfig(w, &next) {
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"message": "cluster updated successfully", "name": name})
+}
+
+// deleteCluster handles DELETE /api/v1/clusters/{name}. Clusters that
+// routes still reference, directly or as a federation subgraph, cannot be
+// deleted.
+func (s *Server) deleteCluster(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	s.configMu.Lock()
+	defer s.configMu.Unlock()
+	cfg := s.currentConfig(w)
+	if cfg == nil {
+		return
+	}
+	next := *cfg
+	next.Clusters = make([]config.Cluster, 0, len(cfg.Clusters))
+	for _, existing := range cfg.Clusters {
+		if existing.Name != name {
+			next.Clusters = append(next.Clusters, existing)
+		}
+	}
+	if len(next.Clusters) == len(cfg.Clusters) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "cluster '" + name + "' not found"})
+		return
+	}
+	if refs := clusterReferences(cfg, name); len(refs) > 0 {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": "cluster '" + name + "' is used by routes: " + strings.Join(refs, ", ")})
+		return
+	}
+	if !s.applyConfig(w, &next) {
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"message": "cluster deleted successfully", "name": name})
+}
+
+// clusterReferences returns the V2 routes using the named cluster.
+func clusterReferences(cfg *config.Config, name string) []string {
+	var refs []string
+	for _, route := range cfg.RoutesV2 {
+		uses := slices.Contains(route.Upstream.ClusterNames(), name)
+		if m := route.Upstream.Mirror; m != nil {
+			uses = uses || m.Cluster == name
+		}
+		if g := route.Upstream.GraphQL; g != nil && g.Federation != nil {
+			for _, sg := range g.Federation.Subgraphs {
+				uses = uses || sg.Cluster == name
+			}
+		}
+		if uses {
+			refs = append(refs, route.Name)
+		}
+	}
+	return refs
+}
+
+// decodeConfigBody decodes a request body using the field names of the
+// configuration file. JSON bodies are accepted, JSON being valid YAML.
+func decodeConfigBody(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	dec := yaml.NewDecoder(io.LimitReader(r.Body, maxConfigBodyBytes))
+	dec.KnownFields(true)
+	if err := dec.Decode(v); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body: " + err.Error()})
+		return false
+	}
+	return true
+}
+
+// currentConfig returns the loaded configuration, writing a 503 response and
+// returning nil when none is available.
+func (s *Server) currentConfig(w http.ResponseWriter) *config.Config {
+	cfg := s.configLoader.Current()
+	if cfg == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "no configuration loaded"})
+	}
+	return cfg
+}
+
+// applyConfig validates next and makes it the active configuration: the V2
+// runtime is recompiled and swapped atomically, the legacy routes and
+// upstreams are reloaded, the configuration is persisted when a persister is
+// set and the change is recorded as a new version. A configuration that
+// fails validation (400), compilation (409) or persisting (500) leaves the
+// gateway untouched. Callers hold configMu.
+func (s *Server) applyConfig(w http.ResponseWriter, next *config.Config) bool {
+	data, ok := s.commitConfig(w, next)
+	if ok {
+		s.versionManager.Save(next, data)
+	}
+	return ok
+}
+
+// commitConfig does the work of applyConfig without recording a version,
+// returning the serialized configuration.
+func (s *Server) commitConfig(w http.ResponseWriter, next *config.Config) ([]byte, bool) {
+	if err := config.Validate(next); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return nil, false
+	}
+	if err := s.checkPluginSettings(next); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return nil, false
+	}
+	compiled, err := s.prepareConfig(next)
+	if err != nil {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+		return nil, false
+	}
+	data, err := yaml.Marshal(next)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "serialize configuration: " + err.Error()})
+		return nil, false
+	}
+	if s.persister != nil {
+		if err := s.persister.Persist(next, data); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "persist configuration: " + err.Error()})
+			return nil, false
+		}
+	}
+	s.activateConfig(next, compiled)
+	return data, true
+}
+
+// prepareConfig compiles the V2 runtime of next without serving it. The
+// result is nil when there is nothing to activate.
+func (s *Server) prepareConfig(next *config.Config) (*runtime.CompiledConfig, error) {
+	usesV2 := next.UsesV2()
+	switch {
+	case s.runtimeStore != nil && (usesV2 || s.runtimeStore.Load() != nil):
+		// Once compiled, the store keeps being updated so that removing the
+		// last route stops serving it.
+		return runtime.Prepare(next, s.runtimeStore)
+	case usesV2:
+		// Without a runtime store the V2 configuration is still compiled as
+		// a dry run.
+		return nil, runtime.Check(next, nil)
+	}
+	return nil, nil
+}
+
+// activateConfig serves next: the compiled V2 runtime is swapped in, the
+// legacy routes and upstreams are replaced together, and a changed
+// logging.level is applied.
+func (s *Server) activateConfig(next *config.Config, compiled *runtime.CompiledConfig) {
+	if compiled != nil {
+		runtime.Activate(compiled, s.runtimeStore)
+	}
+	proxy.Apply(s.router, s.upstreamMgr, next)
+	if s.plugins != nil {
+		s.plugins.Configure(next.Plugins)
+	}
+	s.configLoader.Set(next)
+	logging.Configure(&next.Logging)
+	for _, c := range config.RouteConflicts(next) {
+		slog.Warn("route conflict", slog.String("conflict", c))
+	}
+}
@@ -0,0 +1,208 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oriys/nexus/internal/config"
+	"github.com/oriys/nexus/internal/proxy"
+	"github.com/oriys/nexus/internal/runtime"
+)
+
+const testClusterConfig = testConfig + `clusters:
+  - name: web
+    type: http
+    endpoints:
+      - url: "http://127.0.0.1:9001"
+routes_v2:
+  - name: web-api
+    match:
+      path_prefix: /web
+    upstream:
+      cluster: web
+`
+
+func setupClusterAdmin(t *testing.T) (*Server, *runtime.ConfigStore) {
+	t.Helper()
+	cfgPath := filepath.Join(t.TempDir(), "nexus.yaml")
+	if err := os.WriteFile(cfgPath, []byte(testClusterConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cl := config.NewLoader(cfgPath)
+	cfg, err := cl.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	um := proxy.NewUpstreamManager()
+	um.Reload(cfg.Upstreams)
+	store := runtime.NewConfigStore()
+	if _, err := runtime.CompileAndStore(cfg, store); err != nil {
+		t.Fatal(err)
+	}
+	s := New(cl, config.NewVersionManager(10), proxy.NewRouter(), um)
+	s.SetConfigStore(store)
+	return s, store
+}
+
+func doAdmin(s *Server, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, req)
+	return w
+}
+
+func TestClusters_CRUD(t *testing.T) {
+	s, store := setupClusterAdmin(t)
+
+	w := doAdmin(s, http.MethodPost, "/api/v1/clusters", `{"name":"gql","type":"graphql","endpoints":[{"url":"http://127.0.0.1:4000"}],"graphql":{"max_body_bytes":1024}}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body)
+	}
+	if c := store.Load().Clusters["gql"]; c == nil || c.GraphQL == nil || c.GraphQL.MaxBodyBytes != 1024 {
+		t.Fatalf("cluster not compiled: %+v", c)
+	}
+	if len(s.configLoader.Current().Clusters) != 2 || s.versionManager.Len() != 1 {
+		t.Errorf("change not recorded: %d clusters, %d versions", len(s.configLoader.Current().Clusters), s.versionManager.Len())
+	}
+
+	for _, tt := range []struct {
+		method, path, body string
+		want               int
+	}{
+		{http.MethodPost, "/api/v1/clusters", `{"name":"gql","endpoints":[{"url":"http://x"}]}`, http.StatusConflict},
+		{http.MethodPost, "/api/v1/clusters", `{"name":"typo","endpoint":[]}`, http.StatusBadRequest},
+		{http.MethodPost, "/api/v1/clusters", `{"name":"bad","type":"ftp","endpoints":[{"url":"http://x"}]}`, http.StatusBadRequest},
+		{http.MethodPost, "/api/v1/clusters", `{"type":"http"}`, http.StatusBadRequest},
+		{http.MethodPut, "/api/v1/clusters/missing", `{"endpoints":[{"url":"http://x"}]}`, http.StatusNotFound},
+		{http.MethodPut, "/api/v1/clusters/web", `{"type":"http","endpoints":[]}`, http.StatusBadRequest},
+		{http.MethodDelete, "/api/v1/clusters/missing", "", http.StatusNotFound},
+	} {
+		if w := doAdmin(s, tt.method, tt.path, tt.body); w.Code != tt.want {
+			t.Errorf("%s %s %s: expected %d, got %d: %s", tt.method, tt.path, tt.body, tt.want, w.Code, w.Body)
+		}
+	}
+
+	// Updating a cluster recompiles the routes using it.
+	if w := doAdmin(s, http.MethodPut, "/api/v1/clusters/web", `{"type":"http","endpoints":[{"url":"http://127.0.0.1:9002"}]}`); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+	compiled := store.Load()
+	if eps := compiled.Clusters["web"].Endpoints; len(eps) != 1 || eps[0].URL != "http://127.0.0.1:9002" {
+		t.Errorf("cluster not updated: %+v", eps)
+	}
+	if route, ok := compiled.Router.Match(httptest.NewRequest("GET", "/web/x", nil)); !ok || route.Upstream.ClusterName != "web" {
+		t.Error("route lost after cluster update")
+	}
+
+	w = doAdmin(s, http.MethodDelete, "/api/v1/clusters/web", "")
+	if w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), "web-api") {
+		t.Errorf("expected 409 naming the route, got %d: %s", w.Code, w.Body)
+	}
+	if w := doAdmin(s, http.MethodDelete, "/api/v1/clusters/gql", ""); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+	if _, ok := store.Load().Clusters["gql"]; ok {
+		t.Error("deleted cluster still compiled")
+	}
+	if w := doAdmin(s, http.MethodGet, "/api/v1/clusters", ""); w.Code != http.StatusOK || strings.Contains(w.Body.String(), "gql") {
+		t.Errorf("unexpected listing %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestUpstreams_CRUD(t *testing.T) {
+	s, _ := setupClusterAdmin(t)
+
+	if w := doAdmin(s, http.MethodPost, "/api/v1/upstreams", `{"name":"extra","targets":[{"address":"127.0.0.1:9100","weight":1}]}`); w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body)
+	}
+	if target, ok := s.upstreamMgr.GetTarget("extra"); !ok || target != "127.0.0.1:9100" {
+		t.Errorf("upstream not reloaded: %q %v", target, ok)
+	}
+
+	for _, tt := range []struct {
+		method, path, body string
+		want               int
+	}{
+		{http.MethodPost, "/api/v1/upstreams", `{"name":"extra","targets":[{"address":"127.0.0.1:1"}]}`, http.StatusConflict},
+		{http.MethodPost, "/api/v1/upstreams", `{"name":"empty"}`, http.StatusBadRequest},
+		{http.MethodPut, "/api/v1/upstreams/backend", `{"targets":[]}`, http.StatusBadRequest},
+		{http.MethodPut, "/api/v1/upstreams/missing", `{"targets":[{"address":"127.0.0.1:1"}]}`, http.StatusNotFound},
+		{http.MethodDelete, "/api/v1/upstreams/backend", "", http.StatusConflict},
+		{http.MethodDelete, "/api/v1/upstreams/missing", "", http.StatusNotFound},
+	} {
+		if w := doAdmin(s, tt.method, tt.path, tt.body); w.Code != tt.want {
+			t.Errorf("%s %s %s: expected %d, got %d: %s", tt.method, tt.path, tt.body, tt.want, w.Code, w.Body)
+		}
+	}
+	if target, _ := s.upstreamMgr.GetTarget("backend"); target != "127.0.0.1:9001" {
+		t.Errorf("rejected change applied: %q", target)
+	}
+
+	if w := doAdmin(s, http.MethodPut, "/api/v1/upstreams/extra", `{"targets":[{"address":"127.0.0.1:9200"}]}`); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+	if target, _ := s.upstreamMgr.GetTarget("extra"); target != "127.0.0.1:9200" {
+		t.Errorf("upstream not updated: %q", target)
+	}
+	if w := doAdmin(s, http.MethodDelete, "/api/v1/upstreams/extra", ""); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+	if _, ok := s.upstreamMgr.GetTarget("extra"); ok {
+		t.Error("deleted upstream still served")
+	}
+}
+
+type failingPersister struct{}
+
+func (failingPersister) Persist(*config.Config, []byte) error { return errors.New("disk full") }
+
+func TestApplyConfig_Persists(t *testing.T) {
+	s, store := setupClusterAdmin(t)
+	s.SetPersister(s.configLoader)
+
+	if w := doAdmin(s, http.MethodPost, "/api/v1/routes", `{"name":"legacy","paths":[{"path":"/legacy","type":"prefix"}],"upstream":"backend"}`); w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body)
+	}
+	if w := doAdmin(s, http.MethodPost, "/api/v1/routes-v2", `{"name":"orders","match":{"path_prefix":"/orders"},"upstream":{"cluster":"web"}}`); w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body)
+	}
+	if s.versionManager.Len() != 2 {
+		t.Errorf("expected a version per change, got %d", s.versionManager.Len())
+	}
+
+	// A restart sees both changes.
+	restarted, err := config.NewLoader(s.configLoader.Path()).Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(restarted.Routes) != 2 || len(restarted.RoutesV2) != 2 || restarted.Server.ReadTimeout != 30*time.Second {
+		t.Errorf("unexpected persisted config: %d routes, %d v2 routes, read timeout %v", len(restarted.Routes), len(restarted.RoutesV2), restarted.Server.ReadTimeout)
+	}
+
+	// Rolling back is persisted too.
+	if w := doAdmin(s, http.MethodPost, "/api/v1/config/rollback", ""); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+	if restarted, _ := config.NewLoader(s.configLoader.Path()).Load(); len(restarted.RoutesV2) != 1 {
+		t.Errorf("rollback not persisted: %d v2 routes", len(restarted.RoutesV2))
+	}
+	if _, ok := store.Load().Router.Match(httptest.NewRequest("GET", "/orders", nil)); ok {
+		t.Error("rolled back route still served")
+	}
+
+	// A change that cannot be persisted is not applied.
+	s.SetPersister(failingPersister{})
+	before, current := store.Load(), s.configLoader.Current()
+	if w := doAdmin(s, http.MethodPost, "/api/v1/routes-v2", `{"name":"lost","match":{"path":"/lost"},"upstream":{"cluster":"web"}}`); w.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500, got %d: %s", w.Code, w.Body)
+	}
+	if store.Load() != before || s.configLoader.Current() != current || s.versionManager.Len() != 3 {
+		t.Error("unpersisted change applied")
+	}
+}
@@ -0,0 +1,242 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/oriys/nexus/internal/dubbo"
+	"github.com/oriys/nexus/internal/runtime"
+)
+
+// defaultDubboDebugTimeout bounds a debug invocation that sets no timeout_ms.
+const defaultDubboDebugTimeout = 10 * time.Second
+
+// listDubboServices handles GET /api/v1/dubbo/services, returning the
+// interfaces, methods, groups, versions and provider addresses found in the
+// registry of each Dubbo cluster that configures one. A registry that cannot
+// be queried is reported with its error rather than failing the listing.
+func (s *Server) listDubboServices(w http.ResponseWriter, r *http.Request) {
+	compiled := s.compiledConfig(w)
+	if compiled == nil {
+		return
+	}
+
+	type clusterServices struct {
+		Cluster  string              `json:"cluster"`
+		Registry string              `json:"registry"`
+		Services []dubbo.ServiceInfo `json:"services"`
+		Error    string              `json:"error,omitempty"`
+	}
+	result := make([]clusterServices, 0)
+	for _, c := range compiled.Clusters {
+		if c.DubboRegistry == nil {
+			continue
+		}
+		cs := clusterServices{Cluster: c.Name, Registry: c.Dubbo.Registry.Address, Services: []dubbo.ServiceInfo{}}
+		if services, err := c.DubboRegistry.Services(r.Context()); err != nil {
+			cs.Error = err.Error()
+		} else {
+			cs.Services = services
+		}
+		result = append(result, cs)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Cluster < result[j].Cluster })
+	writeJSON(w, http.StatusOK, result)
+}
+
+// listDubboConnections handles GET /api/v1/dubbo/connections, returning the
+// connection pool state and call counters for each provider of the native
+// Dubbo clusters.
+func (s *Server) listDubboConnections(w http.ResponseWriter, r *http.Request) {
+	compiled := s.compiledConfig(w)
+	if compiled == nil {
+		return
+	}
+
+	type clusterConnections struct {
+		Cluster   string                `json:"cluster"`
+		Providers []dubbo.ProviderStats `json:"providers"`
+	}
+	result := make([]clusterConnections, 0)
+	for _, c := range compiled.Clusters {
+		if stats := c.DubboConnections(); stats != nil {
+			result = append(result, clusterConnections{Cluster: c.Name, Providers: stats})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Cluster < result[j].Cluster })
+	writeJSON(w, http.StatusOK, result)
+}
+
+// dubboDebugRequest is the body of POST /api/v1/debug/dubbo.
+type dubboDebugRequest struct {
+	Cluster string `json:"cluster"`
+	// Address selects one of the cluster's endpoints; by default the next
+	// endpoint in round-robin order is used.
+	Address     string            `json:"address,omitempty"`
+	Interface   string            `json:"interface"`
+	Method      string            `json:"method"`
+	ParamTypes  []string          `json:"param_types,omitempty"`
+	Args        []interface{}     `json:"args,omitempty"`
+	Group       string            `json:"group,omitempty"`
+	Version     string            `json:"version,omitempty"`
+	Attachments map[string]string `json:"attachments,omitempty"`
+	Generic     bool              `json:"generic,omitempty"`
+	TimeoutMs   int               `json:"timeout_ms,omitempty"`
+}
+
+// dubboDebugResponse reports a debug invocation. Request and response bodies
+// are the raw bytes exchanged with the provider, base64-encoded.
+type dubboDebugResponse struct {
+	Cluster    string  `json:"cluster"`
+	Provider   string  `json:"provider"`
+	Protocol   string  `json:"protocol"`
+	DurationMs float64 `json:"duration_ms"`
+	Request    struct {
+		Interface   string            `json:"interface"`
+		Method      string            `json:"method"`
+		Group       string            `json:"group,omitempty"`
+		Version     string            `json:"version,omitempty"`
+		ParamTypes  []string          `json:"param_types"`
+		Args        []interface{}     `json:"args"`
+		Attachments map[string]string `json:"attachments,omitempty"`
+		Body        []byte            `json:"body,omitempty"`
+	} `json:"request"`
+	Response *dubboDebugResult `json:"response,omitempty"`
+	Error    string            `json:"error,omitempty"`
+}
+
+type dubboDebugResult struct {
+	Value       interface{}            `json:"value"`
+	Exception   string                 `json:"exception,omitempty"`
+	Message     string                 `json:"message,omitempty"`
+	Attachments map[string]interface{} `json:"attachments,omitempty"`
+	Body        []byte                 `json:"body,omitempty"`
+}
+
+// debugDubbo handles POST /api/v1/debug/dubbo. It invokes an arbitrary
+// interface and method on a Dubbo cluster through the gateway's own client
+// stack and reports the exchanged messages, so that route settings can be
+// checked against a provider before they are published. Provider errors and
+// exceptions are part of the report; only invalid debug requests fail.
+func (s *Server) debugDubbo(w http.ResponseWriter, r *http.Request) {
+	compiled := s.compiledConfig(w)
+	if compiled == nil {
+		return
+	}
+
+	var req dubboDebugRequest
+	dec := json.NewDecoder(r.Body)
+	dec.UseNumber()
+	if err := dec.Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body: " + err.Error()})
+		return
+	}
+	if req.Interface == "" || req.Method == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "interface and method are required"})
+		return
+	}
+	if len(req.Args) != len(req.ParamTypes) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("%d args given for %d param_types", len(req.Args), len(req.ParamTypes))})
+		return
+	}
+	cluster, ok := compiled.Clusters[req.Cluster]
+	if !ok || cluster.Type != "dubbo" {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": fmt.Sprintf("dubbo cluster %q not found", req.Cluster)})
+		return
+	}
+	addr, err := debugEndpoint(cluster, req.Address)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	inv := &dubbo.Invocation{
+		Interface:   req.Interface,
+		Method:      req.Method,
+		ParamTypes:  req.ParamTypes,
+		Args:        req.Args,
+		Group:       req.Group,
+		Version:     req.Version,
+		Attachments: req.Attachments,
+	}
+	if c := cluster.Dubbo; c != nil {
+		inv.Application = c.Application
+		if inv.Group == "" {
+			inv.Group = c.Group
+		}
+		if inv.Version == "" {
+			inv.Version = c.Version
+		}
+	}
+	if req.Generic {
+		inv = inv.Generic()
+	}
+
+	timeout := defaultDubboDebugTimeout
+	if req.TimeoutMs > 0 {
+		timeout = time.Duration(req.TimeoutMs) * time.Millisecond
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
+	start := time.Now()
+	res, err := runtime.InvokeDubbo(ctx, cluster, addr, inv)
+	if errors.Is(err, runtime.ErrDubboNotNative) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("cluster %q: %v", req.Cluster, err)})
+		return
+	}
+
+	out := dubboDebugResponse{
+		Cluster:    cluster.Name,
+		Provider:   addr,
+		Protocol:   "dubbo",
+		DurationMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if cluster.Dubbo.Protocol == "triple" {
+		out.Protocol = "triple"
+	}
+	out.Request.Interface = inv.Interface
+	out.Request.Method = inv.Method
+	out.Request.Group = inv.Group
+	out.Request.Version = inv.Version
+	out.Request.ParamTypes = inv.ParamTypes
+	out.Request.Args = inv.Args
+	out.Request.Attachments = inv.Attachments
+	if err != nil {
+		out.Error = err.Error()
+	} else {
+		out.Request.Body = res.RequestBody
+		out.Response = &dubboDebugResult{
+			Value:       res.Value,
+			Exception:   res.ExceptionType(),
+			Message:     res.Message(),
+			Attachments: res.Attachments,
+			Body:        res.ResponseBody,
+		}
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// debugEndpoint returns the cluster endpoint to call: the one whose address
+// matches addr, or the next one when addr is empty. Only configured
+// endpoints can be called.
+func debugEndpoint(cluster *runtime.CompiledCluster, addr string) (string, error) {
+	if addr == "" {
+		ep, ok := cluster.NextEndpoint()
+		if !ok {
+			return "", fmt.Errorf("cluster %q has no endpoints", cluster.Name)
+		}
+		return runtime.EndpointAddress(ep), nil
+	}
+	for _, ep := range cluster.Endpoints {
+		if runtime.EndpointAddress(ep) == addr {
+			return addr, nil
+		}
+	}
+	return "", fmt.Errorf("address %q is not an endpoint of cluster %q", addr, cluster.Name)
+}
@@ -0,0 +1,265 @@
+package admin
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/oriys/nexus/internal/config"
+	"github.com/oriys/nexus/internal/dubbo"
+	"github.com/oriys/nexus/internal/runtime"
+)
+
+// stubRegistry returns fixed services or an error.
+type stubRegistry struct {
+	services []dubbo.ServiceInfo
+	err      error
+}
+
+func (r *stubRegistry) Services(context.Context) ([]dubbo.ServiceInfo, error) {
+	return r.services, r.err
+}
+
+func TestListDubboServices(t *testing.T) {
+	s := setupAdmin(t)
+	store := runtime.NewConfigStore()
+	store.Store(&runtime.CompiledConfig{
+		Clusters: map[string]*runtime.CompiledCluster{
+			"order-dubbo": {
+				Name:  "order-dubbo",
+				Type:  "dubbo",
+				Dubbo: &config.ClusterDubbo{Registry: &config.DubboRegistry{Type: "nacos", Address: "http://nacos:8848"}},
+				DubboRegistry: &stubRegistry{services: []dubbo.ServiceInfo{{
+					Interface: "com.foo.OrderService", Version: "1.0.0",
+					Methods: []string{"CreateOrder"}, Providers: []string{"dubbo://10.0.0.1:20880"},
+				}}},
+			},
+			"pay-dubbo": {
+				Name:          "pay-dubbo",
+				Type:          "dubbo",
+				Dubbo:         &config.ClusterDubbo{Registry: &config.DubboRegistry{Type: "nacos", Address: "nacos-b:8848"}},
+				DubboRegistry: &stubRegistry{err: errors.New("connection refused")},
+			},
+			"static-dubbo": {Name: "static-dubbo", Type: "dubbo", Dubbo: &config.ClusterDubbo{}},
+		},
+	})
+	s.SetConfigStore(store)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/dubbo/services", nil)
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	var result []struct {
+		Cluster  string              `json:"cluster"`
+		Registry string              `json:"registry"`
+		Services []dubbo.ServiceInfo `json:"services"`
+		Error    string              `json:"error"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	if len(result) != 2 || result[0].Cluster != "order-dubbo" || result[1].Cluster != "pay-dubbo" {
+		t.Fatalf("expected only clusters with a registry, got %+v", result)
+	}
+	if len(result[0].Services) != 1 || result[0].Services[0].Interface != "com.foo.OrderService" || result[0].Registry != "http://nacos:8848" {
+		t.Errorf("unexpected services: %+v", result[0])
+	}
+	if result[1].Error != "connection refused" || result[1].Services == nil {
+		t.Errorf("expected registry error to be reported, got %+v", result[1])
+	}
+}
+
+func TestListDubboServices_NoRuntime(t *testing.T) {
+	s := setupAdmin(t)
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/dubbo/services", nil)
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d", w.Code)
+	}
+}
+
+func TestListDubboConnections(t *testing.T) {
+	s := setupAdmin(t)
+	store := runtime.NewConfigStore()
+	store.Store(&runtime.CompiledConfig{
+		Clusters: map[string]*runtime.CompiledCluster{
+			"order-dubbo": {
+				Name:      "order-dubbo",
+				Type:      "dubbo",
+				Endpoints: []config.ClusterEndpoint{{Addr: "dubbo://127.0.0.1:1"}},
+				Dubbo:     &config.ClusterDubbo{Serialization: "hessian2"},
+			},
+			"legacy-dubbo": {Name: "legacy-dubbo", Type: "dubbo", Dubbo: &config.ClusterDubbo{Serialization: "json"}},
+			"web":          {Name: "web", Type: "http"},
+		},
+	})
+	s.SetConfigStore(store)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/dubbo/connections", nil)
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	var result []struct {
+		Cluster   string                `json:"cluster"`
+		Providers []dubbo.ProviderStats `json:"providers"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	if len(result) != 1 || result[0].Cluster != "order-dubbo" || result[0].Providers == nil {
+		t.Fatalf("expected only the native dubbo cluster, got %+v", result)
+	}
+}
+
+// newEchoProvider starts a dubbo2 provider that answers every call with its
+// method name and first argument, or throws for the method "fail".
+func newEchoProvider(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { conn.Close() })
+			go func() {
+				r := bufio.NewReader(conn)
+				for {
+					f, err := dubbo.ReadFrame(r, dubbo.DefaultMaxBodySize)
+					if err != nil {
+						return
+					}
+					d := dubbo.NewDecoder(f.Body)
+					var values []interface{}
+					for d.Remaining() {
+						v, _ := d.Decode()
+						values = append(values, v)
+					}
+					var e dubbo.Encoder
+					if values[3] == "fail" {
+						e.WriteInt(0) // exception
+						e.Encode("boom")
+					} else {
+						e.WriteInt(1) // value
+						e.Encode([]interface{}{values[3], values[5]})
+					}
+					dubbo.WriteFrame(conn, &dubbo.Frame{ID: f.ID, Status: dubbo.StatusOK, Body: e.Bytes()})
+				}
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestDebugDubbo(t *testing.T) {
+	addr := newEchoProvider(t)
+	s := setupAdmin(t)
+	store := runtime.NewConfigStore()
+	store.Store(&runtime.CompiledConfig{
+		Clusters: map[string]*runtime.CompiledCluster{
+			"order-dubbo": {
+				Name:      "order-dubbo",
+				Type:      "dubbo",
+				Endpoints: []config.ClusterEndpoint{{Addr: "dubbo://" + addr}},
+				Dubbo:     &config.ClusterDubbo{Serialization: "hessian2", Version: "1.0.0"},
+			},
+			"legacy-dubbo": {
+				Name:      "legacy-dubbo",
+				Type:      "dubbo",
+				Endpoints: []config.ClusterEndpoint{{Addr: "http://127.0.0.1:1"}},
+				Dubbo:     &config.ClusterDubbo{Serialization: "json"},
+			},
+		},
+	})
+	s.SetConfigStore(store)
+
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/debug/dubbo", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		s.Handler().ServeHTTP(w, req)
+		return w
+	}
+
+	w := post(`{"cluster":"order-dubbo","interface":"com.foo.OrderService","method":"GetOrder","param_types":["long"],"args":[42],"attachments":{"trace":"t1"}}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var out struct {
+		Provider string `json:"provider"`
+		Protocol string `json:"protocol"`
+		Request  struct {
+			Version     string            `json:"version"`
+			Attachments map[string]string `json:"attachments"`
+			Body        []byte            `json:"body"`
+		} `json:"request"`
+		Response struct {
+			Value []interface{} `json:"value"`
+			Body  []byte        `json:"body"`
+		} `json:"response"`
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Error != "" || out.Provider != "dubbo://"+addr || out.Protocol != "dubbo" {
+		t.Fatalf("unexpected result %s", w.Body.String())
+	}
+	if len(out.Response.Value) != 2 || out.Response.Value[0] != "GetOrder" || out.Response.Value[1] != float64(42) {
+		t.Errorf("unexpected value %v", out.Response.Value)
+	}
+	if out.Request.Version != "1.0.0" || out.Request.Attachments["trace"] != "t1" {
+		t.Errorf("unexpected request %+v", out.Request)
+	}
+	if len(out.Request.Body) == 0 || len(out.Response.Body) == 0 {
+		t.Error("expected raw request and response bodies")
+	}
+
+	w = post(`{"cluster":"order-dubbo","interface":"com.foo.OrderService","method":"fail"}`)
+	var failed struct {
+		Response struct {
+			Message string `json:"message"`
+		} `json:"response"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &failed)
+	if w.Code != http.StatusOK || failed.Response.Message != "boom" {
+		t.Errorf("expected the exception in the report, got %d %s", w.Code, w.Body.String())
+	}
+
+	tests := []struct {
+		body   string
+		status int
+		want   string
+	}{
+		{`{"cluster":"order-dubbo","interface":"com.foo.OrderService"}`, http.StatusBadRequest, "required"},
+		{`{"cluster":"order-dubbo","interface":"I","method":"m","param_types":["long"]}`, http.StatusBadRequest, "0 args given for 1 param_types"},
+		{`{"cluster":"missing","interface":"I","method":"m"}`, http.StatusNotFound, "not found"},
+		{`{"cluster":"legacy-dubbo","interface":"I","method":"m"}`, http.StatusBadRequest, "natively"},
+		{`{"cluster":"order-dubbo","address":"dubbo://10.0.0.9:20880","interface":"I","method":"m"}`, http.StatusBadRequest, "not an endpoint"},
+		{`{`, http.StatusBadRequest, "invalid request body"},
+	}
+	for _, tt := range tests {
+		w := post(tt.body)
+		if w.Code != tt.status || !strings.Contains(w.Body.String(), tt.want) {
+			t.Errorf("%s: expected %d containing %q, got %d %s", tt.body, tt.status, tt.want, w.Code, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/oriys/nexus/internal/graphql"
+)
+
+// maxPersistedQueryManifestSize bounds the size of an uploaded manifest.
+const maxPersistedQueryManifestSize = 16 << 20
+
+// persistedQueryOperation is one entry of a persisted query manifest, in the
+// format of Apollo's persisted query manifests. Other fields are ignored.
+type persistedQueryOperation struct {
+	ID   string `json:"id"`
+	Body string `json:"body"`
+}
+
+// getPersistedQueries handles GET /api/v1/graphql/persisted-queries,
+// returning the store's counters and the registered manifest.
+func (s *Server) getPersistedQueries(w http.ResponseWriter, r *http.Request) {
+	if s.runtimeStore == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "runtime configuration not available"})
+		return
+	}
+	store := s.runtimeStore.PersistedQueries()
+	ops := make([]persistedQueryOperation, 0)
+	for id, body := range store.Manifest() {
+		ops = append(ops, persistedQueryOperation{ID: id, Body: body})
+	}
+	sort.Slice(ops, func(i, j int) bool { return ops[i].ID < ops[j].ID })
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"stats":      store.Stats(),
+		"operations": ops,
+	})
+}
+
+// syncPersistedQueries handles PUT /api/v1/graphql/persisted-queries,
+// replacing the manifest of pre-registered queries. The body is a manifest
+// of the form {"operations": [{"id": "<sha256>", "body": "<query>"}]}; every
+// id must be the SHA-256 hash of its body and every body a valid document.
+// An empty list clears the manifest.
+func (s *Server) syncPersistedQueries(w http.ResponseWriter, r *http.Request) {
+	if s.runtimeStore == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "runtime configuration not available"})
+		return
+	}
+	var manifest struct {
+		Operations []persistedQueryOperation `json:"operations"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxPersistedQueryManifestSize)).Decode(&manifest); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body: " + err.Error()})
+		return
+	}
+
+	queries := make(map[string]string, len(manifest.Operations))
+	for i, op := range manifest.Operations {
+		if _, err := graphql.Parse(op.Body); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("operations[%d]: %v", i, err)})
+			return
+		}
+		queries[op.ID] = op.Body
+	}
+	store := s.runtimeStore.PersistedQueries()
+	if err := store.SetManifest(queries); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, store.Stats())
+}
@@ -0,0 +1,78 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/oriys/nexus/internal/graphql"
+	"github.com/oriys/nexus/internal/runtime"
+)
+
+func TestPersistedQueries_Sync(t *testing.T) {
+	s := setupAdmin(t)
+	store := runtime.NewConfigStore()
+	s.SetConfigStore(store)
+
+	query := "query GetUser { user { id } }"
+	hash := graphql.QueryHash(query)
+	body := `{"format":"apollo-persisted-query-manifest","version":1,"operations":[{"id":"` + hash + `","name":"GetUser","type":"query","body":"` + query + `"}]}`
+	req := httptest.NewRequest(http.MethodPut, "/api/v1/graphql/persisted-queries", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+	if q, ok := store.PersistedQueries().Lookup(hash, true); !ok || q != query {
+		t.Fatalf("manifest query not registered: %q %v", q, ok)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/graphql/persisted-queries", nil)
+	w = httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, req)
+	var listing struct {
+		Stats      graphql.PersistedQueryStats `json:"stats"`
+		Operations []persistedQueryOperation   `json:"operations"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &listing); err != nil {
+		t.Fatal(err)
+	}
+	if listing.Stats.ManifestEntries != 1 || len(listing.Operations) != 1 || listing.Operations[0].ID != hash {
+		t.Errorf("unexpected listing %s", w.Body)
+	}
+
+	for _, bad := range []string{
+		`{"operations":[{"id":"` + strings.Repeat("0", 64) + `","body":"` + query + `"}]}`,
+		`{"operations":[{"id":"` + graphql.QueryHash("{ a ") + `","body":"{ a "}]}`,
+		`not json`,
+	} {
+		req = httptest.NewRequest(http.MethodPut, "/api/v1/graphql/persisted-queries", strings.NewReader(bad))
+		w = httptest.NewRecorder()
+		s.Handler().ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected 400 for %s, got %d", bad, w.Code)
+		}
+	}
+	if _, ok := store.PersistedQueries().Lookup(hash, true); !ok {
+		t.Error("rejected sync replaced the manifest")
+	}
+
+	req = httptest.NewRequest(http.MethodPut, "/api/v1/graphql/persisted-queries", strings.NewReader(`{"operations":[]}`))
+	w = httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, req)
+	if w.Code != http.StatusOK || store.PersistedQueries().Stats().ManifestEntries != 0 {
+		t.Errorf("expected the manifest to be cleared, got %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestPersistedQueries_NoRuntime(t *testing.T) {
+	s := setupAdmin(t)
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/graphql/persisted-queries", nil)
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, req)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d", w.Code)
+	}
+}
@@ -0,0 +1,63 @@
+package admin
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/oriys/nexus/internal/config"
+	"github.com/oriys/nexus/internal/logging"
+)
+
+// logLevel is the body of GET and PUT /api/v1/logging.
+type logLevel struct {
+	// Level is the level in effect.
+	Level string `json:"level"`
+	// Configured is the level of the configuration, which reloads restore
+	// when they change it.
+	Configured string `json:"configured_level,omitempty"`
+}
+
+// getLogging handles GET /api/v1/logging, reporting the log level.
+func (s *Server) getLogging(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.logLevel())
+}
+
+// setLogging handles PUT /api/v1/logging, changing the log level, e.g.
+// with {"level": "debug"}, without touching the configuration: the level
+// holds until the gateway restarts or a reload changes logging.level.
+func (s *Server) setLogging(w http.ResponseWriter, r *http.Request) {
+	var req logLevel
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body: " + err.Error()})
+		return
+	}
+	level, err := config.ParseLogLevel(req.Level)
+	if err != nil || req.Level == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "level must be debug, info, warn or error"})
+		return
+	}
+	prev := logging.Level()
+	logging.SetLevel(level)
+	// Logged at the higher of both levels, and at least info, so that the
+	// change is not filtered out.
+	slog.Log(r.Context(), max(prev, level, slog.LevelInfo), "log level changed",
+		slog.String("from", levelName(prev)),
+		slog.String("to", levelName(level)),
+	)
+	writeJSON(w, http.StatusOK, s.logLevel())
+}
+
+func (s *Server) logLevel() logLevel {
+	resp := logLevel{Level: levelName(logging.Level())}
+	if cfg := s.configLoader.Current(); cfg != nil {
+		resp.Configured = levelName(cfg.Logging.LogLevel())
+	}
+	return resp
+}
+
+// levelName returns the configuration name of l, e.g. "debug".
+func levelName(l slog.Level) string {
+	return strings.ToLower(l.String())
+}
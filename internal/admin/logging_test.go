@@ -0,0 +1,41 @@
+package admin
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"testing"
+
+	"github.com/oriys/nexus/internal/config"
+	"github.com/oriys/nexus/internal/logging"
+)
+
+func TestLogging(t *testing.T) {
+	s, _ := setupClusterAdmin(t)
+	logging.Configure(&config.LoggingConfig{})
+	defer logging.SetLevel(slog.LevelInfo)
+
+	w := doAdmin(s, http.MethodPut, "/api/v1/logging", `{"level": "debug"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+	if logging.Level() != slog.LevelDebug {
+		t.Errorf("level %v, want debug", logging.Level())
+	}
+	if w := doAdmin(s, http.MethodPut, "/api/v1/logging", `{"level": "trace"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("unknown level: expected 400, got %d", w.Code)
+	}
+
+	// A configuration change leaving logging.level alone keeps the level.
+	if w := doAdmin(s, http.MethodPut, "/api/v1/maintenance", `{"enabled": false}`); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+	w = doAdmin(s, http.MethodGet, "/api/v1/logging", "")
+	var got logLevel
+	if w.Code != http.StatusOK || json.NewDecoder(w.Body).Decode(&got) != nil {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+	if got != (logLevel{Level: "debug", Configured: "info"}) {
+		t.Errorf("got %+v", got)
+	}
+}
@@ -0,0 +1,76 @@
+package admin
+
+import (
+	"net/http"
+
+	"github.com/oriys/nexus/internal/config"
+)
+
+// getMaintenance handles GET /api/v1/maintenance, returning the global
+// maintenance switch.
+func (s *Server) getMaintenance(w http.ResponseWriter, r *http.Request) {
+	cfg := s.currentConfig(w)
+	if cfg == nil {
+		return
+	}
+	m := cfg.Maintenance
+	if m == nil {
+		m = &config.Maintenance{}
+	}
+	writeJSON(w, http.StatusOK, m)
+}
+
+// setMaintenance handles PUT /api/v1/maintenance, replacing the global
+// maintenance switch, e.g. with {"enabled": true}.
+func (s *Server) setMaintenance(w http.ResponseWriter, r *http.Request) {
+	var m config.Maintenance
+	if !decodeConfigBody(w, r, &m) {
+		return
+	}
+	s.configMu.Lock()
+	defer s.configMu.Unlock()
+	cfg := s.currentConfig(w)
+	if cfg == nil {
+		return
+	}
+	next := *cfg
+	next.Maintenance = &m
+	if !s.applyConfig(w, &next) {
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"message": "maintenance updated successfully", "enabled": m.Enabled})
+}
+
+// setRouteV2Maintenance handles PUT /api/v1/routes-v2/{name}/maintenance,
+// replacing the maintenance switch of a route.
+func (s *Server) setRouteV2Maintenance(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	var m config.Maintenance
+	if !decodeConfigBody(w, r, &m) {
+		return
+	}
+	s.configMu.Lock()
+	defer s.configMu.Unlock()
+	cfg := s.currentConfig(w)
+	if cfg == nil {
+		return
+	}
+	next := *cfg
+	next.RoutesV2 = append([]config.RouteV2(nil), cfg.RoutesV2...)
+	found := false
+	for i := range next.RoutesV2 {
+		if next.RoutesV2[i].Name == name {
+			next.RoutesV2[i].Maintenance = &m
+			found = true
+			break
+		}
+	}
+	if !found {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "route '" + name + "' not found"})
+		return
+	}
+	if !s.applyConfig(w, &next) {
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"message": "route maintenance updated successfully", "name": name, "enabled": m.Enabled})
+}
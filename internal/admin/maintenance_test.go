@@ -0,0 +1,44 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/oriys/nexus/internal/config"
+	"github.com/oriys/nexus/internal/runtime"
+)
+
+func TestMaintenance(t *testing.T) {
+	s, store := setupClusterAdmin(t)
+
+	w := doAdmin(s, http.MethodGet, "/api/v1/maintenance", "")
+	var m config.Maintenance
+	if w.Code != http.StatusOK || json.NewDecoder(w.Body).Decode(&m) != nil || m.Enabled {
+		t.Fatalf("expected the switch off, got %d: %s", w.Code, w.Body)
+	}
+	w = doAdmin(s, http.MethodPut, "/api/v1/maintenance", `{"enabled": true, "body": "<p>back soon</p>", "retry_after": "10m"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+	if cur := s.configLoader.Current().Maintenance; cur == nil || !cur.Enabled || cur.RetryAfter != "10m" {
+		t.Errorf("switch not applied: %+v", cur)
+	}
+	if w := doAdmin(s, http.MethodPut, "/api/v1/maintenance", `{"enabled": true, "status": 42}`); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid status: expected 400, got %d", w.Code)
+	}
+
+	w = doAdmin(s, http.MethodPut, "/api/v1/routes-v2/web-api/maintenance", `{"enabled": true}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+	rec := httptest.NewRecorder()
+	runtime.NewGateway(store).ServeHTTP(rec, httptest.NewRequest("GET", "/web/x", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("route in maintenance: expected 503, got %d", rec.Code)
+	}
+	if w := doAdmin(s, http.MethodPut, "/api/v1/routes-v2/missing/maintenance", `{"enabled": true}`); w.Code != http.StatusNotFound {
+		t.Errorf("unknown route: expected 404, got %d", w.Code)
+	}
+}
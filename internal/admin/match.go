@@ -0,0 +1,183 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/oriys/nexus/internal/config"
+	"github.com/oriys/nexus/internal/pathmatch"
+	"github.com/oriys/nexus/internal/runtime"
+)
+
+// matchRequest is the body of POST /api/v1/debug/match: the request to route.
+type matchRequest struct {
+	Method  string            `json:"method,omitempty"` // default GET
+	Host    string            `json:"host,omitempty"`
+	Path    string            `json:"path"` // may carry a query string
+	Headers map[string]string `json:"headers,omitempty"`
+	// Body is only needed to match routes on GraphQL operations.
+	Body string `json:"body,omitempty"`
+}
+
+// legacyMatch reports the legacy route a request would take.
+type legacyMatch struct {
+	Matched  bool                `json:"matched"`
+	Route    string              `json:"route,omitempty"`
+	Host     string              `json:"host,omitempty"`
+	Paths    []config.PathRule   `json:"paths,omitempty"`
+	Upstream string              `json:"upstream,omitempty"`
+	Target   string              `json:"target,omitempty"`
+	Rewrite  *config.RewriteRule `json:"rewrite,omitempty"`
+	Error    string              `json:"error,omitempty"`
+}
+
+// v2Match reports the V2 route a request would take.
+type v2Match struct {
+	Matched bool `json:"matched"`
+	// Allow lists the methods allowed on the path when only the method
+	// kept the request from matching; the gateway answers 405.
+	Allow []string `json:"allow,omitempty"`
+	// Fallback reports that no route matched and server.fallback.cluster
+	// serves the request.
+	Fallback bool     `json:"fallback,omitempty"`
+	Route    string   `json:"route,omitempty"`
+	Filters  []string `json:"filters,omitempty"`
+	// Params are the path parameters captured by a template or regex route.
+	Params map[string]string `json:"params,omitempty"`
+	// Path is the request path after the filters ran.
+	Path        string   `json:"path,omitempty"`
+	Cluster     string   `json:"cluster,omitempty"`
+	ClusterType string   `json:"cluster_type,omitempty"`
+	Endpoint    string   `json:"endpoint,omitempty"`
+	Features    []string `json:"features,omitempty"`
+	TimeoutMs   int      `json:"timeout_ms,omitempty"`
+	Error       string   `json:"error,omitempty"`
+}
+
+// matchResponse reports how both routers would handle a request. Serving
+// names the router handling traffic: "v2" once a compiled V2 configuration
+// is loaded, "legacy" otherwise.
+type matchResponse struct {
+	Serving string       `json:"serving"`
+	Legacy  legacyMatch  `json:"legacy"`
+	V2      *v2Match     `json:"v2,omitempty"`
+	Request matchRequest `json:"request"`
+}
+
+// debugMatch handles POST /api/v1/debug/match. It runs a described request
+// through the legacy router and the compiled V2 router index without sending
+// it anywhere, reporting the route each would pick, the filters that would
+// run and the endpoint the load balancer would select next. Selection is
+// peeked, so testing a match does not shift the load balancers.
+func (s *Server) debugMatch(w http.ResponseWriter, r *http.Request) {
+	var req matchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body: " + err.Error()})
+		return
+	}
+	if req.Method == "" {
+		req.Method = http.MethodGet
+	}
+	req.Method = strings.ToUpper(req.Method)
+	if !strings.HasPrefix(req.Path, "/") {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "path must start with /"})
+		return
+	}
+	if _, err := req.build(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	resp := matchResponse{Serving: "legacy", Request: req}
+	// Each router gets its own copy: V2 filters and GraphQL matching modify
+	// the request.
+	legacyReq, _ := req.build()
+	if result, ok := s.router.Match(legacyReq); ok {
+		resp.Legacy = legacyMatch{
+			Matched:  true,
+			Route:    result.Route.Name,
+			Host:     result.Route.Host,
+			Paths:    result.Route.Paths,
+			Upstream: result.Upstream,
+			Rewrite:  result.Route.Rewrite,
+		}
+		if target, ok := s.upstreamMgr.PeekTarget(result.Upstream); ok {
+			resp.Legacy.Target = target
+		} else {
+			resp.Legacy.Error = "upstream not available"
+		}
+	}
+
+	if s.runtimeStore != nil {
+		if compiled := s.runtimeStore.Load(); compiled != nil {
+			resp.Serving = "v2"
+			v2Req, _ := req.build()
+			resp.V2 = matchV2(compiled, v2Req)
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// build returns the described request as the gateway would receive it.
+func (m *matchRequest) build() (*http.Request, error) {
+	r, err := http.NewRequest(m.Method, m.Path, strings.NewReader(m.Body))
+	if err != nil {
+		return nil, err
+	}
+	r.Host = m.Host
+	r.RequestURI = m.Path
+	for k, v := range m.Headers {
+		r.Header.Set(k, v)
+	}
+	return r, nil
+}
+
+// matchV2 routes r through the compiled configuration and runs the filters of
+// the matched route on it.
+func matchV2(compiled *runtime.CompiledConfig, r *http.Request) *v2Match {
+	compiled.Router.Normalize(r)
+	route, params, ok := compiled.Router.MatchParams(r)
+	if !ok {
+		allow := compiled.Router.AllowedMethods(r)
+		if allow != nil || compiled.Fallback == nil {
+			return &v2Match{Allow: allow}
+		}
+		route = compiled.Fallback
+	}
+	r = r.WithContext(pathmatch.WithParams(r.Context(), params))
+	m := &v2Match{
+		Matched:   true,
+		Fallback:  route == compiled.Fallback,
+		Route:     route.Name,
+		Filters:   route.FilterTypes,
+		Params:    params,
+		Cluster:   route.Upstream.ClusterName,
+		Features:  routeFeatures(route),
+		TimeoutMs: route.TimeoutMs,
+	}
+	for _, f := range route.Filters {
+		if err := f.Apply(r); err != nil {
+			m.Error = "filter error: " + err.Error()
+			return m
+		}
+	}
+	m.Path = r.URL.Path
+	if route.Mock != nil {
+		return m
+	}
+
+	m.Cluster = route.Upstream.Cluster(r)
+	cluster, ok := compiled.Clusters[m.Cluster]
+	if !ok {
+		m.Error = "cluster not found"
+		return m
+	}
+	m.ClusterType = cluster.Type
+	if ep, ok := cluster.PeekEndpoint(); ok {
+		m.Endpoint = runtime.EndpointAddress(ep)
+	} else {
+		m.Error = "cluster has no endpoints"
+	}
+	return m
+}
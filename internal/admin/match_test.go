@@ -0,0 +1,93 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestDebugMatch(t *testing.T) {
+	s, store := setupClusterAdmin(t)
+	s.router.Reload(s.configLoader.Current().Routes)
+	w := doAdmin(s, http.MethodPost, "/api/v1/routes-v2", `{"name":"web-v1","match":{"path_prefix":"/web/v1","headers":[{"name":"X-Tenant","exact":"acme"}]},"filters":[{"type":"strip_prefix","args":{"prefix":"/web"}}],"upstream":{"cluster":"web"}}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body)
+	}
+
+	match := func(body string) matchResponse {
+		t.Helper()
+		w := doAdmin(s, http.MethodPost, "/api/v1/debug/match", body)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+		}
+		var resp matchResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	resp := match(`{"path":"/web/v1/users?id=1","headers":{"x-tenant":"acme"}}`)
+	if resp.Serving != "v2" || resp.Request.Method != http.MethodGet {
+		t.Errorf("unexpected response %+v", resp)
+	}
+	if l := resp.Legacy; !l.Matched || l.Route != "api" || l.Upstream != "backend" || l.Target != "127.0.0.1:9001" {
+		t.Errorf("unexpected legacy match %+v", l)
+	}
+	v2 := resp.V2
+	if v2 == nil || !v2.Matched || v2.Route != "web-v1" || v2.Path != "/v1/users" || v2.Cluster != "web" || v2.Endpoint != "http://127.0.0.1:9001" {
+		t.Fatalf("unexpected v2 match %+v", v2)
+	}
+	if len(v2.Filters) != 1 || v2.Filters[0] != "strip_prefix" {
+		t.Errorf("unexpected filters %v", v2.Filters)
+	}
+
+	// Without the header the shorter prefix wins.
+	if v2 := match(`{"method":"post","path":"/web/v1/users"}`).V2; v2 == nil || v2.Route != "web-api" || v2.Path != "/web/v1/users" {
+		t.Errorf("unexpected v2 match %+v", v2)
+	}
+	if v2 := match(`{"path":"/other"}`).V2; v2 == nil || v2.Matched {
+		t.Errorf("expected no v2 match, got %+v", v2)
+	}
+	if picks := store.Load().Clusters["web"].Picks(); picks != 0 {
+		t.Errorf("matching advanced the load balancer: %d picks", picks)
+	}
+
+	for _, body := range []string{`{"path":"web"}`, `{"path":"/x","method":"BAD METHOD"}`, `not json`} {
+		if w := doAdmin(s, http.MethodPost, "/api/v1/debug/match", body); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", body, w.Code)
+		}
+	}
+}
+
+func TestDebugMatch_Legacy(t *testing.T) {
+	s := setupAdmin(t)
+	w := doAdmin(s, http.MethodPost, "/api/v1/debug/match", `{"host":"example.com","path":"/anything"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+	var resp matchResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Serving != "legacy" || resp.V2 != nil || resp.Legacy.Route != "api" {
+		t.Errorf("unexpected response %+v", resp)
+	}
+}
+
+func TestDebugMatch_Mock(t *testing.T) {
+	s, _ := setupClusterAdmin(t)
+	w := doAdmin(s, http.MethodPost, "/api/v1/routes-v2", `{"name":"mocked","match":{"path":"/mocked"},"upstream":{"mock":{"body":"{}"}}}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body)
+	}
+	w = doAdmin(s, http.MethodPost, "/api/v1/debug/match", `{"path":"/mocked"}`)
+	var resp matchResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	v2 := resp.V2
+	if v2 == nil || !v2.Matched || v2.Route != "mocked" || v2.Error != "" || len(v2.Features) != 1 || v2.Features[0] != "mock" {
+		t.Errorf("unexpected v2 match %+v", v2)
+	}
+}
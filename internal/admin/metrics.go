@@ -0,0 +1,27 @@
+package admin
+
+import (
+	"net/http"
+
+	"github.com/oriys/nexus/internal/connlimit"
+	"github.com/oriys/nexus/internal/logging"
+	"github.com/oriys/nexus/internal/plugin"
+	"github.com/oriys/nexus/internal/runtime"
+	"github.com/oriys/nexus/internal/tenant"
+	"github.com/oriys/nexus/internal/watchdog"
+)
+
+// getMetrics handles GET /metrics, serving the gateway metrics in the
+// Prometheus text exposition format.
+func (s *Server) getMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	runtime.WriteRouteMetrics(w)
+	runtime.WriteGraphQLMetrics(w)
+	runtime.WriteCompressionMetrics(w)
+	runtime.WriteLoadSheddingMetrics(w)
+	plugin.WritePluginMetrics(w)
+	tenant.WriteMetrics(w)
+	connlimit.WriteMetrics(w)
+	watchdog.WriteMetrics(w)
+	logging.WriteMetrics(w)
+}
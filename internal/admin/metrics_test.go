@@ -0,0 +1,26 @@
+package admin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGetMetrics(t *testing.T) {
+	s := setupAdmin(t)
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("unexpected content type %q", ct)
+	}
+	for _, name := range []string{"nexus_graphql_operations_total", "nexus_graphql_errors_total", "nexus_graphql_field_usage_total", "nexus_plugin_executions_total"} {
+		if !strings.Contains(w.Body.String(), "# TYPE "+name+" counter") {
+			t.Errorf("missing metric %s in %s", name, w.Body)
+		}
+	}
+}
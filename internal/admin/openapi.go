@@ -0,0 +1,51 @@
+package admin
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// openAPISpec describes the admin API. The role each operation requires is
+// added from the registered routes when the document is served.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// registeredRoute is an admin endpoint registered through handle.
+type registeredRoute struct {
+	pattern string
+	role    role
+}
+
+// getOpenAPI handles GET /api/v1/openapi.json.
+func (s *Server) getOpenAPI(w http.ResponseWriter, r *http.Request) {
+	doc, err := s.openAPIDocument()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, doc)
+}
+
+// openAPIDocument returns the OpenAPI document with the x-nexus-role of every
+// operation set. It fails when a registered route is not documented.
+func (s *Server) openAPIDocument() (map[string]interface{}, error) {
+	var doc map[string]interface{}
+	if err := json.Unmarshal(openAPISpec, &doc); err != nil {
+		return nil, fmt.Errorf("parse OpenAPI document: %w", err)
+	}
+	paths, _ := doc["paths"].(map[string]interface{})
+	for _, rt := range s.routes {
+		method, path, _ := strings.Cut(rt.pattern, " ")
+		item, _ := paths[path].(map[string]interface{})
+		op, _ := item[strings.ToLower(method)].(map[string]interface{})
+		if op == nil {
+			return nil, fmt.Errorf("OpenAPI document lacks %s", rt.pattern)
+		}
+		op["x-nexus-role"] = rt.role.String()
+	}
+	return doc, nil
+}
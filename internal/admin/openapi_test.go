@@ -0,0 +1,63 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestOpenAPI_MatchesRoutes(t *testing.T) {
+	s := setupAdmin(t)
+	doc, err := s.openAPIDocument()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	registered := make(map[string]bool, len(s.routes))
+	for _, rt := range s.routes {
+		registered[rt.pattern] = true
+	}
+	operationIDs := make(map[string]bool)
+	for path, item := range doc["paths"].(map[string]interface{}) {
+		for method, op := range item.(map[string]interface{}) {
+			if method == "parameters" {
+				continue
+			}
+			pattern := strings.ToUpper(method) + " " + path
+			if !registered[pattern] {
+				t.Errorf("%s is documented but not served", pattern)
+			}
+			op := op.(map[string]interface{})
+			id, _ := op["operationId"].(string)
+			if id == "" || operationIDs[id] {
+				t.Errorf("%s: missing or duplicate operationId %q", pattern, id)
+			}
+			operationIDs[id] = true
+		}
+	}
+}
+
+func TestOpenAPI_Serve(t *testing.T) {
+	s := setupAdmin(t)
+	w := doAdmin(s, http.MethodGet, "/api/v1/openapi.json", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := doc["openapi"].(string); !strings.HasPrefix(v, "3.") {
+		t.Errorf("unexpected openapi version %q", v)
+	}
+	role := func(path, method string) interface{} {
+		return doc["paths"].(map[string]interface{})[path].(map[string]interface{})[method].(map[string]interface{})["x-nexus-role"]
+	}
+	if r := role("/api/v1/config", "get"); r != "read_only" {
+		t.Errorf("expected read_only for GET /api/v1/config, got %v", r)
+	}
+	if r := role("/api/v1/config/rollback", "post"); r != "operator" {
+		t.Errorf("expected operator for POST /api/v1/config/rollback, got %v", r)
+	}
+}
@@ -0,0 +1,96 @@
+package admin
+
+import (
+	"net/http"
+
+	"github.com/oriys/nexus/internal/config"
+	"github.com/oriys/nexus/internal/plugin"
+)
+
+// SetPluginChain attaches the plugin chain serving traffic, so that its
+// plugins can be listed and configured through the API.
+func (s *Server) SetPluginChain(c *plugin.Chain) {
+	s.plugins = c
+}
+
+// checkPluginSettings checks the plugin settings of next against the
+// plugins of the chain and the schemas of their configurations.
+func (s *Server) checkPluginSettings(next *config.Config) error {
+	if s.plugins == nil {
+		return nil
+	}
+	return s.plugins.CheckSettings(next.Plugins)
+}
+
+// pluginUpdate is the body of PUT /api/v1/plugins/{name}. Fields left out
+// keep their current value.
+type pluginUpdate struct {
+	Enabled *bool                   `yaml:"enabled"`
+	Routes  *[]config.PluginBinding `yaml:"routes"`
+}
+
+// listPlugins handles GET /api/v1/plugins, describing the plugins of the
+// chain in execution order.
+func (s *Server) listPlugins(w http.ResponseWriter, r *http.Request) {
+	if s.plugins == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "no plugin chain is running"})
+		return
+	}
+	writeJSON(w, http.StatusOK, s.plugins.Plugins())
+}
+
+// updatePlugin handles PUT /api/v1/plugins/{name}, enabling or disabling a
+// plugin and binding it to routes. The setting is stored in the plugins
+// section of the configuration, so it is persisted and versioned like any
+// other change; a plugin back to its defaults loses its setting.
+func (s *Server) updatePlugin(w http.ResponseWriter, r *http.Request) {
+	if s.plugins == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "no plugin chain is running"})
+		return
+	}
+	name := r.PathValue("name")
+	if !s.plugins.Has(name) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "plugin '" + name + "' not found"})
+		return
+	}
+	var u pluginUpdate
+	if !decodeConfigBody(w, r, &u) {
+		return
+	}
+	s.configMu.Lock()
+	defer s.configMu.Unlock()
+	cfg := s.currentConfig(w)
+	if cfg == nil {
+		return
+	}
+
+	setting := config.PluginSetting{Name: name}
+	var settings []config.PluginSetting
+	for _, ps := range cfg.Plugins {
+		if ps.Name == name {
+			setting = ps
+			continue
+		}
+		settings = append(settings, ps)
+	}
+	if u.Enabled != nil {
+		setting.Disabled = !*u.Enabled
+	}
+	if u.Routes != nil {
+		setting.Routes = *u.Routes
+	}
+	if setting.Disabled || len(setting.Routes) > 0 {
+		settings = append(settings, setting)
+	}
+	next := *cfg
+	next.Plugins = settings
+	if !s.applyConfig(w, &next) {
+		return
+	}
+	for _, info := range s.plugins.Plugins() {
+		if info.Name == name {
+			writeJSON(w, http.StatusOK, info)
+			return
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/oriys/nexus/internal/config"
+	"github.com/oriys/nexus/internal/plugin"
+)
+
+type namedPlugin struct {
+	name  string
+	order int
+}
+
+func (p namedPlugin) Name() string { return p.name }
+func (p namedPlugin) Order() int   { return p.order }
+func (p namedPlugin) Execute(ctx *plugin.GatewayContext, next func()) error {
+	next()
+	return nil
+}
+
+// schemaPlugin declares that its configuration needs a level.
+type schemaPlugin struct{ namedPlugin }
+
+func (schemaPlugin) ConfigSchema() []byte {
+	return []byte(`{"type":"object","properties":{"level":{"type":"string","enum":["debug","info"]}},"required":["level"]}`)
+}
+
+func TestPlugins(t *testing.T) {
+	s, _ := setupClusterAdmin(t)
+	if w := doAdmin(s, http.MethodGet, "/api/v1/plugins", ""); w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 without a plugin chain, got %d", w.Code)
+	}
+
+	chain := plugin.NewChain(namedPlugin{"auth", 20}, schemaPlugin{namedPlugin{"log", 10}})
+	s.SetPluginChain(chain)
+	s.SetPersister(s.configLoader)
+
+	w := doAdmin(s, http.MethodPut, "/api/v1/plugins/auth", `{"enabled":false}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+	w = doAdmin(s, http.MethodPut, "/api/v1/plugins/log", `{"routes":[{"route":"web-api","config":{"level":"debug"}}]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+
+	w = doAdmin(s, http.MethodGet, "/api/v1/plugins", "")
+	var infos []plugin.PluginInfo
+	if err := json.Unmarshal(w.Body.Bytes(), &infos); err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 2 || infos[0].Name != "log" || infos[0].ConfigSchema == nil || !infos[0].Enabled || len(infos[0].Routes) != 1 || infos[0].Routes[0].Config["level"] != "debug" || infos[1].Enabled {
+		t.Errorf("unexpected plugins: %+v", infos)
+	}
+
+	// The settings survive a restart.
+	restarted, err := config.NewLoader(s.configLoader.Path()).Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(restarted.Plugins) != 2 {
+		t.Errorf("plugin settings not persisted: %+v", restarted.Plugins)
+	}
+
+	// Re-enabling the plugin drops its setting.
+	if w := doAdmin(s, http.MethodPut, "/api/v1/plugins/auth", `{"enabled":true}`); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+	if n := len(s.configLoader.Current().Plugins); n != 1 {
+		t.Errorf("expected 1 plugin setting, got %d", n)
+	}
+
+	for _, tt := range []struct {
+		path, body string
+		want       int
+	}{
+		{"/api/v1/plugins/missing", `{"enabled":false}`, http.StatusNotFound},
+		{"/api/v1/plugins/log", `{"routes":[{"route":"nope"}]}`, http.StatusBadRequest},
+		{"/api/v1/plugins/log", `{"enable":true}`, http.StatusBadRequest},
+		{"/api/v1/plugins/log", `{"routes":[{"route":"web-api","config":{"level":"trace"}}]}`, http.StatusBadRequest},
+		{"/api/v1/plugins/log", `{"routes":[{"route":"web-api"}]}`, http.StatusBadRequest},
+	} {
+		if w := doAdmin(s, http.MethodPut, tt.path, tt.body); w.Code != tt.want {
+			t.Errorf("PUT %s %s: expected %d, got %d: %s", tt.path, tt.body, tt.want, w.Code, w.Body)
+		}
+	}
+}
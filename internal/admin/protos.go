@@ -0,0 +1,148 @@
+package admin
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/oriys/nexus/internal/config"
+	"github.com/oriys/nexus/internal/runtime"
+	"github.com/oriys/nexus/internal/transcode"
+)
+
+// maxDescriptorSetSize bounds the size of an uploaded FileDescriptorSet.
+const maxDescriptorSetSize = 16 << 20
+
+// listProtos handles GET /api/v1/protos, returning the active version of every
+// uploaded descriptor set.
+func (s *Server) listProtos(w http.ResponseWriter, r *http.Request) {
+	if s.runtimeStore == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "runtime configuration not available"})
+		return
+	}
+	writeJSON(w, http.StatusOK, s.runtimeStore.Descriptors().List())
+}
+
+// getProto handles GET /api/v1/protos/{name}, returning the active version of a
+// descriptor set along with its retained history.
+func (s *Server) getProto(w http.ResponseWriter, r *http.Request) {
+	if s.runtimeStore == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "runtime configuration not available"})
+		return
+	}
+	name := r.PathValue("name")
+	ds := s.runtimeStore.Descriptors()
+	active, ok := ds.Get(name)
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "descriptor set '" + name + "' not found"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"active":   active,
+		"versions": ds.Versions(name),
+	})
+}
+
+// uploadProto handles PUT /api/v1/protos/{name}. The request body is a
+// serialized FileDescriptorSet, as produced by
+// `protoc --include_imports --descriptor_set_out`. Each upload becomes a new
+// version and the V2 configuration is recompiled against it.
+func (s *Server) uploadProto(w http.ResponseWriter, r *http.Request) {
+	if s.runtimeStore == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "runtime configuration not available"})
+		return
+	}
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxDescriptorSetSize))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body: " + err.Error()})
+		return
+	}
+
+	s.protoMu.Lock()
+	defer s.protoMu.Unlock()
+
+	ds := s.runtimeStore.Descriptors().Clone()
+	set, err := ds.Put(r.PathValue("name"), data)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if !s.applyDescriptors(w, ds) {
+		return
+	}
+	writeJSON(w, http.StatusCreated, set)
+}
+
+// rollbackProto handles POST /api/v1/protos/{name}/rollback, re-activating a
+// previous version of a descriptor set. The rolled-back content is stored as a
+// new version so history stays append-only.
+func (s *Server) rollbackProto(w http.ResponseWriter, r *http.Request) {
+	if s.runtimeStore == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "runtime configuration not available"})
+		return
+	}
+	var body struct {
+		Version int `json:"version"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body: " + err.Error()})
+		return
+	}
+
+	s.protoMu.Lock()
+	defer s.protoMu.Unlock()
+
+	ds := s.runtimeStore.Descriptors().Clone()
+	set, err := ds.Rollback(r.PathValue("name"), body.Version)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	}
+	if !s.applyDescriptors(w, ds) {
+		return
+	}
+	writeJSON(w, http.StatusOK, set)
+}
+
+// deleteProto handles DELETE /api/v1/protos/{name}, removing a descriptor set
+// and all of its versions.
+func (s *Server) deleteProto(w http.ResponseWriter, r *http.Request) {
+	if s.runtimeStore == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "runtime configuration not available"})
+		return
+	}
+	name := r.PathValue("name")
+
+	s.protoMu.Lock()
+	defer s.protoMu.Unlock()
+
+	ds := s.runtimeStore.Descriptors().Clone()
+	if !ds.Delete(name) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "descriptor set '" + name + "' not found"})
+		return
+	}
+	if !s.applyDescriptors(w, ds) {
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"message": "descriptor set deleted successfully", "name": name})
+}
+
+// applyDescriptors installs ds, recompiling the active V2 configuration against
+// it. Changes that break compilation, such as removing a message a transcoding
+// route depends on, are rejected with 409 and leave the gateway untouched.
+func (s *Server) applyDescriptors(w http.ResponseWriter, ds *transcode.DescriptorStore) bool {
+	if _, err := runtime.ApplyDescriptors(s.v2Config(), s.runtimeStore, ds); err != nil {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+		return false
+	}
+	return true
+}
+
+// v2Config returns the loaded configuration if it uses the V2 DSL.
+func (s *Server) v2Config() *config.Config {
+	cfg := s.configLoader.Current()
+	if cfg == nil || !cfg.UsesV2() {
+		return nil
+	}
+	return cfg
+}
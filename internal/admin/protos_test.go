@@ -0,0 +1,237 @@
+package admin
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/oriys/nexus/internal/config"
+	"github.com/oriys/nexus/internal/proxy"
+	"github.com/oriys/nexus/internal/runtime"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+const testV2Config = testConfig + `clusters:
+  - name: user-grpc
+    type: grpc
+    endpoints:
+      - url: "http://127.0.0.1:9090"
+routes_v2:
+  - name: get-user
+    match:
+      path: /api/v1/user/get
+    upstream:
+      cluster: user-grpc
+      grpc:
+        service: user.v1.UserService
+        method: GetUser
+        request:
+          mode: json_to_proto
+        response:
+          mode: proto_to_json
+`
+
+// setupProtoAdmin returns an admin server whose loaded config has a
+// transcoding route that can only compile once user.v1 descriptors exist.
+func setupProtoAdmin(t *testing.T) (*Server, *runtime.ConfigStore) {
+	t.Helper()
+	cfgPath := filepath.Join(t.TempDir(), "nexus.yaml")
+	if err := os.WriteFile(cfgPath, []byte(testV2Config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cl := config.NewLoader(cfgPath)
+	if _, err := cl.Load(); err != nil {
+		t.Fatal(err)
+	}
+	s := New(cl, config.NewVersionManager(10), proxy.NewRouter(), proxy.NewUpstreamManager())
+	store := runtime.NewConfigStore()
+	s.SetConfigStore(store)
+	return s, store
+}
+
+func userDescriptorSet(t *testing.T) []byte {
+	t.Helper()
+	optional := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum()
+	fd := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("user/v1/user.proto"),
+		Package: proto.String("user.v1"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{
+			{Name: proto.String("GetUserRequest"), Field: []*descriptorpb.FieldDescriptorProto{
+				{Name: proto.String("id"), JsonName: proto.String("id"), Number: proto.Int32(1),
+					Type: descriptorpb.FieldDescriptorProto_TYPE_INT64.Enum(), Label: optional},
+			}},
+			{Name: proto.String("User"), Field: []*descriptorpb.FieldDescriptorProto{
+				{Name: proto.String("id"), JsonName: proto.String("id"), Number: proto.Int32(1),
+					Type: descriptorpb.FieldDescriptorProto_TYPE_INT64.Enum(), Label: optional},
+			}},
+		},
+		Service: []*descriptorpb.ServiceDescriptorProto{
+			{Name: proto.String("UserService"), Method: []*descriptorpb.MethodDescriptorProto{
+				{Name: proto.String("GetUser"), InputType: proto.String(".user.v1.GetUserRequest"), OutputType: proto.String(".user.v1.User")},
+			}},
+		},
+	}
+	data, err := proto.Marshal(&descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{fd}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func doProtoRequest(s *Server, method, path string, body []byte) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, req)
+	return w
+}
+
+func TestUploadProto(t *testing.T) {
+	s, store := setupProtoAdmin(t)
+
+	w := doProtoRequest(s, http.MethodPut, "/api/v1/protos/user", userDescriptorSet(t))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var set struct {
+		Name     string   `json:"name"`
+		Version  int      `json:"version"`
+		Services []string `json:"services"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &set); err != nil {
+		t.Fatal(err)
+	}
+	if set.Name != "user" || set.Version != 1 || len(set.Services) != 1 {
+		t.Errorf("unexpected descriptor set %+v", set)
+	}
+
+	compiled := store.Load()
+	if compiled == nil {
+		t.Fatal("expected upload to compile the V2 configuration")
+	}
+	if _, err := compiled.Protos.FindMethod("user.v1.UserService", "GetUser"); err != nil {
+		t.Errorf("expected uploaded method in compiled registry: %v", err)
+	}
+}
+
+func TestUploadProto_Invalid(t *testing.T) {
+	s, store := setupProtoAdmin(t)
+
+	w := doProtoRequest(s, http.MethodPut, "/api/v1/protos/user", []byte("garbage"))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", w.Code)
+	}
+	if len(store.Descriptors().List()) != 0 {
+		t.Error("expected invalid upload not to be stored")
+	}
+}
+
+func TestListAndGetProto(t *testing.T) {
+	s, _ := setupProtoAdmin(t)
+	data := userDescriptorSet(t)
+	doProtoRequest(s, http.MethodPut, "/api/v1/protos/user", data)
+	doProtoRequest(s, http.MethodPut, "/api/v1/protos/user", data)
+
+	w := doProtoRequest(s, http.MethodGet, "/api/v1/protos", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	var list []map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0]["version"].(float64) != 2 {
+		t.Fatalf("expected one set at version 2, got %v", list)
+	}
+
+	w = doProtoRequest(s, http.MethodGet, "/api/v1/protos/user", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	var detail struct {
+		Active   map[string]interface{}   `json:"active"`
+		Versions []map[string]interface{} `json:"versions"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &detail); err != nil {
+		t.Fatal(err)
+	}
+	if len(detail.Versions) != 2 {
+		t.Errorf("expected 2 versions, got %d", len(detail.Versions))
+	}
+
+	w = doProtoRequest(s, http.MethodGet, "/api/v1/protos/missing", nil)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404, got %d", w.Code)
+	}
+}
+
+func TestRollbackProto(t *testing.T) {
+	s, _ := setupProtoAdmin(t)
+	data := userDescriptorSet(t)
+	doProtoRequest(s, http.MethodPut, "/api/v1/protos/user", data)
+	doProtoRequest(s, http.MethodPut, "/api/v1/protos/user", data)
+
+	w := doProtoRequest(s, http.MethodPost, "/api/v1/protos/user/rollback", []byte(`{"version":1}`))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), `"version":3`) {
+		t.Errorf("expected rollback to create version 3, got %s", w.Body.String())
+	}
+
+	w = doProtoRequest(s, http.MethodPost, "/api/v1/protos/user/rollback", []byte(`{"version":9}`))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for unknown version, got %d", w.Code)
+	}
+}
+
+func TestDeleteProto_RejectedWhenInUse(t *testing.T) {
+	s, store := setupProtoAdmin(t)
+	doProtoRequest(s, http.MethodPut, "/api/v1/protos/user", userDescriptorSet(t))
+	before := store.Load()
+
+	w := doProtoRequest(s, http.MethodDelete, "/api/v1/protos/user", nil)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d: %s", w.Code, w.Body.String())
+	}
+	if _, ok := store.Descriptors().Get("user"); !ok {
+		t.Error("expected descriptor set to be kept")
+	}
+	if store.Load() != before {
+		t.Error("expected compiled configuration to be unchanged")
+	}
+}
+
+func TestDeleteProto(t *testing.T) {
+	s := setupAdmin(t)
+	store := runtime.NewConfigStore()
+	s.SetConfigStore(store)
+	doProtoRequest(s, http.MethodPut, "/api/v1/protos/user", userDescriptorSet(t))
+
+	w := doProtoRequest(s, http.MethodDelete, "/api/v1/protos/user", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if len(store.Descriptors().List()) != 0 {
+		t.Error("expected descriptor set to be removed")
+	}
+
+	w = doProtoRequest(s, http.MethodDelete, "/api/v1/protos/user", nil)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404, got %d", w.Code)
+	}
+}
+
+func TestProtos_NoRuntime(t *testing.T) {
+	s := setupAdmin(t)
+	w := doProtoRequest(s, http.MethodGet, "/api/v1/protos", nil)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d", w.Code)
+	}
+}
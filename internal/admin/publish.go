@@ -91,9 +91,10 @@ func (s *Server) publishRoute(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	cfg := s.configLoader.Current()
+	s.configMu.Lock()
+	defer s.configMu.Unlock()
+	cfg := s.currentConfig(w)
 	if cfg == nil {
-		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "no configuration loaded"})
 		return
 	}
 
@@ -106,8 +107,11 @@ func (s *Server) publishRoute(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Add route and reload
-	cfg.Routes = append(cfg.Routes, route)
-	s.router.Reload(cfg.Routes)
+	next := *cfg
+	next.Routes = append(append([]config.Route(nil), cfg.Routes...), route)
+	if !s.applyConfig(w, &next) {
+		return
+	}
 
 	writeJSON(w, http.StatusCreated, map[string]string{"message": "route published successfully", "name": route.Name})
 }
@@ -136,16 +140,19 @@ func (s *Server) updateRoute(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	cfg := s.configLoader.Current()
+	s.configMu.Lock()
+	defer s.configMu.Unlock()
+	cfg := s.currentConfig(w)
 	if cfg == nil {
-		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "no configuration loaded"})
 		return
 	}
 
+	next := *cfg
+	next.Routes = append([]config.Route(nil), cfg.Routes...)
 	found := false
-	for i, existing := range cfg.Routes {
+	for i, existing := range next.Routes {
 		if existing.Name == routeName {
-			cfg.Routes[i] = route
+			next.Routes[i] = route
 			found = true
 			break
 		}
@@ -156,7 +163,9 @@ func (s *Server) updateRoute(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	s.router.Reload(cfg.Routes)
+	if !s.applyConfig(w, &next) {
+		return
+	}
 	writeJSON(w, http.StatusOK, map[string]string{"message": "route updated successfully", "name": routeName})
 }
 
@@ -168,9 +177,10 @@ func (s *Server) deleteRoute(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	cfg := s.configLoader.Current()
+	s.configMu.Lock()
+	defer s.configMu.Unlock()
+	cfg := s.currentConfig(w)
 	if cfg == nil {
-		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "no configuration loaded"})
 		return
 	}
 
@@ -189,8 +199,11 @@ func (s *Server) deleteRoute(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	cfg.Routes = newRoutes
-	s.router.Reload(cfg.Routes)
+	next := *cfg
+	next.Routes = newRoutes
+	if !s.applyConfig(w, &next) {
+		return
+	}
 	writeJSON(w, http.StatusOK, map[string]string{"message": "route unpublished successfully", "name": routeName})
 }
 
@@ -0,0 +1,107 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/oriys/nexus/internal/config"
+)
+
+// errCompile marks reloads rejected because the configuration does not
+// compile.
+var errCompile = errors.New("compile configuration")
+
+// Reload applies a configuration reloaded from the config file or source.
+// It is serialized with the changes made through the API and, like them,
+// compiled before anything is swapped, so a configuration that fails to
+// compile leaves the gateway, and the loader's current configuration, on
+// the previous version. raw is the source recorded as the new version. The
+// loader already validated cfg, and it is not persisted.
+func (s *Server) Reload(cfg *config.Config, raw []byte) error {
+	s.configMu.Lock()
+	defer s.configMu.Unlock()
+	return s.reload(cfg, raw)
+}
+
+func (s *Server) reload(cfg *config.Config, raw []byte) error {
+	compiled, err := s.prepareConfig(cfg)
+	if err == nil {
+		err = s.checkPluginSettings(cfg)
+	}
+	if err != nil {
+		// The loader made cfg current when it loaded it.
+		if cur := s.versionManager.Current(); cur != nil {
+			s.configLoader.Set(cur.Config)
+		}
+		return fmt.Errorf("%w: %w", errCompile, err)
+	}
+	s.activateConfig(cfg, compiled)
+	s.versionManager.Save(cfg, raw)
+	return nil
+}
+
+// ReloadFromLoader reads the configuration again through the loader and
+// applies it as Reload does, returning the changes made. It serves the
+// reloads the watcher cannot see, on SIGHUP or through the API; a
+// configuration without changes is not recorded as a new version.
+func (s *Server) ReloadFromLoader() ([]config.Change, error) {
+	s.configMu.Lock()
+	defer s.configMu.Unlock()
+	prev := s.configLoader.Current()
+	cfg, err := s.configLoader.Load()
+	if err != nil {
+		return nil, err
+	}
+	diff, err := config.Diff(prev, cfg)
+	if err != nil || len(diff) == 0 {
+		s.configLoader.Set(prev)
+		return nil, err
+	}
+	raw, err := s.configLoader.Raw()
+	if err != nil {
+		slog.Warn("failed to read raw config for versioning", slog.String("error", err.Error()))
+		raw = nil
+	}
+	if err := s.reload(cfg, raw); err != nil {
+		return nil, err
+	}
+	return diff, nil
+}
+
+// reloadResult reports a reload triggered through the API.
+type reloadResult struct {
+	Version int             `json:"version"`
+	Diff    []config.Change `json:"diff"`
+}
+
+// reloadConfig handles POST /api/v1/config/reload, reading the config file,
+// directory or source again and applying it. This covers deployments that
+// replace the file in ways the watcher misses. A configuration that cannot
+// be loaded (400) or compiled (409) leaves the gateway untouched.
+func (s *Server) reloadConfig(w http.ResponseWriter, r *http.Request) {
+	diff, err := s.ReloadFromLoader()
+	if errors.Is(err, errCompile) {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "reload configuration: " + err.Error()})
+		return
+	}
+	if len(diff) > 0 {
+		if err := s.SetAuth(s.configLoader.Current().Admin.Auth); err != nil {
+			slog.Error("failed to reload admin auth config", slog.String("error", err.Error()))
+		}
+	}
+
+	result := reloadResult{Diff: []config.Change{}}
+	if v := s.versionManager.Current(); v != nil {
+		result.Version = v.Version
+	}
+	if diff != nil {
+		result.Diff = diff
+	}
+	writeJSON(w, http.StatusOK, result)
+}
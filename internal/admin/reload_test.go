@@ -0,0 +1,110 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/oriys/nexus/internal/config"
+)
+
+func TestReload(t *testing.T) {
+	s, store := setupClusterAdmin(t)
+	cfg := s.configLoader.Current()
+	s.versionManager.Save(cfg, []byte(testClusterConfig))
+
+	next := *cfg
+	next.Upstreams = []config.Upstream{{Name: "replacement", Targets: []config.Target{{Address: "127.0.0.1:9100"}}}}
+	next.Routes = []config.Route{{Name: "api", Paths: []config.PathRule{{Path: "/", Type: "prefix"}}, Upstream: "replacement"}}
+	next.RoutesV2 = nil
+	if err := s.Reload(&next, []byte("next")); err != nil {
+		t.Fatal(err)
+	}
+	result, ok := s.router.Match(httptest.NewRequest("GET", "/x", nil))
+	if target, _ := s.upstreamMgr.PeekTarget(result.Upstream); !ok || target != "127.0.0.1:9100" {
+		t.Errorf("legacy routes not reloaded: %+v", result)
+	}
+	if _, ok := store.Load().Router.Match(httptest.NewRequest("GET", "/web/x", nil)); ok {
+		t.Error("removed V2 route still served")
+	}
+	if s.configLoader.Current() != &next || s.versionManager.Len() != 2 || s.versionManager.Current().Config != &next {
+		t.Error("reload not recorded")
+	}
+
+	// A configuration that fails to compile changes nothing, and the
+	// loader's current configuration is restored.
+	bad := next
+	bad.Upstreams = []config.Upstream{{Name: "other", Targets: []config.Target{{Address: "127.0.0.1:9200"}}}}
+	bad.RoutesV2 = []config.RouteV2{{
+		Name:  "grpc",
+		Match: config.RouteMatch{Path: "/grpc"},
+		Upstream: config.RouteUpstream{Cluster: "web", GRPC: &config.RouteUpstreamGRPC{
+			Service: "missing.v1.Service", Method: "Get",
+			Request: &config.TranscodeMode{Mode: "json_to_proto"},
+		}},
+	}}
+	compiled := store.Load()
+	s.configLoader.Set(&bad) // as the loader does before calling back
+	if err := s.Reload(&bad, nil); err == nil || !strings.Contains(err.Error(), "compile configuration") {
+		t.Fatalf("expected a compile error, got %v", err)
+	}
+	if store.Load() != compiled || s.configLoader.Current() != &next || s.versionManager.Len() != 2 {
+		t.Error("failed reload changed the configuration")
+	}
+	if target, _ := s.upstreamMgr.PeekTarget("replacement"); target != "127.0.0.1:9100" {
+		t.Error("failed reload replaced the upstreams")
+	}
+}
+
+func TestReloadConfig(t *testing.T) {
+	s, store := setupClusterAdmin(t)
+	s.versionManager.Save(s.configLoader.Current(), []byte(testClusterConfig))
+	path := s.configLoader.Path()
+
+	// An unchanged file records no version.
+	w := doAdmin(s, "POST", "/api/v1/config/reload", "")
+	if w.Code != http.StatusOK || s.versionManager.Len() != 1 {
+		t.Fatalf("expected an unchanged reload, got %d: %s", w.Code, w.Body.String())
+	}
+
+	os.WriteFile(path, []byte(strings.Replace(testClusterConfig, "path_prefix: /web", "path_prefix: /site", 1)), 0o644)
+	w = doAdmin(s, "POST", "/api/v1/config/reload", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var result reloadResult
+	json.Unmarshal(w.Body.Bytes(), &result)
+	if result.Version != 2 || len(result.Diff) != 1 || result.Diff[0].Path != "routes_v2[web-api].match.path_prefix" {
+		t.Errorf("unexpected result %+v", result)
+	}
+	if _, ok := store.Load().Router.Match(httptest.NewRequest("GET", "/site/x", nil)); !ok {
+		t.Error("reloaded route not served")
+	}
+
+	// Files that fail to load or compile leave the configuration alone.
+	current := s.configLoader.Current()
+	os.WriteFile(path, []byte("server: ["), 0o644)
+	if w := doAdmin(s, "POST", "/api/v1/config/reload", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d: %s", w.Code, w.Body.String())
+	}
+	os.WriteFile(path, []byte(testClusterConfig+`  - name: grpc
+    match:
+      path: /grpc
+    upstream:
+      cluster: web
+      grpc:
+        service: missing.v1.Service
+        method: Get
+        request:
+          mode: json_to_proto
+`), 0o644)
+	if w := doAdmin(s, "POST", "/api/v1/config/reload", ""); w.Code != http.StatusConflict {
+		t.Errorf("expected 409, got %d: %s", w.Code, w.Body.String())
+	}
+	if s.configLoader.Current() != current || s.versionManager.Len() != 2 {
+		t.Error("failed reload changed the configuration")
+	}
+}
@@ -0,0 +1,128 @@
+package admin
+
+import (
+	"net/http"
+
+	"github.com/oriys/nexus/internal/config"
+)
+
+// listRoutesV2 handles GET /api/v1/routes-v2.
+func (s *Server) listRoutesV2(w http.ResponseWriter, r *http.Request) {
+	cfg := s.currentConfig(w)
+	if cfg == nil {
+		return
+	}
+	routes := config.Redacted(cfg).RoutesV2
+	if routes == nil {
+		routes = []config.RouteV2{}
+	}
+	writeJSON(w, http.StatusOK, routes)
+}
+
+// getRouteV2 handles GET /api/v1/routes-v2/{name}.
+func (s *Server) getRouteV2(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	cfg := s.currentConfig(w)
+	if cfg == nil {
+		return
+	}
+	for _, route := range config.Redacted(cfg).RoutesV2 {
+		if route.Name == name {
+			writeJSON(w, http.StatusOK, route)
+			return
+		}
+	}
+	writeJSON(w, http.StatusNotFound, map[string]string{"error": "route '" + name + "' not found"})
+}
+
+// createRouteV2 handles POST /api/v1/routes-v2 to add a route. The route is
+// validated and compiled with its filters and upstream before it is served.
+func (s *Server) createRouteV2(w http.ResponseWriter, r *http.Request) {
+	var route config.RouteV2
+	if !decodeConfigBody(w, r, &route) {
+		return
+	}
+	if route.Name == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "route name is required"})
+		return
+	}
+	s.configMu.Lock()
+	defer s.configMu.Unlock()
+	cfg := s.currentConfig(w)
+	if cfg == nil {
+		return
+	}
+	for _, existing := range cfg.RoutesV2 {
+		if existing.Name == route.Name {
+			writeJSON(w, http.StatusConflict, map[string]string{"error": "route with name '" + route.Name + "' already exists"})
+			return
+		}
+	}
+	next := *cfg
+	next.RoutesV2 = append(append([]config.RouteV2(nil), cfg.RoutesV2...), route)
+	if !s.applyConfig(w, &next) {
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]string{"message": "route created successfully", "name": route.Name})
+}
+
+// updateRouteV2 handles PUT /api/v1/routes-v2/{name} to replace a route,
+// keeping its position in the route order.
+func (s *Server) updateRouteV2(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	var route config.RouteV2
+	if !decodeConfigBody(w, r, &route) {
+		return
+	}
+	route.Name = name
+	s.configMu.Lock()
+	defer s.configMu.Unlock()
+	cfg := s.currentConfig(w)
+	if cfg == nil {
+		return
+	}
+	next := *cfg
+	next.RoutesV2 = append([]config.RouteV2(nil), cfg.RoutesV2...)
+	found := false
+	for i := range next.RoutesV2 {
+		if next.RoutesV2[i].Name == name {
+			next.RoutesV2[i] = route
+			found = true
+			break
+		}
+	}
+	if !found {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "route '" + name + "' not found"})
+		return
+	}
+	if !s.applyConfig(w, &next) {
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"message": "route updated successfully", "name": name})
+}
+
+// deleteRouteV2 handles DELETE /api/v1/routes-v2/{name}.
+func (s *Server) deleteRouteV2(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	s.configMu.Lock()
+	defer s.configMu.Unlock()
+	cfg := s.currentConfig(w)
+	if cfg == nil {
+		return
+	}
+	next := *cfg
+	next.RoutesV2 = make([]config.RouteV2, 0, len(cfg.RoutesV2))
+	for _, existing := range cfg.RoutesV2 {
+		if existing.Name != name {
+			next.RoutesV2 = append(next.RoutesV2, existing)
+		}
+	}
+	if len(next.RoutesV2) == len(cfg.RoutesV2) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "route '" + name + "' not found"})
+		return
+	}
+	if !s.applyConfig(w, &next) {
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"message": "route deleted successfully", "name": name})
+}
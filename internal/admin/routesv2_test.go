@@ -0,0 +1,92 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/oriys/nexus/internal/config"
+)
+
+func TestRoutesV2_CRUD(t *testing.T) {
+	s, store := setupClusterAdmin(t)
+	matches := func(path string) bool {
+		_, ok := store.Load().Router.Match(httptest.NewRequest("GET", path, nil))
+		return ok
+	}
+
+	w := doAdmin(s, http.MethodPost, "/api/v1/routes-v2", `{"name":"orders","match":{"path_prefix":"/orders"},"filters":[{"type":"strip_prefix","args":{"prefix":"/orders"}}],"upstream":{"cluster":"web","timeout_ms":500}}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body)
+	}
+	if !matches("/orders/1") {
+		t.Fatal("created route not compiled")
+	}
+
+	for _, tt := range []struct {
+		method, path, body string
+		want               int
+	}{
+		{http.MethodPost, "/api/v1/routes-v2", `{"name":"orders","match":{"path":"/x"},"upstream":{"cluster":"web"}}`, http.StatusConflict},
+		{http.MethodPost, "/api/v1/routes-v2", `{"match":{"path":"/x"},"upstream":{"cluster":"web"}}`, http.StatusBadRequest},
+		{http.MethodPost, "/api/v1/routes-v2", `{"name":"x","match":{"path":"/x"},"upstream":{"cluster":"missing"}}`, http.StatusBadRequest},
+		{http.MethodPost, "/api/v1/routes-v2", `{"name":"x","match":{"path":"/x"},"filters":[{"type":"strip_prefix"}],"upstream":{"cluster":"web"}}`, http.StatusBadRequest},
+		{http.MethodPost, "/api/v1/routes-v2", `{"name":"x","match":{"path":"/x"},"filters":[{"type":"teleport"}],"upstream":{"cluster":"web"}}`, http.StatusConflict},
+		{http.MethodPost, "/api/v1/routes-v2", `{"name":"x","match":{"path":"/x"},"upstream":{"cluster":"web"},"extra":1}`, http.StatusBadRequest},
+		{http.MethodPut, "/api/v1/routes-v2/missing", `{"match":{"path":"/x"},"upstream":{"cluster":"web"}}`, http.StatusNotFound},
+		{http.MethodGet, "/api/v1/routes-v2/missing", "", http.StatusNotFound},
+		{http.MethodDelete, "/api/v1/routes-v2/missing", "", http.StatusNotFound},
+	} {
+		if w := doAdmin(s, tt.method, tt.path, tt.body); w.Code != tt.want {
+			t.Errorf("%s %s %s: expected %d, got %d: %s", tt.method, tt.path, tt.body, tt.want, w.Code, w.Body)
+		}
+	}
+	if matches("/x") {
+		t.Error("rejected route compiled")
+	}
+
+	w = doAdmin(s, http.MethodGet, "/api/v1/routes-v2/orders", "")
+	var route config.RouteV2
+	if err := json.Unmarshal(w.Body.Bytes(), &route); err != nil || route.Upstream.TimeoutMs != 500 || len(route.Filters) != 1 {
+		t.Errorf("unexpected route %d: %s", w.Code, w.Body)
+	}
+
+	if w := doAdmin(s, http.MethodPut, "/api/v1/routes-v2/orders", `{"match":{"path_prefix":"/purchases"},"upstream":{"cluster":"web"}}`); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+	if matches("/orders/1") || !matches("/purchases/1") {
+		t.Error("route not updated")
+	}
+	if routes := s.configLoader.Current().RoutesV2; len(routes) != 2 || routes[1].Name != "orders" {
+		t.Errorf("update changed the route order: %+v", routes)
+	}
+
+	// Deleting every route stops serving them.
+	for _, name := range []string{"orders", "web-api"} {
+		if w := doAdmin(s, http.MethodDelete, "/api/v1/routes-v2/"+name, ""); w.Code != http.StatusOK {
+			t.Fatalf("delete %s: expected 200, got %d: %s", name, w.Code, w.Body)
+		}
+	}
+	if matches("/purchases/1") || matches("/web/x") {
+		t.Error("deleted routes still compiled")
+	}
+	if w := doAdmin(s, http.MethodGet, "/api/v1/routes-v2", ""); strings.TrimSpace(w.Body.String()) != "[]" {
+		t.Errorf("expected an empty listing, got %s", w.Body)
+	}
+}
+
+func TestRoutesV2_DryRunWithoutStore(t *testing.T) {
+	s, _ := setupClusterAdmin(t)
+	s.runtimeStore = nil
+	if w := doAdmin(s, http.MethodPost, "/api/v1/routes-v2", `{"name":"x","match":{"path":"/x"},"filters":[{"type":"teleport"}],"upstream":{"cluster":"web"}}`); w.Code != http.StatusConflict {
+		t.Errorf("expected compile failure, got %d: %s", w.Code, w.Body)
+	}
+	if len(s.configLoader.Current().RoutesV2) != 1 {
+		t.Error("rejected route stored")
+	}
+	if w := doAdmin(s, http.MethodPost, "/api/v1/routes-v2", `{"name":"x","match":{"path":"/x"},"upstream":{"cluster":"web"}}`); w.Code != http.StatusCreated {
+		t.Errorf("expected 201, got %d: %s", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,182 @@
+package admin
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/oriys/nexus/internal/dubbo"
+	"github.com/oriys/nexus/internal/runtime"
+)
+
+// runtimeRoute is an entry of the compiled router index.
+type runtimeRoute struct {
+	Host    string   `json:"host,omitempty"`
+	Kind    string   `json:"kind"`
+	Method  string   `json:"method,omitempty"`
+	Path    string   `json:"path"`
+	Route   string   `json:"route"`
+	Methods []string `json:"methods,omitempty"`
+	Headers []string `json:"headers,omitempty"`
+	Query   []string `json:"query,omitempty"`
+	Cookies []string `json:"cookies,omitempty"`
+	GraphQL []string `json:"graphql_operations,omitempty"`
+	Cluster string   `json:"cluster"`
+	// Split maps the clusters of a weighted route to their weights; Cluster
+	// is empty then.
+	Split     map[string]int `json:"split,omitempty"`
+	TimeoutMs int            `json:"timeout_ms,omitempty"`
+	Filters   []string       `json:"filters,omitempty"`
+	// Features lists the request handling compiled into the route, such as
+	// "grpc_transcode" or "graphql_cache".
+	Features []string `json:"features,omitempty"`
+	// Active reports whether a scheduled route is within its schedule; nil
+	// for routes without one.
+	Active *bool `json:"active,omitempty"`
+}
+
+// runtimeCluster is the serving state of a compiled cluster.
+type runtimeCluster struct {
+	Name      string   `json:"name"`
+	Type      string   `json:"type"`
+	LB        string   `json:"lb"`
+	Endpoints []string `json:"endpoints"`
+	// Picks counts the endpoints handed out by the load balancer; Next is
+	// the index of the endpoint it picks next.
+	Picks    uint64                `json:"picks"`
+	Next     *int                  `json:"next,omitempty"`
+	Services []string              `json:"grpc_services,omitempty"`
+	Dubbo    []dubbo.ProviderStats `json:"dubbo_providers,omitempty"`
+}
+
+// getRuntimeRoutes handles GET /api/v1/runtime/routes, returning the router
+// index of the compiled configuration that is serving traffic, grouped by
+// host and in the order entries are tried. A route indexed under several methods appears once per
+// method.
+func (s *Server) getRuntimeRoutes(w http.ResponseWriter, r *http.Request) {
+	compiled := s.compiledConfig(w)
+	if compiled == nil {
+		return
+	}
+
+	routes := make([]runtimeRoute, 0)
+	for _, e := range compiled.Router.Entries() {
+		cr := e.Route
+		rt := runtimeRoute{
+			Host:      e.Host,
+			Kind:      e.Kind,
+			Method:    e.Method,
+			Path:      e.Path,
+			Route:     cr.Name,
+			Methods:   sortedKeys(cr.Match.Methods),
+			Cluster:   cr.Upstream.ClusterName,
+			TimeoutMs: cr.TimeoutMs,
+			Filters:   cr.FilterTypes,
+			Features:  routeFeatures(cr),
+		}
+		if sp := cr.Upstream.Split; sp != nil {
+			rt.Split = make(map[string]int, len(sp.Clusters))
+			for i, name := range sp.Clusters {
+				rt.Split[name] = sp.Weights[i]
+			}
+		}
+		if sc := cr.Match.Schedule; sc != nil {
+			active := sc.Active(time.Now())
+			rt.Active = &active
+		}
+		for _, h := range cr.Match.Headers {
+			rt.Headers = append(rt.Headers, h.Name)
+		}
+		for _, q := range cr.Match.Query {
+			rt.Query = append(rt.Query, q.Name)
+		}
+		for _, c := range cr.Match.Cookies {
+			rt.Cookies = append(rt.Cookies, c.Name)
+		}
+		if g := cr.Match.GraphQL; g != nil {
+			rt.GraphQL = append(sortedKeys(g.Types), sortedKeys(g.Names)...)
+		}
+		routes = append(routes, rt)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"version": compiled.Version,
+		"routes":  routes,
+	})
+}
+
+// getRuntimeClusters handles GET /api/v1/runtime/clusters, returning the
+// endpoints and load balancer position of each compiled cluster and the
+// connection pool state of native Dubbo providers, including their dial
+// back-off. Routes V2 clusters have no circuit breakers, so none are
+// reported.
+func (s *Server) getRuntimeClusters(w http.ResponseWriter, r *http.Request) {
+	compiled := s.compiledConfig(w)
+	if compiled == nil {
+		return
+	}
+
+	clusters := make([]runtimeCluster, 0, len(compiled.Clusters))
+	for _, c := range compiled.Clusters {
+		rc := runtimeCluster{
+			Name:      c.Name,
+			Type:      c.Type,
+			LB:        c.LB,
+			Endpoints: make([]string, 0, len(c.Endpoints)),
+			Picks:     c.Picks(),
+			Dubbo:     c.DubboConnections(),
+		}
+		for _, ep := range c.Endpoints {
+			rc.Endpoints = append(rc.Endpoints, runtime.EndpointAddress(ep))
+		}
+		if n := len(c.Endpoints); n > 0 {
+			next := int(rc.Picks % uint64(n))
+			rc.Next = &next
+		}
+		for _, svc := range c.Services {
+			rc.Services = append(rc.Services, svc.Name)
+		}
+		clusters = append(clusters, rc)
+	}
+	sort.Slice(clusters, func(i, j int) bool { return clusters[i].Name < clusters[j].Name })
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"version":  compiled.Version,
+		"clusters": clusters,
+	})
+}
+
+// routeFeatures names the optional request handling compiled into cr.
+func routeFeatures(cr *runtime.CompiledRoute) []string {
+	var features []string
+	add := func(set bool, name string) {
+		if set {
+			features = append(features, name)
+		}
+	}
+	add(cr.GRPCTranscode != nil, "grpc_transcode")
+	add(cr.GRPCMetadata != nil, "grpc_metadata")
+	add(cr.GRPCAllow != nil, "grpc_allowlist")
+	add(cr.TripleTranscode != nil, "triple_transcode")
+	add(cr.DubboParams != nil, "dubbo_params")
+	add(cr.GraphQLAllow != nil, "graphql_allowlist")
+	add(cr.PersistedQueries != nil, "persisted_queries")
+	add(cr.GraphQLCache != nil, "graphql_cache")
+	add(cr.GraphQLFederation != nil, "graphql_federation")
+	add(cr.GraphQLSubscriptions != nil, "graphql_subscriptions")
+	add(cr.Mock != nil, "mock")
+	add(cr.Upstream.Mirror != nil, "mirror")
+	add(cr.Cache != nil, "cache")
+	return features
+}
+
+// sortedKeys returns the keys of a set in order, or nil for a nil set.
+func sortedKeys(set map[string]struct{}) []string {
+	if set == nil {
+		return nil
+	}
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
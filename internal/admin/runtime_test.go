@@ -0,0 +1,80 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestRuntimeRoutes(t *testing.T) {
+	s, _ := setupClusterAdmin(t)
+	w := doAdmin(s, http.MethodPost, "/api/v1/routes-v2", `{"name":"health","match":{"path":"/web/health","methods":["GET","HEAD"]},"upstream":{"cluster":"web","timeout_ms":200}}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body)
+	}
+
+	w = doAdmin(s, http.MethodGet, "/api/v1/runtime/routes", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+	var body struct {
+		Version uint64         `json:"version"`
+		Routes  []runtimeRoute `json:"routes"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Routes) != 3 {
+		t.Fatalf("expected 3 index entries, got %+v", body.Routes)
+	}
+	for i, want := range []struct{ kind, method, path, route string }{
+		{"exact", "GET", "/web/health", "health"},
+		{"exact", "HEAD", "/web/health", "health"},
+		{"prefix", "", "/web", "web-api"},
+	} {
+		got := body.Routes[i]
+		if got.Kind != want.kind || got.Method != want.method || got.Path != want.path || got.Route != want.route || got.Cluster != "web" {
+			t.Errorf("entry %d: expected %+v, got %+v", i, want, got)
+		}
+	}
+	if got := body.Routes[0]; len(got.Methods) != 2 || got.Methods[0] != "GET" || got.TimeoutMs != 200 {
+		t.Errorf("unexpected health entry %+v", got)
+	}
+}
+
+func TestRuntimeClusters(t *testing.T) {
+	s, store := setupClusterAdmin(t)
+	web := store.Load().Clusters["web"]
+	web.NextEndpoint()
+	web.NextEndpoint()
+
+	w := doAdmin(s, http.MethodGet, "/api/v1/runtime/clusters", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+	var body struct {
+		Clusters []runtimeCluster `json:"clusters"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Clusters) != 1 {
+		t.Fatalf("expected 1 cluster, got %+v", body.Clusters)
+	}
+	c := body.Clusters[0]
+	if c.Name != "web" || c.Type != "http" || c.LB != "round_robin" || len(c.Endpoints) != 1 || c.Endpoints[0] != "http://127.0.0.1:9001" {
+		t.Errorf("unexpected cluster %+v", c)
+	}
+	if c.Picks != 2 || c.Next == nil || *c.Next != 0 {
+		t.Errorf("unexpected load balancer state %+v", c)
+	}
+}
+
+func TestRuntime_NoStore(t *testing.T) {
+	s := setupAdmin(t)
+	for _, path := range []string{"/api/v1/runtime/routes", "/api/v1/runtime/clusters"} {
+		if w := doAdmin(s, http.MethodGet, path, ""); w.Code != http.StatusServiceUnavailable {
+			t.Errorf("%s: expected 503, got %d", path, w.Code)
+		}
+	}
+}
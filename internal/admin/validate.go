@@ -0,0 +1,83 @@
+package admin
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/oriys/nexus/internal/config"
+	"github.com/oriys/nexus/internal/runtime"
+)
+
+// maxCandidateBytes bounds the candidate configurations accepted for
+// validation.
+const maxCandidateBytes = 8 << 20
+
+// validationError is a problem found in a candidate configuration. Stage is
+// "parse", "validate" or "compile".
+type validationError struct {
+	Stage   string `json:"stage"`
+	Message string `json:"message"`
+}
+
+// validationResult is the response of POST /api/v1/config/validate.
+type validationResult struct {
+	Valid  bool              `json:"valid"`
+	Errors []validationError `json:"errors"`
+	// BaseVersion is the version the diff was computed against, 0 if none
+	// was recorded.
+	BaseVersion int             `json:"base_version"`
+	Diff        []config.Change `json:"diff"`
+}
+
+// validateConfig handles POST /api/v1/config/validate. It checks a complete
+// candidate configuration, YAML or JSON with the file field names, the way a
+// reload would, and reports the differences with the active configuration.
+// Nothing is applied: the candidate is compiled as a dry run, which leaves
+// the served transports and caches alone and skips gRPC reflection.
+func (s *Server) validateConfig(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxCandidateBytes+1))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "read request body: " + err.Error()})
+		return
+	}
+	if len(data) > maxCandidateBytes {
+		writeJSON(w, http.StatusRequestEntityTooLarge, map[string]string{"error": "configuration too large"})
+		return
+	}
+
+	result := validationResult{Errors: []validationError{}, Diff: []config.Change{}}
+	candidate, err := config.Parse(data)
+	if err != nil {
+		stage := "parse"
+		var verr *config.ValidationError
+		if errors.As(err, &verr) {
+			stage = "validate"
+		}
+		result.Errors = append(result.Errors, validationError{Stage: stage, Message: err.Error()})
+		writeJSON(w, http.StatusOK, result)
+		return
+	}
+
+	if err := s.checkPluginSettings(candidate); err != nil {
+		result.Errors = append(result.Errors, validationError{Stage: "validate", Message: err.Error()})
+	} else if candidate.UsesV2() {
+		if err := runtime.Check(candidate, s.runtimeStore); err != nil {
+			result.Errors = append(result.Errors, validationError{Stage: "compile", Message: err.Error()})
+		}
+	}
+	result.Valid = len(result.Errors) == 0
+
+	if v := s.versionManager.Current(); v != nil {
+		result.BaseVersion = v.Version
+	}
+	diff, err := config.Diff(s.configLoader.Current(), candidate)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if diff != nil {
+		result.Diff = diff
+	}
+	writeJSON(w, http.StatusOK, result)
+}
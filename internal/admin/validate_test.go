@@ -0,0 +1,67 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func validateCandidate(t *testing.T, s *Server, body string) validationResult {
+	t.Helper()
+	w := doAdmin(s, http.MethodPost, "/api/v1/config/validate", body)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+	var result validationResult
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestValidateConfig(t *testing.T) {
+	s, store := setupClusterAdmin(t)
+	before := store.Load()
+
+	// The unchanged configuration is valid and has no differences.
+	result := validateCandidate(t, s, testClusterConfig)
+	if !result.Valid || len(result.Errors) != 0 || len(result.Diff) != 0 {
+		t.Errorf("unexpected result for the current config: %+v", result)
+	}
+
+	candidate := strings.Replace(testClusterConfig, "http://127.0.0.1:9001", "http://127.0.0.1:9009", 1)
+	result = validateCandidate(t, s, candidate)
+	if !result.Valid || len(result.Diff) != 1 || result.Diff[0].Path != "clusters[web].endpoints[0].url" {
+		t.Errorf("unexpected result %+v", result)
+	}
+
+	// Environment variable references are expanded as on load.
+	t.Setenv("NEXUS_TEST_WEB", "http://127.0.0.1:9001")
+	result = validateCandidate(t, s, strings.Replace(testClusterConfig, "http://127.0.0.1:9001", "${NEXUS_TEST_WEB}", 1))
+	if !result.Valid || len(result.Diff) != 0 {
+		t.Errorf("unexpected result with an environment reference: %+v", result)
+	}
+
+	tests := []struct {
+		name, body, stage string
+	}{
+		{"parse", "server: [", "parse"},
+		{"unknown field", testClusterConfig + "bogus: 1\n", "parse"},
+		{"unset variable", strings.Replace(testClusterConfig, "http://127.0.0.1:9001", "${NEXUS_TEST_UNSET}", 1), "parse"},
+		{"missing secret", strings.Replace(testClusterConfig, "http://127.0.0.1:9001", "file:/nonexistent/url", 1), "parse"},
+		{"validate", strings.Replace(testClusterConfig, "cluster: web", "cluster: nowhere", 1), "validate"},
+		{"compile", testClusterConfig + "    filters:\n      - type: teleport\n", "compile"},
+	}
+	for _, tt := range tests {
+		result := validateCandidate(t, s, tt.body)
+		if result.Valid || len(result.Errors) != 1 || result.Errors[0].Stage != tt.stage {
+			t.Errorf("%s: expected a %s error, got %+v", tt.name, tt.stage, result)
+		}
+	}
+
+	// Nothing is applied.
+	if store.Load() != before || s.versionManager.Len() != 0 {
+		t.Error("validation changed the active configuration")
+	}
+}
@@ -0,0 +1,225 @@
+// Package clientip resolves the address of the client behind a request and
+// matches client addresses against allow and deny lists. Forwarding headers
+// are honored only from trusted proxies, since any client can send them.
+package clientip
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/oriys/nexus/internal/reqctx"
+)
+
+// ParsePrefixes parses a list of IP addresses and CIDRs. An address is the
+// prefix holding only itself.
+func ParsePrefixes(list []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(list))
+	for _, s := range list {
+		if !strings.Contains(s, "/") {
+			addr, err := netip.ParseAddr(s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid IP %q", s)
+			}
+			addr = addr.Unmap()
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q", s)
+		}
+		prefixes = append(prefixes, p.Masked())
+	}
+	return prefixes, nil
+}
+
+// contains reports whether one of prefixes holds addr.
+func contains(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, p := range prefixes {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// PeerAddr returns the address of a remote address such as
+// http.Request.RemoteAddr, or the zero address when it holds none.
+func PeerAddr(remoteAddr string) netip.Addr {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}
+	}
+	return addr.Unmap()
+}
+
+// Resolver resolves the client address of requests. Requests from trusted
+// proxies are attributed to the address their forwarding headers name;
+// other requests to their peer address.
+type Resolver struct {
+	trusted []netip.Prefix
+}
+
+// NewResolver returns a Resolver trusting the proxies at the given
+// addresses and CIDRs. Without any, forwarding headers are ignored.
+func NewResolver(trustedProxies []string) (*Resolver, error) {
+	trusted, err := ParsePrefixes(trustedProxies)
+	if err != nil {
+		return nil, fmt.Errorf("trusted proxies: %w", err)
+	}
+	return &Resolver{trusted: trusted}, nil
+}
+
+// Resolve returns the client address of r. From a trusted peer, the hops of
+// Forwarded, else of X-Forwarded-For, are read from the right, each added
+// by a trusted proxy: the first untrusted hop is the client, and hops a
+// client prepends itself are never reached. Without either header, the
+// address in X-Real-IP is taken.
+func (res *Resolver) Resolve(r *http.Request) netip.Addr {
+	peer := PeerAddr(r.RemoteAddr)
+	if !contains(res.trusted, peer) {
+		return peer
+	}
+	hops := forwardedHops(r.Header.Values("Forwarded"))
+	if hops == nil {
+		hops = forwardedForHops(r.Header.Values("X-Forwarded-For"))
+	}
+	if hops == nil {
+		if addr, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP"))); err == nil {
+			return addr.Unmap()
+		}
+		return peer
+	}
+	client := peer
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(hops[i])
+		if err != nil {
+			// An unknown or obfuscated hop cannot be trusted further
+			return client
+		}
+		client = hop.Unmap()
+		if !contains(res.trusted, client) {
+			return client
+		}
+	}
+	return client
+}
+
+// forwardedForHops returns the hops of X-Forwarded-For headers, nil for
+// none.
+func forwardedForHops(values []string) []string {
+	var hops []string
+	for _, v := range values {
+		for _, hop := range strings.Split(v, ",") {
+			hops = append(hops, strings.TrimSpace(hop))
+		}
+	}
+	return hops
+}
+
+// forwardedHops returns the for= nodes of RFC 7239 Forwarded headers, nil
+// for none. Ports and the brackets and quotes around IPv6 addresses are
+// removed; an element without for= yields an empty hop.
+func forwardedHops(values []string) []string {
+	var hops []string
+	for _, v := range values {
+		for _, element := range strings.Split(v, ",") {
+			var node string
+			for _, pair := range strings.Split(element, ";") {
+				name, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if ok && strings.EqualFold(name, "for") {
+					node = forwardedNode(value)
+				}
+			}
+			hops = append(hops, node)
+		}
+	}
+	return hops
+}
+
+// forwardedNode returns the address of a Forwarded node such as
+// 192.0.2.60, "192.0.2.60:4711" or "[2001:db8::17]:4711".
+func forwardedNode(node string) string {
+	node = strings.Trim(node, `"`)
+	if rest, ok := strings.CutPrefix(node, "["); ok {
+		addr, _, _ := strings.Cut(rest, "]")
+		return addr
+	}
+	if host, _, err := net.SplitHostPort(node); err == nil {
+		return host
+	}
+	return node
+}
+
+// FromRequest returns the client address of r resolved by the ClientIP
+// middleware, or the peer address of r when no resolver ran.
+func FromRequest(r *http.Request) netip.Addr {
+	if addr, ok := reqctx.ClientIP.Get(r.Context()); ok {
+		return addr
+	}
+	return PeerAddr(r.RemoteAddr)
+}
+
+// ACL allows or denies client addresses. Deny entries win; with allow
+// entries, addresses matching none of them are denied.
+type ACL struct {
+	allow []netip.Prefix
+	deny  []netip.Prefix
+}
+
+// NewACL returns an ACL from lists of IP addresses and CIDRs.
+func NewACL(allow, deny []string) (*ACL, error) {
+	a, err := ParsePrefixes(allow)
+	if err != nil {
+		return nil, fmt.Errorf("allow: %w", err)
+	}
+	d, err := ParsePrefixes(deny)
+	if err != nil {
+		return nil, fmt.Errorf("deny: %w", err)
+	}
+	return &ACL{allow: a, deny: d}, nil
+}
+
+// Allows reports whether addr may pass. The zero address passes only an
+// ACL without entries.
+func (a *ACL) Allows(addr netip.Addr) bool {
+	if !addr.IsValid() {
+		return len(a.allow) == 0 && len(a.deny) == 0
+	}
+	addr = addr.Unmap()
+	if contains(a.deny, addr) {
+		return false
+	}
+	return len(a.allow) == 0 || contains(a.allow, addr)
+}
+
+// Listener returns ln closing the connections of peers a denies as soon as
+// they are accepted, before any TLS handshake or request is read.
+func (a *ACL) Listener(ln net.Listener) net.Listener {
+	return &aclListener{Listener: ln, acl: a}
+}
+
+type aclListener struct {
+	net.Listener
+	acl *ACL
+}
+
+func (l *aclListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		if l.acl.Allows(PeerAddr(conn.RemoteAddr().String())) {
+			return conn, nil
+		}
+		conn.Close()
+	}
+}
@@ -0,0 +1,118 @@
+package clientip
+
+import (
+	"net"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestResolver(t *testing.T) {
+	res, err := NewResolver([]string{"10.0.0.0/8", "192.168.1.1"})
+	if err != nil {
+		t.Fatalf("NewResolver: %v", err)
+	}
+	tests := []struct {
+		name   string
+		remote string
+		xff    []string
+		fwd    []string
+		realIP string
+		want   string
+	}{
+		{"untrusted peer", "203.0.113.9:4000", []string{"198.51.100.1"}, nil, "", "203.0.113.9"},
+		{"trusted peer", "10.1.2.3:4000", []string{"198.51.100.1"}, nil, "", "198.51.100.1"},
+		{"spoofed hops", "10.1.2.3:4000", []string{"1.2.3.4, 198.51.100.1, 10.9.9.9"}, nil, "", "198.51.100.1"},
+		{"several headers", "192.168.1.1:4000", []string{"1.2.3.4", "198.51.100.1"}, nil, "", "198.51.100.1"},
+		{"all trusted", "10.1.2.3:4000", []string{"10.4.4.4"}, nil, "", "10.4.4.4"},
+		{"no header", "10.1.2.3:4000", nil, nil, "", "10.1.2.3"},
+		{"malformed hop", "10.1.2.3:4000", []string{"1.2.3.4, bogus"}, nil, "", "10.1.2.3"},
+		{"mapped peer", "[::ffff:203.0.113.9]:4000", nil, nil, "", "203.0.113.9"},
+		{"forwarded", "10.1.2.3:4000", nil, []string{`for=1.2.3.4, for="[2001:db8::17]:4711";proto=https, for=10.5.5.5:80`}, "", "2001:db8::17"},
+		{"forwarded wins", "10.1.2.3:4000", []string{"198.51.100.1"}, []string{"For=198.51.100.2;by=10.1.2.3"}, "", "198.51.100.2"},
+		{"forwarded unknown", "10.1.2.3:4000", nil, []string{"for=198.51.100.2, for=unknown"}, "", "10.1.2.3"},
+		{"forwarded without for", "10.1.2.3:4000", nil, []string{"proto=https"}, "", "10.1.2.3"},
+		{"real ip", "10.1.2.3:4000", nil, nil, "198.51.100.3", "198.51.100.3"},
+		{"real ip untrusted", "203.0.113.9:4000", nil, nil, "198.51.100.3", "203.0.113.9"},
+		{"xff before real ip", "10.1.2.3:4000", []string{"198.51.100.1"}, nil, "198.51.100.3", "198.51.100.1"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = tt.remote
+		for _, v := range tt.xff {
+			req.Header.Add("X-Forwarded-For", v)
+		}
+		for _, v := range tt.fwd {
+			req.Header.Add("Forwarded", v)
+		}
+		if tt.realIP != "" {
+			req.Header.Set("X-Real-IP", tt.realIP)
+		}
+		if got := res.Resolve(req); got.String() != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+	if _, err := NewResolver([]string{"10.0.0.0/33"}); err == nil {
+		t.Error("expected an error for an invalid CIDR")
+	}
+}
+
+func TestACL(t *testing.T) {
+	acl, err := NewACL([]string{"10.0.0.0/8", "2001:db8::/32"}, []string{"10.0.0.66"})
+	if err != nil {
+		t.Fatalf("NewACL: %v", err)
+	}
+	for addr, want := range map[string]bool{
+		"10.1.2.3":        true,
+		"10.0.0.66":       false,
+		"::ffff:10.1.2.3": true,
+		"2001:db8::1":     true,
+		"203.0.113.9":     false,
+	} {
+		if got := acl.Allows(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("Allows(%s) = %v, want %v", addr, got, want)
+		}
+	}
+	if acl.Allows(netip.Addr{}) {
+		t.Error("the zero address passed an ACL with entries")
+	}
+
+	denyOnly, _ := NewACL(nil, []string{"203.0.113.0/24"})
+	if !denyOnly.Allows(netip.MustParseAddr("198.51.100.1")) || denyOnly.Allows(netip.MustParseAddr("203.0.113.9")) {
+		t.Error("deny-only ACL mismatched")
+	}
+	if _, err := NewACL([]string{"not-an-ip"}, nil); err == nil {
+		t.Error("expected an error for an invalid IP")
+	}
+}
+
+func TestACL_Listener(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	acl, _ := NewACL(nil, []string{"127.0.0.1"})
+	ln = acl.Listener(ln)
+
+	accepted := make(chan struct{})
+	go func() {
+		if conn, err := ln.Accept(); err == nil {
+			conn.Close()
+			close(accepted)
+		}
+	}()
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Read(make([]byte, 1)); err == nil {
+		t.Error("denied connection was not closed")
+	}
+	select {
+	case <-accepted:
+		t.Error("denied connection was accepted")
+	default:
+	}
+}
@@ -0,0 +1,160 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// AWSSecretsProvider reads secrets from AWS Secrets Manager. References name
+// the secret, by name or ARN, and optionally a key of a JSON secret, e.g.
+// "nexus/prod#api_key". Credentials are static; empty fields use the
+// standard AWS environment variables.
+type AWSSecretsProvider struct {
+	Client *http.Client
+	// Region defaults to AWS_REGION or AWS_DEFAULT_REGION.
+	Region          string
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	// Endpoint overrides the regional endpoint, e.g. for a VPC endpoint;
+	// empty uses AWS_ENDPOINT_URL_SECRETS_MANAGER or AWS_ENDPOINT_URL.
+	Endpoint string
+}
+
+// Resolve returns the secret string named by ref, or a key of it.
+func (p *AWSSecretsProvider) Resolve(ctx context.Context, ref string) (string, error) {
+	id, field, hasField := strings.Cut(ref, "#")
+	region := firstNonEmpty(p.Region, os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION"))
+	if region == "" {
+		return "", errors.New("aws-sm: AWS_REGION is not set")
+	}
+	creds := awsCredentials{
+		accessKeyID:     firstNonEmpty(p.AccessKeyID, os.Getenv("AWS_ACCESS_KEY_ID")),
+		secretAccessKey: firstNonEmpty(p.SecretAccessKey, os.Getenv("AWS_SECRET_ACCESS_KEY")),
+		sessionToken:    firstNonEmpty(p.SessionToken, os.Getenv("AWS_SESSION_TOKEN")),
+	}
+	if creds.accessKeyID == "" || creds.secretAccessKey == "" {
+		return "", errors.New("aws-sm: AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are not set")
+	}
+	endpoint := firstNonEmpty(p.Endpoint, os.Getenv("AWS_ENDPOINT_URL_SECRETS_MANAGER"), os.Getenv("AWS_ENDPOINT_URL"),
+		"https://secretsmanager."+region+".amazonaws.com")
+
+	body, _ := json.Marshal(map[string]string{"SecretId": id})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(endpoint, "/")+"/", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	signAWS(req, body, "secretsmanager", region, creds, time.Now())
+
+	resp, err := clientOrDefault(p.Client).Do(req)
+	if err != nil {
+		return "", fmt.Errorf("aws-sm: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := readSourceBody(resp)
+	if err != nil {
+		return "", fmt.Errorf("aws-sm: %s: %w", id, err)
+	}
+	var out struct {
+		SecretString *string `json:"SecretString"`
+	}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return "", fmt.Errorf("aws-sm: invalid response: %w", err)
+	}
+	if out.SecretString == nil {
+		return "", fmt.Errorf("aws-sm: secret %s has no string value", id)
+	}
+	if !hasField {
+		return *out.SecretString, nil
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(*out.SecretString), &fields); err != nil {
+		return "", fmt.Errorf("aws-sm: secret %s is not a JSON object", id)
+	}
+	value, err := secretField(fields, field)
+	if err != nil {
+		return "", fmt.Errorf("aws-sm: %s: %w", id, err)
+	}
+	return value, nil
+}
+
+// awsCredentials are static AWS credentials.
+type awsCredentials struct {
+	accessKeyID     string
+	secretAccessKey string
+	sessionToken    string
+}
+
+// signAWS signs req with AWS Signature Version 4. The host, Content-Type
+// and X-Amz-* headers are signed.
+func signAWS(req *http.Request, body []byte, service, region string, creds awsCredentials, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.sessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if lower == "content-type" || strings.HasPrefix(lower, "x-amz-") {
+			headers[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	bodyHash := sha256.Sum256(body)
+	canonical := strings.Join([]string{
+		req.Method,
+		path,
+		req.URL.Query().Encode(),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(bodyHash[:]),
+	}, "\n")
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	canonicalHash := sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(canonicalHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+creds.secretAccessKey), date)
+	for _, part := range []string{region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, toSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.accessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,48 @@
+package config
+
+import "fmt"
+
+// ErrorRateLimit returns max_error_rate, 0.05 when it is not set.
+func (b *BlueGreen) ErrorRateLimit() float64 {
+	if b.MaxErrorRate == 0 {
+		return 0.05
+	}
+	return b.MaxErrorRate
+}
+
+// MinRequestCount returns min_requests, 20 when it is not set.
+func (b *BlueGreen) MinRequestCount() int {
+	if b.MinRequests == 0 {
+		return 20
+	}
+	return b.MinRequests
+}
+
+// validateBlueGreen checks the blue/green clusters of an upstream.
+func validateBlueGreen(u *RouteUpstream) error {
+	b := u.BlueGreen
+	if u.Cluster != "" || len(u.Clusters) > 0 || u.Sticky != nil {
+		return fmt.Errorf("upstream.blue_green cannot be combined with cluster, clusters or sticky")
+	}
+	if b.Blue == "" || b.Green == "" {
+		return fmt.Errorf("upstream.blue_green: blue and green are required")
+	}
+	if b.Blue == b.Green {
+		return fmt.Errorf("upstream.blue_green: blue and green must be different clusters")
+	}
+	switch b.Active {
+	case "", "blue", "green":
+	default:
+		return fmt.Errorf("upstream.blue_green.active: must be 'blue' or 'green', got %q", b.Active)
+	}
+	if err := checkDuration("upstream.blue_green.bake", b.Bake, 0); err != nil {
+		return err
+	}
+	if b.MaxErrorRate < 0 || b.MaxErrorRate > 1 {
+		return fmt.Errorf("upstream.blue_green.max_error_rate must be between 0 and 1, got %v", b.MaxErrorRate)
+	}
+	if b.MinRequests < 0 {
+		return fmt.Errorf("upstream.blue_green.min_requests must not be negative")
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package config
+
+import (
+	"fmt"
+	"text/template"
+)
+
+// DefaultCacheKey is the cache key template of routes without one.
+const DefaultCacheKey = "{{.Host}}{{.Path}}?{{.RawQuery}}"
+
+// KeyTemplate returns the cache key template of the route.
+func (c *RouteCache) KeyTemplate() string {
+	if c.Key == "" {
+		return DefaultCacheKey
+	}
+	return c.Key
+}
+
+// RedisPrefix returns the prefix of the cache keys in Redis.
+func (r *CacheRedis) RedisPrefix() string {
+	if r.Prefix == "" {
+		return "nexus:cache:"
+	}
+	return r.Prefix
+}
+
+// validateResponseCache checks the response cache store.
+func validateResponseCache(c *ResponseCache) error {
+	if c == nil {
+		return nil
+	}
+	switch c.Store {
+	case "", "memory":
+	case "redis":
+		if c.Redis == nil || c.Redis.Address == "" {
+			return fmt.Errorf("response_cache.redis.address is required with the redis store")
+		}
+	default:
+		return fmt.Errorf("response_cache.store: unsupported store %q, must be 'memory' or 'redis'", c.Store)
+	}
+	if c.MaxEntries < 0 {
+		return fmt.Errorf("response_cache.max_entries must not be negative")
+	}
+	if err := checkSize("response_cache.max_response", c.MaxResponse, "", 0); err != nil {
+		return err
+	}
+	if r := c.Redis; r != nil {
+		if r.DB < 0 {
+			return fmt.Errorf("response_cache.redis.db must not be negative")
+		}
+		if err := checkDuration("response_cache.redis.timeout", r.Timeout, 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateRouteCache checks the response cache settings of a route.
+func validateRouteCache(c *RouteCache) error {
+	if c == nil {
+		return nil
+	}
+	if err := checkDuration("cache.ttl", c.TTL, 0); err != nil {
+		return err
+	}
+	if _, err := template.New("key").Parse(c.KeyTemplate()); err != nil {
+		return fmt.Errorf("cache.key: %w", err)
+	}
+	return nil
+}
@@ -11,12 +11,215 @@ type Config struct {
 	RateLimit RateLimitConfig `yaml:"rate_limit"`
 	Auth      AuthConfig      `yaml:"auth"`
 	Admin     AdminConfig     `yaml:"admin"`
-	Version   string          `yaml:"version,omitempty"`
-	Listeners []Listener      `yaml:"listeners,omitempty"`
-	Clusters  []Cluster       `yaml:"clusters,omitempty"`
-	RoutesV2  []RouteV2       `yaml:"routes_v2,omitempty"`
+	// Version pins the configuration schema, e.g. "v1"; a configuration for
+	// a schema this binary does not support is rejected. Empty means
+	// SchemaVersion.
+	Version   string     `yaml:"version,omitempty"`
+	Listeners []Listener `yaml:"listeners,omitempty"`
+	Clusters  []Cluster  `yaml:"clusters,omitempty"`
+	RoutesV2  []RouteV2  `yaml:"routes_v2,omitempty"`
+	// Defaults holds settings V2 routes inherit unless they set their own.
+	Defaults *RouteDefaults `yaml:"defaults,omitempty"`
+	// FilterChains names filter lists that V2 routes include with a
+	// {chain: name} filter, instead of repeating the same filters.
+	FilterChains map[string][]RouteFilter `yaml:"filter_chains,omitempty"`
+	// PathNormalization normalizes request paths before routes, legacy and
+	// V2, are matched.
+	PathNormalization *PathNormalization `yaml:"path_normalization,omitempty"`
+	// ProtoDescriptors lists compiled FileDescriptorSet files used for
+	// JSON↔protobuf transcoding on gRPC routes.
+	ProtoDescriptors []string `yaml:"proto_descriptors,omitempty"`
 	// PluginMode enables the ShenYu-style plugin chain handler.
 	PluginMode bool `yaml:"plugin_mode,omitempty"`
+	// ExternalPlugins are sidecar gRPC services the plugin chain calls, in
+	// plugin mode and in the V2 gateway.
+	ExternalPlugins []ExternalPlugin `yaml:"external_plugins,omitempty"`
+	// PluginDir is a directory of compiled Go plugins (*.so files built with
+	// -buildmode=plugin) loaded into the plugin chain at startup.
+	PluginDir string `yaml:"plugin_dir,omitempty"`
+	// Plugins sets the state of plugin chain plugins, by plugin name;
+	// plugins without a setting are enabled for every request.
+	Plugins []PluginSetting `yaml:"plugins,omitempty"`
+	// TrustedProxies lists the addresses and CIDRs of the proxies in front
+	// of the gateway. Forwarded, X-Forwarded-For and X-Real-IP are honored
+	// only from them, so the client address of a request is the last hop
+	// they did not add. Rate limiting, access logs and IP ACLs all use this
+	// address.
+	TrustedProxies []string `yaml:"trusted_proxies,omitempty"`
+	// IPACL allows or denies every gateway request by client address; V2
+	// routes narrow it further with the ip_acl filter.
+	IPACL *IPACL `yaml:"ip_acl,omitempty"`
+	// Maintenance answers every gateway request with a maintenance response
+	// while it is enabled. Health endpoints keep answering.
+	Maintenance *Maintenance `yaml:"maintenance,omitempty"`
+	// LoadShedding rejects V2 requests of low priority tiers first when the
+	// gateway nears overload.
+	LoadShedding *LoadShedding `yaml:"load_shedding,omitempty"`
+	// Watchdog samples the heap, goroutines and scheduling latency of the
+	// gateway, degrading it while they are past their thresholds.
+	Watchdog *Watchdog `yaml:"watchdog,omitempty"`
+	// Tenancy labels requests with a tenant, partitioning rate limits,
+	// metrics, access logs and cluster selection by tenant.
+	Tenancy *Tenancy `yaml:"tenancy,omitempty"`
+	// ResponseCache configures the store of the HTTP response cache, which
+	// V2 routes enable with cache.
+	ResponseCache *ResponseCache `yaml:"response_cache,omitempty"`
+}
+
+// ResponseCache configures where cached responses are kept.
+type ResponseCache struct {
+	// Store is "memory" (default) or "redis".
+	Store string `yaml:"store,omitempty"`
+	// MaxEntries bounds the responses kept in memory (default 10000); the
+	// least recently used are evicted first.
+	MaxEntries int `yaml:"max_entries,omitempty"`
+	// MaxResponse is the largest response body cached (default 1MiB).
+	MaxResponse Size        `yaml:"max_response,omitempty"`
+	Redis       *CacheRedis `yaml:"redis,omitempty"`
+}
+
+// CacheRedis is the Redis server of a response cache stored in Redis.
+type CacheRedis struct {
+	Address  string `yaml:"address"`
+	Password string `yaml:"password,omitempty"`
+	DB       int    `yaml:"db,omitempty"`
+	// Prefix starts the keys of cached responses (default "nexus:cache:").
+	Prefix string `yaml:"prefix,omitempty"`
+	// Timeout bounds each Redis command (default 1s); the cache is skipped
+	// when Redis does not answer in time.
+	Timeout Duration `yaml:"timeout,omitempty"`
+}
+
+// Tenancy resolves the tenant of a request from an identity claim or, when
+// the caller has none, a request header.
+type Tenancy struct {
+	Header string `yaml:"header,omitempty"`
+	Claim  string `yaml:"claim,omitempty"`
+	// Default is the tenant of requests without one; such requests are not
+	// partitioned when it is empty.
+	Default string `yaml:"default,omitempty"`
+	// RateLimit limits the requests of each tenant separately; Tenants may
+	// override it.
+	RateLimit *TenantRateLimit `yaml:"rate_limit,omitempty"`
+	// Tenants configures known tenants. When it is not empty, the metrics
+	// of other tenants are counted as "other".
+	Tenants []Tenant `yaml:"tenants,omitempty"`
+}
+
+// Tenant configures the traffic of one tenant.
+type Tenant struct {
+	Name      string           `yaml:"name"`
+	RateLimit *TenantRateLimit `yaml:"rate_limit,omitempty"`
+	// Clusters maps the clusters of V2 routes to the clusters serving the
+	// tenant instead.
+	Clusters map[string]string `yaml:"clusters,omitempty"`
+}
+
+// TenantRateLimit allows Rate requests per Window (default 1m).
+type TenantRateLimit struct {
+	Rate   int      `yaml:"rate"`
+	Window Duration `yaml:"window,omitempty"`
+}
+
+// LoadShedding sheds requests by priority tier: "critical", "high",
+// "normal" or "low". The load of the gateway is the larger of its requests
+// in flight over MaxInFlight and its recent average latency over
+// MaxLatency. Low requests are shed from 60% of it, normal requests from
+// 80% and high requests from 100%; critical requests are never shed.
+type LoadShedding struct {
+	MaxInFlight int      `yaml:"max_in_flight,omitempty"`
+	MaxLatency  Duration `yaml:"max_latency,omitempty"`
+	// Header names a request header carrying the tier. Clients can send
+	// it as well, so it can only lower a request below the tier of its
+	// route or the default tier, never raise it.
+	Header string `yaml:"header,omitempty"`
+	// DefaultTier is the tier of requests without one (default "normal").
+	DefaultTier string `yaml:"default_tier,omitempty"`
+}
+
+// Watchdog sets the thresholds past which the gateway degrades. While
+// degraded, it sheds all but critical requests as if fully loaded, skips
+// the features that buffer bodies (mirroring and storing cache entries) and
+// reports not ready on /readyz. It recovers once every sample is below 80%
+// of its threshold. Zero thresholds are not watched.
+type Watchdog struct {
+	// Interval is the time between samples (default 1s).
+	Interval Duration `yaml:"interval,omitempty"`
+	// MaxHeap bounds the bytes of live and unswept heap objects.
+	MaxHeap Size `yaml:"max_heap,omitempty"`
+	// MaxGoroutines bounds the goroutines of the process.
+	MaxGoroutines int `yaml:"max_goroutines,omitempty"`
+	// MaxLag bounds how late the watchdog's timer fires, a measure of the
+	// time runnable goroutines wait for a CPU.
+	MaxLag Duration `yaml:"max_lag,omitempty"`
+}
+
+// Maintenance is a switch answering requests with a maintenance response,
+// 503 with a JSON error unless configured otherwise, instead of serving
+// them.
+type Maintenance struct {
+	Enabled bool `yaml:"enabled"`
+	// Status defaults to 503.
+	Status int `yaml:"status,omitempty"`
+	// Body is the response, e.g. an HTML page or a JSON document. It
+	// defaults to {"error":"maintenance","message":"service under maintenance"}.
+	Body string `yaml:"body,omitempty"`
+	// ContentType defaults to text/html for bodies starting with "<" and
+	// to application/json otherwise.
+	ContentType string `yaml:"content_type,omitempty"`
+	// RetryAfter sets the Retry-After header, e.g. "30m".
+	RetryAfter Duration `yaml:"retry_after,omitempty"`
+}
+
+// IPACL allows or denies clients by address. Entries are IP addresses or
+// CIDRs such as "10.0.0.0/8". Deny entries win; with allow entries, any
+// other address is denied.
+type IPACL struct {
+	Allow []string `yaml:"allow,omitempty"`
+	Deny  []string `yaml:"deny,omitempty"`
+}
+
+// PluginSetting sets the state of a plugin in the plugin chain.
+type PluginSetting struct {
+	Name string `yaml:"name"`
+	// Disabled takes the plugin out of the chain.
+	Disabled bool `yaml:"disabled,omitempty"`
+	// Routes binds the plugin to routes: it then runs only for requests
+	// matching one of them. Empty runs it for every request.
+	Routes []PluginBinding `yaml:"routes,omitempty"`
+}
+
+// PluginBinding binds a plugin to a route, with the plugin configuration
+// for requests matching it.
+type PluginBinding struct {
+	Route  string            `yaml:"route"`
+	Config map[string]string `yaml:"config,omitempty"`
+}
+
+// ExternalPlugin configures a plugin implemented by a sidecar gRPC service,
+// which can be written in any language. The plugin chain sends it each
+// request and applies the verdict it returns.
+type ExternalPlugin struct {
+	Name string `yaml:"name"`
+	// Endpoint is the base URL of the service, e.g. "http://127.0.0.1:9090";
+	// http endpoints are reached over h2c, https endpoints over TLS.
+	Endpoint string `yaml:"endpoint"`
+	// Order places the plugin in the chain; lower orders run first.
+	// Defaults to 50, between global_log and http_proxy.
+	Order int `yaml:"order,omitempty"`
+	// Timeout bounds each call, e.g. "200ms". Defaults to 1s.
+	Timeout Duration `yaml:"timeout,omitempty"`
+	// SendBody includes the request body, up to MaxBody bytes, in calls.
+	SendBody bool `yaml:"send_body,omitempty"`
+	// MaxBody bounds the body bytes sent, e.g. "64KiB" (the default); a
+	// longer body is sent truncated.
+	MaxBody Size `yaml:"max_body,omitempty"`
+	// FailOpen passes requests on when the service fails or times out,
+	// instead of answering them with 502.
+	FailOpen bool `yaml:"fail_open,omitempty"`
+	// ConfigSchema is a JSON schema, written in YAML, that the plugin
+	// configurations bound to routes must satisfy.
+	ConfigSchema map[string]any `yaml:"config_schema,omitempty"`
 }
 
 // ServerConfig defines the HTTP server settings.
@@ -25,6 +228,117 @@ type ServerConfig struct {
 	ReadTimeout     time.Duration `yaml:"read_timeout"`
 	WriteTimeout    time.Duration `yaml:"write_timeout"`
 	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+	// H2C accepts HTTP/2 without TLS (prior knowledge) alongside HTTP/1.1,
+	// which plaintext gRPC clients require.
+	H2C bool `yaml:"h2c,omitempty"`
+	// TLS terminates TLS on the listener, so that V2 routes can match the
+	// server name and client certificate with match.tls.
+	TLS *ServerTLSConfig `yaml:"tls,omitempty"`
+	// MaxHeaderBytes bounds the size of the request line and headers, e.g.
+	// "64KiB"; larger requests are answered with 431. Defaults to 1MB, as
+	// in net/http.
+	MaxHeaderBytes Size `yaml:"max_header_bytes,omitempty"`
+	// MaxHeaderCount bounds the number of request header fields; requests
+	// with more are answered with 431. Zero means no limit.
+	MaxHeaderCount int `yaml:"max_header_count,omitempty"`
+	// MaxURLLength bounds the length of the request target, path and query
+	// together; longer requests are answered with 414. Zero means no limit.
+	MaxURLLength int `yaml:"max_url_length,omitempty"`
+	// IPACL allows or denies connections to the listener by peer address,
+	// closing denied connections before TLS or HTTP is spoken; forwarding
+	// headers play no part at this level.
+	IPACL *IPACL `yaml:"ip_acl,omitempty"`
+	// Connections limits the connections the listener accepts, before TLS
+	// or HTTP is spoken.
+	Connections *ConnectionLimits `yaml:"connections,omitempty"`
+	// Fallback answers the requests no route matches; nil answers them with
+	// a plain-text 404.
+	Fallback *FallbackConfig `yaml:"fallback,omitempty"`
+}
+
+// ConnectionLimits bounds the connections of a listener. Zero values mean
+// no limit.
+type ConnectionLimits struct {
+	// Rate is the number of new connections accepted per second, with
+	// bursts of up to Burst (default Rate). Connections above the rate
+	// wait in the listen backlog.
+	Rate  int `yaml:"rate,omitempty"`
+	Burst int `yaml:"burst,omitempty"`
+	// Max bounds the connections open at once, and MaxPerIP those from one
+	// peer address. Connections above either are closed as soon as they
+	// are accepted; plaintext listeners first answer them with 503.
+	Max      int `yaml:"max,omitempty"`
+	MaxPerIP int `yaml:"max_per_ip,omitempty"`
+}
+
+// FallbackConfig defines how the gateway answers requests no route matches.
+// Requests whose path matches a V2 route restricted to other methods are
+// still answered with 405.
+type FallbackConfig struct {
+	// Cluster forwards unmatched requests to an http cluster, with the
+	// defaults section applied as to routes_v2.
+	Cluster string `yaml:"cluster,omitempty"`
+	// Status and Body answer unmatched requests with a JSON response
+	// instead. Status defaults to 404 and Body to the gateway's JSON error
+	// response.
+	Status int    `yaml:"status,omitempty"`
+	Body   string `yaml:"body,omitempty"`
+}
+
+// ServerTLSConfig defines the certificates of the gateway listener. The
+// certificate and key files are watched, and reloaded without a restart
+// when they change.
+type ServerTLSConfig struct {
+	CertFile string `yaml:"cert_file,omitempty"`
+	KeyFile  string `yaml:"key_file,omitempty"`
+	// Cert and Key hold the certificate and key as PEM instead of files.
+	Cert string `yaml:"cert,omitempty"`
+	Key  string `yaml:"key,omitempty" json:"-"`
+	// Certificates are further certificates, selected by the server name
+	// (SNI) clients request. The certificate above, or else the first of
+	// these, serves clients requesting no name or an unknown one.
+	Certificates []TLSCertificate `yaml:"certificates,omitempty"`
+	// ClientCAFile verifies client certificates. They are verified when
+	// presented unless RequireClientCert is set.
+	ClientCAFile      string `yaml:"client_ca_file,omitempty"`
+	RequireClientCert bool   `yaml:"require_client_cert,omitempty"`
+	// OCSPStapleFile is a DER-encoded OCSP response for the certificate
+	// above, stapled to handshakes and reloaded along with the certificate.
+	OCSPStapleFile string `yaml:"ocsp_staple_file,omitempty"`
+	// MinVersion and MaxVersion bound the TLS versions accepted, "1.0" to
+	// "1.3". MinVersion defaults to "1.2", MaxVersion to "1.3".
+	MinVersion string `yaml:"min_version,omitempty"`
+	MaxVersion string `yaml:"max_version,omitempty"`
+	// CipherSuites restricts the TLS 1.0-1.2 cipher suites, by IANA name
+	// such as TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256. TLS 1.3 suites are
+	// not configurable. Empty uses the Go defaults.
+	CipherSuites []string `yaml:"cipher_suites,omitempty"`
+	// CurvePreferences orders the key exchange groups: X25519, P256, P384,
+	// P521 and X25519MLKEM768. Empty uses the Go defaults.
+	CurvePreferences []string `yaml:"curve_preferences,omitempty"`
+	// ALPN lists the application protocols offered, e.g. [http/1.1] to
+	// turn HTTP/2 off. Empty offers h2 and http/1.1.
+	ALPN []string `yaml:"alpn,omitempty"`
+	// DisableSessionTickets turns off session resumption with tickets.
+	DisableSessionTickets bool `yaml:"disable_session_tickets,omitempty"`
+	// SessionTicketRotation replaces the session ticket key at this
+	// interval, e.g. "1h"; tickets under the two previous keys are still
+	// accepted. Empty keeps the Go default of a daily rotation.
+	SessionTicketRotation Duration `yaml:"session_ticket_rotation,omitempty"`
+}
+
+// TLSCertificate is a certificate of the gateway listener selected by SNI.
+type TLSCertificate struct {
+	CertFile string `yaml:"cert_file,omitempty"`
+	KeyFile  string `yaml:"key_file,omitempty"`
+	Cert     string `yaml:"cert,omitempty"`
+	Key      string `yaml:"key,omitempty" json:"-"`
+	// ServerNames are the names the certificate serves, such as
+	// "api.example.com" or "*.example.com". Defaults to the DNS names of
+	// the certificate.
+	ServerNames []string `yaml:"server_names,omitempty"`
+	// OCSPStapleFile is a DER-encoded OCSP response for the certificate.
+	OCSPStapleFile string `yaml:"ocsp_staple_file,omitempty"`
 }
 
 // Upstream defines a group of backend targets.
@@ -41,11 +355,16 @@ type Target struct {
 
 // Route maps incoming requests to an upstream.
 type Route struct {
-	Name     string        `yaml:"name"`
-	Host     string        `yaml:"host"`
-	Paths    []PathRule    `yaml:"paths"`
-	Upstream string        `yaml:"upstream"`
-	Rewrite  *RewriteRule  `yaml:"rewrite,omitempty"`
+	Name     string       `yaml:"name"`
+	Host     string       `yaml:"host"`
+	Paths    []PathRule   `yaml:"paths"`
+	Upstream string       `yaml:"upstream"`
+	Rewrite  *RewriteRule `yaml:"rewrite,omitempty"`
+	// Priority orders routes competing for the same request: among exact
+	// paths on one host, templates and regexes, or prefixes, higher
+	// priorities are tried first. Equal priorities keep the implicit order,
+	// longest prefix first, then configuration order.
+	Priority int `yaml:"priority,omitempty"`
 }
 
 // RewriteRule defines request rewriting rules for a route.
@@ -72,7 +391,22 @@ type PathRewrite struct {
 	// Prefix replaces the matching path prefix with the given value.
 	// For example, if the route matches "/api" and Prefix is "/internal",
 	// then "/api/users" becomes "/internal/users".
-	Prefix string `yaml:"prefix"`
+	Prefix string `yaml:"prefix,omitempty"`
+	// Path replaces the whole matched path. Path parameters of template and
+	// regex rules are substituted, e.g. "/internal/users/{id}".
+	Path string `yaml:"path,omitempty"`
+	// RegexRewrite rewrites the path with a regular expression and capture
+	// group substitution.
+	RegexRewrite *RegexRewrite `yaml:"regex_rewrite,omitempty"`
+}
+
+// RegexRewrite rewrites the request path matching Pattern. Substitution
+// refers to capture groups as $1 or ${name} and may end in a query string
+// that is prepended to the request query, e.g. "^/api/v(\d+)/(.*)$" →
+// "/internal/$2?ver=$1".
+type RegexRewrite struct {
+	Pattern      string `yaml:"pattern"`
+	Substitution string `yaml:"substitution"`
 }
 
 // HeaderRewrite defines header manipulation rules.
@@ -108,13 +442,49 @@ type DubboRewrite struct {
 // PathRule defines a path matching rule.
 type PathRule struct {
 	Path string `yaml:"path"`
-	Type string `yaml:"type"` // "exact" or "prefix"
+	// Type is "exact", "prefix" or "regex". Exact and prefix paths may be
+	// templates such as "/users/{id}".
+	Type string `yaml:"type"`
 }
 
 // LoggingConfig defines logging settings.
 type LoggingConfig struct {
-	Level  string `yaml:"level"`
+	// Level is "debug", "info" (default), "warn" or "error". The admin API
+	// may change it while the gateway runs.
+	Level string `yaml:"level"`
+	// Format is "json" (default) or "text".
 	Format string `yaml:"format"`
+	// Headers lists request headers recorded in access logs, e.g.
+	// [User-Agent, X-API-Key]; sensitive ones are masked.
+	Headers []string `yaml:"headers,omitempty"`
+	// Redact masks secrets in access and error logs and in the
+	// configuration GET /api/v1/config serves.
+	Redact RedactConfig `yaml:"redact,omitempty"`
+	// Sampling limits the error records logged with the same message.
+	Sampling *LogSampling `yaml:"sampling,omitempty"`
+}
+
+// LogSampling limits repeated error records. In each interval, the first
+// records with a message are logged, then only one in thereafter; the rest
+// are dropped and counted.
+type LogSampling struct {
+	// Interval is the window records are counted in (default 1s).
+	Interval Duration `yaml:"interval,omitempty"`
+	// First is the number of records with a message logged per interval.
+	First int `yaml:"first"`
+	// Thereafter logs one in every Thereafter records past First; zero
+	// drops them all.
+	Thereafter int `yaml:"thereafter,omitempty"`
+}
+
+// RedactConfig names the secrets masked in logs and configuration dumps.
+type RedactConfig struct {
+	// Headers are masked in addition to Authorization,
+	// Proxy-Authorization, Cookie, Set-Cookie and X-API-Key.
+	Headers []string `yaml:"headers,omitempty"`
+	// Fields are JSON body fields masked at any depth wherever bodies are
+	// logged, e.g. [password, token].
+	Fields []string `yaml:"fields,omitempty"`
 }
 
 // RateLimitConfig defines rate limiting settings.
@@ -139,6 +509,64 @@ type APIKeyConfig struct {
 type AdminConfig struct {
 	Enabled bool   `yaml:"enabled"`
 	Listen  string `yaml:"listen"`
+	// TLS serves the admin API over HTTPS. It is required to authenticate
+	// callers by client certificate.
+	TLS *AdminTLSConfig `yaml:"tls,omitempty"`
+	// Auth restricts who may call the admin API. Nil leaves it open to
+	// anyone who can reach the listener.
+	Auth *AdminAuthConfig `yaml:"auth,omitempty"`
+	// Persist writes changes made through the admin API back to the
+	// configuration file, so they survive a restart. The file is rewritten
+	// from the effective configuration, dropping comments and formatting,
+	// and environment variable references are written out expanded. Secret
+	// references are kept.
+	Persist bool `yaml:"persist,omitempty"`
+}
+
+// AdminTLSConfig defines the certificates of the admin listener.
+type AdminTLSConfig struct {
+	CertFile string `yaml:"cert_file,omitempty"`
+	KeyFile  string `yaml:"key_file,omitempty"`
+	// Cert and Key hold the certificate and key as PEM instead of files,
+	// typically through secret references such as "vault:pki/nexus#key".
+	Cert string `yaml:"cert,omitempty"`
+	Key  string `yaml:"key,omitempty" json:"-"`
+	// ClientCAFile verifies client certificates presented to the admin
+	// API. Certificates are optional at the TLS layer, so token callers
+	// can still connect.
+	ClientCAFile string `yaml:"client_ca_file,omitempty"`
+}
+
+// Admin API roles. A read_only caller may use GET endpoints; an operator may
+// also change configuration and invoke debugging endpoints.
+const (
+	AdminRoleReadOnly = "read_only"
+	AdminRoleOperator = "operator"
+)
+
+// AdminAuthConfig defines admin API authentication and access control.
+// Callers are identified by bearer token or client certificate; when
+// neither is configured, only the network allowlist applies.
+type AdminAuthConfig struct {
+	Tokens      []AdminToken      `yaml:"tokens,omitempty"`
+	ClientCerts []AdminClientCert `yaml:"client_certs,omitempty"`
+	// AllowedCIDRs restricts the client addresses allowed to reach the
+	// admin API, as CIDRs or single IPs. Empty allows any address.
+	AllowedCIDRs []string `yaml:"allowed_cidrs,omitempty"`
+}
+
+// AdminToken grants a role to callers sending "Authorization: Bearer <token>".
+type AdminToken struct {
+	Name  string `yaml:"name"`
+	Token string `yaml:"token" json:"-"`
+	Role  string `yaml:"role"`
+}
+
+// AdminClientCert grants a role to callers presenting a client certificate,
+// verified against admin.tls.client_ca_file, with this subject common name.
+type AdminClientCert struct {
+	CommonName string `yaml:"common_name"`
+	Role       string `yaml:"role"`
 }
 
 // Listener defines a network listener.
@@ -171,87 +599,658 @@ type ClusterEndpoint struct {
 type KeepaliveConfig struct {
 	MaxIdleConns      int `yaml:"max_idle_conns"`
 	IdleConnTimeoutMs int `yaml:"idle_conn_timeout_ms"`
+	// IdleConnTimeout is IdleConnTimeoutMs with a unit, e.g. "90s".
+	IdleConnTimeout Duration `yaml:"idle_conn_timeout,omitempty"`
 }
 
 // ClusterGRPC defines gRPC-specific cluster settings.
 type ClusterGRPC struct {
-	Authority    string `yaml:"authority"`
-	MaxRecvMsgMB int    `yaml:"max_recv_msg_mb"`
+	Authority string `yaml:"authority"`
+	// MaxRecvMsgMB rejects upstream messages larger than this many MiB (0 = no limit).
+	MaxRecvMsgMB int `yaml:"max_recv_msg_mb"`
+	// MaxRecvMsg is the limit as a size, e.g. "4MiB".
+	MaxRecvMsg Size `yaml:"max_recv_msg,omitempty"`
+	// MaxSendMsg bounds the request messages sent to the cluster and the
+	// JSON bodies they are encoded from (default 4MiB); larger requests
+	// fail with RESOURCE_EXHAUSTED.
+	MaxSendMsg Size `yaml:"max_send_msg,omitempty"`
+	// KeepaliveTimeMs sends an HTTP/2 PING after this long without frames
+	// from an endpoint (0 = disabled).
+	KeepaliveTimeMs int      `yaml:"keepalive_time_ms,omitempty"`
+	KeepaliveTime   Duration `yaml:"keepalive_time,omitempty"`
+	// KeepaliveTimeoutMs closes a connection whose PING is not answered in
+	// time (default: 20000).
+	KeepaliveTimeoutMs int      `yaml:"keepalive_timeout_ms,omitempty"`
+	KeepaliveTimeout   Duration `yaml:"keepalive_timeout,omitempty"`
+	// MaxConcurrentStreams caps in-flight calls per endpoint; further calls
+	// wait for a free slot (0 = unlimited).
+	MaxConcurrentStreams int `yaml:"max_concurrent_streams,omitempty"`
+	// Reflection discovers service descriptors from the backend's server
+	// reflection service when the config is compiled.
+	Reflection bool `yaml:"reflection,omitempty"`
+	// ReflectionTimeoutMs bounds reflection discovery (default: 5000).
+	ReflectionTimeoutMs int      `yaml:"reflection_timeout_ms,omitempty"`
+	ReflectionTimeout   Duration `yaml:"reflection_timeout,omitempty"`
 }
 
 // ClusterDubbo defines Dubbo-specific cluster settings.
 type ClusterDubbo struct {
-	Application   string `yaml:"application"`
-	Group         string `yaml:"group"`
-	Version       string `yaml:"version"`
+	Application string `yaml:"application"`
+	Group       string `yaml:"group"`
+	Version     string `yaml:"version"`
+	// Serialization selects the payload encoding. With the dubbo protocol,
+	// "hessian2" calls providers natively over the dubbo2 TCP protocol and
+	// "json" (the default) posts JSON invocations to the provider's HTTP
+	// endpoint. With triple, "hessian2" (the default) sends wrapped Hessian
+	// arguments and "protobuf" transcodes JSON to the IDL messages.
 	Serialization string `yaml:"serialization"`
+	// Protocol is "dubbo" (default) or "triple", Dubbo 3's gRPC-compatible
+	// protocol over HTTP/2.
+	Protocol string `yaml:"protocol,omitempty"`
+	// Connections is the number of dubbo2 connections kept to each provider
+	// (default 1); requests are multiplexed over them.
+	Connections int `yaml:"connections,omitempty"`
+	// HeartbeatMs probes idle dubbo2 connections at this interval and drops
+	// those silent for three intervals (default: 60000).
+	HeartbeatMs int      `yaml:"heartbeat_ms,omitempty"`
+	Heartbeat   Duration `yaml:"heartbeat,omitempty"`
+	// ReconnectBackoffMs is the initial wait before redialing a provider that
+	// could not be reached, doubling up to ReconnectBackoffMaxMs (defaults:
+	// 500 and 30000).
+	ReconnectBackoffMs    int      `yaml:"reconnect_backoff_ms,omitempty"`
+	ReconnectBackoffMaxMs int      `yaml:"reconnect_backoff_max_ms,omitempty"`
+	ReconnectBackoff      Duration `yaml:"reconnect_backoff,omitempty"`
+	ReconnectBackoffMax   Duration `yaml:"reconnect_backoff_max,omitempty"`
+	// Registry is where providers register; the admin API lists the services
+	// found there. Calls still go to the cluster's endpoints.
+	Registry *DubboRegistry `yaml:"registry,omitempty"`
+	// MaxBody bounds the request bodies read to build invocations (default
+	// 8MiB); larger requests are answered with 413.
+	MaxBody Size `yaml:"max_body,omitempty"`
+}
+
+// DubboRegistry configures the registry Dubbo providers register in.
+type DubboRegistry struct {
+	// Type is the registry kind; only "nacos" is supported.
+	Type string `yaml:"type"`
+	// Address is the registry server, e.g. "http://nacos:8848".
+	Address   string `yaml:"address"`
+	Namespace string `yaml:"namespace,omitempty"`
+	Group     string `yaml:"group,omitempty"`
+	// TimeoutMs bounds each registry query (default: 5000).
+	TimeoutMs int      `yaml:"timeout_ms,omitempty"`
+	Timeout   Duration `yaml:"timeout,omitempty"`
 }
 
 // ClusterGraphQL defines GraphQL-specific cluster settings.
 type ClusterGraphQL struct {
 	// MaxBodyBytes limits the maximum size of the GraphQL request body (0 = no limit).
 	MaxBodyBytes int64 `yaml:"max_body_bytes,omitempty"`
+	// MaxBody is the limit as a size, e.g. "2MiB".
+	MaxBody Size `yaml:"max_body,omitempty"`
 }
 
 // RouteV2 defines a route in the new DSL format.
 type RouteV2 struct {
-	Name     string        `yaml:"name"`
-	Match    RouteMatch    `yaml:"match"`
-	Filters  []RouteFilter `yaml:"filters,omitempty"`
-	Upstream RouteUpstream `yaml:"upstream"`
+	Name    string        `yaml:"name"`
+	Match   RouteMatch    `yaml:"match"`
+	Filters []RouteFilter `yaml:"filters,omitempty"`
+	// NoDefaultFilters keeps a route without filters of its own from
+	// inheriting defaults.filters.
+	NoDefaultFilters bool          `yaml:"no_default_filters,omitempty"`
+	Upstream         RouteUpstream `yaml:"upstream"`
+	// Priority orders routes competing for the same request, as for Route:
+	// within exact paths, templates and regexes, or prefixes, higher
+	// priorities are tried first.
+	Priority int `yaml:"priority,omitempty"`
+	// Tier is the priority tier of the route's requests under load
+	// shedding; load_shedding.default_tier when empty.
+	Tier string `yaml:"tier,omitempty"`
+	// Maintenance answers the route's requests with a maintenance response
+	// while it is enabled.
+	Maintenance *Maintenance `yaml:"maintenance,omitempty"`
+	// Schedule limits when the route is active; outside it the route
+	// matches no requests.
+	Schedule *RouteSchedule `yaml:"schedule,omitempty"`
+	// Idempotency replays the first response to a request carrying an
+	// idempotency key to the retries of the request.
+	Idempotency *Idempotency `yaml:"idempotency,omitempty"`
+	// Cache serves the route's GET and HEAD requests from the HTTP response
+	// cache.
+	Cache *RouteCache `yaml:"cache,omitempty"`
+}
+
+// RouteCache enables the response cache on a route. Responses are cached
+// for the freshness the upstream gives them with Cache-Control s-maxage or
+// max-age, or Expires; responses marked no-store or private, setting
+// cookies or varying on "*" are not cached.
+type RouteCache struct {
+	// TTL overrides the freshness the upstream gives, and caches responses
+	// the upstream gives none.
+	TTL Duration `yaml:"ttl,omitempty"`
+	// Key is a text/template of the cache key, rendering .Method, .Host,
+	// .Path, .Query, .RawQuery, .Headers, .Params, .Subject and .Tenant
+	// (default "{{.Host}}{{.Path}}?{{.RawQuery}}"). Keys are scoped to the
+	// route and tenant.
+	Key string `yaml:"key,omitempty"`
+}
+
+// Idempotency deduplicates requests by their Idempotency-Key header. The
+// first response to a key is kept for Window and replayed to later requests
+// with the key; a request reusing a key with another body is answered with
+// 422, and one arriving while the first is in flight with 409. Keys are
+// scoped to the route, method, path, caller and tenant.
+type Idempotency struct {
+	// Header names the key header (default "Idempotency-Key").
+	Header string `yaml:"header,omitempty"`
+	// Window is how long a response is replayed (default 24h).
+	Window Duration `yaml:"window,omitempty"`
+	// Methods are the deduplicated methods (default POST and PATCH).
+	Methods []string `yaml:"methods,omitempty"`
+	// MaxEntries bounds the kept responses (default 10000); the least
+	// recently used are evicted first.
+	MaxEntries int `yaml:"max_entries,omitempty"`
+	// MaxResponse is the largest response body kept (default 1MiB); larger
+	// responses are not replayed.
+	MaxResponse Size `yaml:"max_response,omitempty"`
+}
+
+// RouteSchedule activates a route from Start until End and, when Windows
+// are set, only within one of them.
+type RouteSchedule struct {
+	// Start and End are RFC 3339 timestamps; either may be empty.
+	Start string `yaml:"start,omitempty"`
+	End   string `yaml:"end,omitempty"`
+	// Timezone is the IANA time zone of the windows (default UTC).
+	Timezone string           `yaml:"timezone,omitempty"`
+	Windows  []ScheduleWindow `yaml:"windows,omitempty"`
+}
+
+// ScheduleWindow is a daily window from From until To, both "HH:MM". A
+// window ending before it starts runs past midnight and belongs to the day
+// it starts on.
+type ScheduleWindow struct {
+	// Days are "mon" to "sun" or ranges such as "mon-fri"; every day when
+	// empty.
+	Days []string `yaml:"days,omitempty"`
+	From string   `yaml:"from"`
+	To   string   `yaml:"to"`
+}
+
+// PathNormalization controls how request paths are normalized before routes
+// are matched.
+type PathNormalization struct {
+	// MergeSlashes collapses runs of slashes, so "//api///users" matches
+	// and is forwarded as "/api/users".
+	MergeSlashes bool `yaml:"merge_slashes,omitempty"`
+	// StripTrailingSlash makes exact paths and templates match with or
+	// without trailing slashes. Prefixes are unaffected.
+	StripTrailingSlash bool `yaml:"strip_trailing_slash,omitempty"`
+	// CaseInsensitive matches paths regardless of case. The forwarded path
+	// keeps its case.
+	CaseInsensitive bool `yaml:"case_insensitive,omitempty"`
+}
+
+// RouteDefaults are settings every V2 route inherits unless it sets its own.
+type RouteDefaults struct {
+	// Timeout applies to routes without upstream.timeout or timeout_ms.
+	Timeout Duration `yaml:"timeout,omitempty"`
+	// Filters apply to routes that list no filters.
+	Filters []RouteFilter `yaml:"filters,omitempty"`
 }
 
 // RouteMatch defines request matching criteria.
 type RouteMatch struct {
-	Methods    []string      `yaml:"methods,omitempty"`
-	Path       string        `yaml:"path,omitempty"`
-	PathPrefix string        `yaml:"path_prefix,omitempty"`
-	Headers    []HeaderMatch `yaml:"headers,omitempty"`
+	// Host and Hosts restrict the route to requests for these hosts. A
+	// leading "*." matches any subdomain, e.g. "*.example.com". Routes
+	// without a host match any host.
+	Host    string   `yaml:"host,omitempty"`
+	Hosts   []string `yaml:"hosts,omitempty"`
+	Methods []string `yaml:"methods,omitempty"`
+	// StripTrailingSlash and CaseInsensitive override the options of the
+	// same name in path_normalization for this route.
+	StripTrailingSlash *bool `yaml:"strip_trailing_slash,omitempty"`
+	CaseInsensitive    *bool `yaml:"case_insensitive,omitempty"`
+	// Path and PathPrefix may be templates such as "/users/{id}", whose
+	// parameters filters can refer to as {id}.
+	Path       string `yaml:"path,omitempty"`
+	PathPrefix string `yaml:"path_prefix,omitempty"`
+	// PathRegex is a regular expression the whole path must match. Its named
+	// groups, e.g. (?P<id>[0-9]+), become path parameters.
+	PathRegex string        `yaml:"path_regex,omitempty"`
+	Headers   []HeaderMatch `yaml:"headers,omitempty"`
+	// Query matches query parameters, e.g. to send ?version=beta to another
+	// cluster.
+	Query []QueryMatch `yaml:"query,omitempty"`
+	// Cookies match request cookies, e.g. the absence of a session cookie.
+	Cookies []CookieMatch `yaml:"cookies,omitempty"`
+	// TLS matches the TLS connection terminated by server.tls.
+	TLS *TLSMatch `yaml:"tls,omitempty"`
+	// GraphQL matches the operation of a GraphQL request, so that routes on
+	// the same path can send mutations to a primary cluster and queries to
+	// replicas.
+	GraphQL *GraphQLMatch `yaml:"graphql,omitempty"`
+}
+
+// TLSMatch matches requests by the TLS connection they arrived on. Each
+// list set must contain a value of the connection; requests received
+// without TLS do not match.
+type TLSMatch struct {
+	// SNI lists the server names the client may have sent, exact or
+	// "*.example.com" wildcards, regardless of case.
+	SNI []string `yaml:"sni,omitempty"`
+	// ClientCert matches the verified client certificate; requests without
+	// one do not match.
+	ClientCert *ClientCertMatch `yaml:"client_cert,omitempty"`
+}
+
+// ClientCertMatch matches attributes of a verified client certificate.
+type ClientCertMatch struct {
+	CommonNames         []string `yaml:"common_names,omitempty"`
+	OrganizationalUnits []string `yaml:"organizational_units,omitempty"`
+	// SANs lists subject alternative names: DNS names, email addresses,
+	// URIs such as SPIFFE IDs, or IP addresses.
+	SANs []string `yaml:"sans,omitempty"`
 }
 
-// HeaderMatch defines a header matching rule.
+// GraphQLMatch matches GraphQL requests by their operation. Routes with a
+// GraphQL match are tried in configuration order before the other routes
+// on the same path.
+type GraphQLMatch struct {
+	// OperationTypes lists the matched operation types: "query",
+	// "mutation" or "subscription". WebSocket upgrades match
+	// "subscription". Empty matches any type.
+	OperationTypes []string `yaml:"operation_types,omitempty"`
+	// OperationNames lists the matched operation names. Empty matches any
+	// name.
+	OperationNames []string `yaml:"operation_names,omitempty"`
+}
+
+// HeaderMatch defines a header matching rule on the first value of the
+// header.
 type HeaderMatch struct {
 	Name     string `yaml:"name"`
 	Exact    string `yaml:"exact,omitempty"`
 	Contains string `yaml:"contains,omitempty"`
+	// Regex is a regular expression the whole value must match.
+	Regex string `yaml:"regex,omitempty"`
+	// Present requires the header to be present when true, and absent when
+	// false.
+	Present *bool `yaml:"present,omitempty"`
+	// Invert matches the requests the rest of the rule does not.
+	Invert bool `yaml:"invert,omitempty"`
+}
+
+// CookieMatch defines a cookie matching rule. With neither Exact, Regex nor
+// Present set, the cookie only has to be present.
+type CookieMatch struct {
+	Name  string `yaml:"name"`
+	Exact string `yaml:"exact,omitempty"`
+	// Regex is a regular expression the whole value must match.
+	Regex string `yaml:"regex,omitempty"`
+	// Present requires the cookie to be present when true, and absent when
+	// false.
+	Present *bool `yaml:"present,omitempty"`
+	// Invert matches the requests the rest of the rule does not.
+	Invert bool `yaml:"invert,omitempty"`
+}
+
+// QueryMatch defines a query parameter matching rule. With neither Exact
+// nor Regex set, the parameter only has to be present. A parameter given
+// several times matches when any of its values does.
+type QueryMatch struct {
+	Name  string `yaml:"name"`
+	Exact string `yaml:"exact,omitempty"`
+	// Regex is a regular expression the whole value must match.
+	Regex string `yaml:"regex,omitempty"`
 }
 
 // RouteFilter defines a filter in the route pipeline.
 type RouteFilter struct {
-	Type string            `yaml:"type"` // "strip_prefix", "header_set"
-	Args map[string]string `yaml:"args,omitempty"`
+	Type string     `yaml:"type,omitempty"` // "strip_prefix", "header_set"
+	Args FilterArgs `yaml:"args,omitempty"`
+	// Chain includes the filters of the named filter chain in place of
+	// this entry; Type and Args are then not set.
+	Chain string `yaml:"chain,omitempty"`
 }
 
 // RouteUpstream defines the upstream destination for a route.
 type RouteUpstream struct {
-	Cluster   string                `yaml:"cluster"`
+	Cluster string `yaml:"cluster"`
+	// Clusters splits the route's traffic between several clusters by
+	// weight, e.g. 90/10 for a canary release, instead of sending it all to
+	// Cluster.
+	Clusters []WeightedCluster `yaml:"clusters,omitempty"`
+	// Sticky keeps each user on the same one of Clusters; without it every
+	// request picks a cluster at random.
+	Sticky *StickySplit `yaml:"sticky,omitempty"`
+	// BlueGreen serves the route from one of two clusters, switched over
+	// through the admin API, instead of from Cluster.
+	BlueGreen *BlueGreen `yaml:"blue_green,omitempty"`
+	// Mirror copies a share of the route's requests to a shadow cluster.
+	Mirror    *RouteMirror          `yaml:"mirror,omitempty"`
 	TimeoutMs int                   `yaml:"timeout_ms,omitempty"`
+	Timeout   Duration              `yaml:"timeout,omitempty"` // e.g. "30s", instead of timeout_ms
 	GRPC      *RouteUpstreamGRPC    `yaml:"grpc,omitempty"`
 	Dubbo     *RouteUpstreamDubbo   `yaml:"dubbo,omitempty"`
 	GraphQL   *RouteUpstreamGraphQL `yaml:"graphql,omitempty"`
+	Mock      *RouteUpstreamMock    `yaml:"mock,omitempty"`
+}
+
+// WeightedCluster is one cluster of a traffic split, receiving Weight out
+// of the summed weights of the split.
+type WeightedCluster struct {
+	Name   string `yaml:"name"`
+	Weight int    `yaml:"weight"`
+}
+
+// StickySplit assigns users to the clusters of a split by hashing a value
+// of their requests, so a user keeps seeing the same variant. With neither
+// Header nor Cookie set the authenticated subject is hashed. Requests
+// without the value are assigned at random.
+type StickySplit struct {
+	Header string `yaml:"header,omitempty"`
+	Cookie string `yaml:"cookie,omitempty"`
+}
+
+// RouteMirror sends copies of a route's requests to a shadow cluster, e.g.
+// to try a new version of a service with production traffic. The copies
+// leave in the background once the route filters ran, and their responses
+// are discarded, so the shadow cluster never affects clients.
+type RouteMirror struct {
+	// Cluster is the shadow cluster, an HTTP cluster.
+	Cluster string `yaml:"cluster"`
+	// Percent is the share of requests mirrored, above 0 and up to 100.
+	Percent float64 `yaml:"percent"`
+	// MaxBody is the largest request body copied (default 1MiB); requests
+	// with larger bodies are not mirrored.
+	MaxBody Size `yaml:"max_body,omitempty"`
+}
+
+// BlueGreen names the two clusters of a blue/green deployment. Switching
+// the route to the other cluster starts a bake window in which a spike of
+// server errors switches it back.
+type BlueGreen struct {
+	Blue  string `yaml:"blue"`
+	Green string `yaml:"green"`
+	// Active is "blue" (the default) or "green".
+	Active string `yaml:"active,omitempty"`
+	// Bake is how long the error rate is watched after a switch (default 5m).
+	Bake Duration `yaml:"bake,omitempty"`
+	// MaxErrorRate is the share of 5xx responses, from 0 to 1, above which
+	// a switch is rolled back (default 0.05).
+	MaxErrorRate float64 `yaml:"max_error_rate,omitempty"`
+	// MinRequests is the number of responses the error rate needs before it
+	// is judged (default 20).
+	MinRequests int `yaml:"min_requests,omitempty"`
+}
+
+// ActiveCluster returns the cluster serving the route.
+func (b *BlueGreen) ActiveCluster() string {
+	if b.Active == "green" {
+		return b.Green
+	}
+	return b.Blue
+}
+
+// ActiveCluster returns the cluster serving the upstream: Cluster, or the
+// active cluster of BlueGreen. It is empty for split upstreams.
+func (u *RouteUpstream) ActiveCluster() string {
+	if u.BlueGreen != nil {
+		return u.BlueGreen.ActiveCluster()
+	}
+	return u.Cluster
+}
+
+// ClusterNames returns the clusters the upstream may send traffic to.
+func (u *RouteUpstream) ClusterNames() []string {
+	if u.BlueGreen != nil {
+		return []string{u.BlueGreen.Blue, u.BlueGreen.Green}
+	}
+	if len(u.Clusters) == 0 {
+		if u.Cluster == "" {
+			return nil
+		}
+		return []string{u.Cluster}
+	}
+	names := make([]string, len(u.Clusters))
+	for i, c := range u.Clusters {
+		names[i] = c.Name
+	}
+	return names
+}
+
+// RouteUpstreamMock answers a route with a rendered response instead of
+// forwarding it, for routes whose backend does not exist yet. Body and the
+// header values are Go templates with access to the request: .Method,
+// .Path, .Query, .Headers, .Params (path parameters), .Body (raw) and .JSON
+// (the decoded JSON body; an empty object when the body is not JSON).
+type RouteUpstreamMock struct {
+	// Status defaults to 200.
+	Status  int               `yaml:"status,omitempty"`
+	Headers map[string]string `yaml:"headers,omitempty"`
+	Body    string            `yaml:"body,omitempty"`
 }
 
 // RouteUpstreamGRPC defines gRPC-specific upstream settings for a route.
 type RouteUpstreamGRPC struct {
-	Service  string         `yaml:"service"`
-	Method   string         `yaml:"method"`
+	// Service and Method name the RPC the route calls. Both are required
+	// unless Passthrough is set.
+	Service string `yaml:"service,omitempty"`
+	Method  string `yaml:"method,omitempty"`
+	// Passthrough forwards /pkg.Service/Method request paths to the cluster
+	// unchanged, so one route can serve every method of a backend.
+	Passthrough bool `yaml:"passthrough,omitempty"`
+	// Allow restricts passthrough to these services ("pkg.Service") and
+	// methods ("pkg.Service/Method"). Empty allows every method.
+	Allow    []string       `yaml:"allow,omitempty"`
 	Request  *TranscodeMode `yaml:"request,omitempty"`
 	Response *TranscodeMode `yaml:"response,omitempty"`
+	// Metadata restricts which inbound headers are forwarded as gRPC metadata.
+	// When unset, all inbound headers are forwarded.
+	Metadata *GRPCMetadata `yaml:"metadata,omitempty"`
+}
+
+// GRPCMetadata configures inbound header propagation to a gRPC upstream.
+// Deadline (grpc-timeout) and trace context headers are always forwarded.
+type GRPCMetadata struct {
+	// Allow lists the inbound header names forwarded as metadata (case-insensitive).
+	Allow []string `yaml:"allow,omitempty"`
 }
 
 // RouteUpstreamDubbo defines Dubbo-specific upstream settings for a route.
 type RouteUpstreamDubbo struct {
-	Interface  string         `yaml:"interface"`
-	Method     string         `yaml:"method"`
-	ParamTypes []string       `yaml:"param_types,omitempty"`
-	Request    *TranscodeMode `yaml:"request,omitempty"`
-	Response   *TranscodeMode `yaml:"response,omitempty"`
+	Interface string `yaml:"interface"`
+	Method    string `yaml:"method"`
+	// Group and Version select the provider service, overriding the
+	// cluster's settings so one cluster can serve several service versions.
+	Group      string   `yaml:"group,omitempty"`
+	Version    string   `yaml:"version,omitempty"`
+	ParamTypes []string `yaml:"param_types,omitempty"`
+	// Params maps request inputs to the method's positional arguments and
+	// declares their types. It replaces ParamTypes, which sends the JSON body
+	// as the arguments.
+	Params []DubboParam `yaml:"params,omitempty"`
+	// PathTemplate names request path segments for "path.<name>" params, e.g.
+	// "/api/v1/orders/{id}". Each "{name}" matches exactly one segment.
+	PathTemplate string `yaml:"path_template,omitempty"`
+	// Generic calls the method through GenericService.$invoke, so the
+	// provider's classes need not be known to the gateway: arguments are sent
+	// as plain maps and lists and realized into the parameter types by the
+	// provider. Requires a native dubbo or triple cluster with hessian2
+	// serialization.
+	Generic  bool           `yaml:"generic,omitempty"`
+	Request  *TranscodeMode `yaml:"request,omitempty"`
+	Response *TranscodeMode `yaml:"response,omitempty"`
+	// Attachments maps HTTP headers to invocation attachments and response
+	// attachments back to headers, for native dubbo and triple clusters.
+	Attachments *DubboAttachments `yaml:"attachments,omitempty"`
+	// Exceptions maps exceptions thrown by the provider to HTTP statuses.
+	Exceptions *DubboExceptions `yaml:"exceptions,omitempty"`
+}
+
+// DubboExceptions translates provider exceptions into HTTP error responses.
+// RpcExceptions without a mapping get a status derived from their code, e.g.
+// 504 for timeouts and 429 when a limit was exceeded.
+type DubboExceptions struct {
+	// Status is used for exceptions without a mapping (default: 500).
+	Status int `yaml:"status,omitempty"`
+	// Mappings are checked in order; the first match wins.
+	Mappings []DubboExceptionMapping `yaml:"mappings,omitempty"`
+}
+
+// DubboExceptionMapping maps an exception class to an HTTP status.
+type DubboExceptionMapping struct {
+	// Exception is a fully qualified class name, or a package followed by
+	// ".*" to match every class in it and its subpackages. Classes match by
+	// name only; subclasses need their own mapping.
+	Exception string `yaml:"exception"`
+	Status    int    `yaml:"status"`
+}
+
+// DubboParam declares one argument of a Dubbo method and where its value
+// comes from.
+type DubboParam struct {
+	// Type is the Java parameter type, e.g. "long" or "com.foo.CreateOrder".
+	Type string `yaml:"type"`
+	// From is "path.<name>", "query.<name>", "header.<name>", "body" for the
+	// whole JSON body, or "body.<field>" for a field of it, with nested fields
+	// separated by dots. Query params of array types take every value.
+	From string `yaml:"from"`
+}
+
+// DubboAttachments configures the exchange of Dubbo attachments with HTTP
+// headers.
+type DubboAttachments struct {
+	// Request lists inbound headers copied into the invocation attachments.
+	Request []DubboAttachmentMapping `yaml:"request,omitempty"`
+	// Response lists response attachments copied into response headers.
+	Response []DubboAttachmentMapping `yaml:"response,omitempty"`
+}
+
+// DubboAttachmentMapping pairs an HTTP header with a Dubbo attachment key.
+// Either side defaults to the other; a defaulted attachment key is the
+// lower-cased header name.
+type DubboAttachmentMapping struct {
+	Header     string `yaml:"header,omitempty"`
+	Attachment string `yaml:"attachment,omitempty"`
 }
 
 // RouteUpstreamGraphQL defines GraphQL-specific upstream settings for a route.
 type RouteUpstreamGraphQL struct {
 	// Endpoint is the path on the upstream that serves GraphQL (default: "/graphql").
 	Endpoint string `yaml:"endpoint,omitempty"`
+	// GetAsPost forwards GET requests to the upstream as POST requests with
+	// a JSON body, for servers that only accept POST.
+	GetAsPost bool `yaml:"get_as_post,omitempty"`
+	// BlockIntrospection rejects operations that query the schema through
+	// __schema or __type. __typename stays allowed.
+	BlockIntrospection bool `yaml:"block_introspection,omitempty"`
+	// Allowlist restricts execution to known operations. Nil allows any.
+	Allowlist *GraphQLAllowlist `yaml:"allowlist,omitempty"`
+	// PersistedQueries enables Automatic Persisted Queries: the gateway
+	// remembers query text by its SHA-256 hash and resolves hash-only
+	// requests before forwarding them.
+	PersistedQueries *GraphQLPersistedQueries `yaml:"persisted_queries,omitempty"`
+	// Cache caches the responses to query operations. Nil disables caching.
+	// Cache hits on GET requests tell HTTP caches to keep the response for
+	// the rest of the TTL, unless the upstream set its own Cache-Control.
+	Cache *GraphQLCache `yaml:"cache,omitempty"`
+	// Metrics counts the route's operations, response errors and selected
+	// fields, served in the Prometheus text format on the admin /metrics
+	// endpoint. Nil disables them.
+	Metrics *GraphQLMetrics `yaml:"metrics,omitempty"`
+	// Subscriptions proxies WebSocket connections using the
+	// graphql-transport-ws or legacy graphql-ws subprotocol to the upstream
+	// endpoint. Nil rejects WebSocket upgrades.
+	Subscriptions *GraphQLSubscriptions `yaml:"subscriptions,omitempty"`
+	// Federation fans operations out to the subgraph clusters of a
+	// supergraph instead of proxying them to the route's cluster.
+	Federation *GraphQLFederation `yaml:"federation,omitempty"`
+}
+
+// GraphQLSubscriptions configures GraphQL subscriptions over WebSocket.
+type GraphQLSubscriptions struct {
+	// MaxConnections bounds the open subscription connections of the route;
+	// 0 means unlimited. Upgrades beyond the limit are answered with 503.
+	MaxConnections int `yaml:"max_connections,omitempty"`
+	// AuthenticateInit checks the connection_init payload against the
+	// gateway API keys (auth.api_key) before the connection reaches the
+	// upstream. String fields of the payload are read as request headers,
+	// so {"X-API-Key": "..."} carries the key.
+	AuthenticateInit bool `yaml:"authenticate_init,omitempty"`
+	// InitTimeoutMs is how long a client has to send connection_init when
+	// AuthenticateInit is set (default 10000).
+	InitTimeoutMs int      `yaml:"init_timeout_ms,omitempty"`
+	InitTimeout   Duration `yaml:"init_timeout,omitempty"`
+}
+
+// GraphQLFederation configures a federated GraphQL route. The root fields of
+// an operation are fetched from the subgraphs the supergraph schema assigns
+// them to and the results merged into one response. Nested fields are
+// resolved by the subgraph owning their root field.
+type GraphQLFederation struct {
+	// Supergraph is the path of the supergraph schema, composed with the
+	// join spec (join__Graph, @join__type, @join__field).
+	Supergraph string `yaml:"supergraph"`
+	// Subgraphs maps every subgraph of the supergraph to a cluster.
+	Subgraphs []GraphQLSubgraph `yaml:"subgraphs"`
+}
+
+// GraphQLSubgraph maps a subgraph of a supergraph to the cluster serving it.
+type GraphQLSubgraph struct {
+	// Name is the subgraph name given by @join__graph(name: ...).
+	Name    string `yaml:"name"`
+	Cluster string `yaml:"cluster"`
+	// Endpoint is the path on the cluster that serves GraphQL (default: "/graphql").
+	Endpoint string `yaml:"endpoint,omitempty"`
+}
+
+// GraphQLCache configures the response cache of a GraphQL route. Entries are
+// keyed by the query text, operation name and variables; only successful
+// responses without GraphQL errors are stored.
+type GraphQLCache struct {
+	// TTLMs is how long a response is served from the cache.
+	TTLMs int      `yaml:"ttl_ms,omitempty"`
+	TTL   Duration `yaml:"ttl,omitempty"`
+	// MaxEntries bounds the cached responses (default 1000); the least
+	// recently used are evicted first.
+	MaxEntries int `yaml:"max_entries,omitempty"`
+	// MaxResponseBytes is the largest response body cached (default 1 MiB).
+	MaxResponseBytes int64 `yaml:"max_response_bytes,omitempty"`
+	MaxResponse      Size  `yaml:"max_response,omitempty"`
+	// VaryOnSubject keys entries by the authenticated subject, so responses
+	// are never shared between callers. Requests without an identity then
+	// bypass the cache.
+	VaryOnSubject bool `yaml:"vary_on_subject,omitempty"`
+	// VaryHeaders are request headers whose values are part of the key,
+	// e.g. "Accept-Language".
+	VaryHeaders []string `yaml:"vary_headers,omitempty"`
+}
+
+// GraphQLMetrics configures the metrics of a GraphQL route.
+type GraphQLMetrics struct {
+	// FieldSampleRate is the fraction of operations, between 0 and 1, whose
+	// selected fields are counted. 0 disables field usage metrics.
+	FieldSampleRate float64 `yaml:"field_sample_rate,omitempty"`
+}
+
+// GraphQLPersistedQueries configures Automatic Persisted Queries on a route.
+type GraphQLPersistedQueries struct {
+	// ManifestOnly resolves only hashes registered through the admin
+	// manifest and stops learning queries from clients.
+	ManifestOnly bool `yaml:"manifest_only,omitempty"`
+}
+
+// GraphQLAllowlist lists the operations a GraphQL route executes. A request
+// is allowed when its operation name or its query hash is listed.
+type GraphQLAllowlist struct {
+	// Operations are operation names. Names are chosen by clients, so they
+	// only guard against accidental use; use Hashes to pin the query text.
+	Operations []string `yaml:"operations,omitempty"`
+	// Hashes are hex SHA-256 digests of query documents, as used by
+	// persisted queries.
+	Hashes []string `yaml:"hashes,omitempty"`
 }
 
 // TranscodeMode defines transcoding settings.
@@ -0,0 +1,220 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// RouteConflicts reports routes that overlap in a way the configuration
+// probably does not intend. A route is shadowed when an earlier route in
+// matching order matches every request it matches, so it never serves any;
+// two routes are ambiguous when they match the same requests with the same
+// priority, leaving the choice to configuration order. Conflicts do not
+// fail validation, since the matching order is still deterministic.
+func RouteConflicts(cfg *Config) []string {
+	if cfg == nil {
+		return nil
+	}
+	var out []string
+	out = append(out, legacyConflicts(cfg.Routes)...)
+	return append(out, v2Conflicts(cfg.RoutesV2)...)
+}
+
+// legacyPath is a path rule of a legacy route, as the router indexes it.
+type legacyPath struct {
+	route *Route
+	index int // in the routes list
+	path  int // in route.Paths
+	rule  PathRule
+}
+
+func legacyConflicts(routes []Route) []string {
+	var paths []legacyPath
+	for i := range routes {
+		for j, p := range routes[i].Paths {
+			paths = append(paths, legacyPath{route: &routes[i], index: i, path: j, rule: p})
+		}
+	}
+	var out []string
+	for _, b := range paths {
+		for _, a := range paths {
+			if a.index == b.index || !legacyCovers(&a, &b) {
+				continue
+			}
+			name := fmt.Sprintf("route %q paths[%d]", b.route.Name, b.path)
+			if a.route.Host == b.route.Host && a.rule == b.rule && a.route.Priority == b.route.Priority {
+				out = append(out, fmt.Sprintf("%s: matches the same requests as route %q with the same priority; %q wins by configuration order",
+					name, a.route.Name, a.route.Name))
+			} else {
+				out = append(out, fmt.Sprintf("%s: never matches, shadowed by route %q", name, a.route.Name))
+			}
+			break
+		}
+	}
+	return out
+}
+
+// legacyCovers reports whether the router tries a before b and a matches
+// every request b matches.
+func legacyCovers(a, b *legacyPath) bool {
+	ka, kb := legacyKind(&a.rule), legacyKind(&b.rule)
+	if ka != kb {
+		return false
+	}
+	pa, pb := a.route.Priority, b.route.Priority
+	switch ka {
+	case "exact":
+		// Exact paths are looked up for the request host, then without a
+		// host, and the first route of the highest priority is kept.
+		if a.route.Host != b.route.Host || a.rule.Path != b.rule.Path {
+			return false
+		}
+		return pa > pb || pa == pb && a.index < b.index
+	case "pattern":
+		if a.route.Host != "" && a.route.Host != b.route.Host || a.rule != b.rule {
+			return false
+		}
+		return pa > pb || pa == pb && a.index < b.index
+	default:
+		if a.route.Host != "" && a.route.Host != b.route.Host || !strings.HasPrefix(b.rule.Path, a.rule.Path) {
+			return false
+		}
+		if pa != pb {
+			return pa > pb
+		}
+		return len(a.rule.Path) > len(b.rule.Path) || a.rule.Path == b.rule.Path && a.index < b.index
+	}
+}
+
+func legacyKind(p *PathRule) string {
+	if p.Type == "regex" || strings.Contains(p.Path, "{") {
+		return "pattern"
+	}
+	return p.Type
+}
+
+// v2Path is the path criterion of a V2 route, as the router index sees it.
+type v2Path struct {
+	kind string // "exact", "pattern" or "prefix"; empty when not analyzed
+	path string // the exact path, the template or regex, or the prefix
+}
+
+func v2PathOf(m *RouteMatch) v2Path {
+	switch {
+	case m.PathRegex != "":
+		return v2Path{"pattern", "regex:" + m.PathRegex}
+	case strings.Contains(m.Path, "{") || strings.Contains(m.PathPrefix, "{"):
+		return v2Path{"pattern", m.Path + "|" + m.PathPrefix}
+	case m.Path != "" && m.PathPrefix != "":
+		// Indexed both ways; too unusual to be worth analyzing
+		return v2Path{}
+	case m.Path != "":
+		return v2Path{"exact", m.Path}
+	case m.PathPrefix != "":
+		return v2Path{"prefix", m.PathPrefix}
+	}
+	return v2Path{"prefix", "/"}
+}
+
+func v2Conflicts(routes []RouteV2) []string {
+	var out []string
+	for j := range routes {
+		b := &routes[j]
+		for i := range routes {
+			a := &routes[i]
+			if i == j || !v2Covers(a, i, b, j) {
+				continue
+			}
+			if a.Priority == b.Priority && reflect.DeepEqual(a.Match, b.Match) {
+				out = append(out, fmt.Sprintf("route_v2 %q: matches the same requests as route_v2 %q with the same priority; %q wins by configuration order",
+					b.Name, a.Name, a.Name))
+			} else {
+				out = append(out, fmt.Sprintf("route_v2 %q: never matches, shadowed by route_v2 %q", b.Name, a.Name))
+			}
+			break
+		}
+	}
+	return out
+}
+
+// v2Covers reports whether the router index tries a, the i-th route, before
+// b, the j-th, and a matches every request b matches.
+func v2Covers(a *RouteV2, i int, b *RouteV2, j int) bool {
+	ma, mb := &a.Match, &b.Match
+	pa, pb := v2PathOf(ma), v2PathOf(mb)
+	if pa.kind == "" || pa.kind != pb.kind {
+		return false
+	}
+	// Routes are indexed per host; a has to share every host of b
+	ha, hb := ma.HostNames(), mb.HostNames()
+	if (len(ha) == 0) != (len(hb) == 0) || !containsAll(hb, ha) {
+		return false
+	}
+	// Exact routes with methods are looked up before those without
+	if pa.kind == "exact" && (len(ma.Methods) == 0) != (len(mb.Methods) == 0) {
+		return false
+	}
+	if len(ma.Methods) > 0 && (len(mb.Methods) == 0 || !containsAll(mb.Methods, ma.Methods)) {
+		return false
+	}
+	if !subset(ma.Headers, mb.Headers) || !subset(ma.Query, mb.Query) || !subset(ma.Cookies, mb.Cookies) {
+		return false
+	}
+	if ma.GraphQL != nil && !reflect.DeepEqual(ma.GraphQL, mb.GraphQL) {
+		return false
+	}
+	if ma.TLS != nil && !reflect.DeepEqual(ma.TLS, mb.TLS) {
+		return false
+	}
+	if pa.kind == "prefix" {
+		if !strings.HasPrefix(pb.path, pa.path) {
+			return false
+		}
+	} else if pa.path != pb.path {
+		return false
+	}
+
+	if a.Priority != b.Priority {
+		return a.Priority > b.Priority
+	}
+	if pa.kind == "prefix" && len(pa.path) != len(pb.path) {
+		return false
+	}
+	// Routes matching GraphQL operations go first on the same path
+	if (ma.GraphQL != nil) != (mb.GraphQL != nil) {
+		return ma.GraphQL != nil
+	}
+	return i < j
+}
+
+// containsAll reports whether b holds every string of a.
+func containsAll(a, b []string) bool {
+	set := make(map[string]bool, len(b))
+	for _, s := range b {
+		set[s] = true
+	}
+	for _, s := range a {
+		if !set[s] {
+			return false
+		}
+	}
+	return true
+}
+
+// subset reports whether every rule of a is also a rule of b.
+func subset[T any](a, b []T) bool {
+	for _, x := range a {
+		found := false
+		for _, y := range b {
+			if reflect.DeepEqual(x, y) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,63 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRouteConflicts_V2(t *testing.T) {
+	up := RouteUpstream{Cluster: "c"}
+	cfg := &Config{RoutesV2: []RouteV2{
+		{Name: "api", Match: RouteMatch{PathPrefix: "/api"}, Upstream: up},
+		{Name: "api-copy", Match: RouteMatch{PathPrefix: "/api"}, Upstream: up},
+		// Shadowed: /api has a higher priority than the longer prefix.
+		{Name: "boosted", Match: RouteMatch{PathPrefix: "/v1"}, Upstream: up, Priority: 10},
+		{Name: "v1-users", Match: RouteMatch{PathPrefix: "/v1/users"}, Upstream: up},
+		// Not shadowed: the longer prefix wins at equal priority.
+		{Name: "api-users", Match: RouteMatch{PathPrefix: "/api/users"}, Upstream: up},
+		// Not shadowed: the later route narrows the match by header.
+		{Name: "api-beta", Match: RouteMatch{PathPrefix: "/api", Headers: []HeaderMatch{{Name: "x-beta", Exact: "1"}}}, Upstream: up, Priority: 1},
+		// Shadowed: the earlier route matches any method.
+		{Name: "health", Match: RouteMatch{Path: "/health", Methods: []string{"GET", "HEAD"}}, Upstream: up},
+		{Name: "health-get", Match: RouteMatch{Path: "/health", Methods: []string{"GET"}}, Upstream: up},
+		// Not shadowed: routes on other hosts do not compete.
+		{Name: "shop", Match: RouteMatch{Host: "shop.example.com", PathPrefix: "/api"}, Upstream: up},
+		// Not shadowed: a higher priority reorders the routes.
+		{Name: "health-head", Match: RouteMatch{Path: "/health", Methods: []string{"HEAD"}}, Upstream: up, Priority: 5},
+		// Rules are compared by value, not by the address of present.
+		{Name: "login", Match: RouteMatch{Path: "/login", Cookies: []CookieMatch{{Name: "session", Present: new(bool)}}}, Upstream: up},
+		{Name: "login-copy", Match: RouteMatch{Path: "/login", Cookies: []CookieMatch{{Name: "session", Present: new(bool)}}}, Upstream: up},
+	}}
+
+	want := []string{
+		`route_v2 "api-copy": matches the same requests as route_v2 "api" with the same priority; "api" wins by configuration order`,
+		`route_v2 "v1-users": never matches, shadowed by route_v2 "boosted"`,
+		`route_v2 "health-get": never matches, shadowed by route_v2 "health"`,
+		`route_v2 "login-copy": matches the same requests as route_v2 "login" with the same priority; "login" wins by configuration order`,
+	}
+	if got := RouteConflicts(cfg); !reflect.DeepEqual(got, want) {
+		t.Errorf("RouteConflicts() =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestRouteConflicts_Legacy(t *testing.T) {
+	cfg := &Config{Routes: []Route{
+		{Name: "a", Paths: []PathRule{{Path: "/api", Type: "prefix"}}},
+		{Name: "b", Paths: []PathRule{{Path: "/health", Type: "exact"}, {Path: "/api", Type: "prefix"}}},
+		{Name: "c", Host: "example.com", Paths: []PathRule{{Path: "/api/v1", Type: "prefix"}}, Priority: -1},
+		{Name: "d", Host: "example.com", Paths: []PathRule{{Path: "/health", Type: "exact"}}},
+		{Name: "e", Paths: []PathRule{{Path: "/health", Type: "exact"}}, Priority: 1},
+	}}
+
+	want := []string{
+		`route "b" paths[0]: never matches, shadowed by route "e"`,
+		`route "b" paths[1]: matches the same requests as route "a" with the same priority; "a" wins by configuration order`,
+		`route "c" paths[0]: never matches, shadowed by route "a"`,
+	}
+	if got := RouteConflicts(cfg); !reflect.DeepEqual(got, want) {
+		t.Errorf("RouteConflicts() =\n%q\nwant\n%q", got, want)
+	}
+	if got := RouteConflicts(nil); got != nil {
+		t.Errorf("RouteConflicts(nil) = %v", got)
+	}
+}
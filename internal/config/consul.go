@@ -0,0 +1,99 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// consulWait is how long a blocking query waits for a change.
+const consulWait = "5m"
+
+// ConsulSource reads the configuration document from a Consul KV key and
+// watches it with blocking queries.
+type ConsulSource struct {
+	Client *http.Client
+	// Address is the Consul HTTP API, e.g. "http://consul:8500".
+	Address string
+	Key     string
+	// Query holds extra query parameters, such as dc.
+	Query url.Values
+	Token string
+}
+
+// Fetch returns the value of the key.
+func (s *ConsulSource) Fetch(ctx context.Context) ([]byte, error) {
+	data, _, err := s.get(ctx, 0)
+	return data, err
+}
+
+// Watch calls onChange whenever the key is modified.
+func (s *ConsulSource) Watch(ctx context.Context, onChange func([]byte)) {
+	var index uint64
+	for ctx.Err() == nil {
+		data, next, err := s.get(ctx, index)
+		if err != nil {
+			if ctx.Err() == nil {
+				slog.Error("config source watch failed", slog.String("source", s.String()), slog.String("error", err.Error()))
+				sleepCtx(ctx, sourceRetryDelay)
+			}
+			continue
+		}
+		switch {
+		case next < index:
+			// The index went backwards, e.g. after a snapshot restore:
+			// start over.
+			index = 0
+		case next > index:
+			onChange(data)
+			index = next
+		}
+	}
+}
+
+func (s *ConsulSource) String() string {
+	return "consul " + s.Address + "/" + s.Key
+}
+
+// get reads the key, blocking until its index exceeds index when index is
+// not zero. It returns the value and the current index.
+func (s *ConsulSource) get(ctx context.Context, index uint64) ([]byte, uint64, error) {
+	q := url.Values{}
+	for k, v := range s.Query {
+		q[k] = v
+	}
+	q.Set("raw", "")
+	if index > 0 {
+		q.Set("index", strconv.FormatUint(index, 10))
+		q.Set("wait", consulWait)
+	}
+	u := strings.TrimSuffix(s.Address, "/") + "/v1/kv/" + s.Key + "?" + q.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	if s.Token != "" {
+		req.Header.Set("X-Consul-Token", s.Token)
+	}
+	resp, err := clientOrDefault(s.Client).Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("consul: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, 0, fmt.Errorf("consul: key %s not found", s.Key)
+	}
+	data, err := readSourceBody(resp)
+	if err != nil {
+		return nil, 0, fmt.Errorf("consul: %w", err)
+	}
+	next, err := strconv.ParseUint(resp.Header.Get("X-Consul-Index"), 10, 64)
+	if err != nil {
+		return nil, 0, fmt.Errorf("consul: invalid X-Consul-Index %q", resp.Header.Get("X-Consul-Index"))
+	}
+	return data, next, nil
+}
@@ -0,0 +1,286 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// UsesV2 reports whether c has V2 routes the runtime serves: routes with
+// clusters to forward to, or mock routes, which need none.
+func (c *Config) UsesV2() bool {
+	if len(c.RoutesV2) == 0 {
+		return false
+	}
+	if len(c.Clusters) > 0 {
+		return true
+	}
+	for i := range c.RoutesV2 {
+		if c.RoutesV2[i].Upstream.Mock != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// RouteFilters returns the filters route r applies: its own, or
+// defaults.filters when it lists none, with filter chains expanded.
+func (c *Config) RouteFilters(r *RouteV2) []RouteFilter {
+	filters := r.Filters
+	if len(filters) == 0 && !r.NoDefaultFilters && c.Defaults != nil {
+		filters = c.Defaults.Filters
+	}
+	var out []RouteFilter
+	for _, f := range filters {
+		if f.Chain != "" {
+			out = append(out, c.FilterChains[f.Chain]...)
+			continue
+		}
+		out = append(out, f)
+	}
+	return out
+}
+
+// RouteTimeout returns the upstream timeout of route r, or defaults.timeout
+// when r sets none.
+func (c *Config) RouteTimeout(r *RouteV2) time.Duration {
+	if r.Upstream.Timeout == "" && r.Upstream.TimeoutMs == 0 && c.Defaults != nil {
+		return durationOr(c.Defaults.Timeout, 0)
+	}
+	return r.Upstream.TimeoutDuration()
+}
+
+// RouteNormalization returns the path normalization of route r:
+// path_normalization with the overrides of r applied.
+func (c *Config) RouteNormalization(r *RouteV2) PathNormalization {
+	var n PathNormalization
+	if c.PathNormalization != nil {
+		n = *c.PathNormalization
+	}
+	if v := r.Match.StripTrailingSlash; v != nil {
+		n.StripTrailingSlash = *v
+	}
+	if v := r.Match.CaseInsensitive; v != nil {
+		n.CaseInsensitive = *v
+	}
+	return n
+}
+
+// Response returns the status and JSON body answering unmatched requests
+// when f does not forward them to a cluster. The body is nil when f sets
+// none, for the gateway's JSON error response to be used.
+func (f *FallbackConfig) Response() (int, []byte) {
+	status := f.Status
+	if status == 0 {
+		status = http.StatusNotFound
+	}
+	if f.Body == "" {
+		return status, nil
+	}
+	return status, []byte(f.Body)
+}
+
+// validateDefaults validates the defaults section and the filter chains.
+func validateDefaults(cfg *Config) error {
+	names := make([]string, 0, len(cfg.FilterChains))
+	for name := range cfg.FilterChains {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if name == "" {
+			return errors.New("filter_chains: chain name is required")
+		}
+		chain := cfg.FilterChains[name]
+		for j, f := range chain {
+			if f.Chain != "" {
+				return fmt.Errorf("filter_chains.%s[%d]: filter chains cannot include other chains", name, j)
+			}
+		}
+		if err := validateFilters("filter_chains."+name, chain, nil); err != nil {
+			return err
+		}
+	}
+	d := cfg.Defaults
+	if d == nil {
+		return nil
+	}
+	if err := checkDuration("defaults.timeout", d.Timeout, 0); err != nil {
+		return err
+	}
+	return validateFilters("defaults.filters", d.Filters, cfg.FilterChains)
+}
+
+// validateFilters validates a filter list, named by owner in errors, e.g.
+// `route_v2 "users" filters`. Chain references must name one of chains.
+func validateFilters(owner string, filters []RouteFilter, chains map[string][]RouteFilter) error {
+	for j, f := range filters {
+		if f.Chain != "" {
+			if f.Type != "" || len(f.Args) > 0 {
+				return fmt.Errorf("%s[%d]: chain cannot be combined with type or args", owner, j)
+			}
+			if _, ok := chains[f.Chain]; !ok {
+				return fmt.Errorf("%s[%d]: unknown filter chain %q", owner, j, f.Chain)
+			}
+			continue
+		}
+		if f.Type == "" {
+			return fmt.Errorf("%s[%d].type is required", owner, j)
+		}
+		if err := validateFilterArgs(f); err != nil {
+			return fmt.Errorf("%s[%d] (%s): %w", owner, j, f.Type, err)
+		}
+	}
+	return nil
+}
+
+// validateFilterArgs checks the arguments of the built-in filter types.
+// Arguments the runtime does not know are reported when the filter is
+// compiled.
+func validateFilterArgs(f RouteFilter) error {
+	required := func(name string) error {
+		v, err := f.Args.String(name)
+		if err == nil && v == "" {
+			err = fmt.Errorf("'%s' argument is required", name)
+		}
+		return err
+	}
+	switch f.Type {
+	case "strip_prefix":
+		return required("prefix")
+	case "header_set", "response_header_add", "response_header_set", "response_header_remove":
+		return required("key")
+	case "rewrite_path":
+		return required("path")
+	case "regex_rewrite":
+		pattern, err := f.Args.String("pattern")
+		if err != nil {
+			return err
+		}
+		if err := validateRegexRewrite(pattern); err != nil {
+			return fmt.Errorf("'pattern' argument: %w", err)
+		}
+	case "max_body":
+		if n, err := f.Args.Size("size"); err != nil {
+			return err
+		} else if n == 0 {
+			return errors.New("'size' argument must be positive")
+		}
+	case "decompress":
+		_, err := f.Args.Size("max_size")
+		return err
+	case "compress":
+		encodings, err := f.Args.Strings("encodings")
+		if err != nil {
+			return err
+		}
+		for _, enc := range encodings {
+			if enc = strings.ToLower(enc); enc != "gzip" && enc != "deflate" {
+				return fmt.Errorf("unsupported encoding %q (use gzip or deflate)", enc)
+			}
+		}
+		if _, err := f.Args.Strings("types"); err != nil {
+			return err
+		}
+		if _, err := f.Args.Size("min_size"); err != nil {
+			return err
+		}
+		if n, err := f.Args.Int("level", 6); err != nil || n < 1 || n > 9 {
+			return errors.New("'level' argument must be between 1 and 9")
+		}
+	case "bandwidth_limit":
+		if n, err := f.Args.Size("rate"); err != nil {
+			return err
+		} else if n == 0 {
+			return errors.New("'rate' argument must be positive")
+		}
+		if _, err := f.Args.Size("burst"); err != nil {
+			return err
+		}
+		if p, err := f.Args.String("per"); err != nil || p != "" && p != "route" && p != "consumer" && p != "request" {
+			return errors.New("'per' must be route, consumer or request")
+		}
+	case "csrf":
+		_, err := f.Args.Bool("secure", true)
+		return err
+	case "ip_acl":
+		return validateIPACLArgs(f.Args)
+	case "sign_request":
+		return required("key")
+	case "body_transform":
+		if err := required("template"); err != nil {
+			return err
+		}
+		if p, err := f.Args.String("phase"); err != nil || p != "" && p != "request" && p != "response" {
+			return errors.New("'phase' must be request or response")
+		}
+	case "response_status":
+		if !validStatusArg(f.Args, "status", true) {
+			return errors.New("'status' argument must be an HTTP status code")
+		}
+		if !validStatusArg(f.Args, "from", false) {
+			return errors.New("'from' argument must be an HTTP status code")
+		}
+	case "response_body_replace":
+		return required("find")
+	case "response_json_fields":
+		if fields, err := f.Args.Strings("fields"); err != nil {
+			return err
+		} else if len(fields) == 0 {
+			return errors.New("'fields' argument is required")
+		}
+	}
+	return nil
+}
+
+// validateRegexRewrite checks the pattern of a regex path rewrite.
+func validateRegexRewrite(pattern string) error {
+	if pattern == "" {
+		return errors.New("is required")
+	}
+	_, err := regexp.Compile(pattern)
+	return err
+}
+
+// validStatusArg reports whether the argument name is an HTTP status code
+// between 100 and 599; an absent argument is valid unless required.
+func validStatusArg(args FilterArgs, name string, required bool) bool {
+	if !args.Has(name) {
+		return !required
+	}
+	code, err := args.Int(name, 0)
+	return err == nil && code >= 100 && code <= 599
+}
+
+// validateFallback validates server.fallback against the clusters.
+func validateFallback(f *FallbackConfig, clusters []Cluster) error {
+	if f == nil {
+		return nil
+	}
+	if f.Cluster != "" {
+		if f.Status != 0 || f.Body != "" {
+			return errors.New("server.fallback: cluster cannot be combined with status or body")
+		}
+		for _, c := range clusters {
+			if c.Name == f.Cluster {
+				if c.Type != "http" {
+					return fmt.Errorf("server.fallback.cluster: cluster %q must be of type 'http', got %q", c.Name, c.Type)
+				}
+				return nil
+			}
+		}
+		return fmt.Errorf("server.fallback.cluster: unknown cluster %q", f.Cluster)
+	}
+	if f.Status != 0 && (f.Status < 200 || f.Status > 599) {
+		return fmt.Errorf("server.fallback.status: invalid status %d", f.Status)
+	}
+	if f.Body != "" && !json.Valid([]byte(f.Body)) {
+		return errors.New("server.fallback.body: must be valid JSON")
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const defaultsConfig = strictBaseConfig + `defaults:
+  timeout: 10s
+  filters:
+    - chain: common
+filter_chains:
+  common:
+    - type: header_set
+      args: {key: x-gw, value: nexus}
+    - type: header_set
+      args: {key: x-env, value: prod}
+routes_v2:
+  - name: inherits
+    match: {path: /a}
+    upstream: {cluster: users}
+  - name: overrides
+    match: {path_prefix: /b}
+    filters:
+      - type: strip_prefix
+        args: {prefix: /b}
+      - chain: common
+    upstream: {cluster: users, timeout: 2s}
+  - name: opts-out
+    match: {path: /c}
+    no_default_filters: true
+    upstream: {cluster: users, timeout: 0s}
+`
+
+func TestRouteDefaults(t *testing.T) {
+	cfg, err := loadString(t, defaultsConfig, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	filterTypes := func(r *RouteV2) string {
+		var types []string
+		for _, f := range cfg.RouteFilters(r) {
+			key, _ := f.Args.String("key")
+			prefix, _ := f.Args.String("prefix")
+			types = append(types, f.Type+":"+key+prefix)
+		}
+		return strings.Join(types, ",")
+	}
+	for i, tt := range []struct {
+		filters string
+		timeout time.Duration
+	}{
+		{"header_set:x-gw,header_set:x-env", 10 * time.Second},
+		{"strip_prefix:/b,header_set:x-gw,header_set:x-env", 2 * time.Second},
+		{"", 0},
+	} {
+		r := &cfg.RoutesV2[i]
+		if got := filterTypes(r); got != tt.filters {
+			t.Errorf("route %s: filters %q, want %q", r.Name, got, tt.filters)
+		}
+		if got := cfg.RouteTimeout(r); got != tt.timeout {
+			t.Errorf("route %s: timeout %v, want %v", r.Name, got, tt.timeout)
+		}
+	}
+}
+
+func TestRouteDefaults_Validation(t *testing.T) {
+	for _, tt := range []struct{ old, new, want string }{
+		{"- chain: common\n    upstream", "- chain: missing\n    upstream", "unknown filter chain"},
+		{"      - chain: common\n    upstream", "      - chain: missing\n    upstream", `line 26, column 9: route_v2 "overrides" filters[1]: unknown filter chain "missing"`},
+		{"    - chain: common\nfilter_chains", "    - chain: other\nfilter_chains", `defaults.filters[0]: unknown filter chain "other"`},
+		{"      args: {key: x-env, value: prod}", "      args: {value: prod}", `line 15, column 7: filter_chains.common[1] (header_set): 'key' argument is required`},
+		{"    - type: header_set\n      args: {key: x-gw", "    - chain: common\n      args: {key: x-gw", "filter chains cannot include other chains"},
+		{"      - chain: common\n    upstream", "      - chain: common\n        type: header_set\n    upstream", "chain cannot be combined with type or args"},
+		{"timeout: 10s", "timeout: ten seconds", `line 8, column 3: defaults.timeout: invalid duration "ten seconds"`},
+	} {
+		content := strings.Replace(defaultsConfig, tt.old, tt.new, 1)
+		if content == defaultsConfig {
+			t.Fatalf("%q not found", tt.old)
+		}
+		if _, err := loadString(t, content, true); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("expected %q, got %v", tt.want, err)
+		}
+	}
+}
+
+func TestRouteNormalization(t *testing.T) {
+	content := strings.Replace(defaultsConfig, "defaults:", "path_normalization:\n  merge_slashes: true\n  case_insensitive: true\ndefaults:", 1)
+	content = strings.Replace(content, "match: {path: /c}", "match: {path: /c, strip_trailing_slash: true, case_insensitive: false}", 1)
+	cfg, err := loadString(t, content, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []PathNormalization{
+		{MergeSlashes: true, CaseInsensitive: true},
+		{MergeSlashes: true, CaseInsensitive: true},
+		{MergeSlashes: true, StripTrailingSlash: true},
+	} {
+		r := &cfg.RoutesV2[i]
+		if got := cfg.RouteNormalization(r); got != want {
+			t.Errorf("route %s: %+v, want %+v", r.Name, got, want)
+		}
+	}
+	if got := (&Config{}).RouteNormalization(&cfg.RoutesV2[0]); got != (PathNormalization{}) {
+		t.Errorf("without path_normalization: %+v", got)
+	}
+}
+
+func TestValidate_Fallback(t *testing.T) {
+	tests := []struct {
+		name     string
+		fallback FallbackConfig
+		error    string
+	}{
+		{"cluster", FallbackConfig{Cluster: "web"}, ""},
+		{"json", FallbackConfig{Status: 404, Body: `{"error":"not found"}`}, ""},
+		{"unknown cluster", FallbackConfig{Cluster: "missing"}, `server.fallback.cluster: unknown cluster "missing"`},
+		{"grpc cluster", FallbackConfig{Cluster: "rpc"}, `cluster "rpc" must be of type 'http', got "grpc"`},
+		{"cluster and body", FallbackConfig{Cluster: "web", Body: "{}"}, "cluster cannot be combined with status or body"},
+		{"bad status", FallbackConfig{Status: 42}, "server.fallback.status: invalid status 42"},
+		{"bad body", FallbackConfig{Body: "not found"}, "server.fallback.body: must be valid JSON"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Server: ServerConfig{Listen: ":8080", Fallback: &tt.fallback},
+				Clusters: []Cluster{
+					{Name: "web", Type: "http", Endpoints: []ClusterEndpoint{{URL: "http://web:8080"}}},
+					{Name: "rpc", Type: "grpc", Endpoints: []ClusterEndpoint{{Addr: "rpc:9000"}}, GRPC: &ClusterGRPC{}},
+				},
+			}
+			err := Validate(cfg)
+			if tt.error == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.error) {
+				t.Errorf("expected error containing %q, got %v", tt.error, err)
+			}
+		})
+	}
+
+	status, body := (&FallbackConfig{}).Response()
+	if status != 404 || body != nil {
+		t.Errorf("Response() = %d %s", status, body)
+	}
+	status, body = (&FallbackConfig{Status: 410, Body: `{"error":"gone"}`}).Response()
+	if status != 410 || string(body) != `{"error":"gone"}` {
+		t.Errorf("Response() = %d %s", status, body)
+	}
+}
+
+func TestUsesV2(t *testing.T) {
+	cluster := []Cluster{{Name: "c", Type: "http"}}
+	tests := []struct {
+		name string
+		cfg  Config
+		want bool
+	}{
+		{"legacy only", Config{Clusters: cluster}, false},
+		{"routes without clusters", Config{RoutesV2: []RouteV2{{Name: "r", Upstream: RouteUpstream{Cluster: "c"}}}}, false},
+		{"routes and clusters", Config{Clusters: cluster, RoutesV2: []RouteV2{{Name: "r", Upstream: RouteUpstream{Cluster: "c"}}}}, true},
+		{"mock routes", Config{RoutesV2: []RouteV2{{Name: "r", Upstream: RouteUpstream{Mock: &RouteUpstreamMock{}}}}}, true},
+	}
+	for _, tt := range tests {
+		if got := tt.cfg.UsesV2(); got != tt.want {
+			t.Errorf("%s: UsesV2() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
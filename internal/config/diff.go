@@ -0,0 +1,249 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Change operations reported by Diff.
+const (
+	ChangeAdded   = "added"
+	ChangeRemoved = "removed"
+	ChangeChanged = "changed"
+)
+
+// redacted replaces admin tokens in diffs; redactedChanged marks a token
+// that differs from the one with the same name in the other configuration.
+const (
+	redacted        = "<redacted>"
+	redactedChanged = "<redacted:changed>"
+)
+
+// Change is one difference between two configurations. Path uses the field
+// names of the configuration file, and list entries with a name are
+// addressed by it, e.g. routes_v2[orders].upstream.cluster.
+type Change struct {
+	Path string      `json:"path"`
+	Op   string      `json:"op"`
+	Old  interface{} `json:"old,omitempty"`
+	New  interface{} `json:"new,omitempty"`
+}
+
+// Diff returns the changes turning from into to. Fields are compared in
+// name order and named list entries in configuration order. Either
+// configuration may be nil. Secrets are redacted as Redacted does, and
+// admin tokens and API keys are replaced by markers.
+func Diff(from, to *Config) ([]Change, error) {
+	rfrom, rto := Redacted(from), Redacted(to)
+	redactAPIKeys(from, to, rfrom, rto)
+	from, to = redactTokens(rfrom, nil), redactTokens(rto, from)
+	a, err := genericConfig(from)
+	if err != nil {
+		return nil, err
+	}
+	b, err := genericConfig(to)
+	if err != nil {
+		return nil, err
+	}
+	var changes []Change
+	diffValues("", a, b, &changes)
+	return changes, nil
+}
+
+// genericConfig converts cfg to maps and lists keyed by the file field names.
+func genericConfig(cfg *Config) (interface{}, error) {
+	if cfg == nil {
+		return map[string]interface{}{}, nil
+	}
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("marshal config: %w", err)
+	}
+	var v interface{}
+	if err := yaml.Unmarshal(data, &v); err != nil {
+		return nil, fmt.Errorf("unmarshal config: %w", err)
+	}
+	return v, nil
+}
+
+// redactTokens returns a copy of cfg whose admin tokens are replaced by a
+// marker. When prev is set, tokens differing from the same-named token in
+// prev get a distinct marker so the change still shows up.
+func redactTokens(cfg, prev *Config) *Config {
+	if cfg == nil || cfg.Admin.Auth == nil || len(cfg.Admin.Auth.Tokens) == 0 {
+		return cfg
+	}
+	previous := make(map[string]string)
+	if prev != nil && prev.Admin.Auth != nil {
+		for _, t := range prev.Admin.Auth.Tokens {
+			previous[t.Name] = t.Token
+		}
+	}
+	out := *cfg
+	authCfg := *cfg.Admin.Auth
+	authCfg.Tokens = make([]AdminToken, len(cfg.Admin.Auth.Tokens))
+	for i, t := range cfg.Admin.Auth.Tokens {
+		old, ok := previous[t.Name]
+		if prev == nil || (ok && old == t.Token) {
+			t.Token = redacted
+		} else {
+			t.Token = redactedChanged
+		}
+		authCfg.Tokens[i] = t
+	}
+	out.Admin.Auth = &authCfg
+	return &out
+}
+
+// redactAPIKeys replaces the API keys of rfrom and rto, the redacted
+// copies of from and to, with numbered markers. A key in both
+// configurations gets the same marker, so that a diff reports the keys
+// added and removed and the consumers changed without revealing any key.
+func redactAPIKeys(from, to, rfrom, rto *Config) {
+	markers := make(map[string]string)
+	for _, cfg := range []*Config{from, to} {
+		if cfg == nil {
+			continue
+		}
+		keys := make([]string, 0, len(cfg.Auth.APIKey.Keys))
+		for k := range cfg.Auth.APIKey.Keys {
+			if _, ok := markers[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		// Ordered by consumer, so that markers do not reveal the order of
+		// the keys.
+		consumers := cfg.Auth.APIKey.Keys
+		sort.Slice(keys, func(i, j int) bool {
+			ci, cj := consumers[keys[i]], consumers[keys[j]]
+			return ci < cj || ci == cj && keys[i] < keys[j]
+		})
+		for _, k := range keys {
+			markers[k] = fmt.Sprintf("%s-%d", redacted, len(markers)+1)
+		}
+	}
+	for _, pair := range [][2]*Config{{from, rfrom}, {to, rto}} {
+		cfg, out := pair[0], pair[1]
+		if cfg == nil || len(cfg.Auth.APIKey.Keys) == 0 {
+			continue
+		}
+		out.Auth.APIKey.Keys = make(map[string]string, len(cfg.Auth.APIKey.Keys))
+		for k, consumer := range cfg.Auth.APIKey.Keys {
+			out.Auth.APIKey.Keys[markers[k]] = consumer
+		}
+	}
+}
+
+func diffValues(path string, a, b interface{}, changes *[]Change) {
+	switch av := a.(type) {
+	case map[string]interface{}:
+		if bv, ok := b.(map[string]interface{}); ok {
+			diffMaps(path, av, bv, changes)
+			return
+		}
+	case []interface{}:
+		if bv, ok := b.([]interface{}); ok {
+			diffLists(path, av, bv, changes)
+			return
+		}
+	}
+	if !reflect.DeepEqual(a, b) {
+		*changes = append(*changes, Change{Path: path, Op: ChangeChanged, Old: a, New: b})
+	}
+}
+
+func diffMaps(path string, a, b map[string]interface{}, changes *[]Change) {
+	keys := make([]string, 0, len(a)+len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		p := k
+		if path != "" {
+			p = path + "." + k
+		}
+		diffEntry(p, a, b, k, changes)
+	}
+}
+
+func diffEntry(path string, a, b map[string]interface{}, key string, changes *[]Change) {
+	av, inA := a[key]
+	bv, inB := b[key]
+	switch {
+	case !inA:
+		*changes = append(*changes, Change{Path: path, Op: ChangeAdded, New: bv})
+	case !inB:
+		*changes = append(*changes, Change{Path: path, Op: ChangeRemoved, Old: av})
+	default:
+		diffValues(path, av, bv, changes)
+	}
+}
+
+// diffLists compares lists of named entries by name, so reordering or
+// inserting an entry only reports the entries concerned, and other lists by
+// index.
+func diffLists(path string, a, b []interface{}, changes *[]Change) {
+	an, aok := namedEntries(a)
+	bn, bok := namedEntries(b)
+	if aok && bok {
+		names := make([]string, 0, len(a)+len(b))
+		for _, v := range a {
+			names = append(names, entryName(v))
+		}
+		for _, v := range b {
+			if _, ok := an[entryName(v)]; !ok {
+				names = append(names, entryName(v))
+			}
+		}
+		for _, name := range names {
+			diffEntry(path+"["+name+"]", an, bn, name, changes)
+		}
+		return
+	}
+	for i := 0; i < len(a) || i < len(b); i++ {
+		p := fmt.Sprintf("%s[%d]", path, i)
+		switch {
+		case i >= len(a):
+			*changes = append(*changes, Change{Path: p, Op: ChangeAdded, New: b[i]})
+		case i >= len(b):
+			*changes = append(*changes, Change{Path: p, Op: ChangeRemoved, Old: a[i]})
+		default:
+			diffValues(p, a[i], b[i], changes)
+		}
+	}
+}
+
+// namedEntries indexes list entries by their name field. It reports false
+// unless every entry has a distinct name.
+func namedEntries(list []interface{}) (map[string]interface{}, bool) {
+	byName := make(map[string]interface{}, len(list))
+	for _, v := range list {
+		name := entryName(v)
+		if name == "" {
+			return nil, false
+		}
+		if _, dup := byName[name]; dup {
+			return nil, false
+		}
+		byName[name] = v
+	}
+	return byName, true
+}
+
+func entryName(v interface{}) string {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	name, _ := m["name"].(string)
+	return name
+}
@@ -0,0 +1,120 @@
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	from := &Config{
+		Server:    ServerConfig{Listen: ":8080"},
+		Upstreams: []Upstream{{Name: "a", Targets: []Target{{Address: "a:1"}}}, {Name: "b", Targets: []Target{{Address: "b:1"}}}},
+		Clusters:  []Cluster{{Name: "web", Endpoints: []ClusterEndpoint{{URL: "http://web:1"}}}},
+		Admin:     AdminConfig{Auth: &AdminAuthConfig{Tokens: []AdminToken{{Name: "ci", Token: "old-secret", Role: AdminRoleOperator}}}},
+	}
+	to := &Config{
+		Server: ServerConfig{Listen: ":9090"},
+		// b moves first and a is dropped: only a is reported.
+		Upstreams: []Upstream{{Name: "b", Targets: []Target{{Address: "b:1"}}}, {Name: "c", Targets: []Target{{Address: "c:1"}}}},
+		Clusters:  []Cluster{{Name: "web", Endpoints: []ClusterEndpoint{{URL: "http://web:1"}, {URL: "http://web:2"}}}},
+		Admin:     AdminConfig{Auth: &AdminAuthConfig{Tokens: []AdminToken{{Name: "ci", Token: "new-secret", Role: AdminRoleOperator}}}},
+	}
+
+	changes, err := Diff(from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]Change, len(changes))
+	for _, c := range changes {
+		got[c.Path] = c
+	}
+	want := map[string]Change{
+		"server.listen":               {Op: ChangeChanged, Old: ":8080", New: ":9090"},
+		"upstreams[a]":                {Op: ChangeRemoved},
+		"upstreams[c]":                {Op: ChangeAdded},
+		"clusters[web].endpoints[1]":  {Op: ChangeAdded},
+		"admin.auth.tokens[ci].token": {Op: ChangeChanged, Old: redacted, New: redactedChanged},
+	}
+	if len(got) != len(want) {
+		t.Errorf("expected %d changes, got %+v", len(want), changes)
+	}
+	for path, w := range want {
+		c, ok := got[path]
+		if !ok || c.Op != w.Op {
+			t.Errorf("%s: expected %s, got %+v", path, w.Op, c)
+			continue
+		}
+		if w.Old != nil && (!reflect.DeepEqual(c.Old, w.Old) || !reflect.DeepEqual(c.New, w.New)) {
+			t.Errorf("%s: expected %v -> %v, got %v -> %v", path, w.Old, w.New, c.Old, c.New)
+		}
+	}
+
+	// The inputs keep their tokens.
+	if from.Admin.Auth.Tokens[0].Token != "old-secret" || to.Admin.Auth.Tokens[0].Token != "new-secret" {
+		t.Error("Diff modified its arguments")
+	}
+}
+
+func TestDiff_Identical(t *testing.T) {
+	cfg := &Config{
+		Server: ServerConfig{Listen: ":8080"},
+		Admin:  AdminConfig{Auth: &AdminAuthConfig{Tokens: []AdminToken{{Name: "ci", Token: "secret", Role: AdminRoleReadOnly}}}},
+	}
+	changes, err := Diff(cfg, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 0 {
+		t.Errorf("expected no changes, got %+v", changes)
+	}
+
+	changes, err = Diff(nil, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range changes {
+		if c.Op != ChangeAdded {
+			t.Errorf("expected only additions from nil, got %+v", c)
+		}
+	}
+}
+
+func TestDiff_RedactsSecrets(t *testing.T) {
+	from := &Config{
+		Auth:     AuthConfig{APIKey: APIKeyConfig{Keys: map[string]string{"key-a": "client-a", "key-b": "client-b"}}},
+		Clusters: []Cluster{{Name: "db", Endpoints: []ClusterEndpoint{{URL: "http://svc:old-pass@db:1"}}}},
+	}
+	to := &Config{
+		Auth:     AuthConfig{APIKey: APIKeyConfig{Keys: map[string]string{"key-b": "client-c", "key-d": "client-d"}}},
+		Clusters: []Cluster{{Name: "db", Endpoints: []ClusterEndpoint{{URL: "http://svc:new-pass@db:2"}}}},
+	}
+	changes, err := Diff(from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(changes)
+	for _, secret := range []string{"key-a", "key-b", "key-d", "old-pass", "new-pass"} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("diff reveals %q: %s", secret, data)
+		}
+	}
+	got := make(map[string]Change, len(changes))
+	for _, c := range changes {
+		got[c.Path] = c
+	}
+	// Markers follow the keys: key-a is removed, key-b changes consumer and
+	// key-d is added.
+	want := map[string]string{
+		"auth.api_key.keys." + redacted + "-1": ChangeRemoved,
+		"auth.api_key.keys." + redacted + "-2": ChangeChanged,
+		"auth.api_key.keys." + redacted + "-3": ChangeAdded,
+		"clusters[db].endpoints[0].url":        ChangeChanged,
+	}
+	for path, op := range want {
+		if got[path].Op != op {
+			t.Errorf("%s: expected %s, got %+v", path, op, got[path])
+		}
+	}
+}
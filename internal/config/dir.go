@@ -0,0 +1,182 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// namedLists are the top-level lists that the files of a configuration
+// directory contribute entries to. Entry names must be unique across files.
+var namedLists = map[string]bool{
+	"upstreams": true,
+	"routes":    true,
+	"listeners": true,
+	"clusters":  true,
+	"routes_v2": true,
+}
+
+// isConfigFile reports whether name is loaded from a configuration directory.
+func isConfigFile(name string) bool {
+	ext := filepath.Ext(name)
+	return (ext == ".yaml" || ext == ".yml") && !strings.HasPrefix(filepath.Base(name), ".")
+}
+
+// configFiles returns the configuration files of dir in name order.
+func configFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read config directory: %w", err)
+	}
+	var files []string
+	for _, e := range entries {
+		if !e.IsDir() && isConfigFile(e.Name()) {
+			files = append(files, filepath.Join(dir, e.Name()))
+		}
+	}
+	sort.Strings(files)
+	if len(files) == 0 {
+		return nil, fmt.Errorf("config directory %s has no *.yaml files", dir)
+	}
+	return files, nil
+}
+
+// mergeDir parses the files of a configuration directory into one document.
+// Upstreams, routes, listeners, clusters and routes_v2 are concatenated in
+// file name order, and a name defined by two files is a conflict. Any other
+// section may only be set by one file, except scalars such as version, which
+// files may repeat with the same value. Proto descriptor lists are combined.
+func mergeDir(dir string) (*document, error) {
+	files, err := configFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	merged := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	origins := make(map[*yaml.Node]string)
+	sections := make(map[string]*yaml.Node) // key → value in merged
+	owners := make(map[string]string)       // key → file that set it
+	entryOwners := make(map[string]string)  // "key[name]" → file
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("read config file: %w", err)
+		}
+		base := filepath.Base(file)
+		var doc yaml.Node
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", base, err)
+		}
+		if len(doc.Content) == 0 {
+			continue
+		}
+		if err := expandEnv(&doc, os.LookupEnv, nil); err != nil {
+			return nil, fmt.Errorf("expand %s: %w", base, err)
+		}
+		root := doc.Content[0]
+		if root.Kind != yaml.MappingNode {
+			return nil, fmt.Errorf("parse %s: document is not a mapping", base)
+		}
+		recordFile(root, base, origins)
+
+		for i := 0; i+1 < len(root.Content); i += 2 {
+			keyNode, value := root.Content[i], root.Content[i+1]
+			key := keyNode.Value
+			existing, seen := sections[key]
+			switch {
+			case !seen:
+				sections[key] = value
+				owners[key] = base
+				merged.Content = append(merged.Content, keyNode, value)
+				if namedLists[key] {
+					if err := claimEntries(key, value, base, entryOwners); err != nil {
+						return nil, err
+					}
+				}
+			case namedLists[key] || key == "proto_descriptors":
+				if existing.Kind != yaml.SequenceNode || value.Kind != yaml.SequenceNode {
+					return nil, fmt.Errorf("%s: %s must be a list", base, key)
+				}
+				if key == "proto_descriptors" {
+					existing.Content = appendMissing(existing.Content, value.Content)
+					continue
+				}
+				if err := claimEntries(key, value, base, entryOwners); err != nil {
+					return nil, err
+				}
+				existing.Content = append(existing.Content, value.Content...)
+			case existing.Kind == yaml.ScalarNode && value.Kind == yaml.ScalarNode && existing.Value == value.Value:
+				// The same scalar in several files, e.g. version.
+			default:
+				return nil, fmt.Errorf("%s is set in both %s and %s", key, owners[key], base)
+			}
+		}
+	}
+
+	return &document{root: merged, files: origins}, nil
+}
+
+// recordFile records file as the origin of n and the nodes below it.
+func recordFile(n *yaml.Node, file string, files map[*yaml.Node]string) {
+	files[n] = file
+	for _, c := range n.Content {
+		recordFile(c, file, files)
+	}
+}
+
+// claimEntries records the file defining each named entry of a list,
+// failing when another file already defines it.
+func claimEntries(key string, list *yaml.Node, file string, owners map[string]string) error {
+	if list.Kind != yaml.SequenceNode {
+		return nil // reported when decoding
+	}
+	for _, entry := range list.Content {
+		name := entryNameNode(entry)
+		if name == "" {
+			continue
+		}
+		id := key + "[" + name + "]"
+		if owner, ok := owners[id]; ok {
+			if owner == file {
+				continue // duplicates within a file are left to Validate
+			}
+			return fmt.Errorf("%s is defined in both %s and %s", id, owner, file)
+		}
+		owners[id] = file
+	}
+	return nil
+}
+
+// entryNameNode returns the name of a list entry, if it has one.
+func entryNameNode(entry *yaml.Node) string {
+	if entry.Kind != yaml.MappingNode {
+		return ""
+	}
+	for i := 0; i+1 < len(entry.Content); i += 2 {
+		if entry.Content[i].Value == "name" {
+			return entry.Content[i+1].Value
+		}
+	}
+	return ""
+}
+
+// appendMissing appends the scalars of add not already in list.
+func appendMissing(list, add []*yaml.Node) []*yaml.Node {
+	for _, n := range add {
+		dup := false
+		for _, m := range list {
+			if m.Value == n.Value {
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			list = append(list, n)
+		}
+	}
+	return list
+}
@@ -0,0 +1,170 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const dirBaseConfig = `version: v1
+server:
+  listen: ":8080"
+proto_descriptors: [common.pb]
+`
+
+const dirOrdersConfig = `version: v1
+clusters:
+  - name: orders
+    endpoints:
+      - url: "http://orders:8080"
+routes_v2:
+  - name: orders
+    match:
+      path_prefix: /orders
+    upstream:
+      cluster: orders
+proto_descriptors: [common.pb, orders.pb]
+`
+
+const dirUsersConfig = `clusters:
+  - name: users
+    endpoints:
+      - url: "http://users:8080"
+routes_v2:
+  - name: users
+    match:
+      path_prefix: /users
+    upstream:
+      cluster: users
+`
+
+func writeConfigDir(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoadDir(t *testing.T) {
+	dir := writeConfigDir(t, map[string]string{
+		"00-base.yaml": dirBaseConfig,
+		"orders.yaml":  dirOrdersConfig,
+		"users.yml":    dirUsersConfig,
+		"notes.txt":    "not: [config",
+		".hidden.yaml": "not: [config",
+		"empty.yaml":   "# nothing yet\n",
+	})
+	loader := NewLoader(dir)
+	cfg, err := loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loader.IsDir() || cfg.Server.Listen != ":8080" || cfg.Version != "v1" {
+		t.Errorf("unexpected config %+v", cfg)
+	}
+	if len(cfg.Clusters) != 2 || cfg.Clusters[0].Name != "orders" || cfg.Clusters[1].Name != "users" {
+		t.Errorf("unexpected clusters %+v", cfg.Clusters)
+	}
+	if len(cfg.RoutesV2) != 2 || cfg.RoutesV2[1].Upstream.Cluster != "users" {
+		t.Errorf("unexpected routes %+v", cfg.RoutesV2)
+	}
+	if strings.Join(cfg.ProtoDescriptors, ",") != "common.pb,orders.pb" {
+		t.Errorf("unexpected proto descriptors %v", cfg.ProtoDescriptors)
+	}
+
+	raw, err := loader.Raw()
+	if err != nil || !strings.Contains(string(raw), "name: users") {
+		t.Errorf("unexpected raw config %q, %v", raw, err)
+	}
+	if err := loader.Persist(cfg, raw); err == nil {
+		t.Error("expected persisting to a directory to fail")
+	}
+}
+
+func TestLoadDir_Conflicts(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{
+			"route in two files",
+			map[string]string{"a.yaml": dirBaseConfig + dirUsersConfig, "b.yaml": strings.Replace(dirOrdersConfig, "name: orders\n    match", "name: users\n    match", 1)},
+			"routes_v2[users] is defined in both a.yaml and b.yaml",
+		},
+		{
+			"section in two files",
+			map[string]string{"a.yaml": dirBaseConfig, "b.yaml": "server:\n  listen: \":9090\"\n"},
+			"server is set in both a.yaml and b.yaml",
+		},
+		{
+			"different scalars",
+			map[string]string{"a.yaml": dirBaseConfig, "b.yaml": "version: v2\n"},
+			"version is set in both",
+		},
+		{
+			"list shape",
+			map[string]string{"a.yaml": dirBaseConfig + dirUsersConfig, "b.yaml": "clusters: {}\n"},
+			"clusters must be a list",
+		},
+		{
+			"no files",
+			map[string]string{"README.md": "# config"},
+			"has no *.yaml files",
+		},
+		{
+			"invalid file",
+			map[string]string{"a.yaml": dirBaseConfig, "b.yaml": "clusters: [\n"},
+			"parse b.yaml",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewLoader(writeConfigDir(t, tt.files)).Load()
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestWatchDir(t *testing.T) {
+	dir := writeConfigDir(t, map[string]string{"00-base.yaml": dirBaseConfig, "orders.yaml": dirOrdersConfig})
+	loader := NewLoader(dir)
+	if _, err := loader.Load(); err != nil {
+		t.Fatal(err)
+	}
+	changes := make(chan *Config, 10)
+	done := make(chan struct{})
+	defer close(done)
+	go loader.Watch(func(c *Config) { changes <- c }, done)
+	time.Sleep(100 * time.Millisecond) // let the watcher start
+
+	wait := func(routes int) {
+		t.Helper()
+		deadline := time.After(5 * time.Second)
+		for {
+			select {
+			case c := <-changes:
+				if len(c.RoutesV2) == routes {
+					return
+				}
+			case <-deadline:
+				t.Fatalf("expected a reload with %d routes", routes)
+			}
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "users.yaml"), []byte(dirUsersConfig), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	wait(2)
+	if err := os.Remove(filepath.Join(dir, "orders.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	wait(1)
+}
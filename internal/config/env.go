@@ -0,0 +1,105 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// expandEnv replaces environment variable references in the values of a
+// parsed configuration document. ${NAME} is replaced by the value of NAME,
+// which must be set; ${NAME:-default} falls back to default when NAME is
+// unset or empty. $${ stands for a literal ${. Keys and comments are left
+// alone, and an unquoted value is re-typed after expansion, so
+// "listen: :${PORT:-8080}" and "max_idle_conns: ${IDLE_CONNS:-100}" both
+// work. Expanded values cannot change the structure of the document.
+// When refs is not nil, the text of each expanded node is recorded in it,
+// so that the references can be restored when the configuration is written
+// back.
+func expandEnv(node *yaml.Node, lookup func(string) (string, bool), refs map[*yaml.Node]string) error {
+	switch node.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, n := range node.Content {
+			if err := expandEnv(n, lookup, refs); err != nil {
+				return err
+			}
+		}
+	case yaml.MappingNode:
+		for i := 1; i < len(node.Content); i += 2 {
+			if err := expandEnv(node.Content[i], lookup, refs); err != nil {
+				return err
+			}
+		}
+	case yaml.ScalarNode:
+		if !strings.Contains(node.Value, "${") {
+			return nil
+		}
+		value, err := expandString(node.Value, lookup)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", node.Line, err)
+		}
+		if value != node.Value {
+			recordRef(refs, node)
+		}
+		node.Value = value
+		if node.Style&yaml.TaggedStyle == 0 {
+			// Resolve the type from the expanded value.
+			node.Tag = ""
+		}
+	}
+	return nil
+}
+
+// expandString expands the references in s.
+func expandString(s string, lookup func(string) (string, bool)) (string, error) {
+	var b strings.Builder
+	for {
+		i := strings.Index(s, "${")
+		if i < 0 {
+			b.WriteString(s)
+			return b.String(), nil
+		}
+		if i > 0 && s[i-1] == '$' {
+			b.WriteString(s[:i-1] + "${")
+			s = s[i+2:]
+			continue
+		}
+		b.WriteString(s[:i])
+		end := strings.IndexByte(s[i:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("unterminated reference in %q", s)
+		}
+		ref := s[i+2 : i+end]
+		s = s[i+end+1:]
+
+		name, def, hasDef := strings.Cut(ref, ":-")
+		if !validEnvName(name) {
+			return "", fmt.Errorf("invalid environment variable reference ${%s}", ref)
+		}
+		value, ok := lookup(name)
+		switch {
+		case hasDef && value == "":
+			value = def
+		case !ok:
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+		b.WriteString(value)
+	}
+}
+
+// validEnvName reports whether name is a valid environment variable name.
+func validEnvName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, c := range name {
+		switch {
+		case c == '_', c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z':
+		case c >= '0' && c <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,189 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestExpandString(t *testing.T) {
+	env := map[string]string{"HOST": "db.internal", "EMPTY": ""}
+	lookup := func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+	for _, tt := range []struct {
+		in, want, err string
+	}{
+		{in: "plain", want: "plain"},
+		{in: "${HOST}:5432", want: "db.internal:5432"},
+		{in: "${PORT:-8080}", want: "8080"},
+		{in: "${EMPTY:-fallback}", want: "fallback"},
+		{in: "${EMPTY}", want: ""},
+		{in: "${HOST:-x}/${PORT:-}", want: "db.internal/"},
+		{in: "$${HOST} and $5", want: "${HOST} and $5"},
+		{in: "${MISSING}", err: "MISSING is not set"},
+		{in: "${HOST", err: "unterminated"},
+		{in: "${1X}", err: "invalid environment variable"},
+		{in: "${}", err: "invalid environment variable"},
+	} {
+		got, err := expandString(tt.in, lookup)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%q: expected error containing %q, got %q, %v", tt.in, tt.err, got, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("%q: expected %q, got %q, %v", tt.in, tt.want, got, err)
+		}
+	}
+}
+
+func TestLoad_ExpandsEnv(t *testing.T) {
+	t.Setenv("NEXUS_TEST_BACKEND", "10.0.0.7:9001")
+	t.Setenv("NEXUS_TEST_TOKEN", "s3cret: value # not a comment")
+	path := writeTemp(t, `
+# ${NOT_EXPANDED_IN_COMMENTS}
+server:
+  listen: ":${NEXUS_TEST_PORT:-8080}"
+  read_timeout: ${NEXUS_TEST_READ_TIMEOUT:-15s}
+upstreams:
+  - name: backend
+    targets:
+      - address: ${NEXUS_TEST_BACKEND}
+        weight: ${NEXUS_TEST_WEIGHT:-3}
+routes:
+  - name: api
+    paths:
+      - path: /
+        type: prefix
+    upstream: backend
+admin:
+  auth:
+    tokens:
+      - name: ci
+        token: ${NEXUS_TEST_TOKEN}
+        role: operator
+`)
+	cfg, err := NewLoader(path).Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Server.Listen != ":8080" || cfg.Server.ReadTimeout.String() != "15s" {
+		t.Errorf("unexpected server %+v", cfg.Server)
+	}
+	if target := cfg.Upstreams[0].Targets[0]; target.Address != "10.0.0.7:9001" || target.Weight != 3 {
+		t.Errorf("unexpected target %+v", target)
+	}
+	if token := cfg.Admin.Auth.Tokens[0].Token; token != "s3cret: value # not a comment" {
+		t.Errorf("unexpected token %q", token)
+	}
+
+	path = writeTemp(t, "server:\n  listen: \"${NEXUS_TEST_UNSET_VARIABLE}\"\n")
+	if _, err := NewLoader(path).Load(); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("expected an error naming the line, got %v", err)
+	}
+}
+
+func TestPersist_RestoresEnv(t *testing.T) {
+	t.Setenv("NEXUS_TEST_SECRET_KEY", "supersecret")
+	t.Setenv("NEXUS_TEST_BACKEND", "10.0.0.7:9001")
+	path := writeTemp(t, `
+server:
+  listen: ":8080"
+upstreams:
+  - name: backend
+    targets:
+      - address: ${NEXUS_TEST_BACKEND}
+routes:
+  - name: api
+    paths:
+      - path: /
+        type: prefix
+    upstream: backend
+auth:
+  api_key:
+    enabled: true
+    keys:
+      k1: "${NEXUS_TEST_SECRET_KEY}"
+`)
+	loader := NewLoader(path)
+	cfg, err := loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := yaml.Marshal(cfg)
+	if err := loader.Persist(cfg, data); err != nil {
+		t.Fatal(err)
+	}
+	written, _ := os.ReadFile(path)
+	if strings.Contains(string(written), "supersecret") || strings.Contains(string(written), "10.0.0.7") {
+		t.Errorf("persisted file contains expanded values:\n%s", written)
+	}
+	for _, ref := range []string{"${NEXUS_TEST_SECRET_KEY}", "${NEXUS_TEST_BACKEND}"} {
+		if !strings.Contains(string(written), ref) {
+			t.Errorf("persisted file lacks %s:\n%s", ref, written)
+		}
+	}
+	if cfg, err = loader.Load(); err != nil || cfg.Auth.APIKey.Keys["k1"] != "supersecret" {
+		t.Errorf("reloading persisted file: %v", err)
+	}
+}
+
+func TestPersist_KeepsUnrelatedValues(t *testing.T) {
+	t.Setenv("NEXUS_TEST_IDLE", "")
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "token")
+	os.WriteFile(tokenFile, []byte("backend\n"), 0o600)
+	path := writeTemp(t, `
+server:
+  listen: ":8080"
+upstreams:
+  - name: backend
+    targets:
+      - address: "10.0.0.7:9001"
+        weight: 100
+routes:
+  - name: api
+    paths:
+      - path: /
+        type: prefix
+    upstream: backend
+clusters:
+  - name: web
+    endpoints:
+      - url: "http://10.0.0.8:9001"
+    keepalive:
+      max_idle_conns: ${NEXUS_TEST_IDLE:-100}
+admin:
+  auth:
+    tokens:
+      - name: deployer
+        token: "file:`+tokenFile+`"
+        role: operator
+`)
+	loader := NewLoader(path)
+	cfg, err := loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := yaml.Marshal(cfg)
+	if err := loader.Persist(cfg, data); err != nil {
+		t.Fatal(err)
+	}
+	written, _ := os.ReadFile(path)
+	// Only the values written as references are restored, not unrelated
+	// values equal to what they resolved to.
+	for _, want := range []string{"max_idle_conns: ${NEXUS_TEST_IDLE:-100}", "weight: 100", "name: backend", "token: file:" + tokenFile} {
+		if !strings.Contains(string(written), want) {
+			t.Errorf("persisted file lacks %q:\n%s", want, written)
+		}
+	}
+	if n := strings.Count(string(written), "${NEXUS_TEST_IDLE:-100}"); n != 1 {
+		t.Errorf("reference written %d times:\n%s", n, written)
+	}
+}
@@ -0,0 +1,227 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// EtcdSource reads the configuration document from an etcd v3 key through
+// the JSON gateway and watches it with a watch stream.
+type EtcdSource struct {
+	Client *http.Client
+	// Endpoint is the etcd client URL, e.g. "http://etcd:2379".
+	Endpoint string
+	Key      string
+	// Username and Password authenticate when etcd auth is enabled.
+	Username string
+	Password string
+}
+
+// errEtcdCompacted ends a watch whose start revision was compacted away.
+var errEtcdCompacted = errors.New("etcd: watch revision compacted")
+
+// etcdKV is a key-value pair of the JSON gateway. Bytes are base64 encoded
+// and 64-bit integers are strings.
+type etcdKV struct {
+	Value       []byte `json:"value"`
+	ModRevision string `json:"mod_revision"`
+}
+
+// Fetch returns the value of the key.
+func (s *EtcdSource) Fetch(ctx context.Context) ([]byte, error) {
+	kv, _, err := s.fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return kv.Value, nil
+}
+
+// Watch calls onChange whenever the key is put. A deleted key is logged and
+// the configuration kept.
+func (s *EtcdSource) Watch(ctx context.Context, onChange func([]byte)) {
+	var rev int64
+	for ctx.Err() == nil {
+		if rev == 0 {
+			// (Re)start from the current value, so that no change is missed
+			// before the watch or after a compaction.
+			kv, header, err := s.fetch(ctx)
+			if err != nil {
+				s.retry(ctx, err)
+				continue
+			}
+			onChange(kv.Value)
+			rev = header
+		}
+		next, err := s.watch(ctx, rev+1, onChange)
+		if next > rev {
+			rev = next
+		}
+		switch {
+		case errors.Is(err, errEtcdCompacted):
+			rev = 0
+		case err != nil:
+			s.retry(ctx, err)
+		}
+	}
+}
+
+func (s *EtcdSource) String() string {
+	return "etcd " + s.Endpoint + s.Key
+}
+
+func (s *EtcdSource) retry(ctx context.Context, err error) {
+	if ctx.Err() == nil {
+		slog.Error("config source watch failed", slog.String("source", s.String()), slog.String("error", err.Error()))
+		sleepCtx(ctx, sourceRetryDelay)
+	}
+}
+
+// fetch reads the key, returning it and the store revision.
+func (s *EtcdSource) fetch(ctx context.Context) (etcdKV, int64, error) {
+	var resp struct {
+		Header struct {
+			Revision string `json:"revision"`
+		} `json:"header"`
+		KVs []etcdKV `json:"kvs"`
+	}
+	body, err := s.post(ctx, "/v3/kv/range", map[string]interface{}{"key": []byte(s.Key)})
+	if err != nil {
+		return etcdKV{}, 0, err
+	}
+	defer body.Close()
+	if err := json.NewDecoder(body).Decode(&resp); err != nil {
+		return etcdKV{}, 0, fmt.Errorf("etcd: invalid range response: %w", err)
+	}
+	if len(resp.KVs) == 0 {
+		return etcdKV{}, 0, fmt.Errorf("etcd: key %s not found", s.Key)
+	}
+	rev, _ := strconv.ParseInt(resp.Header.Revision, 10, 64)
+	return resp.KVs[0], rev, nil
+}
+
+// watch streams the changes of the key from revision start, returning the
+// last revision seen when the stream ends.
+func (s *EtcdSource) watch(ctx context.Context, start int64, onChange func([]byte)) (int64, error) {
+	body, err := s.post(ctx, "/v3/watch", map[string]interface{}{
+		"create_request": map[string]interface{}{
+			"key":            []byte(s.Key),
+			"start_revision": strconv.FormatInt(start, 10),
+		},
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer body.Close()
+
+	var last int64
+	dec := json.NewDecoder(body)
+	for {
+		var msg struct {
+			Result struct {
+				CompactRevision string `json:"compact_revision"`
+				Canceled        bool   `json:"canceled"`
+				CancelReason    string `json:"cancel_reason"`
+				Events          []struct {
+					Type string `json:"type"`
+					KV   etcdKV `json:"kv"`
+				} `json:"events"`
+			} `json:"result"`
+			Error *struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := dec.Decode(&msg); err != nil {
+			if err == io.EOF {
+				err = fmt.Errorf("etcd: watch stream closed")
+			}
+			return last, err
+		}
+		if msg.Error != nil {
+			return last, fmt.Errorf("etcd: watch: %s", msg.Error.Message)
+		}
+		res := msg.Result
+		if res.CompactRevision != "" && res.CompactRevision != "0" {
+			return last, errEtcdCompacted
+		}
+		if res.Canceled {
+			return last, fmt.Errorf("etcd: watch canceled: %s", res.CancelReason)
+		}
+		for _, ev := range res.Events {
+			if rev, err := strconv.ParseInt(ev.KV.ModRevision, 10, 64); err == nil && rev > last {
+				last = rev
+			}
+			if ev.Type == "DELETE" {
+				slog.Warn("config key deleted, keeping current configuration", slog.String("source", s.String()))
+				continue
+			}
+			onChange(ev.KV.Value)
+		}
+	}
+}
+
+// post sends a JSON gateway request and returns the body of a successful
+// response.
+func (s *EtcdSource) post(ctx context.Context, path string, body interface{}) (io.ReadCloser, error) {
+	token := ""
+	if s.Username != "" {
+		var err error
+		if token, err = s.authenticate(ctx); err != nil {
+			return nil, err
+		}
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(s.Endpoint, "/")+path, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", token)
+	}
+	resp, err := clientOrDefault(s.Client).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("etcd: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("etcd: %s: %s %s", path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return resp.Body, nil
+}
+
+// authenticate exchanges the credentials for a token.
+func (s *EtcdSource) authenticate(ctx context.Context) (string, error) {
+	data, _ := json.Marshal(map[string]string{"name": s.Username, "password": s.Password})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(s.Endpoint, "/")+"/v3/auth/authenticate", bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := clientOrDefault(s.Client).Do(req)
+	if err != nil {
+		return "", fmt.Errorf("etcd: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("etcd: authenticate: %s", resp.Status)
+	}
+	var out struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", fmt.Errorf("etcd: authenticate: invalid response: %w", err)
+	}
+	return out.Token, nil
+}
@@ -0,0 +1,223 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FilterArgs holds the arguments of a route filter. Values keep the type
+// they are written with — strings, numbers, booleans, lists and maps — and
+// filters read them through the typed accessors, which report an argument
+// of the wrong type instead of mis-parsing it. Arguments decoded from JSON,
+// as the admin API does, hold their numbers as float64.
+type FilterArgs map[string]any
+
+// UnmarshalYAML decodes the arguments, keeping floating-point scalars as
+// written: a header value such as 1.10 must not become "1.1".
+func (a *FilterArgs) UnmarshalYAML(n *yaml.Node) error {
+	if n.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: filter args must be a mapping", n.Line)
+	}
+	args := make(FilterArgs, len(n.Content)/2)
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		v, err := decodeArg(n.Content[i+1])
+		if err != nil {
+			return err
+		}
+		args[n.Content[i].Value] = v
+	}
+	*a = args
+	return nil
+}
+
+func decodeArg(n *yaml.Node) (any, error) {
+	if n.Kind == yaml.AliasNode {
+		n = n.Alias
+	}
+	switch n.Kind {
+	case yaml.SequenceNode:
+		list := make([]any, 0, len(n.Content))
+		for _, c := range n.Content {
+			v, err := decodeArg(c)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		return list, nil
+	case yaml.ScalarNode:
+		if n.ShortTag() == "!!float" {
+			return n.Value, nil
+		}
+	}
+	var v any
+	err := n.Decode(&v)
+	return v, err
+}
+
+// Check reports the first argument, in name order, that is not one of known.
+func (a FilterArgs) Check(known ...string) error {
+	names := make([]string, 0, len(a))
+	for name := range a {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		found := false
+		for _, k := range known {
+			if name == k {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("unknown argument %q", name)
+		}
+	}
+	return nil
+}
+
+// Has reports whether the argument name is set to a value other than null
+// or "".
+func (a FilterArgs) Has(name string) bool {
+	v, ok := a[name]
+	return ok && v != nil && v != ""
+}
+
+// String returns the argument name as a string; an absent argument is "".
+// Numbers and booleans are formatted, so "value: 42" is "42".
+func (a FilterArgs) String(name string) (string, error) {
+	v, ok := a[name]
+	if !ok || v == nil {
+		return "", nil
+	}
+	s, ok := scalarString(v)
+	if !ok {
+		return "", fmt.Errorf("'%s' argument must be a string", name)
+	}
+	return s, nil
+}
+
+// Int returns the argument name as an integer, or def when it is not set.
+// A string holding an integer, such as "200", is accepted as well.
+func (a FilterArgs) Int(name string, def int) (int, error) {
+	if !a.Has(name) {
+		return def, nil
+	}
+	v := a[name]
+	switch n := v.(type) {
+	case int:
+		return n, nil
+	case int64:
+		return int(n), nil
+	case uint64:
+		if n <= math.MaxInt32 {
+			return int(n), nil
+		}
+	case float64:
+		if n == math.Trunc(n) && math.Abs(n) <= math.MaxInt32 {
+			return int(n), nil
+		}
+	case string:
+		if i, err := strconv.Atoi(strings.TrimSpace(n)); err == nil {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("'%s' argument must be an integer", name)
+}
+
+// Bool returns the argument name as a boolean, or def when it is not set. The
+// strings "true" and "false" are accepted as well.
+func (a FilterArgs) Bool(name string, def bool) (bool, error) {
+	if !a.Has(name) {
+		return def, nil
+	}
+	v := a[name]
+	switch b := v.(type) {
+	case bool:
+		return b, nil
+	case string:
+		if parsed, err := strconv.ParseBool(strings.TrimSpace(b)); err == nil {
+			return parsed, nil
+		}
+	}
+	return false, fmt.Errorf("'%s' argument must be true or false", name)
+}
+
+// Strings returns the argument name as a list of strings. It is written
+// either as a list or as a comma-separated string; items are trimmed and
+// empty items dropped. An absent argument is nil.
+func (a FilterArgs) Strings(name string) ([]string, error) {
+	v, ok := a[name]
+	if !ok || v == nil {
+		return nil, nil
+	}
+	var items []string
+	switch list := v.(type) {
+	case []any:
+		for _, item := range list {
+			s, ok := scalarString(item)
+			if !ok {
+				return nil, fmt.Errorf("'%s' argument must be a list of strings", name)
+			}
+			items = append(items, s)
+		}
+	case []string:
+		items = list
+	default:
+		s, ok := scalarString(v)
+		if !ok {
+			return nil, fmt.Errorf("'%s' argument must be a list of strings", name)
+		}
+		items = strings.Split(s, ",")
+	}
+	var out []string
+	for _, item := range items {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out, nil
+}
+
+// Size returns the argument name as a number of bytes, written as a Size
+// such as "2MiB" or as a plain number. An absent argument is zero.
+func (a FilterArgs) Size(name string) (int64, error) {
+	s, err := a.String(name)
+	if err != nil {
+		return 0, fmt.Errorf("'%s' argument must be a size such as 2MiB", name)
+	}
+	n, err := Size(s).Parse()
+	if err != nil {
+		return 0, fmt.Errorf("'%s' argument: %w", name, err)
+	}
+	if n < 0 {
+		return 0, fmt.Errorf("'%s' argument must not be negative", name)
+	}
+	return n, nil
+}
+
+// scalarString formats a scalar argument value; lists and maps are not
+// scalars.
+func scalarString(v any) (string, bool) {
+	switch s := v.(type) {
+	case string:
+		return s, true
+	case bool:
+		return strconv.FormatBool(s), true
+	case int:
+		return strconv.Itoa(s), true
+	case int64:
+		return strconv.FormatInt(s, 10), true
+	case uint64:
+		return strconv.FormatUint(s, 10), true
+	case float64:
+		return strconv.FormatFloat(s, 'f', -1, 64), true
+	}
+	return "", false
+}
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestFilterArgs_UnmarshalYAML(t *testing.T) {
+	var f RouteFilter
+	err := yaml.Unmarshal([]byte(`type: compress
+args:
+  level: 5
+  secure: false
+  version: 1.10
+  encodings: [gzip, deflate]
+  name: web
+`), &f)
+	if err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	want := FilterArgs{"level": 5, "secure": false, "version": "1.10", "encodings": []any{"gzip", "deflate"}, "name": "web"}
+	if !reflect.DeepEqual(f.Args, want) {
+		t.Errorf("args = %#v, want %#v", f.Args, want)
+	}
+}
+
+func TestFilterArgs_Accessors(t *testing.T) {
+	args := FilterArgs{
+		"int":      5,
+		"float":    float64(200), // as decoded from JSON
+		"numeric":  "404",
+		"bool":     true,
+		"boolText": "false",
+		"list":     []any{"gzip", " deflate ", ""},
+		"csv":      "id, user.name,",
+		"size":     "2KiB",
+		"bytes":    1024,
+		"map":      map[string]any{"a": "b"},
+		"empty":    "",
+	}
+	for name, want := range map[string]int{"int": 5, "float": 200, "numeric": 404, "missing": 7, "empty": 7} {
+		if got, err := args.Int(name, 7); err != nil || got != want {
+			t.Errorf("Int(%q) = %d, %v; want %d", name, got, err, want)
+		}
+	}
+	for name, want := range map[string]bool{"bool": true, "boolText": false, "missing": true} {
+		if got, err := args.Bool(name, true); err != nil || got != want {
+			t.Errorf("Bool(%q) = %v, %v; want %v", name, got, err, want)
+		}
+	}
+	if got, err := args.String("int"); err != nil || got != "5" {
+		t.Errorf(`String("int") = %q, %v`, got, err)
+	}
+	if got, _ := args.Strings("list"); !reflect.DeepEqual(got, []string{"gzip", "deflate"}) {
+		t.Errorf(`Strings("list") = %q`, got)
+	}
+	if got, _ := args.Strings("csv"); !reflect.DeepEqual(got, []string{"id", "user.name"}) {
+		t.Errorf(`Strings("csv") = %q`, got)
+	}
+	for name, want := range map[string]int64{"size": 2048, "bytes": 1024, "missing": 0} {
+		if got, err := args.Size(name); err != nil || got != want {
+			t.Errorf("Size(%q) = %d, %v; want %d", name, got, err, want)
+		}
+	}
+
+	for _, tt := range []struct {
+		name string
+		call func() error
+		want string
+	}{
+		{"int from text", func() error { _, err := args.Int("csv", 0); return err }, "'csv' argument must be an integer"},
+		{"int from bool", func() error { _, err := args.Int("bool", 0); return err }, "'bool' argument must be an integer"},
+		{"bool from int", func() error { _, err := args.Bool("int", false); return err }, "'int' argument must be true or false"},
+		{"string from list", func() error { _, err := args.String("list"); return err }, "'list' argument must be a string"},
+		{"strings from map", func() error { _, err := args.Strings("map"); return err }, "'map' argument must be a list of strings"},
+		{"size from list", func() error { _, err := args.Size("list"); return err }, "'list' argument must be a size"},
+		{"unknown", func() error { return args.Check("int", "float") }, `unknown argument "bool"`},
+	} {
+		if err := tt.call(); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: error = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+	if err := (FilterArgs{"a": 1}).Check("a", "b"); err != nil {
+		t.Errorf("Check: unexpected error %v", err)
+	}
+}
@@ -0,0 +1,51 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// HostNames returns match.host and match.hosts, lower-cased.
+func (m *RouteMatch) HostNames() []string {
+	var hosts []string
+	if m.Host != "" {
+		hosts = append(hosts, strings.ToLower(m.Host))
+	}
+	for _, h := range m.Hosts {
+		hosts = append(hosts, strings.ToLower(h))
+	}
+	return hosts
+}
+
+// validateRouteHosts validates the host criteria of a V2 route match.
+func validateRouteHosts(route string, m *RouteMatch) error {
+	if m.Host != "" {
+		if err := checkHost(m.Host); err != nil {
+			return fmt.Errorf("route_v2 %q: match.host: %w", route, err)
+		}
+	}
+	for j, h := range m.Hosts {
+		if err := checkHost(h); err != nil {
+			return fmt.Errorf("route_v2 %q: match.hosts[%d]: %w", route, j, err)
+		}
+	}
+	return nil
+}
+
+// checkHost validates an exact host or a "*.example.com" wildcard.
+func checkHost(host string) error {
+	name := host
+	if suffix, ok := strings.CutPrefix(host, "*."); ok {
+		name = suffix
+	}
+	if name == "" {
+		return fmt.Errorf("invalid host %q", host)
+	}
+	if strings.Contains(name, "*") {
+		return fmt.Errorf("invalid host %q, a wildcard is only allowed as a leading \"*.\"", host)
+	}
+	if strings.ContainsAny(name, ":/ ") {
+		return fmt.Errorf("invalid host %q, expected a host name without scheme, port or path", host)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRouteMatchHostNames(t *testing.T) {
+	m := RouteMatch{Host: "API.example.com", Hosts: []string{"*.Example.org"}}
+	want := []string{"api.example.com", "*.example.org"}
+	if got := m.HostNames(); !reflect.DeepEqual(got, want) {
+		t.Errorf("HostNames() = %v, want %v", got, want)
+	}
+	if got := (&RouteMatch{}).HostNames(); got != nil {
+		t.Errorf("HostNames() = %v, want nil", got)
+	}
+}
+
+func TestValidateV2_RouteHosts(t *testing.T) {
+	tests := []struct {
+		name  string
+		match RouteMatch
+		error string
+	}{
+		{"host", RouteMatch{Host: "api.example.com", PathPrefix: "/"}, ""},
+		{"wildcard", RouteMatch{Hosts: []string{"a.example.com", "*.example.com"}, PathPrefix: "/"}, ""},
+		{"bare wildcard", RouteMatch{Host: "*.", PathPrefix: "/"}, `route_v2 "r": match.host: invalid host "*."`},
+		{"inner wildcard", RouteMatch{Hosts: []string{"a.*.example.com"}, PathPrefix: "/"}, `match.hosts[0]: invalid host "a.*.example.com", a wildcard`},
+		{"port", RouteMatch{Host: "example.com:8080", PathPrefix: "/"}, "without scheme, port or path"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Server:   ServerConfig{Listen: ":8080"},
+				Clusters: []Cluster{{Name: "c", Type: "http", Endpoints: []ClusterEndpoint{{URL: "http://c:8080"}}}},
+				RoutesV2: []RouteV2{{Name: "r", Match: tt.match, Upstream: RouteUpstream{Cluster: "c"}}},
+			}
+			err := Validate(cfg)
+			if tt.error == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.error) {
+				t.Errorf("expected error containing %q, got %v", tt.error, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// HeaderName returns the key header, Idempotency-Key when it is not set.
+func (i *Idempotency) HeaderName() string {
+	if i.Header == "" {
+		return "Idempotency-Key"
+	}
+	return i.Header
+}
+
+// MethodList returns the deduplicated methods, POST and PATCH when none are
+// set.
+func (i *Idempotency) MethodList() []string {
+	if len(i.Methods) == 0 {
+		return []string{http.MethodPost, http.MethodPatch}
+	}
+	return i.Methods
+}
+
+// validateIdempotency checks the idempotency settings of a route.
+func validateIdempotency(i *Idempotency) error {
+	if i == nil {
+		return nil
+	}
+	if err := checkDuration("idempotency.window", i.Window, 0); err != nil {
+		return err
+	}
+	if i.Window != "" && i.WindowDuration() == 0 {
+		return fmt.Errorf("idempotency.window must be positive")
+	}
+	if i.MaxEntries < 0 {
+		return fmt.Errorf("idempotency.max_entries must not be negative")
+	}
+	if err := checkSize("idempotency.max_response", i.MaxResponse, "", 0); err != nil {
+		return err
+	}
+	for _, m := range i.Methods {
+		if m == "" || m != strings.ToUpper(m) {
+			return fmt.Errorf("idempotency.methods: invalid method %q", m)
+		}
+	}
+	return nil
+}
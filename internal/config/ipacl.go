@@ -0,0 +1,56 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// validateIPList checks a list of IP addresses and CIDRs, named by owner
+// in errors, e.g. "ip_acl.allow".
+func validateIPList(owner string, list []string) error {
+	for i, s := range list {
+		if strings.Contains(s, "/") {
+			if _, err := netip.ParsePrefix(s); err != nil {
+				return fmt.Errorf("%s[%d]: invalid CIDR %q", owner, i, s)
+			}
+		} else if _, err := netip.ParseAddr(s); err != nil {
+			return fmt.Errorf("%s[%d]: invalid IP %q", owner, i, s)
+		}
+	}
+	return nil
+}
+
+// validateIPACL checks an IP access list, named by owner in errors.
+func validateIPACL(owner string, acl *IPACL) error {
+	if acl == nil {
+		return nil
+	}
+	if len(acl.Allow) == 0 && len(acl.Deny) == 0 {
+		return fmt.Errorf("%s needs allow or deny entries", owner)
+	}
+	if err := validateIPList(owner+".allow", acl.Allow); err != nil {
+		return err
+	}
+	return validateIPList(owner+".deny", acl.Deny)
+}
+
+// validateIPACLArgs checks the arguments of an ip_acl filter.
+func validateIPACLArgs(args FilterArgs) error {
+	allow, err := args.Strings("allow")
+	if err != nil {
+		return err
+	}
+	deny, err := args.Strings("deny")
+	if err != nil {
+		return err
+	}
+	if len(allow) == 0 && len(deny) == 0 {
+		return errors.New("'allow' or 'deny' argument is required")
+	}
+	if err := validateIPList("allow", allow); err != nil {
+		return err
+	}
+	return validateIPList("deny", deny)
+}
@@ -0,0 +1,42 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidate_IPACL(t *testing.T) {
+	tests := []struct {
+		name    string
+		global  *IPACL
+		server  *IPACL
+		trusted []string
+		error   string
+	}{
+		{"none", nil, nil, nil, ""},
+		{"valid", &IPACL{Allow: []string{"10.0.0.0/8", "::1"}}, &IPACL{Deny: []string{"203.0.113.0/24"}}, []string{"10.0.0.1", "172.16.0.0/12"}, ""},
+		{"empty", &IPACL{}, nil, nil, "ip_acl needs allow or deny entries"},
+		{"bad allow", &IPACL{Allow: []string{"10.0.0.256"}}, nil, nil, `ip_acl.allow[0]: invalid IP "10.0.0.256"`},
+		{"bad listener deny", nil, &IPACL{Deny: []string{"::1", "fe80::/129"}}, nil, `server.ip_acl.deny[1]: invalid CIDR "fe80::/129"`},
+		{"bad trusted proxy", nil, nil, []string{"proxy.internal"}, `trusted_proxies[0]: invalid IP "proxy.internal"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Server:         ServerConfig{Listen: ":8080", IPACL: tt.server},
+				IPACL:          tt.global,
+				TrustedProxies: tt.trusted,
+			}
+			err := Validate(cfg)
+			if tt.error == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.error) {
+				t.Errorf("expected error containing %q, got %v", tt.error, err)
+			}
+		})
+	}
+}
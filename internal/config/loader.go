@@ -1,10 +1,16 @@
 package config
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
+	"path/filepath"
 	"sync/atomic"
+	"time"
 
 	"github.com/fsnotify/fsnotify"
 	"gopkg.in/yaml.v3"
@@ -14,31 +20,206 @@ import (
 type Loader struct {
 	path    string
 	current atomic.Value // stores *Config
+	// persisted is the hash of the content last written by Persist, so the
+	// watcher does not reload the loader's own writes.
+	persisted atomic.Pointer[[sha256.Size]byte]
+	// dir is set when path is a directory of configuration files.
+	dir atomic.Bool
+	// source replaces the file when the configuration is stored remotely;
+	// raw is the document last loaded from it.
+	source Source
+	raw    atomic.Pointer[[]byte]
+	// secrets resolves secret references; refs holds the values of the
+	// current configuration that were written as secret or environment
+	// variable references.
+	secrets *SecretResolver
+	refs    atomic.Pointer[map[refPath]valueRef]
+	// lenient logs unknown fields instead of rejecting the configuration.
+	lenient atomic.Bool
+}
+
+// Persister stores the effective configuration, so that changes made
+// through the admin API survive a restart. data is cfg serialized as YAML.
+type Persister interface {
+	Persist(cfg *Config, data []byte) error
 }
 
 // NewLoader creates a new configuration loader for the given file path.
 func NewLoader(path string) *Loader {
-	return &Loader{path: path}
+	return &Loader{path: path, secrets: NewSecretResolver()}
+}
+
+// NewSourceLoader creates a configuration loader reading from a remote
+// source.
+func NewSourceLoader(src Source) *Loader {
+	return &Loader{path: src.String(), source: src, secrets: NewSecretResolver()}
+}
+
+// SetStrict sets whether unknown fields, typically misspelled keys, reject
+// the configuration. Loaders are strict by default; otherwise unknown
+// fields are logged and ignored.
+func (l *Loader) SetStrict(strict bool) {
+	l.lenient.Store(!strict)
+}
+
+// Secrets returns the resolver of secret references, to register
+// additional providers before loading.
+func (l *Loader) Secrets() *SecretResolver {
+	return l.secrets
 }
 
-// Load reads and parses the configuration file.
+// reloadDebounce is how long the watcher waits for further file events
+// before reloading, since editors typically write a file in several steps.
+var reloadDebounce = 100 * time.Millisecond
+
+// sourceFetchTimeout bounds the initial fetch from a remote source.
+const sourceFetchTimeout = 30 * time.Second
+
+// Load reads and parses the configuration file. When the path is a
+// directory, its *.yaml and *.yml files are merged into one configuration,
+// so that teams can own separate route files.
 func (l *Loader) Load() (*Config, error) {
+	if l.source != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), sourceFetchTimeout)
+		defer cancel()
+		data, err := l.source.Fetch(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("fetch config from %s: %w", l.source, err)
+		}
+		return l.loadSource(data)
+	}
+	if fi, err := os.Stat(l.path); err == nil && fi.IsDir() {
+		l.dir.Store(true)
+		return l.loadDir()
+	}
 	data, err := os.ReadFile(l.path)
 	if err != nil {
 		return nil, fmt.Errorf("read config file: %w", err)
 	}
+	return l.load(data)
+}
+
+func (l *Loader) load(data []byte) (*Config, error) {
+	cfg, refs, err := parse(data, l.secrets, l.lenient.Load())
+	if err != nil {
+		return nil, err
+	}
+	l.current.Store(cfg)
+	l.refs.Store(&refs)
+	return cfg, nil
+}
 
-	var cfg Config
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
-		return nil, fmt.Errorf("parse config file: %w", err)
+// Parse reads a complete configuration document, YAML or JSON, the way Load
+// reads a file: environment variable references are expanded, secret
+// references resolved with the built-in providers, unknown fields rejected
+// and the result validated. Errors point at the offending line and column;
+// validation failures are *ValidationError.
+func Parse(data []byte) (*Config, error) {
+	cfg, _, err := parse(data, NewSecretResolver(), false)
+	return cfg, err
+}
+
+// parse expands, resolves and decodes data. It returns the configuration
+// with the values that were written as secret or environment variable
+// references.
+func parse(data []byte, secrets *SecretResolver, lenient bool) (*Config, map[refPath]valueRef, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, nil, fmt.Errorf("parse config file: %w", err)
 	}
+	nodes := make(map[*yaml.Node]string)
+	if err := expandEnv(&doc, os.LookupEnv, nodes); err != nil {
+		return nil, nil, fmt.Errorf("expand config file: %w", err)
+	}
+	return decode(&document{root: &doc}, secrets, lenient, nodes)
+}
 
+// decode resolves the secret references of a parsed document, checks it
+// for unknown fields, which are only logged when lenient, and validates it.
+// nodes holds the text of the nodes already expanded from the environment.
+// Errors point at the offending line and column.
+func decode(doc *document, secrets *SecretResolver, lenient bool, nodes map[*yaml.Node]string) (*Config, map[refPath]valueRef, error) {
+	if err := secrets.resolve(doc.root, nodes); err != nil {
+		return nil, nil, fmt.Errorf("resolve secrets: %w", err)
+	}
+	if errs := doc.unknownFields(); len(errs) > 0 {
+		if !lenient {
+			return nil, nil, fmt.Errorf("parse config file: %w", errors.Join(errs...))
+		}
+		for _, err := range errs {
+			slog.Warn("ignoring unknown config field", slog.String("error", err.Error()))
+		}
+	}
+	var cfg Config
+	if err := doc.root.Decode(&cfg); err != nil {
+		return nil, nil, fmt.Errorf("parse config file: %w", err)
+	}
 	if err := Validate(&cfg); err != nil {
-		return nil, fmt.Errorf("validate config: %w", err)
+		verr := &ValidationError{Err: err}
+		if n := doc.locate(err); n != nil {
+			verr.Position = doc.position(n)
+		}
+		return nil, nil, verr
+	}
+	return &cfg, refPaths(doc.root, nodes), nil
+}
+
+func (l *Loader) loadSource(data []byte) (*Config, error) {
+	cfg, err := l.load(data)
+	if err == nil {
+		l.raw.Store(&data)
+	}
+	return cfg, err
+}
+
+func (l *Loader) loadDir() (*Config, error) {
+	doc, err := mergeDir(l.path)
+	if err != nil {
+		return nil, err
+	}
+	cfg, refs, err := decode(doc, l.secrets, l.lenient.Load(), make(map[*yaml.Node]string))
+	if err != nil {
+		return nil, err
 	}
+	l.current.Store(cfg)
+	l.refs.Store(&refs)
+	return cfg, nil
+}
+
+// Path returns the path of the configuration file or directory.
+func (l *Loader) Path() string {
+	return l.path
+}
 
-	l.current.Store(&cfg)
-	return &cfg, nil
+// IsDir reports whether the configuration was loaded from a directory.
+func (l *Loader) IsDir() bool {
+	return l.dir.Load()
+}
+
+// IsFile reports whether the configuration is read from a single local file,
+// the only kind Persist can write to.
+func (l *Loader) IsFile() bool {
+	return l.source == nil && !l.IsDir()
+}
+
+// Raw returns the source of the current configuration for versioning: the
+// file content or remote document, or for a directory the merged
+// configuration as YAML.
+func (l *Loader) Raw() ([]byte, error) {
+	if l.source != nil {
+		if raw := l.raw.Load(); raw != nil {
+			return *raw, nil
+		}
+		return nil, fmt.Errorf("no configuration loaded")
+	}
+	if !l.IsDir() {
+		return os.ReadFile(l.path)
+	}
+	cfg := l.Current()
+	if cfg == nil {
+		return nil, fmt.Errorf("no configuration loaded")
+	}
+	return yaml.Marshal(cfg)
 }
 
 // Current returns the currently loaded configuration.
@@ -50,41 +231,181 @@ func (l *Loader) Current() *Config {
 	return v.(*Config)
 }
 
+// Set replaces the current configuration, e.g. after a change made through
+// the admin API. The file is not rewritten, so the next file change
+// overrides it.
+func (l *Loader) Set(cfg *Config) {
+	l.current.Store(cfg)
+}
+
+// Persist atomically replaces the configuration file with data and makes cfg
+// the current configuration. Resolved secrets and values expanded from
+// environment variables are written back as their references, so that
+// neither credentials nor per-environment values end up in the file. The
+// watcher recognizes the write and does not reload it.
+func (l *Loader) Persist(cfg *Config, data []byte) error {
+	if l.IsDir() {
+		return fmt.Errorf("cannot persist to config directory %s", l.path)
+	}
+	if l.source != nil {
+		return fmt.Errorf("cannot persist to remote config source %s", l.path)
+	}
+	if refs := l.refs.Load(); refs != nil {
+		var err error
+		if data, err = restoreRefs(data, *refs); err != nil {
+			return fmt.Errorf("restore references: %w", err)
+		}
+	}
+	mode := os.FileMode(0o644)
+	if fi, err := os.Stat(l.path); err == nil {
+		mode = fi.Mode().Perm()
+	}
+	dir, base := filepath.Split(l.path)
+	if dir == "" {
+		dir = "."
+	}
+	tmp, err := os.CreateTemp(dir, "."+base+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("create temporary config file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), mode)
+	}
+	if err != nil {
+		return fmt.Errorf("write temporary config file: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	l.persisted.Store(&sum)
+	if err := os.Rename(tmp.Name(), l.path); err != nil {
+		l.persisted.Store(nil)
+		return fmt.Errorf("replace config file: %w", err)
+	}
+	l.current.Store(cfg)
+	return nil
+}
+
 // Watch starts watching the configuration file for changes and calls onChange
-// when the file is modified. It blocks until the done channel is closed.
+// when the file is modified. A burst of file events results in one reload.
+// It blocks until the done channel is closed.
 func (l *Loader) Watch(onChange func(*Config), done <-chan struct{}) error {
+	if l.source != nil {
+		l.watchSource(onChange, done)
+		return nil
+	}
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
 		return fmt.Errorf("create file watcher: %w", err)
 	}
 	defer watcher.Close()
 
-	if err := watcher.Add(l.path); err != nil {
-		return fmt.Errorf("watch config file: %w", err)
+	if l.IsDir() {
+		return l.watchDir(watcher, onChange, done)
+	}
+
+	// Watch the directory rather than the file, so that the watch survives
+	// the file being replaced by a rename, as Persist and many editors do.
+	dir := filepath.Dir(l.path)
+	if err := watcher.Add(dir); err != nil {
+		return fmt.Errorf("watch config directory: %w", err)
 	}
+	name := filepath.Clean(l.path)
 
 	slog.Info("watching config file for changes", slog.String("path", l.path))
 
+	var pending <-chan time.Time // fires once events have settled
 	for {
 		select {
 		case event, ok := <-watcher.Events:
 			if !ok {
 				return nil
 			}
+			if filepath.Clean(event.Name) != name {
+				continue
+			}
 			if event.Has(fsnotify.Write) || event.Has(fsnotify.Create) {
-				slog.Info("config file changed, reloading", slog.String("path", l.path))
-				cfg, err := l.Load()
-				if err != nil {
-					slog.Error("failed to reload config, keeping current",
-						slog.String("error", err.Error()),
-					)
-					continue
-				}
-				if onChange != nil {
-					onChange(cfg)
-				}
-				slog.Info("config reloaded successfully")
+				pending = time.After(reloadDebounce)
+			}
+		case <-pending:
+			pending = nil
+			data, err := os.ReadFile(l.path)
+			if err != nil {
+				slog.Error("failed to read config, keeping current",
+					slog.String("error", err.Error()),
+				)
+				continue
+			}
+			if p := l.persisted.Load(); p != nil && *p == sha256.Sum256(data) {
+				continue // written by Persist, already current
+			}
+			slog.Info("config file changed, reloading", slog.String("path", l.path))
+			cfg, err := l.load(data)
+			if err != nil {
+				slog.Error("failed to reload config, keeping current",
+					slog.String("error", err.Error()),
+				)
+				continue
+			}
+			if onChange != nil {
+				onChange(cfg)
+			}
+			slog.Info("config reloaded successfully")
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			slog.Error("config watcher error", slog.String("error", err.Error()))
+		case <-done:
+			return nil
+		}
+	}
+}
+
+// watchDir reloads a configuration directory when one of its files is
+// written, added, removed or renamed.
+func (l *Loader) watchDir(watcher *fsnotify.Watcher, onChange func(*Config), done <-chan struct{}) error {
+	if err := watcher.Add(l.path); err != nil {
+		return fmt.Errorf("watch config directory: %w", err)
+	}
+
+	slog.Info("watching config directory for changes", slog.String("path", l.path))
+
+	var pending <-chan time.Time // fires once events have settled
+	var changed string           // the file changed last
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if !isConfigFile(event.Name) || event.Has(fsnotify.Chmod) && !event.Has(fsnotify.Write) {
+				continue
+			}
+			changed = filepath.Base(event.Name)
+			pending = time.After(reloadDebounce)
+		case <-pending:
+			pending = nil
+			slog.Info("config directory changed, reloading",
+				slog.String("path", l.path),
+				slog.String("file", changed),
+			)
+			cfg, err := l.loadDir()
+			if err != nil {
+				slog.Error("failed to reload config, keeping current",
+					slog.String("error", err.Error()),
+				)
+				continue
+			}
+			if onChange != nil {
+				onChange(cfg)
 			}
+			slog.Info("config reloaded successfully")
 		case err, ok := <-watcher.Errors:
 			if !ok {
 				return nil
@@ -95,3 +416,36 @@ func (l *Loader) Watch(onChange func(*Config), done <-chan struct{}) error {
 		}
 	}
 }
+
+// watchSource reloads the configuration when the remote document changes.
+func (l *Loader) watchSource(onChange func(*Config), done <-chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	slog.Info("watching config source for changes", slog.String("source", l.path))
+
+	l.source.Watch(ctx, func(data []byte) {
+		if raw := l.raw.Load(); raw != nil && bytes.Equal(*raw, data) {
+			return
+		}
+		slog.Info("config source changed, reloading", slog.String("source", l.path))
+		cfg, err := l.loadSource(data)
+		if err != nil {
+			slog.Error("failed to reload config, keeping current",
+				slog.String("error", err.Error()),
+			)
+			return
+		}
+		if onChange != nil {
+			onChange(cfg)
+		}
+		slog.Info("config reloaded successfully")
+	})
+}
@@ -1,9 +1,11 @@
 package config
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestLoadValidConfig(t *testing.T) {
@@ -93,6 +95,38 @@ routes: []
 	}
 }
 
+func TestParse(t *testing.T) {
+	t.Setenv("NEXUS_TEST_LISTEN", ":8080")
+	secret := filepath.Join(t.TempDir(), "key")
+	if err := os.WriteFile(secret, []byte("s3cret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Parse([]byte(`
+server:
+  listen: "${NEXUS_TEST_LISTEN}"
+auth:
+  api_key:
+    enabled: true
+    keys:
+      "file:` + secret + `": ops
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Server.Listen != ":8080" || cfg.Auth.APIKey.Keys["s3cret"] != "ops" {
+		t.Errorf("references not resolved: listen %q, keys %v", cfg.Server.Listen, cfg.Auth.APIKey.Keys)
+	}
+
+	if _, err := Parse([]byte("server:\n  listen: \":8080\"\n  bogus: 1\n")); err == nil {
+		t.Error("expected an unknown field to be rejected")
+	}
+	_, err = Parse([]byte("server:\n  listen: \"\"\n"))
+	var verr *ValidationError
+	if !errors.As(err, &verr) || verr.Position != "line 2, column 3" {
+		t.Errorf("expected a located validation error, got %v", err)
+	}
+}
+
 func TestCurrentReturnsNilBeforeLoad(t *testing.T) {
 	loader := NewLoader("nonexistent.yaml")
 	if loader.Current() != nil {
@@ -100,6 +134,102 @@ func TestCurrentReturnsNilBeforeLoad(t *testing.T) {
 	}
 }
 
+func TestSetReplacesCurrent(t *testing.T) {
+	loader := NewLoader("nonexistent.yaml")
+	cfg := &Config{Server: ServerConfig{Listen: ":8080"}}
+	loader.Set(cfg)
+	if loader.Current() != cfg {
+		t.Error("Current() should return the configuration passed to Set()")
+	}
+}
+
+const persistTestConfig = `server:
+  listen: ":8080"
+upstreams:
+  - name: backend
+    targets:
+      - address: "127.0.0.1:9001"
+routes:
+  - name: api
+    paths:
+      - path: /
+        type: prefix
+    upstream: backend
+`
+
+func TestPersist(t *testing.T) {
+	path := writeTemp(t, persistTestConfig)
+	if err := os.Chmod(path, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	loader := NewLoader(path)
+	cfg, err := loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	next := *cfg
+	next.Server.Listen = ":9999"
+	if err := loader.Persist(&next, []byte("server:\n  listen: \":9999\"\n")); err != nil {
+		t.Fatal(err)
+	}
+	if loader.Current() != &next {
+		t.Error("Persist did not make the configuration current")
+	}
+	data, _ := os.ReadFile(path)
+	if string(data) != "server:\n  listen: \":9999\"\n" {
+		t.Errorf("unexpected file content %q", data)
+	}
+	if fi, _ := os.Stat(path); fi.Mode().Perm() != 0o600 {
+		t.Errorf("file mode changed to %v", fi.Mode().Perm())
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Errorf("temporary files left behind: %v", entries)
+	}
+
+	if err := NewLoader(filepath.Join(t.TempDir(), "missing", "config.yaml")).Persist(&next, nil); err == nil {
+		t.Error("expected an error for a missing directory")
+	}
+}
+
+func TestWatch_IgnoresPersistedWrites(t *testing.T) {
+	path := writeTemp(t, persistTestConfig)
+	loader := NewLoader(path)
+	cfg, err := loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes := make(chan *Config, 10)
+	done := make(chan struct{})
+	defer close(done)
+	go loader.Watch(func(c *Config) { changes <- c }, done)
+	time.Sleep(100 * time.Millisecond) // let the watcher start
+
+	if err := loader.Persist(cfg, []byte(persistTestConfig+"# persisted\n")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-changes:
+		t.Fatal("persisted write was reloaded")
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	// The watch survives the rename and picks up later changes, including
+	// files replaced by a rename.
+	tmp := path + ".new"
+	os.WriteFile(tmp, []byte(persistTestConfig+"logging:\n  level: debug\n"), 0o644)
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case c := <-changes:
+		if c.Logging.Level != "debug" {
+			t.Errorf("unexpected reloaded config %+v", c.Logging)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("external change not reloaded")
+	}
+}
+
 func writeTemp(t *testing.T, content string) string {
 	t.Helper()
 	dir := t.TempDir()
@@ -109,3 +239,43 @@ func writeTemp(t *testing.T, content string) string {
 	}
 	return path
 }
+
+func TestWatch_DebouncesEvents(t *testing.T) {
+	path := writeTemp(t, persistTestConfig)
+	loader := NewLoader(path)
+	if _, err := loader.Load(); err != nil {
+		t.Fatal(err)
+	}
+	changes := make(chan *Config, 10)
+	done := make(chan struct{})
+	defer close(done)
+	go loader.Watch(func(c *Config) { changes <- c }, done)
+	time.Sleep(100 * time.Millisecond) // let the watcher start
+
+	// An editor saving in several writes, briefly leaving the file
+	// invalid, causes one reload of the final content.
+	content := persistTestConfig + "logging:\n  level: debug\n"
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < len(content); i += 16 {
+		f.WriteString(content[i:min(i+16, len(content))])
+		time.Sleep(2 * time.Millisecond)
+	}
+	f.Close()
+
+	select {
+	case c := <-changes:
+		if c.Logging.Level != "debug" {
+			t.Errorf("unexpected reloaded config %+v", c.Logging)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("change not reloaded")
+	}
+	select {
+	case <-changes:
+		t.Error("expected a single reload")
+	case <-time.After(300 * time.Millisecond):
+	}
+}
@@ -0,0 +1,55 @@
+package config
+
+import "fmt"
+
+// validTier reports whether tier names a load shedding priority tier.
+func validTier(tier string) bool {
+	switch tier {
+	case "critical", "high", "normal", "low":
+		return true
+	}
+	return false
+}
+
+// validateLoadShedding checks the load shedding settings.
+func validateLoadShedding(ls *LoadShedding) error {
+	if ls == nil {
+		return nil
+	}
+	if ls.MaxInFlight < 0 {
+		return fmt.Errorf("load_shedding.max_in_flight must not be negative")
+	}
+	if err := checkDuration("load_shedding.max_latency", ls.MaxLatency, 0); err != nil {
+		return err
+	}
+	if ls.MaxInFlight == 0 && ls.MaxLatency == "" {
+		return fmt.Errorf("load_shedding: max_in_flight or max_latency is required")
+	}
+	if ls.DefaultTier != "" && !validTier(ls.DefaultTier) {
+		return fmt.Errorf("load_shedding.default_tier: unsupported tier %q, must be 'critical', 'high', 'normal' or 'low'", ls.DefaultTier)
+	}
+	return nil
+}
+
+// validateWatchdog checks the watchdog thresholds.
+func validateWatchdog(w *Watchdog) error {
+	if w == nil {
+		return nil
+	}
+	if err := checkDuration("watchdog.interval", w.Interval, 0); err != nil {
+		return err
+	}
+	if err := checkSize("watchdog.max_heap", w.MaxHeap, "", 0); err != nil {
+		return err
+	}
+	if w.MaxGoroutines < 0 {
+		return fmt.Errorf("watchdog.max_goroutines must not be negative")
+	}
+	if err := checkDuration("watchdog.max_lag", w.MaxLag, 0); err != nil {
+		return err
+	}
+	if w.HeapLimit() == 0 && w.MaxGoroutines == 0 && w.LagLimit() == 0 {
+		return fmt.Errorf("watchdog: max_heap, max_goroutines or max_lag is required")
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// DefaultLogSamplingInterval is the window of log sampling when
+// logging.sampling.interval is not set.
+const DefaultLogSamplingInterval = time.Second
+
+// ParseLogLevel returns the level named s: "debug", "info", "warn" (or
+// "warning") or "error", in any case. Empty is info.
+func ParseLogLevel(s string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return 0, fmt.Errorf("unknown log level %q, expected debug, info, warn or error", s)
+}
+
+// LogLevel returns the configured log level; an invalid level, which
+// Validate rejects, counts as info.
+func (l *LoggingConfig) LogLevel() slog.Level {
+	level, err := ParseLogLevel(l.Level)
+	if err != nil {
+		return slog.LevelInfo
+	}
+	return level
+}
+
+// SampleInterval returns the window of the sampling.
+func (s *LogSampling) SampleInterval() time.Duration {
+	if v := durationOr(s.Interval, 0); v > 0 {
+		return v
+	}
+	return DefaultLogSamplingInterval
+}
+
+// validateLogging checks the log level, format and sampling.
+func validateLogging(l *LoggingConfig) error {
+	if _, err := ParseLogLevel(l.Level); err != nil {
+		return fmt.Errorf("logging.level: %w", err)
+	}
+	switch l.Format {
+	case "", "json", "text":
+	default:
+		return fmt.Errorf("logging.format must be json or text, got %q", l.Format)
+	}
+	if s := l.Sampling; s != nil {
+		if err := checkDuration("logging.sampling.interval", s.Interval, 0); err != nil {
+			return err
+		}
+		if s.First < 0 || s.Thereafter < 0 {
+			return fmt.Errorf("logging.sampling.first and thereafter must not be negative")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Response returns the status, content type and body of the maintenance
+// response.
+func (m *Maintenance) Response() (int, string, []byte) {
+	status, contentType, body := m.Status, m.ContentType, m.Body
+	if status == 0 {
+		status = http.StatusServiceUnavailable
+	}
+	if body == "" {
+		body = `{"error":"maintenance","message":"service under maintenance"}`
+	}
+	if contentType == "" {
+		contentType = "application/json"
+		if strings.HasPrefix(strings.TrimSpace(body), "<") {
+			contentType = "text/html; charset=utf-8"
+		}
+	}
+	return status, contentType, []byte(body)
+}
+
+// validateMaintenance checks the maintenance switch at path.
+func validateMaintenance(path string, m *Maintenance) error {
+	if m == nil {
+		return nil
+	}
+	if m.Status != 0 && (m.Status < 200 || m.Status > 599) {
+		return fmt.Errorf("%s.status %d is not a valid HTTP status", path, m.Status)
+	}
+	return checkDuration(path+".retry_after", m.RetryAfter, 0)
+}
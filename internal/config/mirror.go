@@ -0,0 +1,21 @@
+package config
+
+import "fmt"
+
+// validateMirror checks the traffic mirroring of an upstream.
+func validateMirror(u *RouteUpstream, clusterNames map[string]bool) error {
+	m := u.Mirror
+	if u.Mock != nil || u.GRPC != nil || u.Dubbo != nil || u.GraphQL != nil {
+		return fmt.Errorf("upstream.mirror cannot be combined with mock, grpc, dubbo or graphql")
+	}
+	if m.Cluster == "" {
+		return fmt.Errorf("upstream.mirror.cluster is required")
+	}
+	if len(clusterNames) > 0 && !clusterNames[m.Cluster] {
+		return fmt.Errorf("upstream.mirror references unknown cluster %q", m.Cluster)
+	}
+	if m.Percent <= 0 || m.Percent > 100 {
+		return fmt.Errorf("upstream.mirror.percent must be above 0 and at most 100, got %v", m.Percent)
+	}
+	return checkSize("upstream.mirror.max_body", m.MaxBody, "", 0)
+}
@@ -0,0 +1,78 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/oriys/nexus/internal/pathmatch"
+)
+
+// Pattern compiles the path rule when it is a regex or a template. It
+// returns nil for literal exact and prefix paths.
+func (p *PathRule) Pattern() (*pathmatch.Pattern, error) {
+	switch {
+	case p.Type == "regex":
+		return pathmatch.Regex(p.Path)
+	case pathmatch.IsTemplate(p.Path):
+		return pathmatch.Template(p.Path, p.Type == "prefix")
+	}
+	return nil, nil
+}
+
+// Pattern compiles match.path_regex, or match.path or match.path_prefix
+// when it is a template. It returns nil when the route matches literal
+// paths only.
+func (m *RouteMatch) Pattern() (*pathmatch.Pattern, error) {
+	switch {
+	case m.PathRegex != "":
+		return pathmatch.Regex(m.PathRegex)
+	case pathmatch.IsTemplate(m.Path):
+		return pathmatch.Template(m.Path, false)
+	case pathmatch.IsTemplate(m.PathPrefix):
+		return pathmatch.Template(m.PathPrefix, true)
+	}
+	return nil, nil
+}
+
+// validatePathRule validates paths[j] of the legacy route named route.
+func validatePathRule(route string, j int, p *PathRule) error {
+	switch p.Type {
+	case "exact", "prefix", "regex":
+	default:
+		return fmt.Errorf("route %q paths[%d].type must be 'exact', 'prefix' or 'regex', got %q", route, j, p.Type)
+	}
+	if _, err := p.Pattern(); err != nil {
+		return fmt.Errorf("route %q paths[%d].path: %w", route, j, err)
+	}
+	return nil
+}
+
+// validateRouteMatchPath validates the path rules of a V2 route match.
+func validateRouteMatchPath(route string, m *RouteMatch) error {
+	set := 0
+	for _, s := range []string{m.Path, m.PathPrefix, m.PathRegex} {
+		if s != "" {
+			set++
+		}
+	}
+	if set == 0 {
+		return fmt.Errorf("route_v2 %q: match.path or match.path_prefix is required", route)
+	}
+	p, err := m.Pattern()
+	if p == nil && err == nil {
+		return nil
+	}
+	if set > 1 {
+		return fmt.Errorf("route_v2 %q: match.path_regex and path templates cannot be combined with another path rule", route)
+	}
+	if err != nil {
+		field := "match.path"
+		switch {
+		case m.PathRegex != "":
+			field = "match.path_regex"
+		case m.PathPrefix != "":
+			field = "match.path_prefix"
+		}
+		return fmt.Errorf("route_v2 %q: %s: %w", route, field, err)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidatePathRules(t *testing.T) {
+	tests := []struct {
+		name  string
+		rule  PathRule
+		error string
+	}{
+		{"exact template", PathRule{Path: "/users/{id}", Type: "exact"}, ""},
+		{"prefix template", PathRule{Path: "/users/{id}/", Type: "prefix"}, ""},
+		{"regex", PathRule{Path: `/items/(?P<id>\d+)`, Type: "regex"}, ""},
+		{"bad regex", PathRule{Path: "/items/(", Type: "regex"}, `route "r" paths[0].path: error parsing regexp`},
+		{"bad template", PathRule{Path: "/users/{id", Type: "exact"}, `route "r" paths[0].path: unterminated parameter`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Server:    ServerConfig{Listen: ":8080"},
+				Upstreams: []Upstream{{Name: "backend", Targets: []Target{{Address: "127.0.0.1:80"}}}},
+				Routes:    []Route{{Name: "r", Upstream: "backend", Paths: []PathRule{tt.rule}}},
+			}
+			err := Validate(cfg)
+			if tt.error == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.error) {
+				t.Errorf("expected error containing %q, got %v", tt.error, err)
+			}
+		})
+	}
+}
+
+func TestValidateV2_PathPatterns(t *testing.T) {
+	tests := []struct {
+		name    string
+		match   RouteMatch
+		filters []RouteFilter
+		error   string
+	}{
+		{"template", RouteMatch{Path: "/users/{id}/orders/{oid}"}, nil, ""},
+		{"prefix template", RouteMatch{PathPrefix: "/tenants/{tenant}/"}, nil, ""},
+		{"regex", RouteMatch{PathRegex: `/items/(?P<sku>[A-Z]+)`}, nil, ""},
+		{"bad regex", RouteMatch{PathRegex: "/items/["}, nil, `route_v2 "r": match.path_regex: error parsing regexp`},
+		{"bad template", RouteMatch{Path: "/users/{1}"}, nil, `route_v2 "r": match.path: invalid parameter name "1"`},
+		{"bad prefix template", RouteMatch{PathPrefix: "/users/{id}/{id}"}, nil, `route_v2 "r": match.path_prefix: duplicate parameter "id"`},
+		{"regex with prefix", RouteMatch{PathRegex: "/a", PathPrefix: "/b"}, nil, "cannot be combined"},
+		{"rewrite_path", RouteMatch{Path: "/users/{id}"}, []RouteFilter{{Type: "rewrite_path", Args: FilterArgs{"path": "/u/{id}"}}}, ""},
+		{"rewrite_path without path", RouteMatch{Path: "/users/{id}"}, []RouteFilter{{Type: "rewrite_path"}}, "(rewrite_path): 'path' argument is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Server:   ServerConfig{Listen: ":8080"},
+				Clusters: []Cluster{{Name: "c", Type: "http", Endpoints: []ClusterEndpoint{{URL: "http://c:8080"}}}},
+				RoutesV2: []RouteV2{{Name: "r", Match: tt.match, Filters: tt.filters, Upstream: RouteUpstream{Cluster: "c"}}},
+			}
+			err := Validate(cfg)
+			if tt.error == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.error) {
+				t.Errorf("expected error containing %q, got %v", tt.error, err)
+			}
+		})
+	}
+}
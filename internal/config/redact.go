@@ -0,0 +1,118 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+
+	"github.com/oriys/nexus/internal/redact"
+)
+
+// Redactor returns the redactor logging.redact configures.
+func (c *Config) Redactor() *redact.Redactor {
+	return redact.New(c.Logging.Redact.Headers, c.Logging.Redact.Fields)
+}
+
+// Redacted returns a copy of cfg to show, e.g. from the admin API, with its
+// secrets masked: API keys, passwords in cluster endpoint URLs and of the
+// response cache's Redis, signing keys, and the values filters set on
+// sensitive headers. Admin tokens and TLS keys are
+// never serialized to JSON in the first place.
+func Redacted(cfg *Config) *Config {
+	if cfg == nil {
+		return nil
+	}
+	r := cfg.Redactor()
+	out := *cfg
+	if len(cfg.Auth.APIKey.Keys) > 0 {
+		// Keys are replaced by numbered markers, ordered by consumer so the
+		// output is stable.
+		keys := make([]string, 0, len(cfg.Auth.APIKey.Keys))
+		for k := range cfg.Auth.APIKey.Keys {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			ci, cj := cfg.Auth.APIKey.Keys[keys[i]], cfg.Auth.APIKey.Keys[keys[j]]
+			return ci < cj || ci == cj && keys[i] < keys[j]
+		})
+		out.Auth.APIKey.Keys = make(map[string]string, len(keys))
+		for i, k := range keys {
+			out.Auth.APIKey.Keys[fmt.Sprintf("%s-%d", redact.Mask, i+1)] = cfg.Auth.APIKey.Keys[k]
+		}
+	}
+	if cfg.Clusters != nil {
+		out.Clusters = make([]Cluster, len(cfg.Clusters))
+	}
+	for i, c := range cfg.Clusters {
+		c.Endpoints = append([]ClusterEndpoint(nil), c.Endpoints...)
+		for j := range c.Endpoints {
+			c.Endpoints[j].URL = redactURLPassword(c.Endpoints[j].URL)
+		}
+		out.Clusters[i] = c
+	}
+	if cfg.RoutesV2 != nil {
+		out.RoutesV2 = make([]RouteV2, len(cfg.RoutesV2))
+	}
+	for i, route := range cfg.RoutesV2 {
+		route.Filters = redactFilters(r, route.Filters)
+		out.RoutesV2[i] = route
+	}
+	if rc := cfg.ResponseCache; rc != nil && rc.Redis != nil && rc.Redis.Password != "" {
+		c, redis := *rc, *rc.Redis
+		redis.Password = redact.Mask
+		c.Redis = &redis
+		out.ResponseCache = &c
+	}
+	if cfg.Defaults != nil {
+		d := *cfg.Defaults
+		d.Filters = redactFilters(r, d.Filters)
+		out.Defaults = &d
+	}
+	if cfg.FilterChains != nil {
+		out.FilterChains = make(map[string][]RouteFilter, len(cfg.FilterChains))
+		for name, chain := range cfg.FilterChains {
+			out.FilterChains[name] = redactFilters(r, chain)
+		}
+	}
+	return &out
+}
+
+// redactFilters returns filters with the values of header filters setting
+// sensitive headers masked.
+func redactFilters(r *redact.Redactor, filters []RouteFilter) []RouteFilter {
+	if filters == nil {
+		return nil
+	}
+	out := make([]RouteFilter, len(filters))
+	for i, f := range filters {
+		switch f.Type {
+		case "header_set", "response_header_add", "response_header_set":
+			if key, _ := f.Args.String("key"); r.SensitiveHeader(key) && f.Args.Has("value") {
+				f.Args = maskArg(f.Args, "value")
+			}
+		case "sign_request":
+			f.Args = maskArg(f.Args, "key")
+		}
+		out[i] = f
+	}
+	return out
+}
+
+// maskArg returns a copy of args with the argument name masked.
+func maskArg(args FilterArgs, name string) FilterArgs {
+	out := make(FilterArgs, len(args))
+	for k, v := range args {
+		out[k] = v
+	}
+	out[name] = redact.Mask
+	return out
+}
+
+// redactURLPassword masks the password of a URL, if it has one.
+func redactURLPassword(s string) string {
+	u, err := url.Parse(s)
+	if err != nil {
+		return s
+	}
+	return u.Redacted()
+}
@@ -0,0 +1,46 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRedacted(t *testing.T) {
+	cfg := &Config{
+		Auth:     AuthConfig{APIKey: APIKeyConfig{Enabled: true, Keys: map[string]string{"key-b": "billing", "key-a": "admin"}}},
+		Logging:  LoggingConfig{Redact: RedactConfig{Headers: []string{"X-Upstream-Secret"}}},
+		Clusters: []Cluster{{Name: "c", Endpoints: []ClusterEndpoint{{URL: "http://svc:pw@backend:8080"}}}},
+		RoutesV2: []RouteV2{{Name: "r", Filters: []RouteFilter{
+			{Type: "header_set", Args: FilterArgs{"key": "Authorization", "value": "Bearer abc"}},
+			{Type: "header_set", Args: FilterArgs{"key": "x-upstream-secret", "value": "s1"}},
+			{Type: "header_set", Args: FilterArgs{"key": "X-Env", "value": "prod"}},
+			{Type: "sign_request", Args: FilterArgs{"key": "hmac-key"}},
+		}}},
+		ResponseCache: &ResponseCache{Store: "redis", Redis: &CacheRedis{Address: "redis:6379", Password: "hunter2"}},
+	}
+	out := Redacted(cfg)
+	if out.ResponseCache.Redis.Password != "[REDACTED]" || cfg.ResponseCache.Redis.Password != "hunter2" {
+		t.Errorf("redis password = %q", out.ResponseCache.Redis.Password)
+	}
+	if out.Auth.APIKey.Keys["[REDACTED]-1"] != "admin" || out.Auth.APIKey.Keys["[REDACTED]-2"] != "billing" || len(out.Auth.APIKey.Keys) != 2 {
+		t.Errorf("api keys = %v", out.Auth.APIKey.Keys)
+	}
+	if url := out.Clusters[0].Endpoints[0].URL; strings.Contains(url, "pw") {
+		t.Errorf("endpoint password leaked: %s", url)
+	}
+	var values []string
+	for _, f := range out.RoutesV2[0].Filters {
+		v, _ := f.Args.String("value")
+		if f.Type == "sign_request" {
+			v, _ = f.Args.String("key")
+		}
+		values = append(values, v)
+	}
+	if got := strings.Join(values, ","); got != "[REDACTED],[REDACTED],prod,[REDACTED]" {
+		t.Errorf("filter values = %s", got)
+	}
+	// The configuration itself is untouched
+	if cfg.Auth.APIKey.Keys["key-a"] != "admin" || cfg.Clusters[0].Endpoints[0].URL != "http://svc:pw@backend:8080" || cfg.RoutesV2[0].Filters[0].Args["value"] != "Bearer abc" {
+		t.Error("Redacted modified its input")
+	}
+}
@@ -0,0 +1,109 @@
+package config
+
+import (
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// refPath locates a value of a configuration document: mapping keys are
+// joined by dots and sequence entries are named by their name field, or by
+// index when they have none, e.g. `clusters["web"].endpoints[0].url`. key
+// is set for a mapping key rather than the value under it.
+type refPath struct {
+	path string
+	key  bool
+}
+
+// valueRef is a value written in the configuration as a secret or
+// environment variable reference: text is the reference and value what it
+// resolved to.
+type valueRef struct {
+	text, value string
+}
+
+// recordRef records the text of n before it is replaced by a resolved
+// value. A value resolved twice, such as a secret reference read from an
+// environment variable, keeps its original text.
+func recordRef(refs map[*yaml.Node]string, n *yaml.Node) {
+	if refs == nil {
+		return
+	}
+	if _, ok := refs[n]; !ok {
+		refs[n] = n.Value
+	}
+}
+
+// refPaths locates the nodes of doc recorded by recordRef, once every
+// reference has been resolved, so that entries are named as they will be
+// when the configuration is written back.
+func refPaths(doc *yaml.Node, nodes map[*yaml.Node]string) map[refPath]valueRef {
+	refs := make(map[refPath]valueRef, len(nodes))
+	if len(nodes) == 0 {
+		return refs
+	}
+	walkScalars(doc, "", func(n *yaml.Node, p refPath) {
+		if text, ok := nodes[n]; ok {
+			refs[p] = valueRef{text: text, value: n.Value}
+		}
+	})
+	return refs
+}
+
+// restoreRefs writes the references of refs back into a YAML document.
+// Only the values at the recorded paths that still hold what their
+// reference resolved to are restored; values changed since, and unrelated
+// values that happen to be equal, are kept.
+func restoreRefs(data []byte, refs map[refPath]valueRef) ([]byte, error) {
+	if len(refs) == 0 {
+		return data, nil
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	changed := false
+	walkScalars(&doc, "", func(n *yaml.Node, p refPath) {
+		if ref, ok := refs[p]; ok && n.Value == ref.value {
+			n.Value, n.Tag, n.Style = ref.text, "!!str", 0
+			changed = true
+		}
+	})
+	if !changed {
+		return data, nil
+	}
+	return yaml.Marshal(&doc)
+}
+
+// walkScalars calls fn with each scalar under n, keys included, and its
+// path.
+func walkScalars(n *yaml.Node, path string, fn func(*yaml.Node, refPath)) {
+	switch n.Kind {
+	case yaml.DocumentNode:
+		for _, c := range n.Content {
+			walkScalars(c, path, fn)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k, v := n.Content[i], n.Content[i+1]
+			p := k.Value
+			if path != "" {
+				p = path + "." + k.Value
+			}
+			if k.Kind == yaml.ScalarNode {
+				fn(k, refPath{path: p, key: true})
+			}
+			walkScalars(v, p, fn)
+		}
+	case yaml.SequenceNode:
+		for i, c := range n.Content {
+			id := strconv.Itoa(i)
+			if name := entryNameNode(c); name != "" {
+				id = strconv.Quote(name)
+			}
+			walkScalars(c, path+"["+id+"]", fn)
+		}
+	case yaml.ScalarNode:
+		fn(n, refPath{path: path})
+	}
+}
@@ -0,0 +1,106 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// Bounds returns the start and end of s; a zero time is unbounded.
+func (s *RouteSchedule) Bounds() (start, end time.Time, err error) {
+	if s.Start != "" {
+		if start, err = time.Parse(time.RFC3339, s.Start); err != nil {
+			return start, end, fmt.Errorf("start: %w", err)
+		}
+	}
+	if s.End != "" {
+		if end, err = time.Parse(time.RFC3339, s.End); err != nil {
+			return start, end, fmt.Errorf("end: %w", err)
+		}
+	}
+	return start, end, nil
+}
+
+// Location returns the time zone of the windows of s.
+func (s *RouteSchedule) Location() (*time.Location, error) {
+	if s.Timezone == "" {
+		return time.UTC, nil
+	}
+	return time.LoadLocation(s.Timezone)
+}
+
+// Parse returns the days of w as a bit set indexed by time.Weekday and its
+// bounds in minutes since midnight.
+func (w *ScheduleWindow) Parse() (days uint8, from, to int, err error) {
+	if from, err = parseClock(w.From); err != nil {
+		return 0, 0, 0, fmt.Errorf("from: %w", err)
+	}
+	if to, err = parseClock(w.To); err != nil {
+		return 0, 0, 0, fmt.Errorf("to: %w", err)
+	}
+	if from == to || from == 24*60 {
+		return 0, 0, 0, fmt.Errorf("to: %q does not end a window from %q", w.To, w.From)
+	}
+	if len(w.Days) == 0 {
+		return 0x7f, from, to, nil
+	}
+	for _, d := range w.Days {
+		first, last, ok := strings.Cut(strings.ToLower(d), "-")
+		if !ok {
+			last = first
+		}
+		a, ok1 := weekdays[first]
+		b, ok2 := weekdays[last]
+		if !ok1 || !ok2 {
+			return 0, 0, 0, fmt.Errorf("days: unsupported day %q", d)
+		}
+		for day := a; ; day = (day + 1) % 7 {
+			days |= 1 << day
+			if day == b {
+				break
+			}
+		}
+	}
+	return days, from, to, nil
+}
+
+// parseClock parses "HH:MM" into minutes since midnight; "24:00" ends a
+// day.
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		if s == "24:00" {
+			return 24 * 60, nil
+		}
+		return 0, fmt.Errorf("%q is not a time of day (HH:MM)", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// validateSchedule checks the activation schedule of a route.
+func validateSchedule(s *RouteSchedule) error {
+	if s == nil {
+		return nil
+	}
+	start, end, err := s.Bounds()
+	if err != nil {
+		return fmt.Errorf("schedule.%w", err)
+	}
+	if !start.IsZero() && !end.IsZero() && !end.After(start) {
+		return fmt.Errorf("schedule: end must be after start")
+	}
+	if _, err := s.Location(); err != nil {
+		return fmt.Errorf("schedule.timezone: %w", err)
+	}
+	for i := range s.Windows {
+		if _, _, _, err := s.Windows[i].Parse(); err != nil {
+			return fmt.Errorf("schedule.windows[%d].%w", i, err)
+		}
+	}
+	return nil
+}
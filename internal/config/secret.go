@@ -0,0 +1,154 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// secretResolveTimeout bounds resolving the secret references of one
+// configuration document.
+const secretResolveTimeout = 30 * time.Second
+
+// SecretProvider resolves the secret references of one scheme.
+type SecretProvider interface {
+	// Resolve returns the secret named by ref, the reference without its
+	// scheme, e.g. "kv/data/nexus#api_key".
+	Resolve(ctx context.Context, ref string) (string, error)
+}
+
+// SecretResolver replaces secret references in configuration values with
+// the secrets they name, so that API keys, tokens and TLS keys need not
+// appear in the configuration in plaintext. A reference is a whole value of
+// the form scheme:path[#field] whose scheme has a registered provider:
+//
+//   - vault:kv/data/nexus#api_key reads field api_key of a Vault secret.
+//   - aws-sm:nexus/prod#api_key reads a key of a JSON AWS Secrets Manager
+//     secret; without #field the whole secret string is used.
+//   - file:/run/secrets/api_key reads a file, without trailing newlines.
+//
+// Values such as "vault:8200", where the path is a port number, are
+// addresses rather than references.
+type SecretResolver struct {
+	providers map[string]SecretProvider
+}
+
+// NewSecretResolver creates a SecretResolver with the built-in providers.
+// Vault and AWS Secrets Manager are configured from the standard
+// environment variables (VAULT_ADDR, VAULT_TOKEN, AWS_REGION,
+// AWS_ACCESS_KEY_ID, ...) when a reference is first resolved.
+func NewSecretResolver() *SecretResolver {
+	r := &SecretResolver{providers: make(map[string]SecretProvider)}
+	r.Register("file", FileSecretProvider{})
+	r.Register("vault", &VaultSecretProvider{})
+	r.Register("aws-sm", &AWSSecretsProvider{})
+	return r
+}
+
+// Register adds or replaces the provider of a scheme.
+func (r *SecretResolver) Register(scheme string, p SecretProvider) {
+	r.providers[scheme] = p
+}
+
+// reference splits a value into its provider and reference, reporting
+// whether it is a secret reference.
+func (r *SecretResolver) reference(value string) (SecretProvider, string, bool) {
+	scheme, ref, ok := strings.Cut(value, ":")
+	if !ok || ref == "" || isPort(ref) {
+		return nil, "", false
+	}
+	p, ok := r.providers[scheme]
+	return p, ref, ok
+}
+
+// resolve replaces the secret references among the keys and values of a
+// parsed document. The text of each replaced node is recorded in refs, so
+// the references can be restored when the configuration is written back.
+func (r *SecretResolver) resolve(doc *yaml.Node, refs map[*yaml.Node]string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), secretResolveTimeout)
+	defer cancel()
+	resolved := make(map[string]string) // reference → secret
+	var walk func(n *yaml.Node) error
+	walk = func(n *yaml.Node) error {
+		if n.Kind != yaml.ScalarNode {
+			for _, c := range n.Content {
+				if err := walk(c); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		p, ref, ok := r.reference(n.Value)
+		if !ok {
+			return nil
+		}
+		secret, done := resolved[n.Value]
+		if !done {
+			var err error
+			if secret, err = p.Resolve(ctx, ref); err != nil {
+				return fmt.Errorf("line %d: resolve %s: %w", n.Line, n.Value, err)
+			}
+			resolved[n.Value] = secret
+		}
+		recordRef(refs, n)
+		n.Value = secret
+		if n.Style&yaml.TaggedStyle == 0 {
+			// Resolve the type from the secret.
+			n.Tag = ""
+		}
+		return nil
+	}
+	return walk(doc)
+}
+
+// isPort reports whether s is a port number.
+func isPort(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// secretField returns field of a JSON secret. Strings are returned as is,
+// other values as JSON. Without a field, a secret with a single field
+// returns it.
+func secretField(fields map[string]json.RawMessage, field string) (string, error) {
+	if field == "" {
+		if len(fields) != 1 {
+			return "", fmt.Errorf("secret has %d fields, select one with #field", len(fields))
+		}
+		for f := range fields {
+			field = f
+		}
+	}
+	raw, ok := fields[field]
+	if !ok {
+		return "", fmt.Errorf("secret has no field %q", field)
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s, nil
+	}
+	return string(raw), nil
+}
+
+// FileSecretProvider reads secrets from files, such as those mounted by
+// Kubernetes or Docker secrets.
+type FileSecretProvider struct{}
+
+// Resolve returns the content of the file at path, without trailing
+// newlines.
+func (FileSecretProvider) Resolve(_ context.Context, path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
@@ -0,0 +1,204 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// staticSecrets is a SecretProvider backed by a map.
+type staticSecrets map[string]string
+
+func (s staticSecrets) Resolve(_ context.Context, ref string) (string, error) {
+	if v, ok := s[ref]; ok {
+		return v, nil
+	}
+	return "", os.ErrNotExist
+}
+
+func TestSecretResolver_Reference(t *testing.T) {
+	r := NewSecretResolver()
+	for value, want := range map[string]bool{
+		"vault:kv/data/nexus#api_key": true,
+		"aws-sm:nexus/prod":           true,
+		"file:/run/secrets/token":     true,
+		"vault:8200":                  false,
+		"vault:":                      false,
+		"http://backend:8080":         false,
+		"plain":                       false,
+	} {
+		if _, _, got := r.reference(value); got != want {
+			t.Errorf("reference(%q) = %v, want %v", value, got, want)
+		}
+	}
+}
+
+func TestLoader_Secrets(t *testing.T) {
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "admin-token")
+	os.WriteFile(tokenFile, []byte("s3cr3t\n"), 0o600)
+	t.Setenv("SECRETS_DIR", dir)
+
+	path := filepath.Join(dir, "nexus.yaml")
+	os.WriteFile(path, []byte(sourceTestConfig+`auth:
+  api_key:
+    enabled: true
+    keys:
+      "static:api-key": mobile
+admin:
+  listen: ":9000"
+  auth:
+    tokens:
+      - name: deployer
+        token: "file:${SECRETS_DIR}/admin-token"
+        role: operator
+      - name: dashboard
+        token: static:dashboard-token
+        role: read_only
+`), 0o644)
+
+	loader := NewLoader(path)
+	loader.Secrets().Register("static", staticSecrets{"api-key": "k-123", "dashboard-token": "d-456"})
+	cfg, err := loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Auth.APIKey.Keys["k-123"] != "mobile" {
+		t.Errorf("api key not resolved: %v", cfg.Auth.APIKey.Keys)
+	}
+	if tokens := cfg.Admin.Auth.Tokens; tokens[0].Token != "s3cr3t" || tokens[1].Token != "d-456" {
+		t.Errorf("tokens not resolved: %+v", tokens)
+	}
+
+	// Persisting writes the references back, never the secrets.
+	data, _ := yaml.Marshal(cfg)
+	if err := loader.Persist(cfg, data); err != nil {
+		t.Fatal(err)
+	}
+	written, _ := os.ReadFile(path)
+	for _, secret := range []string{"s3cr3t", "k-123", "d-456"} {
+		if strings.Contains(string(written), secret) {
+			t.Errorf("persisted file contains secret %q:\n%s", secret, written)
+		}
+	}
+	if !strings.Contains(string(written), "file:${SECRETS_DIR}/admin-token") || !strings.Contains(string(written), "static:api-key") {
+		t.Errorf("persisted file lacks references:\n%s", written)
+	}
+	if cfg, err = loader.Load(); err != nil || cfg.Admin.Auth.Tokens[0].Token != "s3cr3t" {
+		t.Errorf("reloading persisted file: %v", err)
+	}
+
+	os.WriteFile(path, []byte(sourceTestConfig+"admin:\n  auth:\n    tokens:\n      - {name: x, token: \"static:missing\", role: operator}\n"), 0o644)
+	if _, err := loader.Load(); err == nil || !strings.Contains(err.Error(), "line 16: resolve static:missing") {
+		t.Errorf("expected a resolution error, got %v", err)
+	}
+}
+
+func TestVaultSecretProvider(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "root" || r.Header.Get("X-Vault-Namespace") != "team" {
+			http.Error(w, `{"errors":["permission denied"]}`, http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/kv/data/nexus":
+			w.Write([]byte(`{"data":{"data":{"api_key":"v2-key","port":8443},"metadata":{"version":3}}}`))
+		case "/v1/secret/nexus":
+			w.Write([]byte(`{"data":{"api_key":"v1-key"}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	t.Setenv("VAULT_ADDR", srv.URL)
+	t.Setenv("VAULT_TOKEN", "root")
+
+	p := &VaultSecretProvider{Namespace: "team"}
+	for ref, want := range map[string]string{
+		"kv/data/nexus#api_key": "v2-key",
+		"kv/data/nexus#port":    "8443",
+		"secret/nexus":          "v1-key",
+	} {
+		if got, err := p.Resolve(context.Background(), ref); err != nil || got != want {
+			t.Errorf("Resolve(%q) = %q, %v; want %q", ref, got, err, want)
+		}
+	}
+	for ref, want := range map[string]string{
+		"kv/data/nexus#missing": `no field "missing"`,
+		"kv/data/nexus":         "select one with #field",
+		"kv/data/other#key":     "not found",
+	} {
+		if _, err := p.Resolve(context.Background(), ref); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Resolve(%q): expected error containing %q, got %v", ref, want, err)
+		}
+	}
+	if _, err := (&VaultSecretProvider{}).Resolve(context.Background(), "secret/nexus"); err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("expected permission error, got %v", err)
+	}
+}
+
+func TestAWSSecretsProvider(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if r.Header.Get("X-Amz-Target") != "secretsmanager.GetSecretValue" ||
+			!strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKID/") ||
+			!strings.Contains(auth, "/eu-west-1/secretsmanager/aws4_request") ||
+			!strings.Contains(auth, "x-amz-security-token") {
+			http.Error(w, "bad signature", http.StatusForbidden)
+			return
+		}
+		var req struct{ SecretId string }
+		json.NewDecoder(r.Body).Decode(&req)
+		switch req.SecretId {
+		case "nexus/prod":
+			w.Write([]byte(`{"Name":"nexus/prod","SecretString":"{\"api_key\":\"aws-key\"}"}`))
+		case "nexus/plain":
+			w.Write([]byte(`{"Name":"nexus/plain","SecretString":"plain-value"}`))
+		default:
+			http.Error(w, `{"__type":"ResourceNotFoundException"}`, http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+	t.Setenv("AWS_REGION", "eu-west-1")
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKID")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_SESSION_TOKEN", "session")
+	t.Setenv("AWS_ENDPOINT_URL", srv.URL)
+
+	p := &AWSSecretsProvider{}
+	for ref, want := range map[string]string{
+		"nexus/prod#api_key": "aws-key",
+		"nexus/prod":         `{"api_key":"aws-key"}`,
+		"nexus/plain":        "plain-value",
+	} {
+		if got, err := p.Resolve(context.Background(), ref); err != nil || got != want {
+			t.Errorf("Resolve(%q) = %q, %v; want %q", ref, got, err, want)
+		}
+	}
+	if _, err := p.Resolve(context.Background(), "nexus/plain#key"); err == nil || !strings.Contains(err.Error(), "not a JSON object") {
+		t.Errorf("expected a JSON error, got %v", err)
+	}
+	if _, err := p.Resolve(context.Background(), "nexus/missing"); err == nil || !strings.Contains(err.Error(), "ResourceNotFoundException") {
+		t.Errorf("expected not found, got %v", err)
+	}
+}
+
+func TestSignAWS(t *testing.T) {
+	// The get-vanilla case of the AWS Signature Version 4 test suite.
+	req, _ := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	creds := awsCredentials{accessKeyID: "AKIDEXAMPLE", secretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+	signAWS(req, nil, "service", "us-east-1", creds, time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+		"SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("Authorization = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,234 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxSourceBytes bounds the configuration documents fetched from a source.
+const maxSourceBytes = 8 << 20
+
+// DefaultPollInterval is how often an HTTP source is polled by default.
+const DefaultPollInterval = 30 * time.Second
+
+// sourceRetryDelay is the pause before a failed watch request is retried.
+var sourceRetryDelay = 5 * time.Second
+
+// Source is a remote store of the configuration document, used instead of a
+// local file.
+type Source interface {
+	// Fetch returns the current document.
+	Fetch(ctx context.Context) ([]byte, error)
+	// Watch calls onChange with each new version of the document until ctx
+	// is done; it may also pass the current document again, for example
+	// when it starts. Failures are logged and retried.
+	Watch(ctx context.Context, onChange func([]byte))
+	// String describes the source for logs, without credentials.
+	String() string
+}
+
+// IsRemote reports whether location names a remote source rather than a
+// local file or directory.
+func IsRemote(location string) bool {
+	scheme, _, ok := strings.Cut(location, "://")
+	if !ok {
+		return false
+	}
+	switch scheme {
+	case "http", "https", "etcd", "etcd+https", "consul", "consul+https":
+		return true
+	}
+	return false
+}
+
+// NewSource returns the source at location:
+//
+//   - http(s)://host/path polls a URL, for example a presigned or public S3
+//     object, every poll interval, using ETags to skip unchanged documents.
+//   - etcd://host:2379/key watches an etcd v3 key through the JSON gateway.
+//     The key is the URL path, leading slash included. User info in the
+//     URL is used to authenticate.
+//   - consul://host:8500/key watches a Consul KV key with blocking queries.
+//     The token is read from CONSUL_HTTP_TOKEN, and query parameters such
+//     as dc are passed on.
+//
+// The +https forms of the etcd and consul schemes use TLS.
+func NewSource(location string, poll time.Duration) (Source, error) {
+	u, err := url.Parse(location)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config source: %w", err)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid config source %s: missing host", redactURL(u))
+	}
+	if poll <= 0 {
+		poll = DefaultPollInterval
+	}
+	base := func(scheme string) string {
+		if strings.HasSuffix(scheme, "+https") {
+			return "https://" + u.Host
+		}
+		return "http://" + u.Host
+	}
+	switch u.Scheme {
+	case "http", "https":
+		return &HTTPSource{URL: location, PollInterval: poll}, nil
+	case "etcd", "etcd+https":
+		if u.Path == "" || u.Path == "/" {
+			return nil, fmt.Errorf("invalid config source %s: missing key", redactURL(u))
+		}
+		src := &EtcdSource{Endpoint: base(u.Scheme), Key: u.Path}
+		if u.User != nil {
+			src.Username = u.User.Username()
+			src.Password, _ = u.User.Password()
+		}
+		return src, nil
+	case "consul", "consul+https":
+		key := strings.TrimPrefix(u.Path, "/")
+		if key == "" {
+			return nil, fmt.Errorf("invalid config source %s: missing key", redactURL(u))
+		}
+		return &ConsulSource{Address: base(u.Scheme), Key: key, Query: u.Query(), Token: os.Getenv("CONSUL_HTTP_TOKEN")}, nil
+	}
+	return nil, fmt.Errorf("unsupported config source scheme %q", u.Scheme)
+}
+
+// HTTPSource polls a configuration document from a URL. S3 objects are
+// read through a public or presigned URL.
+type HTTPSource struct {
+	Client       *http.Client
+	URL          string
+	PollInterval time.Duration
+
+	mu   sync.Mutex
+	etag string
+	last []byte
+}
+
+// Fetch returns the document at the URL.
+func (s *HTTPSource) Fetch(ctx context.Context) ([]byte, error) {
+	data, _, err := s.get(ctx, false)
+	return data, err
+}
+
+// Watch polls the URL every PollInterval and calls onChange when the
+// document changed.
+func (s *HTTPSource) Watch(ctx context.Context, onChange func([]byte)) {
+	ticker := time.NewTicker(s.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		data, changed, err := s.get(ctx, true)
+		if err != nil {
+			if ctx.Err() == nil {
+				slog.Error("config source poll failed", slog.String("source", s.String()), slog.String("error", err.Error()))
+			}
+			continue
+		}
+		if changed {
+			onChange(data)
+		}
+	}
+}
+
+func (s *HTTPSource) String() string {
+	if u, err := url.Parse(s.URL); err == nil {
+		return redactURL(u)
+	}
+	return "http source"
+}
+
+// get fetches the document. When conditional is set, an unchanged document,
+// by ETag or content, is reported as not changed.
+func (s *HTTPSource) get(ctx context.Context, conditional bool) ([]byte, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.URL, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	s.mu.Lock()
+	etag := s.etag
+	s.mu.Unlock()
+	if conditional && etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	resp, err := clientOrDefault(s.Client).Do(req)
+	if err != nil {
+		return nil, false, fmt.Errorf("fetch config: %w", err)
+	}
+	defer resp.Body.Close()
+	if conditional && resp.StatusCode == http.StatusNotModified {
+		return nil, false, nil
+	}
+	data, err := readSourceBody(resp)
+	if err != nil {
+		return nil, false, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.etag = resp.Header.Get("ETag")
+	changed := !bytes.Equal(data, s.last)
+	s.last = data
+	return data, changed, nil
+}
+
+// readSourceBody returns the body of a successful response.
+func readSourceBody(resp *http.Response) ([]byte, error) {
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("fetch config: %s %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSourceBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("fetch config: %w", err)
+	}
+	if len(data) > maxSourceBytes {
+		return nil, fmt.Errorf("fetch config: document larger than %d bytes", maxSourceBytes)
+	}
+	return data, nil
+}
+
+func clientOrDefault(c *http.Client) *http.Client {
+	if c == nil {
+		return http.DefaultClient
+	}
+	return c
+}
+
+// redactURL returns u without its password and query, which may hold
+// credentials such as S3 signatures.
+func redactURL(u *url.URL) string {
+	c := *u
+	c.RawQuery = ""
+	c.Fragment = ""
+	if c.User != nil {
+		c.User = url.User(c.User.Username())
+	}
+	return c.String()
+}
+
+// sleepCtx waits for d or until ctx is done, reporting whether ctx is still
+// live.
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
@@ -0,0 +1,37 @@
+package config
+
+import "fmt"
+
+// validateClusterSplit checks the weighted clusters of an upstream.
+func validateClusterSplit(u *RouteUpstream) error {
+	if u.Cluster != "" {
+		return fmt.Errorf("upstream.cluster and upstream.clusters are mutually exclusive")
+	}
+	if u.GRPC != nil || u.Dubbo != nil || u.GraphQL != nil {
+		return fmt.Errorf("upstream.clusters cannot be combined with grpc, dubbo or graphql")
+	}
+	seen := make(map[string]bool, len(u.Clusters))
+	total := 0
+	for i, c := range u.Clusters {
+		if c.Name == "" {
+			return fmt.Errorf("upstream.clusters[%d].name is required", i)
+		}
+		if seen[c.Name] {
+			return fmt.Errorf("upstream.clusters[%d]: duplicate cluster %q", i, c.Name)
+		}
+		seen[c.Name] = true
+		if c.Weight < 0 {
+			return fmt.Errorf("upstream.clusters[%d].weight must not be negative", i)
+		}
+		total += c.Weight
+	}
+	if total == 0 {
+		return fmt.Errorf("upstream.clusters: at least one weight must be positive")
+	}
+	if s := u.Sticky; s != nil {
+		if s.Header != "" && s.Cookie != "" {
+			return fmt.Errorf("upstream.sticky: header and cookie are mutually exclusive")
+		}
+	}
+	return nil
+}
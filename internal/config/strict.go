@@ -0,0 +1,277 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// document is a parsed configuration document. For a configuration
+// directory, files records the file each node was read from, so positions
+// name the file.
+type document struct {
+	root  *yaml.Node
+	files map[*yaml.Node]string
+}
+
+// position describes where n is in the document, e.g. "line 3, column 5"
+// or "routes.yaml: line 3, column 5".
+func (d *document) position(n *yaml.Node) string {
+	pos := fmt.Sprintf("line %d, column %d", n.Line, n.Column)
+	if file, ok := d.files[n]; ok {
+		return file + ": " + pos
+	}
+	return pos
+}
+
+// unknownFields reports the mapping keys of the document that no field of
+// Config decodes, such as a misspelled "path_previx", one error per key.
+func (d *document) unknownFields() []error {
+	var errs []error
+	var walk func(n *yaml.Node, t reflect.Type, path string)
+	walk = func(n *yaml.Node, t reflect.Type, path string) {
+		if n.Kind == yaml.AliasNode {
+			n = n.Alias
+		}
+		for t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		if reflect.PointerTo(t).Implements(unmarshalerType) {
+			return
+		}
+		switch {
+		case n.Kind == yaml.DocumentNode:
+			for _, c := range n.Content {
+				walk(c, t, path)
+			}
+		case n.Kind == yaml.SequenceNode && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array):
+			for i, c := range n.Content {
+				walk(c, t.Elem(), fmt.Sprintf("%s[%d]", path, i))
+			}
+		case n.Kind == yaml.MappingNode && t.Kind() == reflect.Map:
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				walk(n.Content[i+1], t.Elem(), joinPath(path, n.Content[i].Value))
+			}
+		case n.Kind == yaml.MappingNode && t.Kind() == reflect.Struct:
+			fields := yamlFields(t)
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				key, value := n.Content[i], n.Content[i+1]
+				if key.Value == "<<" {
+					walk(value, t, path) // merge key
+					continue
+				}
+				ft, ok := fields[key.Value]
+				if !ok {
+					msg := fmt.Sprintf("%s: unknown field %q", d.position(key), key.Value)
+					if path != "" {
+						msg += " in " + path
+					}
+					if s := suggestField(key.Value, fields); s != "" {
+						msg += fmt.Sprintf(" (did you mean %q?)", s)
+					}
+					errs = append(errs, errors.New(msg))
+					continue
+				}
+				walk(value, ft, joinPath(path, key.Value))
+			}
+		}
+		// Other kinds are scalars or type mismatches, reported when decoding.
+	}
+	walk(d.root, reflect.TypeOf(Config{}), "")
+	return errs
+}
+
+var unmarshalerType = reflect.TypeOf((*yaml.Unmarshaler)(nil)).Elem()
+
+// yamlFields returns the types of the fields of struct t by YAML key,
+// including the fields of inlined structs.
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if strings.Contains(opts, "inline") {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				for k, v := range yamlFields(ft) {
+					fields[k] = v
+				}
+				continue
+			}
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		fields[name] = f.Type
+	}
+	return fields
+}
+
+// suggestField returns the known field closest to a misspelled key, if
+// any is close enough to be a likely typo.
+func suggestField(key string, fields map[string]reflect.Type) string {
+	best, bestDist := "", 3
+	for name := range fields {
+		if d := editDistance(key, name); d < bestDist || d == bestDist && best != "" && name < best {
+			best, bestDist = name, d
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+var (
+	// namedEntryError matches validation errors naming an entry, such as
+	// `route_v2 "users": upstream.cluster is required`.
+	namedEntryError = regexp.MustCompile(`^(upstream|route|listener|cluster|route_v2) ("(?:[^"\\]|\\.)*")[: ]\s*(\S*)`)
+	// duplicateError matches duplicate name errors.
+	duplicateError = regexp.MustCompile(`^duplicate (upstream|listener|cluster) name: (.*)$`)
+	// pathSegment matches the segments of a field path, e.g. "tokens[0]".
+	pathSegment = regexp.MustCompile(`^([a-z_0-9]+)((?:\[\d+\])*)$`)
+)
+
+// entryLists maps the entry kinds named in validation errors to their lists.
+var entryLists = map[string]string{
+	"upstream": "upstreams",
+	"route":    "routes",
+	"listener": "listeners",
+	"cluster":  "clusters",
+	"route_v2": "routes_v2",
+}
+
+// locate returns the node a validation error refers to, or nil. Errors
+// name the offending field by path, such as "admin.auth.tokens[0]", or by
+// entry, such as `route_v2 "users": upstream.cluster`; the deepest node of
+// that path present in the document is returned.
+func (d *document) locate(err error) *yaml.Node {
+	root := d.root
+	if root.Kind == yaml.DocumentNode && len(root.Content) > 0 {
+		root = root.Content[0]
+	}
+	msg := err.Error()
+	if m := duplicateError.FindStringSubmatch(msg); m != nil {
+		entries := findEntries(root, entryLists[m[1]], m[2])
+		if len(entries) > 1 {
+			return entries[1]
+		}
+		return nil
+	}
+	if m := namedEntryError.FindStringSubmatch(msg); m != nil {
+		name, uerr := strconv.Unquote(m[2])
+		if uerr != nil {
+			return nil
+		}
+		entries := findEntries(root, entryLists[m[1]], name)
+		if len(entries) == 0 {
+			return nil
+		}
+		if n := walkPath(entries[0], m[3]); n != nil {
+			return n
+		}
+		return entries[0]
+	}
+	token, _, _ := strings.Cut(msg, " ")
+	return walkPath(root, token)
+}
+
+// findEntries returns the entries of a top-level list with a name.
+func findEntries(root *yaml.Node, list, name string) []*yaml.Node {
+	var entries []*yaml.Node
+	if seq := mappingValue(root, list); seq != nil && seq.Kind == yaml.SequenceNode {
+		for _, e := range seq.Content {
+			if entryNameNode(e) == name {
+				entries = append(entries, e)
+			}
+		}
+	}
+	return entries
+}
+
+// walkPath follows a field path such as "upstream.grpc.allow[1]:" from n
+// and returns the node of the deepest segment present: the key of a
+// mapping entry or the element of a list. It returns nil when not even the
+// first segment is present.
+func walkPath(n *yaml.Node, path string) *yaml.Node {
+	var found *yaml.Node
+	for _, seg := range strings.Split(strings.TrimRight(path, ":"), ".") {
+		m := pathSegment.FindStringSubmatch(seg)
+		if m == nil {
+			break
+		}
+		key, value := mappingEntry(n, m[1])
+		if key == nil {
+			break
+		}
+		found, n = key, value
+		for _, idx := range strings.Split(m[2], "]") {
+			i, err := strconv.Atoi(strings.TrimPrefix(idx, "["))
+			if err != nil {
+				continue
+			}
+			if n.Kind != yaml.SequenceNode || i >= len(n.Content) {
+				return found
+			}
+			found, n = n.Content[i], n.Content[i]
+		}
+	}
+	return found
+}
+
+// mappingEntry returns the key and value nodes of key in mapping n.
+func mappingEntry(n *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
+	if n.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i], n.Content[i+1]
+		}
+	}
+	return nil, nil
+}
+
+func mappingValue(n *yaml.Node, key string) *yaml.Node {
+	_, v := mappingEntry(n, key)
+	return v
+}
@@ -0,0 +1,162 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const strictBaseConfig = `server:
+  listen: ":8080"
+clusters:
+  - name: users
+    endpoints:
+      - url: "http://users:8080"
+`
+
+func loadString(t *testing.T, content string, strict bool) (*Config, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "nexus.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	loader := NewLoader(path)
+	loader.SetStrict(strict)
+	return loader.Load()
+}
+
+func TestLoad_UnknownFields(t *testing.T) {
+	content := strictBaseConfig + `routes_v2:
+  - name: users
+    match:
+      path_previx: /users
+    upstream:
+      cluster: users
+      timout_ms: 100
+auth:
+  api_key:
+    keys:
+      any-key-name: mobile
+`
+	_, err := loadString(t, content, true)
+	if err == nil {
+		t.Fatal("expected unknown fields to be rejected")
+	}
+	for _, want := range []string{
+		`line 10, column 7: unknown field "path_previx" in routes_v2[0].match (did you mean "path_prefix"?)`,
+		`line 13, column 7: unknown field "timout_ms" in routes_v2[0].upstream (did you mean "timeout_ms"?)`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in:\n%v", want, err)
+		}
+	}
+
+	// Not strict, unknown fields are ignored; the route then fails
+	// validation for lack of a path.
+	if _, err := loadString(t, content, false); err == nil || !strings.Contains(err.Error(), "match.path or match.path_prefix is required") {
+		t.Errorf("expected a validation error, got %v", err)
+	}
+	fixed := strings.Replace(content, "path_previx", "path_prefix", 1)
+	if cfg, err := loadString(t, fixed, false); err != nil || cfg.RoutesV2[0].Match.PathPrefix != "/users" {
+		t.Errorf("expected the lenient load to succeed, got %v", err)
+	}
+}
+
+func TestLoad_ValidationPositions(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name: "named entry field",
+			content: strictBaseConfig + `routes_v2:
+  - name: users
+    match:
+      path: /users
+    upstream:
+      cluster: users
+      timeout_ms: -1
+`,
+			want: `validate config: line 13, column 7: route_v2 "users": upstream.timeout_ms must not be negative`,
+		},
+		{
+			name: "named entry",
+			content: strictBaseConfig + `routes_v2:
+  - name: users
+    match: {path: /users}
+  - name: orders
+    match: {path: /orders}
+    upstream: {cluster: orders}
+`,
+			want: `validate config: line 8, column 5: route_v2 "users": upstream.cluster is required`,
+		},
+		{
+			name: "duplicate",
+			content: strictBaseConfig + `  - name: users
+    endpoints: [{url: "http://users-2:8080"}]
+`,
+			want: "validate config: line 7, column 5: duplicate cluster name: users",
+		},
+		{
+			name: "path",
+			content: strictBaseConfig + `admin:
+  auth:
+    tokens:
+      - {name: a, token: t1, role: operator}
+      - {name: b, token: t2, role: admin}
+`,
+			want: "validate config: line 11, column 9: admin.auth.tokens[1]: role must be",
+		},
+		{
+			name:    "missing section",
+			content: "clusters: []\n",
+			want:    "validate config: server.listen is required",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := loadString(t, tt.content, true); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestLoadDir_Positions(t *testing.T) {
+	dir := writeConfigDir(t, map[string]string{
+		"base.yaml": dirBaseConfig,
+		"users.yaml": dirUsersConfig + `    retries: 3
+`,
+	})
+	_, err := NewLoader(dir).Load()
+	if err == nil || !strings.Contains(err.Error(), `users.yaml: line 11, column 5: unknown field "retries" in routes_v2[0]`) {
+		t.Errorf("expected the file in the error, got %v", err)
+	}
+
+	dir = writeConfigDir(t, map[string]string{
+		"base.yaml":  dirBaseConfig,
+		"users.yaml": strings.Replace(dirUsersConfig, "cluster: users", "cluster: missing", 1),
+	})
+	_, err = NewLoader(dir).Load()
+	if err == nil || !strings.Contains(err.Error(), `users.yaml: line 6, column 5: route_v2 "users" references unknown cluster`) {
+		t.Errorf("expected the file in the error, got %v", err)
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	for _, tt := range []struct {
+		a, b string
+		want int
+	}{
+		{"path_previx", "path_prefix", 1},
+		{"", "abc", 3},
+		{"timout_ms", "timeout_ms", 1},
+		{"listen", "listen", 0},
+	} {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
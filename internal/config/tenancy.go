@@ -0,0 +1,59 @@
+package config
+
+import "fmt"
+
+// validateTenancy checks the tenancy settings against the clusters of cfg.
+func validateTenancy(cfg *Config) error {
+	t := cfg.Tenancy
+	if t == nil {
+		return nil
+	}
+	if t.Header == "" && t.Claim == "" {
+		return fmt.Errorf("tenancy: header or claim is required")
+	}
+	if err := validateTenantRateLimit("tenancy.rate_limit", t.RateLimit); err != nil {
+		return err
+	}
+	clusters := make(map[string]bool, len(cfg.Clusters))
+	for _, c := range cfg.Clusters {
+		clusters[c.Name] = true
+	}
+	seen := make(map[string]bool, len(t.Tenants))
+	for i, tn := range t.Tenants {
+		if tn.Name == "" {
+			return fmt.Errorf("tenancy.tenants[%d]: name is required", i)
+		}
+		if seen[tn.Name] {
+			return fmt.Errorf("tenancy.tenants[%d]: duplicate tenant %q", i, tn.Name)
+		}
+		seen[tn.Name] = true
+		if err := validateTenantRateLimit(fmt.Sprintf("tenancy.tenants[%d].rate_limit", i), tn.RateLimit); err != nil {
+			return err
+		}
+		for from, to := range tn.Clusters {
+			if !clusters[from] {
+				return fmt.Errorf("tenancy.tenants[%d].clusters: cluster %q not found", i, from)
+			}
+			if !clusters[to] {
+				return fmt.Errorf("tenancy.tenants[%d].clusters: cluster %q not found", i, to)
+			}
+		}
+	}
+	return nil
+}
+
+func validateTenantRateLimit(path string, l *TenantRateLimit) error {
+	if l == nil {
+		return nil
+	}
+	if l.Rate <= 0 {
+		return fmt.Errorf("%s.rate must be positive", path)
+	}
+	if err := checkDuration(path+".window", l.Window, 0); err != nil {
+		return err
+	}
+	if l.WindowDuration() == 0 {
+		return fmt.Errorf("%s.window must be positive", path)
+	}
+	return nil
+}
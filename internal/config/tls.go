@@ -0,0 +1,184 @@
+package config
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// validateServerTLS validates the TLS settings of the gateway listener.
+func validateServerTLS(t *ServerTLSConfig) error {
+	if t == nil {
+		return nil
+	}
+	hasDefault := t.CertFile != "" || t.Cert != "" || t.KeyFile != "" || t.Key != ""
+	if (hasDefault || len(t.Certificates) == 0) && !completePair(t.CertFile, t.Cert, t.KeyFile, t.Key) {
+		return errors.New("server.tls: cert_file and key_file are required, or cert and key as PEM")
+	}
+	for i, c := range t.Certificates {
+		if !completePair(c.CertFile, c.Cert, c.KeyFile, c.Key) {
+			return fmt.Errorf("server.tls.certificates[%d]: cert_file and key_file are required, or cert and key as PEM", i)
+		}
+		for j, name := range c.ServerNames {
+			if err := checkHost(name); err != nil {
+				return fmt.Errorf("server.tls.certificates[%d].server_names[%d]: %w", i, j, err)
+			}
+		}
+	}
+	if t.RequireClientCert && t.ClientCAFile == "" {
+		return errors.New("server.tls.require_client_cert requires server.tls.client_ca_file")
+	}
+	return validateServerTLSTuning(t)
+}
+
+// completePair reports whether a certificate and its key are each set
+// exactly once, as a file or as PEM.
+func completePair(certFile, cert, keyFile, key string) bool {
+	return (certFile == "") != (cert == "") && (keyFile == "") != (key == "")
+}
+
+// validateRouteTLS validates the TLS criteria of a V2 route match against
+// the listener settings t.
+func validateRouteTLS(route string, m *TLSMatch, t *ServerTLSConfig) error {
+	if m == nil {
+		return nil
+	}
+	if t == nil {
+		return fmt.Errorf("route_v2 %q: match.tls requires server.tls", route)
+	}
+	if len(m.SNI) == 0 && m.ClientCert == nil {
+		return fmt.Errorf("route_v2 %q: match.tls must set sni or client_cert", route)
+	}
+	for j, name := range m.SNI {
+		if err := checkHost(name); err != nil {
+			return fmt.Errorf("route_v2 %q: match.tls.sni[%d]: %w", route, j, err)
+		}
+	}
+	if c := m.ClientCert; c != nil {
+		if len(c.CommonNames) == 0 && len(c.OrganizationalUnits) == 0 && len(c.SANs) == 0 {
+			return fmt.Errorf("route_v2 %q: match.tls.client_cert must list common_names, organizational_units or sans", route)
+		}
+		if t.ClientCAFile == "" {
+			return fmt.Errorf("route_v2 %q: match.tls.client_cert requires server.tls.client_ca_file", route)
+		}
+	}
+	return nil
+}
+
+// tlsVersions maps the TLS versions of ServerTLSConfig to their IDs.
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// tlsCurves maps the lower-cased key exchange groups of ServerTLSConfig to
+// their IDs.
+var tlsCurves = map[string]tls.CurveID{
+	"x25519":         tls.X25519,
+	"p256":           tls.CurveP256,
+	"p384":           tls.CurveP384,
+	"p521":           tls.CurveP521,
+	"x25519mlkem768": tls.X25519MLKEM768,
+}
+
+// TLSVersions returns the IDs of min_version and max_version, with their
+// defaults.
+func (t *ServerTLSConfig) TLSVersions() (minVersion, maxVersion uint16, err error) {
+	minVersion, maxVersion = tls.VersionTLS12, tls.VersionTLS13
+	if t.MinVersion != "" {
+		v, ok := tlsVersions[t.MinVersion]
+		if !ok {
+			return 0, 0, fmt.Errorf("min_version: unknown TLS version %q (use 1.0 to 1.3)", t.MinVersion)
+		}
+		minVersion = v
+	}
+	if t.MaxVersion != "" {
+		v, ok := tlsVersions[t.MaxVersion]
+		if !ok {
+			return 0, 0, fmt.Errorf("max_version: unknown TLS version %q (use 1.0 to 1.3)", t.MaxVersion)
+		}
+		maxVersion = v
+	}
+	if minVersion > maxVersion {
+		return 0, 0, errors.New("min_version is above max_version")
+	}
+	return minVersion, maxVersion, nil
+}
+
+// CipherSuiteIDs returns the IDs of cipher_suites. Insecure suites and TLS
+// 1.3 suites, which are not configurable, are rejected.
+func (t *ServerTLSConfig) CipherSuiteIDs() ([]uint16, error) {
+	var ids []uint16
+	for i, name := range t.CipherSuites {
+		id, err := cipherSuiteID(name)
+		if err != nil {
+			return nil, fmt.Errorf("cipher_suites[%d]: %w", i, err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func cipherSuiteID(name string) (uint16, error) {
+	for _, cs := range tls.CipherSuites() {
+		if cs.Name != name {
+			continue
+		}
+		if len(cs.SupportedVersions) == 1 && cs.SupportedVersions[0] == tls.VersionTLS13 {
+			return 0, fmt.Errorf("%s is a TLS 1.3 cipher suite, which is not configurable", name)
+		}
+		return cs.ID, nil
+	}
+	for _, cs := range tls.InsecureCipherSuites() {
+		if cs.Name == name {
+			return 0, fmt.Errorf("%s is insecure", name)
+		}
+	}
+	return 0, fmt.Errorf("unknown cipher suite %q", name)
+}
+
+// CurveIDs returns the IDs of curve_preferences.
+func (t *ServerTLSConfig) CurveIDs() ([]tls.CurveID, error) {
+	var ids []tls.CurveID
+	for i, name := range t.CurvePreferences {
+		id, ok := tlsCurves[strings.ToLower(strings.ReplaceAll(name, "-", ""))]
+		if !ok {
+			return nil, fmt.Errorf("curve_preferences[%d]: unknown curve %q (use X25519, P256, P384, P521 or X25519MLKEM768)", i, name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// validateServerTLSTuning validates the protocol settings of the gateway
+// listener.
+func validateServerTLSTuning(t *ServerTLSConfig) error {
+	if _, _, err := t.TLSVersions(); err != nil {
+		return fmt.Errorf("server.tls.%w", err)
+	}
+	if _, err := t.CipherSuiteIDs(); err != nil {
+		return fmt.Errorf("server.tls.%w", err)
+	}
+	if _, err := t.CurveIDs(); err != nil {
+		return fmt.Errorf("server.tls.%w", err)
+	}
+	if len(t.ALPN) > 0 && !slices.Contains(t.ALPN, "h2") && !slices.Contains(t.ALPN, "http/1.1") {
+		return errors.New("server.tls.alpn must offer h2 or http/1.1")
+	}
+	for i, p := range t.ALPN {
+		if p == "" {
+			return fmt.Errorf("server.tls.alpn[%d] is empty", i)
+		}
+	}
+	if err := checkDuration("server.tls.session_ticket_rotation", t.SessionTicketRotation, 0); err != nil {
+		return err
+	}
+	if t.SessionTicketRotation != "" && t.DisableSessionTickets {
+		return errors.New("server.tls.session_ticket_rotation cannot be combined with disable_session_tickets")
+	}
+	return nil
+}
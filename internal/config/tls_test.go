@@ -0,0 +1,59 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidate_ServerTLS(t *testing.T) {
+	withCA := &ServerTLSConfig{CertFile: "tls.crt", KeyFile: "tls.key", ClientCAFile: "ca.crt"}
+	noCA := &ServerTLSConfig{Cert: "PEM", Key: "PEM"}
+	tests := []struct {
+		name  string
+		tls   *ServerTLSConfig
+		match *TLSMatch
+		error string
+	}{
+		{"sni", noCA, &TLSMatch{SNI: []string{"api.example.com", "*.example.org"}}, ""},
+		{"client cert", withCA, &TLSMatch{ClientCert: &ClientCertMatch{OrganizationalUnits: []string{"billing"}}}, ""},
+		{"no listener tls", nil, &TLSMatch{SNI: []string{"api.example.com"}}, `route_v2 "r": match.tls requires server.tls`},
+		{"missing key", &ServerTLSConfig{CertFile: "tls.crt"}, nil, "server.tls: cert_file and key_file are required"},
+		{"sni certificates only", &ServerTLSConfig{Certificates: []TLSCertificate{{CertFile: "a.crt", KeyFile: "a.key", ServerNames: []string{"*.example.com"}}}}, nil, ""},
+		{"incomplete sni certificate", &ServerTLSConfig{Cert: "PEM", Key: "PEM", Certificates: []TLSCertificate{{CertFile: "a.crt", Cert: "PEM", KeyFile: "a.key"}}}, nil, "server.tls.certificates[0]: cert_file and key_file are required"},
+		{"bad server name", &ServerTLSConfig{Cert: "PEM", Key: "PEM", Certificates: []TLSCertificate{{Cert: "PEM", Key: "PEM", ServerNames: []string{"https://a.example.com"}}}}, nil, `server.tls.certificates[0].server_names[0]: invalid host`},
+		{"tuning", &ServerTLSConfig{Cert: "PEM", Key: "PEM", MinVersion: "1.3", CipherSuites: []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"}, CurvePreferences: []string{"X25519", "P-256"}, ALPN: []string{"http/1.1"}, SessionTicketRotation: "1h"}, nil, ""},
+		{"bad version", &ServerTLSConfig{Cert: "PEM", Key: "PEM", MinVersion: "1.4"}, nil, `server.tls.min_version: unknown TLS version "1.4"`},
+		{"inverted versions", &ServerTLSConfig{Cert: "PEM", Key: "PEM", MinVersion: "1.3", MaxVersion: "1.2"}, nil, "server.tls.min_version is above max_version"},
+		{"unknown cipher", &ServerTLSConfig{Cert: "PEM", Key: "PEM", CipherSuites: []string{"TLS_FAST"}}, nil, `server.tls.cipher_suites[0]: unknown cipher suite "TLS_FAST"`},
+		{"insecure cipher", &ServerTLSConfig{Cert: "PEM", Key: "PEM", CipherSuites: []string{"TLS_RSA_WITH_RC4_128_SHA"}}, nil, "TLS_RSA_WITH_RC4_128_SHA is insecure"},
+		{"tls 1.3 cipher", &ServerTLSConfig{Cert: "PEM", Key: "PEM", CipherSuites: []string{"TLS_AES_128_GCM_SHA256"}}, nil, "is a TLS 1.3 cipher suite"},
+		{"unknown curve", &ServerTLSConfig{Cert: "PEM", Key: "PEM", CurvePreferences: []string{"P224"}}, nil, `server.tls.curve_preferences[0]: unknown curve "P224"`},
+		{"alpn without http", &ServerTLSConfig{Cert: "PEM", Key: "PEM", ALPN: []string{"acme-tls/1"}}, nil, "server.tls.alpn must offer h2 or http/1.1"},
+		{"rotation without tickets", &ServerTLSConfig{Cert: "PEM", Key: "PEM", DisableSessionTickets: true, SessionTicketRotation: "1h"}, nil, "cannot be combined with disable_session_tickets"},
+		{"bad rotation", &ServerTLSConfig{Cert: "PEM", Key: "PEM", SessionTicketRotation: "hourly"}, nil, "server.tls.session_ticket_rotation: invalid duration"},
+		{"require without ca", &ServerTLSConfig{Cert: "PEM", Key: "PEM", RequireClientCert: true}, nil, "server.tls.require_client_cert requires server.tls.client_ca_file"},
+		{"empty match", noCA, &TLSMatch{}, "match.tls must set sni or client_cert"},
+		{"bad sni", noCA, &TLSMatch{SNI: []string{"api.example.com:443"}}, `match.tls.sni[0]: invalid host "api.example.com:443"`},
+		{"empty client cert", withCA, &TLSMatch{ClientCert: &ClientCertMatch{}}, "must list common_names, organizational_units or sans"},
+		{"client cert without ca", noCA, &TLSMatch{ClientCert: &ClientCertMatch{SANs: []string{"a"}}}, "match.tls.client_cert requires server.tls.client_ca_file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Server:   ServerConfig{Listen: ":8443", TLS: tt.tls},
+				Clusters: []Cluster{{Name: "c", Type: "http", Endpoints: []ClusterEndpoint{{URL: "http://c:8080"}}}},
+				RoutesV2: []RouteV2{{Name: "r", Match: RouteMatch{PathPrefix: "/", TLS: tt.match}, Upstream: RouteUpstream{Cluster: "c"}}},
+			}
+			err := Validate(cfg)
+			if tt.error == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.error) {
+				t.Errorf("expected error containing %q, got %v", tt.error, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,289 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Duration is a duration written with a unit, such as "30s", "1m30s" or
+// "250ms", as time.ParseDuration accepts. Duration fields stand next to the
+// older millisecond fields they replace, e.g. timeout next to timeout_ms;
+// at most one of the two may be set.
+type Duration string
+
+// Parse returns the duration d denotes; empty is zero.
+func (d Duration) Parse() (time.Duration, error) {
+	if d == "" {
+		return 0, nil
+	}
+	v, err := time.ParseDuration(strings.TrimSpace(string(d)))
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q, expected a number with a unit such as 30s or 500ms", string(d))
+	}
+	return v, nil
+}
+
+// Size is a number of bytes, optionally with a unit, such as "2MiB",
+// "512KB" or "1048576". KB, MB and GB are powers of 1000; KiB, MiB and GiB
+// powers of 1024. Like Duration, Size fields stand next to the older fields
+// they replace, e.g. max_body next to max_body_bytes.
+type Size string
+
+// sizeUnits maps the lower-cased units of a Size to their byte counts.
+var sizeUnits = map[string]float64{
+	"":    1,
+	"b":   1,
+	"kb":  1e3,
+	"mb":  1e6,
+	"gb":  1e9,
+	"kib": 1 << 10,
+	"mib": 1 << 20,
+	"gib": 1 << 30,
+}
+
+// Parse returns the number of bytes s denotes; empty is zero.
+func (s Size) Parse() (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	text := strings.TrimSpace(string(s))
+	i := strings.LastIndexFunc(text, func(r rune) bool { return r >= '0' && r <= '9' || r == '.' })
+	unit, ok := sizeUnits[strings.ToLower(strings.TrimSpace(text[i+1:]))]
+	if !ok {
+		return 0, fmt.Errorf("invalid size %q, unknown unit (use B, KB, MB, GB, KiB, MiB or GiB)", string(s))
+	}
+	n, err := strconv.ParseFloat(text[:i+1], 64)
+	if err != nil || math.IsInf(n*unit, 0) || n*unit > math.MaxInt64 {
+		return 0, fmt.Errorf("invalid size %q, expected a number of bytes such as 1048576 or 2MiB", string(s))
+	}
+	return int64(n * unit), nil
+}
+
+// durationOr returns d, or ms milliseconds when d is not set. An invalid d,
+// which Validate rejects, counts as zero.
+func durationOr(d Duration, ms int) time.Duration {
+	if d == "" {
+		return time.Duration(ms) * time.Millisecond
+	}
+	v, _ := d.Parse()
+	return v
+}
+
+// sizeOr returns s, or legacy bytes when s is not set.
+func sizeOr(s Size, legacy int64) int64 {
+	if s == "" {
+		return legacy
+	}
+	v, _ := s.Parse()
+	return v
+}
+
+// checkDuration validates the Duration field at path, e.g.
+// "upstream.timeout", against its millisecond counterpart path+"_ms".
+func checkDuration(path string, d Duration, ms int) error {
+	if d == "" {
+		return nil
+	}
+	if ms != 0 {
+		return fmt.Errorf("%s and %s_ms are mutually exclusive", path, path)
+	}
+	v, err := d.Parse()
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if v < 0 {
+		return fmt.Errorf("%s must not be negative", path)
+	}
+	return nil
+}
+
+// checkSize validates the Size field at path against the field it replaces.
+func checkSize(path string, s Size, legacyPath string, legacy int64) error {
+	if s == "" {
+		return nil
+	}
+	if legacy != 0 {
+		return fmt.Errorf("%s and %s are mutually exclusive", path, legacyPath)
+	}
+	v, err := s.Parse()
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if v < 0 {
+		return fmt.Errorf("%s must not be negative", path)
+	}
+	return nil
+}
+
+// IdleConnTimeoutDuration returns idle_conn_timeout or idle_conn_timeout_ms.
+func (k *KeepaliveConfig) IdleConnTimeoutDuration() time.Duration {
+	return durationOr(k.IdleConnTimeout, k.IdleConnTimeoutMs)
+}
+
+// MaxHeaderBytesLimit returns max_header_bytes in bytes; zero means the
+// net/http default.
+func (s *ServerConfig) MaxHeaderBytesLimit() int {
+	return int(sizeOr(s.MaxHeaderBytes, 0))
+}
+
+// BakeDuration returns bake, 5 minutes when it is not set.
+func (b *BlueGreen) BakeDuration() time.Duration {
+	if b.Bake == "" {
+		return 5 * time.Minute
+	}
+	return durationOr(b.Bake, 0)
+}
+
+// MaxResponseLimit returns max_response in bytes, 1MiB when it is not set.
+func (c *ResponseCache) MaxResponseLimit() int64 {
+	return sizeOr(c.MaxResponse, 1<<20)
+}
+
+// TTLDuration returns ttl, zero when the upstream's freshness applies.
+func (c *RouteCache) TTLDuration() time.Duration {
+	return durationOr(c.TTL, 0)
+}
+
+// TimeoutDuration returns timeout, a second when it is not set.
+func (r *CacheRedis) TimeoutDuration() time.Duration {
+	return durationOr(r.Timeout, 1000)
+}
+
+// WindowDuration returns window, 24 hours when it is not set.
+func (i *Idempotency) WindowDuration() time.Duration {
+	if i.Window == "" {
+		return 24 * time.Hour
+	}
+	return durationOr(i.Window, 0)
+}
+
+// MaxResponseLimit returns max_response in bytes, 1MiB when it is not set.
+func (i *Idempotency) MaxResponseLimit() int64 {
+	return sizeOr(i.MaxResponse, 1<<20)
+}
+
+// WindowDuration returns window, a minute when it is not set.
+func (l *TenantRateLimit) WindowDuration() time.Duration {
+	return durationOr(l.Window, 60000)
+}
+
+// MaxBodyLimit returns max_body in bytes, 1MiB when it is not set.
+func (m *RouteMirror) MaxBodyLimit() int64 {
+	return sizeOr(m.MaxBody, 1<<20)
+}
+
+// MaxRecvMsgLimit returns max_recv_msg, or max_recv_msg_mb in bytes.
+func (g *ClusterGRPC) MaxRecvMsgLimit() int64 {
+	return sizeOr(g.MaxRecvMsg, int64(g.MaxRecvMsgMB)<<20)
+}
+
+// BurstSize returns burst, or rate when it is not set.
+func (c *ConnectionLimits) BurstSize() int {
+	if c.Burst > 0 {
+		return c.Burst
+	}
+	return c.Rate
+}
+
+// DefaultWatchdogInterval is the time between watchdog samples.
+const DefaultWatchdogInterval = time.Second
+
+// SampleInterval returns the interval, or DefaultWatchdogInterval when it
+// is not set.
+func (w *Watchdog) SampleInterval() time.Duration {
+	if d := durationOr(w.Interval, 0); d > 0 {
+		return d
+	}
+	return DefaultWatchdogInterval
+}
+
+// HeapLimit returns max_heap in bytes, zero when it is not set.
+func (w *Watchdog) HeapLimit() int64 {
+	return sizeOr(w.MaxHeap, 0)
+}
+
+// LagLimit returns max_lag, zero when it is not set.
+func (w *Watchdog) LagLimit() time.Duration {
+	return durationOr(w.MaxLag, 0)
+}
+
+// Default request size limits of protocol rewrites.
+const (
+	DefaultGRPCMaxSendMsg = 4 << 20
+	DefaultDubboMaxBody   = 8 << 20
+)
+
+// MaxSendMsgLimit returns max_send_msg in bytes, 4MiB when it is not set.
+func (g *ClusterGRPC) MaxSendMsgLimit() int64 {
+	return sizeOr(g.MaxSendMsg, DefaultGRPCMaxSendMsg)
+}
+
+// MaxBodyLimit returns max_body in bytes, 8MiB when it is not set.
+func (d *ClusterDubbo) MaxBodyLimit() int64 {
+	return sizeOr(d.MaxBody, DefaultDubboMaxBody)
+}
+
+// KeepaliveTimeDuration returns keepalive_time or keepalive_time_ms.
+func (g *ClusterGRPC) KeepaliveTimeDuration() time.Duration {
+	return durationOr(g.KeepaliveTime, g.KeepaliveTimeMs)
+}
+
+// KeepaliveTimeoutDuration returns keepalive_timeout or keepalive_timeout_ms.
+func (g *ClusterGRPC) KeepaliveTimeoutDuration() time.Duration {
+	return durationOr(g.KeepaliveTimeout, g.KeepaliveTimeoutMs)
+}
+
+// ReflectionTimeoutDuration returns reflection_timeout or
+// reflection_timeout_ms.
+func (g *ClusterGRPC) ReflectionTimeoutDuration() time.Duration {
+	return durationOr(g.ReflectionTimeout, g.ReflectionTimeoutMs)
+}
+
+// HeartbeatDuration returns heartbeat or heartbeat_ms.
+func (d *ClusterDubbo) HeartbeatDuration() time.Duration {
+	return durationOr(d.Heartbeat, d.HeartbeatMs)
+}
+
+// ReconnectBackoffDuration returns reconnect_backoff or reconnect_backoff_ms.
+func (d *ClusterDubbo) ReconnectBackoffDuration() time.Duration {
+	return durationOr(d.ReconnectBackoff, d.ReconnectBackoffMs)
+}
+
+// ReconnectBackoffMaxDuration returns reconnect_backoff_max or
+// reconnect_backoff_max_ms.
+func (d *ClusterDubbo) ReconnectBackoffMaxDuration() time.Duration {
+	return durationOr(d.ReconnectBackoffMax, d.ReconnectBackoffMaxMs)
+}
+
+// TimeoutDuration returns timeout or timeout_ms.
+func (r *DubboRegistry) TimeoutDuration() time.Duration {
+	return durationOr(r.Timeout, r.TimeoutMs)
+}
+
+// MaxBodyLimit returns max_body or max_body_bytes.
+func (g *ClusterGraphQL) MaxBodyLimit() int64 {
+	return sizeOr(g.MaxBody, g.MaxBodyBytes)
+}
+
+// TimeoutDuration returns timeout or timeout_ms.
+func (u *RouteUpstream) TimeoutDuration() time.Duration {
+	return durationOr(u.Timeout, u.TimeoutMs)
+}
+
+// InitTimeoutDuration returns init_timeout or init_timeout_ms.
+func (s *GraphQLSubscriptions) InitTimeoutDuration() time.Duration {
+	return durationOr(s.InitTimeout, s.InitTimeoutMs)
+}
+
+// TTLDuration returns ttl or ttl_ms.
+func (c *GraphQLCache) TTLDuration() time.Duration {
+	return durationOr(c.TTL, c.TTLMs)
+}
+
+// MaxResponseLimit returns max_response or max_response_bytes.
+func (c *GraphQLCache) MaxResponseLimit() int64 {
+	return sizeOr(c.MaxResponse, c.MaxResponseBytes)
+}
@@ -0,0 +1,115 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDuration_Parse(t *testing.T) {
+	for value, want := range map[Duration]time.Duration{
+		"":      0,
+		"30s":   30 * time.Second,
+		"1m30s": 90 * time.Second,
+		"250ms": 250 * time.Millisecond,
+		" 2h ":  2 * time.Hour,
+	} {
+		if got, err := value.Parse(); err != nil || got != want {
+			t.Errorf("Duration(%q).Parse() = %v, %v; want %v", value, got, err, want)
+		}
+	}
+	for _, value := range []Duration{"30", "30x", "soon"} {
+		if _, err := value.Parse(); err == nil || !strings.Contains(err.Error(), "invalid duration") {
+			t.Errorf("Duration(%q).Parse(): expected an error, got %v", value, err)
+		}
+	}
+}
+
+func TestSize_Parse(t *testing.T) {
+	for value, want := range map[Size]int64{
+		"":        0,
+		"1048576": 1 << 20,
+		"512B":    512,
+		"2MiB":    2 << 20,
+		"2 MiB":   2 << 20,
+		"1.5KiB":  1536,
+		"10kb":    10000,
+		"1GB":     1e9,
+	} {
+		if got, err := value.Parse(); err != nil || got != want {
+			t.Errorf("Size(%q).Parse() = %d, %v; want %d", value, got, err, want)
+		}
+	}
+	for _, value := range []Size{"2MB/s", "MiB", "1..5KB", "9999999999GiB"} {
+		if _, err := value.Parse(); err == nil || !strings.Contains(err.Error(), "invalid size") {
+			t.Errorf("Size(%q).Parse(): expected an error, got %v", value, err)
+		}
+	}
+}
+
+func TestUnits_Accessors(t *testing.T) {
+	u := RouteUpstream{TimeoutMs: 1500}
+	if got := u.TimeoutDuration(); got != 1500*time.Millisecond {
+		t.Errorf("legacy timeout_ms: got %v", got)
+	}
+	u = RouteUpstream{Timeout: "30s"}
+	if got := u.TimeoutDuration(); got != 30*time.Second {
+		t.Errorf("timeout: got %v", got)
+	}
+	g := ClusterGRPC{MaxRecvMsgMB: 4}
+	if got := g.MaxRecvMsgLimit(); got != 4<<20 {
+		t.Errorf("legacy max_recv_msg_mb: got %d", got)
+	}
+	g = ClusterGRPC{MaxRecvMsg: "512KiB"}
+	if got := g.MaxRecvMsgLimit(); got != 512<<10 {
+		t.Errorf("max_recv_msg: got %d", got)
+	}
+	if got := g.MaxSendMsgLimit(); got != DefaultGRPCMaxSendMsg {
+		t.Errorf("default max_send_msg: got %d", got)
+	}
+	d := ClusterDubbo{MaxBody: "1MiB"}
+	if got := d.MaxBodyLimit(); got != 1<<20 {
+		t.Errorf("dubbo max_body: got %d", got)
+	}
+	c := GraphQLCache{TTL: "1m", MaxResponseBytes: 100}
+	if c.TTLDuration() != time.Minute || c.MaxResponseLimit() != 100 {
+		t.Errorf("cache: got %v, %d", c.TTLDuration(), c.MaxResponseLimit())
+	}
+}
+
+func TestLoad_Units(t *testing.T) {
+	content := strictBaseConfig + `    keepalive:
+      idle_conn_timeout: 90s
+    graphql:
+      max_body: 2MiB
+routes_v2:
+  - name: users
+    match:
+      path: /users
+    upstream:
+      cluster: users
+      timeout: 30s
+`
+	cfg, err := loadString(t, content, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.RoutesV2[0].Upstream.TimeoutDuration() != 30*time.Second ||
+		cfg.Clusters[0].GraphQL.MaxBodyLimit() != 2<<20 ||
+		cfg.Clusters[0].Keepalive.IdleConnTimeoutDuration() != 90*time.Second {
+		t.Errorf("units not decoded: %+v", cfg.Clusters[0])
+	}
+
+	for _, tt := range []struct{ old, new, want string }{
+		{"timeout: 30s", "timeout: 30x", `line 17, column 7: route_v2 "users": upstream.timeout: invalid duration "30x"`},
+		{"timeout: 30s", "timeout: -1s", `route_v2 "users": upstream.timeout must not be negative`},
+		{"timeout: 30s", "timeout: 30s\n      timeout_ms: 100", "upstream.timeout and upstream.timeout_ms are mutually exclusive"},
+		{"max_body: 2MiB", "max_body: 2 parsecs", `line 10, column 7: cluster "users": graphql.max_body: invalid size "2 parsecs"`},
+		{"max_body: 2MiB", "max_body: 2MiB\n      max_body_bytes: 10", "graphql.max_body and graphql.max_body_bytes are mutually exclusive"},
+	} {
+		_, err := loadString(t, strings.Replace(content, tt.old, tt.new, 1), true)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected %q, got %v", tt.new, tt.want, err)
+		}
+	}
+}
@@ -1,16 +1,47 @@
 package config
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
 )
 
+// SchemaVersion is the configuration schema version this binary supports.
+const SchemaVersion = "v1"
+
+// ValidationError is the error of a configuration document that decodes
+// but fails Validate.
+type ValidationError struct {
+	// Position is the line and column of the offending field, empty when
+	// it could not be located.
+	Position string
+	Err      error
+}
+
+func (e *ValidationError) Error() string {
+	if e.Position == "" {
+		return "validate config: " + e.Err.Error()
+	}
+	return "validate config: " + e.Position + ": " + e.Err.Error()
+}
+
+func (e *ValidationError) Unwrap() error { return e.Err }
+
 // Validate checks the configuration for correctness.
 func Validate(cfg *Config) error {
 	if cfg == nil {
 		return errors.New("config is nil")
 	}
 
+	if err := validateVersion(cfg.Version); err != nil {
+		return err
+	}
+
 	// server.listen is required unless listeners are defined
 	if cfg.Server.Listen == "" && len(cfg.Listeners) == 0 {
 		return errors.New("server.listen is required (or define listeners)")
@@ -53,8 +84,8 @@ func Validate(cfg *Config) error {
 			if p.Path == "" {
 				return fmt.Errorf("route %q paths[%d].path is required", r.Name, j)
 			}
-			if p.Type != "exact" && p.Type != "prefix" {
-				return fmt.Errorf("route %q paths[%d].type must be 'exact' or 'prefix', got %q", r.Name, j, p.Type)
+			if err := validatePathRule(r.Name, j, &r.Paths[j]); err != nil {
+				return err
 			}
 		}
 		if err := validateRewrite(r.Name, r.Rewrite); err != nil {
@@ -62,20 +93,163 @@ func Validate(cfg *Config) error {
 		}
 	}
 
+	if err := validateServerTLS(cfg.Server.TLS); err != nil {
+		return err
+	}
+	if err := validateServerLimits(&cfg.Server); err != nil {
+		return err
+	}
+	if err := validateIPACL("server.ip_acl", cfg.Server.IPACL); err != nil {
+		return err
+	}
+	if err := validateIPACL("ip_acl", cfg.IPACL); err != nil {
+		return err
+	}
+	if err := validateIPList("trusted_proxies", cfg.TrustedProxies); err != nil {
+		return err
+	}
+	if err := validateMaintenance("maintenance", cfg.Maintenance); err != nil {
+		return err
+	}
+	if err := validateLogging(&cfg.Logging); err != nil {
+		return err
+	}
+	if err := validateLoadShedding(cfg.LoadShedding); err != nil {
+		return err
+	}
+	if err := validateWatchdog(cfg.Watchdog); err != nil {
+		return err
+	}
+	if err := validateTenancy(cfg); err != nil {
+		return err
+	}
+	if err := validateResponseCache(cfg.ResponseCache); err != nil {
+		return err
+	}
+
+	if err := validateAdmin(&cfg.Admin); err != nil {
+		return err
+	}
+
 	// Validate new DSL structures (listeners, clusters, routes_v2)
 	if err := validateListeners(cfg.Listeners); err != nil {
 		return err
 	}
 
+	if err := validateExternalPlugins(cfg.ExternalPlugins); err != nil {
+		return err
+	}
+	if err := validatePluginSettings(cfg); err != nil {
+		return err
+	}
+
 	clusterNames := make(map[string]bool)
 	if err := validateClusters(cfg.Clusters, clusterNames); err != nil {
 		return err
 	}
 
-	if err := validateRoutesV2(cfg.RoutesV2, clusterNames); err != nil {
+	if err := validateDefaults(cfg); err != nil {
 		return err
 	}
 
+	if err := validateFallback(cfg.Server.Fallback, cfg.Clusters); err != nil {
+		return err
+	}
+
+	if err := validateRoutesV2(cfg.RoutesV2, clusterNames, cfg.FilterChains); err != nil {
+		return err
+	}
+	for i := range cfg.RoutesV2 {
+		if err := validateRouteTLS(cfg.RoutesV2[i].Name, cfg.RoutesV2[i].Match.TLS, cfg.Server.TLS); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateVersion checks that a configuration's schema version, "v1" or
+// "1", is the one this binary supports.
+func validateVersion(version string) error {
+	if version == "" {
+		return nil
+	}
+	n, err := strconv.Atoi(strings.TrimPrefix(version, "v"))
+	if err != nil || n < 1 {
+		return fmt.Errorf("version: invalid schema version %q, expected %q", version, SchemaVersion)
+	}
+	if supported, _ := strconv.Atoi(strings.TrimPrefix(SchemaVersion, "v")); n > supported {
+		return fmt.Errorf("version: schema version %q is newer than this binary supports (%s); upgrade nexus or pin the configuration to %s", version, SchemaVersion, SchemaVersion)
+	}
+	return nil
+}
+
+// validateAdmin validates the admin API TLS and access control settings.
+func validateAdmin(a *AdminConfig) error {
+	if a.TLS != nil {
+		if (a.TLS.CertFile == "") == (a.TLS.Cert == "") || (a.TLS.KeyFile == "") == (a.TLS.Key == "") {
+			return errors.New("admin.tls: cert_file and key_file are required, or cert and key as PEM")
+		}
+	}
+	if a.Auth == nil {
+		return nil
+	}
+	validRole := func(role string) bool { return role == AdminRoleReadOnly || role == AdminRoleOperator }
+	tokens := make(map[string]bool, len(a.Auth.Tokens))
+	for i, t := range a.Auth.Tokens {
+		if t.Name == "" || t.Token == "" {
+			return fmt.Errorf("admin.auth.tokens[%d]: name and token are required", i)
+		}
+		if tokens[t.Token] {
+			return fmt.Errorf("admin.auth.tokens[%d]: token of %q is already assigned", i, t.Name)
+		}
+		tokens[t.Token] = true
+		if !validRole(t.Role) {
+			return fmt.Errorf("admin.auth.tokens[%d]: role must be 'read_only' or 'operator', got %q", i, t.Role)
+		}
+	}
+	for i, c := range a.Auth.ClientCerts {
+		if c.CommonName == "" {
+			return fmt.Errorf("admin.auth.client_certs[%d].common_name is required", i)
+		}
+		if !validRole(c.Role) {
+			return fmt.Errorf("admin.auth.client_certs[%d]: role must be 'read_only' or 'operator', got %q", i, c.Role)
+		}
+	}
+	if len(a.Auth.ClientCerts) > 0 && (a.TLS == nil || a.TLS.ClientCAFile == "") {
+		return errors.New("admin.auth.client_certs requires admin.tls.client_ca_file")
+	}
+	for i, cidr := range a.Auth.AllowedCIDRs {
+		if _, _, err := net.ParseCIDR(cidr); err != nil && net.ParseIP(cidr) == nil {
+			return fmt.Errorf("admin.auth.allowed_cidrs[%d]: invalid CIDR or IP %q", i, cidr)
+		}
+	}
+	return nil
+}
+
+// validateServerLimits validates the request size and connection limits of
+// the listener.
+func validateServerLimits(s *ServerConfig) error {
+	if err := checkSize("server.max_header_bytes", s.MaxHeaderBytes, "", 0); err != nil {
+		return err
+	}
+	if s.MaxHeaderCount < 0 {
+		return errors.New("server.max_header_count must not be negative")
+	}
+	if s.MaxURLLength < 0 {
+		return errors.New("server.max_url_length must not be negative")
+	}
+	if c := s.Connections; c != nil {
+		if c.Rate < 0 || c.Burst < 0 || c.Max < 0 || c.MaxPerIP < 0 {
+			return errors.New("server.connections limits must not be negative")
+		}
+		if c.Burst > 0 && c.Rate == 0 {
+			return errors.New("server.connections.burst requires server.connections.rate")
+		}
+		if c.Max > 0 && c.MaxPerIP > c.Max {
+			return errors.New("server.connections.max_per_ip must not exceed server.connections.max")
+		}
+	}
 	return nil
 }
 
@@ -97,6 +271,75 @@ func validateListeners(listeners []Listener) error {
 	return nil
 }
 
+// validateExternalPlugins validates external plugin configurations.
+func validateExternalPlugins(plugins []ExternalPlugin) error {
+	names := make(map[string]bool)
+	for i, p := range plugins {
+		if p.Name == "" {
+			return fmt.Errorf("external_plugins[%d].name is required", i)
+		}
+		if names[p.Name] {
+			return fmt.Errorf("duplicate external plugin name: %s", p.Name)
+		}
+		names[p.Name] = true
+		u, err := url.Parse(p.Endpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("external plugin %q: endpoint must be an http or https URL, got %q", p.Name, p.Endpoint)
+		}
+		if _, err := p.Timeout.Parse(); err != nil {
+			return fmt.Errorf("external plugin %q timeout: %w", p.Name, err)
+		}
+		if n, err := p.MaxBody.Parse(); err != nil {
+			return fmt.Errorf("external plugin %q max_body: %w", p.Name, err)
+		} else if n < 0 {
+			return fmt.Errorf("external plugin %q max_body must not be negative", p.Name)
+		}
+		if p.ConfigSchema != nil {
+			if _, err := json.Marshal(p.ConfigSchema); err != nil {
+				return fmt.Errorf("external plugin %q config_schema must be a JSON schema: %w", p.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// validatePluginSettings validates plugin settings. Plugins are bound to
+// the routes the plugin chain matches: routes_v2 when the V2 gateway
+// serves them, the legacy routes otherwise.
+func validatePluginSettings(cfg *Config) error {
+	routeNames := make(map[string]bool)
+	if cfg.UsesV2() {
+		for _, r := range cfg.RoutesV2 {
+			routeNames[r.Name] = true
+		}
+	} else {
+		for _, r := range cfg.Routes {
+			routeNames[r.Name] = true
+		}
+	}
+	names := make(map[string]bool)
+	for i, p := range cfg.Plugins {
+		if p.Name == "" {
+			return fmt.Errorf("plugins[%d].name is required", i)
+		}
+		if names[p.Name] {
+			return fmt.Errorf("duplicate plugin setting: %s", p.Name)
+		}
+		names[p.Name] = true
+		bound := make(map[string]bool)
+		for j, b := range p.Routes {
+			if !routeNames[b.Route] {
+				return fmt.Errorf("plugin %q routes[%d]: unknown route %q", p.Name, j, b.Route)
+			}
+			if bound[b.Route] {
+				return fmt.Errorf("plugin %q routes[%d]: route %q is bound twice", p.Name, j, b.Route)
+			}
+			bound[b.Route] = true
+		}
+	}
+	return nil
+}
+
 // validateClusters validates cluster configurations.
 func validateClusters(clusters []Cluster, clusterNames map[string]bool) error {
 	for i, c := range clusters {
@@ -109,10 +352,10 @@ func validateClusters(clusters []Cluster, clusterNames map[string]bool) error {
 		clusterNames[c.Name] = true
 
 		switch c.Type {
-		case "", "http", "grpc", "dubbo":
+		case "", "http", "grpc", "dubbo", "graphql":
 			// valid
 		default:
-			return fmt.Errorf("cluster %q: unsupported type %q, must be 'http', 'grpc', or 'dubbo'", c.Name, c.Type)
+			return fmt.Errorf("cluster %q: unsupported type %q, must be 'http', 'grpc', 'dubbo', or 'graphql'", c.Name, c.Type)
 		}
 
 		if len(c.Endpoints) == 0 {
@@ -127,56 +370,250 @@ func validateClusters(clusters []Cluster, clusterNames map[string]bool) error {
 		if c.Type == "grpc" && c.GRPC == nil {
 			// grpc cluster config is optional, just use defaults
 		}
+		if ka := c.Keepalive; ka != nil {
+			if err := checkDuration("keepalive.idle_conn_timeout", ka.IdleConnTimeout, ka.IdleConnTimeoutMs); err != nil {
+				return fmt.Errorf("cluster %q: %w", c.Name, err)
+			}
+		}
+		if g := c.GRPC; g != nil {
+			if g.MaxRecvMsgMB < 0 || g.KeepaliveTimeMs < 0 || g.KeepaliveTimeoutMs < 0 || g.MaxConcurrentStreams < 0 {
+				return fmt.Errorf("cluster %q: grpc limits and keepalive settings must not be negative", c.Name)
+			}
+			if err := errors.Join(
+				checkSize("grpc.max_recv_msg", g.MaxRecvMsg, "grpc.max_recv_msg_mb", int64(g.MaxRecvMsgMB)),
+				checkSize("grpc.max_send_msg", g.MaxSendMsg, "", 0),
+				checkDuration("grpc.keepalive_time", g.KeepaliveTime, g.KeepaliveTimeMs),
+				checkDuration("grpc.keepalive_timeout", g.KeepaliveTimeout, g.KeepaliveTimeoutMs),
+				checkDuration("grpc.reflection_timeout", g.ReflectionTimeout, g.ReflectionTimeoutMs),
+			); err != nil {
+				return fmt.Errorf("cluster %q: %w", c.Name, err)
+			}
+		}
+		if g := c.GraphQL; g != nil {
+			if g.MaxBodyBytes < 0 {
+				return fmt.Errorf("cluster %q: graphql.max_body_bytes must not be negative", c.Name)
+			}
+			if err := checkSize("graphql.max_body", g.MaxBody, "graphql.max_body_bytes", g.MaxBodyBytes); err != nil {
+				return fmt.Errorf("cluster %q: %w", c.Name, err)
+			}
+		}
 		if c.Type == "dubbo" && c.Dubbo == nil {
 			// dubbo cluster config is optional, just use defaults
 		}
+		if d := c.Dubbo; d != nil {
+			switch d.Protocol {
+			case "", "dubbo":
+				switch d.Serialization {
+				case "", "json", "hessian2":
+				default:
+					return fmt.Errorf("cluster %q: unsupported dubbo serialization %q, must be 'json' or 'hessian2'", c.Name, d.Serialization)
+				}
+			case "triple":
+				switch d.Serialization {
+				case "", "hessian2", "protobuf":
+				default:
+					return fmt.Errorf("cluster %q: unsupported triple serialization %q, must be 'hessian2' or 'protobuf'", c.Name, d.Serialization)
+				}
+			default:
+				return fmt.Errorf("cluster %q: unsupported dubbo protocol %q, must be 'dubbo' or 'triple'", c.Name, d.Protocol)
+			}
+			if d.Connections < 0 || d.HeartbeatMs < 0 || d.ReconnectBackoffMs < 0 || d.ReconnectBackoffMaxMs < 0 {
+				return fmt.Errorf("cluster %q: dubbo connection settings must not be negative", c.Name)
+			}
+			if err := errors.Join(
+				checkDuration("dubbo.heartbeat", d.Heartbeat, d.HeartbeatMs),
+				checkDuration("dubbo.reconnect_backoff", d.ReconnectBackoff, d.ReconnectBackoffMs),
+				checkDuration("dubbo.reconnect_backoff_max", d.ReconnectBackoffMax, d.ReconnectBackoffMaxMs),
+				checkSize("dubbo.max_body", d.MaxBody, "", 0),
+			); err != nil {
+				return fmt.Errorf("cluster %q: %w", c.Name, err)
+			}
+			if reg := d.Registry; reg != nil {
+				if reg.Type != "nacos" {
+					return fmt.Errorf("cluster %q: unsupported dubbo registry type %q, must be 'nacos'", c.Name, reg.Type)
+				}
+				if reg.Address == "" {
+					return fmt.Errorf("cluster %q: dubbo registry address is required", c.Name)
+				}
+				if reg.TimeoutMs < 0 {
+					return fmt.Errorf("cluster %q: dubbo registry timeout_ms must not be negative", c.Name)
+				}
+				if err := checkDuration("dubbo.registry.timeout", reg.Timeout, reg.TimeoutMs); err != nil {
+					return fmt.Errorf("cluster %q: %w", c.Name, err)
+				}
+			}
+		}
 	}
 	return nil
 }
 
 // validateRoutesV2 validates V2 route configurations.
-func validateRoutesV2(routes []RouteV2, clusterNames map[string]bool) error {
+func validateRoutesV2(routes []RouteV2, clusterNames map[string]bool, chains map[string][]RouteFilter) error {
 	for i, r := range routes {
 		if r.Name == "" {
 			return fmt.Errorf("routes_v2[%d].name is required", i)
 		}
 
-		if r.Match.Path == "" && r.Match.PathPrefix == "" {
-			return fmt.Errorf("route_v2 %q: match.path or match.path_prefix is required", r.Name)
+		if err := validateRouteMatchPath(r.Name, &r.Match); err != nil {
+			return err
+		}
+		if err := validateRouteHosts(r.Name, &r.Match); err != nil {
+			return err
+		}
+		for j, q := range r.Match.Query {
+			if q.Name == "" {
+				return fmt.Errorf("route_v2 %q: match.query[%d].name is required", r.Name, j)
+			}
+			if q.Exact != "" && q.Regex != "" {
+				return fmt.Errorf("route_v2 %q: match.query[%d]: exact and regex are mutually exclusive", r.Name, j)
+			}
+			if q.Regex != "" {
+				if _, err := regexp.Compile(q.Regex); err != nil {
+					return fmt.Errorf("route_v2 %q: match.query[%d].regex: %w", r.Name, j, err)
+				}
+			}
+		}
+		for j, h := range r.Match.Headers {
+			if h.Name == "" {
+				return fmt.Errorf("route_v2 %q: match.headers[%d].name is required", r.Name, j)
+			}
+			if h.Regex != "" && (h.Exact != "" || h.Contains != "") {
+				return fmt.Errorf("route_v2 %q: match.headers[%d]: regex cannot be combined with exact or contains", r.Name, j)
+			}
+			if h.Present != nil && !*h.Present && (h.Exact != "" || h.Contains != "" || h.Regex != "") {
+				return fmt.Errorf("route_v2 %q: match.headers[%d]: present: false cannot be combined with a value", r.Name, j)
+			}
+			if h.Regex != "" {
+				if _, err := regexp.Compile(h.Regex); err != nil {
+					return fmt.Errorf("route_v2 %q: match.headers[%d].regex: %w", r.Name, j, err)
+				}
+			}
+		}
+		for j, c := range r.Match.Cookies {
+			if c.Name == "" {
+				return fmt.Errorf("route_v2 %q: match.cookies[%d].name is required", r.Name, j)
+			}
+			if c.Exact != "" && c.Regex != "" {
+				return fmt.Errorf("route_v2 %q: match.cookies[%d]: exact and regex are mutually exclusive", r.Name, j)
+			}
+			if c.Present != nil && !*c.Present && (c.Exact != "" || c.Regex != "") {
+				return fmt.Errorf("route_v2 %q: match.cookies[%d]: present: false cannot be combined with a value", r.Name, j)
+			}
+			if c.Regex != "" {
+				if _, err := regexp.Compile(c.Regex); err != nil {
+					return fmt.Errorf("route_v2 %q: match.cookies[%d].regex: %w", r.Name, j, err)
+				}
+			}
+		}
+
+		if m := r.Match.GraphQL; m != nil {
+			if len(m.OperationTypes) == 0 && len(m.OperationNames) == 0 {
+				return fmt.Errorf("route_v2 %q: match.graphql must list operation_types or operation_names", r.Name)
+			}
+			for j, t := range m.OperationTypes {
+				switch t {
+				case "query", "mutation", "subscription":
+				default:
+					return fmt.Errorf("route_v2 %q: match.graphql.operation_types[%d]: unsupported operation type %q, must be 'query', 'mutation', or 'subscription'", r.Name, j, t)
+				}
+			}
+			for j, name := range m.OperationNames {
+				if !isGraphQLName(name) {
+					return fmt.Errorf("route_v2 %q: match.graphql.operation_names[%d]: invalid operation name %q", r.Name, j, name)
+				}
+			}
 		}
 
-		if r.Upstream.Cluster == "" {
+		if mock := r.Upstream.Mock; mock != nil {
+			if r.Upstream.Cluster != "" || len(r.Upstream.Clusters) > 0 || r.Upstream.BlueGreen != nil || r.Upstream.GRPC != nil || r.Upstream.Dubbo != nil || r.Upstream.GraphQL != nil {
+				return fmt.Errorf("route_v2 %q: upstream.mock cannot be combined with cluster, clusters, blue_green, grpc, dubbo or graphql", r.Name)
+			}
+			if mock.Status != 0 && (mock.Status < 100 || mock.Status > 599) {
+				return fmt.Errorf("route_v2 %q: upstream.mock.status %d is not a valid HTTP status", r.Name, mock.Status)
+			}
+		} else if r.Upstream.BlueGreen != nil {
+			if err := validateBlueGreen(&r.Upstream); err != nil {
+				return fmt.Errorf("route_v2 %q: %w", r.Name, err)
+			}
+		} else if len(r.Upstream.Clusters) > 0 {
+			if err := validateClusterSplit(&r.Upstream); err != nil {
+				return fmt.Errorf("route_v2 %q: %w", r.Name, err)
+			}
+		} else if r.Upstream.Cluster == "" {
 			return fmt.Errorf("route_v2 %q: upstream.cluster is required", r.Name)
+		} else if r.Upstream.Sticky != nil {
+			return fmt.Errorf("route_v2 %q: upstream.sticky requires upstream.clusters", r.Name)
 		}
 
-		if len(clusterNames) > 0 && !clusterNames[r.Upstream.Cluster] {
-			return fmt.Errorf("route_v2 %q references unknown cluster %q", r.Name, r.Upstream.Cluster)
+		if r.Tier != "" && !validTier(r.Tier) {
+			return fmt.Errorf("route_v2 %q: unsupported tier %q, must be 'critical', 'high', 'normal' or 'low'", r.Name, r.Tier)
+		}
+		if err := validateMaintenance("maintenance", r.Maintenance); err != nil {
+			return fmt.Errorf("route_v2 %q: %w", r.Name, err)
+		}
+		if err := validateSchedule(r.Schedule); err != nil {
+			return fmt.Errorf("route_v2 %q: %w", r.Name, err)
+		}
+		if err := validateIdempotency(r.Idempotency); err != nil {
+			return fmt.Errorf("route_v2 %q: %w", r.Name, err)
+		}
+		if err := validateRouteCache(r.Cache); err != nil {
+			return fmt.Errorf("route_v2 %q: %w", r.Name, err)
 		}
 
-		// Validate filters
-		for j, f := range r.Filters {
-			if f.Type == "" {
-				return fmt.Errorf("route_v2 %q filters[%d].type is required", r.Name, j)
+		if r.Upstream.Mirror != nil {
+			if err := validateMirror(&r.Upstream, clusterNames); err != nil {
+				return fmt.Errorf("route_v2 %q: %w", r.Name, err)
 			}
-			switch f.Type {
-			case "strip_prefix":
-				if f.Args == nil || f.Args["prefix"] == "" {
-					return fmt.Errorf("route_v2 %q filters[%d] (strip_prefix): 'prefix' argument is required", r.Name, j)
-				}
-			case "header_set":
-				if f.Args == nil || f.Args["key"] == "" {
-					return fmt.Errorf("route_v2 %q filters[%d] (header_set): 'key' argument is required", r.Name, j)
-				}
+		}
+
+		for _, name := range r.Upstream.ClusterNames() {
+			if len(clusterNames) > 0 && !clusterNames[name] {
+				return fmt.Errorf("route_v2 %q references unknown cluster %q", r.Name, name)
 			}
 		}
 
+		// Validate filters
+		if err := validateFilters(fmt.Sprintf("route_v2 %q filters", r.Name), r.Filters, chains); err != nil {
+			return err
+		}
+
+		if r.Upstream.TimeoutMs < 0 {
+			return fmt.Errorf("route_v2 %q: upstream.timeout_ms must not be negative", r.Name)
+		}
+		if err := checkDuration("upstream.timeout", r.Upstream.Timeout, r.Upstream.TimeoutMs); err != nil {
+			return fmt.Errorf("route_v2 %q: %w", r.Name, err)
+		}
+
 		// Validate gRPC upstream config
-		if r.Upstream.GRPC != nil {
-			if r.Upstream.GRPC.Service == "" {
-				return fmt.Errorf("route_v2 %q: upstream.grpc.service is required", r.Name)
+		if g := r.Upstream.GRPC; g != nil {
+			if g.Passthrough {
+				if err := validateGRPCPassthrough(r.Name, g); err != nil {
+					return err
+				}
+			} else {
+				if g.Service == "" {
+					return fmt.Errorf("route_v2 %q: upstream.grpc.service is required", r.Name)
+				}
+				if g.Method == "" {
+					return fmt.Errorf("route_v2 %q: upstream.grpc.method is required", r.Name)
+				}
+				if len(g.Allow) > 0 {
+					return fmt.Errorf("route_v2 %q: upstream.grpc.allow requires passthrough", r.Name)
+				}
 			}
-			if r.Upstream.GRPC.Method == "" {
-				return fmt.Errorf("route_v2 %q: upstream.grpc.method is required", r.Name)
+			if err := validateTranscodeMode(r.Name, "upstream.grpc.request", g.Request, "json_to_proto"); err != nil {
+				return err
+			}
+			if err := validateTranscodeMode(r.Name, "upstream.grpc.response", g.Response, "proto_to_json"); err != nil {
+				return err
+			}
+			if md := r.Upstream.GRPC.Metadata; md != nil {
+				for j, name := range md.Allow {
+					if name == "" || strings.ContainsAny(name, " :\t\r\n") {
+						return fmt.Errorf("route_v2 %q: upstream.grpc.metadata.allow[%d]: invalid header name %q", r.Name, j, name)
+					}
+				}
 			}
 		}
 
@@ -188,17 +625,252 @@ func validateRoutesV2(routes []RouteV2, clusterNames map[string]bool) error {
 			if r.Upstream.Dubbo.Method == "" {
 				return fmt.Errorf("route_v2 %q: upstream.dubbo.method is required", r.Name)
 			}
+			if err := validateDubboParams(r.Name, r.Upstream.Dubbo); err != nil {
+				return err
+			}
+			if ex := r.Upstream.Dubbo.Exceptions; ex != nil {
+				if ex.Status != 0 && (ex.Status < 400 || ex.Status > 599) {
+					return fmt.Errorf("route_v2 %q: upstream.dubbo.exceptions.status must be an HTTP error status (400-599)", r.Name)
+				}
+				for i, m := range ex.Mappings {
+					if m.Exception == "" {
+						return fmt.Errorf("route_v2 %q: upstream.dubbo.exceptions.mappings[%d]: exception is required", r.Name, i)
+					}
+					if m.Status < 400 || m.Status > 599 {
+						return fmt.Errorf("route_v2 %q: upstream.dubbo.exceptions.mappings[%d]: status must be an HTTP error status (400-599)", r.Name, i)
+					}
+				}
+			}
+			if a := r.Upstream.Dubbo.Attachments; a != nil {
+				if err := validateDubboAttachments(r.Name, "request", a.Request); err != nil {
+					return err
+				}
+				if err := validateDubboAttachments(r.Name, "response", a.Response); err != nil {
+					return err
+				}
+			}
+		}
+
+		// Validate GraphQL upstream config
+		if c := graphQLCacheOf(r.Upstream.GraphQL); c != nil {
+			if err := errors.Join(
+				checkDuration("upstream.graphql.cache.ttl", c.TTL, c.TTLMs),
+				checkSize("upstream.graphql.cache.max_response", c.MaxResponse, "upstream.graphql.cache.max_response_bytes", c.MaxResponseBytes),
+			); err != nil {
+				return fmt.Errorf("route_v2 %q: %w", r.Name, err)
+			}
+			if c.TTLDuration() <= 0 {
+				field := "ttl_ms"
+				if c.TTL != "" {
+					field = "ttl"
+				}
+				return fmt.Errorf("route_v2 %q: upstream.graphql.cache.%s must be positive", r.Name, field)
+			}
+			if c.MaxEntries < 0 || c.MaxResponseBytes < 0 {
+				return fmt.Errorf("route_v2 %q: upstream.graphql.cache limits must not be negative", r.Name)
+			}
+			for i, name := range c.VaryHeaders {
+				if name == "" || strings.ContainsAny(name, " :\t\r\n") {
+					return fmt.Errorf("route_v2 %q: upstream.graphql.cache.vary_headers[%d]: invalid header name %q", r.Name, i, name)
+				}
+			}
+		}
+		if g := r.Upstream.GraphQL; g != nil && g.Allowlist != nil {
+			if len(g.Allowlist.Operations) == 0 && len(g.Allowlist.Hashes) == 0 {
+				return fmt.Errorf("route_v2 %q: upstream.graphql.allowlist must list operations or hashes", r.Name)
+			}
+			for i, name := range g.Allowlist.Operations {
+				if !isGraphQLName(name) {
+					return fmt.Errorf("route_v2 %q: upstream.graphql.allowlist.operations[%d]: invalid operation name %q", r.Name, i, name)
+				}
+			}
+			for i, h := range g.Allowlist.Hashes {
+				if !isSHA256Hex(h) {
+					return fmt.Errorf("route_v2 %q: upstream.graphql.allowlist.hashes[%d]: expected a hex SHA-256 digest, got %q", r.Name, i, h)
+				}
+			}
+		}
+		if g := r.Upstream.GraphQL; g != nil && g.Metrics != nil {
+			if rate := g.Metrics.FieldSampleRate; rate < 0 || rate > 1 {
+				return fmt.Errorf("route_v2 %q: upstream.graphql.metrics.field_sample_rate must be between 0 and 1", r.Name)
+			}
+		}
+		if g := r.Upstream.GraphQL; g != nil && g.Subscriptions != nil {
+			if g.Subscriptions.MaxConnections < 0 || g.Subscriptions.InitTimeoutMs < 0 {
+				return fmt.Errorf("route_v2 %q: upstream.graphql.subscriptions limits must not be negative", r.Name)
+			}
+			if err := checkDuration("upstream.graphql.subscriptions.init_timeout", g.Subscriptions.InitTimeout, g.Subscriptions.InitTimeoutMs); err != nil {
+				return fmt.Errorf("route_v2 %q: %w", r.Name, err)
+			}
+			if g.Federation != nil {
+				return fmt.Errorf("route_v2 %q: upstream.graphql.subscriptions cannot be used with federation", r.Name)
+			}
+		}
+		if g := r.Upstream.GraphQL; g != nil && g.Federation != nil {
+			fed := g.Federation
+			if fed.Supergraph == "" {
+				return fmt.Errorf("route_v2 %q: upstream.graphql.federation.supergraph is required", r.Name)
+			}
+			if len(fed.Subgraphs) == 0 {
+				return fmt.Errorf("route_v2 %q: upstream.graphql.federation.subgraphs must not be empty", r.Name)
+			}
+			seen := make(map[string]bool, len(fed.Subgraphs))
+			for i, sg := range fed.Subgraphs {
+				if sg.Name == "" || sg.Cluster == "" {
+					return fmt.Errorf("route_v2 %q: upstream.graphql.federation.subgraphs[%d]: name and cluster are required", r.Name, i)
+				}
+				if seen[sg.Name] {
+					return fmt.Errorf("route_v2 %q: upstream.graphql.federation: duplicate subgraph %q", r.Name, sg.Name)
+				}
+				seen[sg.Name] = true
+				if len(clusterNames) > 0 && !clusterNames[sg.Cluster] {
+					return fmt.Errorf("route_v2 %q: upstream.graphql.federation.subgraphs[%d] references unknown cluster %q", r.Name, i, sg.Cluster)
+				}
+			}
 		}
 	}
 	return nil
 }
 
+func graphQLCacheOf(g *RouteUpstreamGraphQL) *GraphQLCache {
+	if g == nil {
+		return nil
+	}
+	return g.Cache
+}
+
+// isGraphQLName reports whether s is a valid GraphQL name.
+func isGraphQLName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, c := range s {
+		if c != '_' && (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (i == 0 || c < '0' || c > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// isSHA256Hex reports whether s is a lowercase or uppercase hex SHA-256 digest.
+func isSHA256Hex(s string) bool {
+	if len(s) != 64 {
+		return false
+	}
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') && (c < 'A' || c > 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+// validateDubboParams checks a Dubbo route's argument mapping against its
+// path template.
+func validateDubboParams(routeName string, d *RouteUpstreamDubbo) error {
+	if d.PathTemplate != "" && !strings.HasPrefix(d.PathTemplate, "/") {
+		return fmt.Errorf("route_v2 %q: upstream.dubbo.path_template must start with '/'", routeName)
+	}
+	if len(d.Params) == 0 {
+		return nil
+	}
+	if len(d.ParamTypes) > 0 {
+		return fmt.Errorf("route_v2 %q: upstream.dubbo.params and param_types are mutually exclusive", routeName)
+	}
+	for i, p := range d.Params {
+		if p.Type == "" {
+			return fmt.Errorf("route_v2 %q: upstream.dubbo.params[%d]: type is required", routeName, i)
+		}
+		source, name, _ := strings.Cut(p.From, ".")
+		switch source {
+		case "body":
+			continue
+		case "path":
+			if name != "" && !strings.Contains(d.PathTemplate, "{"+name+"}") {
+				return fmt.Errorf("route_v2 %q: upstream.dubbo.params[%d]: path_template has no segment {%s}", routeName, i, name)
+			}
+		case "query", "header":
+		default:
+			return fmt.Errorf("route_v2 %q: upstream.dubbo.params[%d]: unsupported source %q (supported: path, query, header, body)", routeName, i, p.From)
+		}
+		if name == "" {
+			return fmt.Errorf("route_v2 %q: upstream.dubbo.params[%d]: %s source requires a name", routeName, i, source)
+		}
+	}
+	return nil
+}
+
+// validateDubboAttachments checks the header/attachment pairs of one
+// direction of a Dubbo route's attachment mapping.
+func validateDubboAttachments(routeName, dir string, mappings []DubboAttachmentMapping) error {
+	for i, m := range mappings {
+		if m.Header == "" && m.Attachment == "" {
+			return fmt.Errorf("route_v2 %q: upstream.dubbo.attachments.%s[%d]: header or attachment is required", routeName, dir, i)
+		}
+		if strings.ContainsAny(m.Header, " :\t\r\n") {
+			return fmt.Errorf("route_v2 %q: upstream.dubbo.attachments.%s[%d]: invalid header name %q", routeName, dir, i, m.Header)
+		}
+	}
+	return nil
+}
+
+// validateTranscodeMode checks that a transcode mode is either passthrough or
+// the single conversion supported at that position.
+func validateTranscodeMode(routeName, field string, tm *TranscodeMode, allowed string) error {
+	if tm == nil {
+		return nil
+	}
+	switch tm.Mode {
+	case "", "passthrough", allowed:
+		return nil
+	default:
+		return fmt.Errorf("route_v2 %q: %s.mode must be 'passthrough' or '%s', got %q", routeName, field, allowed, tm.Mode)
+	}
+}
+
+// validateGRPCPassthrough checks a route that forwards gRPC paths unchanged.
+// Such routes have no single method, so they cannot transcode bodies.
+func validateGRPCPassthrough(routeName string, g *RouteUpstreamGRPC) error {
+	if g.Service != "" || g.Method != "" {
+		return fmt.Errorf("route_v2 %q: upstream.grpc.service and method cannot be set with passthrough", routeName)
+	}
+	if transcodeModeOf(g.Request) != "" || transcodeModeOf(g.Response) != "" {
+		return fmt.Errorf("route_v2 %q: upstream.grpc passthrough cannot transcode request or response", routeName)
+	}
+	for j, entry := range g.Allow {
+		service, method, hasMethod := strings.Cut(entry, "/")
+		if service == "" || strings.HasPrefix(entry, "/") || (hasMethod && (method == "" || strings.Contains(method, "/"))) {
+			return fmt.Errorf("route_v2 %q: upstream.grpc.allow[%d]: expected \"pkg.Service\" or \"pkg.Service/Method\", got %q", routeName, j, entry)
+		}
+	}
+	return nil
+}
+
+func transcodeModeOf(tm *TranscodeMode) string {
+	if tm == nil || tm.Mode == "passthrough" {
+		return ""
+	}
+	return tm.Mode
+}
+
 // validateRewrite validates the rewrite rules for a route.
 func validateRewrite(routeName string, rw *RewriteRule) error {
 	if rw == nil {
 		return nil
 	}
 
+	if pr := rw.PathRewrite; pr != nil && pr.Prefix != "" && pr.Path != "" {
+		return fmt.Errorf("route %q: path_rewrite.prefix and path_rewrite.path are mutually exclusive", routeName)
+	}
+	if pr := rw.PathRewrite; pr != nil && pr.RegexRewrite != nil {
+		if pr.Prefix != "" || pr.Path != "" {
+			return fmt.Errorf("route %q: path_rewrite.regex_rewrite cannot be combined with prefix or path", routeName)
+		}
+		if err := validateRegexRewrite(pr.RegexRewrite.Pattern); err != nil {
+			return fmt.Errorf("route %q: path_rewrite.regex_rewrite.pattern: %w", routeName, err)
+		}
+	}
+
 	switch rw.Protocol {
 	case "", "http":
 		// valid, http is default
@@ -1,8 +1,10 @@
 package config
 
 import (
+	"log/slog"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestValidateV2_ValidConfig(t *testing.T) {
@@ -27,7 +29,7 @@ func TestValidateV2_ValidConfig(t *testing.T) {
 					PathPrefix: "/api/v1/http/",
 				},
 				Filters: []RouteFilter{
-					{Type: "strip_prefix", Args: map[string]string{"prefix": "/api/v1/http"}},
+					{Type: "strip_prefix", Args: FilterArgs{"prefix": "/api/v1/http"}},
 				},
 				Upstream: RouteUpstream{Cluster: "user-http", TimeoutMs: 30000},
 			},
@@ -258,6 +260,530 @@ func TestValidateV2_FilterMissingType(t *testing.T) {
 	}
 }
 
+func TestValidateV2_FilterArgs(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter RouteFilter
+		error  string
+	}{
+		{"header set", RouteFilter{Type: "response_header_set", Args: FilterArgs{"key": "X-Served-By", "value": "nexus"}}, ""},
+		{"header remove without key", RouteFilter{Type: "response_header_remove"}, "(response_header_remove): 'key' argument is required"},
+		{"status", RouteFilter{Type: "response_status", Args: FilterArgs{"from": "404", "status": "200"}}, ""},
+		{"status out of range", RouteFilter{Type: "response_status", Args: FilterArgs{"status": "700"}}, "(response_status): 'status' argument must be an HTTP status code"},
+		{"status bad from", RouteFilter{Type: "response_status", Args: FilterArgs{"from": "x", "status": "200"}}, "'from' argument must be an HTTP status code"},
+		{"body replace without find", RouteFilter{Type: "response_body_replace", Args: FilterArgs{"replace": "y"}}, "(response_body_replace): 'find' argument is required"},
+		{"json fields", RouteFilter{Type: "response_json_fields", Args: FilterArgs{"fields": "id,user.name"}}, ""},
+		{"json fields list", RouteFilter{Type: "response_json_fields", Args: FilterArgs{"fields": []any{"id", "user.name"}}}, ""},
+		{"typed status", RouteFilter{Type: "response_status", Args: FilterArgs{"from": 404, "status": float64(200)}}, ""},
+		{"status not a number", RouteFilter{Type: "response_status", Args: FilterArgs{"status": true}}, "'status' argument must be an HTTP status code"},
+		{"typed compress", RouteFilter{Type: "compress", Args: FilterArgs{"encodings": []any{"gzip"}, "level": 9, "min_size": 512}}, ""},
+		{"compress level not a number", RouteFilter{Type: "compress", Args: FilterArgs{"level": "fast"}}, "'level' argument must be between 1 and 9"},
+		{"csrf secure not a boolean", RouteFilter{Type: "csrf", Args: FilterArgs{"secure": 1}}, "'secure' argument must be true or false"},
+		{"prefix not a string", RouteFilter{Type: "strip_prefix", Args: FilterArgs{"prefix": []any{"/a"}}}, "(strip_prefix): 'prefix' argument must be a string"},
+		{"regex rewrite", RouteFilter{Type: "regex_rewrite", Args: FilterArgs{"pattern": `^/api/v(\d+)/(.*)$`, "substitution": "/internal/$2?ver=$1"}}, ""},
+		{"regex rewrite bad pattern", RouteFilter{Type: "regex_rewrite", Args: FilterArgs{"pattern": "("}}, "(regex_rewrite): 'pattern' argument: error parsing regexp"},
+		{"regex rewrite without pattern", RouteFilter{Type: "regex_rewrite"}, "(regex_rewrite): 'pattern' argument: is required"},
+		{"max body", RouteFilter{Type: "max_body", Args: FilterArgs{"size": "1MiB"}}, ""},
+		{"max body without size", RouteFilter{Type: "max_body"}, "(max_body): 'size' argument must be positive"},
+		{"max body bad size", RouteFilter{Type: "max_body", Args: FilterArgs{"size": "1XB"}}, "(max_body): 'size' argument: invalid size"},
+		{"decompress", RouteFilter{Type: "decompress"}, ""},
+		{"decompress bad max size", RouteFilter{Type: "decompress", Args: FilterArgs{"max_size": "lots"}}, "(decompress): 'max_size' argument: invalid size"},
+		{"compress", RouteFilter{Type: "compress", Args: FilterArgs{"encodings": "gzip, deflate", "min_size": "512B", "level": "6"}}, ""},
+		{"compress brotli", RouteFilter{Type: "compress", Args: FilterArgs{"encodings": "br,gzip"}}, `(compress): unsupported encoding "br"`},
+		{"compress bad level", RouteFilter{Type: "compress", Args: FilterArgs{"level": "11"}}, "'level' argument must be between 1 and 9"},
+		{"bandwidth_limit", RouteFilter{Type: "bandwidth_limit", Args: FilterArgs{"rate": "2MiB", "burst": "256KiB", "per": "consumer"}}, ""},
+		{"bandwidth_limit no rate", RouteFilter{Type: "bandwidth_limit", Args: FilterArgs{"per": "route"}}, "'rate' argument must be positive"},
+		{"bandwidth_limit bad per", RouteFilter{Type: "bandwidth_limit", Args: FilterArgs{"rate": "1MiB", "per": "tenant"}}, "'per' must be route, consumer or request"},
+		{"security headers", RouteFilter{Type: "security_headers", Args: FilterArgs{"hsts": "max-age=31536000"}}, ""},
+		{"csrf", RouteFilter{Type: "csrf", Args: FilterArgs{"cookie": "xsrf", "secure": "false"}}, ""},
+		{"csrf bad secure", RouteFilter{Type: "csrf", Args: FilterArgs{"secure": "maybe"}}, "(csrf): 'secure' argument must be true or false"},
+		{"ip acl", RouteFilter{Type: "ip_acl", Args: FilterArgs{"allow": []any{"10.0.0.0/8", "2001:db8::1"}, "deny": "10.0.0.66"}}, ""},
+		{"ip acl empty", RouteFilter{Type: "ip_acl"}, "(ip_acl): 'allow' or 'deny' argument is required"},
+		{"ip acl bad cidr", RouteFilter{Type: "ip_acl", Args: FilterArgs{"deny": "10.0.0.0/40"}}, `(ip_acl): deny[0]: invalid CIDR "10.0.0.0/40"`},
+		{"sign request", RouteFilter{Type: "sign_request", Args: FilterArgs{"key": "s3cret"}}, ""},
+		{"sign request without key", RouteFilter{Type: "sign_request"}, "(sign_request): 'key' argument is required"},
+		{"body transform", RouteFilter{Type: "body_transform", Args: FilterArgs{"phase": "response", "template": `{"id": {{json .uid}}}`}}, ""},
+		{"body transform without template", RouteFilter{Type: "body_transform"}, "(body_transform): 'template' argument is required"},
+		{"body transform bad phase", RouteFilter{Type: "body_transform", Args: FilterArgs{"template": "{}", "phase": "both"}}, "'phase' must be request or response"},
+		{"json fields empty", RouteFilter{Type: "response_json_fields", Args: FilterArgs{"fields": " , "}}, "(response_json_fields): 'fields' argument is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Server:   ServerConfig{Listen: ":8080"},
+				Clusters: []Cluster{{Name: "c", Type: "http", Endpoints: []ClusterEndpoint{{URL: "http://c:8080"}}}},
+				RoutesV2: []RouteV2{{Name: "r", Match: RouteMatch{PathPrefix: "/"}, Filters: []RouteFilter{tt.filter}, Upstream: RouteUpstream{Cluster: "c"}}},
+			}
+			err := Validate(cfg)
+			if tt.error == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.error) {
+				t.Errorf("expected error containing %q, got %v", tt.error, err)
+			}
+		})
+	}
+}
+
+func TestValidateV2_Mock(t *testing.T) {
+	tests := []struct {
+		name     string
+		upstream RouteUpstream
+		error    string
+	}{
+		{"mock", RouteUpstream{Mock: &RouteUpstreamMock{Status: 201, Body: `{"ok": true}`}}, ""},
+		{"mock with cluster", RouteUpstream{Cluster: "c", Mock: &RouteUpstreamMock{}}, `route_v2 "r": upstream.mock cannot be combined with cluster`},
+		{"mock bad status", RouteUpstream{Mock: &RouteUpstreamMock{Status: 42}}, "upstream.mock.status 42 is not a valid HTTP status"},
+		{"no cluster", RouteUpstream{}, "upstream.cluster is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Server:   ServerConfig{Listen: ":8080"},
+				Clusters: []Cluster{{Name: "c", Type: "http", Endpoints: []ClusterEndpoint{{URL: "http://c:8080"}}}},
+				RoutesV2: []RouteV2{{Name: "r", Match: RouteMatch{PathPrefix: "/"}, Upstream: tt.upstream}},
+			}
+			err := Validate(cfg)
+			if tt.error == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.error) {
+				t.Errorf("expected error containing %q, got %v", tt.error, err)
+			}
+		})
+	}
+}
+
+func TestValidateV2_ClusterSplit(t *testing.T) {
+	split := []WeightedCluster{{Name: "stable", Weight: 90}, {Name: "canary", Weight: 10}}
+	tests := []struct {
+		name     string
+		upstream RouteUpstream
+		error    string
+	}{
+		{"split", RouteUpstream{Clusters: split}, ""},
+		{"sticky header", RouteUpstream{Clusters: split, Sticky: &StickySplit{Header: "X-User-ID"}}, ""},
+		{"sticky subject", RouteUpstream{Clusters: split, Sticky: &StickySplit{}}, ""},
+		{"zero weight", RouteUpstream{Clusters: []WeightedCluster{{Name: "stable", Weight: 100}, {Name: "canary"}}}, ""},
+		{"with cluster", RouteUpstream{Cluster: "stable", Clusters: split}, "upstream.cluster and upstream.clusters are mutually exclusive"},
+		{"with grpc", RouteUpstream{Clusters: split, GRPC: &RouteUpstreamGRPC{Passthrough: true}}, "upstream.clusters cannot be combined with grpc"},
+		{"with mock", RouteUpstream{Clusters: split, Mock: &RouteUpstreamMock{}}, "upstream.mock cannot be combined with cluster, clusters"},
+		{"missing name", RouteUpstream{Clusters: []WeightedCluster{{Weight: 1}}}, "upstream.clusters[0].name is required"},
+		{"duplicate", RouteUpstream{Clusters: []WeightedCluster{{Name: "stable", Weight: 1}, {Name: "stable", Weight: 1}}}, `upstream.clusters[1]: duplicate cluster "stable"`},
+		{"negative weight", RouteUpstream{Clusters: []WeightedCluster{{Name: "stable", Weight: -1}}}, "upstream.clusters[0].weight must not be negative"},
+		{"no weight", RouteUpstream{Clusters: []WeightedCluster{{Name: "stable"}}}, "at least one weight must be positive"},
+		{"unknown cluster", RouteUpstream{Clusters: []WeightedCluster{{Name: "stable", Weight: 1}, {Name: "beta", Weight: 1}}}, `references unknown cluster "beta"`},
+		{"sticky header and cookie", RouteUpstream{Clusters: split, Sticky: &StickySplit{Header: "X-User-ID", Cookie: "uid"}}, "upstream.sticky: header and cookie are mutually exclusive"},
+		{"sticky without split", RouteUpstream{Cluster: "stable", Sticky: &StickySplit{}}, "upstream.sticky requires upstream.clusters"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Server: ServerConfig{Listen: ":8080"},
+				Clusters: []Cluster{
+					{Name: "stable", Type: "http", Endpoints: []ClusterEndpoint{{URL: "http://stable:8080"}}},
+					{Name: "canary", Type: "http", Endpoints: []ClusterEndpoint{{URL: "http://canary:8080"}}},
+				},
+				RoutesV2: []RouteV2{{Name: "r", Match: RouteMatch{PathPrefix: "/"}, Upstream: tt.upstream}},
+			}
+			err := Validate(cfg)
+			if tt.error == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.error) {
+				t.Errorf("expected error containing %q, got %v", tt.error, err)
+			}
+		})
+	}
+}
+
+func TestValidateV2_Mirror(t *testing.T) {
+	tests := []struct {
+		name     string
+		upstream RouteUpstream
+		error    string
+	}{
+		{"mirror", RouteUpstream{Cluster: "c", Mirror: &RouteMirror{Cluster: "shadow", Percent: 10, MaxBody: "64KiB"}}, ""},
+		{"mirror split", RouteUpstream{Clusters: []WeightedCluster{{Name: "c", Weight: 1}}, Mirror: &RouteMirror{Cluster: "shadow", Percent: 100}}, ""},
+		{"missing cluster", RouteUpstream{Cluster: "c", Mirror: &RouteMirror{Percent: 10}}, "upstream.mirror.cluster is required"},
+		{"unknown cluster", RouteUpstream{Cluster: "c", Mirror: &RouteMirror{Cluster: "beta", Percent: 10}}, `upstream.mirror references unknown cluster "beta"`},
+		{"zero percent", RouteUpstream{Cluster: "c", Mirror: &RouteMirror{Cluster: "shadow"}}, "upstream.mirror.percent must be above 0 and at most 100, got 0"},
+		{"over 100 percent", RouteUpstream{Cluster: "c", Mirror: &RouteMirror{Cluster: "shadow", Percent: 150}}, "got 150"},
+		{"bad max body", RouteUpstream{Cluster: "c", Mirror: &RouteMirror{Cluster: "shadow", Percent: 10, MaxBody: "lots"}}, "upstream.mirror.max_body: invalid size"},
+		{"with grpc", RouteUpstream{Cluster: "c", GRPC: &RouteUpstreamGRPC{Passthrough: true}, Mirror: &RouteMirror{Cluster: "shadow", Percent: 10}}, "upstream.mirror cannot be combined with mock, grpc, dubbo or graphql"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Server: ServerConfig{Listen: ":8080"},
+				Clusters: []Cluster{
+					{Name: "c", Type: "http", Endpoints: []ClusterEndpoint{{URL: "http://c:8080"}}},
+					{Name: "shadow", Type: "http", Endpoints: []ClusterEndpoint{{URL: "http://shadow:8080"}}},
+				},
+				RoutesV2: []RouteV2{{Name: "r", Match: RouteMatch{PathPrefix: "/"}, Upstream: tt.upstream}},
+			}
+			err := Validate(cfg)
+			if tt.error == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.error) {
+				t.Errorf("expected error containing %q, got %v", tt.error, err)
+			}
+		})
+	}
+}
+
+func TestValidateV2_BlueGreen(t *testing.T) {
+	tests := []struct {
+		name     string
+		upstream RouteUpstream
+		error    string
+	}{
+		{"blue green", RouteUpstream{BlueGreen: &BlueGreen{Blue: "c", Green: "next", Active: "green", Bake: "10m", MaxErrorRate: 0.1, MinRequests: 50}}, ""},
+		{"with cluster", RouteUpstream{Cluster: "c", BlueGreen: &BlueGreen{Blue: "c", Green: "next"}}, "upstream.blue_green cannot be combined with cluster, clusters or sticky"},
+		{"missing green", RouteUpstream{BlueGreen: &BlueGreen{Blue: "c"}}, "upstream.blue_green: blue and green are required"},
+		{"same cluster", RouteUpstream{BlueGreen: &BlueGreen{Blue: "c", Green: "c"}}, "blue and green must be different clusters"},
+		{"bad active", RouteUpstream{BlueGreen: &BlueGreen{Blue: "c", Green: "next", Active: "red"}}, `upstream.blue_green.active: must be 'blue' or 'green', got "red"`},
+		{"bad bake", RouteUpstream{BlueGreen: &BlueGreen{Blue: "c", Green: "next", Bake: "soon"}}, "upstream.blue_green.bake:"},
+		{"bad error rate", RouteUpstream{BlueGreen: &BlueGreen{Blue: "c", Green: "next", MaxErrorRate: 5}}, "upstream.blue_green.max_error_rate must be between 0 and 1, got 5"},
+		{"unknown cluster", RouteUpstream{BlueGreen: &BlueGreen{Blue: "c", Green: "beta"}}, `references unknown cluster "beta"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Server: ServerConfig{Listen: ":8080"},
+				Clusters: []Cluster{
+					{Name: "c", Type: "http", Endpoints: []ClusterEndpoint{{URL: "http://c:8080"}}},
+					{Name: "next", Type: "http", Endpoints: []ClusterEndpoint{{URL: "http://next:8080"}}},
+				},
+				RoutesV2: []RouteV2{{Name: "r", Match: RouteMatch{PathPrefix: "/"}, Upstream: tt.upstream}},
+			}
+			err := Validate(cfg)
+			if tt.error == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.error) {
+				t.Errorf("expected error containing %q, got %v", tt.error, err)
+			}
+		})
+	}
+}
+
+func TestValidateV2_Maintenance(t *testing.T) {
+	tests := []struct {
+		name  string
+		cfg   func(*Config)
+		error string
+	}{
+		{"global", func(c *Config) { c.Maintenance = &Maintenance{Enabled: true, RetryAfter: "5m"} }, ""},
+		{"route", func(c *Config) { c.RoutesV2[0].Maintenance = &Maintenance{Enabled: true, Status: 503} }, ""},
+		{"global bad status", func(c *Config) { c.Maintenance = &Maintenance{Status: 700} }, "maintenance.status 700 is not a valid HTTP status"},
+		{"global bad retry", func(c *Config) { c.Maintenance = &Maintenance{RetryAfter: "later"} }, "maintenance.retry_after:"},
+		{"route bad status", func(c *Config) { c.RoutesV2[0].Maintenance = &Maintenance{Status: 99} }, `route_v2 "r": maintenance.status 99`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Server:   ServerConfig{Listen: ":8080"},
+				Clusters: []Cluster{{Name: "c", Type: "http", Endpoints: []ClusterEndpoint{{URL: "http://c:8080"}}}},
+				RoutesV2: []RouteV2{{Name: "r", Match: RouteMatch{PathPrefix: "/"}, Upstream: RouteUpstream{Cluster: "c"}}},
+			}
+			tt.cfg(cfg)
+			err := Validate(cfg)
+			if tt.error == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.error) {
+				t.Errorf("expected error containing %q, got %v", tt.error, err)
+			}
+		})
+	}
+}
+
+func TestValidateV2_LoadShedding(t *testing.T) {
+	tests := []struct {
+		name  string
+		cfg   func(*Config)
+		error string
+	}{
+		{"in flight", func(c *Config) { c.LoadShedding = &LoadShedding{MaxInFlight: 500, DefaultTier: "low"} }, ""},
+		{"latency", func(c *Config) { c.LoadShedding = &LoadShedding{MaxLatency: "250ms", Header: "X-Priority"} }, ""},
+		{"route tier", func(c *Config) { c.RoutesV2[0].Tier = "critical" }, ""},
+		{"no threshold", func(c *Config) { c.LoadShedding = &LoadShedding{Header: "X-Priority"} }, "max_in_flight or max_latency is required"},
+		{"negative", func(c *Config) { c.LoadShedding = &LoadShedding{MaxInFlight: -1} }, "load_shedding.max_in_flight must not be negative"},
+		{"bad latency", func(c *Config) { c.LoadShedding = &LoadShedding{MaxLatency: "soon"} }, "load_shedding.max_latency:"},
+		{"bad default", func(c *Config) { c.LoadShedding = &LoadShedding{MaxInFlight: 1, DefaultTier: "urgent"} }, `unsupported tier "urgent"`},
+		{"bad route tier", func(c *Config) { c.RoutesV2[0].Tier = "bulk" }, `route_v2 "r": unsupported tier "bulk"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Server:   ServerConfig{Listen: ":8080"},
+				Clusters: []Cluster{{Name: "c", Type: "http", Endpoints: []ClusterEndpoint{{URL: "http://c:8080"}}}},
+				RoutesV2: []RouteV2{{Name: "r", Match: RouteMatch{PathPrefix: "/"}, Upstream: RouteUpstream{Cluster: "c"}}},
+			}
+			tt.cfg(cfg)
+			err := Validate(cfg)
+			if tt.error == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.error) {
+				t.Errorf("expected error containing %q, got %v", tt.error, err)
+			}
+		})
+	}
+}
+
+func TestValidateV2_Watchdog(t *testing.T) {
+	tests := []struct {
+		name  string
+		cfg   func(*Config)
+		error string
+	}{
+		{"heap", func(c *Config) { c.Watchdog = &Watchdog{MaxHeap: "1GiB"} }, ""},
+		{"all", func(c *Config) { c.Watchdog = &Watchdog{Interval: "500ms", MaxGoroutines: 10000, MaxLag: "100ms"} }, ""},
+		{"no threshold", func(c *Config) { c.Watchdog = &Watchdog{Interval: "1s"} }, "max_heap, max_goroutines or max_lag is required"},
+		{"negative goroutines", func(c *Config) { c.Watchdog = &Watchdog{MaxGoroutines: -1} }, "watchdog.max_goroutines must not be negative"},
+		{"bad heap", func(c *Config) { c.Watchdog = &Watchdog{MaxHeap: "lots"} }, "watchdog.max_heap:"},
+		{"bad lag", func(c *Config) { c.Watchdog = &Watchdog{MaxLag: "-1s"} }, "watchdog.max_lag must not be negative"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Server:   ServerConfig{Listen: ":8080"},
+				Clusters: []Cluster{{Name: "c", Type: "http", Endpoints: []ClusterEndpoint{{URL: "http://c:8080"}}}},
+				RoutesV2: []RouteV2{{Name: "r", Match: RouteMatch{PathPrefix: "/"}, Upstream: RouteUpstream{Cluster: "c"}}},
+			}
+			tt.cfg(cfg)
+			err := Validate(cfg)
+			if tt.error == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.error) {
+				t.Errorf("expected error containing %q, got %v", tt.error, err)
+			}
+		})
+	}
+	w := &Watchdog{MaxHeap: "512MiB", MaxLag: "50ms"}
+	if w.SampleInterval() != DefaultWatchdogInterval || w.HeapLimit() != 512<<20 || w.LagLimit() != 50*time.Millisecond {
+		t.Errorf("watchdog accessors: %v %d %v", w.SampleInterval(), w.HeapLimit(), w.LagLimit())
+	}
+}
+
+func TestValidateV2_Logging(t *testing.T) {
+	tests := []struct {
+		name    string
+		logging LoggingConfig
+		error   string
+	}{
+		{"defaults", LoggingConfig{}, ""},
+		{"text debug", LoggingConfig{Level: "DEBUG", Format: "text"}, ""},
+		{"sampling", LoggingConfig{Level: "warning", Sampling: &LogSampling{Interval: "10s", First: 10, Thereafter: 100}}, ""},
+		{"bad level", LoggingConfig{Level: "verbose"}, `logging.level: unknown log level "verbose"`},
+		{"bad format", LoggingConfig{Format: "logfmt"}, "logging.format must be json or text"},
+		{"bad interval", LoggingConfig{Sampling: &LogSampling{Interval: "often"}}, "logging.sampling.interval:"},
+		{"negative", LoggingConfig{Sampling: &LogSampling{First: -1}}, "must not be negative"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Server: ServerConfig{Listen: ":8080"}, Logging: tt.logging}
+			err := Validate(cfg)
+			if tt.error == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.error) {
+				t.Errorf("expected error containing %q, got %v", tt.error, err)
+			}
+		})
+	}
+	l := &LoggingConfig{Level: "Warn"}
+	if l.LogLevel() != slog.LevelWarn {
+		t.Errorf("log level %v", l.LogLevel())
+	}
+	s := &LogSampling{}
+	if s.SampleInterval() != DefaultLogSamplingInterval {
+		t.Errorf("sampling interval %v", s.SampleInterval())
+	}
+}
+
+func TestValidateV2_Schedule(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule RouteSchedule
+		error    string
+	}{
+		{"bounds", RouteSchedule{Start: "2026-11-11T00:00:00+08:00", End: "2026-11-12T00:00:00+08:00"}, ""},
+		{"windows", RouteSchedule{Timezone: "Europe/Berlin", Windows: []ScheduleWindow{{Days: []string{"mon-fri", "sun"}, From: "22:00", To: "06:00"}, {From: "12:00", To: "24:00"}}}, ""},
+		{"bad start", RouteSchedule{Start: "tomorrow"}, "schedule.start:"},
+		{"end before start", RouteSchedule{Start: "2026-11-12T00:00:00Z", End: "2026-11-11T00:00:00Z"}, "schedule: end must be after start"},
+		{"bad timezone", RouteSchedule{Timezone: "Mars/Olympus"}, "schedule.timezone:"},
+		{"bad day", RouteSchedule{Windows: []ScheduleWindow{{Days: []string{"someday"}, From: "09:00", To: "17:00"}}}, `schedule.windows[0].days: unsupported day "someday"`},
+		{"bad time", RouteSchedule{Windows: []ScheduleWindow{{From: "9am", To: "17:00"}}}, "schedule.windows[0].from:"},
+		{"empty window", RouteSchedule{Windows: []ScheduleWindow{{From: "09:00", To: "09:00"}}}, "schedule.windows[0].to:"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Server:   ServerConfig{Listen: ":8080"},
+				Clusters: []Cluster{{Name: "c", Type: "http", Endpoints: []ClusterEndpoint{{URL: "http://c:8080"}}}},
+				RoutesV2: []RouteV2{{Name: "r", Match: RouteMatch{PathPrefix: "/"}, Upstream: RouteUpstream{Cluster: "c"}, Schedule: &tt.schedule}},
+			}
+			err := Validate(cfg)
+			if tt.error == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.error) {
+				t.Errorf("expected error containing %q, got %v", tt.error, err)
+			}
+		})
+	}
+}
+
+func TestValidateV2_Tenancy(t *testing.T) {
+	tests := []struct {
+		name    string
+		tenancy Tenancy
+		error   string
+	}{
+		{"header", Tenancy{Header: "X-Tenant", RateLimit: &TenantRateLimit{Rate: 100, Window: "1s"}}, ""},
+		{"tenants", Tenancy{Claim: "tenant", Tenants: []Tenant{{Name: "acme", RateLimit: &TenantRateLimit{Rate: 500}, Clusters: map[string]string{"c": "c-acme"}}}}, ""},
+		{"no source", Tenancy{Default: "public"}, "tenancy: header or claim is required"},
+		{"bad rate", Tenancy{Header: "X-Tenant", RateLimit: &TenantRateLimit{}}, "tenancy.rate_limit.rate must be positive"},
+		{"zero window", Tenancy{Header: "X-Tenant", RateLimit: &TenantRateLimit{Rate: 1, Window: "0s"}}, "tenancy.rate_limit.window must be positive"},
+		{"unnamed", Tenancy{Header: "X-Tenant", Tenants: []Tenant{{}}}, "tenancy.tenants[0]: name is required"},
+		{"duplicate", Tenancy{Header: "X-Tenant", Tenants: []Tenant{{Name: "a"}, {Name: "a"}}}, `tenancy.tenants[1]: duplicate tenant "a"`},
+		{"unknown cluster", Tenancy{Header: "X-Tenant", Tenants: []Tenant{{Name: "a", Clusters: map[string]string{"c": "missing"}}}}, `tenancy.tenants[0].clusters: cluster "missing" not found`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Server: ServerConfig{Listen: ":8080"},
+				Clusters: []Cluster{
+					{Name: "c", Type: "http", Endpoints: []ClusterEndpoint{{URL: "http://c:8080"}}},
+					{Name: "c-acme", Type: "http", Endpoints: []ClusterEndpoint{{URL: "http://c-acme:8080"}}},
+				},
+				RoutesV2: []RouteV2{{Name: "r", Match: RouteMatch{PathPrefix: "/"}, Upstream: RouteUpstream{Cluster: "c"}}},
+				Tenancy:  &tt.tenancy,
+			}
+			err := Validate(cfg)
+			if tt.error == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.error) {
+				t.Errorf("expected error containing %q, got %v", tt.error, err)
+			}
+		})
+	}
+}
+
+func TestValidateV2_Idempotency(t *testing.T) {
+	tests := []struct {
+		name  string
+		idem  Idempotency
+		error string
+	}{
+		{"defaults", Idempotency{}, ""},
+		{"custom", Idempotency{Header: "X-Request-Key", Window: "10m", Methods: []string{"POST", "PUT"}, MaxEntries: 100, MaxResponse: "64KiB"}, ""},
+		{"zero window", Idempotency{Window: "0s"}, "idempotency.window must be positive"},
+		{"bad window", Idempotency{Window: "daily"}, "idempotency.window:"},
+		{"negative entries", Idempotency{MaxEntries: -1}, "idempotency.max_entries must not be negative"},
+		{"bad size", Idempotency{MaxResponse: "big"}, "idempotency.max_response:"},
+		{"bad method", Idempotency{Methods: []string{"post"}}, `idempotency.methods: invalid method "post"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Server:   ServerConfig{Listen: ":8080"},
+				Clusters: []Cluster{{Name: "c", Type: "http", Endpoints: []ClusterEndpoint{{URL: "http://c:8080"}}}},
+				RoutesV2: []RouteV2{{Name: "r", Match: RouteMatch{PathPrefix: "/"}, Upstream: RouteUpstream{Cluster: "c"}, Idempotency: &tt.idem}},
+			}
+			err := Validate(cfg)
+			if tt.error == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.error) {
+				t.Errorf("expected error containing %q, got %v", tt.error, err)
+			}
+		})
+	}
+}
+
+func TestValidateV2_ResponseCache(t *testing.T) {
+	tests := []struct {
+		name  string
+		store *ResponseCache
+		cache RouteCache
+		error string
+	}{
+		{"defaults", nil, RouteCache{}, ""},
+		{"custom", &ResponseCache{MaxEntries: 100, MaxResponse: "64KiB"}, RouteCache{TTL: "5m", Key: "{{.Path}}{{.Tenant}}"}, ""},
+		{"redis", &ResponseCache{Store: "redis", Redis: &CacheRedis{Address: "redis:6379", DB: 1, Timeout: "200ms"}}, RouteCache{}, ""},
+		{"redis without address", &ResponseCache{Store: "redis"}, RouteCache{}, "response_cache.redis.address is required"},
+		{"bad store", &ResponseCache{Store: "disk"}, RouteCache{}, `unsupported store "disk"`},
+		{"negative entries", &ResponseCache{MaxEntries: -1}, RouteCache{}, "response_cache.max_entries must not be negative"},
+		{"bad size", &ResponseCache{MaxResponse: "big"}, RouteCache{}, "response_cache.max_response:"},
+		{"bad timeout", &ResponseCache{Store: "redis", Redis: &CacheRedis{Address: "redis:6379", Timeout: "soon"}}, RouteCache{}, "response_cache.redis.timeout:"},
+		{"negative ttl", nil, RouteCache{TTL: "-1s"}, "cache.ttl must not be negative"},
+		{"bad key", nil, RouteCache{Key: "{{.Path"}, "cache.key:"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Server:        ServerConfig{Listen: ":8080"},
+				Clusters:      []Cluster{{Name: "c", Type: "http", Endpoints: []ClusterEndpoint{{URL: "http://c:8080"}}}},
+				RoutesV2:      []RouteV2{{Name: "r", Match: RouteMatch{PathPrefix: "/"}, Upstream: RouteUpstream{Cluster: "c"}, Cache: &tt.cache}},
+				ResponseCache: tt.store,
+			}
+			err := Validate(cfg)
+			if tt.error == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.error) {
+				t.Errorf("expected error containing %q, got %v", tt.error, err)
+			}
+		})
+	}
+}
+
 func TestValidateV2_StripPrefixMissingArg(t *testing.T) {
 	cfg := &Config{
 		Server: ServerConfig{Listen: ":8080"},
@@ -269,7 +795,7 @@ func TestValidateV2_StripPrefixMissingArg(t *testing.T) {
 				Name:  "test",
 				Match: RouteMatch{PathPrefix: "/"},
 				Filters: []RouteFilter{
-					{Type: "strip_prefix", Args: map[string]string{}},
+					{Type: "strip_prefix", Args: FilterArgs{}},
 				},
 				Upstream: RouteUpstream{Cluster: "test"},
 			},
@@ -304,6 +830,98 @@ func TestValidateV2_GRPCUpstreamMissingService(t *testing.T) {
 	}
 }
 
+func TestValidateV2_GRPCInvalidTranscodeMode(t *testing.T) {
+	cfg := &Config{
+		Server: ServerConfig{Listen: ":8080"},
+		Clusters: []Cluster{
+			{Name: "test", Type: "grpc", Endpoints: []ClusterEndpoint{{Target: "dns:///test:9090"}}},
+		},
+		RoutesV2: []RouteV2{
+			{
+				Name:  "test",
+				Match: RouteMatch{Path: "/api/test"},
+				Upstream: RouteUpstream{
+					Cluster: "test",
+					GRPC: &RouteUpstreamGRPC{
+						Service: "test.v1.Test",
+						Method:  "Test",
+						Request: &TranscodeMode{Mode: "proto_to_json"},
+					},
+				},
+			},
+		},
+	}
+	err := Validate(cfg)
+	if err == nil {
+		t.Fatal("expected error for invalid request transcode mode")
+	}
+	if !strings.Contains(err.Error(), "upstream.grpc.request.mode") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestValidateV2_GRPCInvalidMetadataAllow(t *testing.T) {
+	cfg := &Config{
+		Server: ServerConfig{Listen: ":8080"},
+		Clusters: []Cluster{
+			{Name: "test", Type: "grpc", Endpoints: []ClusterEndpoint{{Target: "dns:///test:9090"}}},
+		},
+		RoutesV2: []RouteV2{
+			{
+				Name:  "test",
+				Match: RouteMatch{Path: "/api/test"},
+				Upstream: RouteUpstream{
+					Cluster: "test",
+					GRPC: &RouteUpstreamGRPC{
+						Service:  "test.v1.Test",
+						Method:   "Test",
+						Metadata: &GRPCMetadata{Allow: []string{"x-tenant", ":authority"}},
+					},
+				},
+			},
+		},
+	}
+	err := Validate(cfg)
+	if err == nil {
+		t.Fatal("expected error for invalid metadata header name")
+	}
+	if !strings.Contains(err.Error(), "upstream.grpc.metadata.allow[1]") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestValidateV2_GRPCNegativeLimits(t *testing.T) {
+	cfg := &Config{
+		Server: ServerConfig{Listen: ":8080"},
+		Clusters: []Cluster{
+			{Name: "test", Type: "grpc", Endpoints: []ClusterEndpoint{{Target: "dns:///test:9090"}},
+				GRPC: &ClusterGRPC{MaxConcurrentStreams: -1}},
+		},
+	}
+	if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), "must not be negative") {
+		t.Errorf("expected negative limit error, got %v", err)
+	}
+}
+
+func TestValidateV2_NegativeTimeout(t *testing.T) {
+	cfg := &Config{
+		Server: ServerConfig{Listen: ":8080"},
+		Clusters: []Cluster{
+			{Name: "test", Endpoints: []ClusterEndpoint{{URL: "http://127.0.0.1:9000"}}},
+		},
+		RoutesV2: []RouteV2{
+			{
+				Name:     "test",
+				Match:    RouteMatch{Path: "/api/test"},
+				Upstream: RouteUpstream{Cluster: "test", TimeoutMs: -1},
+			},
+		},
+	}
+	if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), "timeout_ms") {
+		t.Errorf("expected timeout_ms error, got %v", err)
+	}
+}
+
 func TestValidateV2_DubboUpstreamMissingInterface(t *testing.T) {
 	cfg := &Config{
 		Server: ServerConfig{Listen: ":8080"},
@@ -327,6 +945,209 @@ func TestValidateV2_DubboUpstreamMissingInterface(t *testing.T) {
 	}
 }
 
+func TestValidateV2_DubboInvalidSerialization(t *testing.T) {
+	cfg := &Config{
+		Server: ServerConfig{Listen: ":8080"},
+		Clusters: []Cluster{
+			{Name: "test", Type: "dubbo", Endpoints: []ClusterEndpoint{{Addr: "test:20880"}},
+				Dubbo: &ClusterDubbo{Serialization: "kryo"}},
+		},
+	}
+	if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), "unsupported dubbo serialization") {
+		t.Errorf("expected serialization error, got %v", err)
+	}
+}
+
+func TestValidateV2_DubboTriple(t *testing.T) {
+	tests := []struct {
+		dubbo   ClusterDubbo
+		wantErr string
+	}{
+		{dubbo: ClusterDubbo{Protocol: "triple"}},
+		{dubbo: ClusterDubbo{Protocol: "triple", Serialization: "protobuf"}},
+		{dubbo: ClusterDubbo{Protocol: "triple", Serialization: "json"}, wantErr: "unsupported triple serialization"},
+		{dubbo: ClusterDubbo{Serialization: "protobuf"}, wantErr: "unsupported dubbo serialization"},
+		{dubbo: ClusterDubbo{Protocol: "rest"}, wantErr: "unsupported dubbo protocol"},
+	}
+	for _, tt := range tests {
+		d := tt.dubbo
+		cfg := &Config{
+			Server: ServerConfig{Listen: ":8080"},
+			Clusters: []Cluster{
+				{Name: "test", Type: "dubbo", Endpoints: []ClusterEndpoint{{Addr: "test:50051"}}, Dubbo: &d},
+			},
+		}
+		err := Validate(cfg)
+		if tt.wantErr == "" && err != nil {
+			t.Errorf("%+v: unexpected error %v", d, err)
+		}
+		if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("%+v: expected %q error, got %v", d, tt.wantErr, err)
+		}
+	}
+}
+
+func TestValidateV2_DubboRegistry(t *testing.T) {
+	cfg := &Config{
+		Server: ServerConfig{Listen: ":8080"},
+		Clusters: []Cluster{
+			{
+				Name:      "test",
+				Type:      "dubbo",
+				Endpoints: []ClusterEndpoint{{Addr: "test:20880"}},
+				Dubbo:     &ClusterDubbo{Registry: &DubboRegistry{Type: "nacos", Address: "nacos:8848"}},
+			},
+		},
+	}
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cfg.Clusters[0].Dubbo.HeartbeatMs = -1
+	if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), "connection settings") {
+		t.Errorf("expected connection settings error, got %v", err)
+	}
+	cfg.Clusters[0].Dubbo.HeartbeatMs = 0
+
+	reg := cfg.Clusters[0].Dubbo.Registry
+	reg.Type = "zookeeper"
+	if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), "unsupported dubbo registry type") {
+		t.Errorf("expected registry type error, got %v", err)
+	}
+	reg.Type, reg.Address = "nacos", ""
+	if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), "address is required") {
+		t.Errorf("expected address error, got %v", err)
+	}
+}
+
+func TestValidateV2_DubboExceptions(t *testing.T) {
+	exceptions := &DubboExceptions{
+		Status:   502,
+		Mappings: []DubboExceptionMapping{{Exception: "com.foo.NotFound", Status: 404}, {Exception: "com.foo.*", Status: 409}},
+	}
+	cfg := &Config{
+		Server: ServerConfig{Listen: ":8080"},
+		Clusters: []Cluster{
+			{Name: "test", Type: "dubbo", Endpoints: []ClusterEndpoint{{Addr: "test:20880"}}},
+		},
+		RoutesV2: []RouteV2{
+			{
+				Name:  "test",
+				Match: RouteMatch{Path: "/api/test"},
+				Upstream: RouteUpstream{
+					Cluster: "test",
+					Dubbo:   &RouteUpstreamDubbo{Interface: "com.foo.Svc", Method: "m", Exceptions: exceptions},
+				},
+			},
+		},
+	}
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	exceptions.Status = 200
+	if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), "exceptions.status") {
+		t.Errorf("expected default status error, got %v", err)
+	}
+	exceptions.Status = 0
+	exceptions.Mappings[1].Status = 302
+	if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), "mappings[1]") {
+		t.Errorf("expected mapping status error, got %v", err)
+	}
+	exceptions.Mappings[1] = DubboExceptionMapping{Status: 409}
+	if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), "exception is required") {
+		t.Errorf("expected missing exception error, got %v", err)
+	}
+}
+
+func TestValidateV2_DubboAttachments(t *testing.T) {
+	cfg := &Config{
+		Server: ServerConfig{Listen: ":8080"},
+		Clusters: []Cluster{
+			{Name: "test", Type: "dubbo", Endpoints: []ClusterEndpoint{{Addr: "test:20880"}}},
+		},
+		RoutesV2: []RouteV2{
+			{
+				Name:  "test",
+				Match: RouteMatch{Path: "/api/test"},
+				Upstream: RouteUpstream{
+					Cluster: "test",
+					Dubbo: &RouteUpstreamDubbo{Interface: "com.foo.Svc", Method: "m", Attachments: &DubboAttachments{
+						Request:  []DubboAttachmentMapping{{Header: "X-Tenant"}, {Header: "X-Request-Id", Attachment: "traceId"}},
+						Response: []DubboAttachmentMapping{{Attachment: "biz-code", Header: "X-Biz-Code"}},
+					}},
+				},
+			},
+		},
+	}
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	att := cfg.RoutesV2[0].Upstream.Dubbo.Attachments
+	att.Response = append(att.Response, DubboAttachmentMapping{})
+	if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), "attachments.response[1]") {
+		t.Errorf("expected empty mapping error, got %v", err)
+	}
+	att.Response = nil
+	att.Request[0].Header = "X Tenant"
+	if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), "invalid header name") {
+		t.Errorf("expected invalid header error, got %v", err)
+	}
+}
+
+func TestValidateV2_DubboParams(t *testing.T) {
+	newCfg := func(d *RouteUpstreamDubbo) *Config {
+		d.Interface, d.Method = "com.foo.Svc", "m"
+		return &Config{
+			Server: ServerConfig{Listen: ":8080"},
+			Clusters: []Cluster{
+				{Name: "test", Type: "dubbo", Endpoints: []ClusterEndpoint{{Addr: "test:20880"}}},
+			},
+			RoutesV2: []RouteV2{
+				{
+					Name:     "test",
+					Match:    RouteMatch{PathPrefix: "/api/orders/"},
+					Upstream: RouteUpstream{Cluster: "test", Dubbo: d},
+				},
+			},
+		}
+	}
+
+	valid := &RouteUpstreamDubbo{
+		PathTemplate: "/api/orders/{id}",
+		Params: []DubboParam{
+			{Type: "long", From: "path.id"},
+			{Type: "int", From: "query.limit"},
+			{Type: "java.lang.String", From: "header.X-Tenant"},
+			{Type: "com.foo.Req", From: "body"},
+			{Type: "int", From: "body.qty"},
+		},
+	}
+	if err := Validate(newCfg(valid)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		d    *RouteUpstreamDubbo
+		want string
+	}{
+		{"both forms", &RouteUpstreamDubbo{ParamTypes: []string{"long"}, Params: []DubboParam{{Type: "long", From: "body"}}}, "mutually exclusive"},
+		{"missing type", &RouteUpstreamDubbo{Params: []DubboParam{{From: "body"}}}, "type is required"},
+		{"bad source", &RouteUpstreamDubbo{Params: []DubboParam{{Type: "int", From: "cookie.a"}}}, "unsupported source"},
+		{"missing name", &RouteUpstreamDubbo{Params: []DubboParam{{Type: "int", From: "query"}}}, "requires a name"},
+		{"unknown segment", &RouteUpstreamDubbo{PathTemplate: "/api/orders/{id}", Params: []DubboParam{{Type: "int", From: "path.sku"}}}, "no segment {sku}"},
+		{"relative template", &RouteUpstreamDubbo{PathTemplate: "api/{id}"}, "must start with"},
+	}
+	for _, tt := range tests {
+		err := Validate(newCfg(tt.d))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.name, tt.want, err)
+		}
+	}
+}
+
 func TestValidateV2_ListenersCanReplaceServerListen(t *testing.T) {
 	// When listeners are defined, server.listen is not required
 	cfg := &Config{
@@ -381,8 +1202,8 @@ func TestValidateV2_FullDSLConfig(t *testing.T) {
 					PathPrefix: "/api/v1/http/",
 				},
 				Filters: []RouteFilter{
-					{Type: "strip_prefix", Args: map[string]string{"prefix": "/api/v1/http"}},
-					{Type: "header_set", Args: map[string]string{"key": "x-gw", "value": "nova"}},
+					{Type: "strip_prefix", Args: FilterArgs{"prefix": "/api/v1/http"}},
+					{Type: "header_set", Args: FilterArgs{"key": "x-gw", "value": "nova"}},
 				},
 				Upstream: RouteUpstream{Cluster: "user-http", TimeoutMs: 30000},
 			},
@@ -427,3 +1248,272 @@ func TestValidateV2_FullDSLConfig(t *testing.T) {
 		t.Errorf("expected no error for full DSL config, got %v", err)
 	}
 }
+
+func passthroughRouteConfig(g *RouteUpstreamGRPC) *Config {
+	return &Config{
+		Server: ServerConfig{Listen: ":8080"},
+		Clusters: []Cluster{
+			{Name: "test", Type: "grpc", Endpoints: []ClusterEndpoint{{Target: "dns:///test:9090"}}},
+		},
+		RoutesV2: []RouteV2{
+			{
+				Name:     "test",
+				Match:    RouteMatch{PathPrefix: "/"},
+				Upstream: RouteUpstream{Cluster: "test", GRPC: g},
+			},
+		},
+	}
+}
+
+func TestValidateV2_GRPCPassthrough(t *testing.T) {
+	cfg := passthroughRouteConfig(&RouteUpstreamGRPC{
+		Passthrough: true,
+		Allow:       []string{"user.v1.UserService", "order.v1.OrderService/GetOrder"},
+	})
+	if err := Validate(cfg); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestValidateV2_GRPCPassthroughErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		grpc *RouteUpstreamGRPC
+		want string
+	}{
+		{"method set", &RouteUpstreamGRPC{Passthrough: true, Service: "a.S", Method: "M"}, "cannot be set with passthrough"},
+		{"transcoding", &RouteUpstreamGRPC{Passthrough: true, Request: &TranscodeMode{Mode: "json_to_proto"}}, "cannot transcode"},
+		{"leading slash", &RouteUpstreamGRPC{Passthrough: true, Allow: []string{"/a.S/M"}}, "allow[0]"},
+		{"empty method", &RouteUpstreamGRPC{Passthrough: true, Allow: []string{"a.S/"}}, "allow[0]"},
+		{"allow without passthrough", &RouteUpstreamGRPC{Service: "a.S", Method: "M", Allow: []string{"a.S"}}, "requires passthrough"},
+	}
+	for _, tt := range tests {
+		err := Validate(passthroughRouteConfig(tt.grpc))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.name, tt.want, err)
+		}
+	}
+}
+
+func TestValidateV2_GraphQL(t *testing.T) {
+	hash := strings.Repeat("ab", 32)
+	cfg := &Config{
+		Server: ServerConfig{Listen: ":8080"},
+		Clusters: []Cluster{
+			{Name: "gql", Type: "graphql", Endpoints: []ClusterEndpoint{{URL: "http://gql:8080"}}},
+		},
+		RoutesV2: []RouteV2{
+			{
+				Name:  "test",
+				Match: RouteMatch{Path: "/graphql"},
+				Upstream: RouteUpstream{
+					Cluster: "gql",
+					GraphQL: &RouteUpstreamGraphQL{
+						BlockIntrospection: true,
+						Allowlist:          &GraphQLAllowlist{Operations: []string{"GetUser", "_list2"}, Hashes: []string{hash}},
+					},
+				},
+			},
+		},
+	}
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cfg.Clusters[0].GraphQL = &ClusterGraphQL{MaxBodyBytes: -1}
+	if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), "max_body_bytes") {
+		t.Errorf("expected negative max_body_bytes error, got %v", err)
+	}
+	cfg.Clusters[0].GraphQL = nil
+
+	al := cfg.RoutesV2[0].Upstream.GraphQL.Allowlist
+	al.Operations = []string{"2fast"}
+	if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), "allowlist.operations[0]") {
+		t.Errorf("expected invalid operation name error, got %v", err)
+	}
+	al.Operations = nil
+	al.Hashes = []string{"abc"}
+	if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), "allowlist.hashes[0]") {
+		t.Errorf("expected invalid hash error, got %v", err)
+	}
+	al.Hashes = nil
+	if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), "must list operations or hashes") {
+		t.Errorf("expected empty allowlist error, got %v", err)
+	}
+
+	g := cfg.RoutesV2[0].Upstream.GraphQL
+	g.Allowlist = nil
+	g.Cache = &GraphQLCache{TTLMs: 30000, VaryOnSubject: true, VaryHeaders: []string{"Accept-Language"}}
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	g.Cache.TTLMs = 0
+	if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), "cache.ttl_ms must be positive") {
+		t.Errorf("expected ttl error, got %v", err)
+	}
+	g.Cache.TTLMs, g.Cache.MaxEntries = 1000, -1
+	if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), "must not be negative") {
+		t.Errorf("expected negative limit error, got %v", err)
+	}
+	g.Cache.MaxEntries = 0
+	g.Cache.VaryHeaders = []string{"Bad Header"}
+	if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), "vary_headers[0]") {
+		t.Errorf("expected vary header error, got %v", err)
+	}
+
+	g.Cache = nil
+	g.Metrics = &GraphQLMetrics{FieldSampleRate: 1.5}
+	if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), "field_sample_rate") {
+		t.Errorf("expected sample rate error, got %v", err)
+	}
+	g.Metrics.FieldSampleRate = 0.1
+	g.Federation = &GraphQLFederation{Supergraph: "supergraph.graphql", Subgraphs: []GraphQLSubgraph{{Name: "accounts", Cluster: "gql"}}}
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, tt := range []struct {
+		fed  GraphQLFederation
+		want string
+	}{
+		{GraphQLFederation{Subgraphs: []GraphQLSubgraph{{Name: "a", Cluster: "gql"}}}, "supergraph is required"},
+		{GraphQLFederation{Supergraph: "s.graphql"}, "subgraphs must not be empty"},
+		{GraphQLFederation{Supergraph: "s.graphql", Subgraphs: []GraphQLSubgraph{{Name: "a"}}}, "name and cluster are required"},
+		{GraphQLFederation{Supergraph: "s.graphql", Subgraphs: []GraphQLSubgraph{{Name: "a", Cluster: "gql"}, {Name: "a", Cluster: "gql"}}}, `duplicate subgraph "a"`},
+		{GraphQLFederation{Supergraph: "s.graphql", Subgraphs: []GraphQLSubgraph{{Name: "a", Cluster: "missing"}}}, `unknown cluster "missing"`},
+	} {
+		fed := tt.fed
+		g.Federation = &fed
+		if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("expected error containing %q, got %v", tt.want, err)
+		}
+	}
+
+	g.Subscriptions = &GraphQLSubscriptions{MaxConnections: 100}
+	if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), "subscriptions cannot be used with federation") {
+		t.Errorf("expected federation conflict error, got %v", err)
+	}
+	g.Federation = nil
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	g.Subscriptions.InitTimeoutMs = -1
+	if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), "subscriptions limits must not be negative") {
+		t.Errorf("expected negative subscription limit error, got %v", err)
+	}
+}
+
+func TestValidateV2_GraphQLMatch(t *testing.T) {
+	cfg := &Config{
+		Server: ServerConfig{Listen: ":8080"},
+		Clusters: []Cluster{
+			{Name: "gql", Type: "graphql", Endpoints: []ClusterEndpoint{{URL: "http://gql:8080"}}},
+		},
+		RoutesV2: []RouteV2{
+			{
+				Name:     "mutations",
+				Match:    RouteMatch{Path: "/graphql", GraphQL: &GraphQLMatch{OperationTypes: []string{"mutation"}, OperationNames: []string{"Pay"}}},
+				Upstream: RouteUpstream{Cluster: "gql"},
+			},
+		},
+	}
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, tt := range []struct {
+		match GraphQLMatch
+		want  string
+	}{
+		{GraphQLMatch{}, "must list operation_types or operation_names"},
+		{GraphQLMatch{OperationTypes: []string{"Query"}}, `operation_types[0]: unsupported operation type "Query"`},
+		{GraphQLMatch{OperationNames: []string{"my-op"}}, `operation_names[0]: invalid operation name "my-op"`},
+	} {
+		m := tt.match
+		cfg.RoutesV2[0].Match.GraphQL = &m
+		if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("expected error containing %q, got %v", tt.want, err)
+		}
+	}
+}
+
+func TestValidateV2_QueryMatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		query QueryMatch
+		error string
+	}{
+		{"exists", QueryMatch{Name: "debug"}, ""},
+		{"exact", QueryMatch{Name: "version", Exact: "beta"}, ""},
+		{"regex", QueryMatch{Name: "version", Regex: "v[0-9]+"}, ""},
+		{"missing name", QueryMatch{Exact: "beta"}, `route_v2 "r": match.query[0].name is required`},
+		{"exact and regex", QueryMatch{Name: "v", Exact: "a", Regex: "a"}, "exact and regex are mutually exclusive"},
+		{"bad regex", QueryMatch{Name: "v", Regex: "("}, `route_v2 "r": match.query[0].regex: error parsing regexp`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Server:   ServerConfig{Listen: ":8080"},
+				Clusters: []Cluster{{Name: "c", Type: "http", Endpoints: []ClusterEndpoint{{URL: "http://c:8080"}}}},
+				RoutesV2: []RouteV2{{
+					Name:     "r",
+					Match:    RouteMatch{PathPrefix: "/", Query: []QueryMatch{tt.query}},
+					Upstream: RouteUpstream{Cluster: "c"},
+				}},
+			}
+			err := Validate(cfg)
+			if tt.error == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.error) {
+				t.Errorf("expected error containing %q, got %v", tt.error, err)
+			}
+		})
+	}
+}
+
+func TestValidateV2_HeaderCookieMatch(t *testing.T) {
+	no := false
+	tests := []struct {
+		name    string
+		headers []HeaderMatch
+		cookies []CookieMatch
+		error   string
+	}{
+		{"header regex", []HeaderMatch{{Name: "x-cohort", Regex: "beta-.*", Invert: true}}, nil, ""},
+		{"header absent", []HeaderMatch{{Name: "authorization", Present: &no}}, nil, ""},
+		{"cookie absent", nil, []CookieMatch{{Name: "session", Present: &no}}, ""},
+		{"header missing name", []HeaderMatch{{Exact: "1"}}, nil, `route_v2 "r": match.headers[0].name is required`},
+		{"header regex and exact", []HeaderMatch{{Name: "x", Exact: "a", Regex: "a"}}, nil, "regex cannot be combined with exact or contains"},
+		{"header absent with value", []HeaderMatch{{Name: "x", Contains: "a", Present: &no}}, nil, "match.headers[0]: present: false cannot be combined with a value"},
+		{"header bad regex", []HeaderMatch{{Name: "x", Regex: "("}}, nil, `route_v2 "r": match.headers[0].regex: error parsing regexp`},
+		{"cookie missing name", nil, []CookieMatch{{Exact: "1"}}, `route_v2 "r": match.cookies[0].name is required`},
+		{"cookie exact and regex", nil, []CookieMatch{{Name: "c", Exact: "a", Regex: "a"}}, "exact and regex are mutually exclusive"},
+		{"cookie absent with value", nil, []CookieMatch{{Name: "c", Regex: "a", Present: &no}}, "match.cookies[0]: present: false cannot be combined with a value"},
+		{"cookie bad regex", nil, []CookieMatch{{Name: "c", Regex: "("}}, `route_v2 "r": match.cookies[0].regex: error parsing regexp`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Server:   ServerConfig{Listen: ":8080"},
+				Clusters: []Cluster{{Name: "c", Type: "http", Endpoints: []ClusterEndpoint{{URL: "http://c:8080"}}}},
+				RoutesV2: []RouteV2{{
+					Name:     "r",
+					Match:    RouteMatch{PathPrefix: "/", Headers: tt.headers, Cookies: tt.cookies},
+					Upstream: RouteUpstream{Cluster: "c"},
+				}},
+			}
+			err := Validate(cfg)
+			if tt.error == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.error) {
+				t.Errorf("expected error containing %q, got %v", tt.error, err)
+			}
+		})
+	}
+}
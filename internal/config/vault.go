@@ -0,0 +1,87 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// VaultSecretProvider reads secrets from HashiCorp Vault over its HTTP API.
+// References name the API path of the secret and a field, e.g.
+// "kv/data/nexus#api_key" for a KV version 2 mount or "secret/nexus#api_key"
+// for version 1.
+type VaultSecretProvider struct {
+	Client *http.Client
+	// Address is the Vault API, e.g. "https://vault:8200"; empty uses
+	// VAULT_ADDR.
+	Address string
+	// Token authenticates requests; empty uses VAULT_TOKEN.
+	Token string
+	// Namespace selects a Vault Enterprise namespace; empty uses
+	// VAULT_NAMESPACE.
+	Namespace string
+}
+
+// Resolve returns a field of the secret at ref.
+func (p *VaultSecretProvider) Resolve(ctx context.Context, ref string) (string, error) {
+	path, field, _ := strings.Cut(ref, "#")
+	addr := firstNonEmpty(p.Address, os.Getenv("VAULT_ADDR"))
+	if addr == "" {
+		return "", errors.New("vault: VAULT_ADDR is not set")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(addr, "/")+"/v1/"+strings.TrimPrefix(path, "/"), nil)
+	if err != nil {
+		return "", err
+	}
+	if token := firstNonEmpty(p.Token, os.Getenv("VAULT_TOKEN")); token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	if ns := firstNonEmpty(p.Namespace, os.Getenv("VAULT_NAMESPACE")); ns != "" {
+		req.Header.Set("X-Vault-Namespace", ns)
+	}
+	resp, err := clientOrDefault(p.Client).Do(req)
+	if err != nil {
+		return "", fmt.Errorf("vault: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return "", fmt.Errorf("vault: secret %s not found", path)
+	}
+	body, err := readSourceBody(resp)
+	if err != nil {
+		return "", fmt.Errorf("vault: %w", err)
+	}
+	var secret struct {
+		Data map[string]json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(body, &secret); err != nil {
+		return "", fmt.Errorf("vault: invalid response: %w", err)
+	}
+	fields := secret.Data
+	if _, v2 := fields["metadata"]; v2 {
+		// KV version 2 nests the secret under data.data.
+		var inner map[string]json.RawMessage
+		if err := json.Unmarshal(fields["data"], &inner); err == nil && inner != nil {
+			fields = inner
+		}
+	}
+	value, err := secretField(fields, field)
+	if err != nil {
+		return "", fmt.Errorf("vault: %s: %w", path, err)
+	}
+	return value, nil
+}
+
+// firstNonEmpty returns the first of values that is not empty.
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,246 @@
+// Package connlimit limits the connections a listener accepts: how fast new
+// connections are taken from the listen backlog, and how many are open at
+// once, overall and per peer address. The limits apply as connections are
+// accepted, before any TLS handshake or request is read.
+package connlimit
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/oriys/nexus/internal/clientip"
+	"github.com/oriys/nexus/internal/config"
+)
+
+// rejectTimeout bounds the time spent answering a rejected connection.
+const rejectTimeout = time.Second
+
+// maxRejecting bounds the rejected connections answered at once; further
+// ones are closed without a response, so a flood cannot pile up goroutines.
+const maxRejecting = 64
+
+// rejection is the response of plaintext listeners to the connections over
+// a limit.
+var rejection = func() string {
+	body := `{"error":"too_many_connections","message":"the gateway has too many connections, retry later"}`
+	return "HTTP/1.1 503 Service Unavailable\r\n" +
+		"Content-Type: application/json\r\n" +
+		"Retry-After: 1\r\n" +
+		"Connection: close\r\n" +
+		"Content-Length: " + strconv.Itoa(len(body)) + "\r\n" +
+		"\r\n" + body
+}()
+
+// stats counts the connections of the limited listeners for the metrics.
+var stats struct {
+	open      atomic.Int64
+	accepted  atomic.Uint64
+	throttled atomic.Uint64
+	rejected  [2]atomic.Uint64 // by reason: overall, per peer
+}
+
+var rejectReasons = [2]string{"max", "max_per_ip"}
+
+// Listener returns ln enforcing limits. Connections over max or max_per_ip
+// are closed as soon as they are accepted; with plaintext set, they are
+// first answered with 503 so that HTTP clients learn to retry. Over the
+// rate, accepting waits for the next token, leaving connections in the
+// listen backlog.
+func Listener(ln net.Listener, limits *config.ConnectionLimits, plaintext bool) net.Listener {
+	l := &listener{
+		Listener:  ln,
+		max:       limits.Max,
+		maxPerIP:  limits.MaxPerIP,
+		plaintext: plaintext,
+		perIP:     make(map[netip.Addr]int),
+		rejecting: make(chan struct{}, maxRejecting),
+		closed:    make(chan struct{}),
+	}
+	if limits.Rate > 0 {
+		l.bucket = newBucket(limits.Rate, limits.BurstSize(), time.Now)
+	}
+	return l
+}
+
+type listener struct {
+	net.Listener
+	bucket    *bucket
+	max       int
+	maxPerIP  int
+	plaintext bool
+
+	mu    sync.Mutex
+	open  int
+	perIP map[netip.Addr]int
+
+	rejecting chan struct{} // semaphore of the rejections in progress
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+func (l *listener) Accept() (net.Conn, error) {
+	for {
+		if l.bucket != nil {
+			if wait := l.bucket.take(); wait > 0 {
+				stats.throttled.Add(1)
+				timer := time.NewTimer(wait)
+				select {
+				case <-timer.C:
+				case <-l.closed:
+					timer.Stop()
+					return nil, net.ErrClosed
+				}
+			}
+		}
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		peer := clientip.PeerAddr(conn.RemoteAddr().String())
+		if reason, ok := l.admit(peer); !ok {
+			stats.rejected[reason].Add(1)
+			l.reject(conn)
+			continue
+		}
+		stats.accepted.Add(1)
+		stats.open.Add(1)
+		return &limitedConn{Conn: conn, l: l, peer: peer}, nil
+	}
+}
+
+func (l *listener) Close() error {
+	l.closeOnce.Do(func() { close(l.closed) })
+	return l.Listener.Close()
+}
+
+// admit counts a connection from peer, or returns the index of the limit
+// it exceeds.
+func (l *listener) admit(peer netip.Addr) (reason int, ok bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.max > 0 && l.open >= l.max {
+		return 0, false
+	}
+	if l.maxPerIP > 0 && l.perIP[peer] >= l.maxPerIP {
+		return 1, false
+	}
+	l.open++
+	l.perIP[peer]++
+	return 0, true
+}
+
+func (l *listener) release(peer netip.Addr) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.open--
+	if l.perIP[peer]--; l.perIP[peer] <= 0 {
+		delete(l.perIP, peer)
+	}
+}
+
+// reject closes a connection over a limit, answering it first on
+// plaintext listeners. The client's request is read before the connection
+// is closed, so that the close does not reset the response away.
+func (l *listener) reject(conn net.Conn) {
+	if !l.plaintext {
+		conn.Close()
+		return
+	}
+	select {
+	case l.rejecting <- struct{}{}:
+	default:
+		conn.Close()
+		return
+	}
+	go func() {
+		defer func() { <-l.rejecting }()
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(rejectTimeout))
+		if _, err := io.WriteString(conn, rejection); err != nil {
+			return
+		}
+		if cw, ok := conn.(interface{ CloseWrite() error }); ok {
+			cw.CloseWrite()
+		}
+		io.Copy(io.Discard, io.LimitReader(conn, 64<<10))
+	}()
+}
+
+// limitedConn releases its slot of the limits when closed.
+type limitedConn struct {
+	net.Conn
+	l    *listener
+	peer netip.Addr
+	once sync.Once
+}
+
+func (c *limitedConn) Close() error {
+	c.once.Do(func() {
+		c.l.release(c.peer)
+		stats.open.Add(-1)
+	})
+	return c.Conn.Close()
+}
+
+// CloseWrite lets net/http half-close the connection as it would the
+// underlying TCP connection.
+func (c *limitedConn) CloseWrite() error {
+	if cw, ok := c.Conn.(interface{ CloseWrite() error }); ok {
+		return cw.CloseWrite()
+	}
+	return nil
+}
+
+// bucket is a token bucket refilled at rate tokens per second.
+type bucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+}
+
+func newBucket(rate, burst int, now func() time.Time) *bucket {
+	return &bucket{rate: float64(rate), burst: float64(burst), tokens: float64(burst), last: now(), now: now}
+}
+
+// take removes a token, returning how long to wait until it is available.
+// The token is taken even when the caller must wait, so that waiting
+// callers are spaced by the rate.
+func (b *bucket) take() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now()
+	b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// WriteMetrics writes the connection limit metrics in the Prometheus text
+// exposition format.
+func WriteMetrics(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "# HELP nexus_connections_open Connections open on listeners with connection limits.\n# TYPE nexus_connections_open gauge\n")
+	fmt.Fprintf(bw, "nexus_connections_open %d\n", stats.open.Load())
+	fmt.Fprintf(bw, "# HELP nexus_connections_accepted_total Connections accepted within the connection limits.\n# TYPE nexus_connections_accepted_total counter\n")
+	fmt.Fprintf(bw, "nexus_connections_accepted_total %s\n", strconv.FormatUint(stats.accepted.Load(), 10))
+	fmt.Fprintf(bw, "# HELP nexus_connections_throttled_total Accepts delayed by the connection rate limit.\n# TYPE nexus_connections_throttled_total counter\n")
+	fmt.Fprintf(bw, "nexus_connections_throttled_total %s\n", strconv.FormatUint(stats.throttled.Load(), 10))
+	fmt.Fprintf(bw, "# HELP nexus_connections_rejected_total Connections closed for exceeding a connection limit, by limit.\n# TYPE nexus_connections_rejected_total counter\n")
+	for i, reason := range rejectReasons {
+		fmt.Fprintf(bw, "nexus_connections_rejected_total{limit=%q} %s\n", reason, strconv.FormatUint(stats.rejected[i].Load(), 10))
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,153 @@
+package connlimit
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oriys/nexus/internal/config"
+)
+
+// serve accepts connections of ln until it is closed, handing them to the
+// returned channel.
+func serve(t *testing.T, limits *config.ConnectionLimits, plaintext bool) (net.Listener, <-chan net.Conn) {
+	t.Helper()
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln := Listener(inner, limits, plaintext)
+	t.Cleanup(func() { ln.Close() })
+	accepted := make(chan net.Conn, 16)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- conn
+		}
+	}()
+	return ln, accepted
+}
+
+func dial(t *testing.T, ln net.Listener) net.Conn {
+	t.Helper()
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestListener_Max(t *testing.T) {
+	ln, accepted := serve(t, &config.ConnectionLimits{Max: 1}, true)
+
+	dial(t, ln)
+	first := <-accepted
+
+	// A connection over the limit is answered with 503 and closed.
+	over := dial(t, ln)
+	over.Write([]byte("GET / HTTP/1.1\r\nHost: gateway\r\n\r\n"))
+	over.SetReadDeadline(time.Now().Add(2 * time.Second))
+	resp, err := http.ReadResponse(bufio.NewReader(over), nil)
+	if err != nil {
+		t.Fatalf("rejected connection: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusServiceUnavailable || resp.Header.Get("Retry-After") != "1" || !strings.Contains(string(body), "too_many_connections") {
+		t.Errorf("rejection: %d %v %s", resp.StatusCode, resp.Header, body)
+	}
+	select {
+	case <-accepted:
+		t.Fatal("connection over the limit accepted")
+	default:
+	}
+
+	// Closing a connection frees its slot.
+	first.Close()
+	first.Close()
+	dial(t, ln)
+	select {
+	case conn := <-accepted:
+		conn.Close()
+	case <-time.After(2 * time.Second):
+		t.Fatal("connection not accepted after a slot was freed")
+	}
+}
+
+func TestListener_MaxPerIPWithoutResponse(t *testing.T) {
+	ln, accepted := serve(t, &config.ConnectionLimits{MaxPerIP: 1}, false)
+
+	dial(t, ln)
+	defer (<-accepted).Close()
+
+	over := dial(t, ln)
+	over.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if n, err := over.Read(make([]byte, 1)); n != 0 || err == nil {
+		t.Errorf("rejected TLS connection read %d bytes, %v", n, err)
+	}
+}
+
+func TestListener_CloseStopsThrottledAccept(t *testing.T) {
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln := Listener(inner, &config.ConnectionLimits{Rate: 1}, true)
+	ln.(*listener).bucket.tokens = 0
+
+	done := make(chan error)
+	go func() {
+		_, err := ln.Accept()
+		done <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	ln.Close()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("accept succeeded on a closed listener")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("throttled accept not stopped by Close")
+	}
+}
+
+func TestBucket(t *testing.T) {
+	now := time.Unix(0, 0)
+	b := newBucket(10, 2, func() time.Time { return now })
+	for i, want := range []time.Duration{0, 0, 100 * time.Millisecond, 200 * time.Millisecond} {
+		if got := b.take(); got != want {
+			t.Errorf("take %d: wait %v, want %v", i, got, want)
+		}
+	}
+	// The waiting callers used the tokens of the next 200ms; the burst
+	// refills after that.
+	now = now.Add(time.Second)
+	if got := b.take(); got != 0 {
+		t.Errorf("after a second: wait %v", got)
+	}
+	if b.tokens > 1 {
+		t.Errorf("tokens %v above the burst", b.tokens)
+	}
+}
+
+func TestWriteMetrics(t *testing.T) {
+	var b strings.Builder
+	WriteMetrics(&b)
+	for _, want := range []string{
+		"# TYPE nexus_connections_open gauge",
+		`nexus_connections_rejected_total{limit="max_per_ip"}`,
+		"nexus_connections_throttled_total ",
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("metrics lack %q:\n%s", want, b.String())
+		}
+	}
+}
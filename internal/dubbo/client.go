@@ -0,0 +1,448 @@
+package dubbo
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrClosed is returned for calls on a closed client.
+var ErrClosed = errors.New("dubbo: connection closed")
+
+// ErrHeartbeatTimeout breaks a connection on which nothing, not even a
+// heartbeat response, was received for three heartbeat intervals.
+var ErrHeartbeatTimeout = errors.New("dubbo: heartbeat timeout")
+
+// DefaultHeartbeat is the interval at which idle connections are probed,
+// matching Dubbo's default.
+const DefaultHeartbeat = 60 * time.Second
+
+// Client is a single multiplexed connection to a Dubbo provider. Requests are
+// matched to responses by id, so any number of calls can be in flight.
+type Client struct {
+	conn    net.Conn
+	maxBody int
+
+	writeMu sync.Mutex
+	nextID  atomic.Int64
+
+	mu      sync.Mutex
+	pending map[int64]chan *Frame
+	err     error // set once the connection fails
+	done    chan struct{}
+
+	heartbeat time.Duration
+	lastRead  atomic.Int64 // unix nanoseconds of the last frame received
+	stats     *connStats   // shared with the pool; may be nil
+}
+
+// connStats counts heartbeat activity across the connections to a provider.
+type connStats struct {
+	heartbeats        atomic.Uint64
+	heartbeatTimeouts atomic.Uint64
+}
+
+// Dial connects to the provider at addr (host:port). Idle connections are
+// probed every DefaultHeartbeat.
+func Dial(ctx context.Context, addr string) (*Client, error) {
+	return dial(ctx, addr, DefaultHeartbeat, nil)
+}
+
+func dial(ctx context.Context, addr string, heartbeat time.Duration, stats *connStats) (*Client, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{
+		conn:      conn,
+		maxBody:   DefaultMaxBodySize,
+		pending:   make(map[int64]chan *Frame),
+		done:      make(chan struct{}),
+		heartbeat: heartbeat,
+		stats:     stats,
+	}
+	c.lastRead.Store(time.Now().UnixNano())
+	go c.readLoop()
+	go c.heartbeatLoop()
+	return c, nil
+}
+
+// Invoke calls the provider and waits for its result or for ctx to end.
+func (c *Client) Invoke(ctx context.Context, inv *Invocation) (*Result, error) {
+	if dl, ok := ctx.Deadline(); ok && inv.Timeout == 0 {
+		// Let the provider give up when the caller does.
+		withTimeout := *inv
+		withTimeout.Timeout = time.Until(dl)
+		inv = &withTimeout
+	}
+	body, err := inv.encodeRequest()
+	if err != nil {
+		return nil, err
+	}
+
+	id := c.nextID.Add(1)
+	ch := make(chan *Frame, 1)
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return nil, c.err
+	}
+	c.pending[id] = ch
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	if err := c.write(&Frame{ID: id, Request: true, TwoWay: true, Body: body}); err != nil {
+		c.fail(err)
+		return nil, err
+	}
+
+	select {
+	case f := <-ch:
+		res, err := decodeResponse(f)
+		if err != nil {
+			return nil, err
+		}
+		res.RequestBody, res.ResponseBody = body, f.Body
+		return res, nil
+	case <-c.done:
+		return nil, c.Err()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (c *Client) write(f *Frame) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return WriteFrame(c.conn, f)
+}
+
+func (c *Client) readLoop() {
+	r := bufio.NewReader(c.conn)
+	for {
+		f, err := ReadFrame(r, c.maxBody)
+		if err != nil {
+			c.fail(err)
+			return
+		}
+		c.lastRead.Store(time.Now().UnixNano())
+		if f.Request {
+			// Providers probe idle connections with heartbeat requests.
+			if f.heartbeat() && f.TwoWay {
+				go c.write(&Frame{ID: f.ID, Event: true, Status: StatusOK, Body: []byte{'N'}})
+			}
+			continue
+		}
+		if f.Event {
+			continue
+		}
+		c.mu.Lock()
+		ch, ok := c.pending[f.ID]
+		c.mu.Unlock()
+		if ok {
+			ch <- f
+		}
+	}
+}
+
+// heartbeatLoop sends a heartbeat request whenever the connection has been
+// silent for a heartbeat interval, and breaks it after three silent intervals
+// so that calls fail fast and the pool redials.
+func (c *Client) heartbeatLoop() {
+	ticker := time.NewTicker(c.heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+		}
+		idle := time.Since(time.Unix(0, c.lastRead.Load()))
+		if idle >= 3*c.heartbeat {
+			if c.stats != nil {
+				c.stats.heartbeatTimeouts.Add(1)
+			}
+			c.fail(ErrHeartbeatTimeout)
+			return
+		}
+		if idle >= c.heartbeat {
+			if c.stats != nil {
+				c.stats.heartbeats.Add(1)
+			}
+			if err := c.write(&Frame{ID: c.nextID.Add(1), Request: true, TwoWay: true, Event: true, Body: []byte{'N'}}); err != nil {
+				c.fail(err)
+				return
+			}
+		}
+	}
+}
+
+// fail closes the connection and fails all pending and future calls.
+func (c *Client) fail(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return
+	}
+	if errors.Is(err, net.ErrClosed) {
+		err = ErrClosed
+	}
+	c.err = err
+	c.conn.Close()
+	close(c.done)
+}
+
+// Err returns the error that broke the connection, or nil while it is usable.
+func (c *Client) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// Close closes the connection.
+func (c *Client) Close() error {
+	c.fail(ErrClosed)
+	return nil
+}
+
+// PoolOptions configures the connections a Pool keeps to each provider. The
+// zero value selects the defaults.
+type PoolOptions struct {
+	// Connections is the number of connections per provider (default 1).
+	// Calls are spread across them round-robin.
+	Connections int
+	// Heartbeat is the idle probe interval (default DefaultHeartbeat).
+	Heartbeat time.Duration
+	// ReconnectBackoff is the wait after a failed dial before the provider is
+	// dialed again; it doubles with each consecutive failure up to
+	// MaxReconnectBackoff (defaults 500ms and 30s). Calls during the wait
+	// fail fast unless another connection to the provider is live.
+	ReconnectBackoff    time.Duration
+	MaxReconnectBackoff time.Duration
+}
+
+// Defaults for PoolOptions.
+const (
+	defaultReconnectBackoff    = 500 * time.Millisecond
+	defaultMaxReconnectBackoff = 30 * time.Second
+)
+
+func (o PoolOptions) withDefaults() PoolOptions {
+	if o.Connections <= 0 {
+		o.Connections = 1
+	}
+	if o.Heartbeat <= 0 {
+		o.Heartbeat = DefaultHeartbeat
+	}
+	if o.ReconnectBackoff <= 0 {
+		o.ReconnectBackoff = defaultReconnectBackoff
+	}
+	if o.MaxReconnectBackoff < o.ReconnectBackoff {
+		o.MaxReconnectBackoff = max(defaultMaxReconnectBackoff, o.ReconnectBackoff)
+	}
+	return o
+}
+
+// ProviderStats reports the state of a Pool's connections to one provider.
+type ProviderStats struct {
+	Addr string `json:"addr"`
+	// Connections is the number of live connections out of Configured.
+	Connections int   `json:"connections"`
+	Configured  int   `json:"configured"`
+	InFlight    int64 `json:"in_flight"`
+	// Calls counts invocations; Failures those that got no result because
+	// of a connection, protocol or status error, or a timeout.
+	Calls             uint64 `json:"calls"`
+	Failures          uint64 `json:"failures"`
+	Dials             uint64 `json:"dials"`
+	DialFailures      uint64 `json:"dial_failures"`
+	Reconnects        uint64 `json:"reconnects"`
+	Heartbeats        uint64 `json:"heartbeats"`
+	HeartbeatTimeouts uint64 `json:"heartbeat_timeouts"`
+	// RetryAt is set while dialing is backed off after failures.
+	RetryAt   *time.Time `json:"retry_at,omitempty"`
+	LastError string     `json:"last_error,omitempty"`
+}
+
+// Pool keeps long-lived connections to Dubbo providers, redialing broken
+// ones with exponential backoff.
+type Pool struct {
+	opts PoolOptions
+
+	mu        sync.Mutex
+	providers map[string]*provider
+}
+
+// provider holds the connection slots for one address.
+type provider struct {
+	addr  string
+	conns []*Client // nil until dialed
+	next  atomic.Uint64
+
+	// Guarded by Pool.mu.
+	failures int
+	retryAt  time.Time
+	lastErr  error
+
+	inFlight     atomic.Int64
+	calls        atomic.Uint64
+	callFailures atomic.Uint64
+	dials        atomic.Uint64
+	dialFailures atomic.Uint64
+	reconnects   atomic.Uint64
+	stats        connStats
+}
+
+// NewPool creates an empty Pool.
+func NewPool(opts PoolOptions) *Pool {
+	return &Pool{opts: opts.withDefaults(), providers: make(map[string]*provider)}
+}
+
+// Get returns a live client for addr, dialing if needed. Each call moves to
+// the provider's next connection slot.
+func (p *Pool) Get(ctx context.Context, addr string) (*Client, error) {
+	c, _, err := p.get(ctx, addr)
+	return c, err
+}
+
+func (p *Pool) get(ctx context.Context, addr string) (*Client, *provider, error) {
+	p.mu.Lock()
+	pv, ok := p.providers[addr]
+	if !ok {
+		pv = &provider{addr: addr, conns: make([]*Client, p.opts.Connections)}
+		p.providers[addr] = pv
+	}
+	slot := int(pv.next.Add(1)-1) % len(pv.conns)
+	if c := pv.conns[slot]; c != nil && c.Err() == nil {
+		p.mu.Unlock()
+		return c, pv, nil
+	}
+	if wait := time.Until(pv.retryAt); wait > 0 {
+		// Keep serving from the other connections while this one waits.
+		for _, c := range pv.conns {
+			if c != nil && c.Err() == nil {
+				p.mu.Unlock()
+				return c, pv, nil
+			}
+		}
+		err := fmt.Errorf("dubbo: provider %s unavailable, next dial in %s: %w", addr, wait.Round(time.Millisecond), pv.lastErr)
+		p.mu.Unlock()
+		return nil, pv, err
+	}
+	p.mu.Unlock()
+
+	pv.dials.Add(1)
+	c, err := dial(ctx, addr, p.opts.Heartbeat, &pv.stats)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err != nil {
+		pv.dialFailures.Add(1)
+		pv.failures++
+		backoff := p.opts.ReconnectBackoff << min(pv.failures-1, 16)
+		pv.retryAt = time.Now().Add(min(backoff, p.opts.MaxReconnectBackoff))
+		pv.lastErr = err
+		return nil, pv, err
+	}
+	pv.failures, pv.retryAt, pv.lastErr = 0, time.Time{}, nil
+	if p.providers[addr] != pv {
+		// Retain dropped the provider while dialing; it is in use again.
+		p.providers[addr] = pv
+	}
+	// Another caller may have dialed this slot concurrently; keep the first
+	// live one.
+	if cur := pv.conns[slot]; cur != nil {
+		if cur.Err() == nil {
+			c.Close()
+			return cur, pv, nil
+		}
+		pv.reconnects.Add(1)
+	}
+	pv.conns[slot] = c
+	return c, pv, nil
+}
+
+// Invoke calls inv on the provider at addr.
+func (p *Pool) Invoke(ctx context.Context, addr string, inv *Invocation) (*Result, error) {
+	c, pv, err := p.get(ctx, addr)
+	pv.calls.Add(1)
+	if err != nil {
+		pv.callFailures.Add(1)
+		return nil, err
+	}
+	pv.inFlight.Add(1)
+	defer pv.inFlight.Add(-1)
+	res, err := c.Invoke(ctx, inv)
+	if err != nil {
+		pv.callFailures.Add(1)
+	}
+	return res, err
+}
+
+// Stats reports the state of each provider, sorted by address.
+func (p *Pool) Stats() []ProviderStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stats := make([]ProviderStats, 0, len(p.providers))
+	for _, addr := range sortedKeys(p.providers) {
+		pv := p.providers[addr]
+		s := ProviderStats{
+			Addr:              addr,
+			Configured:        len(pv.conns),
+			InFlight:          pv.inFlight.Load(),
+			Calls:             pv.calls.Load(),
+			Failures:          pv.callFailures.Load(),
+			Dials:             pv.dials.Load(),
+			DialFailures:      pv.dialFailures.Load(),
+			Reconnects:        pv.reconnects.Load(),
+			Heartbeats:        pv.stats.heartbeats.Load(),
+			HeartbeatTimeouts: pv.stats.heartbeatTimeouts.Load(),
+		}
+		for _, c := range pv.conns {
+			if c != nil && c.Err() == nil {
+				s.Connections++
+			}
+		}
+		if time.Now().Before(pv.retryAt) {
+			retryAt := pv.retryAt
+			s.RetryAt = &retryAt
+		}
+		if pv.lastErr != nil {
+			s.LastError = pv.lastErr.Error()
+		}
+		stats = append(stats, s)
+	}
+	return stats
+}
+
+// Retain closes the connections to providers whose address is not in addrs.
+func (p *Pool) Retain(addrs map[string]struct{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for addr, pv := range p.providers {
+		if _, ok := addrs[addr]; ok {
+			continue
+		}
+		for _, c := range pv.conns {
+			if c != nil {
+				c.Close()
+			}
+		}
+		delete(p.providers, addr)
+	}
+}
+
+// Close closes every connection in the pool.
+func (p *Pool) Close() {
+	p.Retain(nil)
+}
@@ -0,0 +1,362 @@
+package dubbo
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeProvider serves Dubbo requests on a local listener. handle receives the
+// decoded method name and arguments and returns the response frame body and
+// status.
+type fakeProvider struct {
+	ln     net.Listener
+	handle func(method string, args []interface{}) (byte, []byte)
+
+	mu    sync.Mutex
+	conns []net.Conn
+}
+
+func newFakeProvider(t *testing.T, handle func(method string, args []interface{}) (byte, []byte)) *fakeProvider {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &fakeProvider{ln: ln, handle: handle}
+	t.Cleanup(p.close)
+	go p.serve()
+	return p
+}
+
+func (p *fakeProvider) addr() string { return p.ln.Addr().String() }
+
+func (p *fakeProvider) close() {
+	p.ln.Close()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, c := range p.conns {
+		c.Close()
+	}
+}
+
+func (p *fakeProvider) serve() {
+	for {
+		conn, err := p.ln.Accept()
+		if err != nil {
+			return
+		}
+		p.mu.Lock()
+		p.conns = append(p.conns, conn)
+		p.mu.Unlock()
+		go p.serveConn(conn)
+	}
+}
+
+func (p *fakeProvider) serveConn(conn net.Conn) {
+	var writeMu sync.Mutex
+	r := bufio.NewReader(conn)
+	for {
+		f, err := ReadFrame(r, DefaultMaxBodySize)
+		if err != nil {
+			return
+		}
+		if f.Event {
+			writeMu.Lock()
+			WriteFrame(conn, &Frame{ID: f.ID, Event: true, Status: StatusOK, Body: []byte{'N'}})
+			writeMu.Unlock()
+			continue
+		}
+		go func() {
+			d := NewDecoder(f.Body)
+			var values []interface{}
+			for d.Remaining() {
+				v, err := d.Decode()
+				if err != nil {
+					return
+				}
+				values = append(values, v)
+			}
+			// version, path, version, method, descriptor, args..., attachments
+			method, _ := values[3].(string)
+			status, body := p.handle(method, values[5:len(values)-1])
+			writeMu.Lock()
+			defer writeMu.Unlock()
+			WriteFrame(conn, &Frame{ID: f.ID, Status: status, Body: body})
+		}()
+	}
+}
+
+func valueResponse(v interface{}) []byte {
+	var e Encoder
+	e.WriteInt(responseValue)
+	e.Encode(v)
+	return e.Bytes()
+}
+
+func TestClient_Invoke(t *testing.T) {
+	p := newFakeProvider(t, func(method string, args []interface{}) (byte, []byte) {
+		if method == "slow" {
+			time.Sleep(50 * time.Millisecond)
+		}
+		return StatusOK, valueResponse(method + ":" + args[0].(string))
+	})
+	c, err := Dial(context.Background(), p.addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// Responses arriving out of order are matched to their callers.
+	var wg sync.WaitGroup
+	results := make([]interface{}, 2)
+	for i, method := range []string{"slow", "fast"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, err := c.Invoke(context.Background(), &Invocation{
+				Interface:  "com.foo.Svc",
+				Method:     method,
+				ParamTypes: []string{"java.lang.String"},
+				Args:       []interface{}{"x"},
+			})
+			if err != nil {
+				t.Errorf("%s: %v", method, err)
+				return
+			}
+			results[i] = res.Value
+			if len(res.RequestBody) == 0 || len(res.ResponseBody) == 0 {
+				t.Errorf("%s: expected raw bodies on the result", method)
+			}
+		}()
+	}
+	wg.Wait()
+	if results[0] != "slow:x" || results[1] != "fast:x" {
+		t.Errorf("unexpected results %v", results)
+	}
+}
+
+func TestClient_StatusAndTimeout(t *testing.T) {
+	p := newFakeProvider(t, func(method string, args []interface{}) (byte, []byte) {
+		if method == "hang" {
+			time.Sleep(time.Second)
+		}
+		var e Encoder
+		e.WriteString("no provider")
+		return StatusServiceNotFound, e.Bytes()
+	})
+	c, err := Dial(context.Background(), p.addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	_, err = c.Invoke(context.Background(), &Invocation{Interface: "com.foo.Svc", Method: "m"})
+	var se *StatusError
+	if !errors.As(err, &se) || se.Status != StatusServiceNotFound {
+		t.Errorf("expected status error, got %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := c.Invoke(ctx, &Invocation{Interface: "com.foo.Svc", Method: "hang"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+}
+
+func TestClient_AnswersHeartbeat(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err == nil {
+			accepted <- conn
+		}
+	}()
+
+	c, err := Dial(context.Background(), ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	conn := <-accepted
+	defer conn.Close()
+
+	if err := WriteFrame(conn, &Frame{ID: 9, Request: true, TwoWay: true, Event: true, Body: []byte{'N'}}); err != nil {
+		t.Fatal(err)
+	}
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	f, err := ReadFrame(conn, DefaultMaxBodySize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.ID != 9 || f.Request || !f.Event || f.Status != StatusOK {
+		t.Errorf("unexpected heartbeat response %+v", f)
+	}
+}
+
+func TestPool_RedialsBrokenConnection(t *testing.T) {
+	p := newFakeProvider(t, func(method string, args []interface{}) (byte, []byte) {
+		return StatusOK, valueResponse("ok")
+	})
+	pool := NewPool(PoolOptions{})
+	inv := &Invocation{Interface: "com.foo.Svc", Method: "m"}
+
+	c1, err := pool.Get(context.Background(), p.addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pool.Invoke(context.Background(), p.addr(), inv); err != nil {
+		t.Fatal(err)
+	}
+	c1.Close()
+
+	res, err := pool.Invoke(context.Background(), p.addr(), inv)
+	if err != nil {
+		t.Fatalf("expected redial, got %v", err)
+	}
+	if res.Value != "ok" {
+		t.Errorf("unexpected value %v", res.Value)
+	}
+	if c2, _ := pool.Get(context.Background(), p.addr()); c2 == c1 {
+		t.Error("expected a new client after the connection closed")
+	}
+	if _, err := c1.Invoke(context.Background(), inv); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed from closed client, got %v", err)
+	}
+}
+
+func TestClient_SendsHeartbeat(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err == nil {
+			accepted <- conn
+		}
+	}()
+
+	var stats connStats
+	c, err := dial(context.Background(), ln.Addr().String(), 20*time.Millisecond, &stats)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	conn := <-accepted
+	defer conn.Close()
+
+	// The idle client probes the provider; an answer keeps it alive.
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	f, err := ReadFrame(conn, DefaultMaxBodySize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !f.Request || !f.TwoWay || !f.heartbeat() {
+		t.Fatalf("expected heartbeat request, got %+v", f)
+	}
+	WriteFrame(conn, &Frame{ID: f.ID, Event: true, Status: StatusOK, Body: []byte{'N'}})
+
+	// Without further answers the connection is dropped.
+	select {
+	case <-c.done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the silent connection to be closed")
+	}
+	if !errors.Is(c.Err(), ErrHeartbeatTimeout) {
+		t.Errorf("expected heartbeat timeout, got %v", c.Err())
+	}
+	if stats.heartbeats.Load() == 0 || stats.heartbeatTimeouts.Load() != 1 {
+		t.Errorf("unexpected stats: %d heartbeats, %d timeouts", stats.heartbeats.Load(), stats.heartbeatTimeouts.Load())
+	}
+}
+
+func TestPool_Connections(t *testing.T) {
+	p := newFakeProvider(t, func(method string, args []interface{}) (byte, []byte) {
+		return StatusOK, valueResponse("ok")
+	})
+	pool := NewPool(PoolOptions{Connections: 2})
+	defer pool.Close()
+
+	c1, _ := pool.Get(context.Background(), p.addr())
+	c2, _ := pool.Get(context.Background(), p.addr())
+	if c1 == nil || c2 == nil || c1 == c2 {
+		t.Fatalf("expected two distinct connections, got %p and %p", c1, c2)
+	}
+	if c3, _ := pool.Get(context.Background(), p.addr()); c3 != c1 {
+		t.Error("expected round-robin back to the first connection")
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := pool.Invoke(context.Background(), p.addr(), &Invocation{Interface: "com.foo.Svc", Method: "m"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats := pool.Stats()
+	if len(stats) != 1 {
+		t.Fatalf("expected one provider, got %+v", stats)
+	}
+	s := stats[0]
+	if s.Addr != p.addr() || s.Connections != 2 || s.Configured != 2 || s.Calls != 3 || s.Dials != 2 || s.InFlight != 0 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+
+	c1.Close()
+	if _, err := pool.Invoke(context.Background(), p.addr(), &Invocation{Interface: "com.foo.Svc", Method: "m"}); err != nil {
+		t.Fatal(err)
+	}
+	pool.Get(context.Background(), p.addr())
+	if s := pool.Stats()[0]; s.Reconnects != 1 || s.Connections != 2 {
+		t.Errorf("expected one reconnect, got %+v", s)
+	}
+
+	pool.Retain(map[string]struct{}{})
+	if len(pool.Stats()) != 0 || c2.Err() == nil {
+		t.Error("expected Retain to close connections to dropped providers")
+	}
+}
+
+func TestPool_ReconnectBackoff(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close() // nothing listens: dials are refused
+
+	pool := NewPool(PoolOptions{ReconnectBackoff: 50 * time.Millisecond, MaxReconnectBackoff: time.Second})
+	if _, err := pool.Get(context.Background(), addr); err == nil {
+		t.Fatal("expected dial error")
+	}
+	_, err = pool.Get(context.Background(), addr)
+	if err == nil || !strings.Contains(err.Error(), "next dial in") {
+		t.Fatalf("expected fast failure during backoff, got %v", err)
+	}
+	s := pool.Stats()[0]
+	if s.Dials != 1 || s.DialFailures != 1 || s.RetryAt == nil || s.LastError == "" {
+		t.Errorf("unexpected stats during backoff %+v", s)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	pool.Get(context.Background(), addr)
+	s = pool.Stats()[0]
+	if s.Dials != 2 || s.DialFailures != 2 {
+		t.Errorf("expected a second dial after the backoff, got %+v", s)
+	}
+	// The backoff doubles after consecutive failures.
+	if wait := time.Until(*s.RetryAt); wait <= 50*time.Millisecond {
+		t.Errorf("expected doubled backoff, next dial in %s", wait)
+	}
+}
@@ -0,0 +1,826 @@
+// Package dubbo implements the Dubbo 2 ("dubbo://") wire protocol with
+// Hessian 2 serialization, so the gateway can call providers that expose
+// neither the triple protocol nor an HTTP endpoint.
+package dubbo
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Object is a Hessian object instance decoded from the wire. Objects marshal
+// to JSON as their fields alone.
+type Object struct {
+	Type   string
+	Fields map[string]interface{}
+}
+
+// MarshalJSON implements json.Marshaler.
+func (o *Object) MarshalJSON() ([]byte, error) {
+	return json.Marshal(o.Fields)
+}
+
+// Encoder writes values in Hessian 2 format. The zero value is ready to use.
+type Encoder struct {
+	buf   []byte
+	types map[string]int
+}
+
+// Bytes returns the encoded data.
+func (e *Encoder) Bytes() []byte { return e.buf }
+
+// WriteNull writes a null.
+func (e *Encoder) WriteNull() { e.buf = append(e.buf, 'N') }
+
+// WriteBool writes a boolean.
+func (e *Encoder) WriteBool(v bool) {
+	if v {
+		e.buf = append(e.buf, 'T')
+	} else {
+		e.buf = append(e.buf, 'F')
+	}
+}
+
+// WriteInt writes a 32-bit integer using the most compact encoding.
+func (e *Encoder) WriteInt(v int32) {
+	switch {
+	case v >= -0x10 && v <= 0x2f:
+		e.buf = append(e.buf, byte(v+0x90))
+	case v >= -0x800 && v <= 0x7ff:
+		e.buf = append(e.buf, byte(0xc8+(v>>8)), byte(v))
+	case v >= -0x40000 && v <= 0x3ffff:
+		e.buf = append(e.buf, byte(0xd4+(v>>16)), byte(v>>8), byte(v))
+	default:
+		e.buf = append(e.buf, 'I')
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(v))
+	}
+}
+
+// WriteLong writes a 64-bit integer using the most compact encoding.
+func (e *Encoder) WriteLong(v int64) {
+	switch {
+	case v >= -0x08 && v <= 0x0f:
+		e.buf = append(e.buf, byte(v+0xe0))
+	case v >= -0x800 && v <= 0x7ff:
+		e.buf = append(e.buf, byte(0xf8+(v>>8)), byte(v))
+	case v >= -0x40000 && v <= 0x3ffff:
+		e.buf = append(e.buf, byte(0x3c+(v>>16)), byte(v>>8), byte(v))
+	case v >= math.MinInt32 && v <= math.MaxInt32:
+		e.buf = append(e.buf, 0x59)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(v))
+	default:
+		e.buf = append(e.buf, 'L')
+		e.buf = binary.BigEndian.AppendUint64(e.buf, uint64(v))
+	}
+}
+
+// WriteDouble writes a 64-bit float, compacting integral values.
+func (e *Encoder) WriteDouble(v float64) {
+	if v == math.Trunc(v) && !(v == 0 && math.Signbit(v)) {
+		switch {
+		case v == 0:
+			e.buf = append(e.buf, 0x5b)
+			return
+		case v == 1:
+			e.buf = append(e.buf, 0x5c)
+			return
+		case v >= math.MinInt8 && v <= math.MaxInt8:
+			e.buf = append(e.buf, 0x5d, byte(int8(v)))
+			return
+		case v >= math.MinInt16 && v <= math.MaxInt16:
+			e.buf = append(e.buf, 0x5e)
+			e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(int16(v)))
+			return
+		}
+	}
+	e.buf = append(e.buf, 'D')
+	e.buf = binary.BigEndian.AppendUint64(e.buf, math.Float64bits(v))
+}
+
+// WriteString writes a string. Hessian measures strings in UTF-16 code units
+// and encodes supplementary characters as surrogate pairs, as Java does.
+func (e *Encoder) WriteString(s string) {
+	units := utf16.Encode([]rune(s))
+	for len(units) > 0xffff {
+		e.buf = append(e.buf, 'R', 0xff, 0xff)
+		e.appendChars(units[:0xffff])
+		units = units[0xffff:]
+	}
+	n := len(units)
+	switch {
+	case n <= 0x1f:
+		e.buf = append(e.buf, byte(n))
+	case n <= 0x3ff:
+		e.buf = append(e.buf, byte(0x30+(n>>8)), byte(n))
+	default:
+		e.buf = append(e.buf, 'S', byte(n>>8), byte(n))
+	}
+	e.appendChars(units)
+}
+
+// appendChars writes UTF-16 code units as (modified) UTF-8, one to three
+// bytes per unit.
+func (e *Encoder) appendChars(units []uint16) {
+	for _, u := range units {
+		switch {
+		case u < 0x80:
+			e.buf = append(e.buf, byte(u))
+		case u < 0x800:
+			e.buf = append(e.buf, byte(0xc0|u>>6), byte(0x80|u&0x3f))
+		default:
+			e.buf = append(e.buf, byte(0xe0|u>>12), byte(0x80|(u>>6)&0x3f), byte(0x80|u&0x3f))
+		}
+	}
+}
+
+// WriteBytes writes binary data.
+func (e *Encoder) WriteBytes(b []byte) {
+	for len(b) > 0xffff {
+		e.buf = append(e.buf, 'A', 0xff, 0xff)
+		e.buf = append(e.buf, b[:0xffff]...)
+		b = b[0xffff:]
+	}
+	n := len(b)
+	switch {
+	case n <= 0x0f:
+		e.buf = append(e.buf, byte(0x20+n))
+	case n <= 0x3ff:
+		e.buf = append(e.buf, byte(0x34+(n>>8)), byte(n))
+	default:
+		e.buf = append(e.buf, 'B', byte(n>>8), byte(n))
+	}
+	e.buf = append(e.buf, b...)
+}
+
+// WriteDate writes a timestamp with millisecond precision.
+func (e *Encoder) WriteDate(t time.Time) {
+	e.buf = append(e.buf, 0x4a)
+	e.buf = binary.BigEndian.AppendUint64(e.buf, uint64(t.UnixMilli()))
+}
+
+// writeType writes a type name, or a reference to it if already written.
+func (e *Encoder) writeType(typ string) {
+	if ref, ok := e.types[typ]; ok {
+		e.WriteInt(int32(ref))
+		return
+	}
+	if e.types == nil {
+		e.types = make(map[string]int)
+	}
+	e.types[typ] = len(e.types)
+	e.WriteString(typ)
+}
+
+// beginList writes the header of a fixed-length list. An empty typ writes an
+// untyped list.
+func (e *Encoder) beginList(typ string, n int) {
+	switch {
+	case typ == "" && n <= 7:
+		e.buf = append(e.buf, byte(0x78+n))
+	case typ == "":
+		e.buf = append(e.buf, 0x58)
+		e.WriteInt(int32(n))
+	case n <= 7:
+		e.buf = append(e.buf, byte(0x70+n))
+		e.writeType(typ)
+	default:
+		e.buf = append(e.buf, 'V')
+		e.writeType(typ)
+		e.WriteInt(int32(n))
+	}
+}
+
+// beginMap writes the header of a map. An empty typ writes an untyped map.
+// The map is terminated with endMap.
+func (e *Encoder) beginMap(typ string) {
+	if typ == "" {
+		e.buf = append(e.buf, 'H')
+		return
+	}
+	e.buf = append(e.buf, 'M')
+	e.writeType(typ)
+}
+
+func (e *Encoder) endMap() { e.buf = append(e.buf, 'Z') }
+
+// Encode writes v, choosing the Hessian type from its Go type. It accepts
+// the values produced by encoding/json (with or without UseNumber) plus
+// integers, []byte, time.Time and map[string]string.
+func (e *Encoder) Encode(v interface{}) error {
+	switch x := v.(type) {
+	case nil:
+		e.WriteNull()
+	case bool:
+		e.WriteBool(x)
+	case string:
+		e.WriteString(x)
+	case int:
+		e.writeInteger(int64(x))
+	case int32:
+		e.WriteInt(x)
+	case int64:
+		e.WriteLong(x)
+	case float32:
+		e.WriteDouble(float64(x))
+	case float64:
+		if x == math.Trunc(x) && math.Abs(x) < 1<<53 {
+			e.writeInteger(int64(x))
+		} else {
+			e.WriteDouble(x)
+		}
+	case json.Number:
+		if n, err := x.Int64(); err == nil {
+			e.writeInteger(n)
+			return nil
+		}
+		f, err := x.Float64()
+		if err != nil {
+			return fmt.Errorf("invalid number %s", x)
+		}
+		e.WriteDouble(f)
+	case []byte:
+		e.WriteBytes(x)
+	case time.Time:
+		e.WriteDate(x)
+	case []interface{}:
+		e.beginList("", len(x))
+		for _, elem := range x {
+			if err := e.Encode(elem); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		return e.encodeMap("", x, nil)
+	case map[string]string:
+		e.beginMap("")
+		for _, k := range sortedKeys(x) {
+			e.WriteString(k)
+			e.WriteString(x[k])
+		}
+		e.endMap()
+	default:
+		return fmt.Errorf("unsupported value of type %T", v)
+	}
+	return nil
+}
+
+// writeInteger writes n as an int when it fits, and as a long otherwise.
+// Java deserializers accept either for both int and long targets.
+func (e *Encoder) writeInteger(n int64) {
+	if n >= math.MinInt32 && n <= math.MaxInt32 {
+		e.WriteInt(int32(n))
+	} else {
+		e.WriteLong(n)
+	}
+}
+
+// encodeMap writes m with sorted keys. Values are encoded with valueFn when
+// set, and with Encode otherwise.
+func (e *Encoder) encodeMap(typ string, m map[string]interface{}, valueFn func(interface{}) error) error {
+	if valueFn == nil {
+		valueFn = e.Encode
+	}
+	e.beginMap(typ)
+	for _, k := range sortedKeys(m) {
+		e.WriteString(k)
+		if err := valueFn(m[k]); err != nil {
+			return fmt.Errorf("%s: %w", k, err)
+		}
+	}
+	e.endMap()
+	return nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// classDef is an object class definition read from the stream.
+type classDef struct {
+	name   string
+	fields []string
+}
+
+// Decoder reads Hessian 2 values from a byte slice.
+type Decoder struct {
+	data    []byte
+	pos     int
+	types   []string
+	classes []classDef
+	refs    []interface{}
+}
+
+// NewDecoder creates a Decoder reading data.
+func NewDecoder(data []byte) *Decoder {
+	return &Decoder{data: data}
+}
+
+// Remaining reports whether unread data is left.
+func (d *Decoder) Remaining() bool { return d.pos < len(d.data) }
+
+func (d *Decoder) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("hessian: offset %d: %s", d.pos, fmt.Sprintf(format, args...))
+}
+
+func (d *Decoder) readByte() (byte, error) {
+	if d.pos >= len(d.data) {
+		return 0, d.errorf("unexpected end of data")
+	}
+	b := d.data[d.pos]
+	d.pos++
+	return b, nil
+}
+
+func (d *Decoder) next(n int) ([]byte, error) {
+	if n < 0 || len(d.data)-d.pos < n {
+		return nil, d.errorf("unexpected end of data")
+	}
+	b := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+func (d *Decoder) peek() (byte, error) {
+	if d.pos >= len(d.data) {
+		return 0, d.errorf("unexpected end of data")
+	}
+	return d.data[d.pos], nil
+}
+
+// Decode reads the next value. Ints decode to int32, longs to int64, doubles
+// to float64, dates to time.Time, binary data to []byte, lists to
+// []interface{}, maps to map[string]interface{} (non-string keys are
+// formatted with fmt) and objects to *Object.
+func (d *Decoder) Decode() (interface{}, error) {
+	tag, err := d.readByte()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case tag == 'N':
+		return nil, nil
+	case tag == 'T':
+		return true, nil
+	case tag == 'F':
+		return false, nil
+
+	case tag >= 0x80 && tag <= 0xd7, tag == 'I':
+		return d.readIntTag(tag)
+	case tag >= 0xd8, tag >= 0x38 && tag <= 0x3f, tag == 0x59, tag == 'L':
+		return d.readLongTag(tag)
+	case tag >= 0x5b && tag <= 0x5f, tag == 'D':
+		return d.readDoubleTag(tag)
+
+	case tag == 0x4a:
+		b, err := d.next(8)
+		if err != nil {
+			return nil, err
+		}
+		return time.UnixMilli(int64(binary.BigEndian.Uint64(b))).UTC(), nil
+	case tag == 0x4b:
+		b, err := d.next(4)
+		if err != nil {
+			return nil, err
+		}
+		return time.Unix(int64(int32(binary.BigEndian.Uint32(b)))*60, 0).UTC(), nil
+
+	case tag <= 0x1f, tag >= 0x30 && tag <= 0x33, tag == 'S', tag == 'R':
+		return d.readStringTag(tag)
+	case tag >= 0x20 && tag <= 0x2f, tag >= 0x34 && tag <= 0x37, tag == 'B', tag == 'A':
+		return d.readBytesTag(tag)
+
+	case tag == 0x55, tag == 'V', tag == 0x57, tag == 0x58, tag >= 0x70:
+		return d.readListTag(tag)
+	case tag == 'M':
+		if _, err := d.readType(); err != nil {
+			return nil, err
+		}
+		return d.readMapBody()
+	case tag == 'H':
+		return d.readMapBody()
+
+	case tag == 'C':
+		if err := d.readClassDef(); err != nil {
+			return nil, err
+		}
+		return d.Decode()
+	case tag == 'O':
+		ref, err := d.ReadInt()
+		if err != nil {
+			return nil, err
+		}
+		return d.readObject(int(ref))
+	case tag >= 0x60 && tag <= 0x6f:
+		return d.readObject(int(tag - 0x60))
+
+	case tag == 0x51:
+		ref, err := d.ReadInt()
+		if err != nil {
+			return nil, err
+		}
+		if ref < 0 || int(ref) >= len(d.refs) {
+			return nil, d.errorf("invalid reference %d", ref)
+		}
+		return d.refs[ref], nil
+	}
+	return nil, d.errorf("unknown tag 0x%02x", tag)
+}
+
+// ReadInt reads an int-encoded value.
+func (d *Decoder) ReadInt() (int32, error) {
+	tag, err := d.readByte()
+	if err != nil {
+		return 0, err
+	}
+	return d.readIntTag(tag)
+}
+
+func (d *Decoder) readIntTag(tag byte) (int32, error) {
+	switch {
+	case tag >= 0x80 && tag <= 0xbf:
+		return int32(tag) - 0x90, nil
+	case tag >= 0xc0 && tag <= 0xcf:
+		b, err := d.readByte()
+		if err != nil {
+			return 0, err
+		}
+		return (int32(tag)-0xc8)<<8 | int32(b), nil
+	case tag >= 0xd0 && tag <= 0xd7:
+		b, err := d.next(2)
+		if err != nil {
+			return 0, err
+		}
+		return (int32(tag)-0xd4)<<16 | int32(b[0])<<8 | int32(b[1]), nil
+	case tag == 'I':
+		b, err := d.next(4)
+		if err != nil {
+			return 0, err
+		}
+		return int32(binary.BigEndian.Uint32(b)), nil
+	}
+	return 0, d.errorf("expected int, got tag 0x%02x", tag)
+}
+
+func (d *Decoder) readLongTag(tag byte) (int64, error) {
+	switch {
+	case tag >= 0xd8 && tag <= 0xef:
+		return int64(tag) - 0xe0, nil
+	case tag >= 0xf0:
+		b, err := d.readByte()
+		if err != nil {
+			return 0, err
+		}
+		return (int64(tag)-0xf8)<<8 | int64(b), nil
+	case tag >= 0x38 && tag <= 0x3f:
+		b, err := d.next(2)
+		if err != nil {
+			return 0, err
+		}
+		return (int64(tag)-0x3c)<<16 | int64(b[0])<<8 | int64(b[1]), nil
+	case tag == 0x59:
+		b, err := d.next(4)
+		if err != nil {
+			return 0, err
+		}
+		return int64(int32(binary.BigEndian.Uint32(b))), nil
+	case tag == 'L':
+		b, err := d.next(8)
+		if err != nil {
+			return 0, err
+		}
+		return int64(binary.BigEndian.Uint64(b)), nil
+	}
+	return 0, d.errorf("expected long, got tag 0x%02x", tag)
+}
+
+func (d *Decoder) readDoubleTag(tag byte) (float64, error) {
+	switch tag {
+	case 0x5b:
+		return 0, nil
+	case 0x5c:
+		return 1, nil
+	case 0x5d:
+		b, err := d.readByte()
+		return float64(int8(b)), err
+	case 0x5e:
+		b, err := d.next(2)
+		if err != nil {
+			return 0, err
+		}
+		return float64(int16(binary.BigEndian.Uint16(b))), nil
+	case 0x5f:
+		// Dubbo's hessian-lite writes doubles with up to three decimals as
+		// an int32 count of thousandths.
+		b, err := d.next(4)
+		if err != nil {
+			return 0, err
+		}
+		return float64(int32(binary.BigEndian.Uint32(b))) * 0.001, nil
+	case 'D':
+		b, err := d.next(8)
+		if err != nil {
+			return 0, err
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), nil
+	}
+	return 0, d.errorf("expected double, got tag 0x%02x", tag)
+}
+
+// ReadString reads a string value. A null is returned as "".
+func (d *Decoder) ReadString() (string, error) {
+	tag, err := d.readByte()
+	if err != nil {
+		return "", err
+	}
+	if tag == 'N' {
+		return "", nil
+	}
+	return d.readStringTag(tag)
+}
+
+func (d *Decoder) readStringTag(tag byte) (string, error) {
+	var units []uint16
+	for {
+		var n int
+		final := true
+		switch {
+		case tag <= 0x1f:
+			n = int(tag)
+		case tag >= 0x30 && tag <= 0x33:
+			b, err := d.readByte()
+			if err != nil {
+				return "", err
+			}
+			n = int(tag-0x30)<<8 | int(b)
+		case tag == 'S', tag == 'R':
+			b, err := d.next(2)
+			if err != nil {
+				return "", err
+			}
+			n = int(binary.BigEndian.Uint16(b))
+			final = tag == 'S'
+		default:
+			return "", d.errorf("expected string, got tag 0x%02x", tag)
+		}
+		var err error
+		if units, err = d.readChars(units, n); err != nil {
+			return "", err
+		}
+		if final {
+			return string(utf16.Decode(units)), nil
+		}
+		if tag, err = d.readByte(); err != nil {
+			return "", err
+		}
+	}
+}
+
+// readChars reads n UTF-16 code units encoded as UTF-8. Four-byte sequences,
+// which some encoders emit for supplementary characters, count as two units.
+func (d *Decoder) readChars(units []uint16, n int) ([]uint16, error) {
+	for n > 0 {
+		b, err := d.readByte()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case b < 0x80:
+			units = append(units, uint16(b))
+		case b&0xe0 == 0xc0:
+			c, err := d.readByte()
+			if err != nil {
+				return nil, err
+			}
+			units = append(units, uint16(b&0x1f)<<6|uint16(c&0x3f))
+		case b&0xf0 == 0xe0:
+			c, err := d.next(2)
+			if err != nil {
+				return nil, err
+			}
+			units = append(units, uint16(b&0x0f)<<12|uint16(c[0]&0x3f)<<6|uint16(c[1]&0x3f))
+		case b&0xf8 == 0xf0:
+			d.pos--
+			r, size := utf8.DecodeRune(d.data[d.pos:])
+			if r == utf8.RuneError {
+				return nil, d.errorf("invalid UTF-8")
+			}
+			d.pos += size
+			r1, r2 := utf16.EncodeRune(r)
+			units = append(units, uint16(r1), uint16(r2))
+			n--
+		default:
+			return nil, d.errorf("invalid UTF-8")
+		}
+		n--
+	}
+	return units, nil
+}
+
+func (d *Decoder) readBytesTag(tag byte) ([]byte, error) {
+	out := []byte{}
+	for {
+		var n int
+		final := true
+		switch {
+		case tag >= 0x20 && tag <= 0x2f:
+			n = int(tag - 0x20)
+		case tag >= 0x34 && tag <= 0x37:
+			b, err := d.readByte()
+			if err != nil {
+				return nil, err
+			}
+			n = int(tag-0x34)<<8 | int(b)
+		case tag == 'B', tag == 'A':
+			b, err := d.next(2)
+			if err != nil {
+				return nil, err
+			}
+			n = int(binary.BigEndian.Uint16(b))
+			final = tag == 'B'
+		default:
+			return nil, d.errorf("expected binary, got tag 0x%02x", tag)
+		}
+		chunk, err := d.next(n)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, chunk...)
+		if final {
+			return out, nil
+		}
+		if tag, err = d.readByte(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// readType reads a type name or a reference to a previously read one.
+func (d *Decoder) readType() (string, error) {
+	tag, err := d.peek()
+	if err != nil {
+		return "", err
+	}
+	if tag <= 0x1f || tag >= 0x30 && tag <= 0x33 || tag == 'S' || tag == 'R' {
+		s, err := d.ReadString()
+		if err != nil {
+			return "", err
+		}
+		d.types = append(d.types, s)
+		return s, nil
+	}
+	ref, err := d.ReadInt()
+	if err != nil {
+		return "", err
+	}
+	if ref < 0 || int(ref) >= len(d.types) {
+		return "", d.errorf("invalid type reference %d", ref)
+	}
+	return d.types[ref], nil
+}
+
+func (d *Decoder) readListTag(tag byte) ([]interface{}, error) {
+	n := -1 // variable length, terminated by 'Z'
+	switch {
+	case tag == 0x55:
+		if _, err := d.readType(); err != nil {
+			return nil, err
+		}
+	case tag == 'V':
+		if _, err := d.readType(); err != nil {
+			return nil, err
+		}
+		l, err := d.ReadInt()
+		if err != nil {
+			return nil, err
+		}
+		n = int(l)
+	case tag == 0x57:
+	case tag == 0x58:
+		l, err := d.ReadInt()
+		if err != nil {
+			return nil, err
+		}
+		n = int(l)
+	case tag >= 0x70 && tag <= 0x77:
+		if _, err := d.readType(); err != nil {
+			return nil, err
+		}
+		n = int(tag - 0x70)
+	case tag >= 0x78:
+		n = int(tag - 0x78)
+	}
+	if n < -1 || n > len(d.data)-d.pos {
+		return nil, d.errorf("invalid list length %d", n)
+	}
+
+	ref := len(d.refs)
+	d.refs = append(d.refs, nil)
+	list := make([]interface{}, 0, max(n, 0))
+	for i := 0; n < 0 || i < n; i++ {
+		if n < 0 {
+			if b, err := d.peek(); err != nil {
+				return nil, err
+			} else if b == 'Z' {
+				d.pos++
+				break
+			}
+		}
+		v, err := d.Decode()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, v)
+	}
+	d.refs[ref] = list
+	return list, nil
+}
+
+func (d *Decoder) readMapBody() (map[string]interface{}, error) {
+	m := make(map[string]interface{})
+	d.refs = append(d.refs, m)
+	for {
+		b, err := d.peek()
+		if err != nil {
+			return nil, err
+		}
+		if b == 'Z' {
+			d.pos++
+			return m, nil
+		}
+		k, err := d.Decode()
+		if err != nil {
+			return nil, err
+		}
+		v, err := d.Decode()
+		if err != nil {
+			return nil, err
+		}
+		m[mapKey(k)] = v
+	}
+}
+
+func mapKey(k interface{}) string {
+	switch x := k.(type) {
+	case string:
+		return x
+	case nil:
+		return "null"
+	case int32:
+		return strconv.Itoa(int(x))
+	case int64:
+		return strconv.FormatInt(x, 10)
+	default:
+		return fmt.Sprint(x)
+	}
+}
+
+func (d *Decoder) readClassDef() error {
+	name, err := d.ReadString()
+	if err != nil {
+		return err
+	}
+	n, err := d.ReadInt()
+	if err != nil {
+		return err
+	}
+	if n < 0 || int(n) > len(d.data)-d.pos {
+		return d.errorf("invalid field count %d", n)
+	}
+	def := classDef{name: name, fields: make([]string, n)}
+	for i := range def.fields {
+		if def.fields[i], err = d.ReadString(); err != nil {
+			return err
+		}
+	}
+	d.classes = append(d.classes, def)
+	return nil
+}
+
+func (d *Decoder) readObject(ref int) (*Object, error) {
+	if ref < 0 || ref >= len(d.classes) {
+		return nil, d.errorf("invalid class reference %d", ref)
+	}
+	def := d.classes[ref]
+	obj := &Object{Type: def.name, Fields: make(map[string]interface{}, len(def.fields))}
+	d.refs = append(d.refs, obj)
+	for _, f := range def.fields {
+		v, err := d.Decode()
+		if err != nil {
+			return nil, err
+		}
+		obj.Fields[f] = v
+	}
+	return obj, nil
+}
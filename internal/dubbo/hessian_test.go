@@ -0,0 +1,186 @@
+package dubbo
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEncoder_CompactEncodings(t *testing.T) {
+	tests := []struct {
+		name  string
+		write func(e *Encoder)
+		want  []byte
+	}{
+		{"int 0", func(e *Encoder) { e.WriteInt(0) }, []byte{0x90}},
+		{"int -16", func(e *Encoder) { e.WriteInt(-16) }, []byte{0x80}},
+		{"int 47", func(e *Encoder) { e.WriteInt(47) }, []byte{0xbf}},
+		{"int 48", func(e *Encoder) { e.WriteInt(48) }, []byte{0xc8, 0x30}},
+		{"int -2048", func(e *Encoder) { e.WriteInt(-2048) }, []byte{0xc0, 0x00}},
+		{"int 262143", func(e *Encoder) { e.WriteInt(262143) }, []byte{0xd7, 0xff, 0xff}},
+		{"int max", func(e *Encoder) { e.WriteInt(math.MaxInt32) }, []byte{'I', 0x7f, 0xff, 0xff, 0xff}},
+		{"long 0", func(e *Encoder) { e.WriteLong(0) }, []byte{0xe0}},
+		{"long -8", func(e *Encoder) { e.WriteLong(-8) }, []byte{0xd8}},
+		{"long 2047", func(e *Encoder) { e.WriteLong(2047) }, []byte{0xff, 0xff}},
+		{"long 262143", func(e *Encoder) { e.WriteLong(262143) }, []byte{0x3f, 0xff, 0xff}},
+		{"long int32", func(e *Encoder) { e.WriteLong(math.MaxInt32) }, []byte{0x59, 0x7f, 0xff, 0xff, 0xff}},
+		{"double 0", func(e *Encoder) { e.WriteDouble(0) }, []byte{0x5b}},
+		{"double 1", func(e *Encoder) { e.WriteDouble(1) }, []byte{0x5c}},
+		{"double 127", func(e *Encoder) { e.WriteDouble(127) }, []byte{0x5d, 0x7f}},
+		{"double 1000", func(e *Encoder) { e.WriteDouble(1000) }, []byte{0x5e, 0x03, 0xe8}},
+		{"double -0", func(e *Encoder) { e.WriteDouble(math.Copysign(0, -1)) }, []byte{'D', 0x80, 0, 0, 0, 0, 0, 0, 0}},
+		{"string", func(e *Encoder) { e.WriteString("hello") }, []byte{0x05, 'h', 'e', 'l', 'l', 'o'}},
+		{"bytes", func(e *Encoder) { e.WriteBytes([]byte{1, 2}) }, []byte{0x22, 1, 2}},
+		{"null", func(e *Encoder) { e.WriteNull() }, []byte{'N'}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var e Encoder
+			tt.write(&e)
+			if !bytes.Equal(e.Bytes(), tt.want) {
+				t.Errorf("got % x, want % x", e.Bytes(), tt.want)
+			}
+		})
+	}
+}
+
+func TestEncoder_RoundTrip(t *testing.T) {
+	date := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		in   interface{}
+		want interface{}
+	}{
+		{"null", nil, nil},
+		{"true", true, true},
+		{"small int", 7, int32(7)},
+		{"negative int", -300000, int32(-300000)},
+		{"long", int64(1) << 40, int64(1) << 40},
+		{"double", 12.25, 12.25},
+		{"json number", json.Number("42"), int32(42)},
+		{"json float", json.Number("0.5"), 0.5},
+		{"string", "héllo 世界", "héllo 世界"},
+		{"supplementary", "emoji 😀", "emoji 😀"},
+		{"medium string", strings.Repeat("x", 500), strings.Repeat("x", 500)},
+		{"long string", strings.Repeat("ab", 40000), strings.Repeat("ab", 40000)},
+		{"bytes", bytes.Repeat([]byte{9}, 2000), bytes.Repeat([]byte{9}, 2000)},
+		{"date", date, date},
+		{"list", []interface{}{"a", 1, nil}, []interface{}{"a", int32(1), nil}},
+		{"long list", make([]interface{}, 9), make([]interface{}, 9)},
+		{"map", map[string]interface{}{"k": "v", "n": []interface{}{true}},
+			map[string]interface{}{"k": "v", "n": []interface{}{true}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var e Encoder
+			if err := e.Encode(tt.in); err != nil {
+				t.Fatalf("Encode: %v", err)
+			}
+			d := NewDecoder(e.Bytes())
+			got, err := d.Decode()
+			if err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+			if d.Remaining() {
+				t.Error("unread data left after decode")
+			}
+		})
+	}
+}
+
+func TestDecoder_Objects(t *testing.T) {
+	// class Car { color, model }, two instances, the second by compact ref,
+	// followed by a back-reference to the first.
+	data := []byte{
+		'C', 0x0b, 'e', 'x', 'a', 'm', 'p', 'l', 'e', '.', 'C', 'a', 'r',
+		0x92, 0x05, 'c', 'o', 'l', 'o', 'r', 0x05, 'm', 'o', 'd', 'e', 'l',
+		0x60, 0x03, 'r', 'e', 'd', 0x08, 'c', 'o', 'r', 'v', 'e', 't', 't', 'e',
+	}
+	d := NewDecoder(append(data, 0x60, 0x04, 'b', 'l', 'u', 'e', 'N', 0x51, 0x90))
+	first, err := d.Decode()
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	obj, ok := first.(*Object)
+	if !ok || obj.Type != "example.Car" || obj.Fields["color"] != "red" || obj.Fields["model"] != "corvette" {
+		t.Fatalf("unexpected first object %#v", first)
+	}
+	second, err := d.Decode()
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if o := second.(*Object); o.Fields["color"] != "blue" || o.Fields["model"] != nil {
+		t.Errorf("unexpected second object %#v", o.Fields)
+	}
+	ref, err := d.Decode()
+	if err != nil {
+		t.Fatalf("Decode ref: %v", err)
+	}
+	if ref != first {
+		t.Errorf("reference did not resolve to the first object")
+	}
+
+	b, _ := json.Marshal(obj)
+	if string(b) != `{"color":"red","model":"corvette"}` {
+		t.Errorf("unexpected JSON %s", b)
+	}
+}
+
+func TestDecoder_TypedCollections(t *testing.T) {
+	var e Encoder
+	e.beginList("java.util.ArrayList", 2)
+	e.WriteInt(1)
+	e.WriteInt(2)
+	e.beginMap("java.util.HashMap")
+	e.WriteInt(1)
+	e.WriteString("one")
+	e.endMap()
+	// Variable-length list, terminated by 'Z'.
+	e.buf = append(e.buf, 0x57)
+	e.WriteString("x")
+	e.buf = append(e.buf, 'Z')
+
+	d := NewDecoder(e.Bytes())
+	list, err := d.Decode()
+	if err != nil || !reflect.DeepEqual(list, []interface{}{int32(1), int32(2)}) {
+		t.Fatalf("list: got %#v, %v", list, err)
+	}
+	m, err := d.Decode()
+	if err != nil || !reflect.DeepEqual(m, map[string]interface{}{"1": "one"}) {
+		t.Fatalf("map: got %#v, %v", m, err)
+	}
+	v, err := d.Decode()
+	if err != nil || !reflect.DeepEqual(v, []interface{}{"x"}) {
+		t.Fatalf("variable list: got %#v, %v", v, err)
+	}
+}
+
+func TestDecoder_Truncated(t *testing.T) {
+	var e Encoder
+	if err := e.Encode(map[string]interface{}{"key": "value"}); err != nil {
+		t.Fatal(err)
+	}
+	data := e.Bytes()
+	for i := 0; i < len(data); i++ {
+		if _, err := NewDecoder(data[:i]).Decode(); err == nil {
+			t.Errorf("expected error decoding %d of %d bytes", i, len(data))
+		}
+	}
+}
+
+func TestDecoder_DoubleMill(t *testing.T) {
+	got, err := NewDecoder([]byte{0x5f, 0x00, 0x00, 0x30, 0x39}).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f := got.(float64); math.Abs(f-12.345) > 1e-9 {
+		t.Errorf("got %v, want 12.345", f)
+	}
+}
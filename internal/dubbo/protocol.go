@@ -0,0 +1,544 @@
+package dubbo
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Frame header layout:
+//
+//	0-1   magic 0xdabb
+//	2     flags: request, two-way, event, serialization id (low 5 bits)
+//	3     status (responses only)
+//	4-11  request id
+//	12-15 body length
+const (
+	headerLength = 16
+	magic        = 0xdabb
+
+	flagRequest = 0x80
+	flagTwoWay  = 0x40
+	flagEvent   = 0x20
+
+	// SerializationHessian2 is the Dubbo serialization id for Hessian 2.
+	SerializationHessian2 = 2
+
+	// DubboVersion is the protocol version advertised in requests.
+	DubboVersion = "2.0.2"
+
+	// DefaultMaxBodySize bounds a frame body, matching Dubbo's default
+	// payload limit of 8 MiB.
+	DefaultMaxBodySize = 8 << 20
+)
+
+// Response statuses.
+const (
+	StatusOK                = 20
+	StatusClientTimeout     = 30
+	StatusServerTimeout     = 31
+	StatusBadRequest        = 40
+	StatusBadResponse       = 50
+	StatusServiceNotFound   = 60
+	StatusServiceError      = 70
+	StatusServerError       = 80
+	StatusClientError       = 90
+	StatusThreadpoolExhaust = 100
+)
+
+// Response body kinds written by the provider before the result.
+const (
+	responseWithException               = 0
+	responseValue                       = 1
+	responseNullValue                   = 2
+	responseWithExceptionWithAttachment = 3
+	responseValueWithAttachments        = 4
+	responseNullValueWithAttachments    = 5
+)
+
+// Frame is one Dubbo protocol message.
+type Frame struct {
+	ID      int64
+	Request bool
+	TwoWay  bool
+	Event   bool
+	Status  byte
+	Body    []byte
+}
+
+// heartbeat reports whether f is a heartbeat event (a null event body).
+func (f *Frame) heartbeat() bool {
+	return f.Event && (len(f.Body) == 0 || len(f.Body) == 1 && f.Body[0] == 'N')
+}
+
+// WriteFrame writes f using Hessian 2 serialization.
+func WriteFrame(w io.Writer, f *Frame) error {
+	buf := make([]byte, headerLength, headerLength+len(f.Body))
+	binary.BigEndian.PutUint16(buf[0:2], magic)
+	flags := byte(SerializationHessian2)
+	if f.Request {
+		flags |= flagRequest
+		if f.TwoWay {
+			flags |= flagTwoWay
+		}
+	}
+	if f.Event {
+		flags |= flagEvent
+	}
+	buf[2] = flags
+	buf[3] = f.Status
+	binary.BigEndian.PutUint64(buf[4:12], uint64(f.ID))
+	binary.BigEndian.PutUint32(buf[12:16], uint32(len(f.Body)))
+	buf = append(buf, f.Body...)
+	_, err := w.Write(buf)
+	return err
+}
+
+// ReadFrame reads one frame, rejecting bodies larger than maxBody bytes.
+func ReadFrame(r io.Reader, maxBody int) (*Frame, error) {
+	var hdr [headerLength]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, err
+	}
+	if binary.BigEndian.Uint16(hdr[0:2]) != magic {
+		return nil, fmt.Errorf("dubbo: invalid magic 0x%04x", binary.BigEndian.Uint16(hdr[0:2]))
+	}
+	if id := hdr[2] & 0x1f; id != SerializationHessian2 {
+		return nil, fmt.Errorf("dubbo: unsupported serialization id %d", id)
+	}
+	n := binary.BigEndian.Uint32(hdr[12:16])
+	if int64(n) > int64(maxBody) {
+		return nil, fmt.Errorf("dubbo: frame body of %d bytes exceeds limit of %d", n, maxBody)
+	}
+	f := &Frame{
+		ID:      int64(binary.BigEndian.Uint64(hdr[4:12])),
+		Request: hdr[2]&flagRequest != 0,
+		TwoWay:  hdr[2]&flagTwoWay != 0,
+		Event:   hdr[2]&flagEvent != 0,
+		Status:  hdr[3],
+		Body:    make([]byte, n),
+	}
+	if _, err := io.ReadFull(r, f.Body); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Invocation is a call to a provider method.
+type Invocation struct {
+	Interface string
+	Version   string
+	Group     string
+	Method    string
+	// Application names the calling application to the provider.
+	Application string
+	// ParamTypes are Java type names, such as "java.lang.String", "int" or
+	// "com.foo.OrderRequest". Args are converted to match them.
+	ParamTypes  []string
+	Args        []interface{}
+	Attachments map[string]string
+	Timeout     time.Duration
+}
+
+// GenericMethod is the method of org.apache.dubbo.rpc.service.GenericService
+// that invokes any provider method by name.
+const GenericMethod = "$invoke"
+
+// genericParamTypes is the signature of GenericService.$invoke.
+var genericParamTypes = []string{"java.lang.String", "java.lang.String[]", "java.lang.Object[]"}
+
+// Generic returns an equivalent invocation through GenericService.$invoke.
+// Arguments are sent without type information, as plain maps and lists,
+// which the provider converts to the declared parameter types.
+func (inv *Invocation) Generic() *Invocation {
+	types := make([]interface{}, len(inv.ParamTypes))
+	for i, t := range inv.ParamTypes {
+		types[i] = t
+	}
+	args := inv.Args
+	if args == nil {
+		args = []interface{}{}
+	}
+	g := *inv
+	g.Method = GenericMethod
+	g.ParamTypes = genericParamTypes
+	g.Args = []interface{}{inv.Method, types, args}
+	g.Attachments = map[string]string{"generic": "true"}
+	for k, v := range inv.Attachments {
+		g.Attachments[k] = v
+	}
+	return &g
+}
+
+// encodeRequest serializes the invocation body.
+func (inv *Invocation) encodeRequest() ([]byte, error) {
+	if len(inv.Args) != len(inv.ParamTypes) {
+		return nil, fmt.Errorf("%d arguments given for %d parameter types", len(inv.Args), len(inv.ParamTypes))
+	}
+	var e Encoder
+	e.WriteString(DubboVersion)
+	e.WriteString(inv.Interface)
+	e.WriteString(inv.Version)
+	e.WriteString(inv.Method)
+
+	var desc strings.Builder
+	for _, t := range inv.ParamTypes {
+		desc.WriteString(typeDescriptor(t))
+	}
+	e.WriteString(desc.String())
+
+	for i, arg := range inv.Args {
+		if err := e.EncodeAs(inv.ParamTypes[i], arg); err != nil {
+			return nil, fmt.Errorf("argument %d (%s): %w", i, inv.ParamTypes[i], err)
+		}
+	}
+
+	att := map[string]string{
+		"path":      inv.Interface,
+		"interface": inv.Interface,
+	}
+	if inv.Version != "" {
+		att["version"] = inv.Version
+	}
+	if inv.Group != "" {
+		att["group"] = inv.Group
+	}
+	if inv.Application != "" {
+		att["remote.application"] = inv.Application
+	}
+	if inv.Timeout > 0 {
+		att["timeout"] = strconv.FormatInt(inv.Timeout.Milliseconds(), 10)
+	}
+	for k, v := range inv.Attachments {
+		att[k] = v
+	}
+	if err := e.Encode(att); err != nil {
+		return nil, err
+	}
+	return e.Bytes(), nil
+}
+
+// Result is the outcome of an invocation that reached the provider's method.
+// Exactly one of Value and Exception is meaningful.
+type Result struct {
+	Value       interface{}
+	Exception   interface{}
+	Attachments map[string]interface{}
+	// RequestBody and ResponseBody hold the message bodies as sent and
+	// received, for diagnostics: the Hessian 2 request and response over
+	// dubbo2, or the request and response wrappers over triple.
+	RequestBody  []byte
+	ResponseBody []byte
+}
+
+// Message returns the exception message, or "" if the call succeeded.
+func (r *Result) Message() string {
+	obj, ok := r.Exception.(*Object)
+	if !ok {
+		if r.Exception == nil {
+			return ""
+		}
+		return fmt.Sprint(r.Exception)
+	}
+	if class, ok := genericExceptionClass(obj); ok {
+		if msg, _ := obj.Fields["exceptionMessage"].(string); msg != "" {
+			return class + ": " + msg
+		}
+		return class
+	}
+	if msg, ok := obj.Fields["detailMessage"].(string); ok && msg != "" {
+		return obj.Type + ": " + msg
+	}
+	return obj.Type
+}
+
+// ExceptionType returns the Java class of the exception, if known. For a
+// GenericException thrown by a generic invocation this is the class of the
+// original exception.
+func (r *Result) ExceptionType() string {
+	obj, ok := r.Exception.(*Object)
+	if !ok {
+		return ""
+	}
+	if class, ok := genericExceptionClass(obj); ok {
+		return class
+	}
+	return obj.Type
+}
+
+// RpcExceptionCode returns the code of an RpcException raised by the Dubbo
+// framework, such as RpcTimeout, and whether the exception is one.
+func (r *Result) RpcExceptionCode() (int, bool) {
+	obj, ok := r.Exception.(*Object)
+	if !ok {
+		return 0, false
+	}
+	switch obj.Type {
+	case "org.apache.dubbo.rpc.RpcException", "com.alibaba.dubbo.rpc.RpcException":
+	default:
+		return 0, false
+	}
+	code, _ := obj.Fields["code"].(int32)
+	return int(code), true
+}
+
+// genericExceptionClass returns the original exception class carried by a
+// GenericException.
+func genericExceptionClass(obj *Object) (string, bool) {
+	switch obj.Type {
+	case "org.apache.dubbo.rpc.service.GenericException", "com.alibaba.dubbo.rpc.service.GenericException":
+	default:
+		return "", false
+	}
+	class, _ := obj.Fields["exceptionClass"].(string)
+	return class, class != ""
+}
+
+// RpcException codes, from org.apache.dubbo.rpc.RpcException.
+const (
+	RpcUnknown             = 0
+	RpcNetwork             = 1
+	RpcTimeout             = 2
+	RpcBiz                 = 3
+	RpcForbidden           = 4
+	RpcSerialization       = 5
+	RpcNoInvoker           = 6
+	RpcLimitExceeded       = 7
+	RpcTimeoutTerminate    = 8
+	RpcRegistry            = 9
+	RpcRouterCacheNotBuilt = 10
+	RpcMethodNotFound      = 11
+	RpcValidation          = 12
+)
+
+// StatusError is returned when the provider answers with a status other than
+// OK, for example because the service is not exported or its pool is full.
+type StatusError struct {
+	Status  byte
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("dubbo: status %d: %s", e.Status, e.Message)
+}
+
+// decodeResponse parses the body of a response frame.
+func decodeResponse(f *Frame) (*Result, error) {
+	d := NewDecoder(f.Body)
+	if f.Status != StatusOK {
+		msg, err := d.ReadString()
+		if err != nil {
+			msg = "(unreadable error message)"
+		}
+		return nil, &StatusError{Status: f.Status, Message: msg}
+	}
+
+	kind, err := d.ReadInt()
+	if err != nil {
+		return nil, fmt.Errorf("dubbo: read response kind: %w", err)
+	}
+	res := &Result{}
+	switch kind {
+	case responseValue, responseValueWithAttachments:
+		res.Value, err = d.Decode()
+	case responseWithException, responseWithExceptionWithAttachment:
+		res.Exception, err = d.Decode()
+	case responseNullValue, responseNullValueWithAttachments:
+	default:
+		return nil, fmt.Errorf("dubbo: unknown response kind %d", kind)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("dubbo: decode response: %w", err)
+	}
+	if kind >= responseWithExceptionWithAttachment {
+		att, err := d.Decode()
+		if err != nil {
+			return nil, fmt.Errorf("dubbo: decode response attachments: %w", err)
+		}
+		res.Attachments, _ = att.(map[string]interface{})
+	}
+	return res, nil
+}
+
+// primitiveDescriptors maps Java primitive types to their JVM descriptors.
+var primitiveDescriptors = map[string]string{
+	"boolean": "Z",
+	"byte":    "B",
+	"char":    "C",
+	"short":   "S",
+	"int":     "I",
+	"long":    "J",
+	"float":   "F",
+	"double":  "D",
+	"void":    "V",
+}
+
+// typeDescriptor converts a Java type name to the JVM descriptor Dubbo uses
+// to identify method overloads: "int" → "I", "java.lang.String" →
+// "Ljava/lang/String;", "long[]" → "[J".
+func typeDescriptor(t string) string {
+	if elem, ok := strings.CutSuffix(t, "[]"); ok {
+		return "[" + typeDescriptor(elem)
+	}
+	if d, ok := primitiveDescriptors[t]; ok {
+		return d
+	}
+	return "L" + strings.ReplaceAll(t, ".", "/") + ";"
+}
+
+// EncodeAs writes a JSON-decoded value as the given Java type. Numbers are
+// narrowed to the declared numeric type, byte[] accepts base64 strings,
+// java.util.Date accepts RFC 3339 strings or epoch milliseconds, and JSON
+// objects passed for application classes are written as maps typed with the
+// class name, which Hessian deserializes into the class.
+func (e *Encoder) EncodeAs(javaType string, v interface{}) error {
+	if v == nil {
+		e.WriteNull()
+		return nil
+	}
+	switch javaType {
+	case "", "java.lang.Object":
+		return e.Encode(v)
+	case "boolean", "java.lang.Boolean":
+		b, ok := v.(bool)
+		if !ok {
+			return fmt.Errorf("expected boolean, got %T", v)
+		}
+		e.WriteBool(b)
+	case "byte", "short", "int", "java.lang.Byte", "java.lang.Short", "java.lang.Integer":
+		n, err := integerOf(v)
+		if err != nil {
+			return err
+		}
+		e.WriteInt(int32(n))
+	case "long", "java.lang.Long":
+		n, err := integerOf(v)
+		if err != nil {
+			return err
+		}
+		e.WriteLong(n)
+	case "float", "double", "java.lang.Float", "java.lang.Double":
+		f, err := floatOf(v)
+		if err != nil {
+			return err
+		}
+		e.WriteDouble(f)
+	case "char", "java.lang.Character", "java.lang.String":
+		s, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("expected string, got %T", v)
+		}
+		e.WriteString(s)
+	case "byte[]":
+		s, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("expected base64 string, got %T", v)
+		}
+		b, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return fmt.Errorf("invalid base64: %w", err)
+		}
+		e.WriteBytes(b)
+	case "java.util.Date":
+		t, err := timeOf(v)
+		if err != nil {
+			return err
+		}
+		e.WriteDate(t)
+	case "java.util.List", "java.util.ArrayList", "java.util.Collection", "java.util.Set", "java.util.HashSet":
+		list, ok := v.([]interface{})
+		if !ok {
+			return fmt.Errorf("expected array, got %T", v)
+		}
+		return e.Encode(list)
+	case "java.util.Map", "java.util.HashMap", "java.util.LinkedHashMap":
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("expected object, got %T", v)
+		}
+		return e.Encode(m)
+	default:
+		if elem, ok := strings.CutSuffix(javaType, "[]"); ok {
+			list, ok := v.([]interface{})
+			if !ok {
+				return fmt.Errorf("expected array, got %T", v)
+			}
+			e.beginList("", len(list))
+			for i, item := range list {
+				if err := e.EncodeAs(elem, item); err != nil {
+					return fmt.Errorf("[%d]: %w", i, err)
+				}
+			}
+			return nil
+		}
+		if m, ok := v.(map[string]interface{}); ok {
+			return e.encodeMap(javaType, m, nil)
+		}
+		return e.Encode(v)
+	}
+	return nil
+}
+
+func integerOf(v interface{}) (int64, error) {
+	switch x := v.(type) {
+	case json.Number:
+		n, err := x.Int64()
+		if err != nil {
+			return 0, fmt.Errorf("expected integer, got %s", x)
+		}
+		return n, nil
+	case float64:
+		if x != float64(int64(x)) {
+			return 0, fmt.Errorf("expected integer, got %v", x)
+		}
+		return int64(x), nil
+	case int:
+		return int64(x), nil
+	case int32:
+		return int64(x), nil
+	case int64:
+		return x, nil
+	case string:
+		n, err := strconv.ParseInt(x, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("expected integer, got %q", x)
+		}
+		return n, nil
+	}
+	return 0, fmt.Errorf("expected integer, got %T", v)
+}
+
+func floatOf(v interface{}) (float64, error) {
+	switch x := v.(type) {
+	case json.Number:
+		return x.Float64()
+	case float64:
+		return x, nil
+	case string:
+		return strconv.ParseFloat(x, 64)
+	}
+	if n, err := integerOf(v); err == nil {
+		return float64(n), nil
+	}
+	return 0, fmt.Errorf("expected number, got %T", v)
+}
+
+func timeOf(v interface{}) (time.Time, error) {
+	if s, ok := v.(string); ok {
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("expected RFC 3339 timestamp, got %q", s)
+		}
+		return t, nil
+	}
+	ms, err := integerOf(v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected timestamp or epoch milliseconds, got %T", v)
+	}
+	return time.UnixMilli(ms), nil
+}
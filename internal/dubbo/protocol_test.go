@@ -0,0 +1,294 @@
+package dubbo
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFrame_RoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	in := &Frame{ID: 42, Request: true, TwoWay: true, Body: []byte{'N'}}
+	if err := WriteFrame(&buf, in); err != nil {
+		t.Fatal(err)
+	}
+	hdr := buf.Bytes()[:headerLength]
+	if hdr[0] != 0xda || hdr[1] != 0xbb || hdr[2] != 0xc2 {
+		t.Errorf("unexpected header % x", hdr)
+	}
+
+	out, err := ReadFrame(&buf, DefaultMaxBodySize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("got %+v, want %+v", out, in)
+	}
+}
+
+func TestReadFrame_Errors(t *testing.T) {
+	var buf bytes.Buffer
+	WriteFrame(&buf, &Frame{ID: 1, Body: make([]byte, 100)})
+	if _, err := ReadFrame(bytes.NewReader(buf.Bytes()), 10); err == nil || !strings.Contains(err.Error(), "exceeds limit") {
+		t.Errorf("expected size limit error, got %v", err)
+	}
+
+	bad := append([]byte{}, buf.Bytes()...)
+	bad[0] = 0
+	if _, err := ReadFrame(bytes.NewReader(bad), DefaultMaxBodySize); err == nil || !strings.Contains(err.Error(), "magic") {
+		t.Errorf("expected magic error, got %v", err)
+	}
+
+	bad = append([]byte{}, buf.Bytes()...)
+	bad[2] = 6 // fastjson
+	if _, err := ReadFrame(bytes.NewReader(bad), DefaultMaxBodySize); err == nil || !strings.Contains(err.Error(), "serialization") {
+		t.Errorf("expected serialization error, got %v", err)
+	}
+}
+
+func TestTypeDescriptor(t *testing.T) {
+	tests := map[string]string{
+		"int":                     "I",
+		"long[]":                  "[J",
+		"java.lang.String":        "Ljava/lang/String;",
+		"com.foo.Order[][]":       "[[Lcom/foo/Order;",
+		"com.foo.order.CreateReq": "Lcom/foo/order/CreateReq;",
+	}
+	for in, want := range tests {
+		if got := typeDescriptor(in); got != want {
+			t.Errorf("typeDescriptor(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestInvocation_EncodeRequest(t *testing.T) {
+	inv := &Invocation{
+		Interface:   "com.foo.OrderService",
+		Version:     "1.0.0",
+		Group:       "blue",
+		Method:      "create",
+		ParamTypes:  []string{"com.foo.CreateOrder", "long"},
+		Args:        []interface{}{map[string]interface{}{"sku": "A1"}, json.Number("7")},
+		Attachments: map[string]string{"trace": "t1"},
+		Timeout:     1500 * time.Millisecond,
+	}
+	body, err := inv.encodeRequest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := NewDecoder(body)
+	var got []interface{}
+	for d.Remaining() {
+		v, err := d.Decode()
+		if err != nil {
+			t.Fatalf("Decode: %v", err)
+		}
+		got = append(got, v)
+	}
+	want := []interface{}{
+		DubboVersion, "com.foo.OrderService", "1.0.0", "create", "Lcom/foo/CreateOrder;J",
+		map[string]interface{}{"sku": "A1"},
+		int64(7),
+		map[string]interface{}{
+			"path": "com.foo.OrderService", "interface": "com.foo.OrderService",
+			"version": "1.0.0", "group": "blue", "timeout": "1500", "trace": "t1",
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v\nwant %#v", got, want)
+	}
+	// The argument object is written as a map typed with its class.
+	if !bytes.Contains(body, []byte("M\x13com.foo.CreateOrder")) {
+		t.Error("expected typed map for the request object")
+	}
+
+	inv.Args = inv.Args[:1]
+	if _, err := inv.encodeRequest(); err == nil {
+		t.Error("expected error for argument count mismatch")
+	}
+}
+
+func TestInvocation_Generic(t *testing.T) {
+	inv := (&Invocation{
+		Interface:   "com.foo.OrderService",
+		Method:      "create",
+		ParamTypes:  []string{"com.foo.CreateOrder", "long"},
+		Args:        []interface{}{map[string]interface{}{"sku": "A1"}, json.Number("7")},
+		Attachments: map[string]string{"trace": "t1"},
+	}).Generic()
+	if inv.Method != GenericMethod || inv.Attachments["generic"] != "true" || inv.Attachments["trace"] != "t1" {
+		t.Fatalf("unexpected generic invocation %+v", inv)
+	}
+
+	body, err := inv.encodeRequest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := NewDecoder(body)
+	var got []interface{}
+	for d.Remaining() {
+		v, err := d.Decode()
+		if err != nil {
+			t.Fatalf("Decode: %v", err)
+		}
+		got = append(got, v)
+	}
+	if got[3] != "$invoke" || got[4] != "Ljava/lang/String;[Ljava/lang/String;[Ljava/lang/Object;" {
+		t.Errorf("unexpected method %v and descriptor %v", got[3], got[4])
+	}
+	wantArgs := []interface{}{
+		"create",
+		[]interface{}{"com.foo.CreateOrder", "long"},
+		[]interface{}{map[string]interface{}{"sku": "A1"}, int32(7)},
+	}
+	if !reflect.DeepEqual(got[5:8], wantArgs) {
+		t.Errorf("got args %#v\nwant %#v", got[5:8], wantArgs)
+	}
+	// Generic arguments carry no class names.
+	if bytes.Contains(body, []byte("M\x13com.foo.CreateOrder")) {
+		t.Error("expected untyped maps for generic arguments")
+	}
+}
+
+func TestEncodeAs(t *testing.T) {
+	tests := []struct {
+		javaType string
+		in       interface{}
+		want     interface{}
+	}{
+		{"int", json.Number("5"), int32(5)},
+		{"java.lang.Long", json.Number("5"), int64(5)},
+		{"double", json.Number("3"), float64(3)},
+		{"java.lang.String", "x", "x"},
+		{"boolean", true, true},
+		{"byte[]", "AQI=", []byte{1, 2}},
+		{"java.util.Date", "2024-01-02T03:04:05Z", time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
+		{"int[]", []interface{}{json.Number("1"), json.Number("2")}, []interface{}{int32(1), int32(2)}},
+		{"java.util.List", []interface{}{"a"}, []interface{}{"a"}},
+		{"com.foo.Anything", "plain", "plain"},
+		{"java.lang.Integer", nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.javaType, func(t *testing.T) {
+			var e Encoder
+			if err := e.EncodeAs(tt.javaType, tt.in); err != nil {
+				t.Fatalf("EncodeAs: %v", err)
+			}
+			got, err := NewDecoder(e.Bytes()).Decode()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+
+	errs := []struct {
+		javaType string
+		in       interface{}
+	}{
+		{"int", "abc"},
+		{"int", json.Number("1.5")},
+		{"boolean", "true"},
+		{"java.lang.String", json.Number("1")},
+		{"byte[]", "!!"},
+		{"int[]", map[string]interface{}{}},
+	}
+	for _, tt := range errs {
+		var e Encoder
+		if err := e.EncodeAs(tt.javaType, tt.in); err == nil {
+			t.Errorf("EncodeAs(%s, %#v): expected error", tt.javaType, tt.in)
+		}
+	}
+}
+
+func TestDecodeResponse(t *testing.T) {
+	exception := func() []byte {
+		var e Encoder
+		e.WriteInt(responseWithExceptionWithAttachment)
+		e.buf = append(e.buf, 'C')
+		e.WriteString("java.lang.IllegalStateException")
+		e.WriteInt(1)
+		e.WriteString("detailMessage")
+		e.buf = append(e.buf, 0x60)
+		e.WriteString("out of stock")
+		e.Encode(map[string]string{"dubbo": "2.0.2"})
+		return e.Bytes()
+	}
+
+	t.Run("value", func(t *testing.T) {
+		var e Encoder
+		e.WriteInt(responseValue)
+		e.WriteString("ok")
+		res, err := decodeResponse(&Frame{Status: StatusOK, Body: e.Bytes()})
+		if err != nil || res.Value != "ok" || res.Exception != nil {
+			t.Errorf("got %+v, %v", res, err)
+		}
+	})
+	t.Run("null with attachments", func(t *testing.T) {
+		var e Encoder
+		e.WriteInt(responseNullValueWithAttachments)
+		e.Encode(map[string]string{"k": "v"})
+		res, err := decodeResponse(&Frame{Status: StatusOK, Body: e.Bytes()})
+		if err != nil || res.Value != nil || res.Attachments["k"] != "v" {
+			t.Errorf("got %+v, %v", res, err)
+		}
+	})
+	t.Run("exception", func(t *testing.T) {
+		res, err := decodeResponse(&Frame{Status: StatusOK, Body: exception()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.ExceptionType() != "java.lang.IllegalStateException" {
+			t.Errorf("unexpected exception type %q", res.ExceptionType())
+		}
+		if res.Message() != "java.lang.IllegalStateException: out of stock" {
+			t.Errorf("unexpected message %q", res.Message())
+		}
+	})
+	t.Run("rpc exception", func(t *testing.T) {
+		var e Encoder
+		e.WriteInt(responseWithException)
+		e.buf = append(e.buf, 'C')
+		e.WriteString("org.apache.dubbo.rpc.RpcException")
+		e.WriteInt(2)
+		e.WriteString("detailMessage")
+		e.WriteString("code")
+		e.buf = append(e.buf, 0x60)
+		e.WriteString("timeout")
+		e.WriteInt(RpcTimeout)
+		res, err := decodeResponse(&Frame{Status: StatusOK, Body: e.Bytes()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code, ok := res.RpcExceptionCode(); !ok || code != RpcTimeout {
+			t.Errorf("got code %d, %v", code, ok)
+		}
+	})
+	t.Run("generic exception", func(t *testing.T) {
+		res := &Result{Exception: &Object{
+			Type:   "org.apache.dubbo.rpc.service.GenericException",
+			Fields: map[string]interface{}{"exceptionClass": "com.foo.NotFound", "exceptionMessage": "order 7"},
+		}}
+		if res.ExceptionType() != "com.foo.NotFound" || res.Message() != "com.foo.NotFound: order 7" {
+			t.Errorf("unexpected type %q, message %q", res.ExceptionType(), res.Message())
+		}
+		if _, ok := res.RpcExceptionCode(); ok {
+			t.Error("GenericException is not an RpcException")
+		}
+	})
+	t.Run("status", func(t *testing.T) {
+		var e Encoder
+		e.WriteString("service not found")
+		_, err := decodeResponse(&Frame{Status: StatusServiceNotFound, Body: e.Bytes()})
+		se, ok := err.(*StatusError)
+		if !ok || se.Status != StatusServiceNotFound || se.Message != "service not found" {
+			t.Errorf("got %v", err)
+		}
+	})
+}
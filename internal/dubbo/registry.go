@@ -0,0 +1,188 @@
+package dubbo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ServiceInfo describes a Dubbo service registered by one or more providers.
+type ServiceInfo struct {
+	Interface string   `json:"interface"`
+	Group     string   `json:"group,omitempty"`
+	Version   string   `json:"version,omitempty"`
+	Methods   []string `json:"methods"`
+	// Providers holds the provider addresses as "protocol://host:port".
+	Providers []string `json:"providers"`
+}
+
+// Registry lists the services registered by Dubbo providers.
+type Registry interface {
+	Services(ctx context.Context) ([]ServiceInfo, error)
+}
+
+// nacosPageSize is the number of service names requested per page.
+const nacosPageSize = 100
+
+// NacosRegistry reads interface-level provider registrations from a Nacos
+// naming server through its HTTP open API. Dubbo registers each interface
+// as a service named "providers:<interface>:<version>:<group>"; other
+// services, such as application-level registrations, are ignored.
+type NacosRegistry struct {
+	Client *http.Client
+	// BaseURL is the Nacos server address, e.g. "http://nacos:8848".
+	BaseURL   string
+	Namespace string
+	// Group is the Nacos group the providers register in (default
+	// "DEFAULT_GROUP").
+	Group string
+}
+
+// Services lists the registered services with their healthy providers,
+// sorted by interface, group and version.
+func (n *NacosRegistry) Services(ctx context.Context) ([]ServiceInfo, error) {
+	names, err := n.serviceNames(ctx)
+	if err != nil {
+		return nil, err
+	}
+	services := make([]ServiceInfo, 0, len(names))
+	for _, name := range names {
+		iface, version, group, ok := parseProviderService(name)
+		if !ok {
+			continue
+		}
+		hosts, err := n.instances(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		svc := ServiceInfo{Interface: iface, Group: group, Version: version, Methods: []string{}, Providers: []string{}}
+		methods := make(map[string]struct{})
+		for _, h := range hosts {
+			if !h.Healthy || !h.Enabled {
+				continue
+			}
+			protocol := h.Metadata["protocol"]
+			if protocol == "" {
+				protocol = "dubbo"
+			}
+			svc.Providers = append(svc.Providers, protocol+"://"+h.IP+":"+strconv.Itoa(h.Port))
+			for _, m := range strings.Split(h.Metadata["methods"], ",") {
+				if m = strings.TrimSpace(m); m != "" {
+					methods[m] = struct{}{}
+				}
+			}
+		}
+		svc.Methods = sortedKeys(methods)
+		sort.Strings(svc.Providers)
+		services = append(services, svc)
+	}
+	sort.Slice(services, func(i, j int) bool {
+		a, b := services[i], services[j]
+		if a.Interface != b.Interface {
+			return a.Interface < b.Interface
+		}
+		if a.Group != b.Group {
+			return a.Group < b.Group
+		}
+		return a.Version < b.Version
+	})
+	return services, nil
+}
+
+// parseProviderService splits a Dubbo provider service name.
+func parseProviderService(name string) (iface, version, group string, ok bool) {
+	parts := strings.Split(name, ":")
+	if len(parts) < 2 || parts[0] != "providers" || parts[1] == "" {
+		return "", "", "", false
+	}
+	iface = parts[1]
+	if len(parts) > 2 {
+		version = parts[2]
+	}
+	if len(parts) > 3 {
+		group = parts[3]
+	}
+	return iface, version, group, true
+}
+
+type nacosInstance struct {
+	IP       string            `json:"ip"`
+	Port     int               `json:"port"`
+	Healthy  bool              `json:"healthy"`
+	Enabled  bool              `json:"enabled"`
+	Metadata map[string]string `json:"metadata"`
+}
+
+// serviceNames pages through the service list of the namespace and group.
+func (n *NacosRegistry) serviceNames(ctx context.Context) ([]string, error) {
+	var names []string
+	for page := 1; ; page++ {
+		q := n.query()
+		q.Set("pageNo", strconv.Itoa(page))
+		q.Set("pageSize", strconv.Itoa(nacosPageSize))
+		var resp struct {
+			Count int      `json:"count"`
+			Doms  []string `json:"doms"`
+		}
+		if err := n.get(ctx, "/nacos/v1/ns/service/list", q, &resp); err != nil {
+			return nil, err
+		}
+		names = append(names, resp.Doms...)
+		if len(resp.Doms) == 0 || len(names) >= resp.Count {
+			return names, nil
+		}
+	}
+}
+
+func (n *NacosRegistry) instances(ctx context.Context, service string) ([]nacosInstance, error) {
+	q := n.query()
+	q.Set("serviceName", service)
+	var resp struct {
+		Hosts []nacosInstance `json:"hosts"`
+	}
+	if err := n.get(ctx, "/nacos/v1/ns/instance/list", q, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Hosts, nil
+}
+
+func (n *NacosRegistry) query() url.Values {
+	q := url.Values{}
+	if n.Namespace != "" {
+		q.Set("namespaceId", n.Namespace)
+	}
+	if n.Group != "" {
+		q.Set("groupName", n.Group)
+	}
+	return q
+}
+
+func (n *NacosRegistry) get(ctx context.Context, path string, q url.Values, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(n.BaseURL, "/")+path+"?"+q.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	client := n.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("nacos: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("nacos: %s: %s %s", path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("nacos: %s: invalid response: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package dubbo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// newNacosServer fakes the Nacos naming API with the given instances per
+// service name. Service lists are served two names per page.
+func newNacosServer(t *testing.T, services map[string][]nacosInstance, order []string) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("namespaceId") != "prod" || q.Get("groupName") != "dubbo" {
+			t.Errorf("unexpected namespace/group in %s", r.URL)
+		}
+		switch r.URL.Path {
+		case "/nacos/v1/ns/service/list":
+			page, _ := strconv.Atoi(q.Get("pageNo"))
+			start := (page - 1) * 2
+			end := min(start+2, len(order))
+			json.NewEncoder(w).Encode(map[string]interface{}{"count": len(order), "doms": order[start:end]})
+		case "/nacos/v1/ns/instance/list":
+			hosts, ok := services[q.Get("serviceName")]
+			if !ok {
+				http.Error(w, "service not found", http.StatusNotFound)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"hosts": hosts})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func TestNacosRegistry_Services(t *testing.T) {
+	provider := func(ip string, healthy bool, protocol, methods string) nacosInstance {
+		return nacosInstance{IP: ip, Port: 20880, Healthy: healthy, Enabled: true,
+			Metadata: map[string]string{"protocol": protocol, "methods": methods}}
+	}
+	services := map[string][]nacosInstance{
+		"providers:com.foo.OrderService:1.0.0:blue": {
+			provider("10.0.0.2", true, "dubbo", "CreateOrder,GetOrder"),
+			provider("10.0.0.1", true, "dubbo", "GetOrder,CancelOrder"),
+			provider("10.0.0.3", false, "dubbo", "Unhealthy"),
+		},
+		"providers:com.foo.UserService::": {provider("10.0.1.1", true, "tri", "GetUser")},
+		"order-app":                       {provider("10.0.0.1", true, "", "")},
+	}
+	base := newNacosServer(t, services, []string{"providers:com.foo.UserService::", "order-app", "providers:com.foo.OrderService:1.0.0:blue"})
+
+	reg := &NacosRegistry{BaseURL: base, Namespace: "prod", Group: "dubbo"}
+	got, err := reg.Services(context.Background())
+	if err != nil {
+		t.Fatalf("Services: %v", err)
+	}
+	want := []ServiceInfo{
+		{
+			Interface: "com.foo.OrderService", Group: "blue", Version: "1.0.0",
+			Methods:   []string{"CancelOrder", "CreateOrder", "GetOrder"},
+			Providers: []string{"dubbo://10.0.0.1:20880", "dubbo://10.0.0.2:20880"},
+		},
+		{
+			Interface: "com.foo.UserService",
+			Methods:   []string{"GetUser"},
+			Providers: []string{"tri://10.0.1.1:20880"},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v\nwant %+v", got, want)
+	}
+}
+
+func TestNacosRegistry_Error(t *testing.T) {
+	base := newNacosServer(t, nil, []string{"providers:com.foo.Gone:1.0.0:"})
+	reg := &NacosRegistry{BaseURL: base, Namespace: "prod", Group: "dubbo"}
+	_, err := reg.Services(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("expected error for failed instance lookup, got %v", err)
+	}
+}
+
+func TestParseProviderService(t *testing.T) {
+	tests := []struct {
+		name                  string
+		iface, version, group string
+		ok                    bool
+	}{
+		{"providers:com.foo.A:1.0.0:blue", "com.foo.A", "1.0.0", "blue", true},
+		{"providers:com.foo.A", "com.foo.A", "", "", true},
+		{"consumers:com.foo.A::", "", "", "", false},
+		{"order-app", "", "", "", false},
+	}
+	for _, tt := range tests {
+		iface, version, group, ok := parseProviderService(tt.name)
+		if fmt.Sprint(iface, version, group, ok) != fmt.Sprint(tt.iface, tt.version, tt.group, tt.ok) {
+			t.Errorf("parseProviderService(%q) = %q %q %q %v", tt.name, iface, version, group, ok)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package dubbo
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/oriys/nexus/internal/transcode"
+)
+
+// Triple is Dubbo 3's gRPC-compatible protocol over HTTP/2. Methods defined
+// with protobuf IDL exchange plain protobuf messages. Other methods are
+// invoked in "wrapper" mode: each Hessian 2 encoded argument is carried in a
+// TripleRequestWrapper message and the result in a TripleResponseWrapper,
+// both sent as application/grpc+proto.
+const tripleContentType = "application/grpc+proto"
+
+// Triple request headers carrying the service coordinates.
+const (
+	tripleHeaderVersion = "Tri-Service-Version"
+	tripleHeaderGroup   = "Tri-Service-Group"
+	tripleHeaderApp     = "Tri-Consumer-Appname"
+)
+
+// TripleError is returned when a triple call ends with a non-OK grpc-status.
+type TripleError struct {
+	Code    int
+	Message string
+}
+
+func (e *TripleError) Error() string {
+	return fmt.Sprintf("triple: status %d: %s", e.Code, e.Message)
+}
+
+// TripleClient calls a triple provider. Transport must speak HTTP/2, with
+// prior knowledge for plain-text "http" base URLs.
+type TripleClient struct {
+	Transport http.RoundTripper
+	BaseURL   *url.URL
+}
+
+// Invoke calls inv in wrapper mode with Hessian 2 serialized arguments.
+func (c *TripleClient) Invoke(ctx context.Context, inv *Invocation) (*Result, error) {
+	if len(inv.Args) != len(inv.ParamTypes) {
+		return nil, fmt.Errorf("%d arguments given for %d parameter types", len(inv.Args), len(inv.ParamTypes))
+	}
+	var msg []byte
+	msg = protowire.AppendTag(msg, 1, protowire.BytesType)
+	msg = protowire.AppendString(msg, "hessian2")
+	for i, arg := range inv.Args {
+		var e Encoder
+		if err := e.EncodeAs(inv.ParamTypes[i], arg); err != nil {
+			return nil, fmt.Errorf("argument %d (%s): %w", i, inv.ParamTypes[i], err)
+		}
+		msg = protowire.AppendTag(msg, 2, protowire.BytesType)
+		msg = protowire.AppendBytes(msg, e.Bytes())
+	}
+	for _, t := range inv.ParamTypes {
+		msg = protowire.AppendTag(msg, 3, protowire.BytesType)
+		msg = protowire.AppendString(msg, t)
+	}
+
+	resp, att, err := c.InvokeRaw(ctx, inv, msg)
+	if err != nil {
+		return nil, err
+	}
+	data, err := unwrapTripleResponse(resp)
+	if err != nil {
+		return nil, err
+	}
+	res := &Result{Attachments: att, RequestBody: msg, ResponseBody: resp}
+	if len(data) > 0 {
+		if res.Value, err = NewDecoder(data).Decode(); err != nil {
+			return nil, fmt.Errorf("triple: decode response: %w", err)
+		}
+	}
+	return res, nil
+}
+
+// unwrapTripleResponse extracts the serialized result from a
+// TripleResponseWrapper.
+func unwrapTripleResponse(msg []byte) ([]byte, error) {
+	var data []byte
+	for len(msg) > 0 {
+		num, typ, n := protowire.ConsumeTag(msg)
+		if n < 0 {
+			return nil, fmt.Errorf("triple: invalid response wrapper: %w", protowire.ParseError(n))
+		}
+		msg = msg[n:]
+		if num == 1 && typ == protowire.BytesType {
+			s, n := protowire.ConsumeString(msg)
+			if n < 0 {
+				return nil, fmt.Errorf("triple: invalid response wrapper: %w", protowire.ParseError(n))
+			}
+			if s != "" && s != "hessian2" {
+				return nil, fmt.Errorf("triple: unsupported response serialization %q", s)
+			}
+			msg = msg[n:]
+			continue
+		}
+		if num == 2 && typ == protowire.BytesType {
+			b, n := protowire.ConsumeBytes(msg)
+			if n < 0 {
+				return nil, fmt.Errorf("triple: invalid response wrapper: %w", protowire.ParseError(n))
+			}
+			data = b
+			msg = msg[n:]
+			continue
+		}
+		n = protowire.ConsumeFieldValue(num, typ, msg)
+		if n < 0 {
+			return nil, fmt.Errorf("triple: invalid response wrapper: %w", protowire.ParseError(n))
+		}
+		msg = msg[n:]
+	}
+	return data, nil
+}
+
+// InvokeRaw sends msg, an already serialized protobuf request, to
+// inv.Interface/inv.Method and returns the serialized response message along
+// with the response attachments. inv.Args and inv.ParamTypes are ignored.
+func (c *TripleClient) InvokeRaw(ctx context.Context, inv *Invocation, msg []byte) ([]byte, map[string]interface{}, error) {
+	u := *c.BaseURL
+	u.Path = "/" + inv.Interface + "/" + inv.Method
+	u.RawPath = ""
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), bytes.NewReader(transcode.Frame(msg)))
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Content-Type", tripleContentType)
+	req.Header.Set("Te", "trailers")
+	for k, v := range inv.Attachments {
+		req.Header.Set(k, v)
+	}
+	if inv.Version != "" {
+		req.Header.Set(tripleHeaderVersion, inv.Version)
+	}
+	if inv.Group != "" {
+		req.Header.Set(tripleHeaderGroup, inv.Group)
+	}
+	if inv.Application != "" {
+		req.Header.Set(tripleHeaderApp, inv.Application)
+	}
+	if dl, ok := ctx.Deadline(); ok {
+		// grpc-timeout allows at most eight digits.
+		if ms := (time.Until(dl) + time.Millisecond - 1) / time.Millisecond; ms > 0 && ms <= 99999999 {
+			req.Header.Set("Grpc-Timeout", strconv.FormatInt(int64(ms), 10)+"m")
+		}
+	}
+
+	resp, err := c.Transport.RoundTrip(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("triple: provider returned HTTP %d", resp.StatusCode)
+	}
+
+	// Trailers-only responses carry the status in the headers.
+	if err := tripleStatus(resp.Header); err != nil {
+		return nil, nil, err
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "application/grpc") {
+		return nil, nil, fmt.Errorf("triple: unexpected response content-type %q", ct)
+	}
+	out, err := transcode.ReadFrame(resp.Body)
+	if err != nil && err != io.EOF {
+		return nil, nil, fmt.Errorf("triple: read response: %w", err)
+	}
+	// Drain the body so the trailers become available.
+	if _, err := io.Copy(io.Discard, resp.Body); err != nil {
+		return nil, nil, fmt.Errorf("triple: read response: %w", err)
+	}
+	if err := tripleStatus(resp.Trailer); err != nil {
+		return nil, nil, err
+	}
+	if resp.Trailer.Get("Grpc-Status") == "" && resp.Header.Get("Grpc-Status") == "" {
+		return nil, nil, fmt.Errorf("triple: response ended without grpc-status")
+	}
+
+	att := tripleAttachments(resp.Header)
+	for k, v := range tripleAttachments(resp.Trailer) {
+		att[k] = v
+	}
+	return out, att, nil
+}
+
+// tripleStatus returns the error described by a grpc-status header, if any.
+func tripleStatus(h http.Header) error {
+	s := h.Get("Grpc-Status")
+	if s == "" || s == "0" {
+		return nil
+	}
+	code, err := strconv.Atoi(s)
+	if err != nil {
+		return fmt.Errorf("triple: invalid grpc-status %q", s)
+	}
+	msg, err := url.PathUnescape(h.Get("Grpc-Message"))
+	if err != nil {
+		msg = h.Get("Grpc-Message")
+	}
+	return &TripleError{Code: code, Message: msg}
+}
+
+// tripleAttachments collects the response metadata that is not part of the
+// protocol itself.
+func tripleAttachments(h http.Header) map[string]interface{} {
+	att := make(map[string]interface{})
+	for k, vs := range h {
+		lower := strings.ToLower(k)
+		if lower == "content-type" || lower == "trailer" || lower == "date" ||
+			strings.HasPrefix(lower, "grpc-") || strings.HasPrefix(lower, "tri-") || len(vs) == 0 {
+			continue
+		}
+		att[lower] = vs[0]
+	}
+	return att
+}
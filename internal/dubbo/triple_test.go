@@ -0,0 +1,168 @@
+package dubbo
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/oriys/nexus/internal/transcode"
+)
+
+// newTripleServer starts a plain-text HTTP/2 server and returns a client for it.
+func newTripleServer(t *testing.T, h http.HandlerFunc) *TripleClient {
+	t.Helper()
+	srv := httptest.NewUnstartedServer(h)
+	srv.Config.Protocols = new(http.Protocols)
+	srv.Config.Protocols.SetUnencryptedHTTP2(true)
+	srv.Start()
+	t.Cleanup(srv.Close)
+
+	var protocols http.Protocols
+	protocols.SetUnencryptedHTTP2(true)
+	base, _ := url.Parse(srv.URL)
+	return &TripleClient{Transport: &http.Transport{Protocols: &protocols}, BaseURL: base}
+}
+
+// parseRequestWrapper decodes a TripleRequestWrapper into its hessian
+// arguments and argument types.
+func parseRequestWrapper(t *testing.T, msg []byte) (serialization string, args [][]byte, types []string) {
+	t.Helper()
+	for len(msg) > 0 {
+		num, _, n := protowire.ConsumeTag(msg)
+		msg = msg[n:]
+		b, n := protowire.ConsumeBytes(msg)
+		if n < 0 {
+			t.Fatalf("invalid wrapper field %d", num)
+		}
+		msg = msg[n:]
+		switch num {
+		case 1:
+			serialization = string(b)
+		case 2:
+			args = append(args, b)
+		case 3:
+			types = append(types, string(b))
+		}
+	}
+	return serialization, args, types
+}
+
+func responseWrapper(data []byte) []byte {
+	var msg []byte
+	msg = protowire.AppendTag(msg, 1, protowire.BytesType)
+	msg = protowire.AppendString(msg, "hessian2")
+	msg = protowire.AppendTag(msg, 2, protowire.BytesType)
+	msg = protowire.AppendBytes(msg, data)
+	msg = protowire.AppendTag(msg, 3, protowire.BytesType)
+	return protowire.AppendString(msg, "java.lang.String")
+}
+
+func TestTripleClient_Invoke(t *testing.T) {
+	client := newTripleServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/com.foo.Greeter/greet" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if r.Header.Get("Content-Type") != "application/grpc+proto" || r.Header.Get("Te") != "trailers" {
+			t.Errorf("unexpected protocol headers %v", r.Header)
+		}
+		if r.Header.Get("Tri-Service-Version") != "1.0.0" || r.Header.Get("Tri-Service-Group") != "blue" ||
+			r.Header.Get("Tri-Consumer-Appname") != "gw" || r.Header.Get("X-Tenant") != "acme" {
+			t.Errorf("unexpected service headers %v", r.Header)
+		}
+		if r.Header.Get("Grpc-Timeout") == "" {
+			t.Error("expected grpc-timeout from the context deadline")
+		}
+		msg, err := transcode.ReadFrame(r.Body)
+		if err != nil {
+			t.Fatalf("read frame: %v", err)
+		}
+		ser, args, types := parseRequestWrapper(t, msg)
+		if ser != "hessian2" || len(args) != 2 || len(types) != 2 || types[1] != "int" {
+			t.Errorf("unexpected wrapper %q %d %v", ser, len(args), types)
+		}
+		name, _ := NewDecoder(args[0]).Decode()
+		times, _ := NewDecoder(args[1]).Decode()
+
+		var e Encoder
+		e.WriteString("hello " + name.(string))
+		w.Header().Set("Content-Type", "application/grpc+proto")
+		w.Header().Set("Trailer", "Grpc-Status, Grpc-Message, X-Served-By")
+		w.Header().Set("X-Times", "2")
+		w.Write(transcode.Frame(responseWrapper(e.Bytes())))
+		w.Header().Set("Grpc-Status", "0")
+		w.Header().Set("X-Served-By", "provider-1")
+		if times != int32(2) {
+			t.Errorf("unexpected second argument %#v", times)
+		}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	res, err := client.Invoke(ctx, &Invocation{
+		Interface:   "com.foo.Greeter",
+		Method:      "greet",
+		Version:     "1.0.0",
+		Group:       "blue",
+		Application: "gw",
+		ParamTypes:  []string{"java.lang.String", "int"},
+		Args:        []interface{}{"bob", 2},
+		Attachments: map[string]string{"x-tenant": "acme"},
+	})
+	if err != nil {
+		t.Fatalf("Invoke: %v", err)
+	}
+	if res.Value != "hello bob" {
+		t.Errorf("unexpected value %#v", res.Value)
+	}
+	if _, args, _ := parseRequestWrapper(t, res.RequestBody); len(args) != 2 || len(res.ResponseBody) == 0 {
+		t.Error("expected the request and response wrappers on the result")
+	}
+	if res.Attachments["x-served-by"] != "provider-1" || res.Attachments["x-times"] != "2" {
+		t.Errorf("unexpected attachments %v", res.Attachments)
+	}
+}
+
+func TestTripleClient_Status(t *testing.T) {
+	client := newTripleServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/grpc+proto")
+		switch r.URL.Path {
+		case "/svc/trailersOnly":
+			w.Header().Set("Grpc-Status", "5")
+			w.Header().Set("Grpc-Message", "no%20such%20order")
+			w.WriteHeader(http.StatusOK)
+		case "/svc/trailer":
+			w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+			w.WriteHeader(http.StatusOK)
+			w.Header().Set("Grpc-Status", "14")
+			w.Header().Set("Grpc-Message", "draining")
+		default:
+			// No status at all.
+			w.WriteHeader(http.StatusOK)
+		}
+	})
+
+	tests := []struct {
+		method string
+		code   int
+		msg    string
+	}{
+		{"trailersOnly", 5, "no such order"},
+		{"trailer", 14, "draining"},
+	}
+	for _, tt := range tests {
+		_, _, err := client.InvokeRaw(context.Background(), &Invocation{Interface: "svc", Method: tt.method}, nil)
+		var te *TripleError
+		if !errors.As(err, &te) || te.Code != tt.code || te.Message != tt.msg {
+			t.Errorf("%s: got %v", tt.method, err)
+		}
+	}
+	if _, _, err := client.InvokeRaw(context.Background(), &Invocation{Interface: "svc", Method: "none"}, nil); err == nil {
+		t.Error("expected error for response without grpc-status")
+	}
+}
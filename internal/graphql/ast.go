@@ -0,0 +1,256 @@
+package graphql
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Document is a parsed executable GraphQL document.
+type Document struct {
+	Operations []*Operation
+	Fragments  []*Fragment
+}
+
+// Operation types.
+const (
+	Query        = "query"
+	Mutation     = "mutation"
+	Subscription = "subscription"
+)
+
+// Operation is an operation definition. Shorthand queries ("{ ... }") have
+// type Query and no name.
+type Operation struct {
+	Type         string
+	Name         string
+	Variables    []*VariableDefinition
+	Directives   []*Directive
+	SelectionSet []Selection
+}
+
+// VariableDefinition declares an operation variable.
+type VariableDefinition struct {
+	Name       string
+	Type       *Type
+	Default    *Value
+	Directives []*Directive
+}
+
+// Type is a variable type reference: a named type, a list of Elem, or either
+// made non-null.
+type Type struct {
+	Name    string // empty for lists
+	Elem    *Type  // list element type
+	NonNull bool
+}
+
+func (t *Type) String() string {
+	s := t.Name
+	if t.Elem != nil {
+		s = "[" + t.Elem.String() + "]"
+	}
+	if t.NonNull {
+		s += "!"
+	}
+	return s
+}
+
+// Fragment is a named fragment definition.
+type Fragment struct {
+	Name          string
+	TypeCondition string
+	Directives    []*Directive
+	SelectionSet  []Selection
+}
+
+// Selection is a *Field, *FragmentSpread or *InlineFragment.
+type Selection interface {
+	selection()
+}
+
+// Field selects a field, optionally under an alias.
+type Field struct {
+	Alias        string
+	Name         string
+	Arguments    []*Argument
+	Directives   []*Directive
+	SelectionSet []Selection
+}
+
+// ResponseKey is the key of the field in the response: its alias or name.
+func (f *Field) ResponseKey() string {
+	if f.Alias != "" {
+		return f.Alias
+	}
+	return f.Name
+}
+
+// FragmentSpread includes a named fragment.
+type FragmentSpread struct {
+	Name       string
+	Directives []*Directive
+}
+
+// InlineFragment includes selections, optionally for a type condition.
+type InlineFragment struct {
+	TypeCondition string
+	Directives    []*Directive
+	SelectionSet  []Selection
+}
+
+func (*Field) selection()          {}
+func (*FragmentSpread) selection() {}
+func (*InlineFragment) selection() {}
+
+// Argument is a named argument of a field or directive.
+type Argument struct {
+	Name  string
+	Value *Value
+}
+
+// Directive is a directive applied to a definition or selection.
+type Directive struct {
+	Name      string
+	Arguments []*Argument
+}
+
+// ValueKind identifies the kind of a Value.
+type ValueKind int
+
+// Value kinds.
+const (
+	VariableValue ValueKind = iota
+	IntValue
+	FloatValue
+	StringValue
+	BooleanValue
+	NullValue
+	EnumValue
+	ListValue
+	ObjectValue
+)
+
+// Value is an input value literal. Raw holds the variable name, the number or
+// enum as written, the decoded string, or "true"/"false"/"null".
+type Value struct {
+	Kind   ValueKind
+	Raw    string
+	List   []*Value
+	Fields []*ObjectField
+}
+
+// ObjectField is a field of an input object literal.
+type ObjectField struct {
+	Name  string
+	Value *Value
+}
+
+// Operation returns the operation to execute: the one named name, or the
+// only operation when name is empty.
+func (d *Document) Operation(name string) (*Operation, error) {
+	if name == "" {
+		if len(d.Operations) != 1 {
+			return nil, fmt.Errorf("operationName is required when the document has %d operations", len(d.Operations))
+		}
+		return d.Operations[0], nil
+	}
+	for _, op := range d.Operations {
+		if op.Name == name {
+			return op, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown operation %q", name)
+}
+
+// Fragment returns the fragment named name, or nil.
+func (d *Document) Fragment(name string) *Fragment {
+	for _, f := range d.Fragments {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// WalkFields calls fn for every field op selects, including those selected
+// through fragments, with the path of response keys from the operation root.
+// Each fragment is expanded at most once per path to guard against cycles.
+func (d *Document) WalkFields(op *Operation, fn func(path []string, f *Field)) {
+	d.walk(op.SelectionSet, nil, map[string]bool{}, fn)
+}
+
+func (d *Document) walk(set []Selection, path []string, active map[string]bool, fn func([]string, *Field)) {
+	for _, sel := range set {
+		switch s := sel.(type) {
+		case *Field:
+			p := append(path[:len(path):len(path)], s.ResponseKey())
+			fn(p, s)
+			d.walk(s.SelectionSet, p, active, fn)
+		case *InlineFragment:
+			d.walk(s.SelectionSet, path, active, fn)
+		case *FragmentSpread:
+			frag := d.Fragment(s.Name)
+			if frag == nil || active[s.Name] {
+				continue
+			}
+			active[s.Name] = true
+			d.walk(frag.SelectionSet, path, active, fn)
+			delete(active, s.Name)
+		}
+	}
+}
+
+// Introspects reports whether op queries the schema through __schema or
+// __type. Selecting __typename is not introspection.
+func (d *Document) Introspects(op *Operation) bool {
+	found := false
+	d.WalkFields(op, func(_ []string, f *Field) {
+		if f.Name == "__schema" || f.Name == "__type" {
+			found = true
+		}
+	})
+	return found
+}
+
+// FieldUsage returns the distinct fields op selects as dot-separated paths of
+// field names from the operation root, such as "user.email". Aliases are
+// resolved to field names; meta fields such as __typename are left out.
+func (d *Document) FieldUsage(op *Operation) []string {
+	u := &fieldUsage{doc: d, seen: map[string]bool{}, active: map[string]bool{}}
+	u.walk(op.SelectionSet, "")
+	return u.paths
+}
+
+type fieldUsage struct {
+	doc    *Document
+	seen   map[string]bool
+	active map[string]bool
+	paths  []string
+}
+
+func (u *fieldUsage) walk(set []Selection, prefix string) {
+	for _, sel := range set {
+		switch s := sel.(type) {
+		case *Field:
+			if strings.HasPrefix(s.Name, "__") {
+				continue
+			}
+			p := prefix + s.Name
+			if !u.seen[p] {
+				u.seen[p] = true
+				u.paths = append(u.paths, p)
+			}
+			u.walk(s.SelectionSet, p+".")
+		case *InlineFragment:
+			u.walk(s.SelectionSet, prefix)
+		case *FragmentSpread:
+			frag := u.doc.Fragment(s.Name)
+			if frag == nil || u.active[s.Name] {
+				continue
+			}
+			u.active[s.Name] = true
+			u.walk(frag.SelectionSet, prefix)
+			delete(u.active, s.Name)
+		}
+	}
+}
@@ -0,0 +1,347 @@
+// Package graphql parses GraphQL executable documents and HTTP requests far
+// enough for the gateway to inspect operations: their type and name, the
+// fields they select, and the fragments they use. It does not validate
+// documents against a schema.
+package graphql
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// tokenKind identifies a lexical token.
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenPunct
+	tokenName
+	tokenInt
+	tokenFloat
+	tokenString
+	tokenBlockString
+)
+
+func (k tokenKind) String() string {
+	switch k {
+	case tokenEOF:
+		return "end of document"
+	case tokenPunct:
+		return "punctuator"
+	case tokenName:
+		return "name"
+	case tokenInt:
+		return "integer"
+	case tokenFloat:
+		return "float"
+	case tokenString, tokenBlockString:
+		return "string"
+	}
+	return "token"
+}
+
+// token is a lexical token. For strings, value holds the decoded text.
+type token struct {
+	kind  tokenKind
+	value string
+	pos   int // byte offset in the source
+}
+
+func (t token) String() string {
+	if t.kind == tokenEOF {
+		return t.kind.String()
+	}
+	return fmt.Sprintf("%s %q", t.kind, t.value)
+}
+
+// SyntaxError reports a malformed document.
+type SyntaxError struct {
+	Message string
+	Line    int
+	Column  int
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("syntax error at %d:%d: %s", e.Line, e.Column, e.Message)
+}
+
+// lexer splits a GraphQL source into tokens, skipping whitespace, commas and
+// comments.
+type lexer struct {
+	src string
+	pos int
+}
+
+func (l *lexer) errorf(pos int, format string, args ...interface{}) *SyntaxError {
+	line, col := 1, 1
+	for _, r := range l.src[:min(pos, len(l.src))] {
+		if r == '\n' {
+			line++
+			col = 1
+		} else {
+			col++
+		}
+	}
+	return &SyntaxError{Message: fmt.Sprintf(format, args...), Line: line, Column: col}
+}
+
+// next returns the next token.
+func (l *lexer) next() (token, error) {
+	l.skipIgnored()
+	if l.pos >= len(l.src) {
+		return token{kind: tokenEOF, pos: l.pos}, nil
+	}
+	start := l.pos
+	c := l.src[l.pos]
+	switch {
+	case strings.IndexByte("!$&()=:@[]{}|", c) >= 0:
+		l.pos++
+		return token{kind: tokenPunct, value: string(c), pos: start}, nil
+	case c == '.':
+		if strings.HasPrefix(l.src[l.pos:], "...") {
+			l.pos += 3
+			return token{kind: tokenPunct, value: "...", pos: start}, nil
+		}
+		return token{}, l.errorf(start, "unexpected %q", ".")
+	case c == '_' || isLetter(c):
+		for l.pos < len(l.src) && isNameChar(l.src[l.pos]) {
+			l.pos++
+		}
+		return token{kind: tokenName, value: l.src[start:l.pos], pos: start}, nil
+	case c == '-' || isDigit(c):
+		return l.number()
+	case c == '"':
+		if strings.HasPrefix(l.src[l.pos:], `"""`) {
+			return l.blockString()
+		}
+		return l.string()
+	}
+	r, _ := utf8.DecodeRuneInString(l.src[l.pos:])
+	return token{}, l.errorf(start, "unexpected character %q", r)
+}
+
+func (l *lexer) skipIgnored() {
+	for l.pos < len(l.src) {
+		switch c := l.src[l.pos]; c {
+		case ' ', '\t', '\n', '\r', ',':
+			l.pos++
+		case '#':
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' && l.src[l.pos] != '\r' {
+				l.pos++
+			}
+		default:
+			if strings.HasPrefix(l.src[l.pos:], "\uFEFF") {
+				l.pos += len("\uFEFF")
+				continue
+			}
+			return
+		}
+	}
+}
+
+func (l *lexer) number() (token, error) {
+	start := l.pos
+	kind := tokenInt
+	if l.src[l.pos] == '-' {
+		l.pos++
+	}
+	if err := l.digits(start); err != nil {
+		return token{}, err
+	}
+	if l.pos < len(l.src) && l.src[l.pos] == '.' {
+		kind = tokenFloat
+		l.pos++
+		if err := l.digits(start); err != nil {
+			return token{}, err
+		}
+	}
+	if l.pos < len(l.src) && (l.src[l.pos] == 'e' || l.src[l.pos] == 'E') {
+		kind = tokenFloat
+		l.pos++
+		if l.pos < len(l.src) && (l.src[l.pos] == '+' || l.src[l.pos] == '-') {
+			l.pos++
+		}
+		if err := l.digits(start); err != nil {
+			return token{}, err
+		}
+	}
+	if l.pos < len(l.src) && (isNameChar(l.src[l.pos]) || l.src[l.pos] == '.') {
+		return token{}, l.errorf(l.pos, "invalid number %q", l.src[start:l.pos+1])
+	}
+	return token{kind: kind, value: l.src[start:l.pos], pos: start}, nil
+}
+
+func (l *lexer) digits(start int) error {
+	d := l.pos
+	for l.pos < len(l.src) && isDigit(l.src[l.pos]) {
+		l.pos++
+	}
+	if l.pos == d {
+		return l.errorf(l.pos, "invalid number %q", l.src[start:min(l.pos+1, len(l.src))])
+	}
+	return nil
+}
+
+func (l *lexer) string() (token, error) {
+	start := l.pos
+	l.pos++ // opening quote
+	var b strings.Builder
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case c == '"':
+			l.pos++
+			return token{kind: tokenString, value: b.String(), pos: start}, nil
+		case c == '\n' || c == '\r':
+			return token{}, l.errorf(l.pos, "unterminated string")
+		case c == '\\':
+			if l.pos+1 >= len(l.src) {
+				return token{}, l.errorf(l.pos, "unterminated string")
+			}
+			esc := l.src[l.pos+1]
+			l.pos += 2
+			switch esc {
+			case '"', '\\', '/':
+				b.WriteByte(esc)
+			case 'b':
+				b.WriteByte('\b')
+			case 'f':
+				b.WriteByte('\f')
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case 'u':
+				r, err := l.unicodeEscape()
+				if err != nil {
+					return token{}, err
+				}
+				b.WriteRune(r)
+			default:
+				return token{}, l.errorf(l.pos-2, "invalid escape sequence \\%c", esc)
+			}
+		default:
+			b.WriteByte(c)
+			l.pos++
+		}
+	}
+	return token{}, l.errorf(start, "unterminated string")
+}
+
+// unicodeEscape decodes the hex digits of a \u escape, either four digits or
+// a braced code point, combining surrogate pairs.
+func (l *lexer) unicodeEscape() (rune, error) {
+	start := l.pos - 2
+	if l.pos < len(l.src) && l.src[l.pos] == '{' {
+		end := strings.IndexByte(l.src[l.pos:], '}')
+		if end < 0 {
+			return 0, l.errorf(start, "invalid unicode escape")
+		}
+		r, ok := parseHex(l.src[l.pos+1 : l.pos+end])
+		l.pos += end + 1
+		if !ok || !utf8.ValidRune(r) {
+			return 0, l.errorf(start, "invalid unicode escape")
+		}
+		return r, nil
+	}
+	if l.pos+4 > len(l.src) {
+		return 0, l.errorf(start, "invalid unicode escape")
+	}
+	r, ok := parseHex(l.src[l.pos : l.pos+4])
+	if !ok {
+		return 0, l.errorf(start, "invalid unicode escape")
+	}
+	l.pos += 4
+	if r >= 0xD800 && r <= 0xDBFF && strings.HasPrefix(l.src[l.pos:], `\u`) && l.pos+6 <= len(l.src) {
+		if lo, ok := parseHex(l.src[l.pos+2 : l.pos+6]); ok && lo >= 0xDC00 && lo <= 0xDFFF {
+			l.pos += 6
+			return (r-0xD800)<<10 + (lo - 0xDC00) + 0x10000, nil
+		}
+	}
+	if r >= 0xD800 && r <= 0xDFFF {
+		return 0, l.errorf(start, "invalid unicode escape")
+	}
+	return r, nil
+}
+
+func (l *lexer) blockString() (token, error) {
+	start := l.pos
+	l.pos += 3
+	var raw strings.Builder
+	for l.pos < len(l.src) {
+		switch {
+		case strings.HasPrefix(l.src[l.pos:], `"""`):
+			l.pos += 3
+			return token{kind: tokenBlockString, value: blockStringValue(raw.String()), pos: start}, nil
+		case strings.HasPrefix(l.src[l.pos:], `\"""`):
+			raw.WriteString(`"""`)
+			l.pos += 4
+		default:
+			raw.WriteByte(l.src[l.pos])
+			l.pos++
+		}
+	}
+	return token{}, l.errorf(start, "unterminated block string")
+}
+
+// blockStringValue removes the common indentation and the leading and
+// trailing blank lines of a block string.
+func blockStringValue(raw string) string {
+	lines := strings.Split(strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(raw), "\n")
+	indent := -1
+	for _, line := range lines[1:] {
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed == "" {
+			continue
+		}
+		if n := len(line) - len(trimmed); indent < 0 || n < indent {
+			indent = n
+		}
+	}
+	if indent > 0 {
+		for i := 1; i < len(lines); i++ {
+			if len(lines[i]) >= indent {
+				lines[i] = lines[i][indent:]
+			} else {
+				lines[i] = strings.TrimLeft(lines[i], " \t")
+			}
+		}
+	}
+	for len(lines) > 0 && strings.TrimLeft(lines[0], " \t") == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimLeft(lines[len(lines)-1], " \t") == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
+
+func parseHex(s string) (rune, bool) {
+	if s == "" || len(s) > 6 {
+		return 0, false
+	}
+	var r rune
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case isDigit(c):
+			r = r<<4 | rune(c-'0')
+		case c >= 'a' && c <= 'f':
+			r = r<<4 | rune(c-'a'+10)
+		case c >= 'A' && c <= 'F':
+			r = r<<4 | rune(c-'A'+10)
+		default:
+			return 0, false
+		}
+	}
+	return r, true
+}
+
+func isLetter(c byte) bool { return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' }
+func isDigit(c byte) bool  { return c >= '0' && c <= '9' }
+func isNameChar(c byte) bool {
+	return c == '_' || isLetter(c) || isDigit(c)
+}
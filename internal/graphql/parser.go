@@ -0,0 +1,402 @@
+package graphql
+
+// maxDepth bounds the nesting of selection sets and values so that hostile
+// documents cannot exhaust the stack.
+const maxDepth = 256
+
+// Parse parses an executable GraphQL document: operations and fragments.
+// Type system definitions are rejected.
+func Parse(src string) (*Document, error) {
+	p := &parser{lex: lexer{src: src}}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	doc := &Document{}
+	for p.tok.kind != tokenEOF {
+		switch {
+		case p.peekPunct("{"):
+			set, err := p.selectionSet(0)
+			if err != nil {
+				return nil, err
+			}
+			doc.Operations = append(doc.Operations, &Operation{Type: Query, SelectionSet: set})
+		case p.tok.kind == tokenName && (p.tok.value == Query || p.tok.value == Mutation || p.tok.value == Subscription):
+			op, err := p.operation()
+			if err != nil {
+				return nil, err
+			}
+			doc.Operations = append(doc.Operations, op)
+		case p.tok.kind == tokenName && p.tok.value == "fragment":
+			frag, err := p.fragment()
+			if err != nil {
+				return nil, err
+			}
+			doc.Fragments = append(doc.Fragments, frag)
+		default:
+			return nil, p.unexpected()
+		}
+	}
+	if len(doc.Operations) == 0 {
+		return nil, &SyntaxError{Message: "document contains no operation", Line: 1, Column: 1}
+	}
+	return doc, nil
+}
+
+type parser struct {
+	lex lexer
+	tok token
+}
+
+func (p *parser) advance() error {
+	tok, err := p.lex.next()
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+func (p *parser) unexpected() error {
+	return p.lex.errorf(p.tok.pos, "unexpected %s", p.tok)
+}
+
+func (p *parser) peekPunct(s string) bool {
+	return p.tok.kind == tokenPunct && p.tok.value == s
+}
+
+// skipPunct consumes the punctuator s if it is next.
+func (p *parser) skipPunct(s string) (bool, error) {
+	if !p.peekPunct(s) {
+		return false, nil
+	}
+	return true, p.advance()
+}
+
+func (p *parser) expectPunct(s string) error {
+	if !p.peekPunct(s) {
+		return p.lex.errorf(p.tok.pos, "expected %q, found %s", s, p.tok)
+	}
+	return p.advance()
+}
+
+func (p *parser) name() (string, error) {
+	if p.tok.kind != tokenName {
+		return "", p.lex.errorf(p.tok.pos, "expected name, found %s", p.tok)
+	}
+	name := p.tok.value
+	return name, p.advance()
+}
+
+func (p *parser) keyword(kw string) error {
+	if p.tok.kind != tokenName || p.tok.value != kw {
+		return p.lex.errorf(p.tok.pos, "expected %q, found %s", kw, p.tok)
+	}
+	return p.advance()
+}
+
+func (p *parser) operation() (*Operation, error) {
+	op := &Operation{Type: p.tok.value}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if p.tok.kind == tokenName {
+		op.Name = p.tok.value
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	if ok, err := p.skipPunct("("); err != nil {
+		return nil, err
+	} else if ok {
+		for !p.peekPunct(")") {
+			v, err := p.variableDefinition()
+			if err != nil {
+				return nil, err
+			}
+			op.Variables = append(op.Variables, v)
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	var err error
+	if op.Directives, err = p.directives(); err != nil {
+		return nil, err
+	}
+	if op.SelectionSet, err = p.selectionSet(0); err != nil {
+		return nil, err
+	}
+	return op, nil
+}
+
+func (p *parser) variableDefinition() (*VariableDefinition, error) {
+	if err := p.expectPunct("$"); err != nil {
+		return nil, err
+	}
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expectPunct(":"); err != nil {
+		return nil, err
+	}
+	v := &VariableDefinition{Name: name}
+	if v.Type, err = p.typeRef(0); err != nil {
+		return nil, err
+	}
+	if ok, err := p.skipPunct("="); err != nil {
+		return nil, err
+	} else if ok {
+		if v.Default, err = p.value(true, 0); err != nil {
+			return nil, err
+		}
+	}
+	if v.Directives, err = p.directives(); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (p *parser) typeRef(depth int) (*Type, error) {
+	if depth > maxDepth {
+		return nil, p.lex.errorf(p.tok.pos, "type is nested too deeply")
+	}
+	t := &Type{}
+	if ok, err := p.skipPunct("["); err != nil {
+		return nil, err
+	} else if ok {
+		if t.Elem, err = p.typeRef(depth + 1); err != nil {
+			return nil, err
+		}
+		if err := p.expectPunct("]"); err != nil {
+			return nil, err
+		}
+	} else {
+		var err error
+		if t.Name, err = p.name(); err != nil {
+			return nil, err
+		}
+	}
+	var err error
+	t.NonNull, err = p.skipPunct("!")
+	return t, err
+}
+
+func (p *parser) fragment() (*Fragment, error) {
+	if err := p.advance(); err != nil { // "fragment"
+		return nil, err
+	}
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	if name == "on" {
+		return nil, p.lex.errorf(p.tok.pos, "fragment cannot be named \"on\"")
+	}
+	if err := p.keyword("on"); err != nil {
+		return nil, err
+	}
+	f := &Fragment{Name: name}
+	if f.TypeCondition, err = p.name(); err != nil {
+		return nil, err
+	}
+	if f.Directives, err = p.directives(); err != nil {
+		return nil, err
+	}
+	if f.SelectionSet, err = p.selectionSet(0); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (p *parser) selectionSet(depth int) ([]Selection, error) {
+	if depth > maxDepth {
+		return nil, p.lex.errorf(p.tok.pos, "selection set is nested too deeply")
+	}
+	if err := p.expectPunct("{"); err != nil {
+		return nil, err
+	}
+	var set []Selection
+	for !p.peekPunct("}") {
+		sel, err := p.selection(depth)
+		if err != nil {
+			return nil, err
+		}
+		set = append(set, sel)
+	}
+	if len(set) == 0 {
+		return nil, p.lex.errorf(p.tok.pos, "selection set cannot be empty")
+	}
+	return set, p.advance()
+}
+
+func (p *parser) selection(depth int) (Selection, error) {
+	if ok, err := p.skipPunct("..."); err != nil {
+		return nil, err
+	} else if ok {
+		if p.tok.kind == tokenName && p.tok.value != "on" {
+			s := &FragmentSpread{Name: p.tok.value}
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			s.Directives, err = p.directives()
+			return s, err
+		}
+		f := &InlineFragment{}
+		if p.tok.kind == tokenName { // "on"
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			if f.TypeCondition, err = p.name(); err != nil {
+				return nil, err
+			}
+		}
+		if f.Directives, err = p.directives(); err != nil {
+			return nil, err
+		}
+		f.SelectionSet, err = p.selectionSet(depth + 1)
+		return f, err
+	}
+
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	f := &Field{Name: name}
+	if ok, err := p.skipPunct(":"); err != nil {
+		return nil, err
+	} else if ok {
+		f.Alias = name
+		if f.Name, err = p.name(); err != nil {
+			return nil, err
+		}
+	}
+	if f.Arguments, err = p.arguments(false); err != nil {
+		return nil, err
+	}
+	if f.Directives, err = p.directives(); err != nil {
+		return nil, err
+	}
+	if p.peekPunct("{") {
+		if f.SelectionSet, err = p.selectionSet(depth + 1); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+func (p *parser) arguments(constant bool) ([]*Argument, error) {
+	if ok, err := p.skipPunct("("); err != nil || !ok {
+		return nil, err
+	}
+	var args []*Argument
+	for !p.peekPunct(")") {
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectPunct(":"); err != nil {
+			return nil, err
+		}
+		v, err := p.value(constant, 0)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, &Argument{Name: name, Value: v})
+	}
+	if len(args) == 0 {
+		return nil, p.lex.errorf(p.tok.pos, "argument list cannot be empty")
+	}
+	return args, p.advance()
+}
+
+func (p *parser) directives() ([]*Directive, error) {
+	var dirs []*Directive
+	for p.peekPunct("@") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		d := &Directive{Name: name}
+		if d.Arguments, err = p.arguments(false); err != nil {
+			return nil, err
+		}
+		dirs = append(dirs, d)
+	}
+	return dirs, nil
+}
+
+// value parses an input value. Variables are not allowed in constant values
+// such as variable defaults.
+func (p *parser) value(constant bool, depth int) (*Value, error) {
+	if depth > maxDepth {
+		return nil, p.lex.errorf(p.tok.pos, "value is nested too deeply")
+	}
+	tok := p.tok
+	switch tok.kind {
+	case tokenPunct:
+		switch tok.value {
+		case "$":
+			if constant {
+				return nil, p.lex.errorf(tok.pos, "variables are not allowed in constant values")
+			}
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			name, err := p.name()
+			return &Value{Kind: VariableValue, Raw: name}, err
+		case "[":
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			v := &Value{Kind: ListValue}
+			for !p.peekPunct("]") {
+				item, err := p.value(constant, depth+1)
+				if err != nil {
+					return nil, err
+				}
+				v.List = append(v.List, item)
+			}
+			return v, p.advance()
+		case "{":
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			v := &Value{Kind: ObjectValue}
+			for !p.peekPunct("}") {
+				name, err := p.name()
+				if err != nil {
+					return nil, err
+				}
+				if err := p.expectPunct(":"); err != nil {
+					return nil, err
+				}
+				fv, err := p.value(constant, depth+1)
+				if err != nil {
+					return nil, err
+				}
+				v.Fields = append(v.Fields, &ObjectField{Name: name, Value: fv})
+			}
+			return v, p.advance()
+		}
+	case tokenInt:
+		return &Value{Kind: IntValue, Raw: tok.value}, p.advance()
+	case tokenFloat:
+		return &Value{Kind: FloatValue, Raw: tok.value}, p.advance()
+	case tokenString, tokenBlockString:
+		return &Value{Kind: StringValue, Raw: tok.value}, p.advance()
+	case tokenName:
+		v := &Value{Kind: EnumValue, Raw: tok.value}
+		switch tok.value {
+		case "true", "false":
+			v.Kind = BooleanValue
+		case "null":
+			v.Kind = NullValue
+		}
+		return v, p.advance()
+	}
+	return nil, p.unexpected()
+}
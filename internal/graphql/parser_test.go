@@ -0,0 +1,183 @@
+package graphql
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse_Operations(t *testing.T) {
+	doc, err := Parse(`
+		# fetch a user
+		query GetUser($id: ID!, $tags: [String!] = ["a", "b"]) @cached(ttl: 30) {
+			user(id: $id) {
+				id, name
+				friends: connections(first: 10, filter: {kind: FRIEND, since: 1.5e3}) {
+					...UserFields
+				}
+				... on Admin { level }
+				... @include(if: true) { email }
+			}
+		}
+		mutation { rename(name: """
+			  multi
+			    line
+			""") }
+		fragment UserFields on User { id avatar(size: -2) }
+	`)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(doc.Operations) != 2 || len(doc.Fragments) != 1 {
+		t.Fatalf("got %d operations and %d fragments", len(doc.Operations), len(doc.Fragments))
+	}
+
+	op := doc.Operations[0]
+	if op.Type != Query || op.Name != "GetUser" {
+		t.Errorf("unexpected operation %s %s", op.Type, op.Name)
+	}
+	if len(op.Variables) != 2 || op.Variables[0].Type.String() != "ID!" || op.Variables[1].Type.String() != "[String!]" {
+		t.Errorf("unexpected variables %+v", op.Variables)
+	}
+	if def := op.Variables[1].Default; def == nil || def.Kind != ListValue || len(def.List) != 2 || def.List[1].Raw != "b" {
+		t.Errorf("unexpected default %+v", def)
+	}
+	if len(op.Directives) != 1 || op.Directives[0].Name != "cached" {
+		t.Errorf("unexpected directives %+v", op.Directives)
+	}
+
+	user := op.SelectionSet[0].(*Field)
+	friends := user.SelectionSet[2].(*Field)
+	if friends.Alias != "friends" || friends.Name != "connections" || friends.ResponseKey() != "friends" {
+		t.Errorf("unexpected aliased field %+v", friends)
+	}
+	filter := friends.Arguments[1].Value
+	if filter.Kind != ObjectValue || filter.Fields[0].Value.Kind != EnumValue || filter.Fields[1].Value.Kind != FloatValue {
+		t.Errorf("unexpected object value %+v", filter)
+	}
+	if inline := user.SelectionSet[3].(*InlineFragment); inline.TypeCondition != "Admin" {
+		t.Errorf("unexpected inline fragment %+v", inline)
+	}
+	if inline := user.SelectionSet[4].(*InlineFragment); inline.TypeCondition != "" || inline.Directives[0].Name != "include" {
+		t.Errorf("unexpected inline fragment %+v", inline)
+	}
+
+	mut := doc.Operations[1]
+	if mut.Type != Mutation || mut.Name != "" {
+		t.Errorf("unexpected operation %s %q", mut.Type, mut.Name)
+	}
+	if s := mut.SelectionSet[0].(*Field).Arguments[0].Value.Raw; s != "multi\n  line" {
+		t.Errorf("unexpected block string %q", s)
+	}
+	if doc.Fragment("UserFields") == nil || doc.Fragment("Missing") != nil {
+		t.Error("unexpected fragment lookup")
+	}
+}
+
+func TestParse_Strings(t *testing.T) {
+	doc, err := Parse(`{ a(s: "tab\there \"q\" é 😀 \u{1F600}") }`)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	got := doc.Operations[0].SelectionSet[0].(*Field).Arguments[0].Value.Raw
+	if want := "tab\there \"q\" é 😀 😀"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{``, "no operation"},
+		{`fragment F on T { a }`, "no operation"},
+		{`{ a `, "found end of document"},
+		{`{ }`, "selection set cannot be empty"},
+		{`query Q { a(x: ) }`, "unexpected punctuator"},
+		{`query Q($a: Int = $b) { a }`, "variables are not allowed"},
+		{`{ a(x: "open) }`, "unterminated string"},
+		{`{ a(x: 01x) }`, "invalid number"},
+		{`type Query { a: Int }`, "unexpected name \"type\""},
+		{`{ a(x: "\q") }`, "invalid escape"},
+		{"{\n  a ^ }", "2:5"},
+		{strings.Repeat("{ a ", 300) + strings.Repeat("}", 300), "nested too deeply"},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.src)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Parse(%.20q): expected error containing %q, got %v", tt.src, tt.want, err)
+		}
+	}
+}
+
+func TestDocument_Operation(t *testing.T) {
+	doc, err := Parse(`query A { a } query B { b }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if op, err := doc.Operation("B"); err != nil || op.Name != "B" {
+		t.Errorf("Operation(B) = %v, %v", op, err)
+	}
+	if _, err := doc.Operation(""); err == nil || !strings.Contains(err.Error(), "operationName is required") {
+		t.Errorf("expected ambiguity error, got %v", err)
+	}
+	if _, err := doc.Operation("C"); err == nil || !strings.Contains(err.Error(), "unknown operation") {
+		t.Errorf("expected unknown operation error, got %v", err)
+	}
+}
+
+func TestDocument_WalkFields(t *testing.T) {
+	doc, err := Parse(`
+		query { me { ...A ... on User { b: name } } }
+		fragment A on User { id ...B }
+		fragment B on User { ...A tags }
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	doc.WalkFields(doc.Operations[0], func(path []string, f *Field) {
+		paths = append(paths, strings.Join(path, "."))
+	})
+	want := []string{"me", "me.id", "me.tags", "me.b"}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("got %v, want %v", paths, want)
+	}
+}
+
+func TestDocument_FieldUsage(t *testing.T) {
+	doc, err := Parse(`
+		query { me { ...A ... on User { b: name __typename } } again: me { id } __typename }
+		fragment A on User { id ...B }
+		fragment B on User { ...A friends { n: name } }
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := doc.FieldUsage(doc.Operations[0])
+	want := []string{"me", "me.id", "me.friends", "me.friends.name", "me.name"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestDocument_Introspects(t *testing.T) {
+	tests := []struct {
+		src  string
+		want bool
+	}{
+		{`{ __schema { types { name } } }`, true},
+		{`{ user { ...F } } fragment F on User { t: __type(name: "User") { name } }`, true},
+		{`{ user { __typename id } }`, false},
+	}
+	for _, tt := range tests {
+		doc, err := Parse(tt.src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := doc.Introspects(doc.Operations[0]); got != tt.want {
+			t.Errorf("Introspects(%q) = %v, want %v", tt.src, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package graphql
+
+import (
+	"container/list"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// DefaultPersistedQueryEntries bounds the queries a PersistedQueryStore
+// learns from clients when no other limit is given.
+const DefaultPersistedQueryEntries = 10000
+
+// CodePersistedQueryNotFound tells an Automatic Persisted Queries client to
+// retry with the full query text.
+const CodePersistedQueryNotFound = "PERSISTED_QUERY_NOT_FOUND"
+
+// PersistedQueryStore maps SHA-256 hashes to query documents for Automatic
+// Persisted Queries. It holds two sets: a manifest of pre-registered queries,
+// replaced as a whole, and queries learned from clients, of which the least
+// recently used are evicted beyond the size limit. It is safe for concurrent
+// use.
+type PersistedQueryStore struct {
+	mu       sync.Mutex
+	manifest map[string]string
+	learned  map[string]*list.Element // values are *persistedQuery
+	lru      *list.List
+	max      int
+
+	hits, misses, registrations uint64
+}
+
+type persistedQuery struct {
+	hash  string
+	query string
+}
+
+// PersistedQueryStats reports the contents and usage of a store.
+type PersistedQueryStats struct {
+	ManifestEntries int    `json:"manifest_entries"`
+	LearnedEntries  int    `json:"learned_entries"`
+	MaxEntries      int    `json:"max_entries"`
+	Hits            uint64 `json:"hits"`
+	Misses          uint64 `json:"misses"`
+	Registrations   uint64 `json:"registrations"`
+}
+
+// NewPersistedQueryStore creates a store that learns at most maxEntries
+// queries from clients (DefaultPersistedQueryEntries when <= 0).
+func NewPersistedQueryStore(maxEntries int) *PersistedQueryStore {
+	if maxEntries <= 0 {
+		maxEntries = DefaultPersistedQueryEntries
+	}
+	return &PersistedQueryStore{
+		manifest: make(map[string]string),
+		learned:  make(map[string]*list.Element),
+		lru:      list.New(),
+		max:      maxEntries,
+	}
+}
+
+// Lookup returns the query registered under hash. When manifestOnly is set,
+// queries learned from clients are not consulted.
+func (s *PersistedQueryStore) Lookup(hash string, manifestOnly bool) (string, bool) {
+	hash = strings.ToLower(hash)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if q, ok := s.manifest[hash]; ok {
+		s.hits++
+		return q, true
+	}
+	if !manifestOnly {
+		if el, ok := s.learned[hash]; ok {
+			s.lru.MoveToFront(el)
+			s.hits++
+			return el.Value.(*persistedQuery).query, true
+		}
+	}
+	s.misses++
+	return "", false
+}
+
+// Register learns query under its hash, which it returns.
+func (s *PersistedQueryStore) Register(query string) string {
+	hash := QueryHash(query)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.manifest[hash]; ok {
+		return hash
+	}
+	if el, ok := s.learned[hash]; ok {
+		s.lru.MoveToFront(el)
+		return hash
+	}
+	s.learned[hash] = s.lru.PushFront(&persistedQuery{hash: hash, query: query})
+	s.registrations++
+	for s.lru.Len() > s.max {
+		oldest := s.lru.Back()
+		s.lru.Remove(oldest)
+		delete(s.learned, oldest.Value.(*persistedQuery).hash)
+	}
+	return hash
+}
+
+// SetManifest replaces the pre-registered queries with queries, keyed by
+// their SHA-256 hash. Every key must be the hash of its query.
+func (s *PersistedQueryStore) SetManifest(queries map[string]string) error {
+	manifest := make(map[string]string, len(queries))
+	for hash, query := range queries {
+		if sum := QueryHash(query); !strings.EqualFold(hash, sum) {
+			return fmt.Errorf("persisted query %q: hash does not match query (expected %s)", hash, sum)
+		}
+		manifest[strings.ToLower(hash)] = query
+	}
+	s.mu.Lock()
+	s.manifest = manifest
+	s.mu.Unlock()
+	return nil
+}
+
+// Manifest returns a copy of the pre-registered queries.
+func (s *PersistedQueryStore) Manifest() map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m := make(map[string]string, len(s.manifest))
+	for h, q := range s.manifest {
+		m[h] = q
+	}
+	return m
+}
+
+// Stats returns the current entry counts and lookup counters.
+func (s *PersistedQueryStore) Stats() PersistedQueryStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return PersistedQueryStats{
+		ManifestEntries: len(s.manifest),
+		LearnedEntries:  s.lru.Len(),
+		MaxEntries:      s.max,
+		Hits:            s.hits,
+		Misses:          s.misses,
+		Registrations:   s.registrations,
+	}
+}
@@ -0,0 +1,57 @@
+package graphql
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPersistedQueryStore_Learn(t *testing.T) {
+	s := NewPersistedQueryStore(2)
+	a, b, c := "{ a }", "{ b }", "{ c }"
+	ha := s.Register(a)
+	if ha != QueryHash(a) {
+		t.Fatalf("Register returned %q", ha)
+	}
+	s.Register(b)
+	if q, ok := s.Lookup(strings.ToUpper(ha), false); !ok || q != a {
+		t.Fatalf("Lookup = %q, %v", q, ok)
+	}
+	// a was used more recently than b, so b is evicted.
+	s.Register(c)
+	if _, ok := s.Lookup(QueryHash(b), false); ok {
+		t.Error("expected b to be evicted")
+	}
+	if _, ok := s.Lookup(ha, false); !ok {
+		t.Error("expected a to be kept")
+	}
+	if _, ok := s.Lookup(ha, true); ok {
+		t.Error("learned queries must not be found in manifest-only lookups")
+	}
+
+	st := s.Stats()
+	if st.LearnedEntries != 2 || st.MaxEntries != 2 || st.Registrations != 3 || st.Hits != 2 || st.Misses != 2 {
+		t.Errorf("unexpected stats %+v", st)
+	}
+}
+
+func TestPersistedQueryStore_Manifest(t *testing.T) {
+	s := NewPersistedQueryStore(0)
+	q := "query Q { a }"
+	if err := s.SetManifest(map[string]string{strings.ToUpper(QueryHash(q)): q}); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := s.Lookup(QueryHash(q), true); !ok || got != q {
+		t.Fatalf("Lookup = %q, %v", got, ok)
+	}
+	s.Register(q)
+	if st := s.Stats(); st.ManifestEntries != 1 || st.LearnedEntries != 0 || st.MaxEntries != DefaultPersistedQueryEntries {
+		t.Errorf("unexpected stats %+v", st)
+	}
+
+	if err := s.SetManifest(map[string]string{QueryHash("{ b }"): q}); err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Errorf("expected hash mismatch error, got %v", err)
+	}
+	if len(s.Manifest()) != 1 {
+		t.Error("failed SetManifest must keep the previous manifest")
+	}
+}
@@ -0,0 +1,135 @@
+package graphql
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Print formats doc as a compact executable document that Parse reads back
+// into an equivalent document.
+func Print(doc *Document) string {
+	var b strings.Builder
+	for _, op := range doc.Operations {
+		if b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		printOperation(&b, op)
+	}
+	for _, f := range doc.Fragments {
+		if b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString("fragment " + f.Name + " on " + f.TypeCondition)
+		printDirectives(&b, f.Directives)
+		printSelectionSet(&b, f.SelectionSet)
+	}
+	return b.String()
+}
+
+func printOperation(b *strings.Builder, op *Operation) {
+	b.WriteString(op.Type)
+	if op.Name != "" {
+		b.WriteString(" " + op.Name)
+	}
+	if len(op.Variables) > 0 {
+		b.WriteByte('(')
+		for i, v := range op.Variables {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString("$" + v.Name + ": " + v.Type.String())
+			if v.Default != nil {
+				b.WriteString(" = ")
+				printValue(b, v.Default)
+			}
+			printDirectives(b, v.Directives)
+		}
+		b.WriteByte(')')
+	}
+	printDirectives(b, op.Directives)
+	printSelectionSet(b, op.SelectionSet)
+}
+
+func printSelectionSet(b *strings.Builder, set []Selection) {
+	b.WriteString(" {")
+	for _, sel := range set {
+		b.WriteByte(' ')
+		switch s := sel.(type) {
+		case *Field:
+			if s.Alias != "" {
+				b.WriteString(s.Alias + ": ")
+			}
+			b.WriteString(s.Name)
+			printArguments(b, s.Arguments)
+			printDirectives(b, s.Directives)
+			if len(s.SelectionSet) > 0 {
+				printSelectionSet(b, s.SelectionSet)
+			}
+		case *FragmentSpread:
+			b.WriteString("..." + s.Name)
+			printDirectives(b, s.Directives)
+		case *InlineFragment:
+			b.WriteString("...")
+			if s.TypeCondition != "" {
+				b.WriteString(" on " + s.TypeCondition)
+			}
+			printDirectives(b, s.Directives)
+			printSelectionSet(b, s.SelectionSet)
+		}
+	}
+	b.WriteString(" }")
+}
+
+func printArguments(b *strings.Builder, args []*Argument) {
+	if len(args) == 0 {
+		return
+	}
+	b.WriteByte('(')
+	for i, a := range args {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(a.Name + ": ")
+		printValue(b, a.Value)
+	}
+	b.WriteByte(')')
+}
+
+func printDirectives(b *strings.Builder, dirs []*Directive) {
+	for _, d := range dirs {
+		b.WriteString(" @" + d.Name)
+		printArguments(b, d.Arguments)
+	}
+}
+
+func printValue(b *strings.Builder, v *Value) {
+	switch v.Kind {
+	case VariableValue:
+		b.WriteString("$" + v.Raw)
+	case StringValue:
+		// JSON string escapes are all valid in GraphQL strings.
+		s, _ := json.Marshal(v.Raw)
+		b.Write(s)
+	case ListValue:
+		b.WriteByte('[')
+		for i, item := range v.List {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			printValue(b, item)
+		}
+		b.WriteByte(']')
+	case ObjectValue:
+		b.WriteByte('{')
+		for i, f := range v.Fields {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString(f.Name + ": ")
+			printValue(b, f.Value)
+		}
+		b.WriteByte('}')
+	default:
+		b.WriteString(v.Raw)
+	}
+}
@@ -0,0 +1,29 @@
+package graphql
+
+import "testing"
+
+func TestPrint(t *testing.T) {
+	tests := map[string]string{
+		`{ a }`: `query { a }`,
+		`query Q($id: ID! = "x\n\"y\"", $n: [Int!] @d) @op(a: 1) { u: user(id: $id, f: {a: [1, 2.5, true, null, ENUM], b: """block"""}) @include(if: $n) { ...F ... on User @skip(if: false) { name } ... { id } } }
+		fragment F on User { id }`: `query Q($id: ID! = "x\n\"y\"", $n: [Int!] @d) @op(a: 1) { u: user(id: $id, f: {a: [1, 2.5, true, null, ENUM], b: "block"}) @include(if: $n) { ...F ... on User @skip(if: false) { name } ... { id } } } fragment F on User { id }`,
+		`mutation M { a } subscription S { b }`: `mutation M { a } subscription S { b }`,
+	}
+	for src, want := range tests {
+		doc, err := Parse(src)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", src, err)
+		}
+		got := Print(doc)
+		if got != want {
+			t.Errorf("Print(%q)\n got %s\nwant %s", src, got, want)
+		}
+		again, err := Parse(got)
+		if err != nil {
+			t.Fatalf("printed document does not parse: %v", err)
+		}
+		if Print(again) != got {
+			t.Errorf("printing is not stable for %q", src)
+		}
+	}
+}
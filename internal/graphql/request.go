@@ -0,0 +1,249 @@
+package graphql
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Request is a GraphQL-over-HTTP request.
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+	Extensions    map[string]interface{} `json:"extensions,omitempty"`
+}
+
+// RequestError reports a GraphQL-over-HTTP request that cannot be accepted,
+// with the HTTP status and error code to answer it with.
+type RequestError struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+func (e *RequestError) Error() string { return e.Message }
+
+func badRequest(format string, args ...interface{}) *RequestError {
+	return &RequestError{Status: http.StatusBadRequest, Code: CodeBadRequest, Message: fmt.Sprintf(format, args...)}
+}
+
+// ReadRequest decodes the GraphQL request carried by r: the query string
+// parameters of a GET, or the JSON body of a POST. The body is read in full
+// and replaced, so r can still be forwarded; a body larger than maxBytes
+// (when positive) is rejected and left unconsumed. The request must carry a
+// query, or the hash of a persisted one. Errors are *RequestError.
+func ReadRequest(r *http.Request, maxBytes int64) (*Request, error) {
+	var req *Request
+	var err error
+	if r.Method == http.MethodGet {
+		req, err = readQueryParams(r)
+	} else {
+		req, err = readBody(r, maxBytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if req.Query == "" && req.PersistedQueryHash() == "" {
+		return nil, badRequest("query is required")
+	}
+	return req, nil
+}
+
+func readQueryParams(r *http.Request) (*Request, error) {
+	q := r.URL.Query()
+	req := &Request{Query: q.Get("query"), OperationName: q.Get("operationName")}
+	if v := q.Get("variables"); v != "" {
+		if err := unmarshalJSON([]byte(v), &req.Variables); err != nil || req.Variables == nil && v != "null" {
+			return nil, badRequest("variables must be a JSON object")
+		}
+	}
+	if v := q.Get("extensions"); v != "" {
+		if err := unmarshalJSON([]byte(v), &req.Extensions); err != nil || req.Extensions == nil && v != "null" {
+			return nil, badRequest("extensions must be a JSON object")
+		}
+	}
+	return req, nil
+}
+
+func readBody(r *http.Request, maxBytes int64) (*Request, error) {
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		mt, _, err := mime.ParseMediaType(ct)
+		if err != nil || (mt != "application/json" && !strings.HasSuffix(mt, "+json")) {
+			return nil, &RequestError{Status: http.StatusUnsupportedMediaType, Code: CodeBadRequest, Message: fmt.Sprintf("unsupported content type %q, expected application/json", ct)}
+		}
+	}
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, badRequest("request body is empty")
+	}
+	if maxBytes > 0 && r.ContentLength > maxBytes {
+		return nil, tooLarge(maxBytes)
+	}
+	src := io.Reader(r.Body)
+	if maxBytes > 0 {
+		src = io.LimitReader(r.Body, maxBytes+1)
+	}
+	body, err := io.ReadAll(src)
+	if maxBytes > 0 && int64(len(body)) > maxBytes {
+		// Put back what was read so the body can be read again.
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+		return nil, tooLarge(maxBytes)
+	}
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return nil, badRequest("read request body: %v", err)
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil, badRequest("request body is empty")
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil || fields == nil {
+		return nil, badRequest("request body must be a JSON object")
+	}
+	req := &Request{}
+	for _, f := range []struct {
+		name, kind string
+		dst        interface{}
+	}{
+		{"query", "a string", &req.Query},
+		{"operationName", "a string", &req.OperationName},
+		{"variables", "an object", &req.Variables},
+		{"extensions", "an object", &req.Extensions},
+	} {
+		raw, ok := fields[f.name]
+		if !ok || string(raw) == "null" {
+			continue
+		}
+		if err := unmarshalJSON(raw, f.dst); err != nil {
+			return nil, badRequest("%s must be %s", f.name, f.kind)
+		}
+	}
+	return req, nil
+}
+
+func tooLarge(maxBytes int64) *RequestError {
+	return &RequestError{
+		Status:  http.StatusRequestEntityTooLarge,
+		Code:    CodePayloadTooLarge,
+		Message: fmt.Sprintf("request body exceeds %d bytes", maxBytes),
+	}
+}
+
+// unmarshalJSON decodes data keeping numbers as json.Number, so variables are
+// forwarded without losing precision.
+func unmarshalJSON(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if dec.More() {
+		return errors.New("unexpected data after JSON value")
+	}
+	return nil
+}
+
+// SetRequest replaces the GraphQL request carried by r with req: the query
+// string parameters of a GET, or the JSON body of any other method.
+func SetRequest(r *http.Request, req *Request) error {
+	if r.Method == http.MethodGet {
+		q := r.URL.Query()
+		q.Set("query", req.Query)
+		for name, v := range map[string]map[string]interface{}{"variables": req.Variables, "extensions": req.Extensions} {
+			if v == nil {
+				q.Del(name)
+				continue
+			}
+			b, err := json.Marshal(v)
+			if err != nil {
+				return err
+			}
+			q.Set(name, string(b))
+		}
+		if req.OperationName != "" {
+			q.Set("operationName", req.OperationName)
+		} else {
+			q.Del("operationName")
+		}
+		r.URL.RawQuery = q.Encode()
+		return nil
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+	r.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	return nil
+}
+
+// ConvertToPost turns r, a GET carrying req, into the equivalent POST with a
+// JSON body. Query string parameters other than the GraphQL ones are kept.
+func ConvertToPost(r *http.Request, req *Request) error {
+	q := r.URL.Query()
+	for _, name := range []string{"query", "operationName", "variables", "extensions"} {
+		q.Del(name)
+	}
+	r.URL.RawQuery = q.Encode()
+	r.Method = http.MethodPost
+	r.Header.Set("Content-Type", "application/json")
+	return SetRequest(r, req)
+}
+
+// PersistedQueryHash returns the SHA-256 hash from the persistedQuery
+// extension, or "".
+func (r *Request) PersistedQueryHash() string {
+	pq, _ := r.Extensions["persistedQuery"].(map[string]interface{})
+	h, _ := pq["sha256Hash"].(string)
+	return strings.ToLower(h)
+}
+
+// QueryHash returns the hex SHA-256 digest of a query document, the hash
+// used by persisted queries.
+func QueryHash(query string) string {
+	sum := sha256.Sum256([]byte(query))
+	return hex.EncodeToString(sum[:])
+}
+
+// Error codes reported in the extensions of gateway errors.
+const (
+	CodeBadRequest           = "BAD_REQUEST"
+	CodePayloadTooLarge      = "PAYLOAD_TOO_LARGE"
+	CodeMethodNotAllowed     = "METHOD_NOT_ALLOWED"
+	CodeParseFailed          = "GRAPHQL_PARSE_FAILED"
+	CodeValidationFailed     = "GRAPHQL_VALIDATION_FAILED"
+	CodeIntrospectionBlocked = "INTROSPECTION_DISABLED"
+	CodeOperationNotAllowed  = "OPERATION_NOT_ALLOWED"
+	CodeSubgraphFailed       = "SUBGRAPH_REQUEST_FAILED"
+	CodeSubscriptionLimit    = "SUBSCRIPTION_LIMIT_REACHED"
+)
+
+// Error is a GraphQL response error.
+type Error struct {
+	Message    string                 `json:"message"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+// WriteError writes a GraphQL response holding a single error with the given
+// code in its extensions.
+func WriteError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(struct {
+		Errors []Error `json:"errors"`
+	}{[]Error{{Message: message, Extensions: map[string]interface{}{"code": code}}}})
+}
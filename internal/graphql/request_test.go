@@ -0,0 +1,166 @@
+package graphql
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestReadRequest_Post(t *testing.T) {
+	body := `{"query":"query Q { a }","operationName":"Q","variables":{"id":1},"extensions":{"persistedQuery":{"version":1,"sha256Hash":"ABC"}}}`
+	r := httptest.NewRequest("POST", "/graphql", strings.NewReader(body))
+	req, err := ReadRequest(r, 0)
+	if err != nil {
+		t.Fatalf("ReadRequest: %v", err)
+	}
+	if req.Query != "query Q { a }" || req.OperationName != "Q" || req.Variables["id"] != json.Number("1") {
+		t.Errorf("unexpected request %+v", req)
+	}
+	if h := req.PersistedQueryHash(); h != "abc" {
+		t.Errorf("PersistedQueryHash = %q", h)
+	}
+	if rest, _ := io.ReadAll(r.Body); string(rest) != body {
+		t.Errorf("body not restored: %q", rest)
+	}
+
+	for bad, msg := range map[string]string{
+		"":                                     "request body is empty",
+		"[1]":                                  "request body must be a JSON object",
+		`{"query":1}`:                          "query must be a string",
+		`{"query":"{ a }","operationName":[]}`: "operationName must be a string",
+		`{"query":"{ a }","variables":[]}`:     "variables must be an object",
+		`{"query":"{ a }"} {}`:                 "request body must be a JSON object",
+		`{"variables":{}}`:                     "query is required",
+	} {
+		_, err := ReadRequest(httptest.NewRequest("POST", "/graphql", strings.NewReader(bad)), 0)
+		if reqErr, ok := err.(*RequestError); !ok || reqErr.Status != http.StatusBadRequest || reqErr.Message != msg {
+			t.Errorf("body %q: expected %q, got %v", bad, msg, err)
+		}
+	}
+}
+
+func TestReadRequest_Limits(t *testing.T) {
+	body := `{"query":"{ a }"}`
+	if _, err := ReadRequest(httptest.NewRequest("POST", "/graphql", strings.NewReader(body)), int64(len(body))); err != nil {
+		t.Fatalf("body at the limit rejected: %v", err)
+	}
+
+	r := httptest.NewRequest("POST", "/graphql", strings.NewReader(body))
+	_, err := ReadRequest(r, int64(len(body)-1))
+	if reqErr, ok := err.(*RequestError); !ok || reqErr.Status != http.StatusRequestEntityTooLarge || reqErr.Code != CodePayloadTooLarge {
+		t.Errorf("expected 413 for a declared length over the limit, got %v", err)
+	}
+
+	// Without a declared length the limit applies while reading.
+	r = httptest.NewRequest("POST", "/graphql", io.MultiReader(strings.NewReader(body)))
+	r.ContentLength = -1
+	_, err = ReadRequest(r, 8)
+	if reqErr, ok := err.(*RequestError); !ok || reqErr.Status != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413 for a streamed body over the limit, got %v", err)
+	}
+	if rest, _ := io.ReadAll(r.Body); string(rest) != body {
+		t.Errorf("rejected body not restored: %q", rest)
+	}
+
+	r = httptest.NewRequest("POST", "/graphql", strings.NewReader(body))
+	r.Header.Set("Content-Type", "text/plain")
+	_, err = ReadRequest(r, 0)
+	if reqErr, ok := err.(*RequestError); !ok || reqErr.Status != http.StatusUnsupportedMediaType {
+		t.Errorf("expected 415 for a text body, got %v", err)
+	}
+	r = httptest.NewRequest("POST", "/graphql", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/graphql-response+json; charset=utf-8")
+	if _, err := ReadRequest(r, 0); err != nil {
+		t.Errorf("expected +json media types to be accepted, got %v", err)
+	}
+}
+
+func TestReadRequest_Get(t *testing.T) {
+	q := url.Values{"query": {"{ a }"}, "variables": {`{"x":"y"}`}, "operationName": {"Op"}}
+	req, err := ReadRequest(httptest.NewRequest("GET", "/graphql?"+q.Encode(), nil), 0)
+	if err != nil {
+		t.Fatalf("ReadRequest: %v", err)
+	}
+	if req.Query != "{ a }" || req.OperationName != "Op" || req.Variables["x"] != "y" {
+		t.Errorf("unexpected request %+v", req)
+	}
+	for _, bad := range []string{"query=%7B+a+%7D&variables=nope", "query=%7B+a+%7D&variables=%5B%5D", "operationName=Op"} {
+		if _, err := ReadRequest(httptest.NewRequest("GET", "/graphql?"+bad, nil), 0); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestQueryHash(t *testing.T) {
+	if h := QueryHash("{ a }"); len(h) != 64 || h != QueryHash("{ a }") || h == QueryHash("{ b }") {
+		t.Errorf("unexpected hash %q", h)
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	w := httptest.NewRecorder()
+	WriteError(w, 403, CodeOperationNotAllowed, "nope")
+	if w.Code != 403 || w.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("unexpected response %d %v", w.Code, w.Header())
+	}
+	var resp struct {
+		Errors []Error `json:"errors"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Errors) != 1 || resp.Errors[0].Message != "nope" || resp.Errors[0].Extensions["code"] != CodeOperationNotAllowed {
+		t.Errorf("unexpected body %s", w.Body)
+	}
+}
+
+func TestSetRequest(t *testing.T) {
+	req := &Request{Query: "{ a }", Variables: map[string]interface{}{"n": json.Number("12345678901234567890")}}
+
+	post := httptest.NewRequest("POST", "/graphql", strings.NewReader(`{}`))
+	if err := SetRequest(post, req); err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(post.Body)
+	if string(body) != `{"query":"{ a }","variables":{"n":12345678901234567890}}` || post.ContentLength != int64(len(body)) {
+		t.Errorf("unexpected body %s (length %d)", body, post.ContentLength)
+	}
+
+	get := httptest.NewRequest("GET", "/graphql?operationName=Old&extensions=%7B%7D", nil)
+	if err := SetRequest(get, req); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadRequest(get, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Query != "{ a }" || got.OperationName != "" || got.Extensions != nil || got.Variables["n"] != json.Number("12345678901234567890") {
+		t.Errorf("unexpected request %+v", got)
+	}
+}
+
+func TestConvertToPost(t *testing.T) {
+	q := url.Values{"query": {"query Q($id: ID) { a }"}, "variables": {`{"id":"1"}`}, "operationName": {"Q"}, "trace": {"on"}}
+	r := httptest.NewRequest("GET", "/graphql?"+q.Encode(), nil)
+	req, err := ReadRequest(r, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ConvertToPost(r, req); err != nil {
+		t.Fatal(err)
+	}
+	if r.Method != "POST" || r.URL.RawQuery != "trace=on" || r.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("unexpected request %s %s %v", r.Method, r.URL, r.Header)
+	}
+	got, err := ReadRequest(r, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Query != req.Query || got.OperationName != "Q" || got.Variables["id"] != "1" {
+		t.Errorf("unexpected body %+v", got)
+	}
+}
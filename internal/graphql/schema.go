@@ -0,0 +1,392 @@
+package graphql
+
+import "fmt"
+
+// Schema is a parsed GraphQL schema (SDL) document. Descriptions and
+// directive definitions are skipped; extensions are merged into the types
+// they extend.
+type Schema struct {
+	Types map[string]*TypeDefinition
+	// QueryType, MutationType and SubscriptionType name the root operation
+	// types; they default to Query, Mutation and Subscription.
+	QueryType        string
+	MutationType     string
+	SubscriptionType string
+}
+
+// TypeDefinition defines a named type.
+type TypeDefinition struct {
+	Kind       string // "scalar", "type", "interface", "union", "enum" or "input"
+	Name       string
+	Interfaces []string
+	Directives []*Directive
+	// Fields holds the fields of object and interface types.
+	Fields []*FieldDefinition
+	// InputFields holds the fields of input object types.
+	InputFields []*InputValueDefinition
+	// Members holds the member types of unions.
+	Members    []string
+	EnumValues []*EnumValueDefinition
+}
+
+// Field returns the field named name, or nil.
+func (t *TypeDefinition) Field(name string) *FieldDefinition {
+	for _, f := range t.Fields {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// FieldDefinition defines a field of an object or interface type.
+type FieldDefinition struct {
+	Name       string
+	Arguments  []*InputValueDefinition
+	Type       *Type
+	Directives []*Directive
+}
+
+// InputValueDefinition defines an argument or an input object field.
+type InputValueDefinition struct {
+	Name       string
+	Type       *Type
+	Default    *Value
+	Directives []*Directive
+}
+
+// EnumValueDefinition defines a value of an enum type.
+type EnumValueDefinition struct {
+	Name       string
+	Directives []*Directive
+}
+
+// RootType returns the root type of the given operation type, or nil when
+// the schema does not define it.
+func (s *Schema) RootType(operation string) *TypeDefinition {
+	var name string
+	switch operation {
+	case Query:
+		name = s.QueryType
+	case Mutation:
+		name = s.MutationType
+	case Subscription:
+		name = s.SubscriptionType
+	}
+	return s.Types[name]
+}
+
+// ParseSchema parses a GraphQL schema document. Executable definitions are
+// rejected.
+func ParseSchema(src string) (*Schema, error) {
+	p := &parser{lex: lexer{src: src}}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	s := &Schema{Types: make(map[string]*TypeDefinition)}
+	roots := map[string]string{}
+	for p.tok.kind != tokenEOF {
+		if err := p.description(); err != nil {
+			return nil, err
+		}
+		if p.tok.kind != tokenName {
+			return nil, p.unexpected()
+		}
+		extend := p.tok.value == "extend"
+		if extend {
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			if p.tok.kind != tokenName {
+				return nil, p.unexpected()
+			}
+		}
+		pos := p.tok.pos
+		switch kw := p.tok.value; kw {
+		case "schema":
+			if err := p.schemaDefinition(roots); err != nil {
+				return nil, err
+			}
+		case "directive":
+			if extend {
+				return nil, p.unexpected()
+			}
+			if err := p.directiveDefinition(); err != nil {
+				return nil, err
+			}
+		case "scalar", "type", "interface", "union", "enum", "input":
+			t, err := p.typeDefinition()
+			if err != nil {
+				return nil, err
+			}
+			prev := s.Types[t.Name]
+			switch {
+			case prev == nil && !extend:
+				s.Types[t.Name] = t
+			case prev != nil && prev.Kind != t.Kind:
+				return nil, p.lex.errorf(pos, "%s %s conflicts with %s %s", kw, t.Name, prev.Kind, t.Name)
+			case prev != nil && !extend:
+				return nil, p.lex.errorf(pos, "type %s is defined more than once", t.Name)
+			case prev == nil:
+				// An extension may precede the definition it extends.
+				s.Types[t.Name] = t
+			default:
+				prev.Interfaces = append(prev.Interfaces, t.Interfaces...)
+				prev.Directives = append(prev.Directives, t.Directives...)
+				prev.Fields = append(prev.Fields, t.Fields...)
+				prev.InputFields = append(prev.InputFields, t.InputFields...)
+				prev.Members = append(prev.Members, t.Members...)
+				prev.EnumValues = append(prev.EnumValues, t.EnumValues...)
+			}
+		default:
+			return nil, p.unexpected()
+		}
+	}
+	s.QueryType, s.MutationType, s.SubscriptionType = "Query", "Mutation", "Subscription"
+	if len(roots) > 0 {
+		s.QueryType, s.MutationType, s.SubscriptionType = roots[Query], roots[Mutation], roots[Subscription]
+	}
+	for op, name := range roots {
+		if t := s.Types[name]; t == nil || t.Kind != "type" {
+			return nil, fmt.Errorf("%s root type %s is not an object type", op, name)
+		}
+	}
+	return s, nil
+}
+
+// description skips the optional description of a definition.
+func (p *parser) description() error {
+	if p.tok.kind == tokenString || p.tok.kind == tokenBlockString {
+		return p.advance()
+	}
+	return nil
+}
+
+func (p *parser) schemaDefinition(roots map[string]string) error {
+	if err := p.advance(); err != nil { // "schema"
+		return err
+	}
+	if _, err := p.directives(); err != nil {
+		return err
+	}
+	if ok, err := p.skipPunct("{"); err != nil || !ok {
+		return err
+	}
+	for !p.peekPunct("}") {
+		pos := p.tok.pos
+		op, err := p.name()
+		if err != nil {
+			return err
+		}
+		if op != Query && op != Mutation && op != Subscription {
+			return p.lex.errorf(pos, "unknown operation type %q", op)
+		}
+		if err := p.expectPunct(":"); err != nil {
+			return err
+		}
+		if roots[op], err = p.name(); err != nil {
+			return err
+		}
+	}
+	return p.advance()
+}
+
+func (p *parser) directiveDefinition() error {
+	if err := p.advance(); err != nil { // "directive"
+		return err
+	}
+	if err := p.expectPunct("@"); err != nil {
+		return err
+	}
+	if _, err := p.name(); err != nil {
+		return err
+	}
+	if _, err := p.argumentDefinitions(); err != nil {
+		return err
+	}
+	if p.tok.kind == tokenName && p.tok.value == "repeatable" {
+		if err := p.advance(); err != nil {
+			return err
+		}
+	}
+	if err := p.keyword("on"); err != nil {
+		return err
+	}
+	if _, err := p.skipPunct("|"); err != nil {
+		return err
+	}
+	for {
+		if _, err := p.name(); err != nil {
+			return err
+		}
+		if ok, err := p.skipPunct("|"); err != nil || !ok {
+			return err
+		}
+	}
+}
+
+func (p *parser) typeDefinition() (*TypeDefinition, error) {
+	t := &TypeDefinition{Kind: p.tok.value}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	var err error
+	if t.Name, err = p.name(); err != nil {
+		return nil, err
+	}
+	if (t.Kind == "type" || t.Kind == "interface") && p.tok.kind == tokenName && p.tok.value == "implements" {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if _, err := p.skipPunct("&"); err != nil {
+			return nil, err
+		}
+		for {
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			t.Interfaces = append(t.Interfaces, name)
+			if ok, err := p.skipPunct("&"); err != nil {
+				return nil, err
+			} else if !ok {
+				break
+			}
+		}
+	}
+	if t.Directives, err = p.directives(); err != nil {
+		return nil, err
+	}
+
+	switch t.Kind {
+	case "type", "interface":
+		if ok, err := p.skipPunct("{"); err != nil || !ok {
+			return t, err
+		}
+		for !p.peekPunct("}") {
+			f, err := p.fieldDefinition()
+			if err != nil {
+				return nil, err
+			}
+			t.Fields = append(t.Fields, f)
+		}
+		return t, p.advance()
+	case "input":
+		if ok, err := p.skipPunct("{"); err != nil || !ok {
+			return t, err
+		}
+		for !p.peekPunct("}") {
+			v, err := p.inputValueDefinition()
+			if err != nil {
+				return nil, err
+			}
+			t.InputFields = append(t.InputFields, v)
+		}
+		return t, p.advance()
+	case "union":
+		if ok, err := p.skipPunct("="); err != nil || !ok {
+			return t, err
+		}
+		if _, err := p.skipPunct("|"); err != nil {
+			return nil, err
+		}
+		for {
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			t.Members = append(t.Members, name)
+			if ok, err := p.skipPunct("|"); err != nil {
+				return nil, err
+			} else if !ok {
+				return t, nil
+			}
+		}
+	case "enum":
+		if ok, err := p.skipPunct("{"); err != nil || !ok {
+			return t, err
+		}
+		for !p.peekPunct("}") {
+			if err := p.description(); err != nil {
+				return nil, err
+			}
+			v := &EnumValueDefinition{}
+			if v.Name, err = p.name(); err != nil {
+				return nil, err
+			}
+			if v.Directives, err = p.directives(); err != nil {
+				return nil, err
+			}
+			t.EnumValues = append(t.EnumValues, v)
+		}
+		return t, p.advance()
+	}
+	return t, nil
+}
+
+func (p *parser) fieldDefinition() (*FieldDefinition, error) {
+	if err := p.description(); err != nil {
+		return nil, err
+	}
+	f := &FieldDefinition{}
+	var err error
+	if f.Name, err = p.name(); err != nil {
+		return nil, err
+	}
+	if f.Arguments, err = p.argumentDefinitions(); err != nil {
+		return nil, err
+	}
+	if err := p.expectPunct(":"); err != nil {
+		return nil, err
+	}
+	if f.Type, err = p.typeRef(0); err != nil {
+		return nil, err
+	}
+	if f.Directives, err = p.directives(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (p *parser) argumentDefinitions() ([]*InputValueDefinition, error) {
+	if ok, err := p.skipPunct("("); err != nil || !ok {
+		return nil, err
+	}
+	var args []*InputValueDefinition
+	for !p.peekPunct(")") {
+		v, err := p.inputValueDefinition()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, v)
+	}
+	return args, p.advance()
+}
+
+func (p *parser) inputValueDefinition() (*InputValueDefinition, error) {
+	if err := p.description(); err != nil {
+		return nil, err
+	}
+	v := &InputValueDefinition{}
+	var err error
+	if v.Name, err = p.name(); err != nil {
+		return nil, err
+	}
+	if err := p.expectPunct(":"); err != nil {
+		return nil, err
+	}
+	if v.Type, err = p.typeRef(0); err != nil {
+		return nil, err
+	}
+	if ok, err := p.skipPunct("="); err != nil {
+		return nil, err
+	} else if ok {
+		if v.Default, err = p.value(true, 0); err != nil {
+			return nil, err
+		}
+	}
+	if v.Directives, err = p.directives(); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
@@ -0,0 +1,103 @@
+package graphql
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseSchema(t *testing.T) {
+	src := `
+"""
+The schema.
+"""
+schema @link(url: "https://specs.apollo.dev/link/v1.0") { query: Root mutation: Mutation }
+
+directive @tag(name: String!) repeatable on FIELD_DEFINITION | OBJECT
+
+scalar DateTime @specifiedBy(url: "https://example.com")
+
+"A node."
+interface Node { id: ID! }
+
+type Root implements Node & Named @tag(name: "root") {
+  id: ID!
+  "Looks up a user."
+  user(id: ID!, "Whether to include drafts." drafts: Boolean = false): User
+  users(filter: UserFilter): [User!]!
+}
+
+type Mutation { touch: Boolean }
+
+extend type Root { search(q: String): [SearchResult] }
+
+union SearchResult = | User | Post
+
+enum Role { ADMIN @deprecated USER }
+
+input UserFilter { role: Role = USER, limit: Int }
+
+type User { id: ID! role: Role }
+type Post { id: ID! }
+`
+	s, err := ParseSchema(src)
+	if err != nil {
+		t.Fatalf("ParseSchema: %v", err)
+	}
+	if s.QueryType != "Root" || s.MutationType != "Mutation" || s.SubscriptionType != "" {
+		t.Errorf("unexpected root types %q %q %q", s.QueryType, s.MutationType, s.SubscriptionType)
+	}
+	root := s.RootType(Query)
+	if root == nil || len(root.Fields) != 4 || root.Field("search") == nil {
+		t.Fatalf("unexpected query type %+v", root)
+	}
+	if len(root.Interfaces) != 2 || len(root.Directives) != 1 || root.Directives[0].Name != "tag" {
+		t.Errorf("unexpected interfaces or directives %v %v", root.Interfaces, root.Directives)
+	}
+	user := root.Field("user")
+	if len(user.Arguments) != 2 || user.Arguments[1].Default.Raw != "false" || user.Type.String() != "User" {
+		t.Errorf("unexpected user field %+v", user)
+	}
+	if typ := root.Field("users").Type.String(); typ != "[User!]!" {
+		t.Errorf("users type = %s", typ)
+	}
+	if u := s.Types["SearchResult"]; u.Kind != "union" || strings.Join(u.Members, ",") != "User,Post" {
+		t.Errorf("unexpected union %+v", u)
+	}
+	if e := s.Types["Role"]; e.Kind != "enum" || len(e.EnumValues) != 2 || len(e.EnumValues[0].Directives) != 1 {
+		t.Errorf("unexpected enum %+v", e)
+	}
+	if in := s.Types["UserFilter"]; in.Kind != "input" || len(in.InputFields) != 2 || in.InputFields[0].Default.Raw != "USER" {
+		t.Errorf("unexpected input %+v", in)
+	}
+	if s.RootType(Subscription) != nil {
+		t.Error("expected no subscription root type")
+	}
+}
+
+func TestParseSchema_DefaultRoots(t *testing.T) {
+	s, err := ParseSchema(`type Query { a: Int } type Subscription { b: Int }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.RootType(Query) == nil || s.RootType(Subscription) == nil || s.RootType(Mutation) != nil {
+		t.Errorf("unexpected root types %+v", s)
+	}
+}
+
+func TestParseSchema_Errors(t *testing.T) {
+	tests := map[string]string{
+		"{ a }":                               `unexpected punctuator "{"`,
+		"query { a }":                         `unexpected name "query"`,
+		"type A { a: Int } type A { b: Int }": "defined more than once",
+		"type A { a: Int } extend input A { b: Int }": "conflicts with",
+		"schema { query: Missing }":                   "query root type Missing is not an object type",
+		"schema { other: A } type A { a: Int }":       `unknown operation type "other"`,
+		"type A { a Int }":                            `expected ":"`,
+		"directive @d on":                             "expected name",
+	}
+	for src, want := range tests {
+		if _, err := ParseSchema(src); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("ParseSchema(%q) = %v, want error containing %q", src, err, want)
+		}
+	}
+}
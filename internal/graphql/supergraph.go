@@ -0,0 +1,341 @@
+package graphql
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Supergraph is a federated schema composed from subgraphs. Subgraphs are
+// described with the join spec (https://specs.apollo.dev/join): the values
+// of the join__Graph enum name them through @join__graph, and @join__type
+// and @join__field assign types and fields to them.
+type Supergraph struct {
+	Schema *Schema
+	// Graphs lists the subgraphs in the order the join__Graph enum declares
+	// them.
+	Graphs []SupergraphGraph
+	byEnum map[string]string // join__Graph value to subgraph name
+}
+
+// SupergraphGraph is one subgraph of a supergraph.
+type SupergraphGraph struct {
+	Name string
+	URL  string
+}
+
+// ParseSupergraph parses a supergraph schema document.
+func ParseSupergraph(src string) (*Supergraph, error) {
+	schema, err := ParseSchema(src)
+	if err != nil {
+		return nil, err
+	}
+	enum := schema.Types["join__Graph"]
+	if enum == nil || enum.Kind != "enum" {
+		return nil, fmt.Errorf("supergraph does not define the join__Graph enum")
+	}
+	sg := &Supergraph{Schema: schema, byEnum: make(map[string]string, len(enum.EnumValues))}
+	for _, v := range enum.EnumValues {
+		d := findDirective(v.Directives, "join__graph")
+		if d == nil {
+			return nil, fmt.Errorf("join__Graph value %s has no @join__graph directive", v.Name)
+		}
+		g := SupergraphGraph{Name: stringArgument(d, "name"), URL: stringArgument(d, "url")}
+		if g.Name == "" {
+			return nil, fmt.Errorf("join__Graph value %s: @join__graph requires a name", v.Name)
+		}
+		sg.Graphs = append(sg.Graphs, g)
+		sg.byEnum[v.Name] = g.Name
+	}
+	if len(sg.Graphs) == 0 {
+		return nil, fmt.Errorf("supergraph declares no subgraphs")
+	}
+	if schema.RootType(Query) == nil {
+		return nil, fmt.Errorf("supergraph does not define a query root type")
+	}
+	return sg, nil
+}
+
+// owner returns the subgraph resolving field name of the root type t. A
+// field resolvable by several subgraphs goes to the first one listed.
+func (sg *Supergraph) owner(t *TypeDefinition, name string) (string, error) {
+	f := t.Field(name)
+	if f == nil {
+		return "", fmt.Errorf("cannot query field %q on type %q", name, t.Name)
+	}
+	for _, d := range f.Directives {
+		if d.Name != "join__field" || booleanArgument(d, "external") {
+			continue
+		}
+		if g, ok := sg.graphArgument(d); ok {
+			return g, nil
+		}
+	}
+	// Without @join__field, every subgraph defining the type resolves it.
+	for _, d := range t.Directives {
+		if d.Name != "join__type" {
+			continue
+		}
+		if g, ok := sg.graphArgument(d); ok {
+			return g, nil
+		}
+	}
+	return "", fmt.Errorf("field %q on type %q is not resolved by any subgraph", name, t.Name)
+}
+
+func (sg *Supergraph) graphArgument(d *Directive) (string, bool) {
+	for _, a := range d.Arguments {
+		if a.Name == "graph" && a.Value.Kind == EnumValue {
+			g, ok := sg.byEnum[a.Value.Raw]
+			return g, ok
+		}
+	}
+	return "", false
+}
+
+func findDirective(dirs []*Directive, name string) *Directive {
+	for _, d := range dirs {
+		if d.Name == name {
+			return d
+		}
+	}
+	return nil
+}
+
+func stringArgument(d *Directive, name string) string {
+	for _, a := range d.Arguments {
+		if a.Name == name && a.Value.Kind == StringValue {
+			return a.Value.Raw
+		}
+	}
+	return ""
+}
+
+func booleanArgument(d *Directive, name string) bool {
+	for _, a := range d.Arguments {
+		if a.Name == name {
+			return a.Value.Kind == BooleanValue && a.Value.Raw == "true"
+		}
+	}
+	return false
+}
+
+// QueryPlan splits an operation on a supergraph into fetches of its root
+// fields from the subgraphs resolving them. Results of nested fields must be
+// resolved by the subgraph owning the root field: entities are not joined
+// across subgraphs.
+type QueryPlan struct {
+	// Fetches are executed concurrently for queries and in order for
+	// mutations, whose root fields must run serially.
+	Fetches []*Fetch
+	// Keys lists the response keys of the root fields in document order.
+	Keys []string
+}
+
+// Fetch is the operation sent to one subgraph.
+type Fetch struct {
+	Graph    string
+	Document *Document
+	// Variables names the operation variables the document uses.
+	Variables []string
+	// Keys lists the response keys of the root fields it resolves.
+	Keys []string
+}
+
+// Plan splits op of doc by the subgraphs resolving its root fields.
+// Fragments spread on the root type are inlined so that each subgraph only
+// receives the fields it owns; __typename goes along with the first fetch.
+func (sg *Supergraph) Plan(doc *Document, op *Operation) (*QueryPlan, error) {
+	if op.Type == Subscription {
+		return nil, fmt.Errorf("subscriptions cannot be planned across subgraphs")
+	}
+	root := sg.Schema.RootType(op.Type)
+	if root == nil {
+		return nil, fmt.Errorf("supergraph does not support %s operations", op.Type)
+	}
+	pl := &planner{sg: sg, doc: doc, root: root, active: map[string]bool{}, keys: map[string]bool{}}
+	parts, err := pl.split(op.SelectionSet)
+	if err != nil {
+		return nil, err
+	}
+
+	// Queries fetch each subgraph once; mutations keep the order of their
+	// root fields, fetching again whenever the subgraph changes.
+	plan := &QueryPlan{Keys: pl.order}
+	var fetches []*Fetch
+	byGraph := map[string]*Fetch{}
+	for _, part := range parts {
+		f := byGraph[part.graph]
+		if op.Type == Mutation {
+			f = nil
+			if n := len(fetches); n > 0 && fetches[n-1].Graph == part.graph {
+				f = fetches[n-1]
+			}
+		}
+		if f == nil {
+			f = &Fetch{Graph: part.graph, Document: &Document{Operations: []*Operation{{Type: op.Type, Name: op.Name, Directives: op.Directives}}}}
+			fetches = append(fetches, f)
+			byGraph[part.graph] = f
+		}
+		o := f.Document.Operations[0]
+		o.SelectionSet = append(o.SelectionSet, part.sel)
+		f.Keys = append(f.Keys, part.keys...)
+	}
+	if len(fetches) == 0 {
+		// Only __typename was selected.
+		fetches = append(fetches, &Fetch{Graph: sg.Graphs[0].Name, Document: &Document{Operations: []*Operation{{Type: op.Type, Name: op.Name, Directives: op.Directives, SelectionSet: pl.typename}}}, Keys: pl.typenameKeys})
+	} else {
+		o := fetches[0].Document.Operations[0]
+		o.SelectionSet = append(o.SelectionSet, pl.typename...)
+		fetches[0].Keys = append(fetches[0].Keys, pl.typenameKeys...)
+	}
+	for _, f := range fetches {
+		o := f.Document.Operations[0]
+		used := map[string]bool{}
+		fragments := map[string]bool{}
+		collectVariables(doc, o.Directives, o.SelectionSet, used, fragments)
+		for _, v := range op.Variables {
+			if used[v.Name] {
+				o.Variables = append(o.Variables, v)
+				f.Variables = append(f.Variables, v.Name)
+			}
+		}
+		for _, frag := range doc.Fragments {
+			if fragments[frag.Name] {
+				f.Document.Fragments = append(f.Document.Fragments, frag)
+			}
+		}
+	}
+	plan.Fetches = fetches
+	return plan, nil
+}
+
+type planner struct {
+	sg           *Supergraph
+	doc          *Document
+	root         *TypeDefinition
+	active       map[string]bool // fragments being expanded
+	keys         map[string]bool
+	order        []string
+	typename     []Selection
+	typenameKeys []string
+}
+
+// planPart is a root selection, or the part of a root fragment, resolved by
+// a single subgraph.
+type planPart struct {
+	graph string
+	sel   Selection
+	keys  []string
+}
+
+// split partitions the root selections set by subgraph, keeping their order.
+// Fragments are split into one inline fragment per subgraph.
+func (pl *planner) split(set []Selection) ([]planPart, error) {
+	var parts []planPart
+	for _, sel := range set {
+		switch s := sel.(type) {
+		case *Field:
+			key := s.ResponseKey()
+			if !pl.keys[key] {
+				pl.keys[key] = true
+				pl.order = append(pl.order, key)
+			}
+			if s.Name == "__typename" {
+				pl.typename = append(pl.typename, s)
+				pl.typenameKeys = append(pl.typenameKeys, key)
+				continue
+			}
+			if strings.HasPrefix(s.Name, "__") {
+				return nil, fmt.Errorf("%s cannot be queried across subgraphs", s.Name)
+			}
+			graph, err := pl.sg.owner(pl.root, s.Name)
+			if err != nil {
+				return nil, err
+			}
+			parts = append(parts, planPart{graph: graph, sel: s, keys: []string{key}})
+		case *InlineFragment:
+			sub, err := pl.split(s.SelectionSet)
+			if err != nil {
+				return nil, err
+			}
+			parts = append(parts, regroup(sub, s.TypeCondition, s.Directives)...)
+		case *FragmentSpread:
+			frag := pl.doc.Fragment(s.Name)
+			if frag == nil {
+				return nil, fmt.Errorf("unknown fragment %q", s.Name)
+			}
+			if pl.active[s.Name] {
+				return nil, fmt.Errorf("fragment %q spreads itself", s.Name)
+			}
+			pl.active[s.Name] = true
+			sub, err := pl.split(frag.SelectionSet)
+			delete(pl.active, s.Name)
+			if err != nil {
+				return nil, err
+			}
+			parts = append(parts, regroup(sub, frag.TypeCondition, s.Directives)...)
+		}
+	}
+	return parts, nil
+}
+
+// regroup wraps the parts of a split fragment into one inline fragment per
+// subgraph, so the fragment's directives apply to each of them.
+func regroup(parts []planPart, typeCondition string, dirs []*Directive) []planPart {
+	var out []planPart
+	idx := map[string]int{}
+	for _, p := range parts {
+		i, ok := idx[p.graph]
+		if !ok {
+			i = len(out)
+			idx[p.graph] = i
+			out = append(out, planPart{graph: p.graph, sel: &InlineFragment{TypeCondition: typeCondition, Directives: dirs}})
+		}
+		frag := out[i].sel.(*InlineFragment)
+		frag.SelectionSet = append(frag.SelectionSet, p.sel)
+		out[i].keys = append(out[i].keys, p.keys...)
+	}
+	return out
+}
+
+// collectVariables records the variables and fragments used by set and its
+// directives, following fragment spreads.
+func collectVariables(doc *Document, dirs []*Directive, set []Selection, vars, fragments map[string]bool) {
+	for _, d := range dirs {
+		for _, a := range d.Arguments {
+			collectValueVariables(a.Value, vars)
+		}
+	}
+	for _, sel := range set {
+		switch s := sel.(type) {
+		case *Field:
+			for _, a := range s.Arguments {
+				collectValueVariables(a.Value, vars)
+			}
+			collectVariables(doc, s.Directives, s.SelectionSet, vars, fragments)
+		case *InlineFragment:
+			collectVariables(doc, s.Directives, s.SelectionSet, vars, fragments)
+		case *FragmentSpread:
+			collectVariables(doc, s.Directives, nil, vars, fragments)
+			if frag := doc.Fragment(s.Name); frag != nil && !fragments[s.Name] {
+				fragments[s.Name] = true
+				collectVariables(doc, frag.Directives, frag.SelectionSet, vars, fragments)
+			}
+		}
+	}
+}
+
+func collectValueVariables(v *Value, vars map[string]bool) {
+	switch v.Kind {
+	case VariableValue:
+		vars[v.Raw] = true
+	case ListValue:
+		for _, item := range v.List {
+			collectValueVariables(item, vars)
+		}
+	case ObjectValue:
+		for _, f := range v.Fields {
+			collectValueVariables(f.Value, vars)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package graphql
+
+import (
+	"strings"
+	"testing"
+)
+
+const testSupergraph = `
+schema
+  @link(url: "https://specs.apollo.dev/link/v1.0")
+  @link(url: "https://specs.apollo.dev/join/v0.3", for: EXECUTION)
+{
+  query: Query
+  mutation: Mutation
+}
+
+directive @join__field(graph: join__Graph, requires: join__FieldSet, provides: join__FieldSet, type: String, external: Boolean, override: String, usedOverridden: Boolean) repeatable on FIELD_DEFINITION | INPUT_FIELD_DEFINITION
+directive @join__graph(name: String!, url: String!) on ENUM_VALUE
+directive @join__type(graph: join__Graph!, key: join__FieldSet, extension: Boolean! = false, resolvable: Boolean! = true, isInterfaceObject: Boolean! = false) repeatable on OBJECT | INTERFACE | UNION | ENUM | INPUT_OBJECT | SCALAR
+directive @link(url: String, as: String, for: link__Purpose, import: [link__Import]) repeatable on SCHEMA
+
+scalar join__FieldSet
+scalar link__Import
+
+enum link__Purpose {
+  """Security features."""
+  SECURITY
+  EXECUTION
+}
+
+enum join__Graph {
+  ACCOUNTS @join__graph(name: "accounts", url: "http://accounts:4001/graphql")
+  PRODUCTS @join__graph(name: "products", url: "http://products:4002/graphql")
+}
+
+type Query
+  @join__type(graph: ACCOUNTS)
+  @join__type(graph: PRODUCTS)
+{
+  me: User @join__field(graph: ACCOUNTS)
+  user(id: ID!): User @join__field(graph: ACCOUNTS)
+  topProducts(first: Int = 5): [Product] @join__field(graph: PRODUCTS)
+  version: String
+}
+
+type Mutation
+  @join__type(graph: ACCOUNTS)
+  @join__type(graph: PRODUCTS)
+{
+  login(name: String!): User @join__field(graph: ACCOUNTS)
+  addProduct(upc: String!): Product @join__field(graph: PRODUCTS)
+}
+
+type User @join__type(graph: ACCOUNTS, key: "id") {
+  id: ID!
+  name: String
+}
+
+type Product @join__type(graph: PRODUCTS, key: "upc") {
+  upc: String!
+  name: String
+}
+`
+
+func TestParseSupergraph(t *testing.T) {
+	sg, err := ParseSupergraph(testSupergraph)
+	if err != nil {
+		t.Fatalf("ParseSupergraph: %v", err)
+	}
+	if len(sg.Graphs) != 2 || sg.Graphs[0] != (SupergraphGraph{Name: "accounts", URL: "http://accounts:4001/graphql"}) {
+		t.Errorf("unexpected graphs %+v", sg.Graphs)
+	}
+
+	for src, want := range map[string]string{
+		`type Query { a: Int }`:                                            "join__Graph",
+		`enum join__Graph { A } type Query { a: Int }`:                     "no @join__graph directive",
+		`enum join__Graph { A @join__graph(name: "a", url: "") } scalar X`: "query root type",
+	} {
+		if _, err := ParseSupergraph(src); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("ParseSupergraph(%q) = %v, want error containing %q", src, err, want)
+		}
+	}
+}
+
+func planFor(t *testing.T, query string) *QueryPlan {
+	t.Helper()
+	sg, err := ParseSupergraph(testSupergraph)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := Parse(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan, err := sg.Plan(doc, doc.Operations[0])
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+	return plan
+}
+
+func TestPlan_Query(t *testing.T) {
+	plan := planFor(t, `query Home($id: ID!, $n: Int, $unused: Int) {
+		__typename
+		products: topProducts(first: $n) { ...P }
+		...Root
+		version
+	}
+	fragment Root on Query { me { ...U } other: user(id: $id) @include(if: true) { id } top: topProducts { upc } }
+	fragment U on User { id name }
+	fragment P on Product { upc name }`)
+
+	if got := strings.Join(plan.Keys, ","); got != "__typename,products,me,other,top,version" {
+		t.Errorf("keys = %s", got)
+	}
+	if len(plan.Fetches) != 2 {
+		t.Fatalf("expected 2 fetches, got %d", len(plan.Fetches))
+	}
+	products, accounts := plan.Fetches[0], plan.Fetches[1]
+	if products.Graph != "products" || accounts.Graph != "accounts" {
+		t.Fatalf("unexpected fetch order %s, %s", products.Graph, accounts.Graph)
+	}
+	if got := Print(products.Document); got != `query Home($n: Int) { products: topProducts(first: $n) { ...P } ... on Query { top: topProducts { upc } } __typename } fragment P on Product { upc name }` {
+		t.Errorf("products document = %s", got)
+	}
+	if strings.Join(products.Variables, ",") != "n" || strings.Join(products.Keys, ",") != "products,top,__typename" {
+		t.Errorf("unexpected products fetch %v %v", products.Variables, products.Keys)
+	}
+	// Fields without @join__field go to the first subgraph defining the type.
+	if got := Print(accounts.Document); got != `query Home($id: ID!) { ... on Query { me { ...U } other: user(id: $id) @include(if: true) { id } } version } fragment U on User { id name }` {
+		t.Errorf("accounts document = %s", got)
+	}
+}
+
+func TestPlan_Mutation(t *testing.T) {
+	plan := planFor(t, `mutation { a: login(name: "a") b: login(name: "b") addProduct(upc: "1") { upc } c: login(name: "c") }`)
+	var graphs []string
+	for _, f := range plan.Fetches {
+		graphs = append(graphs, f.Graph+":"+strings.Join(f.Keys, "+"))
+	}
+	if got := strings.Join(graphs, ","); got != "accounts:a+b,products:addProduct,accounts:c" {
+		t.Errorf("mutation fetches = %s", got)
+	}
+}
+
+func TestPlan_Typename(t *testing.T) {
+	plan := planFor(t, `{ t: __typename }`)
+	if len(plan.Fetches) != 1 || plan.Fetches[0].Graph != "accounts" || Print(plan.Fetches[0].Document) != "query { t: __typename }" {
+		t.Errorf("unexpected plan %+v", plan.Fetches)
+	}
+}
+
+func TestPlan_Errors(t *testing.T) {
+	sg, err := ParseSupergraph(testSupergraph)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for query, want := range map[string]string{
+		`{ nope }`:                              `cannot query field "nope" on type "Query"`,
+		`{ __schema { types { name } } }`:       "__schema cannot be queried across subgraphs",
+		`{ ...Missing }`:                        `unknown fragment "Missing"`,
+		`{ ...A } fragment A on Query { ...A }`: `fragment "A" spreads itself`,
+		`subscription { me { id } }`:            "subscriptions",
+	} {
+		doc, err := Parse(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := sg.Plan(doc, doc.Operations[0]); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Plan(%q) = %v, want error containing %q", query, err, want)
+		}
+	}
+}
@@ -8,7 +8,8 @@ import (
 
 // Checker provides health and readiness check endpoints.
 type Checker struct {
-	ready atomic.Bool
+	ready    atomic.Bool
+	degraded atomic.Pointer[string]
 }
 
 // NewChecker creates a new health checker.
@@ -21,6 +22,17 @@ func (c *Checker) SetReady(ready bool) {
 	c.ready.Store(ready)
 }
 
+// SetDegraded marks the service as degraded for reason, which names what
+// degraded it; "" clears it. A degraded service is not ready, so that load
+// balancers move traffic elsewhere until it recovers.
+func (c *Checker) SetDegraded(reason string) {
+	if reason == "" {
+		c.degraded.Store(nil)
+		return
+	}
+	c.degraded.Store(&reason)
+}
+
 // HealthzHandler returns a handler for the /healthz endpoint (liveness).
 func (c *Checker) HealthzHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -34,7 +46,10 @@ func (c *Checker) HealthzHandler() http.HandlerFunc {
 func (c *Checker) ReadyzHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
-		if c.ready.Load() {
+		if reason := c.degraded.Load(); reason != nil && c.ready.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			json.NewEncoder(w).Encode(map[string]string{"status": "degraded", "reason": *reason})
+		} else if c.ready.Load() {
 			w.WriteHeader(http.StatusOK)
 			json.NewEncoder(w).Encode(map[string]string{"status": "ready"})
 		} else {
@@ -87,3 +87,25 @@ func TestReadyzToggle(t *testing.T) {
 		t.Errorf("expected 503 after SetReady(false), got %d", rr.Code)
 	}
 }
+
+func TestReadyzDegraded(t *testing.T) {
+	checker := NewChecker()
+	checker.SetReady(true)
+	checker.SetDegraded("max_heap")
+	handler := checker.ReadyzHandler()
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/readyz", nil))
+	var body map[string]string
+	json.NewDecoder(rr.Body).Decode(&body)
+	if rr.Code != http.StatusServiceUnavailable || body["status"] != "degraded" || body["reason"] != "max_heap" {
+		t.Errorf("degraded readyz: %d %v", rr.Code, body)
+	}
+
+	checker.SetDegraded("")
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/readyz", nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected 200 after recovery, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,114 @@
+// Package httperror writes the error responses of the gateway. Errors are
+// answered with a JSON envelope carrying an error code, a message, the
+// request ID and the matched route, so that clients and support can tell
+// gateway errors apart and trace them; clients preferring HTML, such as
+// browsers, get the same information as a small HTML page.
+package httperror
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/oriys/nexus/internal/reqctx"
+)
+
+// Common error codes.
+const (
+	CodeNoRoute             = "no_route"
+	CodeMethodNotAllowed    = "method_not_allowed"
+	CodeBadGateway          = "bad_gateway"
+	CodeUpstreamUnavailable = "upstream_unavailable"
+	CodeInternal            = "internal_error"
+	CodeNotConfigured       = "not_configured"
+	CodeOverloaded          = "overloaded"
+)
+
+// Response is the JSON envelope of gateway errors.
+type Response struct {
+	// Error is the code of the error, such as "no_route".
+	Error     string `json:"error"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id,omitempty"`
+	Route     string `json:"route,omitempty"`
+}
+
+// Write answers r with status, code and message. The request ID and the
+// route are taken from the request context of r. Headers set on w before,
+// such as Retry-After, are kept.
+func Write(w http.ResponseWriter, r *http.Request, status int, code, message string) {
+	resp := Response{Error: code, Message: message}
+	if r != nil {
+		resp.RequestID, _ = reqctx.RequestID.Get(r.Context())
+		if resp.RequestID == "" {
+			resp.RequestID = r.Header.Get("X-Request-ID")
+		}
+		resp.Route, _ = reqctx.Route.Get(r.Context())
+	}
+	h := w.Header()
+	h.Del("Content-Length")
+	h.Set("X-Content-Type-Options", "nosniff")
+	if r != nil && prefersHTML(r.Header.Values("Accept")) {
+		h.Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(status)
+		writeHTML(w, status, resp)
+		return
+	}
+	h.Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// CodeFor returns the error code of status, its status text in snake
+// case: "forbidden" for 403.
+func CodeFor(status int) string {
+	text := http.StatusText(status)
+	if text == "" {
+		return "error_" + strconv.Itoa(status)
+	}
+	text = strings.NewReplacer(" ", "_", "-", "_", "'", "").Replace(text)
+	return strings.ToLower(text)
+}
+
+// prefersHTML reports whether the Accept header values rank text/html
+// above JSON. Clients without an Accept header, or accepting anything
+// equally, get JSON.
+func prefersHTML(accept []string) bool {
+	var htmlQ, jsonQ float64
+	for _, v := range accept {
+		for _, item := range strings.Split(v, ",") {
+			mt, params, _ := strings.Cut(strings.TrimSpace(item), ";")
+			mt = strings.ToLower(strings.TrimSpace(mt))
+			q := 1.0
+			for _, p := range strings.Split(params, ";") {
+				if v, ok := strings.CutPrefix(strings.TrimSpace(p), "q="); ok {
+					if f, err := strconv.ParseFloat(v, 64); err == nil {
+						q = f
+					}
+				}
+			}
+			switch mt {
+			case "text/html", "application/xhtml+xml":
+				htmlQ = max(htmlQ, q)
+			case "application/json", "application/*":
+				jsonQ = max(jsonQ, q)
+			case "*/*":
+				htmlQ = max(htmlQ, q)
+				jsonQ = max(jsonQ, q)
+			}
+		}
+	}
+	return htmlQ > jsonQ
+}
+
+func writeHTML(w http.ResponseWriter, status int, resp Response) {
+	title := html.EscapeString(strconv.Itoa(status) + " " + http.StatusText(status))
+	fmt.Fprintf(w, "<!DOCTYPE html>\n<html><head><meta charset=\"utf-8\"><title>%s</title></head>\n<body><h1>%s</h1>\n<p>%s</p>\n", title, title, html.EscapeString(resp.Message))
+	if resp.RequestID != "" {
+		fmt.Fprintf(w, "<p><small>Request ID: %s</small></p>\n", html.EscapeString(resp.RequestID))
+	}
+	fmt.Fprint(w, "</body></html>\n")
+}
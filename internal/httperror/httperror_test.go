@@ -0,0 +1,97 @@
+package httperror
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/oriys/nexus/internal/reqctx"
+)
+
+func TestWrite_JSON(t *testing.T) {
+	r, values := reqctx.AttachRequest(httptest.NewRequest("GET", "/orders", nil))
+	reqctx.Set(values, reqctx.RequestID, "req-1")
+	reqctx.Set(values, reqctx.Route, "orders")
+	w := httptest.NewRecorder()
+	w.Header().Set("Retry-After", "1")
+	w.Header().Set("Content-Length", "42")
+	Write(w, r, http.StatusBadGateway, CodeBadGateway, "bad gateway")
+
+	if w.Code != http.StatusBadGateway || w.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("got %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	if w.Header().Get("Retry-After") != "1" || w.Header().Get("Content-Length") != "" {
+		t.Errorf("headers %v", w.Header())
+	}
+	var resp Response
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp != (Response{Error: "bad_gateway", Message: "bad gateway", RequestID: "req-1", Route: "orders"}) {
+		t.Errorf("response %+v", resp)
+	}
+
+	// Before the middleware runs, the request ID comes from the header.
+	r = httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("X-Request-ID", "req-2")
+	w = httptest.NewRecorder()
+	Write(w, r, http.StatusNotFound, CodeNoRoute, "no matching route")
+	if want := `{"error":"no_route","message":"no matching route","request_id":"req-2"}` + "\n"; w.Body.String() != want {
+		t.Errorf("body %q, want %q", w.Body.String(), want)
+	}
+}
+
+func TestWrite_HTML(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+	r.Header.Set("X-Request-ID", "<id>")
+	w := httptest.NewRecorder()
+	Write(w, r, http.StatusServiceUnavailable, CodeOverloaded, "retry <later>")
+	body := w.Body.String()
+	if w.Code != http.StatusServiceUnavailable || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") {
+		t.Fatalf("got %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	for _, want := range []string{"<title>503 Service Unavailable</title>", "retry &lt;later&gt;", "Request ID: &lt;id&gt;"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("body lacks %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestPrefersHTML(t *testing.T) {
+	for _, tt := range []struct {
+		accept string
+		want   bool
+	}{
+		{"", false},
+		{"*/*", false},
+		{"application/json", false},
+		{"text/html", true},
+		{"text/html;q=0.5, application/json", false},
+		{"application/json;q=0.5, text/html", true},
+		{"text/html, */*;q=0.8", true},
+	} {
+		var accept []string
+		if tt.accept != "" {
+			accept = []string{tt.accept}
+		}
+		if got := prefersHTML(accept); got != tt.want {
+			t.Errorf("prefersHTML(%q) = %v, want %v", tt.accept, got, tt.want)
+		}
+	}
+}
+
+func TestCodeFor(t *testing.T) {
+	for status, want := range map[int]string{
+		http.StatusForbidden:             "forbidden",
+		http.StatusRequestEntityTooLarge: "request_entity_too_large",
+		http.StatusTeapot:                "im_a_teapot",
+		599:                              "error_599",
+	} {
+		if got := CodeFor(status); got != want {
+			t.Errorf("CodeFor(%d) = %q, want %q", status, got, want)
+		}
+	}
+}
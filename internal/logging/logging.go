@@ -0,0 +1,149 @@
+// Package logging builds the gateway's log handler from the logging
+// configuration: its format, a level that can change while the gateway
+// runs, and the sampling of repeated errors.
+package logging
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/oriys/nexus/internal/config"
+)
+
+// level is the level of every handler NewHandler returns.
+var level slog.LevelVar
+
+// configured is the level the configuration last set. Reloads change the
+// level only when logging.level changes, so that a level set through the
+// admin API survives reloads that leave it alone.
+var configured struct {
+	sync.Mutex
+	level slog.Level
+	set   bool
+}
+
+// dropped counts the records sampling dropped.
+var dropped atomic.Uint64
+
+// NewHandler returns a handler writing records to w in the format of cfg,
+// at the shared level, which it sets to the configured one.
+func NewHandler(w io.Writer, cfg *config.LoggingConfig) slog.Handler {
+	Configure(cfg)
+	opts := &slog.HandlerOptions{Level: &level}
+	var h slog.Handler
+	if cfg.Format == "text" {
+		h = slog.NewTextHandler(w, opts)
+	} else {
+		h = slog.NewJSONHandler(w, opts)
+	}
+	if cfg.Sampling != nil {
+		h = newSampler(h, cfg.Sampling)
+	}
+	return h
+}
+
+// Configure applies the level of a loaded or reloaded configuration, if
+// it differs from the level the previous one set. Format and sampling
+// apply when the handler is built.
+func Configure(cfg *config.LoggingConfig) {
+	next := cfg.LogLevel()
+	configured.Lock()
+	defer configured.Unlock()
+	if configured.set && configured.level == next {
+		return
+	}
+	configured.level, configured.set = next, true
+	level.Set(next)
+}
+
+// Level returns the current level.
+func Level() slog.Level {
+	return level.Level()
+}
+
+// SetLevel changes the current level until the configured level changes.
+func SetLevel(l slog.Level) {
+	level.Set(l)
+}
+
+// sampler drops error records past the sampling limits of their message.
+type sampler struct {
+	next  slog.Handler
+	state *samplerState
+}
+
+// samplerState counts the records per message of the current interval. It
+// is shared by the handlers WithAttrs and WithGroup derive.
+type samplerState struct {
+	interval   time.Duration
+	first      int
+	thereafter int
+	now        func() time.Time
+
+	mu     sync.Mutex
+	window time.Time
+	counts map[string]int
+}
+
+func newSampler(next slog.Handler, cfg *config.LogSampling) *sampler {
+	return &sampler{next: next, state: &samplerState{
+		interval:   cfg.SampleInterval(),
+		first:      cfg.First,
+		thereafter: cfg.Thereafter,
+		now:        time.Now,
+		counts:     make(map[string]int),
+	}}
+}
+
+// allow reports whether a record with msg is logged.
+func (s *samplerState) allow(msg string) bool {
+	now := s.now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now.Sub(s.window) >= s.interval {
+		s.window = now
+		clear(s.counts)
+	}
+	n := s.counts[msg] + 1
+	s.counts[msg] = n
+	if n <= s.first {
+		return true
+	}
+	return s.thereafter > 0 && (n-s.first)%s.thereafter == 0
+}
+
+func (h *sampler) Enabled(ctx context.Context, l slog.Level) bool {
+	return h.next.Enabled(ctx, l)
+}
+
+func (h *sampler) Handle(ctx context.Context, rec slog.Record) error {
+	if rec.Level >= slog.LevelError && !h.state.allow(rec.Message) {
+		dropped.Add(1)
+		return nil
+	}
+	return h.next.Handle(ctx, rec)
+}
+
+func (h *sampler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &sampler{next: h.next.WithAttrs(attrs), state: h.state}
+}
+
+func (h *sampler) WithGroup(name string) slog.Handler {
+	return &sampler{next: h.next.WithGroup(name), state: h.state}
+}
+
+// WriteMetrics writes the logging metrics in the Prometheus text
+// exposition format.
+func WriteMetrics(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "# HELP nexus_log_records_dropped_total Error log records dropped by sampling.\n# TYPE nexus_log_records_dropped_total counter\n")
+	fmt.Fprintf(bw, "nexus_log_records_dropped_total %s\n", strconv.FormatUint(dropped.Load(), 10))
+	return bw.Flush()
+}
@@ -0,0 +1,75 @@
+package logging
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oriys/nexus/internal/config"
+)
+
+func TestNewHandler(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewHandler(&buf, &config.LoggingConfig{Level: "warn", Format: "text"}))
+	logger.Info("hidden")
+	logger.Warn("shown", slog.String("k", "v"))
+	if got := buf.String(); strings.Contains(got, "hidden") || !strings.Contains(got, "level=WARN msg=shown k=v") {
+		t.Errorf("text output %q", got)
+	}
+
+	// A level set at runtime stays until the configured level changes.
+	SetLevel(slog.LevelDebug)
+	Configure(&config.LoggingConfig{Level: "warn"})
+	if Level() != slog.LevelDebug {
+		t.Errorf("reload kept the level at %v", Level())
+	}
+	Configure(&config.LoggingConfig{Level: "error"})
+	if Level() != slog.LevelError {
+		t.Errorf("reload set the level to %v", Level())
+	}
+
+	buf.Reset()
+	logger = slog.New(NewHandler(&buf, &config.LoggingConfig{}))
+	logger.Info("json")
+	if Level() != slog.LevelInfo || !strings.HasPrefix(buf.String(), `{"time":`) {
+		t.Errorf("level %v, json output %q", Level(), buf.String())
+	}
+}
+
+func TestSampler(t *testing.T) {
+	var buf bytes.Buffer
+	h := newSampler(slog.NewJSONHandler(&buf, nil), &config.LogSampling{First: 2, Thereafter: 3})
+	now := time.Unix(1000, 0)
+	h.state.now = func() time.Time { return now }
+	logger := slog.New(h).With(slog.String("component", "test"))
+	before := dropped.Load()
+
+	for range 8 {
+		logger.Error("upstream failed")
+	}
+	logger.Error("other")
+	logger.Warn("upstream failed")
+	// Records 1, 2, 5 and 8 of the message pass, plus the other two.
+	if n := strings.Count(buf.String(), "\n"); n != 6 {
+		t.Errorf("logged %d records:\n%s", n, buf.String())
+	}
+	if n := dropped.Load() - before; n != 4 {
+		t.Errorf("dropped %d records", n)
+	}
+
+	// A new interval starts the counts over.
+	buf.Reset()
+	now = now.Add(time.Second)
+	logger.Error("upstream failed")
+	if buf.Len() == 0 {
+		t.Error("first record of the next interval dropped")
+	}
+
+	var b strings.Builder
+	WriteMetrics(&b)
+	if !strings.Contains(b.String(), "# TYPE nexus_log_records_dropped_total counter\nnexus_log_records_dropped_total ") {
+		t.Errorf("metrics:\n%s", b.String())
+	}
+}
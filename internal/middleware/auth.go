@@ -5,6 +5,7 @@ import (
 	"net/http"
 
 	"github.com/oriys/nexus/internal/auth"
+	"github.com/oriys/nexus/internal/reqctx"
 )
 
 // Auth returns a middleware that enforces authentication.
@@ -21,7 +22,8 @@ func Auth(authenticator auth.Authenticator) Middleware {
 				})
 				return
 			}
-			ctx := auth.IdentityToContext(r.Context(), identity)
+			ctx, _ := reqctx.Attach(auth.IdentityToContext(r.Context(), identity))
+			reqctx.Identity.Set(ctx, identity)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
@@ -3,6 +3,9 @@ package middleware
 import (
 	"log/slog"
 	"net/http"
+
+	"github.com/oriys/nexus/internal/httperror"
+	"github.com/oriys/nexus/internal/reqctx"
 )
 
 // Middleware is a function that wraps an http.Handler.
@@ -26,9 +29,9 @@ func recoverWrap(h http.Handler) http.Handler {
 			if err := recover(); err != nil {
 				slog.Error("middleware panic recovered",
 					slog.Any("error", err),
-					slog.String("path", r.URL.Path),
+					slog.String("path", reqctx.OriginalPathOf(r)),
 				)
-				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				httperror.Write(w, r, http.StatusInternalServerError, httperror.CodeInternal, "internal server error")
 			}
 		}()
 		h.ServeHTTP(w, r)
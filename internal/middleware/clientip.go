@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/oriys/nexus/internal/clientip"
+	"github.com/oriys/nexus/internal/reqctx"
+)
+
+// ClientIP returns a middleware that resolves the client address of each
+// request with resolver, for the middleware, plugins and filters after it
+// to read with clientip.FromRequest.
+func ClientIP(resolver *clientip.Resolver) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r, _ = reqctx.AttachRequest(r)
+			reqctx.ClientIP.Set(r.Context(), resolver.Resolve(r))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// IPACL returns a middleware that answers requests from client addresses
+// acl denies with 403.
+func IPACL(acl *clientip.ACL) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !acl.Allows(clientip.FromRequest(r)) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusForbidden)
+				json.NewEncoder(w).Encode(map[string]string{
+					"error":   "forbidden",
+					"message": "client address not allowed",
+				})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
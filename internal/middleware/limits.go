@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/oriys/nexus/internal/httperror"
+)
+
+// RequestLimits returns a middleware that answers requests with more than
+// maxHeaders header fields with 431, and requests whose target is longer
+// than maxURL bytes with 414. Zero disables a limit. The total header size
+// is bounded by http.Server.MaxHeaderBytes before requests get here.
+func RequestLimits(maxHeaders, maxURL int) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if maxURL > 0 && len(r.RequestURI) > maxURL {
+				writeLimitError(w, r, http.StatusRequestURITooLong, "uri_too_long", "request URL too long")
+				return
+			}
+			if maxHeaders > 0 && headerCount(r.Header) > maxHeaders {
+				writeLimitError(w, r, http.StatusRequestHeaderFieldsTooLarge, "header_fields_too_large", "too many request header fields")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// headerCount returns the number of header fields in h, counting each
+// value of a repeated header.
+func headerCount(h http.Header) int {
+	n := 0
+	for _, values := range h {
+		n += len(values)
+	}
+	return n
+}
+
+func writeLimitError(w http.ResponseWriter, r *http.Request, status int, code, message string) {
+	w.Header().Set("Connection", "close")
+	httperror.Write(w, r, status, code, message)
+}
@@ -1,15 +1,47 @@
 package middleware
 
 import (
+	"context"
 	"log/slog"
 	"net/http"
+	"strings"
+	"sync"
 	"time"
+
+	"github.com/oriys/nexus/internal/clientip"
+	"github.com/oriys/nexus/internal/reqctx"
 )
 
-// statusWriter captures the response status code.
+const logAttrsKey contextKey = "log_attrs"
+
+// logAttrs collects the attributes handlers add to the access log entry of
+// a request.
+type logAttrs struct {
+	mu    sync.Mutex
+	attrs []any
+}
+
+// AddLogAttrs adds attributes to the access log entry of the request whose
+// context is ctx. Outside the Logging middleware it does nothing.
+func AddLogAttrs(ctx context.Context, attrs ...slog.Attr) {
+	la, ok := ctx.Value(logAttrsKey).(*logAttrs)
+	if !ok {
+		return
+	}
+	la.mu.Lock()
+	defer la.mu.Unlock()
+	for _, a := range attrs {
+		la.attrs = append(la.attrs, a)
+	}
+}
+
+// statusWriter captures the response status code and the bytes of the
+// response body. Wrapping the writer the client is answered through, it
+// counts the bytes sent, compressed when the response is.
 type statusWriter struct {
 	http.ResponseWriter
-	status int
+	status  int
+	bytes   int64
 	written bool
 }
 
@@ -21,33 +53,95 @@ func (w *statusWriter) WriteHeader(code int) {
 	w.ResponseWriter.WriteHeader(code)
 }
 
+// Unwrap lets http.ResponseController reach the underlying writer, so that
+// WebSocket connections can be hijacked.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
 func (w *statusWriter) Write(b []byte) (int, error) {
 	if !w.written {
 		w.status = http.StatusOK
 		w.written = true
 	}
-	return w.ResponseWriter.Write(b)
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// headerAttrs returns the named headers present in h as log attributes.
+func headerAttrs(h http.Header, names []string) []any {
+	var attrs []any
+	for _, name := range names {
+		if values := h.Values(name); len(values) > 0 {
+			attrs = append(attrs, slog.String(http.CanonicalHeaderKey(name), strings.Join(values, ", ")))
+		}
+	}
+	return attrs
+}
+
+// clientIPString returns the resolved client address of r for logs, empty
+// when it has none.
+func clientIPString(r *http.Request) string {
+	if addr := clientip.FromRequest(r); addr.IsValid() {
+		return addr.String()
+	}
+	return ""
 }
 
-// Logging returns a middleware that logs each request with structured slog output.
-func Logging() Middleware {
+// Logging returns a middleware that logs each request with structured slog
+// output: its status, the bytes of the response body as sent, with their
+// content encoding when compressed, the latency, and the route and upstream
+// endpoint of the request once the gateway picked them. The path is the one
+// the client sent; a path rewritten for the upstream is recorded as
+// upstream_path. The named request
+// headers are recorded in a headers group; install a redacting slog
+// handler to mask the sensitive ones.
+func Logging(headers ...string) Middleware {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			start := time.Now()
 			sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+			la := &logAttrs{}
+			// The store is shared with the handlers, which set the route
+			// and upstream of the request in it.
+			ctx, _ := reqctx.Attach(r.Context())
+			r = r.WithContext(context.WithValue(ctx, logAttrsKey, la))
+			reqctx.SetOriginalPath(r)
 
 			next.ServeHTTP(sw, r)
 
 			duration := time.Since(start)
-			slog.Info("request",
+			args := []any{
 				slog.String("request_id", GetRequestID(r.Context())),
 				slog.String("method", r.Method),
-				slog.String("path", r.URL.Path),
+				slog.String("path", reqctx.OriginalPathOf(r)),
 				slog.String("host", r.Host),
 				slog.Int("status", sw.status),
+				slog.Int64("bytes", sw.bytes),
 				slog.Duration("latency", duration),
 				slog.String("remote_addr", r.RemoteAddr),
-			)
+				slog.String("client_ip", clientIPString(r)),
+			}
+			if enc := sw.Header().Get("Content-Encoding"); enc != "" {
+				args = append(args, slog.String("content_encoding", enc))
+			}
+			if route, ok := reqctx.Route.Get(r.Context()); ok {
+				args = append(args, slog.String("route", route))
+			}
+			if path := r.URL.Path; path != reqctx.OriginalPathOf(r) {
+				args = append(args, slog.String("upstream_path", path))
+			}
+			if upstream, ok := reqctx.Upstream.Get(r.Context()); ok {
+				args = append(args, slog.String("upstream", upstream))
+			}
+			if hdrs := headerAttrs(r.Header, headers); len(hdrs) > 0 {
+				args = append(args, slog.Group("headers", hdrs...))
+			}
+			la.mu.Lock()
+			args = append(args, la.attrs...)
+			la.mu.Unlock()
+			slog.Info("request", args...)
 		})
 	}
 }
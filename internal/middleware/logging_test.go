@@ -1,9 +1,16 @@
 package middleware
 
 import (
+	"bytes"
+	"context"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+
+	"github.com/oriys/nexus/internal/clientip"
+	"github.com/oriys/nexus/internal/reqctx"
 )
 
 func TestLoggingMiddleware(t *testing.T) {
@@ -38,3 +45,75 @@ func TestLoggingCapturesStatus(t *testing.T) {
 		t.Errorf("expected 404, got %d", rr.Code)
 	}
 }
+
+func TestLoggingAddLogAttrs(t *testing.T) {
+	var buf bytes.Buffer
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		AddLogAttrs(r.Context(), slog.String("plugin", "auth"))
+	})
+	Logging()(handler).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/test", nil))
+
+	if !strings.Contains(buf.String(), `"plugin":"auth"`) {
+		t.Errorf("attribute missing from log entry: %s", buf.String())
+	}
+	// Outside the middleware, attributes are dropped.
+	AddLogAttrs(context.Background(), slog.String("plugin", "auth"))
+}
+
+func TestLoggingClientIP(t *testing.T) {
+	var buf bytes.Buffer
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+
+	resolver, _ := clientip.NewResolver([]string{"10.0.0.0/8"})
+	req := httptest.NewRequest("GET", "/test", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("X-Real-IP", "198.51.100.7")
+	Chain(okHandler(), ClientIP(resolver), Logging()).ServeHTTP(httptest.NewRecorder(), req)
+
+	if !strings.Contains(buf.String(), `"client_ip":"198.51.100.7"`) || !strings.Contains(buf.String(), `"remote_addr":"10.0.0.1:1234"`) {
+		t.Errorf("client address missing from log entry: %s", buf.String())
+	}
+}
+
+func TestLoggingHeaders(t *testing.T) {
+	var buf bytes.Buffer
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+
+	req := httptest.NewRequest("GET", "/test", nil)
+	req.Header.Set("User-Agent", "curl")
+	Logging("user-agent", "X-Missing")(okHandler()).ServeHTTP(httptest.NewRecorder(), req)
+
+	if !strings.Contains(buf.String(), `"headers":{"User-Agent":"curl"}`) {
+		t.Errorf("headers missing from log entry: %s", buf.String())
+	}
+}
+
+func TestLoggingResponse(t *testing.T) {
+	var buf bytes.Buffer
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqctx.Route.Set(r.Context(), "orders")
+		reqctx.Upstream.Set(r.Context(), "10.0.0.5:8080")
+		r.URL.Path = "/v2/orders"
+		w.Header().Set("Content-Encoding", "gzip")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("compressed"))
+		w.Write([]byte("body"))
+	})
+	// The store is attached by the middleware itself when nothing before it
+	// did.
+	Logging()(handler).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/orders", nil))
+
+	for _, want := range []string{`"path":"/orders"`, `"upstream_path":"/v2/orders"`, `"status":201`, `"bytes":14`, `"content_encoding":"gzip"`, `"route":"orders"`, `"upstream":"10.0.0.5:8080"`} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("log entry lacks %s: %s", want, buf.String())
+		}
+	}
+}
@@ -0,0 +1,19 @@
+package middleware
+
+import "net/http"
+
+// Maintenance returns a middleware answering requests with the handler page
+// returns, such as the maintenance response of the current configuration.
+// Requests are served normally while page returns nil, so the switch takes
+// effect without rebuilding the chain.
+func Maintenance(page func() http.Handler) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if h := page(); h != nil {
+				h.ServeHTTP(w, r)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -8,7 +8,11 @@ import (
 	"time"
 
 	"github.com/oriys/nexus/internal/auth"
+	"github.com/oriys/nexus/internal/clientip"
+	"github.com/oriys/nexus/internal/config"
 	"github.com/oriys/nexus/internal/ratelimit"
+	"github.com/oriys/nexus/internal/reqctx"
+	"github.com/oriys/nexus/internal/tenant"
 )
 
 func okHandler() http.Handler {
@@ -68,6 +72,38 @@ func TestRateLimitMiddleware_DeniesOverRate(t *testing.T) {
 	}
 }
 
+func TestRateLimitMiddleware_KeysByResolvedClient(t *testing.T) {
+	limiter := ratelimit.NewLimiter(2, time.Minute)
+	resolver, _ := clientip.NewResolver([]string{"10.0.0.1"})
+	handler := Chain(okHandler(), RequestID(), ClientIP(resolver), RateLimit(limiter, ClientIPKeyExtractor))
+
+	// One client behind the trusted proxy, over different connections
+	for i, port := range []string{"1000", "1001", "1002"} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "10.0.0.1:" + port
+		req.Header.Set("X-Forwarded-For", "198.51.100.7")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		want := http.StatusOK
+		if i == 2 {
+			want = http.StatusTooManyRequests
+		}
+		if rr.Code != want {
+			t.Errorf("request %d: expected status %d, got %d", i, want, rr.Code)
+		}
+	}
+
+	// Another client behind the same proxy has its own limit
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "10.0.0.1:1003"
+	req.Header.Set("X-Forwarded-For", "198.51.100.8")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected status 200 for another client, got %d", rr.Code)
+	}
+}
+
 // --- Auth Tests ---
 
 func TestAuthMiddleware_ValidKey(t *testing.T) {
@@ -180,3 +216,128 @@ func TestTraceContext_StoresTraceIDInContext(t *testing.T) {
 		t.Errorf("expected trace ID %q, got %q", expected, capturedTraceID)
 	}
 }
+
+// --- IP ACL Tests ---
+
+func TestIPACLMiddleware(t *testing.T) {
+	resolver, _ := clientip.NewResolver([]string{"10.0.0.1"})
+	acl, _ := clientip.NewACL([]string{"198.51.100.0/24"}, nil)
+	handler := Chain(okHandler(), RequestID(), ClientIP(resolver), IPACL(acl))
+
+	tests := []struct {
+		remote, xff string
+		want        int
+	}{
+		{"198.51.100.7:1234", "", http.StatusOK},
+		{"203.0.113.9:1234", "", http.StatusForbidden},
+		{"10.0.0.1:1234", "198.51.100.7", http.StatusOK},
+		{"203.0.113.9:1234", "198.51.100.7", http.StatusForbidden}, // untrusted peer
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = tt.remote
+		if tt.xff != "" {
+			req.Header.Set("X-Forwarded-For", tt.xff)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s via %q: status %d, want %d", tt.remote, tt.xff, rec.Code, tt.want)
+		}
+	}
+}
+
+// --- Request Limits Tests ---
+
+func TestRequestLimits(t *testing.T) {
+	handler := RequestLimits(4, 20)(okHandler())
+	tests := []struct {
+		name    string
+		target  string
+		headers int
+		want    int
+	}{
+		{"within limits", "/short", 4, http.StatusOK},
+		{"long url", "/a/rather/long/path?q=1", 0, http.StatusRequestURITooLong},
+		{"too many headers", "/", 5, http.StatusRequestHeaderFieldsTooLarge},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+		for i := 0; i < tt.headers; i++ {
+			req.Header.Add("X-Filler", "v") // repeated values count separately
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != tt.want {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.want, rr.Code)
+		}
+	}
+
+	// Zero disables both limits
+	req := httptest.NewRequest(http.MethodGet, "/a/rather/long/path?q=1", nil)
+	rr := httptest.NewRecorder()
+	RequestLimits(0, 0)(okHandler()).ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected status 200 without limits, got %d", rr.Code)
+	}
+}
+
+// --- Maintenance Tests ---
+
+func TestMaintenance(t *testing.T) {
+	var page http.Handler
+	handler := Maintenance(func() http.Handler { return page })(okHandler())
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 outside maintenance, got %d", rr.Code)
+	}
+
+	// The switch applies to the next request without rebuilding the chain
+	page = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 in maintenance, got %d", rr.Code)
+	}
+}
+
+// --- Tenant Tests ---
+
+func TestTenant(t *testing.T) {
+	tn := tenant.New(&config.Tenancy{Header: "X-Tenant", RateLimit: &config.TenantRateLimit{Rate: 1, Window: "10s"}})
+	var seen string
+	handler := Tenant(func() *tenant.Tenancy { return tn })(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen, _ = reqctx.Tenant.Get(r.Context())
+	}))
+	serve := func(tenant string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tenant != "" {
+			req.Header.Set("X-Tenant", tenant)
+		}
+		rr := httptest.NewRecorder()
+		seen = ""
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := serve("acme"); rr.Code != http.StatusOK || seen != "acme" {
+		t.Fatalf("status %d, tenant %q", rr.Code, seen)
+	}
+	rr := serve("acme")
+	if rr.Code != http.StatusTooManyRequests || rr.Header().Get("Retry-After") != "10" {
+		t.Errorf("expected 429 with Retry-After 10 over the limit, got %d %q", rr.Code, rr.Header().Get("Retry-After"))
+	}
+	if rr := serve("globex"); rr.Code != http.StatusOK {
+		t.Errorf("another tenant was limited: %d", rr.Code)
+	}
+	// Requests without a tenant are not limited.
+	for i := 0; i < 3; i++ {
+		if rr := serve(""); rr.Code != http.StatusOK || seen != "" {
+			t.Errorf("untenanted request: status %d, tenant %q", rr.Code, seen)
+		}
+	}
+}
@@ -4,14 +4,20 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"github.com/oriys/nexus/internal/clientip"
 	"github.com/oriys/nexus/internal/ratelimit"
 )
 
 // KeyExtractor extracts the rate limit key from a request.
 type KeyExtractor func(r *http.Request) string
 
-// ClientIPKeyExtractor extracts the client IP as the rate limit key.
+// ClientIPKeyExtractor extracts the client IP as the rate limit key: the
+// address resolved by the ClientIP middleware, without the port, so that a
+// client's connections share one limit.
 func ClientIPKeyExtractor(r *http.Request) string {
+	if addr := clientip.FromRequest(r); addr.IsValid() {
+		return addr.String()
+	}
 	return r.RemoteAddr
 }
 
@@ -5,13 +5,16 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"net/http"
+
+	"github.com/oriys/nexus/internal/reqctx"
 )
 
 type contextKey string
 
 const requestIDKey contextKey = "request_id"
 
-// RequestID adds a unique request ID to each request.
+// RequestID adds a unique request ID to each request. It also attaches the
+// request's reqctx store, as the first middleware of the chain.
 func RequestID() Middleware {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -20,7 +23,8 @@ func RequestID() Middleware {
 				id = generateID()
 			}
 			w.Header().Set("X-Request-ID", id)
-			ctx := context.WithValue(r.Context(), requestIDKey, id)
+			ctx, _ := reqctx.Attach(context.WithValue(r.Context(), requestIDKey, id))
+			reqctx.RequestID.Set(ctx, id)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
@@ -4,6 +4,8 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+
+	"github.com/oriys/nexus/internal/reqctx"
 )
 
 func TestRequestIDGenerated(t *testing.T) {
@@ -54,3 +56,20 @@ func TestGetRequestIDNoContext(t *testing.T) {
 		t.Errorf("expected empty string, got %s", id)
 	}
 }
+
+func TestRequestIDSharedThroughReqctx(t *testing.T) {
+	var gotID, gotTrace string
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotID, _ = reqctx.RequestID.Get(r.Context())
+		gotTrace, _ = reqctx.TraceID.Get(r.Context())
+	})
+
+	req := httptest.NewRequest("GET", "/test", nil)
+	req.Header.Set("X-Request-ID", "req-7")
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	Chain(handler, RequestID(), TraceContext()).ServeHTTP(httptest.NewRecorder(), req)
+
+	if gotID != "req-7" || gotTrace != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("reqctx request ID %q, trace ID %q", gotID, gotTrace)
+	}
+}
@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/oriys/nexus/internal/reqctx"
+	"github.com/oriys/nexus/internal/tenant"
+)
+
+// Tenant returns a middleware that labels each request with its tenant for
+// the handlers after it, the access log and the tenant metrics, and
+// answers requests over the rate limit of their tenant with 429. The
+// tenancy is read per request, so that reloads apply at once; requests
+// without a tenant, or while tenancy returns nil, pass through unlabeled.
+func Tenant(tenancy func() *tenant.Tenancy) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			t := tenancy()
+			if t == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			name := t.Resolve(r)
+			if name == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			r, _ = reqctx.AttachRequest(r)
+			reqctx.Tenant.Set(r.Context(), name)
+			AddLogAttrs(r.Context(), slog.String("tenant", name))
+
+			start := time.Now()
+			if ok, window := t.Allow(name); !ok {
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("Retry-After", strconv.Itoa(int((window+time.Second-1)/time.Second)))
+				w.WriteHeader(http.StatusTooManyRequests)
+				json.NewEncoder(w).Encode(map[string]string{
+					"error":   "rate_limit_exceeded",
+					"message": "too many requests for tenant, please try again later",
+				})
+				t.Observe(name, http.StatusTooManyRequests, time.Since(start))
+				return
+			}
+			sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(sw, r)
+			t.Observe(name, sw.status, time.Since(start))
+		})
+	}
+}
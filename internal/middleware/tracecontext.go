@@ -9,6 +9,8 @@ import (
 	"net/http"
 	"strings"
 	"time"
+
+	"github.com/oriys/nexus/internal/reqctx"
 )
 
 const traceIDKey contextKey = "trace_id"
@@ -33,7 +35,8 @@ func TraceContext() Middleware {
 				r.Header.Set("traceparent", traceparent)
 			}
 			traceID := extractTraceID(traceparent)
-			ctx := context.WithValue(r.Context(), traceIDKey, traceID)
+			ctx, _ := reqctx.Attach(context.WithValue(r.Context(), traceIDKey, traceID))
+			reqctx.TraceID.Set(ctx, traceID)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
@@ -0,0 +1,226 @@
+// Package pathmatch implements the parameterized and regular expression
+// path rules shared by the legacy router and the V2 router index.
+//
+// A template such as "/users/{id}/orders/{oid}" matches one path segment
+// per parameter; "{name:regex}" constrains a parameter with a regular
+// expression instead. A regex rule matches the whole path, and its named
+// groups become parameters.
+package pathmatch
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Pattern is a compiled template or regex path rule.
+type Pattern struct {
+	re    *regexp.Regexp
+	names []string
+}
+
+// IsTemplate reports whether path is a template rather than a literal path.
+func IsTemplate(path string) bool {
+	return strings.Contains(path, "{")
+}
+
+// Template compiles a path template. With prefix set the template only has
+// to match the beginning of a path, as path_prefix rules do.
+func Template(tmpl string, prefix bool) (*Pattern, error) {
+	var b strings.Builder
+	b.WriteString("^")
+	var names []string
+	seen := make(map[string]bool)
+	rest := tmpl
+	for rest != "" {
+		open := strings.IndexByte(rest, '{')
+		if open < 0 {
+			if strings.IndexByte(rest, '}') >= 0 {
+				return nil, fmt.Errorf("unbalanced '}' in template %q", tmpl)
+			}
+			b.WriteString(regexp.QuoteMeta(rest))
+			break
+		}
+		if strings.IndexByte(rest[:open], '}') >= 0 {
+			return nil, fmt.Errorf("unbalanced '}' in template %q", tmpl)
+		}
+		b.WriteString(regexp.QuoteMeta(rest[:open]))
+
+		end := closingBrace(rest, open)
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated parameter in template %q", tmpl)
+		}
+		name, expr, _ := strings.Cut(rest[open+1:end], ":")
+		if !isIdent(name) {
+			return nil, fmt.Errorf("invalid parameter name %q in template %q", name, tmpl)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate parameter %q in template %q", name, tmpl)
+		}
+		seen[name] = true
+		names = append(names, name)
+		if expr == "" {
+			expr = "[^/]+"
+		}
+		if _, err := regexp.Compile(expr); err != nil {
+			return nil, fmt.Errorf("parameter %q: %w", name, err)
+		}
+		b.WriteString("(" + expr + ")")
+		rest = rest[end+1:]
+	}
+	if !prefix {
+		b.WriteString("$")
+	}
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return nil, fmt.Errorf("template %q: %w", tmpl, err)
+	}
+	if re.NumSubexp() != len(names) {
+		return nil, fmt.Errorf("template %q: parameter expressions must not contain capturing groups", tmpl)
+	}
+	return &Pattern{re: re, names: names}, nil
+}
+
+// Regex compiles a regular expression that must match the whole path. Named
+// groups, e.g. (?P<id>[0-9]+), are exposed as parameters.
+func Regex(expr string) (*Pattern, error) {
+	re, err := regexp.Compile("^(?:" + expr + ")$")
+	if err != nil {
+		return nil, err
+	}
+	return &Pattern{re: re, names: re.SubexpNames()[1:]}, nil
+}
+
+// IgnoreCase returns a copy of p matching paths regardless of case.
+func (p *Pattern) IgnoreCase() *Pattern {
+	return &Pattern{re: regexp.MustCompile("(?i)" + p.re.String()), names: p.names}
+}
+
+// Match matches path against p. It returns the matched part of the path,
+// which is shorter than path only for prefix templates, and the captured
+// parameters.
+func (p *Pattern) Match(path string) (string, map[string]string, bool) {
+	m := p.re.FindStringSubmatch(path)
+	if m == nil {
+		return "", nil, false
+	}
+	var params map[string]string
+	for i, name := range p.names {
+		if name == "" {
+			continue
+		}
+		if params == nil {
+			params = make(map[string]string, len(p.names))
+		}
+		params[name] = m[i+1]
+	}
+	return m[0], params, true
+}
+
+// String returns the regular expression p compiles to.
+func (p *Pattern) String() string {
+	return p.re.String()
+}
+
+// Expand replaces each {name} in s with the parameter of that name. Braces
+// naming no parameter are left as they are.
+func Expand(s string, params map[string]string) string {
+	if len(params) == 0 || !strings.Contains(s, "{") {
+		return s
+	}
+	var b strings.Builder
+	for {
+		open := strings.IndexByte(s, '{')
+		if open < 0 {
+			break
+		}
+		end := strings.IndexByte(s[open:], '}')
+		if end < 0 {
+			break
+		}
+		end += open
+		v, ok := params[s[open+1:end]]
+		if !ok {
+			b.WriteString(s[:open+1])
+			s = s[open+1:]
+			continue
+		}
+		b.WriteString(s[:open])
+		b.WriteString(v)
+		s = s[end+1:]
+	}
+	b.WriteString(s)
+	return b.String()
+}
+
+// MergeSlashes collapses each run of slashes in path into one.
+func MergeSlashes(path string) string {
+	if !strings.Contains(path, "//") {
+		return path
+	}
+	var b strings.Builder
+	b.Grow(len(path))
+	for i := 0; i < len(path); i++ {
+		if path[i] == '/' && i > 0 && path[i-1] == '/' {
+			continue
+		}
+		b.WriteByte(path[i])
+	}
+	return b.String()
+}
+
+// StripTrailingSlash removes the trailing slashes of path, except from "/".
+func StripTrailingSlash(path string) string {
+	for len(path) > 1 && path[len(path)-1] == '/' {
+		path = path[:len(path)-1]
+	}
+	return path
+}
+
+type paramsKey struct{}
+
+// WithParams returns ctx carrying the path parameters of the matched route.
+func WithParams(ctx context.Context, params map[string]string) context.Context {
+	if len(params) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, paramsKey{}, params)
+}
+
+// Params returns the path parameters stored in ctx by WithParams.
+func Params(ctx context.Context) map[string]string {
+	params, _ := ctx.Value(paramsKey{}).(map[string]string)
+	return params
+}
+
+// closingBrace returns the index of the '}' closing the '{' at s[open],
+// allowing balanced braces inside, as in {id:[0-9]{3}}.
+func closingBrace(s string, open int) int {
+	depth := 0
+	for i := open; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func isIdent(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		if r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || i > 0 && r >= '0' && r <= '9' {
+			continue
+		}
+		return false
+	}
+	return true
+}
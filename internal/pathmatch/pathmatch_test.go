@@ -0,0 +1,135 @@
+package pathmatch
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestTemplate(t *testing.T) {
+	tests := []struct {
+		tmpl   string
+		prefix bool
+		path   string
+		ok     bool
+		match  string
+		params map[string]string
+	}{
+		{"/users/{id}/orders/{oid}", false, "/users/42/orders/7", true, "/users/42/orders/7", map[string]string{"id": "42", "oid": "7"}},
+		{"/users/{id}/orders/{oid}", false, "/users/42/orders/7/items", false, "", nil},
+		{"/users/{id}", false, "/users/", false, "", nil},
+		{"/users/{id}", false, "/users/a/b", false, "", nil},
+		{"/users/{id:[0-9]+}", false, "/users/abc", false, "", nil},
+		{"/users/{id:[0-9]{3}}", false, "/users/123", true, "/users/123", map[string]string{"id": "123"}},
+		{"/files/{path:.+}", false, "/files/a/b.txt", true, "/files/a/b.txt", map[string]string{"path": "a/b.txt"}},
+		{"/v1.0/{name}", false, "/v1x0/a", false, "", nil},
+		{"/users/{id}", true, "/users/42/orders", true, "/users/42", map[string]string{"id": "42"}},
+	}
+	for _, tt := range tests {
+		p, err := Template(tt.tmpl, tt.prefix)
+		if err != nil {
+			t.Fatalf("Template(%q): %v", tt.tmpl, err)
+		}
+		match, params, ok := p.Match(tt.path)
+		if ok != tt.ok || match != tt.match || !reflect.DeepEqual(params, tt.params) {
+			t.Errorf("%q.Match(%q) = %q, %v, %v; want %q, %v, %v",
+				tt.tmpl, tt.path, match, params, ok, tt.match, tt.params, tt.ok)
+		}
+	}
+}
+
+func TestTemplate_Invalid(t *testing.T) {
+	for _, tmpl := range []string{
+		"/users/{id",
+		"/users/id}",
+		"/users/{}",
+		"/users/{1d}",
+		"/users/{id}/{id}",
+		"/users/{id:[}",
+		"/users/{id:(a|b)}",
+	} {
+		if _, err := Template(tmpl, false); err == nil {
+			t.Errorf("Template(%q): expected error", tmpl)
+		}
+	}
+}
+
+func TestRegex(t *testing.T) {
+	p, err := Regex(`/items/(?P<id>[0-9]+)(\.json)?`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, params, ok := p.Match("/items/12.json")
+	if !ok || !reflect.DeepEqual(params, map[string]string{"id": "12"}) {
+		t.Errorf("Match = %v, %v", params, ok)
+	}
+	if _, _, ok := p.Match("/items/12/extra"); ok {
+		t.Error("regex must match the whole path")
+	}
+	if _, err := Regex("("); err == nil {
+		t.Error("expected error for invalid regex")
+	}
+}
+
+func TestPattern_IgnoreCase(t *testing.T) {
+	p, err := Template("/Users/{id}", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, ok := p.Match("/users/Ab"); ok {
+		t.Error("templates are case-sensitive by default")
+	}
+	_, params, ok := p.IgnoreCase().Match("/users/Ab")
+	if !ok || params["id"] != "Ab" {
+		t.Errorf("IgnoreCase().Match = %v, %v", params, ok)
+	}
+}
+
+func TestExpand(t *testing.T) {
+	params := map[string]string{"id": "42", "oid": "7"}
+	tests := map[string]string{
+		"/internal/users/{id}": "/internal/users/42",
+		"/u/{id}/o/{oid}":      "/u/42/o/7",
+		"/keep/{other}/{id}":   "/keep/{other}/42",
+		"{":                    "{",
+		"user-{id}":            "user-42",
+		"/no/params":           "/no/params",
+	}
+	for in, want := range tests {
+		if got := Expand(in, params); got != want {
+			t.Errorf("Expand(%q) = %q, want %q", in, got, want)
+		}
+	}
+	if got := Expand("/u/{id}", nil); got != "/u/{id}" {
+		t.Errorf("Expand without params = %q", got)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	merge := map[string]string{"//api///users/": "/api/users/", "/api": "/api", "": ""}
+	for in, want := range merge {
+		if got := MergeSlashes(in); got != want {
+			t.Errorf("MergeSlashes(%q) = %q, want %q", in, got, want)
+		}
+	}
+	strip := map[string]string{"/api/v1//": "/api/v1", "/": "/", "/api": "/api", "": ""}
+	for in, want := range strip {
+		if got := StripTrailingSlash(in); got != want {
+			t.Errorf("StripTrailingSlash(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestParamsContext(t *testing.T) {
+	ctx := context.Background()
+	if Params(ctx) != nil {
+		t.Fatal("expected no params")
+	}
+	if WithParams(ctx, nil) != ctx {
+		t.Error("WithParams without params should return ctx")
+	}
+	ctx = WithParams(ctx, map[string]string{"id": "1"})
+	if Params(ctx)["id"] != "1" {
+		t.Errorf("Params = %v", Params(ctx))
+	}
+}
@@ -0,0 +1,50 @@
+package pathmatch
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Rewrite is a compiled regex path rewrite. The substitution refers to the
+// capture groups of the pattern as $1 or ${name}, and may end in a query
+// string, e.g. "^/api/v(\d+)/(.*)$" → "/internal/$2?ver=$1".
+type Rewrite struct {
+	re  *regexp.Regexp
+	sub string
+}
+
+// CompileRewrite compiles a regex path rewrite.
+func CompileRewrite(pattern, substitution string) (*Rewrite, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return &Rewrite{re: re, sub: substitution}, nil
+}
+
+// Apply rewrites the path of u when the pattern matches it, replacing the
+// first match with the substitution. Query parameters produced by the
+// substitution are placed before those of the original query. It reports
+// whether the path matched.
+func (rw *Rewrite) Apply(u *url.URL) bool {
+	m := rw.re.FindStringSubmatchIndex(u.Path)
+	if m == nil {
+		return false
+	}
+	out := rw.re.ExpandString(nil, rw.sub, u.Path, m)
+	path := u.Path[:m[0]] + string(out) + u.Path[m[1]:]
+	if i := strings.IndexByte(path, '?'); i >= 0 {
+		query := path[i+1:]
+		path = path[:i]
+		if query != "" && u.RawQuery != "" {
+			query += "&" + u.RawQuery
+		}
+		if query != "" {
+			u.RawQuery = query
+		}
+	}
+	u.Path = path
+	u.RawPath = ""
+	return true
+}
@@ -0,0 +1,44 @@
+package pathmatch
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestRewrite(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		sub     string
+		in      string
+		want    string
+		matched bool
+	}{
+		{"groups and query", `^/api/v(\d+)/(.*)$`, "/internal/$2?ver=$1", "/api/v2/users/42", "/internal/users/42?ver=2", true},
+		{"keeps original query", `^/api/v(\d+)/(.*)$`, "/internal/$2?ver=$1", "/api/v3/items?limit=5", "/internal/items?ver=3&limit=5", true},
+		{"named groups", `^/shop/(?P<sku>[A-Z]+)$`, "/catalog/items/${sku}", "/shop/ABC", "/catalog/items/ABC", true},
+		{"partial match", `/old/`, "/new/", "/a/old/b", "/a/new/b", true},
+		{"no match", `^/api/v(\d+)/`, "/x/", "/other/path?q=1", "/other/path?q=1", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rw, err := CompileRewrite(tt.pattern, tt.sub)
+			if err != nil {
+				t.Fatalf("compile error: %v", err)
+			}
+			u, _ := url.Parse(tt.in)
+			if got := rw.Apply(u); got != tt.matched {
+				t.Errorf("matched = %v", got)
+			}
+			if got := u.RequestURI(); got != tt.want {
+				t.Errorf("rewritten to %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileRewrite_Invalid(t *testing.T) {
+	if _, err := CompileRewrite("/api/(", "/x"); err == nil {
+		t.Error("expected error for invalid pattern")
+	}
+}
@@ -0,0 +1,436 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/oriys/nexus/internal/config"
+	"github.com/oriys/nexus/internal/httperror"
+	"github.com/oriys/nexus/internal/reqctx"
+	"github.com/oriys/nexus/internal/transcode"
+)
+
+// ExternalPlugin delegates to a sidecar gRPC service, so that plugins can be
+// written in Java, Python or any language with gRPC support. The service
+// implements:
+//
+//	service nexus.plugin.v1.ExternalPlugin {
+//	  rpc Execute(ExecuteRequest) returns (ExecuteResponse);
+//	}
+//
+//	message Header { string name = 1; string value = 2; }
+//
+//	message ExecuteRequest {
+//	  string method = 1;
+//	  string path = 2;
+//	  string raw_query = 3;
+//	  string host = 4;
+//	  string remote_addr = 5;
+//	  repeated Header headers = 6;
+//	  bytes body = 7;             // only with send_body
+//	  bool body_truncated = 8;    // body holds the first max_body bytes
+//	  string route = 9;           // the matched rule, if any
+//	  string upstream = 10;
+//	  repeated Header config = 11; // the plugin configuration bound to the route
+//	  string original_path = 12;  // the path the client sent, before rewrites
+//	}
+//
+//	message ExecuteResponse {
+//	  enum Verdict { CONTINUE = 0; RESPOND = 1; }
+//	  Verdict verdict = 1;
+//	  // RESPOND answers the request instead of passing it on.
+//	  int32 status = 2;           // defaults to 403
+//	  repeated Header response_headers = 3;
+//	  bytes response_body = 4;
+//	  // CONTINUE applies these to the request, then passes it on.
+//	  repeated Header set_headers = 5;
+//	  repeated string remove_headers = 6;
+//	  string path = 7;            // replaces the path when set
+//	  bool replace_body = 8;
+//	  bytes body = 9;             // the new body when replace_body is set
+//	  repeated Header attributes = 10; // stored in GatewayContext.Attributes
+//	}
+type ExternalPlugin struct {
+	name     string
+	order    int
+	url      string
+	timeout  time.Duration
+	sendBody bool
+	maxBody  int64
+	failOpen bool
+	schema   []byte
+	client   *http.Client
+}
+
+// externalPluginMethod is the path of the Execute method.
+const externalPluginMethod = "/nexus.plugin.v1.ExternalPlugin/Execute"
+
+// Defaults for the optional external plugin settings.
+const (
+	defaultExternalOrder   = 50
+	defaultExternalTimeout = time.Second
+	defaultExternalMaxBody = 64 << 10
+	// maxExternalReply bounds the ExecuteResponse message.
+	maxExternalReply = 4 << 20
+)
+
+// Verdicts of an ExecuteResponse.
+const (
+	verdictContinue = 0
+	verdictRespond  = 1
+)
+
+// NewExternalPlugin creates an ExternalPlugin from its configuration, which
+// config.Validate has checked.
+func NewExternalPlugin(cfg config.ExternalPlugin) *ExternalPlugin {
+	p := &ExternalPlugin{
+		name:     cfg.Name,
+		order:    cfg.Order,
+		url:      strings.TrimSuffix(cfg.Endpoint, "/") + externalPluginMethod,
+		sendBody: cfg.SendBody,
+		failOpen: cfg.FailOpen,
+		timeout:  defaultExternalTimeout,
+		maxBody:  defaultExternalMaxBody,
+	}
+	if p.order == 0 {
+		p.order = defaultExternalOrder
+	}
+	if d, _ := cfg.Timeout.Parse(); d > 0 {
+		p.timeout = d
+	}
+	if n, _ := cfg.MaxBody.Parse(); n > 0 {
+		p.maxBody = n
+	}
+	if cfg.ConfigSchema != nil {
+		p.schema, _ = json.Marshal(cfg.ConfigSchema)
+	}
+	// HTTP/2 only: over TLS for https endpoints, with prior knowledge (h2c)
+	// for http endpoints.
+	var protocols http.Protocols
+	protocols.SetHTTP2(true)
+	protocols.SetUnencryptedHTTP2(true)
+	p.client = &http.Client{Transport: &http.Transport{Protocols: &protocols}}
+	return p
+}
+
+// Name returns the configured plugin name.
+func (p *ExternalPlugin) Name() string { return p.name }
+
+// Order returns the configured execution order.
+func (p *ExternalPlugin) Order() int { return p.order }
+
+// ConfigSchema returns the configured schema of route configurations.
+func (p *ExternalPlugin) ConfigSchema() []byte { return p.schema }
+
+// Execute sends the request to the service and applies its verdict. When
+// the call fails the request is answered with 502, or passed on unchanged
+// with fail_open.
+func (p *ExternalPlugin) Execute(ctx *GatewayContext, next func()) error {
+	msg, err := p.request(ctx)
+	if err != nil {
+		return err
+	}
+	callCtx, cancel := context.WithTimeout(ctx.Request.Context(), p.timeout)
+	defer cancel()
+	reply, err := p.call(callCtx, msg)
+	var res *externalResult
+	if err == nil {
+		res, err = parseExternalResult(reply)
+	}
+	if err != nil {
+		slog.Error("external plugin error",
+			slog.String("plugin", p.name),
+			slog.String("path", reqctx.OriginalPathOf(ctx.Request)),
+			slog.Bool("fail_open", p.failOpen),
+			slog.String("error", err.Error()),
+		)
+		if p.failOpen {
+			next()
+			return nil
+		}
+		httperror.Write(ctx.ResponseWriter, ctx.Request, http.StatusBadGateway, httperror.CodeBadGateway, "bad gateway")
+		return nil
+	}
+
+	if res.verdict == verdictRespond {
+		res.respond(ctx.ResponseWriter)
+		return nil
+	}
+	res.apply(ctx)
+	next()
+	return nil
+}
+
+// request encodes the ExecuteRequest for ctx. With send_body, up to maxBody
+// bytes of the body are read and put back in front of the rest.
+func (p *ExternalPlugin) request(ctx *GatewayContext) ([]byte, error) {
+	r := ctx.Request
+	var msg []byte
+	msg = appendString(msg, 1, r.Method)
+	msg = appendString(msg, 2, r.URL.Path)
+	msg = appendString(msg, 3, r.URL.RawQuery)
+	msg = appendString(msg, 4, r.Host)
+	msg = appendString(msg, 5, r.RemoteAddr)
+	for name, values := range r.Header {
+		for _, v := range values {
+			msg = appendHeader(msg, 6, name, v)
+		}
+	}
+	if p.sendBody && r.Body != nil && r.Body != http.NoBody {
+		body, err := io.ReadAll(io.LimitReader(r.Body, p.maxBody+1))
+		if err != nil {
+			return nil, fmt.Errorf("read request body: %w", err)
+		}
+		truncated := int64(len(body)) > p.maxBody
+		r.Body = readCloser{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+		if truncated {
+			body = body[:p.maxBody]
+			msg = protowire.AppendTag(msg, 8, protowire.VarintType)
+			msg = protowire.AppendVarint(msg, 1)
+		}
+		msg = protowire.AppendTag(msg, 7, protowire.BytesType)
+		msg = protowire.AppendBytes(msg, body)
+	}
+	if ctx.Rule != nil {
+		msg = appendString(msg, 9, ctx.Rule.Name)
+		msg = appendString(msg, 10, ctx.Rule.Upstream)
+	}
+	for k, v := range ctx.Config {
+		msg = appendHeader(msg, 11, k, v)
+	}
+	msg = appendString(msg, 12, reqctx.OriginalPathOf(r))
+	return msg, nil
+}
+
+// readCloser reads from a replayed body but closes the original one.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// call invokes Execute with msg and returns the response message.
+func (p *ExternalPlugin) call(ctx context.Context, msg []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(transcode.Frame(msg)))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/grpc+proto")
+	req.Header.Set("Te", "trailers")
+	if ms := p.timeout.Milliseconds(); ms > 0 && ms <= 99999999 {
+		req.Header.Set("Grpc-Timeout", strconv.FormatInt(ms, 10)+"m")
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("service returned HTTP %d", resp.StatusCode)
+	}
+	// Trailers-only responses carry the status in the headers.
+	if err := grpcStatusError(resp.Header); err != nil {
+		return nil, err
+	}
+	out, err := transcode.ReadFrame(transcode.LimitFrames(resp.Body, maxExternalReply))
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+	// Drain the body so the trailers become available.
+	if _, err := io.Copy(io.Discard, resp.Body); err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+	if err := grpcStatusError(resp.Trailer); err != nil {
+		return nil, err
+	}
+	if resp.Trailer.Get("Grpc-Status") == "" && resp.Header.Get("Grpc-Status") == "" {
+		return nil, errors.New("response ended without grpc-status")
+	}
+	if out == nil {
+		return nil, errors.New("response has no message")
+	}
+	return out, nil
+}
+
+// grpcStatusError returns the error described by a grpc-status header, if
+// any.
+func grpcStatusError(h http.Header) error {
+	if s := h.Get("Grpc-Status"); s != "" && s != "0" {
+		return fmt.Errorf("grpc-status %s: %s", s, h.Get("Grpc-Message"))
+	}
+	return nil
+}
+
+// externalResult is a decoded ExecuteResponse.
+type externalResult struct {
+	verdict         uint64
+	status          int
+	responseHeaders [][2]string
+	responseBody    []byte
+	setHeaders      [][2]string
+	removeHeaders   []string
+	path            string
+	replaceBody     bool
+	body            []byte
+	attributes      [][2]string
+}
+
+func parseExternalResult(msg []byte) (*externalResult, error) {
+	res := &externalResult{}
+	for len(msg) > 0 {
+		num, typ, n := protowire.ConsumeTag(msg)
+		if n < 0 {
+			return nil, fmt.Errorf("invalid response message: %w", protowire.ParseError(n))
+		}
+		msg = msg[n:]
+		switch {
+		case typ == protowire.VarintType && (num == 1 || num == 2 || num == 8):
+			var v uint64
+			v, n = protowire.ConsumeVarint(msg)
+			switch num {
+			case 1:
+				res.verdict = v
+			case 2:
+				res.status = int(int32(v))
+			case 8:
+				res.replaceBody = v != 0
+			}
+		case typ == protowire.BytesType && num >= 3 && num <= 10 && num != 8:
+			var b []byte
+			b, n = protowire.ConsumeBytes(msg)
+			if n < 0 {
+				break
+			}
+			switch num {
+			case 3, 5, 10:
+				h, err := parseHeader(b)
+				if err != nil {
+					return nil, err
+				}
+				switch num {
+				case 3:
+					res.responseHeaders = append(res.responseHeaders, h)
+				case 5:
+					res.setHeaders = append(res.setHeaders, h)
+				default:
+					res.attributes = append(res.attributes, h)
+				}
+			case 4:
+				res.responseBody = b
+			case 6:
+				res.removeHeaders = append(res.removeHeaders, string(b))
+			case 7:
+				res.path = string(b)
+			case 9:
+				res.body = b
+			}
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, msg)
+		}
+		if n < 0 {
+			return nil, fmt.Errorf("invalid response message: %w", protowire.ParseError(n))
+		}
+		msg = msg[n:]
+	}
+	switch res.verdict {
+	case verdictContinue:
+	case verdictRespond:
+		if res.status == 0 {
+			res.status = http.StatusForbidden
+		}
+		if res.status < 100 || res.status > 599 {
+			return nil, fmt.Errorf("invalid response status %d", res.status)
+		}
+	default:
+		return nil, fmt.Errorf("unknown verdict %d", res.verdict)
+	}
+	return res, nil
+}
+
+// parseHeader decodes a Header message.
+func parseHeader(msg []byte) ([2]string, error) {
+	var h [2]string
+	for len(msg) > 0 {
+		num, typ, n := protowire.ConsumeTag(msg)
+		if n < 0 {
+			return h, fmt.Errorf("invalid header message: %w", protowire.ParseError(n))
+		}
+		msg = msg[n:]
+		if typ == protowire.BytesType && (num == 1 || num == 2) {
+			var s string
+			s, n = protowire.ConsumeString(msg)
+			if n >= 0 {
+				h[num-1] = s
+			}
+		} else {
+			n = protowire.ConsumeFieldValue(num, typ, msg)
+		}
+		if n < 0 {
+			return h, fmt.Errorf("invalid header message: %w", protowire.ParseError(n))
+		}
+		msg = msg[n:]
+	}
+	return h, nil
+}
+
+// respond writes the response a RESPOND verdict describes.
+func (res *externalResult) respond(w http.ResponseWriter) {
+	for _, h := range res.responseHeaders {
+		w.Header().Add(h[0], h[1])
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(res.responseBody)))
+	w.WriteHeader(res.status)
+	w.Write(res.responseBody)
+}
+
+// apply applies the request mutations of a CONTINUE verdict.
+func (res *externalResult) apply(ctx *GatewayContext) {
+	r := ctx.Request
+	for _, name := range res.removeHeaders {
+		r.Header.Del(name)
+	}
+	for _, h := range res.setHeaders {
+		r.Header.Set(h[0], h[1])
+	}
+	if res.path != "" {
+		r.URL.Path = res.path
+		r.URL.RawPath = ""
+	}
+	if res.replaceBody {
+		if r.Body != nil {
+			r.Body.Close()
+		}
+		r.Body = io.NopCloser(bytes.NewReader(res.body))
+		r.ContentLength = int64(len(res.body))
+		r.Header.Set("Content-Length", strconv.Itoa(len(res.body)))
+	}
+	for _, a := range res.attributes {
+		ctx.Attributes[a[0]] = a[1]
+	}
+}
+
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+func appendHeader(b []byte, num protowire.Number, name, value string) []byte {
+	var h []byte
+	h = appendString(h, 1, name)
+	h = appendString(h, 2, value)
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, h)
+}
@@ -0,0 +1,249 @@
+package plugin
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/oriys/nexus/internal/config"
+	"github.com/oriys/nexus/internal/reqctx"
+	"github.com/oriys/nexus/internal/transcode"
+)
+
+// executeRequest is the part of an ExecuteRequest the tests inspect.
+type executeRequest struct {
+	method, path, route string
+	originalPath        string
+	headers, config     map[string]string
+	body                string
+	truncated           bool
+}
+
+func decodeExecuteRequest(t *testing.T, msg []byte) executeRequest {
+	t.Helper()
+	req := executeRequest{headers: make(map[string]string), config: make(map[string]string)}
+	for len(msg) > 0 {
+		num, typ, n := protowire.ConsumeTag(msg)
+		msg = msg[n:]
+		if typ == protowire.VarintType {
+			v, n := protowire.ConsumeVarint(msg)
+			req.truncated = num == 8 && v == 1
+			msg = msg[n:]
+			continue
+		}
+		b, n := protowire.ConsumeBytes(msg)
+		if n < 0 {
+			t.Fatalf("malformed request: %v", protowire.ParseError(n))
+		}
+		msg = msg[n:]
+		switch num {
+		case 1:
+			req.method = string(b)
+		case 2:
+			req.path = string(b)
+		case 6:
+			h, err := parseHeader(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.headers[h[0]] = h[1]
+		case 7:
+			req.body = string(b)
+		case 9:
+			req.route = string(b)
+		case 11:
+			h, err := parseHeader(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.config[h[0]] = h[1]
+		case 12:
+			req.originalPath = string(b)
+		}
+	}
+	return req
+}
+
+// newSidecar starts an h2c gRPC server answering Execute with reply, or
+// with grpc-status status when status is not "0".
+func newSidecar(t *testing.T, status string, reply []byte, seen chan<- executeRequest) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != externalPluginMethod || r.ProtoMajor != 2 {
+			t.Errorf("unexpected call %s %s", r.Proto, r.URL.Path)
+		}
+		msg, err := transcode.ReadFrame(r.Body)
+		if err != nil {
+			t.Errorf("read request: %v", err)
+		}
+		if seen != nil {
+			seen <- decodeExecuteRequest(t, msg)
+		}
+		w.Header().Set("Content-Type", "application/grpc")
+		if status != "0" {
+			w.Header().Set("Grpc-Status", status)
+			w.Header().Set("Grpc-Message", "plugin failed")
+			return
+		}
+		w.Header().Set("Trailer", "Grpc-Status")
+		w.Write(transcode.Frame(reply))
+		w.Header().Set("Grpc-Status", "0")
+	}))
+	srv.Config.Protocols = new(http.Protocols)
+	srv.Config.Protocols.SetUnencryptedHTTP2(true)
+	srv.Start()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func headerField(num protowire.Number, name, value string) []byte {
+	return appendHeader(nil, num, name, value)
+}
+
+func varintField(num protowire.Number, v uint64) []byte {
+	b := protowire.AppendTag(nil, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+func TestExternalPlugin_Continue(t *testing.T) {
+	var reply []byte
+	reply = append(reply, headerField(5, "X-User", "alice")...)
+	reply = appendString(reply, 6, "Authorization")
+	reply = appendString(reply, 7, "/internal/orders")
+	reply = append(reply, varintField(8, 1)...)
+	reply = appendString(reply, 9, `{"rewritten":true}`)
+	reply = append(reply, headerField(10, "tenant", "acme")...)
+	seen := make(chan executeRequest, 1)
+	srv := newSidecar(t, "0", reply, seen)
+
+	p := NewExternalPlugin(config.ExternalPlugin{Name: "auth", Endpoint: srv.URL, SendBody: true, MaxBody: "4B"})
+	if p.Name() != "auth" || p.Order() != defaultExternalOrder {
+		t.Errorf("name %q, order %d", p.Name(), p.Order())
+	}
+	r, _ := reqctx.AttachRequest(httptest.NewRequest("POST", "/api/orders", strings.NewReader("0123456789")))
+	reqctx.SetOriginalPath(r)
+	r.URL.Path = "/orders"
+	r.Header.Set("Authorization", "Bearer t")
+	ctx := &GatewayContext{Request: r, ResponseWriter: httptest.NewRecorder(), Attributes: map[string]interface{}{}, Rule: &RuleData{Name: "orders"}, Config: map[string]string{"policy": "strict"}}
+	called := false
+	if err := p.Execute(ctx, func() { called = true }); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got := <-seen
+	if got.method != "POST" || got.path != "/orders" || got.originalPath != "/api/orders" || got.route != "orders" || got.headers["Authorization"] != "Bearer t" {
+		t.Errorf("request = %+v", got)
+	}
+	if got.config["policy"] != "strict" {
+		t.Errorf("config = %v", got.config)
+	}
+	if got.body != "0123" || !got.truncated {
+		t.Errorf("body %q, truncated %v", got.body, got.truncated)
+	}
+	if !called {
+		t.Fatal("next not called")
+	}
+	if r.Header.Get("X-User") != "alice" || r.Header.Get("Authorization") != "" || r.URL.Path != "/internal/orders" {
+		t.Errorf("request not mutated: %v %s", r.Header, r.URL.Path)
+	}
+	if body, _ := io.ReadAll(r.Body); string(body) != `{"rewritten":true}` || r.ContentLength != int64(len(body)) {
+		t.Errorf("body = %q", body)
+	}
+	if ctx.Attributes["tenant"] != "acme" {
+		t.Errorf("attributes = %v", ctx.Attributes)
+	}
+}
+
+func TestExternalPlugin_BodyReplayed(t *testing.T) {
+	srv := newSidecar(t, "0", []byte{}, nil)
+	p := NewExternalPlugin(config.ExternalPlugin{Name: "inspect", Endpoint: srv.URL, SendBody: true, MaxBody: "4B"})
+	r := httptest.NewRequest("POST", "/", strings.NewReader("0123456789"))
+	ctx := &GatewayContext{Request: r, ResponseWriter: httptest.NewRecorder(), Attributes: map[string]interface{}{}}
+	var body []byte
+	p.Execute(ctx, func() { body, _ = io.ReadAll(ctx.Request.Body) })
+	if string(body) != "0123456789" {
+		t.Errorf("body passed on = %q", body)
+	}
+}
+
+func TestExternalPlugin_Respond(t *testing.T) {
+	var reply []byte
+	reply = append(reply, varintField(1, verdictRespond)...)
+	reply = append(reply, varintField(2, http.StatusUnauthorized)...)
+	reply = append(reply, headerField(3, "Www-Authenticate", "Bearer")...)
+	reply = appendString(reply, 4, "denied")
+	srv := newSidecar(t, "0", reply, nil)
+
+	p := NewExternalPlugin(config.ExternalPlugin{Name: "auth", Endpoint: srv.URL, Order: 10})
+	rec := httptest.NewRecorder()
+	ctx := &GatewayContext{Request: httptest.NewRequest("GET", "/", nil), ResponseWriter: rec, Attributes: map[string]interface{}{}}
+	called := false
+	p.Execute(ctx, func() { called = true })
+	if called {
+		t.Error("next called after RESPOND")
+	}
+	if rec.Code != http.StatusUnauthorized || rec.Body.String() != "denied" || rec.Header().Get("Www-Authenticate") != "Bearer" {
+		t.Errorf("response = %d %q %v", rec.Code, rec.Body.String(), rec.Header())
+	}
+	if p.Order() != 10 {
+		t.Errorf("order = %d", p.Order())
+	}
+}
+
+func TestExternalPlugin_Failure(t *testing.T) {
+	srv := newSidecar(t, "14", nil, nil)
+	for _, failOpen := range []bool{false, true} {
+		p := NewExternalPlugin(config.ExternalPlugin{Name: "auth", Endpoint: srv.URL, FailOpen: failOpen})
+		rec := httptest.NewRecorder()
+		ctx := &GatewayContext{Request: httptest.NewRequest("GET", "/", nil), ResponseWriter: rec, Attributes: map[string]interface{}{}}
+		called := false
+		if err := p.Execute(ctx, func() { called = true }); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if called != failOpen {
+			t.Errorf("fail_open %v: next called = %v", failOpen, called)
+		}
+		if !failOpen && rec.Code != http.StatusBadGateway {
+			t.Errorf("status = %d, want 502", rec.Code)
+		}
+	}
+
+	// An unreachable service
+	p := NewExternalPlugin(config.ExternalPlugin{Name: "down", Endpoint: "http://127.0.0.1:1"})
+	rec := httptest.NewRecorder()
+	p.Execute(&GatewayContext{Request: httptest.NewRequest("GET", "/", nil), ResponseWriter: rec, Attributes: map[string]interface{}{}}, func() {})
+	if rec.Code != http.StatusBadGateway {
+		t.Errorf("unreachable: status = %d, want 502", rec.Code)
+	}
+}
+
+func TestParseExternalResult_Invalid(t *testing.T) {
+	for name, msg := range map[string][]byte{
+		"unknown verdict": varintField(1, 7),
+		"bad status":      append(varintField(1, verdictRespond), varintField(2, 42)...),
+		"truncated":       {0x2a, 0x05, 'a'},
+	} {
+		if _, err := parseExternalResult(msg); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestExternalPlugin_ConfigSchema(t *testing.T) {
+	p := NewExternalPlugin(config.ExternalPlugin{Name: "risk", Endpoint: "http://127.0.0.1:9500", ConfigSchema: map[string]any{
+		"type":       "object",
+		"properties": map[string]any{"level": map[string]any{"type": "string", "enum": []any{"low", "high"}}},
+	}})
+	chain := NewChain(p)
+	err := chain.CheckSettings([]config.PluginSetting{{Name: "risk", Routes: []config.PluginBinding{{Route: "orders", Config: map[string]string{"level": "mid"}}}}})
+	if err == nil || !strings.Contains(err.Error(), `"level" must be one of low, high`) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if NewExternalPlugin(config.ExternalPlugin{Name: "plain", Endpoint: "http://127.0.0.1:9500"}).ConfigSchema() != nil {
+		t.Error("plugin without a schema declares one")
+	}
+}
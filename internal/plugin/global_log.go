@@ -3,6 +3,9 @@ package plugin
 import (
 	"log/slog"
 	"time"
+
+	"github.com/oriys/nexus/internal/clientip"
+	"github.com/oriys/nexus/internal/reqctx"
 )
 
 // GlobalLogPlugin logs request details before and after the downstream
@@ -30,9 +33,10 @@ func (p *GlobalLogPlugin) Execute(ctx *GatewayContext, next func()) error {
 	slog.Info("plugin request begin",
 		slog.String("plugin", p.Name()),
 		slog.String("method", r.Method),
-		slog.String("path", r.URL.Path),
+		slog.String("path", reqctx.OriginalPathOf(r)),
 		slog.String("host", r.Host),
 		slog.String("remote_addr", r.RemoteAddr),
+		slog.Any("client_ip", clientip.FromRequest(r)),
 	)
 
 	next()
@@ -40,7 +44,7 @@ func (p *GlobalLogPlugin) Execute(ctx *GatewayContext, next func()) error {
 	slog.Info("plugin request end",
 		slog.String("plugin", p.Name()),
 		slog.String("method", r.Method),
-		slog.String("path", r.URL.Path),
+		slog.String("path", reqctx.OriginalPathOf(r)),
 		slog.Duration("latency", time.Since(start)),
 	)
 
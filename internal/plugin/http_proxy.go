@@ -5,6 +5,8 @@ import (
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+
+	"github.com/oriys/nexus/internal/httperror"
 )
 
 // HttpProxyPlugin forwards the request to the upstream backend using
@@ -27,7 +29,7 @@ func (p *HttpProxyPlugin) Order() int { return 100 }
 // it is the terminal plugin in the chain.
 func (p *HttpProxyPlugin) Execute(ctx *GatewayContext, next func()) error {
 	if ctx.Rule == nil || ctx.Rule.Upstream == "" {
-		http.Error(ctx.ResponseWriter, "no upstream configured", http.StatusBadGateway)
+		httperror.Write(ctx.ResponseWriter, ctx.Request, http.StatusBadGateway, httperror.CodeUpstreamUnavailable, "no upstream configured")
 		return nil
 	}
 
@@ -43,7 +45,7 @@ func (p *HttpProxyPlugin) Execute(ctx *GatewayContext, next func()) error {
 				slog.String("upstream", upstream),
 				slog.String("error", err.Error()),
 			)
-			http.Error(ctx.ResponseWriter, "bad gateway", http.StatusBadGateway)
+			httperror.Write(ctx.ResponseWriter, ctx.Request, http.StatusBadGateway, httperror.CodeBadGateway, "bad gateway")
 			return nil
 		}
 	}
@@ -59,7 +61,7 @@ func (p *HttpProxyPlugin) Execute(ctx *GatewayContext, next func()) error {
 				slog.String("upstream", upstream),
 				slog.String("error", err.Error()),
 			)
-			http.Error(w, "bad gateway", http.StatusBadGateway)
+			httperror.Write(w, r, http.StatusBadGateway, httperror.CodeBadGateway, "bad gateway")
 		},
 	}
 
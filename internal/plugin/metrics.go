@@ -0,0 +1,78 @@
+package plugin
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/oriys/nexus/internal/promtext"
+)
+
+// pluginMetrics holds the metrics of all plugin chains, by plugin name. It
+// lives as long as the process so that counters survive config reloads.
+var pluginMetrics = &pluginMetricSet{values: make(map[string]*pluginStats)}
+
+type pluginMetricSet struct {
+	mu     sync.Mutex
+	values map[string]*pluginStats
+}
+
+type pluginStats struct {
+	executions, errors uint64
+	seconds            float64
+}
+
+// observe records one execution of the named plugin. self is the time spent
+// in the plugin itself, excluding the plugins it passed the request on to.
+func (m *pluginMetricSet) observe(name string, self time.Duration, failed bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	st, ok := m.values[name]
+	if !ok {
+		st = &pluginStats{}
+		m.values[name] = st
+	}
+	st.executions++
+	if failed {
+		st.errors++
+	}
+	st.seconds += self.Seconds()
+}
+
+// WritePluginMetrics writes the plugin metrics in the Prometheus text
+// exposition format.
+func WritePluginMetrics(w io.Writer) error {
+	m := pluginMetrics
+	m.mu.Lock()
+	names := make([]string, 0, len(m.values))
+	for name := range m.values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	stats := make([]pluginStats, len(names))
+	for i, name := range names {
+		stats[i] = *m.values[name]
+	}
+	m.mu.Unlock()
+
+	bw := bufio.NewWriter(w)
+	metrics := []struct {
+		name, help string
+		value      func(pluginStats) string
+	}{
+		{"nexus_plugin_executions_total", "Plugin executions, by plugin.", func(s pluginStats) string { return strconv.FormatUint(s.executions, 10) }},
+		{"nexus_plugin_errors_total", "Plugin executions that returned an error, by plugin.", func(s pluginStats) string { return strconv.FormatUint(s.errors, 10) }},
+		{"nexus_plugin_seconds_total", "Time spent in plugins, excluding the plugins after them in the chain, by plugin.", func(s pluginStats) string { return strconv.FormatFloat(s.seconds, 'g', -1, 64) }},
+	}
+	for _, mt := range metrics {
+		fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s counter\n", mt.name, mt.help, mt.name)
+		for i, name := range names {
+			fmt.Fprintf(bw, "%s{plugin=%s} %s\n", mt.name, promtext.QuoteLabel(name), mt.value(stats[i]))
+		}
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,91 @@
+package plugin
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oriys/nexus/internal/config"
+	"github.com/oriys/nexus/internal/middleware"
+)
+
+// sleepPlugin sleeps before passing the request on.
+type sleepPlugin struct {
+	name  string
+	order int
+	sleep time.Duration
+	err   error
+}
+
+func (p *sleepPlugin) Name() string { return p.name }
+func (p *sleepPlugin) Order() int   { return p.order }
+func (p *sleepPlugin) Execute(ctx *GatewayContext, next func()) error {
+	time.Sleep(p.sleep)
+	next()
+	return p.err
+}
+
+func TestChain_Metrics(t *testing.T) {
+	var buf bytes.Buffer
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+
+	fast := &sleepPlugin{name: "metrics-fast", order: 10}
+	slow := &sleepPlugin{name: "metrics-slow", order: 20, sleep: 20 * time.Millisecond, err: errors.New("boom")}
+	chain := NewChain(fast, slow)
+	handler := middleware.Logging()(chain.Handler())
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	// The slow plugin's time is not attributed to the plugin before it.
+	pluginMetrics.mu.Lock()
+	fastStats, slowStats := *pluginMetrics.values["metrics-fast"], *pluginMetrics.values["metrics-slow"]
+	pluginMetrics.mu.Unlock()
+	if fastStats.executions != 1 || fastStats.errors != 0 || fastStats.seconds >= 0.02 {
+		t.Errorf("fast plugin stats = %+v", fastStats)
+	}
+	if slowStats.executions != 1 || slowStats.errors != 1 || slowStats.seconds < 0.02 {
+		t.Errorf("slow plugin stats = %+v", slowStats)
+	}
+
+	var out bytes.Buffer
+	if err := WritePluginMetrics(&out); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"# TYPE nexus_plugin_seconds_total counter",
+		`nexus_plugin_executions_total{plugin="metrics-fast"} 1`,
+		`nexus_plugin_errors_total{plugin="metrics-slow"} 1`,
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("missing %q in:\n%s", want, out.String())
+		}
+	}
+
+	// The access log entry carries the time of each plugin.
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if strings.Contains(line, `"msg":"request"`) {
+			if !strings.Contains(line, `"plugins":{"metrics-fast":`) || !strings.Contains(line, `"metrics-slow":`) {
+				t.Errorf("plugin timings missing from access log: %s", line)
+			}
+			return
+		}
+	}
+	t.Errorf("no access log entry in %s", buf.String())
+}
+
+func TestChain_MetricsSkipUnboundPlugins(t *testing.T) {
+	p := &stubPlugin{name: "metrics-unbound", order: 10}
+	chain := NewChain(p)
+	chain.Configure([]config.PluginSetting{{Name: "metrics-unbound", Disabled: true}})
+	chain.Handler().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	pluginMetrics.mu.Lock()
+	defer pluginMetrics.mu.Unlock()
+	if _, ok := pluginMetrics.values["metrics-unbound"]; ok {
+		t.Error("disabled plugin recorded")
+	}
+}
@@ -0,0 +1,101 @@
+package plugin
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	goplugin "plugin"
+	"sort"
+)
+
+// APIVersion is the version of the Plugin interface and GatewayContext that
+// native plugins are built against. It changes whenever either changes in
+// a way that breaks compiled plugins.
+const APIVersion = "v1"
+
+// Symbols a native plugin exports. A plugin is a main package built with
+// go build -buildmode=plugin against the same Nexus sources as the
+// gateway:
+//
+//	var NexusPluginAPI = plugin.APIVersion
+//
+//	func NewNexusPlugin() (plugin.Plugin, error) { ... }
+const (
+	nativeAPISymbol = "NexusPluginAPI"
+	nativeNewSymbol = "NewNexusPlugin"
+)
+
+// symbolTable looks up the exported symbols of a native plugin.
+type symbolTable interface {
+	Lookup(name string) (goplugin.Symbol, error)
+}
+
+// LoadNativePlugins opens the compiled Go plugins (*.so files) of dir, in
+// file name order, and creates their plugins. A plugin built for another
+// API version, or with another Go toolchain or version of a shared
+// package, is an error; so is a missing dir.
+func LoadNativePlugins(dir string) ([]Plugin, error) {
+	if _, err := os.Stat(dir); err != nil {
+		return nil, fmt.Errorf("plugin directory: %w", err)
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.so"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	var plugins []Plugin
+	names := make(map[string]string)
+	for _, path := range paths {
+		so, err := goplugin.Open(path)
+		if err != nil {
+			// The runtime checks the toolchain and package versions.
+			return nil, fmt.Errorf("native plugin %s: %w", filepath.Base(path), err)
+		}
+		p, err := newNativePlugin(so)
+		if err != nil {
+			return nil, fmt.Errorf("native plugin %s: %w", filepath.Base(path), err)
+		}
+		if other, ok := names[p.Name()]; ok {
+			return nil, fmt.Errorf("native plugin %s: name %q is already used by %s", filepath.Base(path), p.Name(), other)
+		}
+		names[p.Name()] = filepath.Base(path)
+		plugins = append(plugins, p)
+	}
+	return plugins, nil
+}
+
+// newNativePlugin checks the API version of an opened plugin and creates
+// its plugin.
+func newNativePlugin(so symbolTable) (Plugin, error) {
+	sym, err := so.Lookup(nativeAPISymbol)
+	if err != nil {
+		return nil, fmt.Errorf("missing %s: not a Nexus plugin", nativeAPISymbol)
+	}
+	api, ok := sym.(*string)
+	if !ok {
+		return nil, fmt.Errorf("%s is %T, want string", nativeAPISymbol, sym)
+	}
+	if *api != APIVersion {
+		return nil, fmt.Errorf("built for plugin API %q, the gateway supports %q", *api, APIVersion)
+	}
+	sym, err = so.Lookup(nativeNewSymbol)
+	if err != nil {
+		return nil, fmt.Errorf("missing %s", nativeNewSymbol)
+	}
+	newPlugin, ok := sym.(func() (Plugin, error))
+	if !ok {
+		return nil, fmt.Errorf("%s is %T, want func() (plugin.Plugin, error)", nativeNewSymbol, sym)
+	}
+	p, err := newPlugin()
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return nil, errors.New(nativeNewSymbol + " returned no plugin")
+	}
+	if p.Name() == "" {
+		return nil, errors.New("plugin has no name")
+	}
+	return p, nil
+}
@@ -0,0 +1,60 @@
+package plugin
+
+import (
+	"errors"
+	goplugin "plugin"
+	"strings"
+	"testing"
+)
+
+// fakeSymbols is a symbolTable backed by a map.
+type fakeSymbols map[string]goplugin.Symbol
+
+func (f fakeSymbols) Lookup(name string) (goplugin.Symbol, error) {
+	if sym, ok := f[name]; ok {
+		return sym, nil
+	}
+	return nil, errors.New("symbol " + name + " not found")
+}
+
+func TestNewNativePlugin(t *testing.T) {
+	api, oldAPI, stub := APIVersion, "v0", &stubPlugin{name: "native", order: 30}
+	newStub := func() (Plugin, error) { return stub, nil }
+	tests := []struct {
+		name string
+		syms fakeSymbols
+		want string
+	}{
+		{"valid", fakeSymbols{nativeAPISymbol: &api, nativeNewSymbol: newStub}, ""},
+		{"not a plugin", fakeSymbols{nativeNewSymbol: newStub}, "not a Nexus plugin"},
+		{"old API", fakeSymbols{nativeAPISymbol: &oldAPI, nativeNewSymbol: newStub}, `built for plugin API "v0"`},
+		{"API type", fakeSymbols{nativeAPISymbol: &stub.order, nativeNewSymbol: newStub}, "NexusPluginAPI is *int, want string"},
+		{"no constructor", fakeSymbols{nativeAPISymbol: &api}, "missing NewNexusPlugin"},
+		{"constructor type", fakeSymbols{nativeAPISymbol: &api, nativeNewSymbol: func() Plugin { return stub }}, "want func() (plugin.Plugin, error)"},
+		{"constructor error", fakeSymbols{nativeAPISymbol: &api, nativeNewSymbol: func() (Plugin, error) { return nil, errors.New("bad license") }}, "bad license"},
+		{"no name", fakeSymbols{nativeAPISymbol: &api, nativeNewSymbol: func() (Plugin, error) { return &stubPlugin{}, nil }}, "plugin has no name"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := newNativePlugin(tt.syms)
+			if tt.want == "" {
+				if err != nil || p != stub {
+					t.Fatalf("got %v, %v", p, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestLoadNativePlugins(t *testing.T) {
+	if plugins, err := LoadNativePlugins(t.TempDir()); err != nil || len(plugins) != 0 {
+		t.Errorf("empty directory: %v, %v", plugins, err)
+	}
+	if _, err := LoadNativePlugins(t.TempDir() + "/missing"); err == nil {
+		t.Error("expected an error for a missing directory")
+	}
+}
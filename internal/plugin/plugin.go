@@ -4,9 +4,17 @@
 package plugin
 
 import (
+	"encoding/json"
 	"log/slog"
 	"net/http"
 	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/oriys/nexus/internal/config"
+	"github.com/oriys/nexus/internal/httperror"
+	"github.com/oriys/nexus/internal/middleware"
+	"github.com/oriys/nexus/internal/reqctx"
 )
 
 // RuleData holds the matched route and rule information for a request.
@@ -26,10 +34,21 @@ type RuleData struct {
 type GatewayContext struct {
 	Request        *http.Request
 	ResponseWriter http.ResponseWriter
-	// Attributes stores data passed between plugins.
+	// Attributes stores untyped data passed between plugins, such as the
+	// attributes external plugins return. Prefer typed reqctx keys, which
+	// middleware and route filters see too; see Values.
 	Attributes map[string]interface{}
 	// Rule is the matched routing rule.
 	Rule *RuleData
+	// Config is the configuration bound to the running plugin for the
+	// matched rule, if any.
+	Config map[string]string
+}
+
+// Values returns the request-scoped store of the request, shared with the
+// middleware and route filters; nil when the request has none.
+func (ctx *GatewayContext) Values() *reqctx.Store {
+	return reqctx.FromContext(ctx.Request.Context())
 }
 
 // Plugin defines the interface that all gateway plugins must implement.
@@ -45,9 +64,28 @@ type Plugin interface {
 }
 
 // Chain holds an ordered list of plugins and executes them as a
-// responsibility chain.
+// responsibility chain. Plugin settings can be changed while requests run.
 type Chain struct {
-	plugins []Plugin
+	plugins  []Plugin
+	settings atomic.Pointer[map[string]config.PluginSetting]
+	resolve  func(r *http.Request) *RuleData
+}
+
+// PluginInfo describes a plugin of a chain and its settings.
+type PluginInfo struct {
+	Name    string         `json:"name"`
+	Order   int            `json:"order"`
+	Enabled bool           `json:"enabled"`
+	Routes  []RouteBinding `json:"routes,omitempty"`
+	// ConfigSchema is the schema of the configuration the plugin accepts,
+	// if it declares one.
+	ConfigSchema json.RawMessage `json:"config_schema,omitempty"`
+}
+
+// RouteBinding binds a plugin to a route, with its configuration there.
+type RouteBinding struct {
+	Route  string            `json:"route"`
+	Config map[string]string `json:"config,omitempty"`
 }
 
 // NewChain creates a new plugin chain. Plugins are sorted by Order()
@@ -61,10 +99,85 @@ func NewChain(plugins ...Plugin) *Chain {
 	return &Chain{plugins: sorted}
 }
 
-// Execute runs the plugin chain for the given context.
+// SetResolver sets the function Handler uses to find the rule a request
+// matches; without one, requests have no rule.
+func (c *Chain) SetResolver(resolve func(r *http.Request) *RuleData) {
+	c.resolve = resolve
+}
+
+// Configure replaces the plugin settings. Plugins without a setting are
+// enabled for every request.
+func (c *Chain) Configure(settings []config.PluginSetting) {
+	m := make(map[string]config.PluginSetting, len(settings))
+	for _, ps := range settings {
+		m[ps.Name] = ps
+	}
+	c.settings.Store(&m)
+}
+
+// setting returns the setting of the named plugin.
+func (c *Chain) setting(name string) config.PluginSetting {
+	if m := c.settings.Load(); m != nil {
+		if ps, ok := (*m)[name]; ok {
+			return ps
+		}
+	}
+	return config.PluginSetting{Name: name}
+}
+
+// Plugins describes the plugins of the chain in execution order.
+func (c *Chain) Plugins() []PluginInfo {
+	infos := make([]PluginInfo, len(c.plugins))
+	for i, p := range c.plugins {
+		ps := c.setting(p.Name())
+		infos[i] = PluginInfo{Name: p.Name(), Order: p.Order(), Enabled: !ps.Disabled}
+		if c, ok := p.(Configurable); ok && json.Valid(c.ConfigSchema()) {
+			infos[i].ConfigSchema = c.ConfigSchema()
+		}
+		for _, b := range ps.Routes {
+			infos[i].Routes = append(infos[i].Routes, RouteBinding{Route: b.Route, Config: b.Config})
+		}
+	}
+	return infos
+}
+
+// Has reports whether the chain holds a plugin named name.
+func (c *Chain) Has(name string) bool {
+	for _, p := range c.plugins {
+		if p.Name() == name {
+			return true
+		}
+	}
+	return false
+}
+
+// applies reports whether a plugin with setting ps runs for ctx, and with
+// which bound configuration.
+func applies(ps config.PluginSetting, ctx *GatewayContext) (bool, map[string]string) {
+	if ps.Disabled {
+		return false, nil
+	}
+	if len(ps.Routes) == 0 {
+		return true, nil
+	}
+	if ctx.Rule == nil {
+		return false, nil
+	}
+	for _, b := range ps.Routes {
+		if b.Route == ctx.Rule.Name {
+			return true, b.Config
+		}
+	}
+	return false, nil
+}
+
+// Execute runs the plugin chain for the given context. The time each
+// plugin spends, excluding the plugins it passes the request on to, is
+// recorded in the plugin metrics and the access log entry of the request.
 func (c *Chain) Execute(ctx *GatewayContext) error {
 	var chainErr error
 	index := 0
+	timings := make([]slog.Attr, 0, len(c.plugins))
 
 	var run func()
 	run = func() {
@@ -73,12 +186,36 @@ func (c *Chain) Execute(ctx *GatewayContext) error {
 		}
 		p := c.plugins[index]
 		index++
-		if err := p.Execute(ctx, run); err != nil {
+		ok, cfg := applies(c.setting(p.Name()), ctx)
+		if !ok {
+			run()
+			return
+		}
+		ctx.Config = cfg
+		slot := len(timings)
+		timings = append(timings, slog.Attr{})
+		start := time.Now()
+		var downstream time.Duration
+		next := func() {
+			nextStart := time.Now()
+			run()
+			downstream += time.Since(nextStart)
+			// Restore the configuration of p for the rest of its Execute
+			ctx.Config = cfg
+		}
+		err := p.Execute(ctx, next)
+		if err != nil {
 			chainErr = err
 		}
+		self := time.Since(start) - downstream
+		pluginMetrics.observe(p.Name(), self, err != nil)
+		timings[slot] = slog.Duration(p.Name(), self)
 	}
 	run()
 
+	if len(timings) > 0 {
+		middleware.AddLogAttrs(ctx.Request.Context(), slog.Attr{Key: "plugins", Value: slog.GroupValue(timings...)})
+	}
 	return chainErr
 }
 
@@ -86,18 +223,29 @@ func (c *Chain) Execute(ctx *GatewayContext) error {
 // request and runs it through the plugin chain.
 func (c *Chain) Handler() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r, _ = reqctx.AttachRequest(r)
+		reqctx.SetOriginalPath(r)
 		ctx := &GatewayContext{
 			Request:        r,
 			ResponseWriter: w,
 			Attributes:     make(map[string]interface{}),
 		}
+		if c.resolve != nil {
+			ctx.Rule = c.resolve(r)
+			if ctx.Rule != nil {
+				reqctx.Route.Set(r.Context(), ctx.Rule.Name)
+				if ctx.Rule.Upstream != "" {
+					reqctx.Upstream.Set(r.Context(), ctx.Rule.Upstream)
+				}
+			}
+		}
 		if err := c.Execute(ctx); err != nil {
 			slog.Error("plugin chain error",
-				slog.String("path", r.URL.Path),
+				slog.String("path", reqctx.OriginalPathOf(r)),
 				slog.String("method", r.Method),
 				slog.String("error", err.Error()),
 			)
-			http.Error(w, "internal server error", http.StatusInternalServerError)
+			httperror.Write(w, r, http.StatusInternalServerError, httperror.CodeInternal, "internal server error")
 		}
 	})
 }
@@ -5,6 +5,8 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+
+	"github.com/oriys/nexus/internal/config"
 )
 
 // stubPlugin is a test helper that records calls and can be configured.
@@ -242,3 +244,62 @@ func (a *attrReader) Execute(ctx *GatewayContext, next func()) error {
 	next()
 	return nil
 }
+
+// configRecorder records the configuration it sees on every call.
+type configRecorder struct {
+	name    string
+	order   int
+	configs *[]map[string]string
+}
+
+func (c *configRecorder) Name() string { return c.name }
+func (c *configRecorder) Order() int   { return c.order }
+func (c *configRecorder) Execute(ctx *GatewayContext, next func()) error {
+	*c.configs = append(*c.configs, ctx.Config)
+	next()
+	return nil
+}
+
+func TestChain_Configure(t *testing.T) {
+	var configs []map[string]string
+	a := &stubPlugin{name: "a", order: 10}
+	b := &configRecorder{name: "b", order: 20, configs: &configs}
+	chain := NewChain(a, b)
+	chain.Configure([]config.PluginSetting{
+		{Name: "a", Disabled: true},
+		{Name: "b", Routes: []config.PluginBinding{{Route: "orders", Config: map[string]string{"mode": "strict"}}}},
+	})
+
+	run := func(rule *RuleData) {
+		a.called = false
+		ctx := &GatewayContext{
+			Request:        httptest.NewRequest("GET", "/", nil),
+			ResponseWriter: httptest.NewRecorder(),
+			Attributes:     make(map[string]interface{}),
+			Rule:           rule,
+		}
+		if err := chain.Execute(ctx); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	run(&RuleData{Name: "orders"})
+	run(&RuleData{Name: "users"})
+	run(nil)
+	if a.called {
+		t.Error("disabled plugin was called")
+	}
+	if len(configs) != 1 || configs[0]["mode"] != "strict" {
+		t.Errorf("bound plugin configs = %v, want one call with mode=strict", configs)
+	}
+
+	infos := chain.Plugins()
+	if len(infos) != 2 || infos[0].Enabled || !infos[1].Enabled || len(infos[1].Routes) != 1 || infos[1].Routes[0].Route != "orders" {
+		t.Errorf("Plugins() = %+v", infos)
+	}
+
+	chain.Configure(nil)
+	run(nil)
+	if !a.called || len(configs) != 2 || configs[1] != nil {
+		t.Errorf("after reset: a called %v, configs %v", a.called, configs)
+	}
+}
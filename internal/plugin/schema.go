@@ -0,0 +1,253 @@
+package plugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/oriys/nexus/internal/config"
+)
+
+// Configurable is implemented by plugins that declare the configuration
+// they accept when bound to a route. ConfigSchema returns a JSON schema
+// document; configurations that do not satisfy it are rejected when the
+// plugin settings are loaded or changed through the admin API, so that the
+// plugin never runs with them.
+//
+// Configuration values are strings, so schemas describe an object whose
+// properties are checked on their text. The supported keywords are type
+// ("object" at the top; "string", "integer", "number" or "boolean" for
+// properties), properties, required, additionalProperties (a boolean),
+// enum, pattern, minimum, maximum, minLength, maxLength and description.
+type Configurable interface {
+	ConfigSchema() []byte
+}
+
+// Schema is a parsed plugin configuration schema.
+type Schema struct {
+	Type                 string             `json:"type,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *bool              `json:"additionalProperties,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+
+	pattern *regexp.Regexp
+}
+
+// ParseSchema parses a plugin configuration schema, rejecting keywords and
+// types the validator does not support rather than ignoring them.
+func ParseSchema(doc []byte) (*Schema, error) {
+	var s Schema
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&s); err != nil {
+		return nil, fmt.Errorf("invalid config schema: %w", err)
+	}
+	if s.Type != "" && s.Type != "object" {
+		return nil, fmt.Errorf("invalid config schema: type must be \"object\", not %q", s.Type)
+	}
+	for name, prop := range s.Properties {
+		if prop == nil {
+			return nil, fmt.Errorf("invalid config schema: property %q is null", name)
+		}
+		if err := prop.compileProperty(); err != nil {
+			return nil, fmt.Errorf("invalid config schema: property %q: %w", name, err)
+		}
+	}
+	for _, name := range s.Required {
+		if _, ok := s.Properties[name]; !ok && s.AdditionalProperties != nil && !*s.AdditionalProperties {
+			return nil, fmt.Errorf("invalid config schema: required property %q is not allowed by additionalProperties", name)
+		}
+	}
+	return &s, nil
+}
+
+// compileProperty checks the schema of a property and compiles its pattern.
+func (s *Schema) compileProperty() error {
+	switch s.Type {
+	case "", "string", "integer", "number", "boolean":
+	default:
+		return fmt.Errorf("unsupported type %q", s.Type)
+	}
+	if s.Properties != nil || s.Required != nil || s.AdditionalProperties != nil {
+		return fmt.Errorf("nested objects are not supported")
+	}
+	if s.Pattern != "" {
+		re, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return fmt.Errorf("pattern: %w", err)
+		}
+		s.pattern = re
+	}
+	return nil
+}
+
+// Validate checks a plugin configuration against the schema, reporting
+// every problem, in property name order.
+func (s *Schema) Validate(cfg map[string]string) error {
+	var problems []string
+	for _, name := range s.Required {
+		if _, ok := cfg[name]; !ok {
+			problems = append(problems, fmt.Sprintf("%q is required", name))
+		}
+	}
+	names := make([]string, 0, len(cfg))
+	for name := range cfg {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		prop, ok := s.Properties[name]
+		if !ok {
+			if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+				problems = append(problems, fmt.Sprintf("%q is not a known setting (known: %s)", name, strings.Join(s.propertyNames(), ", ")))
+			}
+			continue
+		}
+		if err := prop.check(cfg[name]); err != nil {
+			problems = append(problems, fmt.Sprintf("%q %s", name, err))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// propertyNames returns the declared property names, sorted.
+func (s *Schema) propertyNames() []string {
+	names := make([]string, 0, len(s.Properties))
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// check checks a property value, returning an error that completes the
+// sentence starting with the property name.
+func (s *Schema) check(v string) error {
+	var num float64
+	switch s.Type {
+	case "integer":
+		i, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+		if err != nil {
+			return fmt.Errorf("must be an integer, got %q", v)
+		}
+		num = float64(i)
+	case "number":
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			return fmt.Errorf("must be a number, got %q", v)
+		}
+		num = f
+	case "boolean":
+		if _, err := strconv.ParseBool(strings.TrimSpace(v)); err != nil {
+			return fmt.Errorf("must be true or false, got %q", v)
+		}
+	}
+	if s.Type == "integer" || s.Type == "number" {
+		if s.Minimum != nil && num < *s.Minimum {
+			return fmt.Errorf("must be at least %s, got %s", formatNumber(*s.Minimum), v)
+		}
+		if s.Maximum != nil && num > *s.Maximum {
+			return fmt.Errorf("must be at most %s, got %s", formatNumber(*s.Maximum), v)
+		}
+	}
+	n := utf8.RuneCountInString(v)
+	if s.MinLength != nil && n < *s.MinLength {
+		return fmt.Errorf("must be at least %d characters long", *s.MinLength)
+	}
+	if s.MaxLength != nil && n > *s.MaxLength {
+		return fmt.Errorf("must be at most %d characters long", *s.MaxLength)
+	}
+	if s.pattern != nil && !s.pattern.MatchString(v) {
+		return fmt.Errorf("must match %s, got %q", s.Pattern, v)
+	}
+	if len(s.Enum) > 0 {
+		allowed := make([]string, len(s.Enum))
+		for i, e := range s.Enum {
+			allowed[i] = enumString(e)
+			if allowed[i] == v {
+				return nil
+			}
+		}
+		return fmt.Errorf("must be one of %s, got %q", strings.Join(allowed, ", "), v)
+	}
+	return nil
+}
+
+// enumString formats an enum value as configuration text.
+func enumString(v any) string {
+	switch e := v.(type) {
+	case string:
+		return e
+	case float64:
+		return formatNumber(e)
+	case bool:
+		return strconv.FormatBool(e)
+	case nil:
+		return "null"
+	}
+	return fmt.Sprint(v)
+}
+
+func formatNumber(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// schema returns the parsed configuration schema of p, nil when it declares
+// none.
+func schema(p Plugin) (*Schema, error) {
+	c, ok := p.(Configurable)
+	if !ok {
+		return nil, nil
+	}
+	doc := c.ConfigSchema()
+	if len(doc) == 0 {
+		return nil, nil
+	}
+	return ParseSchema(doc)
+}
+
+// CheckSettings checks plugin settings before they are applied with
+// Configure: the schemas the plugins declare must be valid, every setting
+// must name a plugin of the chain, and each configuration bound to a route
+// must satisfy the schema of its plugin.
+func (c *Chain) CheckSettings(settings []config.PluginSetting) error {
+	schemas := make(map[string]*Schema, len(c.plugins))
+	for _, p := range c.plugins {
+		sch, err := schema(p)
+		if err != nil {
+			return fmt.Errorf("plugin %q: %w", p.Name(), err)
+		}
+		schemas[p.Name()] = sch
+	}
+	for _, ps := range settings {
+		sch, ok := schemas[ps.Name]
+		if !ok {
+			return fmt.Errorf("plugin %q is not in the plugin chain", ps.Name)
+		}
+		if sch == nil {
+			continue
+		}
+		for _, b := range ps.Routes {
+			if err := sch.Validate(b.Config); err != nil {
+				return fmt.Errorf("plugin %q route %q config: %w", ps.Name, b.Route, err)
+			}
+		}
+	}
+	return nil
+}
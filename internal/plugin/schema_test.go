@@ -0,0 +1,105 @@
+package plugin
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/oriys/nexus/internal/config"
+)
+
+// schemaPlugin is a plugin declaring a configuration schema.
+type schemaPlugin struct {
+	stubPlugin
+	schema string
+}
+
+func (p *schemaPlugin) ConfigSchema() []byte { return []byte(p.schema) }
+
+const rateSchema = `{
+  "type": "object",
+  "properties": {
+    "mode":  {"type": "string", "enum": ["soft", "hard"]},
+    "limit": {"type": "integer", "minimum": 1, "maximum": 1000},
+    "ratio": {"type": "number"},
+    "log":   {"type": "boolean"},
+    "key":   {"type": "string", "pattern": "^[a-z-]+$", "maxLength": 8}
+  },
+  "required": ["limit"],
+  "additionalProperties": false
+}`
+
+func TestSchema_Validate(t *testing.T) {
+	s, err := ParseSchema([]byte(rateSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		cfg  map[string]string
+		want string
+	}{
+		{map[string]string{"limit": "10", "mode": "hard", "ratio": "0.5", "log": "true", "key": "client"}, ""},
+		{map[string]string{}, `"limit" is required`},
+		{map[string]string{"limit": "ten"}, `"limit" must be an integer, got "ten"`},
+		{map[string]string{"limit": "0"}, `"limit" must be at least 1, got 0`},
+		{map[string]string{"limit": "5000"}, `"limit" must be at most 1000`},
+		{map[string]string{"limit": "1", "mode": "medium"}, `"mode" must be one of soft, hard, got "medium"`},
+		{map[string]string{"limit": "1", "ratio": "half"}, `"ratio" must be a number`},
+		{map[string]string{"limit": "1", "log": "yes"}, `"log" must be true or false`},
+		{map[string]string{"limit": "1", "key": "Client"}, `"key" must match ^[a-z-]+$`},
+		{map[string]string{"limit": "1", "key": "very-long-key"}, `"key" must be at most 8 characters long`},
+		{map[string]string{"limit": "1", "burst": "2"}, `"burst" is not a known setting (known: key, limit, log, mode, ratio)`},
+		{map[string]string{"mode": "x"}, `"limit" is required; "mode" must be one of`},
+	}
+	for _, tt := range tests {
+		err := s.Validate(tt.cfg)
+		if tt.want == "" && err != nil || tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)) {
+			t.Errorf("%v: expected %q, got %v", tt.cfg, tt.want, err)
+		}
+	}
+}
+
+func TestParseSchema_Invalid(t *testing.T) {
+	for doc, want := range map[string]string{
+		`{"type": "array"}`:                                  `type must be "object"`,
+		`{"properties": {"a": {"type": "object"}}}`:          `property "a": unsupported type "object"`,
+		`{"properties": {"a": {"pattern": "("}}}`:            `property "a": pattern`,
+		`{"properties": {"a": {"format": "email"}}}`:         `unknown field "format"`,
+		`{"required": ["a"], "additionalProperties": false}`: `required property "a" is not allowed`,
+		`not json`: "invalid config schema",
+	} {
+		if _, err := ParseSchema([]byte(doc)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: expected %q, got %v", doc, want, err)
+		}
+	}
+}
+
+func TestChain_CheckSettings(t *testing.T) {
+	rate := &schemaPlugin{stubPlugin: stubPlugin{name: "rate", order: 10}, schema: rateSchema}
+	chain := NewChain(rate, &stubPlugin{name: "plain", order: 20})
+	bind := func(name string, cfg map[string]string) []config.PluginSetting {
+		return []config.PluginSetting{{Name: name, Routes: []config.PluginBinding{{Route: "orders", Config: cfg}}}}
+	}
+	if err := chain.CheckSettings(bind("rate", map[string]string{"limit": "5"})); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := chain.CheckSettings(bind("plain", map[string]string{"anything": "goes"})); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	err := chain.CheckSettings(bind("rate", map[string]string{"limit": "-1"}))
+	if err == nil || err.Error() != `plugin "rate" route "orders" config: "limit" must be at least 1, got -1` {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := chain.CheckSettings(bind("missing", nil)); err == nil || !strings.Contains(err.Error(), "not in the plugin chain") {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if infos := chain.Plugins(); string(infos[0].ConfigSchema) != rateSchema || infos[1].ConfigSchema != nil {
+		t.Errorf("unexpected schemas: %s, %s", infos[0].ConfigSchema, infos[1].ConfigSchema)
+	}
+
+	// A plugin declaring an invalid schema is reported even without settings.
+	bad := NewChain(&schemaPlugin{stubPlugin: stubPlugin{name: "bad"}, schema: `{"type": "string"}`})
+	if err := bad.CheckSettings(nil); err == nil || !strings.Contains(err.Error(), `plugin "bad": invalid config schema`) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,108 @@
+// Package prefixtree implements a radix tree mapping string prefixes to
+// values, so that the prefixes of a request path can be found in time
+// proportional to the length of the path rather than the number of routes.
+package prefixtree
+
+// Tree maps prefixes to values. The zero value is an empty tree. A Tree is
+// not safe for concurrent modification, but may be read concurrently once
+// built.
+type Tree[V any] struct {
+	root node[V]
+	size int
+}
+
+type node[V any] struct {
+	label    string // the edge from the parent
+	values   []V
+	children []*node[V] // distinct first bytes of label
+}
+
+// Len returns the number of values in the tree.
+func (t *Tree[V]) Len() int {
+	return t.size
+}
+
+// Insert adds v under prefix. Values under the same prefix keep their
+// insertion order.
+func (t *Tree[V]) Insert(prefix string, v V) {
+	t.size++
+	n := &t.root
+	for {
+		if prefix == "" {
+			n.values = append(n.values, v)
+			return
+		}
+		i := n.child(prefix[0])
+		if i < 0 {
+			n.children = append(n.children, &node[V]{label: prefix, values: []V{v}})
+			return
+		}
+		c := n.children[i]
+		l := commonPrefix(prefix, c.label)
+		if l < len(c.label) {
+			// Split the edge at the common prefix
+			split := &node[V]{label: c.label[:l], children: []*node[V]{c}}
+			c.label = c.label[l:]
+			n.children[i] = split
+			c = split
+		}
+		prefix = prefix[l:]
+		n = c
+	}
+}
+
+// Match calls fn for each prefix of s in the tree, longest first, with the
+// values under it, until fn returns true. It reports whether fn did.
+func (t *Tree[V]) Match(s string, fn func(prefix string, values []V) bool) bool {
+	type match struct {
+		n   *node[V]
+		end int
+	}
+	var buf [16]match
+	matches := buf[:0]
+
+	n, end := &t.root, 0
+	for {
+		if len(n.values) > 0 {
+			matches = append(matches, match{n, end})
+		}
+		if end == len(s) {
+			break
+		}
+		i := n.child(s[end])
+		if i < 0 {
+			break
+		}
+		c := n.children[i]
+		if len(s)-end < len(c.label) || s[end:end+len(c.label)] != c.label {
+			break
+		}
+		n, end = c, end+len(c.label)
+	}
+
+	for i := len(matches) - 1; i >= 0; i-- {
+		if fn(s[:matches[i].end], matches[i].n.values) {
+			return true
+		}
+	}
+	return false
+}
+
+func (n *node[V]) child(b byte) int {
+	for i, c := range n.children {
+		if c.label[0] == b {
+			return i
+		}
+	}
+	return -1
+}
+
+func commonPrefix(a, b string) int {
+	n := min(len(a), len(b))
+	for i := range n {
+		if a[i] != b[i] {
+			return i
+		}
+	}
+	return n
+}
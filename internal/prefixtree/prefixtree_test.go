@@ -0,0 +1,67 @@
+package prefixtree
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func matches(t *Tree[string], s string) []string {
+	var got []string
+	t.Match(s, func(prefix string, values []string) bool {
+		got = append(got, prefix+"="+strings.Join(values, ","))
+		return false
+	})
+	return got
+}
+
+func TestTree_Match(t *testing.T) {
+	var tree Tree[string]
+	for _, p := range []string{"/api/v1", "/api", "/", "/api/v2", "/apiary", "/api", "/api/v1/users"} {
+		tree.Insert(p, p+"#"+fmt.Sprint(tree.Len()))
+	}
+	if tree.Len() != 7 {
+		t.Errorf("Len() = %d, want 7", tree.Len())
+	}
+
+	tests := map[string][]string{
+		"/api/v1/users/7": {"/api/v1/users=/api/v1/users#6", "/api/v1=/api/v1#0", "/api=/api#1,/api#5", "/=/#2"},
+		"/api/v":          {"/api=/api#1,/api#5", "/=/#2"},
+		"/apiary":         {"/apiary=/apiary#4", "/api=/api#1,/api#5", "/=/#2"},
+		"/ap":             {"/=/#2"},
+		"/api":            {"/api=/api#1,/api#5", "/=/#2"},
+		"other":           nil,
+		"":                nil,
+	}
+	for s, want := range tests {
+		if got := matches(&tree, s); !reflect.DeepEqual(got, want) {
+			t.Errorf("Match(%q) = %v, want %v", s, got, want)
+		}
+	}
+}
+
+func TestTree_MatchStops(t *testing.T) {
+	var tree Tree[int]
+	tree.Insert("/a", 1)
+	tree.Insert("/a/b", 2)
+	var seen []int
+	found := tree.Match("/a/b/c", func(_ string, values []int) bool {
+		seen = append(seen, values...)
+		return true
+	})
+	if !found || !reflect.DeepEqual(seen, []int{2}) {
+		t.Errorf("Match = %v, saw %v", found, seen)
+	}
+	if tree.Match("/b", func(string, []int) bool { return true }) {
+		t.Error("expected no match")
+	}
+}
+
+func TestTree_EmptyPrefix(t *testing.T) {
+	var tree Tree[int]
+	tree.Insert("", 1)
+	if got := tree.Match("/anything", func(prefix string, values []int) bool { return prefix == "" && values[0] == 1 }); !got {
+		t.Error("the empty prefix should match any string")
+	}
+}
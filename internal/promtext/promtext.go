@@ -0,0 +1,12 @@
+// Package promtext holds helpers shared by the packages that write metrics
+// in the Prometheus text exposition format.
+package promtext
+
+import "strings"
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// QuoteLabel quotes a Prometheus label value.
+func QuoteLabel(v string) string {
+	return `"` + labelEscaper.Replace(v) + `"`
+}
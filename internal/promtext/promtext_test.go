@@ -0,0 +1,9 @@
+package promtext
+
+import "testing"
+
+func TestQuoteLabel(t *testing.T) {
+	if got := QuoteLabel("a\"b\\c\nd"); got != `"a\"b\\c\nd"` {
+		t.Errorf("QuoteLabel = %s", got)
+	}
+}
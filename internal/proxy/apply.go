@@ -0,0 +1,20 @@
+package proxy
+
+import "github.com/oriys/nexus/internal/config"
+
+// Apply replaces the route table of router and the upstream groups of um
+// together. Both are built first, then swapped while no Proxy request is
+// between matching a route and picking its target, so requests see the
+// routes and upstreams of either the old or the new configuration.
+func Apply(router *Router, um *UpstreamManager, cfg *config.Config) {
+	table := buildRouteTable(cfg.Routes, cfg.PathNormalization)
+	if fb := cfg.Server.Fallback; fb != nil && fb.Cluster == "" {
+		table.notFoundStatus, table.notFoundBody = fb.Response()
+	}
+	groups := buildUpstreamGroups(cfg.Upstreams)
+
+	router.swapMu.Lock()
+	defer router.swapMu.Unlock()
+	router.store(table)
+	um.store(groups)
+}
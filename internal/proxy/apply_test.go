@@ -0,0 +1,80 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oriys/nexus/internal/config"
+)
+
+func TestApply_SwapsRoutesAndUpstreamsTogether(t *testing.T) {
+	// Both configurations route /api to an upstream defined only in that
+	// configuration.
+	routes := func(upstream string) []config.Route {
+		return []config.Route{{Name: "api", Paths: []config.PathRule{{Path: "/api", Type: "prefix"}}, Upstream: upstream}}
+	}
+	upstreams := func(name, addr string) []config.Upstream {
+		return []config.Upstream{{Name: name, Targets: []config.Target{{Address: addr}}}}
+	}
+	router, um := NewRouter(), NewUpstreamManager()
+	Apply(router, um, &config.Config{Routes: routes("blue"), Upstreams: upstreams("blue", "10.0.0.1:80")})
+
+	resolve := func() string {
+		result, ok := router.Match(httptest.NewRequest("GET", "/api/x", nil))
+		if !ok {
+			return "no route"
+		}
+		target, ok := um.PeekTarget(result.Upstream)
+		if !ok {
+			return "no upstream " + result.Upstream
+		}
+		return target
+	}
+
+	// A request between matching and picking its target, as Proxy does,
+	// holds back the swap.
+	router.swapMu.RLock()
+	applied := make(chan struct{})
+	go func() {
+		Apply(router, um, &config.Config{Routes: routes("green"), Upstreams: upstreams("green", "10.0.0.2:80")})
+		close(applied)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	if got := resolve(); got != "10.0.0.1:80" {
+		t.Errorf("during a request: expected the old target, got %s", got)
+	}
+	router.swapMu.RUnlock()
+
+	<-applied
+	if got := resolve(); got != "10.0.0.2:80" {
+		t.Errorf("after the swap: expected the new target, got %s", got)
+	}
+}
+
+func TestApply_FallbackResponse(t *testing.T) {
+	router, um := NewRouter(), NewUpstreamManager()
+	p := NewProxy(router, um)
+
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest("GET", "/missing", nil))
+	if w.Code != http.StatusNotFound || w.Body.String() != `{"error":"no_route","message":"no matching route"}`+"\n" {
+		t.Errorf("default: got %d %q", w.Code, w.Body)
+	}
+
+	Apply(router, um, &config.Config{Server: config.ServerConfig{Fallback: &config.FallbackConfig{Status: http.StatusGone}}})
+	w = httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest("GET", "/missing", nil))
+	if w.Code != http.StatusGone || w.Header().Get("Content-Type") != "application/json" || !strings.Contains(w.Body.String(), `"error":"no_route"`) {
+		t.Errorf("fallback status: got %d %q %s", w.Code, w.Header().Get("Content-Type"), w.Body)
+	}
+
+	Apply(router, um, &config.Config{Server: config.ServerConfig{Fallback: &config.FallbackConfig{Body: `{"error":"nothing here"}`}}})
+	w = httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest("GET", "/missing", nil))
+	if w.Code != http.StatusNotFound || w.Header().Get("Content-Type") != "application/json" || w.Body.String() != `{"error":"nothing here"}` {
+		t.Errorf("fallback body: got %d %q %s", w.Code, w.Header().Get("Content-Type"), w.Body)
+	}
+}
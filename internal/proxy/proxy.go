@@ -8,6 +8,9 @@ import (
 	"strings"
 
 	"github.com/oriys/nexus/internal/config"
+	"github.com/oriys/nexus/internal/httperror"
+	"github.com/oriys/nexus/internal/pathmatch"
+	"github.com/oriys/nexus/internal/reqctx"
 )
 
 // Proxy is the main reverse proxy handler that routes requests to upstreams.
@@ -26,19 +29,42 @@ func NewProxy(router *Router, upstream *UpstreamManager) *Proxy {
 
 // ServeHTTP implements the http.Handler interface.
 func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Match and pick the target from one configuration; see Apply.
+	r, _ = reqctx.AttachRequest(r)
+	reqctx.SetOriginalPath(r)
+	p.router.swapMu.RLock()
+	p.router.Normalize(r)
 	result, matched := p.router.Match(r)
+	var targetAddr string
+	var ok bool
+	if matched {
+		targetAddr, ok = p.upstream.GetTarget(result.Upstream)
+	}
+	table := p.router.table.Load()
+	p.router.swapMu.RUnlock()
 	if !matched {
-		http.Error(w, "no matching route", http.StatusNotFound)
+		if table.notFoundBody == nil {
+			status := table.notFoundStatus
+			if status == 0 {
+				status = http.StatusNotFound
+			}
+			httperror.Write(w, r, status, httperror.CodeNoRoute, "no matching route")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(table.notFoundStatus)
+		w.Write(table.notFoundBody)
 		return
 	}
 
+	reqctx.Route.Set(r.Context(), result.Route.Name)
 	upstreamName := result.Upstream
-	targetAddr, ok := p.upstream.GetTarget(upstreamName)
 	if !ok {
 		slog.Error("upstream not found", slog.String("upstream", upstreamName))
-		http.Error(w, "upstream not available", http.StatusBadGateway)
+		httperror.Write(w, r, http.StatusBadGateway, httperror.CodeUpstreamUnavailable, "upstream not available")
 		return
 	}
+	reqctx.Upstream.Set(r.Context(), targetAddr)
 
 	target, err := url.Parse("http://" + targetAddr)
 	if err != nil {
@@ -46,12 +72,18 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			slog.String("target", targetAddr),
 			slog.String("error", err.Error()),
 		)
-		http.Error(w, "bad gateway", http.StatusBadGateway)
+		httperror.Write(w, r, http.StatusBadGateway, httperror.CodeBadGateway, "bad gateway")
 		return
 	}
 
 	// Determine the matched path prefix for path rewriting
-	matchedPath := findMatchedPath(result.Route, r.URL.Path)
+	matchedPath := result.MatchedPath
+	if matchedPath == "" {
+		matchedPath = findMatchedPath(result.Route, r.URL.Path)
+	}
+	if result.Params != nil {
+		r = r.WithContext(pathmatch.WithParams(r.Context(), result.Params))
+	}
 
 	// Apply request rewriting before proxying
 	if err := ApplyRewrite(r, result.Route, matchedPath); err != nil {
@@ -59,7 +91,7 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			slog.String("route", result.Route.Name),
 			slog.String("error", err.Error()),
 		)
-		http.Error(w, "request rewrite failed", http.StatusBadRequest)
+		httperror.Write(w, r, http.StatusBadRequest, "rewrite_failed", "request rewrite failed")
 		return
 	}
 
@@ -74,7 +106,7 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 				slog.String("target", targetAddr),
 				slog.String("error", err.Error()),
 			)
-			http.Error(w, "bad gateway", http.StatusBadGateway)
+			httperror.Write(w, r, http.StatusBadGateway, httperror.CodeBadGateway, "bad gateway")
 		},
 	}
 
@@ -9,8 +9,10 @@ import (
 	"log/slog"
 	"net/http"
 	"strings"
+	"sync"
 
 	"github.com/oriys/nexus/internal/config"
+	"github.com/oriys/nexus/internal/pathmatch"
 )
 
 // applyHTTPRewrite applies HTTP-to-HTTP request rewriting rules.
@@ -20,7 +22,14 @@ func applyHTTPRewrite(r *http.Request, rw *config.RewriteRule, matchedPath strin
 	}
 
 	// Apply path rewrite
-	if rw.PathRewrite != nil && rw.PathRewrite.Prefix != "" {
+	if rw.PathRewrite != nil && rw.PathRewrite.Path != "" {
+		r.URL.Path = pathmatch.Expand(rw.PathRewrite.Path, pathmatch.Params(r.Context()))
+		r.URL.RawPath = ""
+	} else if rw.PathRewrite != nil && rw.PathRewrite.RegexRewrite != nil {
+		if re := regexRewrite(rw.PathRewrite.RegexRewrite); re != nil {
+			re.Apply(r.URL)
+		}
+	} else if rw.PathRewrite != nil && rw.PathRewrite.Prefix != "" {
 		originalPath := r.URL.Path
 		if matchedPath != "" && strings.HasPrefix(originalPath, matchedPath) {
 			r.URL.Path = rw.PathRewrite.Prefix + strings.TrimPrefix(originalPath, matchedPath)
@@ -32,20 +41,40 @@ func applyHTTPRewrite(r *http.Request, rw *config.RewriteRule, matchedPath strin
 	applyHeaderRewrite(r, rw.Headers)
 }
 
-// applyHeaderRewrite applies header manipulation rules to the request.
+// regexRewrites caches the compiled regex_rewrite rules of legacy routes,
+// keyed by a config.RegexRewrite value.
+var regexRewrites sync.Map
+
+// regexRewrite returns the compiled form of rr, or nil when its pattern does
+// not compile; validation rejects such patterns.
+func regexRewrite(rr *config.RegexRewrite) *pathmatch.Rewrite {
+	if v, ok := regexRewrites.Load(*rr); ok {
+		return v.(*pathmatch.Rewrite)
+	}
+	rw, err := pathmatch.CompileRewrite(rr.Pattern, rr.Substitution)
+	if err != nil {
+		return nil
+	}
+	regexRewrites.Store(*rr, rw)
+	return rw
+}
+
+// applyHeaderRewrite applies header manipulation rules to the request. Path
+// parameters in the added and set values are substituted.
 func applyHeaderRewrite(r *http.Request, headers *config.HeaderRewrite) {
 	if headers == nil {
 		return
 	}
+	params := pathmatch.Params(r.Context())
 
 	// Add headers (append)
 	for key, value := range headers.Add {
-		r.Header.Add(key, value)
+		r.Header.Add(key, pathmatch.Expand(value, params))
 	}
 
 	// Set headers (overwrite)
 	for key, value := range headers.Set {
-		r.Header.Set(key, value)
+		r.Header.Set(key, pathmatch.Expand(value, params))
 	}
 
 	// Remove headers
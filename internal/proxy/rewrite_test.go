@@ -11,6 +11,7 @@ import (
 	"testing"
 
 	"github.com/oriys/nexus/internal/config"
+	"github.com/oriys/nexus/internal/pathmatch"
 )
 
 func TestApplyRewrite_NilRewrite(t *testing.T) {
@@ -94,6 +95,59 @@ func TestApplyHTTPRewrite_PathRewriteEmptyPrefix(t *testing.T) {
 	}
 }
 
+func TestApplyHTTPRewrite_PathTemplate(t *testing.T) {
+	req := httptest.NewRequest("GET", "http://example.com/users/42/orders/7", nil)
+	req = req.WithContext(pathmatch.WithParams(req.Context(), map[string]string{"id": "42", "oid": "7"}))
+	route := config.Route{
+		Name:     "test",
+		Upstream: "backend",
+		Rewrite: &config.RewriteRule{
+			PathRewrite: &config.PathRewrite{Path: "/internal/orders/{oid}"},
+			Headers:     &config.HeaderRewrite{Set: map[string]string{"X-User-ID": "{id}"}},
+		},
+	}
+
+	if err := ApplyRewrite(req, route, "/users/42/orders/7"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if req.URL.Path != "/internal/orders/7" {
+		t.Errorf("expected /internal/orders/7, got %s", req.URL.Path)
+	}
+	if got := req.Header.Get("X-User-ID"); got != "42" {
+		t.Errorf("expected X-User-ID 42, got %q", got)
+	}
+}
+
+func TestApplyHTTPRewrite_RegexRewrite(t *testing.T) {
+	req := httptest.NewRequest("GET", "http://example.com/api/v2/users/42?limit=5", nil)
+	route := config.Route{
+		Name:     "test",
+		Upstream: "backend",
+		Rewrite: &config.RewriteRule{
+			PathRewrite: &config.PathRewrite{RegexRewrite: &config.RegexRewrite{
+				Pattern:      `^/api/v(\d+)/(.*)$`,
+				Substitution: "/internal/$2?ver=$1",
+			}},
+		},
+	}
+
+	if err := ApplyRewrite(req, route, "/api"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if req.URL.Path != "/internal/users/42" || req.URL.RawQuery != "ver=2&limit=5" {
+		t.Errorf("expected /internal/users/42?ver=2&limit=5, got %s", req.URL.RequestURI())
+	}
+
+	// Paths the pattern does not match are forwarded unchanged
+	req = httptest.NewRequest("GET", "http://example.com/api/users", nil)
+	if err := ApplyRewrite(req, route, "/api"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if req.URL.Path != "/api/users" {
+		t.Errorf("expected /api/users, got %s", req.URL.Path)
+	}
+}
+
 func TestApplyHTTPRewrite_HeaderAdd(t *testing.T) {
 	req := httptest.NewRequest("GET", "http://example.com/api", nil)
 	route := config.Route{
@@ -3,10 +3,14 @@ package proxy
 import (
 	"log/slog"
 	"net/http"
+	"sort"
 	"strings"
+	"sync"
 	"sync/atomic"
 
 	"github.com/oriys/nexus/internal/config"
+	"github.com/oriys/nexus/internal/pathmatch"
+	"github.com/oriys/nexus/internal/prefixtree"
 )
 
 // routeEntry is an internal representation of a route for matching.
@@ -17,10 +21,44 @@ type routeEntry struct {
 
 // Router matches incoming requests to upstream backends based on Host and Path rules.
 type Router struct {
+	table atomic.Pointer[routeTable]
+	// swapMu orders Apply with the requests resolving a route and then its
+	// upstream target, so that no request sees the routes of one
+	// configuration with the upstreams of another.
+	swapMu sync.RWMutex
+}
+
+// routeTable is the immutable route table swapped in by Reload.
+type routeTable struct {
 	// exact stores exact host+path → routeEntry mappings.
-	exact atomic.Pointer[map[string]routeEntry]
-	// prefixes stores prefix-based route entries sorted by path length (longest first).
-	prefixes atomic.Pointer[[]prefixEntry]
+	exact map[string]routeEntry
+	// patterns stores template and regex route entries by priority, then in
+	// configuration order. They are tried after exact paths and before
+	// prefixes.
+	patterns []patternEntry
+	// prefixes stores prefix-based route entries sorted by priority, then by
+	// path length (longest first).
+	prefixes []prefixEntry
+	// prefixLevels indexes prefixes in a prefix tree per priority, highest
+	// first, so matching does not scan every prefix.
+	prefixLevels []prefixLevel
+	// norm is the path_normalization the keys above are normalized by.
+	norm config.PathNormalization
+	// notFoundStatus and notFoundBody answer unmatched requests with JSON,
+	// from server.fallback; a nil body answers with plain text.
+	notFoundStatus int
+	notFoundBody   []byte
+}
+
+type prefixLevel struct {
+	priority int
+	tree     prefixtree.Tree[*prefixEntry]
+}
+
+type patternEntry struct {
+	host    string
+	pattern *pathmatch.Pattern
+	entry   routeEntry
 }
 
 type prefixEntry struct {
@@ -32,16 +70,24 @@ type prefixEntry struct {
 // NewRouter creates a new Router.
 func NewRouter() *Router {
 	r := &Router{}
-	empty := make(map[string]routeEntry)
-	r.exact.Store(&empty)
-	emptyPrefixes := make([]prefixEntry, 0)
-	r.prefixes.Store(&emptyPrefixes)
+	r.table.Store(&routeTable{exact: make(map[string]routeEntry)})
 	return r
 }
 
 // Reload rebuilds the route table from the provided routes.
 func (r *Router) Reload(routes []config.Route) {
+	r.store(buildRouteTable(routes, nil))
+}
+
+// buildRouteTable indexes routes for matching, normalizing their paths by
+// norm when set.
+func buildRouteTable(routes []config.Route, norm *config.PathNormalization) *routeTable {
+	t := &routeTable{}
+	if norm != nil {
+		t.norm = *norm
+	}
 	exact := make(map[string]routeEntry)
+	var patterns []patternEntry
 	var prefixes []prefixEntry
 
 	for _, route := range routes {
@@ -50,29 +96,102 @@ func (r *Router) Reload(routes []config.Route) {
 			upstream: route.Upstream,
 		}
 		for _, p := range route.Paths {
+			pattern, err := p.Pattern()
+			if err != nil {
+				slog.Warn("skipping invalid path rule",
+					slog.String("route", route.Name),
+					slog.String("path", p.Path),
+					slog.String("error", err.Error()),
+				)
+				continue
+			}
+			if pattern != nil {
+				if t.norm.CaseInsensitive {
+					pattern = pattern.IgnoreCase()
+				}
+				patterns = append(patterns, patternEntry{
+					host:    route.Host,
+					pattern: pattern,
+					entry:   entry,
+				})
+				continue
+			}
 			switch p.Type {
 			case "exact":
-				key := routeKey(route.Host, p.Path)
+				// The first route wins unless a later one has a higher priority
+				key := routeKey(route.Host, t.exactPath(p.Path))
+				if prev, ok := exact[key]; ok && prev.route.Priority >= route.Priority {
+					continue
+				}
 				exact[key] = entry
 			case "prefix":
 				prefixes = append(prefixes, prefixEntry{
 					host:   route.Host,
-					prefix: p.Path,
+					prefix: t.prefixPath(p.Path),
 					entry:  entry,
 				})
 			}
 		}
 	}
 
-	// Sort prefixes by length descending (longest prefix match first)
+	// Higher priorities first, then templates and regexes in configuration
+	// order and prefixes longest first
+	sort.SliceStable(patterns, func(i, j int) bool {
+		return patterns[i].entry.route.Priority > patterns[j].entry.route.Priority
+	})
 	sortPrefixesByLength(prefixes)
+	var levels []prefixLevel
+	for i := range prefixes {
+		pe := &prefixes[i]
+		n := len(levels)
+		if n == 0 || levels[n-1].priority != pe.entry.route.Priority {
+			levels = append(levels, prefixLevel{priority: pe.entry.route.Priority})
+			n++
+		}
+		levels[n-1].tree.Insert(pe.prefix, pe)
+	}
+	t.exact, t.patterns, t.prefixes, t.prefixLevels = exact, patterns, prefixes, levels
+	return t
+}
 
-	r.exact.Store(&exact)
-	r.prefixes.Store(&prefixes)
+// exactPath returns path as compared with exact paths.
+func (t *routeTable) exactPath(path string) string {
+	if t.norm.StripTrailingSlash {
+		path = pathmatch.StripTrailingSlash(path)
+	}
+	if t.norm.CaseInsensitive {
+		path = strings.ToLower(path)
+	}
+	return path
+}
 
+// prefixPath returns path as compared with prefixes.
+func (t *routeTable) prefixPath(path string) string {
+	if t.norm.CaseInsensitive {
+		path = strings.ToLower(path)
+	}
+	return path
+}
+
+// Normalize merges runs of slashes in the path of req in place when the
+// path_normalization applied last sets merge_slashes, so that the merged
+// path is both matched and forwarded.
+func (r *Router) Normalize(req *http.Request) {
+	if !r.table.Load().norm.MergeSlashes {
+		return
+	}
+	if path := pathmatch.MergeSlashes(req.URL.Path); path != req.URL.Path {
+		req.URL.Path = path
+		req.URL.RawPath = ""
+	}
+}
+
+func (r *Router) store(t *routeTable) {
+	r.table.Store(t)
 	slog.Info("route table reloaded",
-		slog.Int("exact_routes", len(exact)),
-		slog.Int("prefix_routes", len(prefixes)),
+		slog.Int("exact_routes", len(t.exact)),
+		slog.Int("pattern_routes", len(t.patterns)),
+		slog.Int("prefix_routes", len(t.prefixes)),
 	)
 }
 
@@ -80,6 +199,12 @@ func (r *Router) Reload(routes []config.Route) {
 type MatchResult struct {
 	Upstream string
 	Route    config.Route
+	// MatchedPath is the part of the path a template or regex rule matched,
+	// or a literal rule matched after path normalization; empty for literal
+	// rules matching the path as written.
+	MatchedPath string
+	// Params are the path parameters a template or regex rule captured.
+	Params map[string]string
 }
 
 // Match finds the best matching route for a request.
@@ -90,30 +215,66 @@ func (r *Router) Match(req *http.Request) (MatchResult, bool) {
 	if idx := strings.LastIndex(host, ":"); idx != -1 {
 		host = host[:idx]
 	}
-	path := req.URL.Path
+	t := r.table.Load()
 
 	// Try exact match first (O(1))
-	exact := *r.exact.Load()
+	exact := t.exact
+	path := t.exactPath(req.URL.Path)
+	var matchedPath string
+	if path != req.URL.Path {
+		matchedPath = req.URL.Path
+	}
 	key := routeKey(host, path)
 	if entry, ok := exact[key]; ok {
-		return MatchResult{Upstream: entry.upstream, Route: entry.route}, true
+		return MatchResult{Upstream: entry.upstream, Route: entry.route, MatchedPath: matchedPath}, true
 	}
 	// Also try without host for wildcard routes
 	if host != "" {
 		key = routeKey("", path)
 		if entry, ok := exact[key]; ok {
-			return MatchResult{Upstream: entry.upstream, Route: entry.route}, true
+			return MatchResult{Upstream: entry.upstream, Route: entry.route, MatchedPath: matchedPath}, true
 		}
 	}
 
-	// Try prefix match (longest match wins)
-	prefixes := *r.prefixes.Load()
-	for _, pe := range prefixes {
+	// Try templates and regexes by priority
+	patternPath := req.URL.Path
+	if t.norm.StripTrailingSlash {
+		patternPath = pathmatch.StripTrailingSlash(patternPath)
+	}
+	for _, pe := range t.patterns {
 		if pe.host != "" && pe.host != host {
 			continue
 		}
-		if strings.HasPrefix(path, pe.prefix) {
-			return MatchResult{Upstream: pe.entry.upstream, Route: pe.entry.route}, true
+		if matched, params, ok := pe.pattern.Match(patternPath); ok {
+			return MatchResult{
+				Upstream:    pe.entry.upstream,
+				Route:       pe.entry.route,
+				MatchedPath: matched,
+				Params:      params,
+			}, true
+		}
+	}
+
+	// Try prefix match (highest priority, then longest match wins)
+	var matched *prefixEntry
+	path = t.prefixPath(req.URL.Path)
+	for i := range t.prefixLevels {
+		found := t.prefixLevels[i].tree.Match(path, func(_ string, entries []*prefixEntry) bool {
+			for _, pe := range entries {
+				if pe.host == "" || pe.host == host {
+					matched = pe
+					return true
+				}
+			}
+			return false
+		})
+		if found {
+			if path != req.URL.Path {
+				matchedPath = req.URL.Path[:len(matched.prefix)]
+			} else {
+				matchedPath = ""
+			}
+			return MatchResult{Upstream: matched.entry.upstream, Route: matched.entry.route, MatchedPath: matchedPath}, true
 		}
 	}
 
@@ -128,9 +289,18 @@ func sortPrefixesByLength(entries []prefixEntry) {
 	// Simple insertion sort (route tables are typically small)
 	for i := 1; i < len(entries); i++ {
 		j := i
-		for j > 0 && len(entries[j].prefix) > len(entries[j-1].prefix) {
+		for j > 0 && prefixBefore(&entries[j], &entries[j-1]) {
 			entries[j], entries[j-1] = entries[j-1], entries[j]
 			j--
 		}
 	}
 }
+
+// prefixBefore reports whether a is tried before b: higher priority first,
+// then longer prefix first.
+func prefixBefore(a, b *prefixEntry) bool {
+	if pa, pb := a.entry.route.Priority, b.entry.route.Priority; pa != pb {
+		return pa > pb
+	}
+	return len(a.prefix) > len(b.prefix)
+}
@@ -1,8 +1,10 @@
 package proxy
 
 import (
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"testing"
 
 	"github.com/oriys/nexus/internal/config"
@@ -198,3 +200,131 @@ func TestRouterHostWithPort(t *testing.T) {
 		t.Errorf("expected backend, got %s", result.Upstream)
 	}
 }
+
+func TestRouterPathPatterns(t *testing.T) {
+	router := NewRouter()
+	router.Reload([]config.Route{
+		{
+			Name:     "users",
+			Upstream: "users",
+			Paths:    []config.PathRule{{Path: "/users", Type: "prefix"}},
+		},
+		{
+			Name:     "order",
+			Upstream: "orders",
+			Paths:    []config.PathRule{{Path: "/users/{id}/orders/{oid}", Type: "exact"}},
+		},
+		{
+			Name:     "tenant",
+			Upstream: "tenants",
+			Paths:    []config.PathRule{{Path: "/t/{tenant}", Type: "prefix"}},
+		},
+		{
+			Name:     "items",
+			Upstream: "items",
+			Paths:    []config.PathRule{{Path: `/items/(?P<id>[0-9]+)`, Type: "regex"}},
+		},
+	})
+
+	tests := []struct {
+		path     string
+		upstream string
+		matched  string
+		params   map[string]string
+	}{
+		{"/users/42/orders/7", "orders", "/users/42/orders/7", map[string]string{"id": "42", "oid": "7"}},
+		{"/users/42/orders", "users", "", nil},
+		{"/t/acme/api/v1", "tenants", "/t/acme", map[string]string{"tenant": "acme"}},
+		{"/items/12", "items", "/items/12", map[string]string{"id": "12"}},
+	}
+	for _, tt := range tests {
+		result, ok := router.Match(httptest.NewRequest("GET", "http://example.com"+tt.path, nil))
+		if !ok {
+			t.Errorf("%s: expected match", tt.path)
+			continue
+		}
+		if result.Upstream != tt.upstream || result.MatchedPath != tt.matched || !reflect.DeepEqual(result.Params, tt.params) {
+			t.Errorf("%s: got %s %q %v, want %s %q %v", tt.path,
+				result.Upstream, result.MatchedPath, result.Params, tt.upstream, tt.matched, tt.params)
+		}
+	}
+	if _, ok := router.Match(httptest.NewRequest("GET", "/items/abc", nil)); ok {
+		t.Error("expected /items/abc not to match")
+	}
+}
+
+func TestRouterPriority(t *testing.T) {
+	router := NewRouter()
+	router.Reload([]config.Route{
+		{Name: "api", Upstream: "api", Paths: []config.PathRule{{Path: "/api", Type: "prefix"}}, Priority: 1},
+		{Name: "api-v1", Upstream: "api-v1", Paths: []config.PathRule{{Path: "/api/v1", Type: "prefix"}}},
+		{Name: "first", Upstream: "first", Paths: []config.PathRule{{Path: "/status", Type: "exact"}}},
+		{Name: "second", Upstream: "second", Paths: []config.PathRule{{Path: "/status", Type: "exact"}}},
+		{Name: "low", Upstream: "low", Paths: []config.PathRule{{Path: "/ping", Type: "exact"}}},
+		{Name: "high", Upstream: "high", Paths: []config.PathRule{{Path: "/ping", Type: "exact"}}, Priority: 2},
+	})
+
+	tests := map[string]string{
+		"/api/v1/users": "api",
+		"/status":       "first",
+		"/ping":         "high",
+	}
+	for path, want := range tests {
+		result, ok := router.Match(httptest.NewRequest("GET", path, nil))
+		if !ok || result.Upstream != want {
+			t.Errorf("%s: got %q, want %q", path, result.Upstream, want)
+		}
+	}
+}
+
+func TestRouterPathNormalization(t *testing.T) {
+	router := NewRouter()
+	router.store(buildRouteTable([]config.Route{
+		{Name: "users", Upstream: "users", Paths: []config.PathRule{{Path: "/API/v1/users/", Type: "exact"}}},
+		{Name: "user", Upstream: "user", Paths: []config.PathRule{{Path: "/api/v1/users/{id}", Type: "exact"}}},
+		{Name: "docs", Upstream: "docs", Paths: []config.PathRule{{Path: "/docs", Type: "prefix"}}},
+	}, &config.PathNormalization{MergeSlashes: true, StripTrailingSlash: true, CaseInsensitive: true}))
+
+	tests := []struct {
+		path, upstream, forwarded, matched string
+	}{
+		{"//api//v1/users", "users", "/api/v1/users", ""},
+		{"/Api/V1/Users/", "users", "/Api/V1/Users/", "/Api/V1/Users/"},
+		{"/api/v1/USERS/42/", "user", "/api/v1/USERS/42/", "/api/v1/USERS/42"},
+		{"/DOCS/intro", "docs", "/DOCS/intro", "/DOCS"},
+		{"/docs/intro", "docs", "/docs/intro", ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "http://example.com"+tt.path, nil)
+		router.Normalize(req)
+		result, ok := router.Match(req)
+		if !ok || result.Upstream != tt.upstream || req.URL.Path != tt.forwarded || result.MatchedPath != tt.matched {
+			t.Errorf("%s: got %v %s %s %q, want %s %s %q", tt.path, ok, result.Upstream, req.URL.Path, result.MatchedPath,
+				tt.upstream, tt.forwarded, tt.matched)
+		}
+	}
+}
+
+// BenchmarkRouterPrefix matches against a table of 5000 prefix routes, the
+// worst case for a linear scan.
+func BenchmarkRouterPrefix(b *testing.B) {
+	var routes []config.Route
+	for i := range 5000 {
+		routes = append(routes, config.Route{
+			Name:     fmt.Sprintf("svc-%d", i),
+			Upstream: fmt.Sprintf("svc-%d", i),
+			Paths:    []config.PathRule{{Path: fmt.Sprintf("/api/v1/svc-%d/", i), Type: "prefix"}},
+		})
+	}
+	router := NewRouter()
+	router.Reload(routes)
+	req := httptest.NewRequest("GET", "http://example.com/api/v1/svc-42/users/7", nil)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		if result, ok := router.Match(req); !ok || result.Upstream != "svc-42" {
+			b.Fatalf("got %v", result.Upstream)
+		}
+	}
+}
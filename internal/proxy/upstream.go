@@ -29,19 +29,27 @@ func NewUpstreamManager() *UpstreamManager {
 
 // Reload rebuilds all upstream groups from the configuration.
 func (m *UpstreamManager) Reload(upstreams []config.Upstream) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+	m.store(buildUpstreamGroups(upstreams))
+}
 
-	newUpstreams := make(map[string]*upstreamGroup, len(upstreams))
+// buildUpstreamGroups creates the upstream groups of a configuration.
+func buildUpstreamGroups(upstreams []config.Upstream) map[string]*upstreamGroup {
+	groups := make(map[string]*upstreamGroup, len(upstreams))
 	for _, u := range upstreams {
-		newUpstreams[u.Name] = &upstreamGroup{
+		groups[u.Name] = &upstreamGroup{
 			name:    u.Name,
 			targets: u.Targets,
 		}
 	}
-	m.upstreams = newUpstreams
+	return groups
+}
 
-	slog.Info("upstream groups reloaded", slog.Int("count", len(newUpstreams)))
+func (m *UpstreamManager) store(groups map[string]*upstreamGroup) {
+	m.mu.Lock()
+	m.upstreams = groups
+	m.mu.Unlock()
+
+	slog.Info("upstream groups reloaded", slog.Int("count", len(groups)))
 }
 
 // GetTarget returns the next target address for the given upstream using round-robin.
@@ -60,3 +68,16 @@ func (m *UpstreamManager) GetTarget(upstreamName string) (string, bool) {
 	target := group.targets[idx%uint64(len(group.targets))]
 	return target.Address, true
 }
+
+// PeekTarget returns the target address GetTarget returns next for the given
+// upstream, without advancing the round-robin position.
+func (m *UpstreamManager) PeekTarget(upstreamName string) (string, bool) {
+	m.mu.RLock()
+	group, ok := m.upstreams[upstreamName]
+	m.mu.RUnlock()
+
+	if !ok || len(group.targets) == 0 {
+		return "", false
+	}
+	return group.targets[group.counter.Load()%uint64(len(group.targets))].Address, true
+}
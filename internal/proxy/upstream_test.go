@@ -72,3 +72,23 @@ func TestUpstreamManagerReload(t *testing.T) {
 		t.Errorf("expected 5.6.7.8:80, got %s", addr)
 	}
 }
+
+func TestUpstreamManagerPeekTarget(t *testing.T) {
+	mgr := NewUpstreamManager()
+	mgr.Reload([]config.Upstream{
+		{Name: "a", Targets: []config.Target{{Address: "1.2.3.4:80"}, {Address: "1.2.3.5:80"}}},
+	})
+
+	for i := 0; i < 2; i++ {
+		if addr, ok := mgr.PeekTarget("a"); !ok || addr != "1.2.3.4:80" {
+			t.Fatalf("peek %d: expected 1.2.3.4:80, got %s", i, addr)
+		}
+	}
+	mgr.GetTarget("a")
+	if addr, _ := mgr.PeekTarget("a"); addr != "1.2.3.5:80" {
+		t.Errorf("expected 1.2.3.5:80 after a pick, got %s", addr)
+	}
+	if _, ok := mgr.PeekTarget("missing"); ok {
+		t.Error("expected no target for unknown upstream")
+	}
+}
@@ -0,0 +1,180 @@
+// Package redact masks secrets before they reach logs or the configuration
+// the admin API serves: the values of sensitive headers, wherever they are
+// logged, and sensitive fields of logged JSON bodies.
+package redact
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// Mask replaces redacted values.
+const Mask = "[REDACTED]"
+
+// DefaultHeaders are the headers every Redactor treats as sensitive.
+var DefaultHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-API-Key"}
+
+// Redactor decides what is sensitive. A nil Redactor masks nothing.
+type Redactor struct {
+	headers map[string]bool // normalized names
+	fields  map[string]bool // lower-case names
+}
+
+// New returns a Redactor masking DefaultHeaders and headers, and the JSON
+// fields named fields at any depth. Names are case-insensitive.
+func New(headers, fields []string) *Redactor {
+	r := &Redactor{headers: make(map[string]bool), fields: make(map[string]bool)}
+	for _, h := range append(append([]string(nil), DefaultHeaders...), headers...) {
+		r.headers[normalize(h)] = true
+	}
+	for _, f := range fields {
+		r.fields[strings.ToLower(f)] = true
+	}
+	return r
+}
+
+// normalize folds a header name or log attribute key, so that
+// "X-API-Key", "x-api-key" and "x_api_key" compare equal.
+func normalize(name string) string {
+	return strings.ReplaceAll(strings.ToLower(name), "_", "-")
+}
+
+// SensitiveHeader reports whether the header name is masked.
+func (r *Redactor) SensitiveHeader(name string) bool {
+	return r != nil && r.headers[normalize(name)]
+}
+
+// Header returns a copy of h with the values of sensitive headers masked.
+func (r *Redactor) Header(h http.Header) http.Header {
+	out := make(http.Header, len(h))
+	for name, values := range h {
+		if r.SensitiveHeader(name) {
+			values = []string{Mask}
+		}
+		out[name] = values
+	}
+	return out
+}
+
+// JSON returns data with the values of sensitive fields masked, and
+// whether it changed anything. Data that is not JSON is returned as is.
+func (r *Redactor) JSON(data []byte) ([]byte, bool) {
+	if r == nil || len(r.fields) == 0 {
+		return data, false
+	}
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 || trimmed[0] != '{' && trimmed[0] != '[' {
+		return data, false
+	}
+	dec := json.NewDecoder(bytes.NewReader(trimmed))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil || dec.More() {
+		return data, false
+	}
+	if !r.maskFields(v) {
+		return data, false
+	}
+	out, err := json.Marshal(v)
+	if err != nil {
+		return data, false
+	}
+	return out, true
+}
+
+// maskFields masks the sensitive fields of a decoded JSON value in place.
+func (r *Redactor) maskFields(v any) bool {
+	changed := false
+	switch v := v.(type) {
+	case map[string]any:
+		for k, item := range v {
+			if r.fields[strings.ToLower(k)] {
+				v[k] = Mask
+				changed = true
+			} else if r.maskFields(item) {
+				changed = true
+			}
+		}
+	case []any:
+		for _, item := range v {
+			if r.maskFields(item) {
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+// Attr returns a with its sensitive parts masked: the value of an attribute
+// named after a sensitive header, sensitive headers of an http.Header
+// value, and sensitive fields of a string holding JSON. Groups are
+// redacted recursively.
+func (r *Redactor) Attr(a slog.Attr) slog.Attr {
+	a.Value = a.Value.Resolve()
+	switch a.Value.Kind() {
+	case slog.KindGroup:
+		attrs := a.Value.Group()
+		out := make([]slog.Attr, len(attrs))
+		for i, attr := range attrs {
+			out[i] = r.Attr(attr)
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(out...)}
+	case slog.KindString:
+		if r.SensitiveHeader(a.Key) {
+			if a.Value.String() != "" {
+				a.Value = slog.StringValue(Mask)
+			}
+			return a
+		}
+		if masked, ok := r.JSON([]byte(a.Value.String())); ok {
+			a.Value = slog.StringValue(string(masked))
+		}
+	case slog.KindAny:
+		if r.SensitiveHeader(a.Key) {
+			a.Value = slog.StringValue(Mask)
+		} else if h, ok := a.Value.Any().(http.Header); ok {
+			a.Value = slog.AnyValue(r.Header(h))
+		}
+	}
+	return a
+}
+
+// Handler returns a slog.Handler redacting the attributes of records
+// before passing them to next.
+func (r *Redactor) Handler(next slog.Handler) slog.Handler {
+	return &handler{next: next, r: r}
+}
+
+type handler struct {
+	next slog.Handler
+	r    *Redactor
+}
+
+func (h *handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *handler) Handle(ctx context.Context, rec slog.Record) error {
+	out := slog.NewRecord(rec.Time, rec.Level, rec.Message, rec.PC)
+	rec.Attrs(func(a slog.Attr) bool {
+		out.AddAttrs(h.r.Attr(a))
+		return true
+	})
+	return h.next.Handle(ctx, out)
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	out := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		out[i] = h.r.Attr(a)
+	}
+	return &handler{next: h.next.WithAttrs(out), r: h.r}
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	return &handler{next: h.next.WithGroup(name), r: h.r}
+}
@@ -0,0 +1,52 @@
+package redact
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRedactor_JSON(t *testing.T) {
+	r := New(nil, []string{"password", "Token"})
+	out, ok := r.JSON([]byte(`{"user":"ann","password":"s3cret","items":[{"token":"abc","id":7}]}`))
+	if !ok {
+		t.Fatal("expected the body to be redacted")
+	}
+	want := `{"items":[{"id":7,"token":"[REDACTED]"}],"password":"[REDACTED]","user":"ann"}`
+	if string(out) != want {
+		t.Errorf("got %s, want %s", out, want)
+	}
+	for _, body := range []string{`{"user":"ann"}`, `not json`, `{"password":`, `{} {}`} {
+		if out, ok := r.JSON([]byte(body)); ok || string(out) != body {
+			t.Errorf("%q changed to %q", body, out)
+		}
+	}
+}
+
+func TestRedactor_Handler(t *testing.T) {
+	var buf bytes.Buffer
+	r := New([]string{"X-Session"}, []string{"password"})
+	logger := slog.New(r.Handler(slog.NewJSONHandler(&buf, nil)))
+
+	h := http.Header{}
+	h.Set("Authorization", "Bearer abc")
+	h.Set("Accept", "text/html")
+	logger.With(slog.String("x_api_key", "k1")).Info("request",
+		slog.Group("headers", slog.String("Authorization", "Bearer abc"), slog.String("X-Session", "s1"), slog.String("User-Agent", "curl")),
+		slog.Any("header", h),
+		slog.String("body", `{"password":"hunter2"}`),
+	)
+	out := buf.String()
+	for _, secret := range []string{"Bearer abc", "k1", "s1", "hunter2"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("%q leaked: %s", secret, out)
+		}
+	}
+	for _, kept := range []string{`"User-Agent":"curl"`, `"Accept":["text/html"]`, `{\"password\":\"[REDACTED]\"}`} {
+		if !strings.Contains(out, kept) {
+			t.Errorf("%s missing: %s", kept, out)
+		}
+	}
+}
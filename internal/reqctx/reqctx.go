@@ -0,0 +1,185 @@
+// Package reqctx is a request-scoped key/value store shared by the
+// middleware, the plugin chain and the route filters of a request. The
+// store travels in the request context, so anything holding the request can
+// reach it. Values are read and written through typed keys: a key is
+// compared by identity, not by name, so packages never overwrite each
+// other's values, and a value always has the type of its key.
+package reqctx
+
+import (
+	"context"
+	"net/http"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/oriys/nexus/internal/auth"
+)
+
+// Well-known keys, set by the gateway as a request progresses.
+var (
+	// RequestID is the request ID, set by the RequestID middleware.
+	RequestID = NewKey[string]("request_id")
+	// TraceID is the W3C trace ID, set by the TraceContext middleware.
+	TraceID = NewKey[string]("trace_id")
+	// ClientIP is the client address resolved from trusted forwarding
+	// headers, set by the ClientIP middleware.
+	ClientIP = NewKey[netip.Addr]("client_ip")
+	// Identity is the authenticated caller, set by the Auth middleware.
+	Identity = NewKey[*auth.Identity]("identity")
+	// Route is the name of the matched route, set once the request is
+	// routed.
+	Route = NewKey[string]("route")
+	// Upstream is the address of the upstream endpoint the request is sent
+	// to, set once the load balancer picks it.
+	Upstream = NewKey[string]("upstream")
+	// Tenant is the tenant of the request, set by the Tenant middleware.
+	Tenant = NewKey[string]("tenant")
+	// OriginalPath is the path the client sent, kept as normalization,
+	// strip_prefix and rewrites change the request path; see
+	// SetOriginalPath.
+	OriginalPath = NewKey[string]("original_path")
+)
+
+// Key identifies a value of type T. Create keys with NewKey, once, in a
+// package-level variable.
+type Key[T any] struct {
+	name string
+}
+
+// NewKey returns a new key. The name only describes the key; two keys with
+// the same name are still distinct.
+func NewKey[T any](name string) *Key[T] {
+	return &Key[T]{name: name}
+}
+
+// Name returns the name the key was created with.
+func (k *Key[T]) Name() string { return k.name }
+
+// Get returns the value of k in the store of ctx, if it is set and has not
+// expired.
+func (k *Key[T]) Get(ctx context.Context) (T, bool) {
+	return Get(FromContext(ctx), k)
+}
+
+// Set sets the value of k in the store of ctx, for the rest of the request.
+// Without a store it does nothing.
+func (k *Key[T]) Set(ctx context.Context, v T) {
+	Set(FromContext(ctx), k, v)
+}
+
+// SetTTL sets the value of k in the store of ctx until ttl elapses, for
+// values that must not outlive their validity on long requests such as
+// streams.
+func (k *Key[T]) SetTTL(ctx context.Context, v T, ttl time.Duration) {
+	SetTTL(FromContext(ctx), k, v, ttl)
+}
+
+// Delete removes the value of k from the store of ctx.
+func (k *Key[T]) Delete(ctx context.Context) {
+	FromContext(ctx).delete(k)
+}
+
+// Store holds the values of a request. It is safe for concurrent use; the
+// methods of a nil Store do nothing and find nothing.
+type Store struct {
+	mu     sync.RWMutex
+	values map[any]entry
+}
+
+type entry struct {
+	value   any
+	expires time.Time // zero for no expiry
+}
+
+type storeKey struct{}
+
+// FromContext returns the store of ctx, nil if it has none.
+func FromContext(ctx context.Context) *Store {
+	s, _ := ctx.Value(storeKey{}).(*Store)
+	return s
+}
+
+// Attach returns a context holding a store, and the store: the store ctx
+// already holds, or a new one.
+func Attach(ctx context.Context) (context.Context, *Store) {
+	if s := FromContext(ctx); s != nil {
+		return ctx, s
+	}
+	s := &Store{}
+	return context.WithValue(ctx, storeKey{}, s), s
+}
+
+// AttachRequest is Attach for the context of r, returning r with the store.
+func AttachRequest(r *http.Request) (*http.Request, *Store) {
+	ctx, s := Attach(r.Context())
+	if ctx != r.Context() {
+		r = r.WithContext(ctx)
+	}
+	return r, s
+}
+
+// SetOriginalPath records the path of r as OriginalPath, unless a handler
+// that saw r before did. The first handler of the gateway calls it before
+// anything changes the path.
+func SetOriginalPath(r *http.Request) {
+	if _, ok := OriginalPath.Get(r.Context()); !ok {
+		OriginalPath.Set(r.Context(), r.URL.Path)
+	}
+}
+
+// OriginalPathOf returns the path the client sent r with: OriginalPath, or
+// the path of r when it is not recorded. Logs report this path, so that
+// the entries of a request agree however it was rewritten.
+func OriginalPathOf(r *http.Request) string {
+	if path, ok := OriginalPath.Get(r.Context()); ok {
+		return path
+	}
+	return r.URL.Path
+}
+
+// Get returns the value of k in s, if it is set and has not expired.
+func Get[T any](s *Store, k *Key[T]) (T, bool) {
+	var zero T
+	if s == nil {
+		return zero, false
+	}
+	s.mu.RLock()
+	e, ok := s.values[k]
+	s.mu.RUnlock()
+	if !ok || !e.expires.IsZero() && !time.Now().Before(e.expires) {
+		return zero, false
+	}
+	return e.value.(T), true
+}
+
+// Set sets the value of k in s.
+func Set[T any](s *Store, k *Key[T], v T) {
+	s.set(k, entry{value: v})
+}
+
+// SetTTL sets the value of k in s until ttl elapses.
+func SetTTL[T any](s *Store, k *Key[T], v T, ttl time.Duration) {
+	s.set(k, entry{value: v, expires: time.Now().Add(ttl)})
+}
+
+func (s *Store) set(k any, e entry) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.values == nil {
+		s.values = make(map[any]entry)
+	}
+	s.values[k] = e
+}
+
+func (s *Store) delete(k any) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.values, k)
+}
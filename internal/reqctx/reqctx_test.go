@@ -0,0 +1,80 @@
+package reqctx
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestStore(t *testing.T) {
+	ctx, s := Attach(context.Background())
+	if again, same := Attach(ctx); again != ctx || same != s {
+		t.Fatal("Attach replaced the store of the context")
+	}
+
+	RequestID.Set(ctx, "req-1")
+	if id, ok := RequestID.Get(ctx); !ok || id != "req-1" {
+		t.Errorf("RequestID = %q, %v", id, ok)
+	}
+
+	// Keys with the same name do not collide.
+	other := NewKey[int]("request_id")
+	Set(s, other, 42)
+	if id, _ := RequestID.Get(ctx); id != "req-1" {
+		t.Errorf("RequestID overwritten: %q", id)
+	}
+	if n, ok := Get(s, other); !ok || n != 42 {
+		t.Errorf("other = %d, %v", n, ok)
+	}
+
+	RequestID.Delete(ctx)
+	if _, ok := RequestID.Get(ctx); ok {
+		t.Error("deleted value found")
+	}
+}
+
+func TestStore_TTL(t *testing.T) {
+	ctx, _ := Attach(context.Background())
+	token := NewKey[string]("token")
+	token.SetTTL(ctx, "short", 10*time.Millisecond)
+	if v, ok := token.Get(ctx); !ok || v != "short" {
+		t.Fatalf("token = %q, %v", v, ok)
+	}
+	time.Sleep(15 * time.Millisecond)
+	if _, ok := token.Get(ctx); ok {
+		t.Error("expired value found")
+	}
+}
+
+func TestStore_NoStore(t *testing.T) {
+	ctx := context.Background()
+	Route.Set(ctx, "orders")
+	if _, ok := Route.Get(ctx); ok {
+		t.Error("value found without a store")
+	}
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r2, s := AttachRequest(r)
+	if r2 == r || FromContext(r2.Context()) != s {
+		t.Fatal("store not attached to the request")
+	}
+	if r3, _ := AttachRequest(r2); r3 != r2 {
+		t.Error("request copied although it holds a store")
+	}
+}
+
+func TestOriginalPath(t *testing.T) {
+	r := httptest.NewRequest("GET", "/api/orders/7", nil)
+	if got := OriginalPathOf(r); got != "/api/orders/7" {
+		t.Errorf("without a store: %q", got)
+	}
+	r, _ = AttachRequest(r)
+	SetOriginalPath(r)
+	r.URL.Path = "/orders/7"
+	// Later handlers keep the path the first one recorded.
+	SetOriginalPath(r)
+	if got := OriginalPathOf(r); got != "/api/orders/7" {
+		t.Errorf("original path %q", got)
+	}
+}
@@ -0,0 +1,194 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/oriys/nexus/internal/clientip"
+	"github.com/oriys/nexus/internal/config"
+	"github.com/oriys/nexus/internal/reqctx"
+)
+
+// bandwidthChunk bounds the bytes a throttled body reads at once, so that
+// its rate stays smooth below the burst.
+const bandwidthChunk = 32 << 10
+
+// maxBandwidthConsumers bounds the per-consumer buckets of a filter; idle
+// buckets are dropped once it is reached.
+const maxBandwidthConsumers = 10000
+
+// bandwidthLimitFilter limits the rate at which response bodies are sent,
+// in bytes per second. The limit is shared by all responses of the route,
+// by the responses of each consumer, or applies to each response alone.
+type bandwidthLimitFilter struct {
+	rate, burst float64
+	per         string // "route", "consumer" or "request"
+
+	mu      sync.Mutex
+	route   *tokenBucket
+	buckets map[string]*tokenBucket
+}
+
+func newBandwidthLimitFilter(args config.FilterArgs) (Filter, error) {
+	if err := args.Check("rate", "burst", "per"); err != nil {
+		return nil, fmt.Errorf("bandwidth_limit filter: %w", err)
+	}
+	rate, err := args.Size("rate")
+	if err == nil && rate == 0 {
+		err = fmt.Errorf("'rate' argument is required")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("bandwidth_limit filter: %w", err)
+	}
+	burst, err := args.Size("burst")
+	if err != nil {
+		return nil, fmt.Errorf("bandwidth_limit filter: %w", err)
+	}
+	if burst == 0 {
+		burst = rate
+	}
+	per, err := args.String("per")
+	if err != nil {
+		return nil, fmt.Errorf("bandwidth_limit filter: %w", err)
+	}
+	switch per {
+	case "":
+		per = "route"
+	case "route", "consumer", "request":
+	default:
+		return nil, fmt.Errorf("bandwidth_limit filter: 'per' must be route, consumer or request")
+	}
+	f := &bandwidthLimitFilter{rate: float64(rate), burst: float64(burst), per: per}
+	if per == "route" {
+		f.route = f.newBucket()
+	} else {
+		f.buckets = make(map[string]*tokenBucket)
+	}
+	return f, nil
+}
+
+func (f *bandwidthLimitFilter) Apply(r *http.Request) error { return nil }
+
+func (f *bandwidthLimitFilter) ApplyResponse(resp *http.Response) error {
+	if resp.Body == nil || resp.Body == http.NoBody || resp.StatusCode == http.StatusSwitchingProtocols || resp.Request == nil {
+		return nil
+	}
+	resp.Body = &throttledBody{
+		ReadCloser: resp.Body,
+		ctx:        resp.Request.Context(),
+		bucket:     f.bucket(resp.Request),
+		chunk:      int(min(f.burst, bandwidthChunk)),
+	}
+	return nil
+}
+
+func (f *bandwidthLimitFilter) newBucket() *tokenBucket {
+	return &tokenBucket{rate: f.rate, burst: f.burst, tokens: f.burst, last: time.Now()}
+}
+
+// bucket returns the bucket limiting the response to r.
+func (f *bandwidthLimitFilter) bucket(r *http.Request) *tokenBucket {
+	switch f.per {
+	case "route":
+		return f.route
+	case "request":
+		return f.newBucket()
+	}
+	key := consumerKey(r)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	b, ok := f.buckets[key]
+	if !ok {
+		if len(f.buckets) >= maxBandwidthConsumers {
+			f.dropIdle()
+		}
+		b = f.newBucket()
+		f.buckets[key] = b
+	}
+	return b
+}
+
+// dropIdle removes the buckets that have refilled, whose consumers sent
+// nothing lately. Callers hold f.mu.
+func (f *bandwidthLimitFilter) dropIdle() {
+	now := time.Now()
+	for key, b := range f.buckets {
+		if b.idle(now) {
+			delete(f.buckets, key)
+		}
+	}
+}
+
+// consumerKey identifies the consumer of r: its authenticated subject, then
+// its tenant, then its client address.
+func consumerKey(r *http.Request) string {
+	if id, ok := reqctx.Identity.Get(r.Context()); ok && id != nil && id.Subject != "" {
+		return "subject:" + id.Source + ":" + id.Subject
+	}
+	if t, ok := reqctx.Tenant.Get(r.Context()); ok {
+		return "tenant:" + t
+	}
+	return "ip:" + clientip.FromRequest(r).String()
+}
+
+// tokenBucket holds the bytes a limit lets through, refilled at rate up to
+// burst.
+type tokenBucket struct {
+	mu          sync.Mutex
+	rate, burst float64
+	tokens      float64
+	last        time.Time
+}
+
+// take takes n bytes from the bucket, returning how long to wait before
+// sending them.
+func (b *tokenBucket) take(n int) time.Duration {
+	now := time.Now()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	b.tokens -= float64(n)
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+func (b *tokenBucket) idle(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.tokens+now.Sub(b.last).Seconds()*b.rate >= b.burst
+}
+
+// throttledBody delays the reads of a response body to the rate of its
+// bucket.
+type throttledBody struct {
+	io.ReadCloser
+	ctx    context.Context
+	bucket *tokenBucket
+	chunk  int
+}
+
+func (t *throttledBody) Read(p []byte) (int, error) {
+	if len(p) > t.chunk {
+		p = p[:t.chunk]
+	}
+	n, err := t.ReadCloser.Read(p)
+	if n > 0 {
+		if d := t.bucket.take(n); d > 0 {
+			timer := time.NewTimer(d)
+			defer timer.Stop()
+			select {
+			case <-timer.C:
+			case <-t.ctx.Done():
+				return 0, t.ctx.Err()
+			}
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,78 @@
+package runtime
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oriys/nexus/internal/auth"
+	"github.com/oriys/nexus/internal/config"
+	"github.com/oriys/nexus/internal/reqctx"
+)
+
+func TestBandwidthLimitFilter(t *testing.T) {
+	f, err := newBandwidthLimitFilter(config.FilterArgs{"rate": "100KiB", "burst": "10KiB"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp := newTestResponse(200, "application/octet-stream", strings.Repeat("x", 30<<10))
+	resp.Request = httptest.NewRequest("GET", "/", nil)
+	if err := f.(ResponseFilter).ApplyResponse(resp); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	n, err := io.Copy(io.Discard, resp.Body)
+	// The burst goes out at once, the other 20KiB at 100KiB/s.
+	if elapsed := time.Since(start); err != nil || n != 30<<10 || elapsed < 150*time.Millisecond || elapsed > 2*time.Second {
+		t.Errorf("read %d bytes in %s: %v", n, elapsed, err)
+	}
+
+	// A cancelled request stops waiting.
+	ctx, cancel := context.WithCancel(context.Background())
+	resp = newTestResponse(200, "", strings.Repeat("x", 30<<10))
+	resp.Request = httptest.NewRequest("GET", "/", nil).WithContext(ctx)
+	f.(ResponseFilter).ApplyResponse(resp)
+	cancel()
+	if _, err := io.Copy(io.Discard, resp.Body); err != context.Canceled {
+		t.Errorf("expected the request's cancellation, got %v", err)
+	}
+}
+
+func TestBandwidthLimitFilter_Per(t *testing.T) {
+	request := func(subject string) *http.Request {
+		r, _ := reqctx.AttachRequest(httptest.NewRequest("GET", "/", nil))
+		reqctx.Identity.Set(r.Context(), &auth.Identity{Subject: subject, Source: "apikey"})
+		return r
+	}
+	for _, tt := range []struct {
+		per                string
+		sameSubject, other bool // whether the buckets are shared
+	}{
+		{"route", true, true},
+		{"consumer", true, false},
+		{"request", false, false},
+	} {
+		f, err := newBandwidthLimitFilter(config.FilterArgs{"rate": "1MiB", "per": tt.per})
+		if err != nil {
+			t.Fatal(err)
+		}
+		bf := f.(*bandwidthLimitFilter)
+		a := bf.bucket(request("alice"))
+		if got := bf.bucket(request("alice")) == a; got != tt.sameSubject {
+			t.Errorf("%s: same consumer shares the bucket: %v", tt.per, got)
+		}
+		if got := bf.bucket(request("bob")) == a; got != tt.other {
+			t.Errorf("%s: other consumer shares the bucket: %v", tt.per, got)
+		}
+	}
+
+	for _, args := range []config.FilterArgs{{}, {"rate": "0"}, {"rate": "1MiB", "per": "client"}, {"rate": "1MiB", "size": "1"}} {
+		if _, err := newBandwidthLimitFilter(args); err == nil {
+			t.Errorf("args %v: expected an error", args)
+		}
+	}
+}
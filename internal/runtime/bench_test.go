@@ -0,0 +1,181 @@
+package runtime
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/oriys/nexus/internal/config"
+)
+
+// The request path benchmarks run against benchConfig: a table of exact,
+// parameterized and prefix routes in front of one cluster whose transport
+// answers in memory, so that they measure the gateway alone.
+
+// fakeUpstream answers every request with a fixed JSON body without a
+// network round trip.
+type fakeUpstream struct{ body []byte }
+
+func (f fakeUpstream) RoundTrip(r *http.Request) (*http.Response, error) {
+	if r.Body != nil {
+		io.Copy(io.Discard, r.Body)
+		r.Body.Close()
+	}
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"application/json"}},
+		Body:          io.NopCloser(bytes.NewReader(f.body)),
+		ContentLength: int64(len(f.body)),
+		Request:       r,
+	}, nil
+}
+
+// benchConfig compiles n routes of each kind. The cluster gets a transport
+// of its own, answering in memory, rather than the pooled one.
+func benchConfig(tb testing.TB, n int) *CompiledConfig {
+	tb.Helper()
+	const cluster = "backend"
+	cfg := &config.Config{
+		Clusters: []config.Cluster{
+			{Name: cluster, Type: "http", Endpoints: []config.ClusterEndpoint{{URL: "http://backend:8080"}}},
+		},
+	}
+	backend := config.RouteUpstream{Cluster: cluster}
+	for i := range n {
+		cfg.RoutesV2 = append(cfg.RoutesV2,
+			config.RouteV2{
+				Name:     fmt.Sprintf("exact-%d", i),
+				Match:    config.RouteMatch{Methods: []string{"GET"}, Path: fmt.Sprintf("/api/v1/svc-%d/status", i)},
+				Upstream: backend,
+			},
+			config.RouteV2{
+				Name:     fmt.Sprintf("param-%d", i),
+				Match:    config.RouteMatch{Path: fmt.Sprintf("/api/v1/svc-%d/users/{id}", i)},
+				Upstream: backend,
+			},
+			config.RouteV2{
+				Name:     fmt.Sprintf("prefix-%d", i),
+				Match:    config.RouteMatch{PathPrefix: fmt.Sprintf("/api/v1/svc-%d/", i)},
+				Upstream: backend,
+				Filters: []config.RouteFilter{
+					{Type: "strip_prefix", Args: config.FilterArgs{"prefix": "/api/v1"}},
+					{Type: "header_set", Args: config.FilterArgs{"key": "X-Gateway", "value": "nexus"}},
+					{Type: "regex_rewrite", Args: config.FilterArgs{"pattern": "^/svc-([0-9]+)/(.*)", "substitution": "/services/$1/$2"}},
+				},
+			},
+		)
+	}
+	compiled, err := Compile(cfg, 1)
+	if err != nil {
+		tb.Fatalf("compile error: %v", err)
+	}
+	cc := compiled.Clusters[cluster]
+	cc.proxyTransport = newHTTPTransport(httpSettingsOf(cc))
+	cc.proxyTransport.base.RegisterProtocol("http", fakeUpstream{body: []byte(`{"ok":true}`)})
+	return compiled
+}
+
+func BenchmarkRouterIndex_Match(b *testing.B) {
+	compiled := benchConfig(b, 1000)
+	for _, tt := range []struct{ name, path, route string }{
+		{"exact", "/api/v1/svc-500/status", "exact-500"},
+		{"param", "/api/v1/svc-500/users/7", "param-500"},
+		{"prefix", "/api/v1/svc-500/orders/7", "prefix-500"},
+	} {
+		b.Run(tt.name, func(b *testing.B) {
+			req := httptest.NewRequest("GET", tt.path, nil)
+			b.ReportAllocs()
+			for range b.N {
+				if route, ok := compiled.Router.Match(req); !ok || route.Name != tt.route {
+					b.Fatalf("got %v", route)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkFilters(b *testing.B) {
+	compiled := benchConfig(b, 1)
+	route, ok := compiled.Router.Match(httptest.NewRequest("GET", "/api/v1/svc-0/orders", nil))
+	if !ok {
+		b.Fatal("no route")
+	}
+	req := httptest.NewRequest("GET", "/api/v1/svc-0/orders", nil)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		req.URL.Path = "/api/v1/svc-0/orders"
+		for _, f := range route.Filters {
+			if err := f.Apply(req); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkGateway_ServeHTTP(b *testing.B) {
+	store := NewConfigStore()
+	store.Store(benchConfig(b, 1000))
+	gw := NewGateway(store)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		rec := httptest.NewRecorder()
+		gw.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/svc-500/orders/7", nil))
+		if rec.Code != http.StatusOK {
+			b.Fatalf("status %d: %s", rec.Code, rec.Body)
+		}
+	}
+}
+
+// TestRequestPathAllocations fails when the request path allocates more
+// than its budget, catching hot-path regressions in an ordinary test run.
+// Raise a budget only for a change that needs the extra allocations.
+func TestRequestPathAllocations(t *testing.T) {
+	if raceEnabled {
+		t.Skip("allocation counts differ under the race detector")
+	}
+	compiled := benchConfig(t, 100)
+	store := NewConfigStore()
+	store.Store(compiled)
+	gw := NewGateway(store)
+
+	match := func(path string) func() {
+		req := httptest.NewRequest("GET", path, nil)
+		return func() { compiled.Router.Match(req) }
+	}
+	route, _ := compiled.Router.Match(httptest.NewRequest("GET", "/api/v1/svc-50/orders", nil))
+	filterReq := httptest.NewRequest("GET", "/api/v1/svc-50/orders", nil)
+
+	for _, tt := range []struct {
+		name   string
+		budget float64
+		fn     func()
+	}{
+		{"match exact", 1, match("/api/v1/svc-50/status")},
+		{"match param", 7, match("/api/v1/svc-50/users/7")},
+		{"match prefix", 1, match("/api/v1/svc-50/orders/7")},
+		{"filters", 5, func() {
+			filterReq.URL.Path = "/api/v1/svc-50/orders"
+			for _, f := range route.Filters {
+				f.Apply(filterReq)
+			}
+		}},
+		// net/http's share of the gateway's allocations varies between Go
+		// releases, so its budget leaves some room.
+		{"gateway", 80, func() {
+			gw.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/v1/svc-50/orders/7", nil))
+		}},
+	} {
+		if got := testing.AllocsPerRun(100, tt.fn); got > tt.budget {
+			t.Errorf("%s: %.0f allocations per request, budget %.0f", tt.name, got, tt.budget)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package runtime
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/oriys/nexus/internal/config"
+)
+
+// defaultDecompressLimit bounds a decompressed request body when the
+// decompress filter sets no max_size.
+const defaultDecompressLimit = 10 << 20
+
+// StatusError is returned by a filter rejecting a request with a status
+// other than 400 Bad Request.
+type StatusError struct {
+	Status  int
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	return e.Message
+}
+
+func errBodyTooLarge(limit int64) error {
+	return &StatusError{
+		Status:  http.StatusRequestEntityTooLarge,
+		Message: fmt.Sprintf("request body exceeds %d bytes", limit),
+	}
+}
+
+// maxBodyFilter rejects requests whose body exceeds a size with 413. A body
+// of unknown length is buffered, up to the limit, so it is checked before
+// anything is forwarded.
+type maxBodyFilter struct {
+	limit int64
+}
+
+func newMaxBodyFilter(args config.FilterArgs) (Filter, error) {
+	if err := args.Check("size"); err != nil {
+		return nil, fmt.Errorf("max_body filter: %w", err)
+	}
+	limit, err := args.Size("size")
+	if err != nil {
+		return nil, fmt.Errorf("max_body filter: %w", err)
+	}
+	if limit == 0 {
+		return nil, fmt.Errorf("max_body filter: 'size' argument is required")
+	}
+	return &maxBodyFilter{limit: limit}, nil
+}
+
+func (f *maxBodyFilter) Apply(r *http.Request) error {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+	if r.ContentLength > f.limit {
+		return errBodyTooLarge(f.limit)
+	}
+	if r.ContentLength >= 0 {
+		// The server stops reading the body at its Content-Length
+		return nil
+	}
+	body, err := readLimited(r.Body, f.limit)
+	if err != nil {
+		return err
+	}
+	setRequestBody(r, body)
+	return nil
+}
+
+// decompressFilter decodes gzip and deflate request bodies before they are
+// forwarded, bounding the decoded size to defuse compression bombs. Bodies
+// in other encodings are forwarded as they are.
+type decompressFilter struct {
+	limit int64
+}
+
+func newDecompressFilter(args config.FilterArgs) (Filter, error) {
+	if err := args.Check("max_size"); err != nil {
+		return nil, fmt.Errorf("decompress filter: %w", err)
+	}
+	limit, err := args.Size("max_size")
+	if err != nil {
+		return nil, fmt.Errorf("decompress filter: %w", err)
+	}
+	if limit == 0 {
+		limit = defaultDecompressLimit
+	}
+	return &decompressFilter{limit: limit}, nil
+}
+
+func (f *decompressFilter) Apply(r *http.Request) error {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+	var dec io.ReadCloser
+	switch strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding"))) {
+	case "gzip", "x-gzip":
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return fmt.Errorf("decompress request body: %w", err)
+		}
+		dec = zr
+	case "deflate":
+		dec = flate.NewReader(r.Body)
+	default:
+		return nil
+	}
+	body, err := readLimited(dec, f.limit)
+	dec.Close()
+	r.Body.Close()
+	if err != nil {
+		var se *StatusError
+		if errors.As(err, &se) {
+			return err
+		}
+		return fmt.Errorf("decompress request body: %w", err)
+	}
+	r.Header.Del("Content-Encoding")
+	setRequestBody(r, body)
+	return nil
+}
+
+// readLimited reads r to the end, failing with a 413 StatusError when it
+// holds more than limit bytes.
+func readLimited(r io.Reader, limit int64) ([]byte, error) {
+	body, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > limit {
+		return nil, errBodyTooLarge(limit)
+	}
+	return body, nil
+}
+
+func setRequestBody(r *http.Request, body []byte) {
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+	r.Header.Set("Content-Length", strconv.Itoa(len(body)))
+}
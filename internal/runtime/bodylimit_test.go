@@ -0,0 +1,166 @@
+package runtime
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/oriys/nexus/internal/config"
+)
+
+func gzipped(t *testing.T, s string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte(s))
+	zw.Close()
+	return buf.Bytes()
+}
+
+func TestMaxBodyFilter(t *testing.T) {
+	f, err := newMaxBodyFilter(config.FilterArgs{"size": "8B"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	req := httptest.NewRequest("POST", "/", strings.NewReader("12345678"))
+	if err := f.Apply(req); err != nil {
+		t.Errorf("body at the limit rejected: %v", err)
+	}
+
+	var se *StatusError
+	req = httptest.NewRequest("POST", "/", strings.NewReader("123456789"))
+	if err := f.Apply(req); !errors.As(err, &se) || se.Status != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413, got %v", err)
+	}
+
+	// A body of unknown length is buffered and checked
+	req = httptest.NewRequest("POST", "/", io.NopCloser(strings.NewReader("123456789")))
+	req.ContentLength = -1
+	if err := f.Apply(req); !errors.As(err, &se) {
+		t.Errorf("expected 413 for chunked body, got %v", err)
+	}
+	req = httptest.NewRequest("POST", "/", io.NopCloser(strings.NewReader("1234")))
+	req.ContentLength = -1
+	if err := f.Apply(req); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if body, _ := io.ReadAll(req.Body); string(body) != "1234" || req.ContentLength != 4 {
+		t.Errorf("body = %q, length %d", body, req.ContentLength)
+	}
+
+	if _, err := newMaxBodyFilter(config.FilterArgs{}); err == nil {
+		t.Error("expected error for missing size")
+	}
+}
+
+func TestDecompressFilter(t *testing.T) {
+	f, err := newDecompressFilter(config.FilterArgs{"max_size": "1KiB"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	req := httptest.NewRequest("POST", "/", bytes.NewReader(gzipped(t, `{"a":1}`)))
+	req.Header.Set("Content-Encoding", "gzip")
+	if err := f.Apply(req); err != nil {
+		t.Fatalf("apply error: %v", err)
+	}
+	if body, _ := io.ReadAll(req.Body); string(body) != `{"a":1}` {
+		t.Errorf("body = %q", body)
+	}
+	if req.Header.Get("Content-Encoding") != "" || req.ContentLength != 7 {
+		t.Errorf("encoding %q, length %d", req.Header.Get("Content-Encoding"), req.ContentLength)
+	}
+
+	var buf bytes.Buffer
+	fw, _ := flate.NewWriter(&buf, flate.DefaultCompression)
+	fw.Write([]byte("deflated"))
+	fw.Close()
+	req = httptest.NewRequest("POST", "/", &buf)
+	req.Header.Set("Content-Encoding", "deflate")
+	if err := f.Apply(req); err != nil {
+		t.Fatalf("apply error: %v", err)
+	}
+	if body, _ := io.ReadAll(req.Body); string(body) != "deflated" {
+		t.Errorf("body = %q", body)
+	}
+
+	// A small payload expanding past max_size is rejected
+	var se *StatusError
+	req = httptest.NewRequest("POST", "/", bytes.NewReader(gzipped(t, strings.Repeat("0", 1<<20))))
+	req.Header.Set("Content-Encoding", "gzip")
+	if err := f.Apply(req); !errors.As(err, &se) || se.Status != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413, got %v", err)
+	}
+
+	req = httptest.NewRequest("POST", "/", strings.NewReader("not gzip"))
+	req.Header.Set("Content-Encoding", "gzip")
+	if err := f.Apply(req); err == nil || errors.As(err, &se) {
+		t.Errorf("expected decode error, got %v", err)
+	}
+
+	req = httptest.NewRequest("POST", "/", strings.NewReader("br data"))
+	req.Header.Set("Content-Encoding", "br")
+	if err := f.Apply(req); err != nil || req.Header.Get("Content-Encoding") != "br" {
+		t.Errorf("unsupported encoding not passed through: %v", err)
+	}
+}
+
+func TestGateway_MaxBody(t *testing.T) {
+	var gotBody string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		gotBody = string(b)
+	}))
+	defer backend.Close()
+
+	cfg := &config.Config{
+		Clusters: []config.Cluster{
+			{Name: "backend", Type: "http", Endpoints: []config.ClusterEndpoint{{URL: backend.URL}}},
+		},
+		RoutesV2: []config.RouteV2{{
+			Name:  "upload",
+			Match: config.RouteMatch{Path: "/upload"},
+			Filters: []config.RouteFilter{
+				{Type: "max_body", Args: config.FilterArgs{"size": "64"}},
+				{Type: "decompress", Args: config.FilterArgs{"max_size": "16"}},
+			},
+			Upstream: config.RouteUpstream{Cluster: "backend"},
+		}},
+	}
+	compiled, err := Compile(cfg, 1)
+	if err != nil {
+		t.Fatalf("compile error: %v", err)
+	}
+	store := NewConfigStore()
+	store.Store(compiled)
+	gw := NewGateway(store)
+
+	rec := httptest.NewRecorder()
+	gw.ServeHTTP(rec, httptest.NewRequest("POST", "/upload", strings.NewReader(strings.Repeat("x", 65))))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversize body: status = %d", rec.Code)
+	}
+
+	req := httptest.NewRequest("POST", "/upload", bytes.NewReader(gzipped(t, strings.Repeat("y", 17))))
+	req.Header.Set("Content-Encoding", "gzip")
+	rec = httptest.NewRecorder()
+	gw.ServeHTTP(rec, req)
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversize decompressed body: status = %d", rec.Code)
+	}
+
+	req = httptest.NewRequest("POST", "/upload", bytes.NewReader(gzipped(t, "hello")))
+	req.Header.Set("Content-Encoding", "gzip")
+	rec = httptest.NewRecorder()
+	gw.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || gotBody != "hello" {
+		t.Errorf("status = %d, backend got %q", rec.Code, gotBody)
+	}
+}
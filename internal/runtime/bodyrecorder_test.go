@@ -0,0 +1,24 @@
+package runtime
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBodyRecorder(t *testing.T) {
+	w := httptest.NewRecorder()
+	rec := &bodyRecorder{ResponseWriter: w, limit: 8}
+	rec.Write([]byte("hello"))
+	if rec.status != http.StatusOK || rec.body.String() != "hello" || rec.overflow {
+		t.Fatalf("status %d, body %q, overflow %v", rec.status, rec.body.String(), rec.overflow)
+	}
+	// Past the limit the copy is dropped, but the client gets everything.
+	rec.Write([]byte(" world"))
+	if !rec.overflow || rec.body.Len() != 0 || w.Body.String() != "hello world" {
+		t.Errorf("overflow %v, kept %q, sent %q", rec.overflow, rec.body.String(), w.Body.String())
+	}
+	if http.NewResponseController(rec).Flush() != nil || !w.Flushed {
+		t.Error("expected the flush to reach the underlying writer")
+	}
+}
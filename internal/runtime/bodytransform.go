@@ -0,0 +1,122 @@
+package runtime
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"text/template"
+
+	"github.com/oriys/nexus/internal/config"
+)
+
+// bodyTemplateFuncs are the functions available to body_transform templates.
+var bodyTemplateFuncs = template.FuncMap{
+	// json renders a value as JSON, e.g. {{json .user}}.
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// bodyTransformFilter reshapes a JSON request body with a Go template. The
+// decoded body is the template's data and the output must be valid JSON.
+type bodyTransformFilter struct {
+	tmpl *template.Template
+}
+
+// responseBodyTransformFilter applies a body_transform template to the
+// upstream response instead of the request.
+type responseBodyTransformFilter struct {
+	bodyTransformFilter
+}
+
+func newBodyTransformFilter(args config.FilterArgs) (Filter, error) {
+	text, err := requiredArg(args, "template", "phase")
+	if err != nil {
+		return nil, fmt.Errorf("body_transform filter: %w", err)
+	}
+	phase, err := args.String("phase")
+	if err != nil {
+		return nil, fmt.Errorf("body_transform filter: %w", err)
+	}
+	tmpl, err := template.New("body_transform").Funcs(bodyTemplateFuncs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("body_transform filter template: %w", err)
+	}
+	f := bodyTransformFilter{tmpl: tmpl}
+	switch phase {
+	case "", "request":
+		return &f, nil
+	case "response":
+		return &responseBodyTransformFilter{f}, nil
+	default:
+		return nil, fmt.Errorf("body_transform filter 'phase' must be request or response")
+	}
+}
+
+func (f *bodyTransformFilter) Apply(r *http.Request) error {
+	if r.Body == nil || r.Body == http.NoBody || !isJSONContent(r.Header) {
+		return nil
+	}
+	body, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		return fmt.Errorf("read request body: %w", err)
+	}
+	out, err := f.transform(body)
+	if err != nil {
+		return err
+	}
+	setRequestBody(r, out)
+	return nil
+}
+
+func (f *responseBodyTransformFilter) Apply(r *http.Request) error { return nil }
+
+func (f *responseBodyTransformFilter) ApplyResponse(resp *http.Response) error {
+	if !rewritableBody(resp) || !isJSONContent(resp.Header) {
+		return nil
+	}
+	body, err := readResponseBody(resp)
+	if err != nil {
+		return err
+	}
+	out, err := f.transform(body)
+	if err != nil {
+		return err
+	}
+	setResponseBody(resp, out)
+	return nil
+}
+
+// transform renders the template over the decoded JSON body. An empty body
+// is passed through.
+func (f *bodyTransformFilter) transform(body []byte) ([]byte, error) {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return body, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var data any
+	if err := dec.Decode(&data); err != nil {
+		return nil, fmt.Errorf("body_transform: invalid JSON body: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := f.tmpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("body_transform: %w", err)
+	}
+	if !json.Valid(buf.Bytes()) {
+		return nil, fmt.Errorf("body_transform: template output is not valid JSON")
+	}
+	return buf.Bytes(), nil
+}
+
+// isJSONContent reports whether h declares a JSON body.
+func isJSONContent(h http.Header) bool {
+	mt, _, _ := mime.ParseMediaType(h.Get("Content-Type"))
+	return mt == "application/json" || strings.HasSuffix(mt, "+json")
+}
@@ -0,0 +1,115 @@
+package runtime
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/oriys/nexus/internal/config"
+)
+
+func TestBodyTransformFilter_Request(t *testing.T) {
+	f, err := newBodyTransformFilter(config.FilterArgs{
+		"template": `{"user": {"id": {{json .uid}}, "name": {{json .full_name}}}, "tags": [{{range $i, $t := .tags}}{{if $i}},{{end}}{{json $t}}{{end}}]}`,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	req := httptest.NewRequest("POST", "/users", strings.NewReader(`{"uid": 12345678901234567890, "full_name": "Ann \"A\"", "tags": ["a", "b"]}`))
+	req.Header.Set("Content-Type", "application/json")
+	if err := f.Apply(req); err != nil {
+		t.Fatalf("apply error: %v", err)
+	}
+	body, _ := io.ReadAll(req.Body)
+	want := `{"user": {"id": 12345678901234567890, "name": "Ann \"A\""}, "tags": ["a","b"]}`
+	if string(body) != want {
+		t.Errorf("body = %s", body)
+	}
+	if req.ContentLength != int64(len(want)) {
+		t.Errorf("content length = %d", req.ContentLength)
+	}
+}
+
+func TestBodyTransformFilter_Errors(t *testing.T) {
+	if _, err := newBodyTransformFilter(config.FilterArgs{}); err == nil {
+		t.Error("expected error for missing template")
+	}
+	if _, err := newBodyTransformFilter(config.FilterArgs{"template": "{{.a"}); err == nil {
+		t.Error("expected error for unparsable template")
+	}
+	if _, err := newBodyTransformFilter(config.FilterArgs{"template": "{}", "phase": "both"}); err == nil {
+		t.Error("expected error for unknown phase")
+	}
+
+	f, _ := newBodyTransformFilter(config.FilterArgs{"template": `{"a": {{.a}}`})
+	req := httptest.NewRequest("POST", "/", strings.NewReader(`{"a": 1}`))
+	req.Header.Set("Content-Type", "application/json")
+	if err := f.Apply(req); err == nil || !strings.Contains(err.Error(), "not valid JSON") {
+		t.Errorf("expected invalid output error, got %v", err)
+	}
+
+	req = httptest.NewRequest("POST", "/", strings.NewReader(`{"a":`))
+	req.Header.Set("Content-Type", "application/json")
+	if err := f.Apply(req); err == nil || !strings.Contains(err.Error(), "invalid JSON body") {
+		t.Errorf("expected invalid body error, got %v", err)
+	}
+
+	// Non-JSON bodies are forwarded untouched
+	req = httptest.NewRequest("POST", "/", strings.NewReader("a=1"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if err := f.Apply(req); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if body, _ := io.ReadAll(req.Body); string(body) != "a=1" {
+		t.Errorf("body = %s", body)
+	}
+}
+
+func TestGateway_BodyTransform(t *testing.T) {
+	var gotBody string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		gotBody = string(b)
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"result": {"items": [1, 2]}, "meta": {"total": 2}}`)
+	}))
+	defer backend.Close()
+
+	cfg := &config.Config{
+		Clusters: []config.Cluster{
+			{Name: "backend", Type: "http", Endpoints: []config.ClusterEndpoint{{URL: backend.URL}}},
+		},
+		RoutesV2: []config.RouteV2{{
+			Name:  "legacy",
+			Match: config.RouteMatch{Path: "/orders"},
+			Filters: []config.RouteFilter{
+				{Type: "body_transform", Args: config.FilterArgs{"template": `{"order_id": {{json .id}}}`}},
+				{Type: "body_transform", Args: config.FilterArgs{"phase": "response", "template": `{"data": {{json .result.items}}, "total": {{.meta.total}}}`}},
+			},
+			Upstream: config.RouteUpstream{Cluster: "backend"},
+		}},
+	}
+	compiled, err := Compile(cfg, 1)
+	if err != nil {
+		t.Fatalf("compile error: %v", err)
+	}
+	store := NewConfigStore()
+	store.Store(compiled)
+
+	req := httptest.NewRequest("POST", "/orders", strings.NewReader(`{"id": "o-1"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	NewGateway(store).ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	if gotBody != `{"order_id": "o-1"}` {
+		t.Errorf("backend got %s", gotBody)
+	}
+	if rec.Body.String() != `{"data": [1,2], "total": 2}` {
+		t.Errorf("body = %s", rec.Body)
+	}
+}
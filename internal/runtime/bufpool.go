@@ -0,0 +1,69 @@
+package runtime
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// maxPooledBuffer is the largest buffer returned to the pool; larger ones
+// are left to the garbage collector so one huge body does not stay pinned.
+const maxPooledBuffer = 1 << 20
+
+// bodyBuffers holds the buffers protocol rewrites read bodies into.
+var bodyBuffers = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+
+func getBuffer() *bytes.Buffer {
+	return bodyBuffers.Get().(*bytes.Buffer)
+}
+
+func putBuffer(b *bytes.Buffer) {
+	if b.Cap() > maxPooledBuffer {
+		return
+	}
+	b.Reset()
+	bodyBuffers.Put(b)
+}
+
+// readPooled reads r to its end into a pooled buffer, which the caller
+// returns with putBuffer.
+func readPooled(r io.Reader) (*bytes.Buffer, error) {
+	b := getBuffer()
+	if _, err := b.ReadFrom(r); err != nil {
+		putBuffer(b)
+		return nil, err
+	}
+	return b, nil
+}
+
+// pooledBody is a request or response body reading from a pooled buffer,
+// which it returns to the pool when closed. Transports may close a request
+// body while another goroutine reads it, so reads and Close are serialized.
+type pooledBody struct {
+	mu  sync.Mutex
+	r   io.Reader
+	buf *bytes.Buffer
+}
+
+func newPooledBody(r io.Reader, buf *bytes.Buffer) *pooledBody {
+	return &pooledBody{r: r, buf: buf}
+}
+
+func (b *pooledBody) Read(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.buf == nil {
+		return 0, io.EOF
+	}
+	return b.r.Read(p)
+}
+
+func (b *pooledBody) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.buf != nil {
+		putBuffer(b.buf)
+		b.buf, b.r = nil, nil
+	}
+	return nil
+}
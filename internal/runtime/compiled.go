@@ -1,11 +1,20 @@
 package runtime
 
 import (
+	"crypto/tls"
+	"fmt"
 	"net/http"
+	"net/url"
+	"regexp"
 	"strings"
 	"sync/atomic"
+	"time"
 
 	"github.com/oriys/nexus/internal/config"
+	"github.com/oriys/nexus/internal/dubbo"
+	"github.com/oriys/nexus/internal/graphql"
+	"github.com/oriys/nexus/internal/pathmatch"
+	"github.com/oriys/nexus/internal/transcode"
 )
 
 // CompiledConfig is the pre-compiled, read-only configuration used at request time.
@@ -15,7 +24,21 @@ type CompiledConfig struct {
 	Router    *RouterIndex
 	Clusters  map[string]*CompiledCluster
 	Filters   *FilterRegistry
+	Protos    *transcode.Registry
 	Version   uint64
+	// Fallback serves the requests no route matches, from
+	// server.fallback.cluster; nil when not configured.
+	Fallback *CompiledRoute
+	// NotFoundStatus and NotFoundBody answer the requests no route matches
+	// with JSON, from server.fallback; a nil body answers with plain text.
+	NotFoundStatus int
+	NotFoundBody   []byte
+	// LoadShedder rejects requests of low priority tiers while the gateway
+	// is overloaded; nil when load shedding is not configured.
+	LoadShedder *LoadShedder
+	// TenantClusters maps tenants to the clusters serving them in place of
+	// the clusters of their routes, from tenancy.tenants.
+	TenantClusters map[string]map[string]string
 }
 
 // CompiledCluster holds a pre-compiled cluster with resolved endpoints.
@@ -28,7 +51,51 @@ type CompiledCluster struct {
 	GRPC      *config.ClusterGRPC
 	Dubbo     *config.ClusterDubbo
 	GraphQL   *config.ClusterGraphQL
-	counter   atomic.Uint64
+	// Services lists the gRPC services discovered through server reflection.
+	Services []GRPCService
+	// DubboRegistry lists the providers of a Dubbo cluster with a registry
+	// configured; nil otherwise.
+	DubboRegistry dubbo.Registry
+	counter       atomic.Uint64
+	// targets holds the URL of each of Endpoints, resolved by Compile.
+	targets []*url.URL
+	// transport is the managed HTTP/2 transport for gRPC clusters.
+	transport *grpcTransport
+	// proxyTransport is the pooled transport for clusters proxied over
+	// HTTP.
+	proxyTransport *httpTransport
+	// dubboPool holds the provider connections of native Dubbo clusters.
+	dubboPool *dubbo.Pool
+}
+
+// grpcTransport returns the cluster's managed gRPC transport. Clusters that
+// were not built by Compile fall back to the shared pool.
+func (c *CompiledCluster) grpcTransport() *grpcTransport {
+	if c.transport != nil {
+		return c.transport
+	}
+	return grpcTransports.forCluster(c)
+}
+
+// roundTripper returns the transport requests proxied to the cluster use.
+// Clusters that were not built by Compile fall back to the shared pools.
+func (c *CompiledCluster) roundTripper() http.RoundTripper {
+	if c.Type == "grpc" || tripleDubbo(c) {
+		return c.grpcTransport()
+	}
+	if c.proxyTransport != nil {
+		return c.proxyTransport
+	}
+	return httpTransports.forCluster(c)
+}
+
+// dubboClients returns the cluster's Dubbo connection pool. Clusters that
+// were not built by Compile fall back to the shared pools.
+func (c *CompiledCluster) dubboClients() *dubbo.Pool {
+	if c.dubboPool != nil {
+		return c.dubboPool
+	}
+	return dubboPools.forCluster(c)
 }
 
 // NextEndpoint returns the next endpoint using round-robin load balancing.
@@ -40,6 +107,68 @@ func (c *CompiledCluster) NextEndpoint() (config.ClusterEndpoint, bool) {
 	return c.Endpoints[idx%uint64(len(c.Endpoints))], true
 }
 
+// NextTarget picks the next endpoint as NextEndpoint does, returning its
+// URL and address. Clusters that were not built by Compile resolve the URL
+// on each call.
+func (c *CompiledCluster) NextTarget() (*url.URL, string, error) {
+	if len(c.Endpoints) == 0 {
+		return nil, "", fmt.Errorf("no endpoints available for cluster %s", c.Name)
+	}
+	i := (c.counter.Add(1) - 1) % uint64(len(c.Endpoints))
+	addr := EndpointAddress(c.Endpoints[i])
+	if len(c.targets) == len(c.Endpoints) {
+		return c.targets[i], addr, nil
+	}
+	target, err := c.resolveTarget(addr)
+	return target, addr, err
+}
+
+// resolveTarget returns the URL of the endpoint at addr: the base URL of
+// HTTP/2 requests for gRPC and triple clusters, the provider address for
+// native Dubbo clusters, and the URL requests are proxied to for the
+// others, where addresses without a scheme are taken as http.
+func (c *CompiledCluster) resolveTarget(addr string) (*url.URL, error) {
+	switch {
+	case c.Type == "grpc":
+		return GRPCTargetURL(addr)
+	case tripleDubbo(c):
+		return TripleTargetURL(addr)
+	case nativeDubbo(c):
+		provider, err := DubboProviderAddr(addr)
+		if err != nil {
+			return nil, err
+		}
+		return &url.URL{Scheme: "dubbo", Host: provider}, nil
+	}
+	raw := addr
+	if !strings.Contains(raw, "://") {
+		raw = "http://" + raw
+	}
+	target, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid upstream target %s: %w", addr, err)
+	}
+	if target.Host == "" {
+		return nil, fmt.Errorf("invalid upstream target %s: missing host", addr)
+	}
+	return target, nil
+}
+
+// PeekEndpoint returns the endpoint NextEndpoint returns next, without
+// advancing the load balancer.
+func (c *CompiledCluster) PeekEndpoint() (config.ClusterEndpoint, bool) {
+	if len(c.Endpoints) == 0 {
+		return config.ClusterEndpoint{}, false
+	}
+	return c.Endpoints[c.counter.Load()%uint64(len(c.Endpoints))], true
+}
+
+// Picks returns the number of endpoints NextEndpoint has handed out, so the
+// endpoint it returns next is Endpoints[Picks() % len(Endpoints)].
+func (c *CompiledCluster) Picks() uint64 {
+	return c.counter.Load()
+}
+
 // EndpointAddress returns the effective address of an endpoint.
 func EndpointAddress(ep config.ClusterEndpoint) string {
 	if ep.URL != "" {
@@ -51,21 +180,131 @@ func EndpointAddress(ep config.ClusterEndpoint) string {
 	return ep.Addr
 }
 
+// GRPCTargetURL converts a gRPC endpoint address into the base URL used for
+// HTTP/2 requests. It accepts plain "host:port", gRPC "dns:///host:port"
+// targets, and explicit http/https URLs.
+func GRPCTargetURL(addr string) (*url.URL, error) {
+	if rest, ok := strings.CutPrefix(addr, "dns:///"); ok {
+		addr = rest
+	} else if rest, ok := strings.CutPrefix(addr, "dns:"); ok {
+		addr = rest
+	}
+	if !strings.Contains(addr, "://") {
+		addr = "http://" + addr
+	}
+	u, err := url.Parse(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid grpc target %s: %w", addr, err)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid grpc target %s: missing host", addr)
+	}
+	return u, nil
+}
+
+// GRPCService describes a service discovered through server reflection.
+type GRPCService struct {
+	Name    string   `json:"name"`
+	Methods []string `json:"methods"`
+}
+
 // CompiledRoute holds a pre-compiled route with resolved filters and upstream.
 type CompiledRoute struct {
-	Name      string
-	Match     CompiledMatch
-	Filters   []Filter
-	Upstream  RouteUpstreamConfig
-	TimeoutMs int
+	Name    string
+	Match   CompiledMatch
+	Filters []Filter
+	// FilterTypes holds the type of each of Filters.
+	FilterTypes []string
+	Upstream    RouteUpstreamConfig
+	TimeoutMs   int
+	// Priority orders the route among those competing for a request.
+	Priority int
+	// GRPCTranscode is set when the route converts JSON bodies to and from
+	// binary protobuf; nil means bodies are forwarded as-is.
+	GRPCTranscode *GRPCTranscode
+	// GRPCMetadata holds the canonical names of inbound headers forwarded to a
+	// gRPC upstream; nil forwards all headers.
+	GRPCMetadata map[string]struct{}
+	// GRPCAllow restricts the methods a passthrough gRPC route forwards; nil
+	// allows every method.
+	GRPCAllow *GRPCAllowlist
+	// TripleTranscode holds the IDL message codecs for a Dubbo route on a
+	// triple cluster with protobuf serialization.
+	TripleTranscode *GRPCTranscode
+	// DubboParams maps request inputs to the arguments of a Dubbo route; nil
+	// sends the JSON body as the arguments.
+	DubboParams *DubboParams
+	// GraphQLAllow restricts the operations a GraphQL route executes; nil
+	// allows every operation.
+	GraphQLAllow *GraphQLAllowlist
+	// PersistedQueries resolves persisted query hashes for a GraphQL route;
+	// nil when persisted queries are disabled.
+	PersistedQueries *graphql.PersistedQueryStore
+	// GraphQLCache caches the responses of a GraphQL route; nil when caching
+	// is disabled.
+	GraphQLCache *GraphQLCache
+	// GraphQLFederation executes the operations of a federated GraphQL
+	// route across subgraph clusters; nil when the route proxies to its
+	// cluster.
+	GraphQLFederation *GraphQLFederation
+	// GraphQLSubscriptions proxies the WebSocket subscription connections
+	// of a GraphQL route; nil when subscriptions are disabled.
+	GraphQLSubscriptions *GraphQLSubscriptions
+	// ResponseFilters holds the filters of Filters that rewrite the upstream
+	// response, in order.
+	ResponseFilters []ResponseFilter
+	// Mock renders the responses of a route with upstream.mock; nil when the
+	// route forwards to its cluster.
+	Mock *MockResponse
+	// Idempotency replays responses to requests with a repeated
+	// idempotency key; nil when the route does not deduplicate requests.
+	Idempotency *IdempotencyCache
+	// Cache serves the route's GET and HEAD requests from the response
+	// cache; nil when the route is not cached.
+	Cache *RouteCache
+	// Maintenance answers the route's requests while the route is in
+	// maintenance; nil otherwise.
+	Maintenance *MaintenancePage
+	// Tier is the priority tier of the route's requests under load
+	// shedding; empty for the default tier.
+	Tier string
+	// stats counts the route's requests.
+	stats *RouteCounters
+}
+
+// GRPCTranscode holds the resolved message codecs for a transcoding gRPC route.
+type GRPCTranscode struct {
+	Request  *transcode.Codec // nil when the request body is passed through
+	Response *transcode.Codec // nil when the response body is passed through
+	// ClientStreaming and ServerStreaming mirror the method descriptor. Streams
+	// are exchanged with HTTP clients as newline-delimited JSON.
+	ClientStreaming bool
+	ServerStreaming bool
 }
 
 // RouteUpstreamConfig holds the upstream configuration for a compiled route.
 type RouteUpstreamConfig struct {
 	ClusterName string
-	GRPC        *config.RouteUpstreamGRPC
-	Dubbo       *config.RouteUpstreamDubbo
-	GraphQL     *config.RouteUpstreamGraphQL
+	// Split divides the traffic between weighted clusters; ClusterName is
+	// empty when it is set.
+	Split *ClusterSplit
+	// Outcomes counts the responses of the cluster for routes whose cluster
+	// is switched over; nil for the other routes.
+	Outcomes *UpstreamOutcomes
+	// Mirror copies requests to a shadow cluster; nil when the route is
+	// not mirrored.
+	Mirror  *RouteMirror
+	GRPC    *config.RouteUpstreamGRPC
+	Dubbo   *config.RouteUpstreamDubbo
+	GraphQL *config.RouteUpstreamGraphQL
+}
+
+// Cluster returns the name of the cluster serving r.
+func (u *RouteUpstreamConfig) Cluster(r *http.Request) string {
+	if u.Split != nil {
+		return u.Split.Pick(r)
+	}
+	return u.ClusterName
 }
 
 // CompiledMatch holds pre-compiled match criteria for fast evaluation.
@@ -73,112 +312,324 @@ type CompiledMatch struct {
 	Methods    map[string]struct{} // nil means match all
 	Path       string              // exact path match (empty = not used)
 	PathPrefix string              // prefix match (empty = not used)
-	Headers    []CompiledHeaderMatch
+	// Pattern matches path templates and regexes; Path and PathPrefix are
+	// empty when it is set.
+	Pattern *pathmatch.Pattern
+	// norm normalizes request paths before they are compared with Path,
+	// PathPrefix and Pattern, which are normalized already.
+	norm    pathNorm
+	Headers []CompiledHeaderMatch
+	Query   []CompiledQueryMatch
+	Cookies []CompiledCookieMatch
+	TLS     *CompiledTLSMatch     // nil means any connection
+	GraphQL *CompiledGraphQLMatch // nil means any request
+	// Schedule limits when the route matches; nil means always.
+	Schedule *CompiledSchedule
 }
 
-// CompiledHeaderMatch is a pre-compiled header matcher.
+// CompiledHeaderMatch is a pre-compiled header matcher on the first value
+// of the header.
 type CompiledHeaderMatch struct {
 	Name     string
 	Exact    string
 	Contains string
+	Regex    *regexp.Regexp // anchored to the whole value
+	Present  bool           // the header must be present
+	Absent   bool           // the header must be absent
+	Invert   bool           // the rule matches when the rest does not
+}
+
+func (h *CompiledHeaderMatch) matches(header http.Header) bool {
+	values := header.Values(h.Name)
+	var val string
+	if len(values) > 0 {
+		val = values[0]
+	}
+	ok := !(h.Present && len(values) == 0) && !(h.Absent && len(values) > 0) &&
+		(h.Exact == "" || val == h.Exact) &&
+		(h.Contains == "" || strings.Contains(val, h.Contains)) &&
+		(h.Regex == nil || len(values) > 0 && h.Regex.MatchString(val))
+	return ok != h.Invert
+}
+
+// CompiledCookieMatch is a pre-compiled cookie matcher. With neither Exact,
+// Regex nor Absent set, the cookie only has to be present.
+type CompiledCookieMatch struct {
+	Name   string
+	Exact  string
+	Regex  *regexp.Regexp // anchored to the whole value
+	Absent bool           // the cookie must be absent
+	Invert bool           // the rule matches when the rest does not
+}
+
+func (c *CompiledCookieMatch) matches(r *http.Request) bool {
+	cookie, err := r.Cookie(c.Name)
+	var ok bool
+	switch {
+	case c.Absent:
+		ok = err != nil
+	case err != nil:
+		ok = false
+	case c.Regex != nil:
+		ok = c.Regex.MatchString(cookie.Value)
+	case c.Exact != "":
+		ok = cookie.Value == c.Exact
+	default:
+		ok = true
+	}
+	return ok != c.Invert
+}
+
+// CompiledQueryMatch is a pre-compiled query parameter matcher. With
+// neither Exact nor Regex set, the parameter only has to be present.
+type CompiledQueryMatch struct {
+	Name  string
+	Exact string
+	Regex *regexp.Regexp // anchored to the whole value
+}
+
+func (q *CompiledQueryMatch) matches(query url.Values) bool {
+	values, ok := query[q.Name]
+	if !ok {
+		return false
+	}
+	if q.Exact == "" && q.Regex == nil {
+		return true
+	}
+	for _, v := range values {
+		if q.Regex != nil && q.Regex.MatchString(v) || q.Regex == nil && v == q.Exact {
+			return true
+		}
+	}
+	return false
+}
+
+// CompiledTLSMatch is a pre-compiled TLS connection matcher. Nil lists and
+// sets match any value.
+type CompiledTLSMatch struct {
+	SNI []string // lower-cased, exact or "*.example.com" wildcards
+	// ClientCert requires a verified client certificate, whose attributes
+	// must be in the sets below.
+	ClientCert          bool
+	CommonNames         map[string]struct{}
+	OrganizationalUnits map[string]struct{}
+	SANs                map[string]struct{}
+}
+
+func (m *CompiledTLSMatch) matches(cs *tls.ConnectionState) bool {
+	if cs == nil {
+		return false
+	}
+	if m.SNI != nil && !matchesServerName(m.SNI, strings.ToLower(cs.ServerName)) {
+		return false
+	}
+	if !m.ClientCert {
+		return true
+	}
+	if len(cs.VerifiedChains) == 0 {
+		return false
+	}
+	cert := cs.VerifiedChains[0][0]
+	if _, ok := m.CommonNames[cert.Subject.CommonName]; m.CommonNames != nil && !ok {
+		return false
+	}
+	if m.OrganizationalUnits != nil && !containsAny(m.OrganizationalUnits, cert.Subject.OrganizationalUnit) {
+		return false
+	}
+	if m.SANs != nil {
+		sans := append(append([]string(nil), cert.DNSNames...), cert.EmailAddresses...)
+		for _, u := range cert.URIs {
+			sans = append(sans, u.String())
+		}
+		for _, ip := range cert.IPAddresses {
+			sans = append(sans, ip.String())
+		}
+		if !containsAny(m.SANs, sans) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesServerName reports whether name is one of names or a subdomain of
+// one of their wildcards.
+func matchesServerName(names []string, name string) bool {
+	for _, n := range names {
+		if suffix, ok := strings.CutPrefix(n, "*"); ok {
+			if strings.HasSuffix(name, suffix) && len(name) > len(suffix) {
+				return true
+			}
+		} else if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+func containsAny(set map[string]struct{}, values []string) bool {
+	for _, v := range values {
+		if _, ok := set[v]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// CompiledGraphQLMatch is a pre-compiled GraphQL operation matcher.
+type CompiledGraphQLMatch struct {
+	Types map[string]struct{} // nil means any operation type
+	Names map[string]struct{} // nil means any operation name
+	// MaxBodyBytes bounds the request body read to find the operation.
+	MaxBodyBytes int64
+	// Persisted resolves hash-only persisted queries; nil leaves them
+	// unmatched.
+	Persisted *graphql.PersistedQueryStore
+}
+
+// requestOperation reads the GraphQL operation of a request once for all
+// the routes tried.
+type requestOperation struct {
+	read      bool
+	ok        bool
+	typ, name string
+}
+
+func (o *requestOperation) get(r *http.Request, m *CompiledGraphQLMatch) (typ, name string, ok bool) {
+	if !o.read {
+		o.read = true
+		o.typ, o.name, o.ok = readOperation(r, m)
+	}
+	return o.typ, o.name, o.ok
+}
+
+// readOperation returns the type and name of the GraphQL operation r
+// executes. WebSocket upgrades are subscriptions. The body is replaced, so
+// r can still be forwarded.
+func readOperation(r *http.Request, m *CompiledGraphQLMatch) (typ, name string, ok bool) {
+	if isWebSocketUpgrade(r) {
+		return graphql.Subscription, "", true
+	}
+	req, err := graphql.ReadRequest(r, m.MaxBodyBytes)
+	if err != nil {
+		return "", "", false
+	}
+	query := req.Query
+	if query == "" && m.Persisted != nil {
+		query, _ = m.Persisted.Lookup(req.PersistedQueryHash(), false)
+	}
+	if query == "" {
+		return "", "", false
+	}
+	doc, err := graphql.Parse(query)
+	if err != nil {
+		return "", "", false
+	}
+	op, err := doc.Operation(req.OperationName)
+	if err != nil {
+		return "", "", false
+	}
+	return op.Type, op.Name, true
 }
 
 // Matches returns true if the request matches this compiled match.
 func (m *CompiledMatch) Matches(r *http.Request) bool {
+	return m.matches(r, &requestOperation{})
+}
+
+func (m *CompiledMatch) matches(r *http.Request, op *requestOperation) bool {
 	// Check method
 	if m.Methods != nil {
 		if _, ok := m.Methods[r.Method]; !ok {
 			return false
 		}
 	}
+	return m.matchesAnyMethod(r, op)
+}
 
+// matchesAnyMethod is matches without the method check.
+func (m *CompiledMatch) matchesAnyMethod(r *http.Request, op *requestOperation) bool {
+	if m.Schedule != nil && !m.Schedule.Active(time.Now()) {
+		return false
+	}
 	path := r.URL.Path
 
 	// Check exact path
 	if m.Path != "" {
-		if path != m.Path {
+		if m.norm.exact(path) != m.Path {
 			return false
 		}
 	}
 
 	// Check path prefix
 	if m.PathPrefix != "" {
-		if !strings.HasPrefix(path, m.PathPrefix) {
+		if !strings.HasPrefix(m.norm.prefix(path), m.PathPrefix) {
 			return false
 		}
 	}
 
-	// Check headers
-	for _, h := range m.Headers {
-		val := r.Header.Get(h.Name)
-		if h.Exact != "" && val != h.Exact {
+	// Check path template or regex
+	if m.Pattern != nil {
+		if _, _, ok := m.Pattern.Match(m.norm.pattern(path)); !ok {
 			return false
 		}
-		if h.Contains != "" && !strings.Contains(val, h.Contains) {
+	}
+
+	// Check headers
+	for i := range m.Headers {
+		if !m.Headers[i].matches(r.Header) {
 			return false
 		}
 	}
 
-	return true
-}
-
-// RouterIndex provides O(1)/O(logN) route matching.
-type RouterIndex struct {
-	// exactRoutes maps "METHOD|path" → *CompiledRoute for O(1) exact lookups.
-	exactRoutes map[string]*CompiledRoute
-	// prefixRoutes is sorted by prefix length (longest first) for longest-prefix matching.
-	prefixRoutes []*prefixRouteEntry
-}
-
-type prefixRouteEntry struct {
-	prefix string
-	route  *CompiledRoute
-}
-
-// Match finds the best matching route for the request.
-func (ri *RouterIndex) Match(r *http.Request) (*CompiledRoute, bool) {
-	if ri == nil {
-		return nil, false
+	// Check cookies
+	for i := range m.Cookies {
+		if !m.Cookies[i].matches(r) {
+			return false
+		}
 	}
 
-	path := r.URL.Path
-	method := r.Method
-
-	// Try exact match first: "METHOD|path"
-	key := method + "|" + path
-	if route, ok := ri.exactRoutes[key]; ok {
-		if route.Match.Matches(r) {
-			return route, true
+	// Check query parameters
+	if len(m.Query) > 0 {
+		query := r.URL.Query()
+		for i := range m.Query {
+			if !m.Query[i].matches(query) {
+				return false
+			}
 		}
 	}
-	// Try without method for wildcard method routes
-	key = "|" + path
-	if route, ok := ri.exactRoutes[key]; ok {
-		if route.Match.Matches(r) {
-			return route, true
-		}
+
+	// Check the TLS connection
+	if m.TLS != nil && !m.TLS.matches(r.TLS) {
+		return false
 	}
 
-	// Try prefix match (longest prefix wins)
-	for _, pe := range ri.prefixRoutes {
-		if strings.HasPrefix(path, pe.prefix) {
-			if pe.route.Match.Matches(r) {
-				return pe.route, true
-			}
+	// Check the GraphQL operation last, since it reads the body
+	if g := m.GraphQL; g != nil {
+		typ, name, ok := op.get(r, g)
+		if !ok {
+			return false
+		}
+		if _, ok := g.Types[typ]; g.Types != nil && !ok {
+			return false
+		}
+		if _, ok := g.Names[name]; g.Names != nil && !ok {
+			return false
 		}
 	}
 
-	return nil, false
+	return true
 }
 
 // ConfigStore provides atomic access to the current CompiledConfig.
 type ConfigStore struct {
-	current atomic.Value // stores *CompiledConfig
+	current     atomic.Value // stores *CompiledConfig
+	descriptors atomic.Pointer[transcode.DescriptorStore]
+	persisted   *graphql.PersistedQueryStore
 }
 
 // NewConfigStore creates a new ConfigStore.
 func NewConfigStore() *ConfigStore {
-	return &ConfigStore{}
+	return &ConfigStore{persisted: graphql.NewPersistedQueryStore(0)}
 }
 
 // Store atomically stores a new CompiledConfig.
@@ -194,3 +645,20 @@ func (s *ConfigStore) Load() *CompiledConfig {
 	}
 	return v.(*CompiledConfig)
 }
+
+// PersistedQueries returns the persisted query store shared by the GraphQL
+// routes that enable persisted queries. It outlives compiled configurations,
+// so learned queries and the manifest survive reloads.
+func (s *ConfigStore) PersistedQueries() *graphql.PersistedQueryStore {
+	return s.persisted
+}
+
+// Descriptors returns the descriptor sets uploaded at runtime, which are
+// merged into every compiled configuration. The result must not be modified;
+// Clone it and pass the copy to ApplyDescriptors instead.
+func (s *ConfigStore) Descriptors() *transcode.DescriptorStore {
+	if ds := s.descriptors.Load(); ds != nil {
+		return ds
+	}
+	return transcode.NewDescriptorStore()
+}
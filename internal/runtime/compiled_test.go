@@ -1,12 +1,16 @@
 package runtime
 
 import (
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"reflect"
 	"strings"
 	"testing"
 
 	"github.com/oriys/nexus/internal/config"
+	"github.com/oriys/nexus/internal/graphql"
 )
 
 func TestCompile_BasicHTTPRoute(t *testing.T) {
@@ -29,8 +33,8 @@ func TestCompile_BasicHTTPRoute(t *testing.T) {
 					PathPrefix: "/api/v1/http/",
 				},
 				Filters: []config.RouteFilter{
-					{Type: "strip_prefix", Args: map[string]string{"prefix": "/api/v1/http"}},
-					{Type: "header_set", Args: map[string]string{"key": "x-gw", "value": "nova"}},
+					{Type: "strip_prefix", Args: config.FilterArgs{"prefix": "/api/v1/http"}},
+					{Type: "header_set", Args: config.FilterArgs{"key": "x-gw", "value": "nova"}},
 				},
 				Upstream: config.RouteUpstream{
 					Cluster:   "user-http",
@@ -65,6 +69,64 @@ func TestCompile_BasicHTTPRoute(t *testing.T) {
 	}
 }
 
+func TestCompile_RouteTimeout(t *testing.T) {
+	cfg := &config.Config{
+		Clusters: []config.Cluster{{Name: "web", Endpoints: []config.ClusterEndpoint{{URL: "http://web:8080"}}}},
+		RoutesV2: []config.RouteV2{
+			{Name: "legacy", Match: config.RouteMatch{Path: "/a"}, Upstream: config.RouteUpstream{Cluster: "web", TimeoutMs: 2500}},
+			{Name: "unit", Match: config.RouteMatch{Path: "/b"}, Upstream: config.RouteUpstream{Cluster: "web", Timeout: "1m30s"}},
+			{Name: "fine", Match: config.RouteMatch{Path: "/c"}, Upstream: config.RouteUpstream{Cluster: "web", Timeout: "500us"}},
+		},
+	}
+	compiled, err := Compile(cfg, 1)
+	if err != nil {
+		t.Fatalf("compile error: %v", err)
+	}
+	for path, want := range map[string]int{"/a": 2500, "/b": 90000, "/c": 1} {
+		route, ok := compiled.Router.Match(httptest.NewRequest("GET", path, nil))
+		if !ok {
+			t.Fatalf("route %s not matched", path)
+		}
+		if route.TimeoutMs != want {
+			t.Errorf("route %s: TimeoutMs = %d, want %d", path, route.TimeoutMs, want)
+		}
+	}
+}
+
+func TestCompile_RouteDefaults(t *testing.T) {
+	cfg := &config.Config{
+		Clusters: []config.Cluster{{Name: "web", Endpoints: []config.ClusterEndpoint{{URL: "http://web:8080"}}}},
+		Defaults: &config.RouteDefaults{Timeout: "5s", Filters: []config.RouteFilter{{Chain: "tag"}}},
+		FilterChains: map[string][]config.RouteFilter{
+			"tag": {{Type: "header_set", Args: config.FilterArgs{"key": "x-gw", "value": "nexus"}}},
+		},
+		RoutesV2: []config.RouteV2{
+			{Name: "inherits", Match: config.RouteMatch{PathPrefix: "/a"}, Upstream: config.RouteUpstream{Cluster: "web"}},
+			{Name: "overrides", Match: config.RouteMatch{PathPrefix: "/b"}, Upstream: config.RouteUpstream{Cluster: "web", TimeoutMs: 100},
+				Filters: []config.RouteFilter{{Type: "strip_prefix", Args: config.FilterArgs{"prefix": "/b"}}}},
+		},
+	}
+	compiled, err := Compile(cfg, 1)
+	if err != nil {
+		t.Fatalf("compile error: %v", err)
+	}
+	route, ok := compiled.Router.Match(httptest.NewRequest("GET", "/a/x", nil))
+	if !ok || route.TimeoutMs != 5000 || strings.Join(route.FilterTypes, ",") != "header_set" {
+		t.Fatalf("defaults not inherited: %+v", route)
+	}
+	req := httptest.NewRequest("GET", "/a/x", nil)
+	for _, f := range route.Filters {
+		f.Apply(req)
+	}
+	if req.Header.Get("x-gw") != "nexus" {
+		t.Error("chain filter not applied")
+	}
+	route, ok = compiled.Router.Match(httptest.NewRequest("GET", "/b/x", nil))
+	if !ok || route.TimeoutMs != 100 || strings.Join(route.FilterTypes, ",") != "strip_prefix" {
+		t.Errorf("route settings not kept: %+v", route)
+	}
+}
+
 func TestCompile_GRPCRoute(t *testing.T) {
 	cfg := &config.Config{
 		Clusters: []config.Cluster{
@@ -263,6 +325,166 @@ func TestRouterIndex_HeaderMatch(t *testing.T) {
 	}
 }
 
+func TestRouterIndex_QueryMatch(t *testing.T) {
+	cfg := &config.Config{
+		Clusters: []config.Cluster{
+			{Name: "backend", Type: "http", Endpoints: []config.ClusterEndpoint{{URL: "http://backend:8080"}}},
+		},
+		RoutesV2: []config.RouteV2{
+			{
+				Name:     "beta",
+				Match:    config.RouteMatch{PathPrefix: "/api", Query: []config.QueryMatch{{Name: "version", Exact: "beta"}}},
+				Upstream: config.RouteUpstream{Cluster: "backend"},
+			},
+			{
+				Name:     "numbered",
+				Match:    config.RouteMatch{PathPrefix: "/api", Query: []config.QueryMatch{{Name: "version", Regex: "v[0-9]+"}}},
+				Upstream: config.RouteUpstream{Cluster: "backend"},
+			},
+			{
+				Name:     "debug",
+				Match:    config.RouteMatch{PathPrefix: "/api", Query: []config.QueryMatch{{Name: "debug"}}},
+				Upstream: config.RouteUpstream{Cluster: "backend"},
+			},
+			{
+				Name:     "default",
+				Match:    config.RouteMatch{PathPrefix: "/"},
+				Upstream: config.RouteUpstream{Cluster: "backend"},
+			},
+		},
+	}
+	compiled, err := Compile(cfg, 1)
+	if err != nil {
+		t.Fatalf("compile error: %v", err)
+	}
+
+	tests := map[string]string{
+		"/api/users?version=beta":         "beta",
+		"/api/users?version=v2":           "numbered",
+		"/api/users?version=v2x":          "default",
+		"/api/users?version=alpha&debug":  "debug",
+		"/api/users?version=a&version=v1": "numbered",
+		"/api/users":                      "default",
+	}
+	for target, want := range tests {
+		route, ok := compiled.Router.Match(httptest.NewRequest("GET", target, nil))
+		if !ok || route.Name != want {
+			t.Errorf("%s: got %v, want %s", target, route, want)
+		}
+	}
+}
+
+func TestRouterIndex_HeaderCookieMatch(t *testing.T) {
+	yes, no := true, false
+	backend := config.RouteUpstream{Cluster: "backend"}
+	cfg := &config.Config{
+		Clusters: []config.Cluster{
+			{Name: "backend", Type: "http", Endpoints: []config.ClusterEndpoint{{URL: "http://backend:8080"}}},
+		},
+		RoutesV2: []config.RouteV2{
+			{Name: "cohort", Match: config.RouteMatch{PathPrefix: "/app", Headers: []config.HeaderMatch{{Name: "X-Cohort", Regex: "beta-[0-9]+"}}}, Upstream: backend},
+			{Name: "login", Match: config.RouteMatch{PathPrefix: "/app", Cookies: []config.CookieMatch{{Name: "session", Present: &no}}}, Upstream: backend},
+			{Name: "canary", Match: config.RouteMatch{PathPrefix: "/app", Cookies: []config.CookieMatch{{Name: "canary", Exact: "1"}}}, Upstream: backend},
+			{Name: "non-mobile", Match: config.RouteMatch{PathPrefix: "/app", Headers: []config.HeaderMatch{
+				{Name: "X-Tenant", Present: &yes},
+				{Name: "User-Agent", Contains: "Mobile", Invert: true},
+			}}, Upstream: backend},
+			{Name: "default", Match: config.RouteMatch{PathPrefix: "/"}, Upstream: backend},
+		},
+	}
+	compiled, err := Compile(cfg, 1)
+	if err != nil {
+		t.Fatalf("compile error: %v", err)
+	}
+
+	tests := []struct {
+		headers map[string]string
+		want    string
+	}{
+		{map[string]string{"X-Cohort": "beta-7"}, "cohort"},
+		{map[string]string{"X-Cohort": "beta-7x"}, "login"},
+		{map[string]string{"Cookie": "session=abc; canary=1"}, "canary"},
+		{map[string]string{"Cookie": "session=abc; canary=2"}, "default"},
+		{map[string]string{"Cookie": "session=abc", "X-Tenant": "acme", "User-Agent": "Desktop"}, "non-mobile"},
+		{map[string]string{"Cookie": "session=abc", "X-Tenant": "acme", "User-Agent": "Mobile Safari"}, "default"},
+		{map[string]string{"Cookie": "session=abc", "X-Tenant": ""}, "non-mobile"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/app/home", nil)
+		for k, v := range tt.headers {
+			req.Header.Set(k, v)
+		}
+		route, ok := compiled.Router.Match(req)
+		if !ok || route.Name != tt.want {
+			t.Errorf("%v: got %v, want %s", tt.headers, route, tt.want)
+		}
+	}
+}
+
+func TestRouterIndex_GraphQLOperationMatch(t *testing.T) {
+	cfg := &config.Config{
+		Clusters: []config.Cluster{
+			{Name: "primary", Type: "graphql", Endpoints: []config.ClusterEndpoint{{URL: "http://primary:8080"}}},
+			{Name: "replicas", Type: "graphql", Endpoints: []config.ClusterEndpoint{{URL: "http://replicas:8080"}}},
+		},
+		RoutesV2: []config.RouteV2{
+			// Routes without a GraphQL match are tried last.
+			{Name: "queries", Match: config.RouteMatch{Path: "/graphql"}, Upstream: config.RouteUpstream{Cluster: "replicas"}},
+			{
+				Name:     "mutations",
+				Match:    config.RouteMatch{Path: "/graphql", GraphQL: &config.GraphQLMatch{OperationTypes: []string{"mutation", "subscription"}}},
+				Upstream: config.RouteUpstream{Cluster: "primary"},
+			},
+			{
+				Name:     "consistent-reads",
+				Match:    config.RouteMatch{PathPrefix: "/", GraphQL: &config.GraphQLMatch{OperationTypes: []string{"query"}, OperationNames: []string{"GetBalance"}}},
+				Upstream: config.RouteUpstream{Cluster: "primary"},
+			},
+			{Name: "fallback", Match: config.RouteMatch{PathPrefix: "/"}, Upstream: config.RouteUpstream{Cluster: "replicas"}},
+		},
+	}
+	persisted := graphql.NewPersistedQueryStore(0)
+	hash := persisted.Register("mutation Pay { pay }")
+	compiled, err := compile(cfg, 1, nil, persisted, false)
+	if err != nil {
+		t.Fatalf("compile error: %v", err)
+	}
+
+	for _, tt := range []struct {
+		path, body, want string
+	}{
+		{"/graphql", `{"query":"mutation { pay }"}`, "mutations"},
+		{"/graphql", `{"query":"query A { a } mutation B { b }","operationName":"B"}`, "mutations"},
+		{"/graphql", `{"query":"{ balance }"}`, "queries"},
+		{"/graphql", `{"extensions":{"persistedQuery":{"version":1,"sha256Hash":"` + hash + `"}}}`, "mutations"},
+		{"/graphql", `{"query":"mutation {"}`, "queries"},
+		{"/api/graphql", `{"query":"query GetBalance { balance }"}`, "consistent-reads"},
+		{"/api/graphql", `{"query":"query GetHistory { history }"}`, "fallback"},
+	} {
+		req := httptest.NewRequest("POST", tt.path, strings.NewReader(tt.body))
+		route, ok := compiled.Router.Match(req)
+		if !ok || route.Name != tt.want {
+			t.Errorf("%s %s: expected route %s, got %v", tt.path, tt.body, tt.want, route)
+			continue
+		}
+		// The body stays available to the upstream.
+		if body, _ := io.ReadAll(req.Body); string(body) != tt.body {
+			t.Errorf("%s: body not restored: %q", tt.body, body)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/graphql", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	if route, ok := compiled.Router.Match(req); !ok || route.Name != "mutations" {
+		t.Errorf("expected WebSocket upgrades to match subscriptions, got %v", route)
+	}
+	req = httptest.NewRequest("GET", "/graphql?query="+url.QueryEscape("{ balance }"), nil)
+	if route, ok := compiled.Router.Match(req); !ok || route.Name != "queries" {
+		t.Errorf("expected GET queries to match the queries route, got %v", route)
+	}
+}
+
 func TestRouterIndex_PrefixMatch(t *testing.T) {
 	cfg := &config.Config{
 		Clusters: []config.Cluster{
@@ -348,6 +570,139 @@ func TestRouterIndex_ExactOverPrefix(t *testing.T) {
 	}
 }
 
+func TestRouterIndex_PathPatterns(t *testing.T) {
+	cfg := &config.Config{
+		Clusters: []config.Cluster{
+			{Name: "backend", Type: "http", Endpoints: []config.ClusterEndpoint{{URL: "http://backend:8080"}}},
+		},
+		RoutesV2: []config.RouteV2{
+			{Name: "users", Match: config.RouteMatch{PathPrefix: "/users"}, Upstream: config.RouteUpstream{Cluster: "backend"}},
+			{Name: "order", Match: config.RouteMatch{Path: "/users/{id}/orders/{oid}"}, Upstream: config.RouteUpstream{Cluster: "backend"}},
+			{Name: "items", Match: config.RouteMatch{PathRegex: `/items/(?P<sku>[A-Z]+-[0-9]+)`}, Upstream: config.RouteUpstream{Cluster: "backend"}},
+			{Name: "me", Match: config.RouteMatch{Path: "/users/me/orders/latest"}, Upstream: config.RouteUpstream{Cluster: "backend"}},
+		},
+	}
+	compiled, err := Compile(cfg, 1)
+	if err != nil {
+		t.Fatalf("compile error: %v", err)
+	}
+
+	tests := []struct {
+		path   string
+		route  string
+		params map[string]string
+	}{
+		{"/users/42/orders/7", "order", map[string]string{"id": "42", "oid": "7"}},
+		{"/users/me/orders/latest", "me", nil},
+		{"/users/42/orders", "users", nil},
+		{"/items/AB-12", "items", map[string]string{"sku": "AB-12"}},
+		{"/items/ab-12", "", nil},
+	}
+	for _, tt := range tests {
+		route, params, ok := compiled.Router.MatchParams(httptest.NewRequest("GET", tt.path, nil))
+		if tt.route == "" {
+			if ok {
+				t.Errorf("%s: expected no match, got %s", tt.path, route.Name)
+			}
+			continue
+		}
+		if !ok || route.Name != tt.route || !reflect.DeepEqual(params, tt.params) {
+			t.Errorf("%s: got %v %v, want %s %v", tt.path, route, params, tt.route, tt.params)
+		}
+	}
+
+	var got []string
+	for _, e := range compiled.Router.Entries() {
+		got = append(got, e.Kind+" "+e.Path+" "+e.Route.Name)
+	}
+	want := []string{
+		"exact /users/me/orders/latest me",
+		"template /users/{id}/orders/{oid} order",
+		"regex /items/(?P<sku>[A-Z]+-[0-9]+) items",
+		"prefix /users users",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("unexpected entries:\n%s", strings.Join(got, "\n"))
+	}
+}
+
+func TestGateway_PathParams(t *testing.T) {
+	var gotPath, gotUser string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotUser = r.URL.Path, r.Header.Get("X-User-ID")
+	}))
+	defer backend.Close()
+
+	cfg := &config.Config{
+		Clusters: []config.Cluster{
+			{Name: "backend", Type: "http", Endpoints: []config.ClusterEndpoint{{URL: backend.URL}}},
+		},
+		RoutesV2: []config.RouteV2{{
+			Name:  "user",
+			Match: config.RouteMatch{Path: "/users/{id}"},
+			Filters: []config.RouteFilter{
+				{Type: "rewrite_path", Args: config.FilterArgs{"path": "/internal/users/{id}"}},
+				{Type: "header_set", Args: config.FilterArgs{"key": "X-User-ID", "value": "{id}"}},
+			},
+			Upstream: config.RouteUpstream{Cluster: "backend"},
+		}},
+	}
+	compiled, err := Compile(cfg, 1)
+	if err != nil {
+		t.Fatalf("compile error: %v", err)
+	}
+	store := NewConfigStore()
+	store.Store(compiled)
+
+	rec := httptest.NewRecorder()
+	NewGateway(store).ServeHTTP(rec, httptest.NewRequest("GET", "/users/42", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	if gotPath != "/internal/users/42" || gotUser != "42" {
+		t.Errorf("backend got path %q, X-User-ID %q", gotPath, gotUser)
+	}
+}
+
+func TestRouterIndex_Entries(t *testing.T) {
+	cfg := &config.Config{
+		Clusters: []config.Cluster{
+			{Name: "backend", Type: "http", Endpoints: []config.ClusterEndpoint{{URL: "http://backend:8080"}}},
+		},
+		RoutesV2: []config.RouteV2{
+			{Name: "api", Match: config.RouteMatch{PathPrefix: "/api"}, Upstream: config.RouteUpstream{Cluster: "backend"}},
+			{Name: "any-health", Match: config.RouteMatch{Path: "/health"}, Upstream: config.RouteUpstream{Cluster: "backend"}},
+			{Name: "get-health", Match: config.RouteMatch{Methods: []string{"GET"}, Path: "/health"}, Upstream: config.RouteUpstream{Cluster: "backend"}},
+			{Name: "api-v1", Match: config.RouteMatch{PathPrefix: "/api/v1"}, Upstream: config.RouteUpstream{Cluster: "backend"}},
+			{Name: "catch-all", Upstream: config.RouteUpstream{Cluster: "backend"}},
+		},
+	}
+	compiled, err := Compile(cfg, 1)
+	if err != nil {
+		t.Fatalf("compile error: %v", err)
+	}
+
+	var got []string
+	for _, e := range compiled.Router.Entries() {
+		got = append(got, e.Kind+" "+e.Method+" "+e.Path+" "+e.Route.Name)
+	}
+	want := []string{
+		"exact GET /health get-health",
+		"exact  /health any-health",
+		"prefix  /api/v1 api-v1",
+		"prefix  /api api",
+		"prefix  / catch-all",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("unexpected entries:\n%s", strings.Join(got, "\n"))
+	}
+
+	var nilIndex *RouterIndex
+	if entries := nilIndex.Entries(); entries != nil {
+		t.Errorf("expected no entries, got %v", entries)
+	}
+}
+
 func TestCompiledCluster_NextEndpoint(t *testing.T) {
 	cluster := &CompiledCluster{
 		Name: "test",
@@ -379,6 +734,63 @@ func TestCompiledCluster_NextEndpoint(t *testing.T) {
 			t.Errorf("iteration %d: expected %s, got %s", i, expected[i], addr)
 		}
 	}
+	if picks := cluster.Picks(); picks != 6 {
+		t.Errorf("expected 6 picks, got %d", picks)
+	}
+	cluster.NextEndpoint()
+	if ep, ok := cluster.PeekEndpoint(); !ok || EndpointAddress(ep) != "http://host2:8080" {
+		t.Errorf("expected to peek host2, got %v", ep)
+	}
+	if picks := cluster.Picks(); picks != 7 {
+		t.Errorf("peeking advanced the load balancer: %d picks", picks)
+	}
+}
+
+func TestCompile_ResolvesEndpointTargets(t *testing.T) {
+	cfg := &config.Config{
+		Clusters: []config.Cluster{
+			{Name: "web", Type: "http", Endpoints: []config.ClusterEndpoint{{URL: "https://web:8443/base"}, {Addr: "web-2:8080"}}},
+			{Name: "rpc", Type: "grpc", Endpoints: []config.ClusterEndpoint{{Target: "dns:///rpc:9090"}}},
+			{Name: "orders", Type: "dubbo", Dubbo: &config.ClusterDubbo{Serialization: "hessian2"}, Endpoints: []config.ClusterEndpoint{{Addr: "dubbo://orders:20880"}}},
+			{Name: "users", Type: "dubbo", Dubbo: &config.ClusterDubbo{Protocol: "triple"}, Endpoints: []config.ClusterEndpoint{{Addr: "tri://users:50051"}}},
+		},
+	}
+	compiled, err := Compile(cfg, 1)
+	if err != nil {
+		t.Fatalf("compile error: %v", err)
+	}
+	for _, tt := range []struct{ cluster, want string }{
+		{"web", "https://web:8443/base"},
+		{"web", "http://web-2:8080"},
+		{"rpc", "http://rpc:9090"},
+		{"orders", "dubbo://orders:20880"},
+		{"users", "http://users:50051"},
+	} {
+		cluster := compiled.Clusters[tt.cluster]
+		target, _, err := cluster.NextTarget()
+		if err != nil || target.String() != tt.want {
+			t.Errorf("%s: expected %s, got %v, %v", tt.cluster, tt.want, target, err)
+		}
+	}
+	// Requests share the URLs resolved at compile time.
+	web := compiled.Clusters["web"]
+	if a, _, _ := web.NextTarget(); a != web.targets[0] {
+		t.Error("target resolved again")
+	}
+
+	cfg.Clusters[1].Endpoints[0].Target = "http://[::1"
+	if _, err := Compile(cfg, 2); err == nil || !strings.Contains(err.Error(), `cluster "rpc" endpoint[0]: invalid grpc target`) {
+		t.Errorf("expected an invalid endpoint error, got %v", err)
+	}
+
+	// Clusters not built by Compile resolve their targets on each call.
+	bare := &CompiledCluster{Name: "bare", Type: "http", Endpoints: []config.ClusterEndpoint{{Addr: "bare:8080"}}}
+	if target, addr, err := bare.NextTarget(); err != nil || target.String() != "http://bare:8080" || addr != "bare:8080" {
+		t.Errorf("bare cluster: %v %q %v", target, addr, err)
+	}
+	if _, _, err := (&CompiledCluster{Name: "empty"}).NextTarget(); err == nil {
+		t.Error("expected an error for a cluster without endpoints")
+	}
 }
 
 func TestEndpointAddress(t *testing.T) {
@@ -461,6 +873,65 @@ func TestCompileAndStore(t *testing.T) {
 	}
 }
 
+func TestCheck_LeavesRuntimeAlone(t *testing.T) {
+	candidate := func(maxIdle, maxEntries int) *config.Config {
+		return &config.Config{
+			ResponseCache: &config.ResponseCache{MaxEntries: maxEntries},
+			Clusters: []config.Cluster{
+				{Name: "check-web", Type: "http", Endpoints: []config.ClusterEndpoint{{URL: "http://test:8080"}}, Keepalive: &config.KeepaliveConfig{MaxIdleConns: maxIdle}},
+				// Nothing listens here; a dry run must not ask.
+				{Name: "check-grpc", Type: "grpc", Endpoints: []config.ClusterEndpoint{{URL: "http://127.0.0.1:1"}}, GRPC: &config.ClusterGRPC{Reflection: true, ReflectionTimeoutMs: 100}},
+			},
+			RoutesV2: []config.RouteV2{{
+				Name:        "check-route",
+				Match:       config.RouteMatch{PathPrefix: "/"},
+				Upstream:    config.RouteUpstream{Cluster: "check-web"},
+				Idempotency: &config.Idempotency{MaxEntries: maxEntries},
+				Cache:       &config.RouteCache{TTL: "1m"},
+			}},
+		}
+	}
+
+	served := candidate(4, 10)
+	served.Clusters = served.Clusters[:1]
+	store := NewConfigStore()
+	if _, err := CompileAndStore(served, store); err != nil {
+		t.Fatal(err)
+	}
+	transport := httpTransports.forCluster(store.Load().Clusters["check-web"])
+	idempotencyCaches.Lock()
+	idempotency := idempotencyCaches.routes["check-route"]
+	idempotencyCaches.Unlock()
+	responseCache.Lock()
+	cacheStore := responseCache.store
+	responseCache.Unlock()
+
+	if err := Check(candidate(8, 20), store); err != nil {
+		t.Fatalf("check: %v", err)
+	}
+	if httpTransports.forCluster(store.Load().Clusters["check-web"]) != transport {
+		t.Error("check replaced the cluster transport")
+	}
+	grpcTransports.mu.Lock()
+	_, ok := grpcTransports.transports["check-grpc"]
+	grpcTransports.mu.Unlock()
+	if ok {
+		t.Error("check added a gRPC transport")
+	}
+	idempotencyCaches.Lock()
+	replaced := idempotencyCaches.routes["check-route"] != idempotency
+	idempotencyCaches.Unlock()
+	if replaced {
+		t.Error("check replaced the idempotency cache")
+	}
+	responseCache.Lock()
+	replaced = responseCache.store != cacheStore
+	responseCache.Unlock()
+	if replaced {
+		t.Error("check replaced the response cache store")
+	}
+}
+
 func TestCompile_InvalidFilterType(t *testing.T) {
 	cfg := &config.Config{
 		Clusters: []config.Cluster{
@@ -576,6 +1047,90 @@ func TestGateway_NoMatch(t *testing.T) {
 	}
 }
 
+func TestGateway_MethodNotAllowed(t *testing.T) {
+	cfg := &config.Config{
+		Clusters: []config.Cluster{
+			{Name: "test", Type: "http", Endpoints: []config.ClusterEndpoint{{URL: "http://test:8080"}}},
+		},
+		RoutesV2: []config.RouteV2{
+			{
+				Name:     "list-users",
+				Match:    config.RouteMatch{Methods: []string{"GET"}, Path: "/users"},
+				Upstream: config.RouteUpstream{Cluster: "test"},
+			},
+			{
+				Name:     "create-user",
+				Match:    config.RouteMatch{Methods: []string{"POST"}, Path: "/users"},
+				Upstream: config.RouteUpstream{Cluster: "test"},
+			},
+		},
+	}
+
+	store := NewConfigStore()
+	if _, err := CompileAndStore(cfg, store); err != nil {
+		t.Fatalf("compile error: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	NewGateway(store).ServeHTTP(w, httptest.NewRequest("DELETE", "/users", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405, got %d", w.Code)
+	}
+	if got := w.Header().Get("Allow"); got != "GET, POST" {
+		t.Errorf("expected Allow: GET, POST, got %q", got)
+	}
+}
+
+func TestGateway_Fallback(t *testing.T) {
+	var gotPath, gotHeader string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotHeader = r.URL.Path, r.Header.Get("X-Gateway")
+	}))
+	defer backend.Close()
+
+	cfg := &config.Config{
+		Server: config.ServerConfig{Fallback: &config.FallbackConfig{Cluster: "default"}},
+		Defaults: &config.RouteDefaults{Filters: []config.RouteFilter{
+			{Type: "header_set", Args: config.FilterArgs{"key": "X-Gateway", "value": "nexus"}},
+		}},
+		Clusters: []config.Cluster{
+			{Name: "default", Type: "http", Endpoints: []config.ClusterEndpoint{{URL: backend.URL}}},
+		},
+		RoutesV2: []config.RouteV2{{
+			Name:     "users",
+			Match:    config.RouteMatch{Methods: []string{"GET"}, Path: "/users"},
+			Upstream: config.RouteUpstream{Cluster: "default"},
+		}},
+	}
+	store := NewConfigStore()
+	if _, err := CompileAndStore(cfg, store); err != nil {
+		t.Fatalf("compile error: %v", err)
+	}
+	gw := NewGateway(store)
+
+	w := httptest.NewRecorder()
+	gw.ServeHTTP(w, httptest.NewRequest("GET", "/legacy/page", nil))
+	if w.Code != http.StatusOK || gotPath != "/legacy/page" || gotHeader != "nexus" {
+		t.Errorf("fallback: status %d, backend got path %q, X-Gateway %q", w.Code, gotPath, gotHeader)
+	}
+	// A method mismatch is still answered with 405.
+	w = httptest.NewRecorder()
+	gw.ServeHTTP(w, httptest.NewRequest("POST", "/users", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405, got %d", w.Code)
+	}
+
+	cfg.Server.Fallback = &config.FallbackConfig{Status: http.StatusGone, Body: `{"code":"gone"}`}
+	if _, err := CompileAndStore(cfg, store); err != nil {
+		t.Fatalf("compile error: %v", err)
+	}
+	w = httptest.NewRecorder()
+	gw.ServeHTTP(w, httptest.NewRequest("GET", "/legacy/page", nil))
+	if w.Code != http.StatusGone || w.Header().Get("Content-Type") != "application/json" || w.Body.String() != `{"code":"gone"}` {
+		t.Errorf("custom 404: got %d %q %s", w.Code, w.Header().Get("Content-Type"), w.Body)
+	}
+}
+
 func TestGateway_ClusterNotFound(t *testing.T) {
 	cfg := &config.Config{
 		Clusters: []config.Cluster{
@@ -2,16 +2,37 @@ package runtime
 
 import (
 	"fmt"
-	"sort"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
 	"sync/atomic"
+	"time"
 
 	"github.com/oriys/nexus/internal/config"
+	"github.com/oriys/nexus/internal/graphql"
+	"github.com/oriys/nexus/internal/transcode"
 )
 
 // Compile compiles a Config into a CompiledConfig for fast request-time lookups.
 func Compile(cfg *config.Config, version uint64) (*CompiledConfig, error) {
+	return compile(cfg, version, nil, graphql.NewPersistedQueryStore(0), false)
+}
+
+// compile builds the runtime of cfg. A dry run leaves the process-wide
+// transports, caches and counters alone and does not query gRPC reflection,
+// so the result only tells whether cfg compiles and must not be served.
+func compile(cfg *config.Config, version uint64, uploaded *transcode.DescriptorStore, persisted *graphql.PersistedQueryStore, dry bool) (*CompiledConfig, error) {
 	fr := NewFilterRegistry()
 
+	protos, err := transcode.LoadRegistry(cfg.ProtoDescriptors)
+	if err != nil {
+		return nil, err
+	}
+	if err := uploaded.AddTo(protos); err != nil {
+		return nil, err
+	}
+
 	// Compile clusters
 	clusters := make(map[string]*CompiledCluster, len(cfg.Clusters))
 	for _, c := range cfg.Clusters {
@@ -31,12 +52,36 @@ func Compile(cfg *config.Config, version uint64) (*CompiledConfig, error) {
 		if cc.Type == "" {
 			cc.Type = "http"
 		}
+		cc.targets = make([]*url.URL, len(cc.Endpoints))
+		for i, ep := range cc.Endpoints {
+			if cc.targets[i], err = cc.resolveTarget(EndpointAddress(ep)); err != nil {
+				return nil, fmt.Errorf("cluster %q endpoint[%d]: %w", c.Name, i, err)
+			}
+		}
+		// Transports and pools are shared with the served runtime, so a dry
+		// run leaves them alone.
+		if !dry {
+			if cc.Type == "grpc" || tripleDubbo(cc) {
+				cc.transport = grpcTransports.forCluster(cc)
+			} else if !nativeDubbo(cc) {
+				cc.proxyTransport = httpTransports.forCluster(cc)
+			} else {
+				cc.dubboPool = dubboPools.forCluster(cc)
+			}
+		}
+		if cc.Type == "grpc" && cc.GRPC != nil && cc.GRPC.Reflection && !dry {
+			if err := discoverCluster(cc, protos); err != nil {
+				return nil, fmt.Errorf("cluster %q: %w", c.Name, err)
+			}
+		}
+		if c.Dubbo != nil && c.Dubbo.Registry != nil {
+			cc.DubboRegistry = newDubboRegistry(c.Dubbo.Registry)
+		}
 		clusters[c.Name] = cc
 	}
 
 	// Compile routes
-	exactRoutes := make(map[string]*CompiledRoute)
-	var prefixRoutes []*prefixRouteEntry
+	router := newRouterIndex(cfg.PathNormalization)
 
 	for _, rv2 := range cfg.RoutesV2 {
 		// Compile match
@@ -44,6 +89,22 @@ func Compile(cfg *config.Config, version uint64) (*CompiledConfig, error) {
 			Path:       rv2.Match.Path,
 			PathPrefix: rv2.Match.PathPrefix,
 		}
+		pattern, err := rv2.Match.Pattern()
+		if err != nil {
+			return nil, fmt.Errorf("route %q: %w", rv2.Name, err)
+		}
+		cm.norm = newPathNorm(cfg.RouteNormalization(&rv2))
+		if pattern != nil {
+			if cm.norm.fold {
+				pattern = pattern.IgnoreCase()
+			}
+			cm.Pattern = pattern
+			cm.Path, cm.PathPrefix = "", ""
+		}
+		if cm.Path != "" {
+			cm.Path = cm.norm.exact(cm.Path)
+		}
+		cm.PathPrefix = cm.norm.prefix(cm.PathPrefix)
 
 		if len(rv2.Match.Methods) > 0 {
 			cm.Methods = make(map[string]struct{}, len(rv2.Match.Methods))
@@ -53,100 +114,425 @@ func Compile(cfg *config.Config, version uint64) (*CompiledConfig, error) {
 		}
 
 		for _, h := range rv2.Match.Headers {
-			cm.Headers = append(cm.Headers, CompiledHeaderMatch{
+			ch := CompiledHeaderMatch{
 				Name:     h.Name,
 				Exact:    h.Exact,
 				Contains: h.Contains,
-			})
+				Invert:   h.Invert,
+			}
+			if h.Present != nil {
+				ch.Present, ch.Absent = *h.Present, !*h.Present
+			}
+			if h.Regex != "" {
+				re, err := regexp.Compile("^(?:" + h.Regex + ")$")
+				if err != nil {
+					return nil, fmt.Errorf("route %q: header %q: %w", rv2.Name, h.Name, err)
+				}
+				ch.Regex = re
+			}
+			cm.Headers = append(cm.Headers, ch)
 		}
 
-		// Compile filters
-		var filters []Filter
-		for _, rf := range rv2.Filters {
-			f, err := fr.Compile(rf)
-			if err != nil {
-				return nil, fmt.Errorf("route %q filter %q: %w", rv2.Name, rf.Type, err)
+		for _, c := range rv2.Match.Cookies {
+			cc := CompiledCookieMatch{
+				Name:   c.Name,
+				Exact:  c.Exact,
+				Absent: c.Present != nil && !*c.Present,
+				Invert: c.Invert,
 			}
-			filters = append(filters, f)
+			if c.Regex != "" {
+				re, err := regexp.Compile("^(?:" + c.Regex + ")$")
+				if err != nil {
+					return nil, fmt.Errorf("route %q: cookie %q: %w", rv2.Name, c.Name, err)
+				}
+				cc.Regex = re
+			}
+			cm.Cookies = append(cm.Cookies, cc)
+		}
+
+		for _, q := range rv2.Match.Query {
+			cq := CompiledQueryMatch{Name: q.Name, Exact: q.Exact}
+			if q.Regex != "" {
+				re, err := regexp.Compile("^(?:" + q.Regex + ")$")
+				if err != nil {
+					return nil, fmt.Errorf("route %q: query %q: %w", rv2.Name, q.Name, err)
+				}
+				cq.Regex = re
+			}
+			cm.Query = append(cm.Query, cq)
+		}
+
+		if rv2.Schedule != nil {
+			if cm.Schedule, err = compileSchedule(rv2.Schedule); err != nil {
+				return nil, fmt.Errorf("route %q: schedule: %w", rv2.Name, err)
+			}
+		}
+
+		if g := rv2.Match.GraphQL; g != nil {
+			cm.GraphQL = &CompiledGraphQLMatch{Persisted: persisted}
+			if len(g.OperationTypes) > 0 {
+				cm.GraphQL.Types = make(map[string]struct{}, len(g.OperationTypes))
+				for _, t := range g.OperationTypes {
+					cm.GraphQL.Types[t] = struct{}{}
+				}
+			}
+			if len(g.OperationNames) > 0 {
+				cm.GraphQL.Names = make(map[string]struct{}, len(g.OperationNames))
+				for _, n := range g.OperationNames {
+					cm.GraphQL.Names[n] = struct{}{}
+				}
+			}
+			if cc := clusters[rv2.Upstream.ActiveCluster()]; cc != nil && cc.GraphQL != nil {
+				cm.GraphQL.MaxBodyBytes = cc.GraphQL.MaxBodyLimit()
+			}
+		}
+
+		if t := rv2.Match.TLS; t != nil {
+			cm.TLS = &CompiledTLSMatch{}
+			for _, name := range t.SNI {
+				cm.TLS.SNI = append(cm.TLS.SNI, strings.ToLower(name))
+			}
+			if c := t.ClientCert; c != nil {
+				cm.TLS.ClientCert = true
+				cm.TLS.CommonNames = stringSet(c.CommonNames)
+				cm.TLS.OrganizationalUnits = stringSet(c.OrganizationalUnits)
+				cm.TLS.SANs = stringSet(c.SANs)
+			}
+		}
+
+		// Compile filters
+		filters, filterTypes, err := compileFilters(fr, cfg, &rv2)
+		if err != nil {
+			return nil, err
 		}
 
 		cr := &CompiledRoute{
-			Name:    rv2.Name,
-			Match:   cm,
-			Filters: filters,
+			Name:        rv2.Name,
+			Match:       cm,
+			Priority:    rv2.Priority,
+			Filters:     filters,
+			FilterTypes: filterTypes,
 			Upstream: RouteUpstreamConfig{
-				ClusterName: rv2.Upstream.Cluster,
+				ClusterName: rv2.Upstream.ActiveCluster(),
 				GRPC:        rv2.Upstream.GRPC,
 				Dubbo:       rv2.Upstream.Dubbo,
 				GraphQL:     rv2.Upstream.GraphQL,
 			},
-			TimeoutMs: rv2.Upstream.TimeoutMs,
+			// Rounded up, so a timeout below a millisecond is kept.
+			TimeoutMs: int((cfg.RouteTimeout(&rv2) + time.Millisecond - 1) / time.Millisecond),
+		}
+		cr.ResponseFilters = responseFilters(filters)
+		cr.Maintenance = NewMaintenancePage(rv2.Maintenance)
+		cr.Tier = rv2.Tier
+		if !dry {
+			cr.stats = routeCountersFor(rv2.Name)
+		}
+		if rv2.Idempotency != nil {
+			if dry {
+				cr.Idempotency = newIdempotencyCache(rv2.Idempotency)
+			} else {
+				cr.Idempotency = idempotencyCacheFor(rv2.Name, rv2.Idempotency)
+			}
+		}
+		if rv2.Cache != nil {
+			var store cacheStore
+			if !dry {
+				store = responseStoreFor(cfg.ResponseCache)
+			}
+			if cr.Cache, err = newRouteCache(rv2.Name, rv2.Cache, cfg.ResponseCache, store); err != nil {
+				return nil, fmt.Errorf("route_v2 %q: %w", rv2.Name, err)
+			}
+		}
+		if len(rv2.Upstream.Clusters) > 0 {
+			cr.Upstream.Split = newClusterSplit(&rv2.Upstream)
+		}
+		if rv2.Upstream.BlueGreen != nil && !dry {
+			cr.Upstream.Outcomes = Outcomes(rv2.Name, cr.Upstream.ClusterName)
+		}
+		if m := rv2.Upstream.Mirror; m != nil {
+			if cc := clusters[m.Cluster]; cc != nil && cc.Type != "" && cc.Type != "http" {
+				return nil, fmt.Errorf("route %q: mirror cluster %q is not an HTTP cluster", rv2.Name, m.Cluster)
+			}
+			cr.Upstream.Mirror = newRouteMirror(m, cfg.RouteTimeout(&rv2))
+		}
+		if rv2.Upstream.Mock != nil {
+			if cr.Mock, err = compileMock(rv2.Upstream.Mock); err != nil {
+				return nil, fmt.Errorf("route %q: %w", rv2.Name, err)
+			}
 		}
 
-		// Index the route
-		if cm.Path != "" {
-			// Exact path routes go into the exact map
-			if cm.Methods != nil {
-				for m := range cm.Methods {
-					key := m + "|" + cm.Path
-					exactRoutes[key] = cr
+		if rv2.Upstream.GRPC != nil {
+			// Routes on reflection-enabled clusters must name a method the
+			// backend actually serves. A dry run has not asked the backend,
+			// so it cannot check them.
+			cc := clusters[rv2.Upstream.ActiveCluster()]
+			reflected := cc != nil && cc.GRPC != nil && cc.GRPC.Reflection
+			if reflected && !dry && !rv2.Upstream.GRPC.Passthrough {
+				if _, err := protos.FindMethod(rv2.Upstream.GRPC.Service, rv2.Upstream.GRPC.Method); err != nil {
+					return nil, fmt.Errorf("route %q: %w", rv2.Name, err)
+				}
+			}
+			if !reflected || !dry {
+				tc, err := compileGRPCTranscode(rv2.Upstream.GRPC, protos)
+				if err != nil {
+					return nil, fmt.Errorf("route %q: %w", rv2.Name, err)
+				}
+				cr.GRPCTranscode = tc
+			}
+			if g := rv2.Upstream.GRPC; g.Passthrough && len(g.Allow) > 0 {
+				cr.GRPCAllow = newGRPCAllowlist(g.Allow)
+			}
+			if md := rv2.Upstream.GRPC.Metadata; md != nil {
+				cr.GRPCMetadata = make(map[string]struct{}, len(md.Allow))
+				for _, name := range md.Allow {
+					cr.GRPCMetadata[http.CanonicalHeaderKey(name)] = struct{}{}
 				}
-			} else {
-				key := "|" + cm.Path
-				exactRoutes[key] = cr
 			}
 		}
 
-		if cm.PathPrefix != "" {
-			// Prefix routes go into the prefix list
-			prefixRoutes = append(prefixRoutes, &prefixRouteEntry{
-				prefix: cm.PathPrefix,
-				route:  cr,
-			})
+		if d := rv2.Upstream.Dubbo; d != nil {
+			if cc := clusters[rv2.Upstream.ActiveCluster()]; d.Generic && !genericDubbo(cc) {
+				return nil, fmt.Errorf("route %q: generic invocation requires a dubbo cluster using hessian2 serialization", rv2.Name)
+			}
+			if cc := clusters[rv2.Upstream.ActiveCluster()]; cc != nil && tripleDubbo(cc) && cc.Dubbo.Serialization == "protobuf" {
+				tc, err := compileTripleTranscode(d, protos)
+				if err != nil {
+					return nil, fmt.Errorf("route %q: %w", rv2.Name, err)
+				}
+				cr.TripleTranscode = tc
+			}
+			params, err := compileDubboParams(d)
+			if err != nil {
+				return nil, fmt.Errorf("route %q: %w", rv2.Name, err)
+			}
+			if params != nil && cr.TripleTranscode != nil {
+				return nil, fmt.Errorf("route %q: params cannot be used with protobuf serialization", rv2.Name)
+			}
+			cr.DubboParams = params
 		}
 
-		// If neither path nor prefix is set, this is a catch-all (treated as "/" prefix)
-		if cm.Path == "" && cm.PathPrefix == "" {
-			prefixRoutes = append(prefixRoutes, &prefixRouteEntry{
-				prefix: "/",
-				route:  cr,
-			})
+		if g := rv2.Upstream.GraphQL; g != nil {
+			if g.Allowlist != nil {
+				cr.GraphQLAllow = newGraphQLAllowlist(g.Allowlist)
+			}
+			if g.PersistedQueries != nil {
+				cr.PersistedQueries = persisted
+			}
+			if g.Cache != nil {
+				cr.GraphQLCache = newGraphQLCache(g.Cache)
+			}
+			if g.Federation != nil {
+				fed, err := compileGraphQLFederation(g.Federation, clusters)
+				if err != nil {
+					return nil, fmt.Errorf("route %q: %w", rv2.Name, err)
+				}
+				cr.GraphQLFederation = fed
+			}
+			if g.Subscriptions != nil {
+				subs, err := compileGraphQLSubscriptions(rv2.Name, g.Subscriptions, cfg.Auth, dry)
+				if err != nil {
+					return nil, fmt.Errorf("route %q: %w", rv2.Name, err)
+				}
+				cr.GraphQLSubscriptions = subs
+			}
+		}
+
+		router.add(cr, &rv2)
+	}
+	router.sort()
+
+	compiled := &CompiledConfig{
+		Listeners:   cfg.Listeners,
+		Router:      router,
+		Clusters:    clusters,
+		Filters:     fr,
+		Protos:      protos,
+		Version:     version,
+		LoadShedder: NewLoadShedder(cfg.LoadShedding),
+	}
+	if t := cfg.Tenancy; t != nil {
+		for _, tn := range t.Tenants {
+			if len(tn.Clusters) == 0 {
+				continue
+			}
+			if compiled.TenantClusters == nil {
+				compiled.TenantClusters = make(map[string]map[string]string)
+			}
+			compiled.TenantClusters[tn.Name] = tn.Clusters
+		}
+	}
+	if fb := cfg.Server.Fallback; fb != nil {
+		if fb.Cluster == "" {
+			compiled.NotFoundStatus, compiled.NotFoundBody = fb.Response()
+		} else {
+			// The fallback is forwarded as a route matching any request
+			rv2 := config.RouteV2{Name: "fallback", Upstream: config.RouteUpstream{Cluster: fb.Cluster}}
+			filters, filterTypes, err := compileFilters(fr, cfg, &rv2)
+			if err != nil {
+				return nil, err
+			}
+			compiled.Fallback = &CompiledRoute{
+				Name:        rv2.Name,
+				Filters:     filters,
+				FilterTypes: filterTypes,
+				Upstream:    RouteUpstreamConfig{ClusterName: fb.Cluster},
+				TimeoutMs:   int((cfg.RouteTimeout(&rv2) + time.Millisecond - 1) / time.Millisecond),
+			}
+			compiled.Fallback.ResponseFilters = responseFilters(filters)
 		}
 	}
+	return compiled, nil
+}
 
-	// Sort prefix routes by length descending (longest match first).
-	// Use lexicographic ordering as tiebreaker for deterministic matching.
-	sort.Slice(prefixRoutes, func(i, j int) bool {
-		if len(prefixRoutes[i].prefix) != len(prefixRoutes[j].prefix) {
-			return len(prefixRoutes[i].prefix) > len(prefixRoutes[j].prefix)
+// compileFilters compiles the filters route rv2 applies.
+func compileFilters(fr *FilterRegistry, cfg *config.Config, rv2 *config.RouteV2) ([]Filter, []string, error) {
+	var filters []Filter
+	var filterTypes []string
+	for _, rf := range cfg.RouteFilters(rv2) {
+		f, err := fr.Compile(rf)
+		if err != nil {
+			return nil, nil, fmt.Errorf("route %q filter %q: %w", rv2.Name, rf.Type, err)
 		}
-		return prefixRoutes[i].prefix < prefixRoutes[j].prefix
-	})
+		if cf, ok := f.(*compressFilter); ok {
+			cf.route = rv2.Name
+		}
+		filters = append(filters, f)
+		filterTypes = append(filterTypes, rf.Type)
+	}
+	return filters, filterTypes, nil
+}
+
+// compileGRPCTranscode resolves the request/response codecs for a gRPC route.
+// It returns nil when neither direction transcodes.
+func compileGRPCTranscode(g *config.RouteUpstreamGRPC, protos *transcode.Registry) (*GRPCTranscode, error) {
+	reqMode := transcodeModeOf(g.Request)
+	respMode := transcodeModeOf(g.Response)
+	if reqMode != "json_to_proto" && respMode != "proto_to_json" {
+		return nil, nil
+	}
+	if protos.Len() == 0 {
+		return nil, fmt.Errorf("grpc transcoding requires proto_descriptors")
+	}
 
-	router := &RouterIndex{
-		exactRoutes:  exactRoutes,
-		prefixRoutes: prefixRoutes,
+	method, err := protos.FindMethod(g.Service, g.Method)
+	if err != nil {
+		return nil, err
 	}
 
-	return &CompiledConfig{
-		Listeners: cfg.Listeners,
-		Router:    router,
-		Clusters:  clusters,
-		Filters:   fr,
-		Version:   version,
-	}, nil
+	tc := &GRPCTranscode{
+		ClientStreaming: method.IsStreamingClient(),
+		ServerStreaming: method.IsStreamingServer(),
+	}
+	if reqMode == "json_to_proto" {
+		md := method.Input()
+		if name := g.Request.Proto; name != "" {
+			if md, err = protos.FindMessage(name); err != nil {
+				return nil, err
+			}
+			if md.FullName() != method.Input().FullName() {
+				return nil, fmt.Errorf("request proto %q does not match %s input type %s",
+					name, method.FullName(), method.Input().FullName())
+			}
+		}
+		tc.Request = protos.Codec(md)
+	}
+	if respMode == "proto_to_json" {
+		md := method.Output()
+		if name := g.Response.Proto; name != "" {
+			if md, err = protos.FindMessage(name); err != nil {
+				return nil, err
+			}
+			if md.FullName() != method.Output().FullName() {
+				return nil, fmt.Errorf("response proto %q does not match %s output type %s",
+					name, method.FullName(), method.Output().FullName())
+			}
+		}
+		tc.Response = protos.Codec(md)
+	}
+	return tc, nil
+}
+
+func transcodeModeOf(tm *config.TranscodeMode) string {
+	if tm == nil {
+		return ""
+	}
+	return tm.Mode
 }
 
 // versionCounter is used to generate unique version numbers for compiled configs.
 var versionCounter atomic.Uint64
 
-// CompileAndStore compiles the config and stores it atomically.
+// CompileAndStore compiles the config, together with the descriptor sets
+// uploaded to the store, and stores it atomically.
 func CompileAndStore(cfg *config.Config, store *ConfigStore) (*CompiledConfig, error) {
+	compiled, err := Prepare(cfg, store)
+	if err != nil {
+		return nil, err
+	}
+	Activate(compiled, store)
+	return compiled, nil
+}
+
+// Prepare compiles cfg like CompileAndStore but leaves the store unchanged,
+// so that callers can finish other work before serving it with Activate.
+func Prepare(cfg *config.Config, store *ConfigStore) (*CompiledConfig, error) {
+	return compile(cfg, versionCounter.Add(1), store.Descriptors(), store.PersistedQueries(), false)
+}
+
+// Activate stores a configuration compiled by Prepare and releases the
+// connections no cluster uses any more.
+func Activate(compiled *CompiledConfig, store *ConfigStore) {
+	store.Store(compiled)
+	grpcTransports.retain(compiled.Clusters)
+	httpTransports.retain(compiled.Clusters)
+	dubboPools.retain(compiled.Clusters)
+}
+
+// Check compiles cfg like CompileAndStore without storing the result, to
+// validate a configuration before it is applied. It is a dry run: the
+// served runtime is left untouched and gRPC reflection is not queried. With
+// a nil store cfg is compiled without uploaded descriptor sets.
+func Check(cfg *config.Config, store *ConfigStore) error {
+	if store == nil {
+		_, err := compile(cfg, 0, nil, graphql.NewPersistedQueryStore(0), true)
+		return err
+	}
+	_, err := compile(cfg, 0, store.Descriptors(), store.PersistedQueries(), true)
+	return err
+}
+
+// ApplyDescriptors replaces the uploaded descriptor sets. When cfg is non-nil
+// it is recompiled against the new sets first; if compilation fails the store
+// is left unchanged and the error is returned.
+func ApplyDescriptors(cfg *config.Config, store *ConfigStore, ds *transcode.DescriptorStore) (*CompiledConfig, error) {
+	if cfg == nil {
+		if err := ds.AddTo(transcode.NewRegistry()); err != nil {
+			return nil, err
+		}
+		store.descriptors.Store(ds)
+		return nil, nil
+	}
 	version := versionCounter.Add(1)
-	compiled, err := Compile(cfg, version)
+	compiled, err := compile(cfg, version, ds, store.PersistedQueries(), false)
 	if err != nil {
 		return nil, err
 	}
+	store.descriptors.Store(ds)
 	store.Store(compiled)
+	grpcTransports.retain(compiled.Clusters)
+	httpTransports.retain(compiled.Clusters)
+	dubboPools.retain(compiled.Clusters)
 	return compiled, nil
 }
+
+// stringSet returns the set of values, or nil when there are none.
+func stringSet(values []string) map[string]struct{} {
+	if len(values) == 0 {
+		return nil
+	}
+	set := make(map[string]struct{}, len(values))
+	for _, v := range values {
+		set[v] = struct{}{}
+	}
+	return set
+}
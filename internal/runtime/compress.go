@@ -0,0 +1,308 @@
+package runtime
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/oriys/nexus/internal/config"
+	"github.com/oriys/nexus/internal/promtext"
+)
+
+// defaultCompressMinSize is the smallest response body the compress filter
+// compresses when it sets no min_size.
+const defaultCompressMinSize = 1 << 10
+
+// defaultCompressTypes are the media types the compress filter compresses
+// when it sets no types. Types ending in "/" match by prefix, and any
+// +json or +xml type matches as well.
+var defaultCompressTypes = []string{
+	"text/html", "text/plain", "text/css", "text/csv", "text/xml", "text/javascript",
+	"application/json", "application/javascript", "application/xml", "image/svg+xml",
+}
+
+// compressFilter compresses upstream responses with gzip or deflate, picking
+// the first of its encodings the client accepts. Responses that are already
+// encoded, partial, smaller than the minimum size or of another media type
+// are forwarded as they are.
+type compressFilter struct {
+	encodings []string
+	types     []string
+	minSize   int64
+	level     int
+	// route names the route in the compression metrics.
+	route string
+}
+
+func newCompressFilter(args config.FilterArgs) (Filter, error) {
+	if err := args.Check("encodings", "types", "min_size", "level"); err != nil {
+		return nil, fmt.Errorf("compress filter: %w", err)
+	}
+	f := &compressFilter{minSize: defaultCompressMinSize}
+	var err error
+	if f.encodings, err = lowerList(args, "encodings"); err != nil {
+		return nil, fmt.Errorf("compress filter: %w", err)
+	}
+	if len(f.encodings) == 0 {
+		f.encodings = []string{"gzip", "deflate"}
+	}
+	for _, enc := range f.encodings {
+		if enc != "gzip" && enc != "deflate" {
+			return nil, fmt.Errorf("compress filter: unsupported encoding %q", enc)
+		}
+	}
+	if f.types, err = lowerList(args, "types"); err != nil {
+		return nil, fmt.Errorf("compress filter: %w", err)
+	}
+	if len(f.types) == 0 {
+		f.types = defaultCompressTypes
+	}
+	if args.Has("min_size") {
+		if f.minSize, err = args.Size("min_size"); err != nil {
+			return nil, fmt.Errorf("compress filter: %w", err)
+		}
+	}
+	f.level, err = args.Int("level", gzip.DefaultCompression)
+	if err != nil || args.Has("level") && (f.level < gzip.BestSpeed || f.level > gzip.BestCompression) {
+		return nil, fmt.Errorf("compress filter: 'level' must be between 1 and 9")
+	}
+	return f, nil
+}
+
+// lowerList returns the list argument name of a filter, lower-cased.
+func lowerList(args config.FilterArgs, name string) ([]string, error) {
+	list, err := args.Strings(name)
+	for i, item := range list {
+		list[i] = strings.ToLower(item)
+	}
+	return list, err
+}
+
+func (f *compressFilter) Apply(r *http.Request) error { return nil }
+
+func (f *compressFilter) ApplyResponse(resp *http.Response) error {
+	if resp.Request == nil || resp.Request.Method == http.MethodHead || !rewritableBody(resp) ||
+		resp.Header.Get("Content-Encoding") != "" || resp.Header.Get("Content-Range") != "" ||
+		resp.StatusCode < http.StatusOK || resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusNotModified {
+		return nil
+	}
+	if resp.ContentLength >= 0 && resp.ContentLength < f.minSize {
+		return nil
+	}
+	if !f.compressible(resp.Header.Get("Content-Type")) {
+		return nil
+	}
+	enc := negotiateEncoding(resp.Request.Header.Values("Accept-Encoding"), f.encodings)
+	if enc == "" {
+		return nil
+	}
+
+	pr, pw := io.Pipe()
+	go f.compress(pw, resp.Body, enc)
+	resp.Body = pr
+	resp.ContentLength = -1
+	resp.Header.Del("Content-Length")
+	resp.Header.Set("Content-Encoding", enc)
+	resp.Header.Add("Vary", "Accept-Encoding")
+	if etag := resp.Header.Get("Etag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		// The compressed body is no longer byte-for-byte the tagged one
+		resp.Header.Set("Etag", "W/"+etag)
+	}
+	return nil
+}
+
+// compress copies body to pw, compressed with enc, and records the sizes and
+// the time spent compressing.
+func (f *compressFilter) compress(pw *io.PipeWriter, body io.ReadCloser, enc string) {
+	defer body.Close()
+	out := &countingWriter{w: pw}
+	var zw interface {
+		io.WriteCloser
+		Flush() error
+	}
+	if enc == "gzip" {
+		zw, _ = gzip.NewWriterLevel(out, f.level)
+	} else {
+		zw, _ = flate.NewWriter(out, f.level)
+	}
+
+	var in int64
+	var cpu time.Duration
+	buf := make([]byte, 32<<10)
+	var err error
+	for {
+		n, rerr := body.Read(buf)
+		if n > 0 {
+			start := time.Now()
+			_, err = zw.Write(buf[:n])
+			if err == nil && rerr == nil {
+				// Flush what is compressed so far, so streamed responses
+				// keep flowing.
+				err = zw.Flush()
+			}
+			cpu += time.Since(start)
+			in += int64(n)
+			if err != nil {
+				break
+			}
+		}
+		if rerr == io.EOF {
+			start := time.Now()
+			err = zw.Close()
+			cpu += time.Since(start)
+			break
+		}
+		if rerr != nil {
+			err = rerr
+			break
+		}
+	}
+	observeCompression(f.route, enc, in, out.n, cpu)
+	pw.CloseWithError(err)
+}
+
+// compressible reports whether f compresses responses of contentType.
+func (f *compressFilter) compressible(contentType string) bool {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	if strings.HasSuffix(mt, "+json") || strings.HasSuffix(mt, "+xml") {
+		return true
+	}
+	for _, t := range f.types {
+		if t == mt || strings.HasSuffix(t, "/") && strings.HasPrefix(mt, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// negotiateEncoding returns the first of encodings the Accept-Encoding
+// header values accept, or "" when the client accepts none of them.
+func negotiateEncoding(accept []string, encodings []string) string {
+	q := make(map[string]float64)
+	for _, v := range accept {
+		for _, item := range strings.Split(v, ",") {
+			name, params, _ := strings.Cut(strings.TrimSpace(item), ";")
+			name = strings.ToLower(strings.TrimSpace(name))
+			if name == "" {
+				continue
+			}
+			weight := 1.0
+			if p, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+				if w, err := strconv.ParseFloat(p, 64); err == nil {
+					weight = w
+				}
+			}
+			q[name] = weight
+		}
+	}
+	for _, enc := range encodings {
+		w, ok := q[enc]
+		if !ok {
+			w, ok = q["*"]
+		}
+		if ok && w > 0 {
+			return enc
+		}
+	}
+	return ""
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// compressionMetrics holds the compression counters of all routes, by
+// route and encoding. It lives as long as the process so that counters
+// survive config reloads; its series are bounded by the configured routes.
+var compressionMetrics sync.Map // compressionSeries → *compressionCounters
+
+type compressionSeries struct {
+	route, encoding string
+}
+
+type compressionCounters struct {
+	responses, in, out atomic.Uint64
+	nanos              atomic.Uint64
+}
+
+// observeCompression counts a response compressed on route with enc. It
+// takes no lock, as it runs for every compressed response.
+func observeCompression(route, enc string, in, out int64, cpu time.Duration) {
+	s := compressionSeries{route, enc}
+	v, ok := compressionMetrics.Load(s)
+	if !ok {
+		v, _ = compressionMetrics.LoadOrStore(s, new(compressionCounters))
+	}
+	c := v.(*compressionCounters)
+	c.responses.Add(1)
+	c.in.Add(uint64(in))
+	c.out.Add(uint64(out))
+	c.nanos.Add(uint64(cpu))
+}
+
+func compressionSnapshot() []CompressionStats {
+	stats := []CompressionStats{}
+	compressionMetrics.Range(func(k, v any) bool {
+		s, c := k.(compressionSeries), v.(*compressionCounters)
+		stats = append(stats, CompressionStats{
+			Route:     s.route,
+			Encoding:  s.encoding,
+			Responses: c.responses.Load(),
+			BytesIn:   c.in.Load(),
+			BytesOut:  c.out.Load(),
+			Seconds:   time.Duration(c.nanos.Load()).Seconds(),
+		})
+		return true
+	})
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Route != stats[j].Route {
+			return stats[i].Route < stats[j].Route
+		}
+		return stats[i].Encoding < stats[j].Encoding
+	})
+	return stats
+}
+
+// WriteCompressionMetrics writes the response compression metrics in the
+// Prometheus text exposition format. The compression ratio of a route is
+// its bytes out divided by its bytes in.
+func WriteCompressionMetrics(w io.Writer) error {
+	stats := compressionSnapshot()
+	bw := bufio.NewWriter(w)
+	metrics := []struct {
+		name, help string
+		value      func(CompressionStats) string
+	}{
+		{"nexus_compression_responses_total", "Responses compressed by the gateway, by route and encoding.", func(s CompressionStats) string { return strconv.FormatUint(s.Responses, 10) }},
+		{"nexus_compression_bytes_in_total", "Response bytes before compression, by route and encoding.", func(s CompressionStats) string { return strconv.FormatUint(s.BytesIn, 10) }},
+		{"nexus_compression_bytes_out_total", "Response bytes after compression, by route and encoding.", func(s CompressionStats) string { return strconv.FormatUint(s.BytesOut, 10) }},
+		{"nexus_compression_seconds_total", "Time spent compressing responses, by route and encoding.", func(s CompressionStats) string { return strconv.FormatFloat(s.Seconds, 'g', -1, 64) }},
+	}
+	for _, mt := range metrics {
+		fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s counter\n", mt.name, mt.help, mt.name)
+		for _, s := range stats {
+			fmt.Fprintf(bw, "%s{route=%s,encoding=%s} %s\n", mt.name, promtext.QuoteLabel(s.Route), promtext.QuoteLabel(s.Encoding), mt.value(s))
+		}
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,146 @@
+package runtime
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/oriys/nexus/internal/config"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := []struct {
+		accept []string
+		want   string
+	}{
+		{[]string{"gzip, deflate, br"}, "gzip"},
+		{[]string{"br", "deflate"}, "deflate"},
+		{[]string{"gzip;q=0, deflate;q=0.5"}, "deflate"},
+		{[]string{"*"}, "gzip"},
+		{[]string{"*;q=0, identity"}, ""},
+		{[]string{"br"}, ""},
+		{nil, ""},
+	}
+	for _, tt := range tests {
+		if got := negotiateEncoding(tt.accept, []string{"gzip", "deflate"}); got != tt.want {
+			t.Errorf("negotiateEncoding(%q) = %q, want %q", tt.accept, got, tt.want)
+		}
+	}
+}
+
+func TestCompressFilter(t *testing.T) {
+	f, err := newCompressFilter(config.FilterArgs{"min_size": "16"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cf := f.(*compressFilter)
+	body := strings.Repeat(`{"item": "value"}`, 100)
+
+	newResp := func(contentType, body, accept string) *http.Response {
+		resp := newTestResponse(200, contentType, body)
+		resp.Request = httptest.NewRequest("GET", "/", nil)
+		resp.Request.Header.Set("Accept-Encoding", accept)
+		resp.Header.Set("Etag", `"v1"`)
+		return resp
+	}
+
+	resp := newResp("application/json", body, "gzip")
+	if err := cf.ApplyResponse(resp); err != nil {
+		t.Fatalf("apply error: %v", err)
+	}
+	if resp.Header.Get("Content-Encoding") != "gzip" || resp.ContentLength != -1 || resp.Header.Get("Vary") != "Accept-Encoding" {
+		t.Fatalf("headers = %v, length %d", resp.Header, resp.ContentLength)
+	}
+	if resp.Header.Get("Etag") != `W/"v1"` {
+		t.Errorf("Etag = %q", resp.Header.Get("Etag"))
+	}
+	zr, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		t.Fatalf("gzip reader: %v", err)
+	}
+	if got, _ := io.ReadAll(zr); string(got) != body {
+		t.Errorf("decompressed body differs")
+	}
+
+	resp = newResp("text/html; charset=utf-8", body, "deflate")
+	cf.ApplyResponse(resp)
+	if got, _ := io.ReadAll(flate.NewReader(resp.Body)); string(got) != body {
+		t.Errorf("deflate body differs")
+	}
+
+	encoded := newResp("application/json", body, "gzip")
+	encoded.Header.Set("Content-Encoding", "gzip")
+	skipped := map[string]*http.Response{
+		"small":           newResp("application/json", "{}", "gzip"),
+		"image":           newResp("image/png", body, "gzip"),
+		"not accepted":    newResp("application/json", body, "br"),
+		"already encoded": encoded,
+	}
+	for name, resp := range skipped {
+		enc := resp.Header.Get("Content-Encoding")
+		cf.ApplyResponse(resp)
+		if resp.Header.Get("Content-Encoding") != enc {
+			t.Errorf("%s: response compressed", name)
+		}
+	}
+
+	if _, err := newCompressFilter(config.FilterArgs{"encodings": "br"}); err == nil {
+		t.Error("expected error for brotli")
+	}
+}
+
+func TestGateway_Compress(t *testing.T) {
+	payload := strings.Repeat("hello compression ", 200)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		io.WriteString(w, payload)
+	}))
+	defer backend.Close()
+
+	cfg := &config.Config{
+		Clusters: []config.Cluster{
+			{Name: "backend", Type: "http", Endpoints: []config.ClusterEndpoint{{URL: backend.URL}}},
+		},
+		RoutesV2: []config.RouteV2{{
+			Name:     "compressed",
+			Match:    config.RouteMatch{PathPrefix: "/"},
+			Filters:  []config.RouteFilter{{Type: "compress"}},
+			Upstream: config.RouteUpstream{Cluster: "backend"},
+		}},
+	}
+	compiled, err := Compile(cfg, 1)
+	if err != nil {
+		t.Fatalf("compile error: %v", err)
+	}
+	store := NewConfigStore()
+	store.Store(compiled)
+
+	req := httptest.NewRequest("GET", "/page", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	NewGateway(store).ServeHTTP(rec, req)
+	if rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("response not compressed: %v", rec.Header())
+	}
+	if rec.Body.Len() >= len(payload) {
+		t.Errorf("compressed body is %d bytes", rec.Body.Len())
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(rec.Body.Bytes()))
+	if err != nil {
+		t.Fatalf("gzip reader: %v", err)
+	}
+	if got, _ := io.ReadAll(zr); string(got) != payload {
+		t.Error("decompressed body differs")
+	}
+
+	var buf bytes.Buffer
+	WriteCompressionMetrics(&buf)
+	if !strings.Contains(buf.String(), `nexus_compression_bytes_in_total{route="compressed",encoding="gzip"} 3600`) {
+		t.Errorf("metrics:\n%s", buf.String())
+	}
+}
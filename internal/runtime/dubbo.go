@@ -0,0 +1,475 @@
+package runtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"github.com/oriys/nexus/internal/config"
+	"github.com/oriys/nexus/internal/dubbo"
+	"github.com/oriys/nexus/internal/reqctx"
+	"github.com/oriys/nexus/internal/transcode"
+)
+
+// nativeDubbo reports whether calls to cluster use the dubbo2 TCP protocol
+// rather than the JSON-over-HTTP bridge.
+func nativeDubbo(cluster *CompiledCluster) bool {
+	return cluster.Dubbo != nil && cluster.Dubbo.Serialization == "hessian2" && !tripleDubbo(cluster)
+}
+
+// tripleDubbo reports whether calls to cluster use the triple protocol.
+func tripleDubbo(cluster *CompiledCluster) bool {
+	return cluster.Dubbo != nil && cluster.Dubbo.Protocol == "triple"
+}
+
+// genericDubbo reports whether cluster can serve GenericService.$invoke calls,
+// which need Hessian 2 serialization over dubbo2 or triple.
+func genericDubbo(cluster *CompiledCluster) bool {
+	if cluster == nil || cluster.Dubbo == nil {
+		return false
+	}
+	if tripleDubbo(cluster) {
+		return cluster.Dubbo.Serialization != "protobuf"
+	}
+	return nativeDubbo(cluster)
+}
+
+// defaultDubboRegistryTimeout bounds registry queries when the registry does
+// not configure a timeout.
+const defaultDubboRegistryTimeout = 5 * time.Second
+
+// newDubboRegistry returns the client for a cluster's provider registry.
+func newDubboRegistry(cfg *config.DubboRegistry) dubbo.Registry {
+	timeout := defaultDubboRegistryTimeout
+	if d := cfg.TimeoutDuration(); d > 0 {
+		timeout = d
+	}
+	base := cfg.Address
+	if !strings.Contains(base, "://") {
+		base = "http://" + base
+	}
+	return &dubbo.NacosRegistry{
+		Client:    &http.Client{Timeout: timeout},
+		BaseURL:   base,
+		Namespace: cfg.Namespace,
+		Group:     cfg.Group,
+	}
+}
+
+// DubboProviderAddr converts a Dubbo endpoint address into host:port. It
+// accepts plain "host:port" and "dubbo://host:port" URLs.
+func DubboProviderAddr(addr string) (string, error) {
+	if !strings.Contains(addr, "://") {
+		return addr, nil
+	}
+	u, err := url.Parse(addr)
+	if err != nil {
+		return "", fmt.Errorf("invalid dubbo provider address %s: %w", addr, err)
+	}
+	if u.Host == "" {
+		return "", fmt.Errorf("invalid dubbo provider address %s: missing host", addr)
+	}
+	return u.Host, nil
+}
+
+// TripleTargetURL converts a triple endpoint address into the base URL used
+// for HTTP/2 requests. "tri://host:port" is treated as plain-text HTTP/2.
+func TripleTargetURL(addr string) (*url.URL, error) {
+	if rest, ok := strings.CutPrefix(addr, "tri://"); ok {
+		addr = "http://" + rest
+	}
+	return GRPCTargetURL(addr)
+}
+
+// compileTripleTranscode resolves the IDL messages of a Dubbo route on a
+// triple cluster with protobuf serialization. Messages named in the route's
+// request/response proto settings take precedence over the method's input and
+// output types, which are looked up by treating the interface as the proto
+// service name.
+func compileTripleTranscode(d *config.RouteUpstreamDubbo, protos *transcode.Registry) (*GRPCTranscode, error) {
+	if protos.Len() == 0 {
+		return nil, fmt.Errorf("triple protobuf serialization requires proto_descriptors")
+	}
+	resolve := func(tm *config.TranscodeMode, fromMethod func(protoreflect.MethodDescriptor) protoreflect.MessageDescriptor) (*transcode.Codec, error) {
+		if tm != nil && tm.Proto != "" {
+			md, err := protos.FindMessage(tm.Proto)
+			if err != nil {
+				return nil, err
+			}
+			return protos.Codec(md), nil
+		}
+		method, err := protos.FindMethod(d.Interface, d.Method)
+		if err != nil {
+			return nil, err
+		}
+		return protos.Codec(fromMethod(method)), nil
+	}
+
+	req, err := resolve(d.Request, protoreflect.MethodDescriptor.Input)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := resolve(d.Response, protoreflect.MethodDescriptor.Output)
+	if err != nil {
+		return nil, err
+	}
+	return &GRPCTranscode{Request: req, Response: resp}, nil
+}
+
+// invokeNative calls the route's method over the dubbo2 or triple protocol.
+// Arguments come from the route's params mapping when set; otherwise the JSON
+// request body supplies them: the body itself for a single-parameter method,
+// or a JSON array with one element per parameter. The result is written back
+// as JSON.
+func (u *DubboUpstream) invokeNative(w http.ResponseWriter, r *http.Request, route *CompiledRoute, cluster *CompiledCluster) error {
+	dubboCfg := route.Upstream.Dubbo
+
+	var args []interface{}
+	var protoReq []byte
+	var err error
+	paramTypes := dubboCfg.ParamTypes
+	switch {
+	case route.TripleTranscode != nil:
+		protoReq, err = tripleProtoRequest(r, route.TripleTranscode)
+	case route.DubboParams != nil:
+		paramTypes = route.DubboParams.Types
+		args, err = route.DubboParams.Args(r)
+	default:
+		args, err = dubboArgs(r, paramTypes)
+	}
+	if err != nil {
+		if !writeDubboBodyTooLarge(w, err) {
+			writeDubboError(w, http.StatusBadRequest, err.Error(), "")
+		}
+		return err
+	}
+
+	target, addr, err := cluster.NextTarget()
+	if err != nil {
+		writeDubboError(w, http.StatusServiceUnavailable, "no endpoints available", "")
+		return err
+	}
+	reqctx.Upstream.Set(r.Context(), addr)
+
+	ctx := r.Context()
+	if route.TimeoutMs > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(route.TimeoutMs)*time.Millisecond)
+		defer cancel()
+	}
+
+	inv := &dubbo.Invocation{
+		Interface:   dubboCfg.Interface,
+		Method:      dubboCfg.Method,
+		ParamTypes:  paramTypes,
+		Args:        args,
+		Version:     dubboServiceVersion(dubboCfg, cluster),
+		Group:       dubboServiceGroup(dubboCfg, cluster),
+		Application: cluster.Dubbo.Application,
+	}
+	if dubboCfg.Attachments != nil {
+		inv.Attachments = requestAttachments(r.Header, dubboCfg.Attachments.Request)
+	}
+	if dubboCfg.Generic {
+		inv = inv.Generic()
+	}
+
+	res, err := invokeDubbo(ctx, cluster, target, inv, route.TripleTranscode, protoReq)
+	if err != nil {
+		slog.Error("dubbo invocation error",
+			slog.String("cluster", cluster.Name),
+			slog.String("target", addr),
+			slog.String("method", dubboCfg.Interface+"."+dubboCfg.Method),
+			slog.String("error", err.Error()),
+		)
+		writeDubboError(w, dubboErrorStatus(err), dubboErrorMessage(err), "")
+		return err
+	}
+	if dubboCfg.Attachments != nil {
+		responseAttachments(w.Header(), res.Attachments, dubboCfg.Attachments.Response)
+	}
+	if res.Exception != nil {
+		writeDubboError(w, dubboExceptionStatus(res, dubboCfg.Exceptions), res.Message(), res.ExceptionType())
+		return nil
+	}
+
+	body, err := json.Marshal(res.Value)
+	if err != nil {
+		writeDubboError(w, http.StatusBadGateway, "cannot encode dubbo result as JSON", "")
+		return fmt.Errorf("encode dubbo result: %w", err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+	return nil
+}
+
+// attachmentKey returns the attachment key of m, defaulting to the
+// lower-cased header name.
+func attachmentKey(m config.DubboAttachmentMapping) string {
+	if m.Attachment != "" {
+		return m.Attachment
+	}
+	return strings.ToLower(m.Header)
+}
+
+// headerName returns the HTTP header of m, defaulting to the attachment key.
+func headerName(m config.DubboAttachmentMapping) string {
+	if m.Header != "" {
+		return m.Header
+	}
+	return m.Attachment
+}
+
+// requestAttachments collects the mapped request headers that are present.
+func requestAttachments(h http.Header, mappings []config.DubboAttachmentMapping) map[string]string {
+	att := make(map[string]string, len(mappings))
+	for _, m := range mappings {
+		if v := h.Get(headerName(m)); v != "" {
+			att[attachmentKey(m)] = v
+		}
+	}
+	return att
+}
+
+// responseAttachments copies the mapped result attachments into response
+// headers. Attachment keys are matched case-insensitively since triple
+// carries them as HTTP/2 headers.
+func responseAttachments(h http.Header, att map[string]interface{}, mappings []config.DubboAttachmentMapping) {
+	for _, m := range mappings {
+		key := attachmentKey(m)
+		v, ok := att[key]
+		if !ok {
+			for k, kv := range att {
+				if strings.EqualFold(k, key) {
+					v, ok = kv, true
+					break
+				}
+			}
+		}
+		if ok && v != nil {
+			h.Set(headerName(m), fmt.Sprint(v))
+		}
+	}
+}
+
+// dubboServiceGroup returns the service group for a route: its own group, or
+// the cluster's.
+func dubboServiceGroup(d *config.RouteUpstreamDubbo, cluster *CompiledCluster) string {
+	if d.Group != "" {
+		return d.Group
+	}
+	if cluster.Dubbo != nil {
+		return cluster.Dubbo.Group
+	}
+	return ""
+}
+
+// dubboServiceVersion returns the service version for a route: its own
+// version, or the cluster's.
+func dubboServiceVersion(d *config.RouteUpstreamDubbo, cluster *CompiledCluster) string {
+	if d.Version != "" {
+		return d.Version
+	}
+	if cluster.Dubbo != nil {
+		return cluster.Dubbo.Version
+	}
+	return ""
+}
+
+// ErrDubboNotNative is returned by InvokeDubbo for clusters that do not call
+// providers natively with Hessian 2 serialization.
+var ErrDubboNotNative = errors.New("cluster does not call dubbo providers natively with hessian2 serialization")
+
+// InvokeDubbo calls inv on the cluster endpoint at addr over the cluster's
+// protocol, dubbo2 or triple, using the connections and transports that
+// serve the cluster's routes. The cluster must use Hessian 2 serialization.
+func InvokeDubbo(ctx context.Context, cluster *CompiledCluster, addr string, inv *dubbo.Invocation) (*dubbo.Result, error) {
+	if !genericDubbo(cluster) {
+		return nil, ErrDubboNotNative
+	}
+	target, err := cluster.resolveTarget(addr)
+	if err != nil {
+		return nil, err
+	}
+	return invokeDubbo(ctx, cluster, target, inv, nil, nil)
+}
+
+// invokeDubbo sends inv to the endpoint at target over triple or dubbo2. tc
+// and protoReq are only used by triple clusters with protobuf serialization.
+func invokeDubbo(ctx context.Context, cluster *CompiledCluster, target *url.URL, inv *dubbo.Invocation, tc *GRPCTranscode, protoReq []byte) (*dubbo.Result, error) {
+	if tripleDubbo(cluster) {
+		return invokeTriple(ctx, cluster, target, inv, tc, protoReq)
+	}
+	return cluster.dubboClients().Invoke(ctx, target.Host, inv)
+}
+
+// invokeTriple calls inv over the triple protocol using the cluster's HTTP/2
+// transport. With IDL codecs the request is the pre-encoded protoReq and the
+// response is converted to JSON; otherwise arguments are sent in wrapper mode.
+func invokeTriple(ctx context.Context, cluster *CompiledCluster, base *url.URL, inv *dubbo.Invocation, tc *GRPCTranscode, protoReq []byte) (*dubbo.Result, error) {
+	client := &dubbo.TripleClient{Transport: cluster.grpcTransport(), BaseURL: base}
+	if tc == nil {
+		return client.Invoke(ctx, inv)
+	}
+	out, att, err := client.InvokeRaw(ctx, inv, protoReq)
+	if err != nil {
+		return nil, err
+	}
+	body, err := tc.Response.ToJSON(out)
+	if err != nil {
+		return nil, fmt.Errorf("decode triple response: %w", err)
+	}
+	return &dubbo.Result{Value: json.RawMessage(body), Attachments: att}, nil
+}
+
+// tripleProtoRequest transcodes the JSON request body into the route's IDL
+// request message.
+func tripleProtoRequest(r *http.Request, tc *GRPCTranscode) ([]byte, error) {
+	var data []byte
+	if r.Body != nil {
+		buf, err := readPooled(r.Body)
+		r.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read request body: %w", err)
+		}
+		defer putBuffer(buf)
+		data = buf.Bytes()
+	}
+	msg, err := tc.Request.FromJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid request body: %w", err)
+	}
+	return msg, nil
+}
+
+// dubboArgs decodes the request body into one argument per parameter type.
+func dubboArgs(r *http.Request, paramTypes []string) ([]interface{}, error) {
+	if len(paramTypes) == 0 {
+		if r.Body != nil {
+			r.Body.Close()
+		}
+		return nil, nil
+	}
+	body, err := readJSONBody(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(paramTypes) == 1 {
+		return []interface{}{body}, nil
+	}
+	list, ok := body.([]interface{})
+	if !ok || len(list) != len(paramTypes) {
+		return nil, fmt.Errorf("request body must be a JSON array of %d arguments", len(paramTypes))
+	}
+	return list, nil
+}
+
+// writeDubboBodyTooLarge answers 413 when err comes from a request body
+// larger than the cluster's dubbo.max_body, reporting whether it did.
+func writeDubboBodyTooLarge(w http.ResponseWriter, err error) bool {
+	var tooLarge *http.MaxBytesError
+	if !errors.As(err, &tooLarge) {
+		return false
+	}
+	writeDubboError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("request body larger than %d bytes", tooLarge.Limit), "")
+	return true
+}
+
+// dubboMaxBody returns the largest request body the cluster accepts.
+func dubboMaxBody(cluster *CompiledCluster) int64 {
+	if cluster.Dubbo == nil {
+		return config.DefaultDubboMaxBody
+	}
+	return cluster.Dubbo.MaxBodyLimit()
+}
+
+// dubboErrorStatus maps a failed invocation to an HTTP status.
+func dubboErrorStatus(err error) int {
+	var se *dubbo.StatusError
+	var te *dubbo.TripleError
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	case errors.As(err, &te):
+		return GRPCStatus{Code: te.Code}.HTTPStatus()
+	case errors.As(err, &se):
+		switch se.Status {
+		case dubbo.StatusClientTimeout, dubbo.StatusServerTimeout:
+			return http.StatusGatewayTimeout
+		case dubbo.StatusThreadpoolExhaust:
+			return http.StatusServiceUnavailable
+		}
+	}
+	return http.StatusBadGateway
+}
+
+// dubboExceptionStatus maps an exception thrown by the provider to an HTTP
+// status: the first matching configured mapping, then the status implied by
+// an RpcException's code, then the configured default.
+func dubboExceptionStatus(res *dubbo.Result, cfg *config.DubboExceptions) int {
+	class := res.ExceptionType()
+	if cfg != nil && class != "" {
+		for _, m := range cfg.Mappings {
+			if pkg, ok := strings.CutSuffix(m.Exception, ".*"); ok {
+				if strings.HasPrefix(class, pkg+".") {
+					return m.Status
+				}
+			} else if class == m.Exception {
+				return m.Status
+			}
+		}
+	}
+	if code, ok := res.RpcExceptionCode(); ok {
+		switch code {
+		case dubbo.RpcTimeout, dubbo.RpcTimeoutTerminate:
+			return http.StatusGatewayTimeout
+		case dubbo.RpcForbidden:
+			return http.StatusForbidden
+		case dubbo.RpcLimitExceeded:
+			return http.StatusTooManyRequests
+		case dubbo.RpcNoInvoker:
+			return http.StatusServiceUnavailable
+		case dubbo.RpcValidation:
+			return http.StatusBadRequest
+		case dubbo.RpcNetwork, dubbo.RpcSerialization, dubbo.RpcMethodNotFound:
+			return http.StatusBadGateway
+		}
+	}
+	if cfg != nil && cfg.Status != 0 {
+		return cfg.Status
+	}
+	return http.StatusInternalServerError
+}
+
+func dubboErrorMessage(err error) string {
+	var se *dubbo.StatusError
+	var te *dubbo.TripleError
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return "upstream timeout"
+	case errors.As(err, &te):
+		return te.Message
+	case errors.As(err, &se):
+		return se.Message
+	}
+	return "bad gateway"
+}
+
+func writeDubboError(w http.ResponseWriter, status int, msg, exception string) {
+	body := map[string]string{"error": msg}
+	if exception != "" {
+		body["exception"] = exception
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,766 @@
+package runtime
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/oriys/nexus/internal/config"
+	"github.com/oriys/nexus/internal/dubbo"
+	"github.com/oriys/nexus/internal/transcode"
+)
+
+// dubboCall is a request received by a fake Dubbo provider.
+type dubboCall struct {
+	service     string
+	version     string
+	method      string
+	descriptor  string
+	args        []interface{}
+	attachments map[string]interface{}
+}
+
+// newDubboProvider starts a fake dubbo2 provider. reply returns the response
+// status and body for each call.
+func newDubboProvider(t *testing.T, reply func(call dubboCall) (byte, []byte)) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	var conns []net.Conn
+	t.Cleanup(func() {
+		ln.Close()
+		mu.Lock()
+		defer mu.Unlock()
+		for _, c := range conns {
+			c.Close()
+		}
+	})
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			conns = append(conns, conn)
+			mu.Unlock()
+			go func() {
+				r := bufio.NewReader(conn)
+				for {
+					f, err := dubbo.ReadFrame(r, dubbo.DefaultMaxBodySize)
+					if err != nil {
+						return
+					}
+					if f.Event {
+						dubbo.WriteFrame(conn, &dubbo.Frame{ID: f.ID, Event: true, Status: dubbo.StatusOK, Body: []byte{'N'}})
+						continue
+					}
+					d := dubbo.NewDecoder(f.Body)
+					var values []interface{}
+					for d.Remaining() {
+						v, err := d.Decode()
+						if err != nil {
+							return
+						}
+						values = append(values, v)
+					}
+					call := dubboCall{
+						service:    values[1].(string),
+						version:    values[2].(string),
+						method:     values[3].(string),
+						descriptor: values[4].(string),
+						args:       values[5 : len(values)-1],
+					}
+					call.attachments, _ = values[len(values)-1].(map[string]interface{})
+					status, body := reply(call)
+					dubbo.WriteFrame(conn, &dubbo.Frame{ID: f.ID, Status: status, Body: body})
+				}
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+// dubboValue encodes a successful response carrying v.
+func dubboValue(v interface{}) []byte {
+	var e dubbo.Encoder
+	e.WriteInt(1) // value
+	e.Encode(v)
+	return e.Bytes()
+}
+
+func nativeDubboRoute(addr string, paramTypes ...string) (*CompiledRoute, *CompiledCluster) {
+	route := &CompiledRoute{
+		Name: "dubbo",
+		Upstream: RouteUpstreamConfig{
+			ClusterName: "orders",
+			Dubbo: &config.RouteUpstreamDubbo{
+				Interface:  "com.foo.order.OrderService",
+				Method:     "CreateOrder",
+				ParamTypes: paramTypes,
+			},
+		},
+	}
+	cluster := &CompiledCluster{
+		Name:      "orders",
+		Type:      "dubbo",
+		Endpoints: []config.ClusterEndpoint{{Addr: addr}},
+		Dubbo: &config.ClusterDubbo{
+			Application:   "nova-gw",
+			Group:         "blue",
+			Version:       "1.0.0",
+			Serialization: "hessian2",
+		},
+	}
+	return route, cluster
+}
+
+func TestDubboUpstream_NativeInvoke(t *testing.T) {
+	calls := make(chan dubboCall, 1)
+	addr := newDubboProvider(t, func(call dubboCall) (byte, []byte) {
+		calls <- call
+		return dubbo.StatusOK, dubboValue(map[string]interface{}{"orderId": int64(9001), "status": "CREATED"})
+	})
+	route, cluster := nativeDubboRoute("dubbo://"+addr, "com.foo.order.CreateOrderRequest")
+
+	req := httptest.NewRequest("POST", "/api/v1/order/create", strings.NewReader(`{"sku":"A1","qty":2}`))
+	w := httptest.NewRecorder()
+	if err := (&DubboUpstream{}).Handle(w, req, route, cluster); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON response: %v", err)
+	}
+	if got["orderId"] != float64(9001) || got["status"] != "CREATED" {
+		t.Errorf("unexpected response %v", got)
+	}
+
+	call := <-calls
+	if call.service != "com.foo.order.OrderService" || call.method != "CreateOrder" || call.version != "1.0.0" {
+		t.Errorf("unexpected call %+v", call)
+	}
+	if call.descriptor != "Lcom/foo/order/CreateOrderRequest;" {
+		t.Errorf("unexpected descriptor %q", call.descriptor)
+	}
+	wantArgs := []interface{}{map[string]interface{}{"sku": "A1", "qty": int32(2)}}
+	if !reflect.DeepEqual(call.args, wantArgs) {
+		t.Errorf("got args %#v, want %#v", call.args, wantArgs)
+	}
+	if call.attachments["group"] != "blue" || call.attachments["remote.application"] != "nova-gw" {
+		t.Errorf("unexpected attachments %v", call.attachments)
+	}
+}
+
+func TestDubboUpstream_NativeMultipleArgs(t *testing.T) {
+	calls := make(chan dubboCall, 1)
+	addr := newDubboProvider(t, func(call dubboCall) (byte, []byte) {
+		calls <- call
+		return dubbo.StatusOK, dubboValue(true)
+	})
+	route, cluster := nativeDubboRoute(addr, "long", "java.lang.String")
+
+	req := httptest.NewRequest("POST", "/", strings.NewReader(`[42, "note"]`))
+	w := httptest.NewRecorder()
+	(&DubboUpstream{}).Handle(w, req, route, cluster)
+	if w.Code != http.StatusOK || strings.TrimSpace(w.Body.String()) != "true" {
+		t.Fatalf("unexpected response %d %s", w.Code, w.Body.String())
+	}
+	if call := <-calls; !reflect.DeepEqual(call.args, []interface{}{int64(42), "note"}) {
+		t.Errorf("unexpected args %#v", call.args)
+	}
+
+	// A body that does not match the parameter list is rejected before the call.
+	req = httptest.NewRequest("POST", "/", strings.NewReader(`{"id":42}`))
+	w = httptest.NewRecorder()
+	(&DubboUpstream{}).Handle(w, req, route, cluster)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", w.Code)
+	}
+}
+
+func TestDubboUpstream_NativeErrors(t *testing.T) {
+	addr := newDubboProvider(t, func(call dubboCall) (byte, []byte) {
+		var e dubbo.Encoder
+		switch call.args[0] {
+		case "throw":
+			// An exception object: class IllegalArgumentException { detailMessage }.
+			e.WriteInt(0)
+			return dubbo.StatusOK, append(append(e.Bytes(), 'C'), exceptionBody("java.lang.IllegalArgumentException", "bad sku")...)
+		case "missing":
+			e.WriteString("Not found exported service")
+			return dubbo.StatusServiceNotFound, e.Bytes()
+		case "busy":
+			e.WriteString("thread pool is exhausted")
+			return dubbo.StatusThreadpoolExhaust, e.Bytes()
+		case "slow":
+			time.Sleep(200 * time.Millisecond)
+		}
+		return dubbo.StatusOK, dubboValue(nil)
+	})
+
+	tests := []struct {
+		arg       string
+		timeout   int
+		status    int
+		exception string
+	}{
+		{arg: "throw", status: http.StatusInternalServerError, exception: "java.lang.IllegalArgumentException"},
+		{arg: "missing", status: http.StatusBadGateway},
+		{arg: "busy", status: http.StatusServiceUnavailable},
+		{arg: "slow", timeout: 20, status: http.StatusGatewayTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.arg, func(t *testing.T) {
+			route, cluster := nativeDubboRoute(addr, "java.lang.String")
+			route.TimeoutMs = tt.timeout
+			req := httptest.NewRequest("POST", "/", strings.NewReader(`"`+tt.arg+`"`))
+			w := httptest.NewRecorder()
+			(&DubboUpstream{}).Handle(w, req, route, cluster)
+
+			if w.Code != tt.status {
+				t.Fatalf("expected %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+			var body map[string]string
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("invalid JSON error body: %v", err)
+			}
+			if body["error"] == "" || body["exception"] != tt.exception {
+				t.Errorf("unexpected error body %v", body)
+			}
+		})
+	}
+}
+
+func TestDubboUpstream_GenericInvoke(t *testing.T) {
+	calls := make(chan dubboCall, 1)
+	addr := newDubboProvider(t, func(call dubboCall) (byte, []byte) {
+		calls <- call
+		return dubbo.StatusOK, dubboValue(map[string]interface{}{"class": "com.foo.order.Order", "id": int64(1)})
+	})
+	route, cluster := nativeDubboRoute(addr, "com.foo.order.CreateOrderRequest")
+	route.Upstream.Dubbo.Generic = true
+
+	req := httptest.NewRequest("POST", "/", strings.NewReader(`{"sku":"A1"}`))
+	w := httptest.NewRecorder()
+	if err := (&DubboUpstream{}).Handle(w, req, route, cluster); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	call := <-calls
+	if call.service != "com.foo.order.OrderService" || call.method != "$invoke" {
+		t.Errorf("unexpected call %s.%s", call.service, call.method)
+	}
+	wantArgs := []interface{}{
+		"CreateOrder",
+		[]interface{}{"com.foo.order.CreateOrderRequest"},
+		[]interface{}{map[string]interface{}{"sku": "A1"}},
+	}
+	if !reflect.DeepEqual(call.args, wantArgs) {
+		t.Errorf("got args %#v, want %#v", call.args, wantArgs)
+	}
+	if call.attachments["generic"] != "true" {
+		t.Errorf("expected generic attachment, got %v", call.attachments)
+	}
+}
+
+func TestDubboUpstream_Attachments(t *testing.T) {
+	calls := make(chan dubboCall, 1)
+	addr := newDubboProvider(t, func(call dubboCall) (byte, []byte) {
+		calls <- call
+		var e dubbo.Encoder
+		e.WriteInt(4) // value with attachments
+		e.WriteString("ok")
+		e.Encode(map[string]string{"biz-code": "A100", "dubbo": "2.0.2"})
+		return dubbo.StatusOK, e.Bytes()
+	})
+	route, cluster := nativeDubboRoute(addr, "java.lang.String")
+	route.Upstream.Dubbo.Attachments = &config.DubboAttachments{
+		Request: []config.DubboAttachmentMapping{
+			{Header: "X-Tenant"},
+			{Header: "X-Request-Id", Attachment: "traceId"},
+			{Header: "X-Missing"},
+		},
+		Response: []config.DubboAttachmentMapping{
+			{Attachment: "biz-code", Header: "X-Biz-Code"},
+			{Attachment: "absent"},
+		},
+	}
+
+	req := httptest.NewRequest("POST", "/", strings.NewReader(`"a"`))
+	req.Header.Set("X-Tenant", "acme")
+	req.Header.Set("X-Request-Id", "r-1")
+	req.Header.Set("X-Other", "ignored")
+	w := httptest.NewRecorder()
+	if err := (&DubboUpstream{}).Handle(w, req, route, cluster); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("X-Biz-Code"); got != "A100" {
+		t.Errorf("expected X-Biz-Code A100, got %q", got)
+	}
+	if _, ok := w.Header()["Absent"]; ok {
+		t.Error("unexpected header for a missing attachment")
+	}
+
+	att := (<-calls).attachments
+	if att["x-tenant"] != "acme" || att["traceId"] != "r-1" {
+		t.Errorf("unexpected attachments %v", att)
+	}
+	if _, ok := att["x-missing"]; ok {
+		t.Error("absent header should not become an attachment")
+	}
+	if _, ok := att["x-other"]; ok {
+		t.Error("unmapped header should not become an attachment")
+	}
+}
+
+func TestDubboUpstream_NativeParams(t *testing.T) {
+	calls := make(chan dubboCall, 1)
+	addr := newDubboProvider(t, func(call dubboCall) (byte, []byte) {
+		calls <- call
+		return dubbo.StatusOK, dubboValue("ok")
+	})
+	route, cluster := nativeDubboRoute(addr)
+	d := route.Upstream.Dubbo
+	d.PathTemplate = "/api/v1/orders/{id}"
+	d.Params = []config.DubboParam{
+		{Type: "long", From: "path.id"},
+		{Type: "int", From: "query.limit"},
+		{Type: "java.lang.String", From: "body.note"},
+	}
+	params, err := compileDubboParams(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	route.DubboParams = params
+
+	req := httptest.NewRequest("POST", "/api/v1/orders/42?limit=5", strings.NewReader(`{"note":"gift"}`))
+	w := httptest.NewRecorder()
+	if err := (&DubboUpstream{}).Handle(w, req, route, cluster); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	call := <-calls
+	if call.descriptor != "JILjava/lang/String;" {
+		t.Errorf("unexpected descriptor %q", call.descriptor)
+	}
+	if want := []interface{}{int64(42), int32(5), "gift"}; !reflect.DeepEqual(call.args, want) {
+		t.Errorf("got args %#v, want %#v", call.args, want)
+	}
+
+	// Inputs that cannot be mapped are rejected before the call.
+	req = httptest.NewRequest("POST", "/api/v1/orders/42", strings.NewReader(`{}`))
+	w = httptest.NewRecorder()
+	(&DubboUpstream{}).Handle(w, req, route, cluster)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", w.Code)
+	}
+}
+
+func TestCompile_GenericRequiresHessian(t *testing.T) {
+	cfg := tripleConfig("http://127.0.0.1:1", "protobuf")
+	cfg.RoutesV2[0].Upstream.Dubbo.Generic = true
+	if _, err := Compile(cfg, 1); err == nil || !strings.Contains(err.Error(), "generic invocation") {
+		t.Errorf("expected generic invocation error, got %v", err)
+	}
+
+	cfg.Clusters[0].Dubbo = &config.ClusterDubbo{Serialization: "json"}
+	if _, err := Compile(cfg, 1); err == nil || !strings.Contains(err.Error(), "generic invocation") {
+		t.Errorf("expected generic invocation error for the HTTP bridge, got %v", err)
+	}
+
+	cfg.Clusters[0].Dubbo = &config.ClusterDubbo{Protocol: "triple"}
+	if _, err := Compile(cfg, 1); err != nil {
+		t.Errorf("unexpected error for triple wrapper cluster: %v", err)
+	}
+}
+
+func TestCompile_DubboRegistry(t *testing.T) {
+	cfg := tripleConfig("http://127.0.0.1:1", "hessian2")
+	cfg.Clusters[0].Dubbo.Registry = &config.DubboRegistry{Type: "nacos", Address: "nacos:8848", Namespace: "prod"}
+	compiled, err := Compile(cfg, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg, ok := compiled.Clusters["users"].DubboRegistry.(*dubbo.NacosRegistry)
+	if !ok || reg.BaseURL != "http://nacos:8848" || reg.Namespace != "prod" {
+		t.Errorf("unexpected registry %#v", compiled.Clusters["users"].DubboRegistry)
+	}
+}
+
+func TestCompile_DubboPools(t *testing.T) {
+	addr := newDubboProvider(t, func(call dubboCall) (byte, []byte) {
+		return dubbo.StatusOK, dubboValue("ok")
+	})
+	cluster := func(name, addr string, connections int) config.Cluster {
+		return config.Cluster{
+			Name:      name,
+			Type:      "dubbo",
+			Endpoints: []config.ClusterEndpoint{{Addr: "dubbo://" + addr}},
+			Dubbo:     &config.ClusterDubbo{Serialization: "hessian2", Connections: connections},
+		}
+	}
+	cfg := &config.Config{Clusters: []config.Cluster{
+		cluster("orders", addr, 0),
+		cluster("payments", "127.0.0.1:1", 0),
+		cluster("stock", "127.0.0.1:2", 2),
+	}}
+	store := NewConfigStore()
+	compiled, err := CompileAndStore(cfg, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	orders, payments, stock := compiled.Clusters["orders"], compiled.Clusters["payments"], compiled.Clusters["stock"]
+	if orders.dubboPool == nil || orders.dubboPool != payments.dubboPool || orders.dubboPool == stock.dubboPool {
+		t.Fatal("expected clusters with equal connection settings to share a pool")
+	}
+
+	route, _ := nativeDubboRoute(addr)
+	w := httptest.NewRecorder()
+	(&DubboUpstream{}).Handle(w, httptest.NewRequest("POST", "/", nil), route, orders)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	stats := orders.DubboConnections()
+	if len(stats) != 1 || stats[0].Addr != addr || stats[0].Calls != 1 || stats[0].Connections != 1 {
+		t.Errorf("unexpected connection stats %+v", stats)
+	}
+	if len(payments.DubboConnections()) != 0 {
+		t.Error("expected no stats for a provider that was never called")
+	}
+
+	// Dropping the cluster on reload closes its provider connections.
+	client, _ := orders.dubboPool.Get(context.Background(), addr)
+	cfg.Clusters = cfg.Clusters[1:]
+	if _, err := CompileAndStore(cfg, store); err != nil {
+		t.Fatal(err)
+	}
+	if client.Err() == nil {
+		t.Error("expected the dropped provider's connection to be closed")
+	}
+}
+
+// exceptionBody encodes the remainder of a class definition and instance for
+// a Throwable with only a detailMessage field. The leading 'C' is written by
+// the caller.
+func exceptionBody(class, message string) []byte {
+	var e dubbo.Encoder
+	e.WriteString(class)
+	e.WriteInt(1)
+	e.WriteString("detailMessage")
+	b := append(e.Bytes(), 0x60)
+	var msg dubbo.Encoder
+	msg.WriteString(message)
+	return append(b, msg.Bytes()...)
+}
+
+// exceptionResponse encodes a response throwing an object of class with the
+// given field name/value pairs.
+func exceptionResponse(class string, fields ...interface{}) []byte {
+	var e dubbo.Encoder
+	e.WriteInt(0) // exception
+	b := append(e.Bytes(), 'C')
+	var def dubbo.Encoder
+	def.WriteString(class)
+	def.WriteInt(int32(len(fields) / 2))
+	for i := 0; i < len(fields); i += 2 {
+		def.WriteString(fields[i].(string))
+	}
+	b = append(append(b, def.Bytes()...), 0x60)
+	var values dubbo.Encoder
+	for i := 1; i < len(fields); i += 2 {
+		values.Encode(fields[i])
+	}
+	return append(b, values.Bytes()...)
+}
+
+func TestDubboUpstream_ExceptionMapping(t *testing.T) {
+	addr := newDubboProvider(t, func(call dubboCall) (byte, []byte) {
+		switch call.args[0] {
+		case "notfound":
+			return dubbo.StatusOK, exceptionResponse("com.foo.order.OrderNotFoundException", "detailMessage", "order 7")
+		case "conflict":
+			return dubbo.StatusOK, exceptionResponse("com.foo.order.error.StockConflict", "detailMessage", "sold out")
+		case "generic":
+			return dubbo.StatusOK, exceptionResponse("org.apache.dubbo.rpc.service.GenericException",
+				"exceptionClass", "com.foo.order.OrderNotFoundException", "exceptionMessage", "order 8")
+		case "timeout":
+			return dubbo.StatusOK, exceptionResponse("org.apache.dubbo.rpc.RpcException", "detailMessage", "timed out", "code", 2)
+		case "limited":
+			return dubbo.StatusOK, exceptionResponse("com.alibaba.dubbo.rpc.RpcException", "code", 7)
+		}
+		return dubbo.StatusOK, exceptionResponse("java.lang.IllegalStateException", "detailMessage", "boom")
+	})
+
+	tests := []struct {
+		arg       string
+		status    int
+		exception string
+		message   string
+	}{
+		{"notfound", http.StatusNotFound, "com.foo.order.OrderNotFoundException", "com.foo.order.OrderNotFoundException: order 7"},
+		{"conflict", http.StatusConflict, "com.foo.order.error.StockConflict", "com.foo.order.error.StockConflict: sold out"},
+		{"generic", http.StatusNotFound, "com.foo.order.OrderNotFoundException", "com.foo.order.OrderNotFoundException: order 8"},
+		{"timeout", http.StatusGatewayTimeout, "org.apache.dubbo.rpc.RpcException", "org.apache.dubbo.rpc.RpcException: timed out"},
+		{"limited", http.StatusTooManyRequests, "com.alibaba.dubbo.rpc.RpcException", "com.alibaba.dubbo.rpc.RpcException"},
+		{"other", http.StatusBadGateway, "java.lang.IllegalStateException", "java.lang.IllegalStateException: boom"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.arg, func(t *testing.T) {
+			route, cluster := nativeDubboRoute(addr, "java.lang.String")
+			route.Upstream.Dubbo.Exceptions = &config.DubboExceptions{
+				Status: http.StatusBadGateway,
+				Mappings: []config.DubboExceptionMapping{
+					{Exception: "com.foo.order.OrderNotFoundException", Status: http.StatusNotFound},
+					{Exception: "com.foo.order.*", Status: http.StatusConflict},
+				},
+			}
+			req := httptest.NewRequest("POST", "/", strings.NewReader(`"`+tt.arg+`"`))
+			w := httptest.NewRecorder()
+			(&DubboUpstream{}).Handle(w, req, route, cluster)
+
+			if w.Code != tt.status {
+				t.Fatalf("expected %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+			var body map[string]string
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("invalid JSON error body: %v", err)
+			}
+			if body["exception"] != tt.exception || body["error"] != tt.message {
+				t.Errorf("unexpected error body %v", body)
+			}
+		})
+	}
+}
+
+func TestDubboUpstream_JSONSerializationUsesHTTP(t *testing.T) {
+	var gotPath string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		w.Write([]byte(`{}`))
+	}))
+	defer backend.Close()
+
+	route, cluster := nativeDubboRoute(backend.URL, "java.lang.String")
+	cluster.Dubbo.Serialization = "json"
+	req := httptest.NewRequest("POST", "/", strings.NewReader(`"x"`))
+	w := httptest.NewRecorder()
+	(&DubboUpstream{}).Handle(w, req, route, cluster)
+	if w.Code != http.StatusOK || gotPath != "/com.foo.order.OrderService/CreateOrder" {
+		t.Errorf("expected HTTP bridge call, got %d to %q", w.Code, gotPath)
+	}
+}
+
+func TestDubboUpstream_MaxBody(t *testing.T) {
+	var got string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got = string(body)
+		w.Write([]byte(`{}`))
+	}))
+	defer backend.Close()
+
+	for _, serialization := range []string{"json", "hessian2"} {
+		route, cluster := nativeDubboRoute(backend.URL, "java.util.Map")
+		cluster.Dubbo.Serialization = serialization
+		cluster.Dubbo.MaxBody = "16B"
+		w := httptest.NewRecorder()
+		(&DubboUpstream{}).Handle(w, httptest.NewRequest("POST", "/", strings.NewReader(`{"id":"0123456789"}`)), route, cluster)
+		if w.Code != http.StatusRequestEntityTooLarge || !strings.Contains(w.Body.String(), "larger than 16 bytes") {
+			t.Errorf("%s: expected 413, got %d: %s", serialization, w.Code, w.Body)
+		}
+	}
+
+	// Bodies within the limit are embedded in the invocation.
+	route, cluster := nativeDubboRoute(backend.URL, "java.util.Map")
+	cluster.Dubbo.Serialization = "json"
+	cluster.Dubbo.MaxBody = "16B"
+	w := httptest.NewRecorder()
+	(&DubboUpstream{}).Handle(w, httptest.NewRequest("POST", "/", strings.NewReader(`{"id": 1}`)), route, cluster)
+	want := `{"interface":"com.foo.order.OrderService","method":"CreateOrder","param_types":["java.util.Map"],"args":{"id":1}}`
+	if w.Code != http.StatusOK || got != want {
+		t.Errorf("expected %s, got %d: %s", want, w.Code, got)
+	}
+}
+
+func TestDubboUpstream_RouteServiceOverride(t *testing.T) {
+	calls := make(chan dubboCall, 1)
+	addr := newDubboProvider(t, func(call dubboCall) (byte, []byte) {
+		calls <- call
+		return dubbo.StatusOK, dubboValue(nil)
+	})
+
+	route, cluster := nativeDubboRoute(addr, "java.lang.String")
+	route.Upstream.Dubbo.Version = "2.0.0"
+	(&DubboUpstream{}).Handle(httptest.NewRecorder(), httptest.NewRequest("POST", "/", strings.NewReader(`"x"`)), route, cluster)
+	call := <-calls
+	if call.version != "2.0.0" || call.attachments["version"] != "2.0.0" || call.attachments["group"] != "blue" {
+		t.Errorf("expected route version with cluster group, got version %q, attachments %v", call.version, call.attachments)
+	}
+
+	route.Upstream.Dubbo.Group = "canary"
+	(&DubboUpstream{}).Handle(httptest.NewRecorder(), httptest.NewRequest("POST", "/", strings.NewReader(`"x"`)), route, cluster)
+	if call := <-calls; call.attachments["group"] != "canary" {
+		t.Errorf("expected route group, got attachments %v", call.attachments)
+	}
+
+	// The HTTP bridge sends the same selection as headers.
+	var gotGroup, gotVersion string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotGroup, gotVersion = r.Header.Get("Dubbo-Group"), r.Header.Get("Dubbo-Version")
+		w.Write([]byte(`{}`))
+	}))
+	defer backend.Close()
+	route, cluster = nativeDubboRoute(backend.URL, "java.lang.String")
+	cluster.Dubbo.Serialization = "json"
+	route.Upstream.Dubbo.Group = "canary"
+	(&DubboUpstream{}).Handle(httptest.NewRecorder(), httptest.NewRequest("POST", "/", strings.NewReader(`"x"`)), route, cluster)
+	if gotGroup != "canary" || gotVersion != "1.0.0" {
+		t.Errorf("expected Dubbo-Group canary and Dubbo-Version 1.0.0, got %q and %q", gotGroup, gotVersion)
+	}
+}
+
+func TestDubboProviderAddr(t *testing.T) {
+	tests := map[string]string{
+		"order-dubbo:20880":         "order-dubbo:20880",
+		"dubbo://order-dubbo:20880": "order-dubbo:20880",
+	}
+	for in, want := range tests {
+		got, err := DubboProviderAddr(in)
+		if err != nil || got != want {
+			t.Errorf("DubboProviderAddr(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := DubboProviderAddr("dubbo://"); err == nil {
+		t.Error("expected error for address without host")
+	}
+}
+
+func tripleConfig(backendURL, serialization string, protos ...string) *config.Config {
+	return &config.Config{
+		ProtoDescriptors: protos,
+		Clusters: []config.Cluster{{
+			Name:      "users",
+			Type:      "dubbo",
+			Endpoints: []config.ClusterEndpoint{{URL: strings.Replace(backendURL, "http://", "tri://", 1)}},
+			Dubbo:     &config.ClusterDubbo{Protocol: "triple", Serialization: serialization, Version: "2.0.0"},
+		}},
+		RoutesV2: []config.RouteV2{{
+			Name:  "get-user",
+			Match: config.RouteMatch{Path: "/api/user"},
+			Upstream: config.RouteUpstream{
+				Cluster: "users",
+				Dubbo:   &config.RouteUpstreamDubbo{Interface: "user.v1.UserService", Method: "GetUser", ParamTypes: []string{"long"}},
+			},
+		}},
+	}
+}
+
+func TestDubboUpstream_TripleProtobuf(t *testing.T) {
+	descPath := writeUserDescriptorSet(t)
+	reg, err := transcode.LoadRegistry([]string{descPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	respDesc, _ := reg.FindMessage("user.v1.User")
+
+	backend := newGRPCBackend(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/user.v1.UserService/GetUser" || r.Header.Get("Tri-Service-Version") != "2.0.0" {
+			t.Errorf("unexpected request %s %v", r.URL.Path, r.Header)
+		}
+		msg, err := transcode.ReadFrame(r.Body)
+		if err != nil {
+			t.Fatalf("read frame: %v", err)
+		}
+		// GetUserRequest{id: 42}
+		if want := []byte{0x08, 42}; string(msg) != string(want) {
+			t.Errorf("unexpected request message % x", msg)
+		}
+		resp, _ := reg.Codec(respDesc).FromJSON([]byte(`{"id":"42","displayName":"Ada"}`))
+		w.Header().Set("Content-Type", "application/grpc+proto")
+		w.Header().Set("Trailer", "Grpc-Status")
+		w.Write(transcode.Frame(resp))
+		w.Header().Set("Grpc-Status", "0")
+	})
+	defer backend.Close()
+
+	compiled, err := Compile(tripleConfig(backend.URL, "protobuf", descPath), 1)
+	if err != nil {
+		t.Fatalf("compile error: %v", err)
+	}
+	route, _ := compiled.Router.Match(httptest.NewRequest("POST", "/api/user", nil))
+	if route.TripleTranscode == nil {
+		t.Fatal("expected triple codecs to be resolved")
+	}
+
+	req := httptest.NewRequest("POST", "/api/user", strings.NewReader(`{"id":42}`))
+	w := httptest.NewRecorder()
+	if err := (&DubboUpstream{}).Handle(w, req, route, compiled.Clusters["users"]); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if w.Code != http.StatusOK || w.Body.String() != `{"id":"42","displayName":"Ada"}` {
+		t.Errorf("unexpected response %d %s", w.Code, w.Body.String())
+	}
+
+	// Invalid JSON for the IDL message is rejected before the call.
+	req = httptest.NewRequest("POST", "/api/user", strings.NewReader(`{"id":"abc"}`))
+	w = httptest.NewRecorder()
+	(&DubboUpstream{}).Handle(w, req, route, compiled.Clusters["users"])
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", w.Code)
+	}
+
+	if _, err := Compile(tripleConfig(backend.URL, "protobuf"), 2); err == nil {
+		t.Error("expected compile error without proto descriptors")
+	}
+}
+
+func TestDubboUpstream_TripleWrapperStatus(t *testing.T) {
+	backend := newGRPCBackend(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/grpc+proto")
+		w.Header().Set("Grpc-Status", "14")
+		w.Header().Set("Grpc-Message", "provider%20draining")
+		w.WriteHeader(http.StatusOK)
+	})
+	defer backend.Close()
+
+	compiled, err := Compile(tripleConfig(backend.URL, ""), 1)
+	if err != nil {
+		t.Fatalf("compile error: %v", err)
+	}
+	route, _ := compiled.Router.Match(httptest.NewRequest("POST", "/api/user", nil))
+	req := httptest.NewRequest("POST", "/api/user", strings.NewReader(`42`))
+	w := httptest.NewRecorder()
+	(&DubboUpstream{}).Handle(w, req, route, compiled.Clusters["users"])
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d: %s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), "provider draining") {
+		t.Errorf("unexpected body %s", w.Body.String())
+	}
+}
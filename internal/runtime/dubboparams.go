@@ -0,0 +1,205 @@
+package runtime
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/oriys/nexus/internal/config"
+)
+
+// DubboParams maps HTTP request inputs to the positional arguments of a Dubbo
+// method.
+type DubboParams struct {
+	// Types holds the Java type of each argument.
+	Types    []string
+	sources  []dubboParamSource
+	template []string // path template segments; "{name}" captures a segment
+}
+
+type dubboParamSource struct {
+	kind string // "path", "query", "header" or "body"
+	name string // body field path, empty for the whole body
+}
+
+// compileDubboParams builds the argument mapping of a Dubbo route, or returns
+// nil when the route sends the request body as the arguments.
+func compileDubboParams(d *config.RouteUpstreamDubbo) (*DubboParams, error) {
+	if len(d.Params) == 0 {
+		return nil, nil
+	}
+	p := &DubboParams{}
+	if d.PathTemplate != "" {
+		p.template = strings.Split(d.PathTemplate, "/")
+	}
+	for i, param := range d.Params {
+		kind, name, _ := strings.Cut(param.From, ".")
+		switch kind {
+		case "path", "query", "header", "body":
+		default:
+			return nil, fmt.Errorf("params[%d]: unsupported source %q", i, param.From)
+		}
+		p.Types = append(p.Types, param.Type)
+		p.sources = append(p.sources, dubboParamSource{kind: kind, name: name})
+	}
+	return p, nil
+}
+
+// Args extracts the arguments from r. The body is read and decoded only when
+// a parameter refers to it. Path, query and header values are strings, which
+// the Hessian encoder converts to the declared numeric or date types.
+func (p *DubboParams) Args(r *http.Request) ([]interface{}, error) {
+	var pathValues map[string]string
+	var body interface{}
+	bodyRead := false
+
+	args := make([]interface{}, len(p.sources))
+	for i, src := range p.sources {
+		typ := p.Types[i]
+		var v interface{}
+		switch src.kind {
+		case "path":
+			if pathValues == nil {
+				var ok bool
+				if pathValues, ok = p.matchPath(r.URL); !ok {
+					return nil, fmt.Errorf("request path does not match %s", strings.Join(p.template, "/"))
+				}
+			}
+			if s, ok := pathValues[src.name]; ok {
+				v = s
+			}
+		case "query":
+			values := r.URL.Query()[src.name]
+			switch {
+			case javaArrayType(typ):
+				list := make([]interface{}, len(values))
+				for j, s := range values {
+					list[j] = s
+				}
+				v = list
+			case len(values) > 0:
+				v = values[0]
+			}
+		case "header":
+			if values := r.Header.Values(src.name); len(values) > 0 {
+				v = values[0]
+			}
+		case "body":
+			if !bodyRead {
+				var err error
+				if body, err = readJSONBody(r); err != nil {
+					return nil, err
+				}
+				bodyRead = true
+			}
+			v = bodyField(body, src.name)
+		}
+
+		if s, ok := v.(string); ok && (typ == "boolean" || typ == "java.lang.Boolean") {
+			b, err := strconv.ParseBool(s)
+			if err != nil {
+				return nil, fmt.Errorf("argument %d: expected boolean, got %q", i, s)
+			}
+			v = b
+		}
+		if v == nil && javaPrimitive(typ) {
+			return nil, fmt.Errorf("argument %d (%s): missing value for %s", i, typ, sourceName(src))
+		}
+		args[i] = v
+	}
+	return args, nil
+}
+
+// matchPath captures the named segments of u's path against the template.
+func (p *DubboParams) matchPath(u *url.URL) (map[string]string, bool) {
+	segments := strings.Split(u.EscapedPath(), "/")
+	if len(segments) != len(p.template) {
+		return nil, false
+	}
+	values := make(map[string]string)
+	for i, seg := range p.template {
+		if name, ok := strings.CutPrefix(seg, "{"); ok && strings.HasSuffix(name, "}") {
+			s, err := url.PathUnescape(segments[i])
+			if err != nil || s == "" {
+				return nil, false
+			}
+			values[strings.TrimSuffix(name, "}")] = s
+			continue
+		}
+		if seg != segments[i] {
+			return nil, false
+		}
+	}
+	return values, true
+}
+
+func sourceName(src dubboParamSource) string {
+	if src.name == "" {
+		return src.kind
+	}
+	return src.kind + "." + src.name
+}
+
+// readJSONBody decodes the request body, keeping numbers as json.Number. An
+// empty body decodes to nil.
+func readJSONBody(r *http.Request) (interface{}, error) {
+	if r.Body == nil {
+		return nil, nil
+	}
+	buf, err := readPooled(r.Body)
+	r.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+	defer putBuffer(buf)
+	if len(bytes.TrimSpace(buf.Bytes())) == 0 {
+		return nil, nil
+	}
+	var body interface{}
+	dec := json.NewDecoder(buf)
+	dec.UseNumber()
+	if err := dec.Decode(&body); err != nil {
+		return nil, fmt.Errorf("request body is not valid JSON: %w", err)
+	}
+	return body, nil
+}
+
+// bodyField returns the value at a dot-separated field path of a decoded JSON
+// body, or nil when any step is missing.
+func bodyField(body interface{}, path string) interface{} {
+	if path == "" {
+		return body
+	}
+	for _, key := range strings.Split(path, ".") {
+		obj, ok := body.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		body = obj[key]
+	}
+	return body
+}
+
+// javaPrimitive reports whether t is a primitive type, which cannot be null.
+func javaPrimitive(t string) bool {
+	switch t {
+	case "boolean", "byte", "short", "int", "long", "float", "double", "char":
+		return true
+	}
+	return false
+}
+
+// javaArrayType reports whether t takes a list of values.
+func javaArrayType(t string) bool {
+	switch t {
+	case "byte[]":
+		return false
+	case "java.util.List", "java.util.ArrayList", "java.util.Collection", "java.util.Set", "java.util.HashSet":
+		return true
+	}
+	return strings.HasSuffix(t, "[]")
+}
@@ -0,0 +1,89 @@
+package runtime
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/oriys/nexus/internal/config"
+)
+
+func TestDubboParams_Args(t *testing.T) {
+	p, err := compileDubboParams(&config.RouteUpstreamDubbo{
+		PathTemplate: "/api/v1/orders/{id}/items",
+		Params: []config.DubboParam{
+			{Type: "long", From: "path.id"},
+			{Type: "java.lang.String", From: "query.note"},
+			{Type: "long[]", From: "query.sku"},
+			{Type: "boolean", From: "query.dry"},
+			{Type: "java.lang.String", From: "header.X-Tenant"},
+			{Type: "int", From: "body.item.qty"},
+			{Type: "com.foo.Item", From: "body"},
+			{Type: "java.lang.Integer", From: "body.missing"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(p.Types, []string{"long", "java.lang.String", "long[]", "boolean", "java.lang.String", "int", "com.foo.Item", "java.lang.Integer"}) {
+		t.Errorf("unexpected types %v", p.Types)
+	}
+
+	req := httptest.NewRequest("POST", "/api/v1/orders/a%2Fb/items?note=hi&sku=1&sku=2&dry=true", strings.NewReader(`{"item":{"qty":3}}`))
+	req.Header.Set("X-Tenant", "acme")
+	args, err := p.Args(req)
+	if err != nil {
+		t.Fatalf("Args: %v", err)
+	}
+	want := []interface{}{
+		"a/b",
+		"hi",
+		[]interface{}{"1", "2"},
+		true,
+		"acme",
+		json.Number("3"),
+		map[string]interface{}{"item": map[string]interface{}{"qty": json.Number("3")}},
+		nil,
+	}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("got %#v\nwant %#v", args, want)
+	}
+}
+
+func TestDubboParams_ArgsErrors(t *testing.T) {
+	p, err := compileDubboParams(&config.RouteUpstreamDubbo{
+		PathTemplate: "/orders/{id}",
+		Params: []config.DubboParam{
+			{Type: "long", From: "path.id"},
+			{Type: "boolean", From: "query.dry"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		target string
+		want   string
+	}{
+		{"/orders/1/extra?dry=true", "does not match"},
+		{"/items/1?dry=true", "does not match"},
+		{"/orders/1?dry=maybe", "expected boolean"},
+		{"/orders/1", "missing value for query.dry"},
+	}
+	for _, tt := range tests {
+		_, err := p.Args(httptest.NewRequest("GET", tt.target, nil))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.target, tt.want, err)
+		}
+	}
+
+	if p, _ := compileDubboParams(&config.RouteUpstreamDubbo{}); p != nil {
+		t.Error("expected nil mapping without params")
+	}
+	if _, err := compileDubboParams(&config.RouteUpstreamDubbo{Params: []config.DubboParam{{Type: "int", From: "cookie.a"}}}); err == nil {
+		t.Error("expected error for unsupported source")
+	}
+}
@@ -0,0 +1,102 @@
+package runtime
+
+import (
+	"sync"
+
+	"github.com/oriys/nexus/internal/dubbo"
+)
+
+// dubboPoolSet hands out one connection pool per set of connection options.
+// Native Dubbo clusters with the same options share a pool, which is kept
+// across config reloads so provider connections stay open.
+type dubboPoolSet struct {
+	mu    sync.Mutex
+	pools map[dubbo.PoolOptions]*dubbo.Pool
+}
+
+var dubboPools = &dubboPoolSet{pools: make(map[dubbo.PoolOptions]*dubbo.Pool)}
+
+func dubboPoolOptions(cc *CompiledCluster) dubbo.PoolOptions {
+	var o dubbo.PoolOptions
+	if d := cc.Dubbo; d != nil {
+		o.Connections = d.Connections
+		o.Heartbeat = d.HeartbeatDuration()
+		o.ReconnectBackoff = d.ReconnectBackoffDuration()
+		o.MaxReconnectBackoff = d.ReconnectBackoffMaxDuration()
+	}
+	return o
+}
+
+// forCluster returns the pool for cc's connection options.
+func (s *dubboPoolSet) forCluster(cc *CompiledCluster) *dubbo.Pool {
+	o := dubboPoolOptions(cc)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.pools[o]
+	if !ok {
+		p = dubbo.NewPool(o)
+		s.pools[o] = p
+	}
+	return p
+}
+
+// retain closes connections to providers that no native Dubbo cluster in
+// clusters uses any more, and pools that no cluster uses at all.
+func (s *dubboPoolSet) retain(clusters map[string]*CompiledCluster) {
+	used := make(map[*dubbo.Pool]map[string]struct{})
+	for _, cc := range clusters {
+		if cc.dubboPool == nil {
+			continue
+		}
+		addrs := used[cc.dubboPool]
+		if addrs == nil {
+			addrs = make(map[string]struct{})
+			used[cc.dubboPool] = addrs
+		}
+		for _, addr := range cc.dubboProviders() {
+			addrs[addr] = struct{}{}
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for o, p := range s.pools {
+		addrs, ok := used[p]
+		if !ok {
+			p.Close()
+			delete(s.pools, o)
+			continue
+		}
+		p.Retain(addrs)
+	}
+}
+
+// dubboProviders returns the provider addresses of the cluster's endpoints.
+func (c *CompiledCluster) dubboProviders() []string {
+	addrs := make([]string, 0, len(c.Endpoints))
+	for _, ep := range c.Endpoints {
+		if addr, err := DubboProviderAddr(EndpointAddress(ep)); err == nil {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
+
+// DubboConnections reports the connection pool state for each provider of a
+// native Dubbo cluster; it returns nil for other clusters.
+func (c *CompiledCluster) DubboConnections() []dubbo.ProviderStats {
+	if !nativeDubbo(c) {
+		return nil
+	}
+	providers := make(map[string]struct{})
+	for _, addr := range c.dubboProviders() {
+		providers[addr] = struct{}{}
+	}
+	stats := make([]dubbo.ProviderStats, 0, len(providers))
+	for _, s := range c.dubboClients().Stats() {
+		if _, ok := providers[s.Addr]; ok {
+			stats = append(stats, s)
+		}
+	}
+	return stats
+}
@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/oriys/nexus/internal/config"
+	"github.com/oriys/nexus/internal/pathmatch"
 )
 
 // Filter is the interface for request/response filters.
@@ -19,8 +20,10 @@ type FilterRegistry struct {
 	factories map[string]FilterFactory
 }
 
-// FilterFactory creates a Filter from pre-parsed arguments.
-type FilterFactory func(args map[string]string) (Filter, error)
+// FilterFactory creates a Filter from its arguments. It rejects arguments
+// it does not know and arguments of the wrong type, so that a bad filter
+// fails when the config is compiled rather than when a request runs it.
+type FilterFactory func(args config.FilterArgs) (Filter, error)
 
 // NewFilterRegistry creates a new FilterRegistry with built-in filters.
 func NewFilterRegistry() *FilterRegistry {
@@ -29,6 +32,23 @@ func NewFilterRegistry() *FilterRegistry {
 	}
 	fr.Register("strip_prefix", newStripPrefixFilter)
 	fr.Register("header_set", newHeaderSetFilter)
+	fr.Register("rewrite_path", newRewritePathFilter)
+	fr.Register("regex_rewrite", newRegexRewriteFilter)
+	fr.Register("body_transform", newBodyTransformFilter)
+	fr.Register("max_body", newMaxBodyFilter)
+	fr.Register("decompress", newDecompressFilter)
+	fr.Register("response_header_add", newResponseHeaderAddFilter)
+	fr.Register("response_header_set", newResponseHeaderSetFilter)
+	fr.Register("response_header_remove", newResponseHeaderRemoveFilter)
+	fr.Register("response_status", newResponseStatusFilter)
+	fr.Register("response_body_replace", newResponseBodyReplaceFilter)
+	fr.Register("response_json_fields", newResponseJSONFieldsFilter)
+	fr.Register("compress", newCompressFilter)
+	fr.Register("bandwidth_limit", newBandwidthLimitFilter)
+	fr.Register("security_headers", newSecurityHeadersFilter)
+	fr.Register("csrf", newCSRFFilter)
+	fr.Register("ip_acl", newIPACLFilter)
+	fr.Register("sign_request", newSignRequestFilter)
 	return fr
 }
 
@@ -51,14 +71,28 @@ type stripPrefixFilter struct {
 	prefix string
 }
 
-func newStripPrefixFilter(args map[string]string) (Filter, error) {
-	prefix := args["prefix"]
-	if prefix == "" {
-		return nil, fmt.Errorf("strip_prefix filter requires 'prefix' argument")
+func newStripPrefixFilter(args config.FilterArgs) (Filter, error) {
+	prefix, err := requiredArg(args, "prefix")
+	if err != nil {
+		return nil, fmt.Errorf("strip_prefix filter: %w", err)
 	}
 	return &stripPrefixFilter{prefix: prefix}, nil
 }
 
+// requiredArg checks that args holds only the named arguments and returns
+// the first of them, which must be a non-empty string. The others are
+// optional.
+func requiredArg(args config.FilterArgs, name string, optional ...string) (string, error) {
+	if err := args.Check(append([]string{name}, optional...)...); err != nil {
+		return "", err
+	}
+	v, err := args.String(name)
+	if err == nil && v == "" {
+		err = fmt.Errorf("'%s' argument is required", name)
+	}
+	return v, err
+}
+
 func (f *stripPrefixFilter) Apply(r *http.Request) error {
 	if strings.HasPrefix(r.URL.Path, f.prefix) {
 		newPath := strings.TrimPrefix(r.URL.Path, f.prefix)
@@ -71,22 +105,74 @@ func (f *stripPrefixFilter) Apply(r *http.Request) error {
 	return nil
 }
 
-// headerSetFilter sets a header on the request.
+// rewritePathFilter replaces the request path. Path parameters captured by
+// the route match are substituted, e.g. "/internal/users/{id}".
+type rewritePathFilter struct {
+	path string
+}
+
+func newRewritePathFilter(args config.FilterArgs) (Filter, error) {
+	path, err := requiredArg(args, "path")
+	if err != nil {
+		return nil, fmt.Errorf("rewrite_path filter: %w", err)
+	}
+	return &rewritePathFilter{path: path}, nil
+}
+
+func (f *rewritePathFilter) Apply(r *http.Request) error {
+	r.URL.Path = pathmatch.Expand(f.path, pathmatch.Params(r.Context()))
+	r.URL.RawPath = ""
+	return nil
+}
+
+// regexRewriteFilter rewrites the request path with a regular expression,
+// substituting its capture groups, e.g. "^/api/v(\d+)/(.*)$" →
+// "/internal/$2?ver=$1".
+type regexRewriteFilter struct {
+	rw *pathmatch.Rewrite
+}
+
+func newRegexRewriteFilter(args config.FilterArgs) (Filter, error) {
+	pattern, err := requiredArg(args, "pattern", "substitution")
+	if err != nil {
+		return nil, fmt.Errorf("regex_rewrite filter: %w", err)
+	}
+	substitution, err := args.String("substitution")
+	if err != nil {
+		return nil, fmt.Errorf("regex_rewrite filter: %w", err)
+	}
+	rw, err := pathmatch.CompileRewrite(pattern, substitution)
+	if err != nil {
+		return nil, fmt.Errorf("regex_rewrite filter: %w", err)
+	}
+	return &regexRewriteFilter{rw: rw}, nil
+}
+
+func (f *regexRewriteFilter) Apply(r *http.Request) error {
+	f.rw.Apply(r.URL)
+	return nil
+}
+
+// headerSetFilter sets a header on the request. Path parameters in the value
+// are substituted.
 type headerSetFilter struct {
 	key   string
 	value string
 }
 
-func newHeaderSetFilter(args map[string]string) (Filter, error) {
-	key := args["key"]
-	value := args["value"]
-	if key == "" {
-		return nil, fmt.Errorf("header_set filter requires 'key' argument")
+func newHeaderSetFilter(args config.FilterArgs) (Filter, error) {
+	key, err := requiredArg(args, "key", "value")
+	if err != nil {
+		return nil, fmt.Errorf("header_set filter: %w", err)
+	}
+	value, err := args.String("value")
+	if err != nil {
+		return nil, fmt.Errorf("header_set filter: %w", err)
 	}
 	return &headerSetFilter{key: key, value: value}, nil
 }
 
 func (f *headerSetFilter) Apply(r *http.Request) error {
-	r.Header.Set(f.key, f.value)
+	r.Header.Set(f.key, pathmatch.Expand(f.value, pathmatch.Params(r.Context())))
 	return nil
 }
@@ -2,9 +2,11 @@ package runtime
 
 import (
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/oriys/nexus/internal/config"
+	"github.com/oriys/nexus/internal/pathmatch"
 )
 
 func TestStripPrefixFilter(t *testing.T) {
@@ -22,7 +24,7 @@ func TestStripPrefixFilter(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			f, err := newStripPrefixFilter(map[string]string{"prefix": tt.prefix})
+			f, err := newStripPrefixFilter(config.FilterArgs{"prefix": tt.prefix})
 			if err != nil {
 				t.Fatalf("unexpected error: %v", err)
 			}
@@ -40,14 +42,14 @@ func TestStripPrefixFilter(t *testing.T) {
 }
 
 func TestStripPrefixFilter_MissingArg(t *testing.T) {
-	_, err := newStripPrefixFilter(map[string]string{})
+	_, err := newStripPrefixFilter(config.FilterArgs{})
 	if err == nil {
 		t.Fatal("expected error for missing prefix arg")
 	}
 }
 
 func TestHeaderSetFilter(t *testing.T) {
-	f, err := newHeaderSetFilter(map[string]string{"key": "X-Gateway", "value": "nexus"})
+	f, err := newHeaderSetFilter(config.FilterArgs{"key": "X-Gateway", "value": "nexus"})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -63,7 +65,7 @@ func TestHeaderSetFilter(t *testing.T) {
 }
 
 func TestHeaderSetFilter_Overwrite(t *testing.T) {
-	f, err := newHeaderSetFilter(map[string]string{"key": "Content-Type", "value": "application/json"})
+	f, err := newHeaderSetFilter(config.FilterArgs{"key": "Content-Type", "value": "application/json"})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -81,7 +83,7 @@ func TestHeaderSetFilter_Overwrite(t *testing.T) {
 }
 
 func TestHeaderSetFilter_MissingKey(t *testing.T) {
-	_, err := newHeaderSetFilter(map[string]string{"value": "test"})
+	_, err := newHeaderSetFilter(config.FilterArgs{"value": "test"})
 	if err == nil {
 		t.Fatal("expected error for missing key arg")
 	}
@@ -93,7 +95,7 @@ func TestFilterRegistry_Compile(t *testing.T) {
 	// Test strip_prefix
 	f, err := fr.Compile(config.RouteFilter{
 		Type: "strip_prefix",
-		Args: map[string]string{"prefix": "/api"},
+		Args: config.FilterArgs{"prefix": "/api"},
 	})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
@@ -110,7 +112,7 @@ func TestFilterRegistry_Compile(t *testing.T) {
 	// Test header_set
 	f, err = fr.Compile(config.RouteFilter{
 		Type: "header_set",
-		Args: map[string]string{"key": "X-Test", "value": "hello"},
+		Args: config.FilterArgs{"key": "X-Test", "value": "hello"},
 	})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
@@ -133,6 +135,49 @@ func TestFilterRegistry_UnknownType(t *testing.T) {
 	}
 }
 
+func TestFilterRegistry_ArgErrors(t *testing.T) {
+	fr := NewFilterRegistry()
+	tests := []struct {
+		filter config.RouteFilter
+		error  string
+	}{
+		{config.RouteFilter{Type: "strip_prefix", Args: config.FilterArgs{"prefix": "/a", "prefx": "/b"}}, `unknown argument "prefx"`},
+		{config.RouteFilter{Type: "header_set", Args: config.FilterArgs{"key": "X-A", "value": []any{"a"}}}, "'value' argument must be a string"},
+		{config.RouteFilter{Type: "response_header_remove", Args: config.FilterArgs{"key": "X-A", "value": "a"}}, `unknown argument "value"`},
+		{config.RouteFilter{Type: "response_status", Args: config.FilterArgs{"status": "ok"}}, "'status' argument must be an integer"},
+		{config.RouteFilter{Type: "compress", Args: config.FilterArgs{"level": true}}, "'level' must be between 1 and 9"},
+		{config.RouteFilter{Type: "csrf", Args: config.FilterArgs{"secure": "sometimes"}}, "'secure' argument must be true or false"},
+		{config.RouteFilter{Type: "max_body", Args: config.FilterArgs{"size": map[string]any{}}}, "'size' argument must be a size"},
+		{config.RouteFilter{Type: "security_headers", Args: config.FilterArgs{"hst": "max-age=60"}}, `unknown argument "hst"`},
+		// Typed values
+		{config.RouteFilter{Type: "compress", Args: config.FilterArgs{"encodings": []any{"GZIP"}, "level": 9, "min_size": float64(512)}}, ""},
+		{config.RouteFilter{Type: "csrf", Args: config.FilterArgs{"secure": false}}, ""},
+		{config.RouteFilter{Type: "response_status", Args: config.FilterArgs{"from": 404, "status": float64(200)}}, ""},
+		{config.RouteFilter{Type: "header_set", Args: config.FilterArgs{"key": "X-Version", "value": 2}}, ""},
+	}
+	for _, tt := range tests {
+		_, err := fr.Compile(tt.filter)
+		if tt.error == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", tt.filter.Type, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.error) {
+			t.Errorf("%s: error = %v, want %q", tt.filter.Type, err, tt.error)
+		}
+	}
+
+	f, _ := fr.Compile(config.RouteFilter{Type: "csrf", Args: config.FilterArgs{"secure": false}})
+	if f.(*csrfFilter).secure {
+		t.Error("csrf secure = true, want false")
+	}
+	f, _ = fr.Compile(config.RouteFilter{Type: "response_status", Args: config.FilterArgs{"from": 404, "status": float64(200)}})
+	if sf := f.(*responseStatusFilter); sf.from != 404 || sf.status != 200 {
+		t.Errorf("response_status = %+v", sf)
+	}
+}
+
 func TestFilterChain_Integration(t *testing.T) {
 	cfg := &config.Config{
 		Clusters: []config.Cluster{
@@ -146,8 +191,8 @@ func TestFilterChain_Integration(t *testing.T) {
 					PathPrefix: "/api/v1/http/",
 				},
 				Filters: []config.RouteFilter{
-					{Type: "strip_prefix", Args: map[string]string{"prefix": "/api/v1/http"}},
-					{Type: "header_set", Args: map[string]string{"key": "x-gw", "value": "nexus"}},
+					{Type: "strip_prefix", Args: config.FilterArgs{"prefix": "/api/v1/http"}},
+					{Type: "header_set", Args: config.FilterArgs{"key": "x-gw", "value": "nexus"}},
 				},
 				Upstream: config.RouteUpstream{Cluster: "backend"},
 			},
@@ -179,3 +224,45 @@ func TestFilterChain_Integration(t *testing.T) {
 		t.Errorf("expected x-gw=nexus, got %s", req.Header.Get("x-gw"))
 	}
 }
+
+func TestRewritePathFilter(t *testing.T) {
+	f, err := newRewritePathFilter(config.FilterArgs{"path": "/internal/users/{id}"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	req := httptest.NewRequest("GET", "/users/42", nil)
+	req = req.WithContext(pathmatch.WithParams(req.Context(), map[string]string{"id": "42"}))
+	if err := f.Apply(req); err != nil {
+		t.Fatalf("apply error: %v", err)
+	}
+	if req.URL.Path != "/internal/users/42" {
+		t.Errorf("expected /internal/users/42, got %s", req.URL.Path)
+	}
+
+	if _, err := newRewritePathFilter(config.FilterArgs{}); err == nil {
+		t.Fatal("expected error for missing path arg")
+	}
+}
+
+func TestRegexRewriteFilter(t *testing.T) {
+	f, err := newRegexRewriteFilter(config.FilterArgs{"pattern": `^/api/v(\d+)/(.*)$`, "substitution": "/internal/$2?ver=$1"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	req := httptest.NewRequest("GET", "/api/v1/orders/7", nil)
+	if err := f.Apply(req); err != nil {
+		t.Fatalf("apply error: %v", err)
+	}
+	if req.URL.Path != "/internal/orders/7" || req.URL.RawQuery != "ver=1" {
+		t.Errorf("expected /internal/orders/7?ver=1, got %s", req.URL.RequestURI())
+	}
+
+	if _, err := newRegexRewriteFilter(config.FilterArgs{}); err == nil {
+		t.Fatal("expected error for missing pattern arg")
+	}
+	if _, err := newRegexRewriteFilter(config.FilterArgs{"pattern": "("}); err == nil {
+		t.Fatal("expected error for invalid pattern")
+	}
+}
@@ -1,64 +1,268 @@
 package runtime
 
 import (
+	"errors"
 	"log/slog"
 	"net/http"
+	"strings"
+	"time"
+
+	"github.com/oriys/nexus/internal/httperror"
+	"github.com/oriys/nexus/internal/pathmatch"
+	"github.com/oriys/nexus/internal/plugin"
+	"github.com/oriys/nexus/internal/reqctx"
+)
+
+// Orders of the gateway's own plugins. Custom plugins run between the
+// route filters and the upstream (external plugins default to order 50),
+// so they see the matched route and the filtered request.
+const (
+	routeMatchOrder   = 10
+	routeFiltersOrder = 20
+	upstreamOrder     = 100
 )
 
+// exchangeKey holds the gateway state of a request.
+var exchangeKey = reqctx.NewKey[*exchange]("exchange")
+
+// exchange is the state the gateway plugins share for a request.
+type exchange struct {
+	cfg   *CompiledConfig
+	route *CompiledRoute
+	// stats wraps the response writer once a route is matched.
+	stats routeStatsWriter
+}
+
+// exchangeOf returns the gateway state of ctx, nil outside a Gateway.
+func exchangeOf(ctx *plugin.GatewayContext) *exchange {
+	ex, _ := exchangeKey.Get(ctx.Request.Context())
+	return ex
+}
+
 // Gateway is the main request handler that uses CompiledConfig for routing.
+// Requests run through a plugin chain: route matching, route filters and
+// the upstream are plugins of the chain, and custom plugins run in between.
 type Gateway struct {
-	store      *ConfigStore
-	dispatcher *UpstreamDispatcher
+	store *ConfigStore
+	chain *plugin.Chain
 }
 
-// NewGateway creates a new Gateway handler.
-func NewGateway(store *ConfigStore) *Gateway {
+// NewGateway creates a new Gateway handler running plugins in its chain
+// alongside its own.
+func NewGateway(store *ConfigStore, plugins ...plugin.Plugin) *Gateway {
+	all := []plugin.Plugin{
+		routeMatchPlugin{},
+		routeFiltersPlugin{},
+		&upstreamPlugin{dispatcher: NewUpstreamDispatcher()},
+	}
 	return &Gateway{
-		store:      store,
-		dispatcher: NewUpstreamDispatcher(),
+		store: store,
+		chain: plugin.NewChain(append(all, plugins...)...),
 	}
 }
 
+// Chain returns the plugin chain requests run through.
+func (g *Gateway) Chain() *plugin.Chain {
+	return g.chain
+}
+
 // ServeHTTP handles incoming requests using the compiled configuration.
 func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	cfg := g.store.Load()
 	if cfg == nil {
-		http.Error(w, "gateway not configured", http.StatusServiceUnavailable)
+		httperror.Write(w, r, http.StatusServiceUnavailable, httperror.CodeNotConfigured, "gateway not configured")
 		return
 	}
 
-	// Match route
-	route, matched := cfg.Router.Match(r)
+	// The configuration is loaded once, so that every plugin of the request
+	// sees the same one across a reload.
+	r, values := reqctx.AttachRequest(r)
+	reqctx.SetOriginalPath(r)
+	reqctx.Set(values, exchangeKey, &exchange{cfg: cfg})
+	ctx := &plugin.GatewayContext{
+		Request:        r,
+		ResponseWriter: w,
+		Attributes:     make(map[string]interface{}),
+	}
+	if err := g.chain.Execute(ctx); err != nil {
+		slog.Error("plugin chain error",
+			slog.String("path", reqctx.OriginalPathOf(r)),
+			slog.String("method", r.Method),
+			slog.String("error", err.Error()),
+		)
+		httperror.Write(w, r, http.StatusInternalServerError, httperror.CodeInternal, "internal server error")
+	}
+}
+
+// routeMatchPlugin matches the request against the routes, setting the rule
+// of the context. Requests no route matches are answered here.
+type routeMatchPlugin struct{}
+
+func (routeMatchPlugin) Name() string { return "route_match" }
+func (routeMatchPlugin) Order() int   { return routeMatchOrder }
+
+func (routeMatchPlugin) Execute(ctx *plugin.GatewayContext, next func()) error {
+	ex := exchangeOf(ctx)
+	if ex == nil {
+		return errors.New("route_match runs only in a gateway")
+	}
+	w, r := ctx.ResponseWriter, ctx.Request
+	cfg := ex.cfg
+
+	cfg.Router.Normalize(r)
+	route, params, matched := cfg.Router.MatchParams(r)
 	if !matched {
-		http.Error(w, "no matching route", http.StatusNotFound)
-		return
+		if allowed := cfg.Router.AllowedMethods(r); allowed != nil {
+			w.Header().Set("Allow", strings.Join(allowed, ", "))
+			httperror.Write(w, r, http.StatusMethodNotAllowed, httperror.CodeMethodNotAllowed, "method not allowed")
+			return nil
+		}
+		if cfg.Fallback == nil {
+			cfg.writeNotFound(w, r)
+			return nil
+		}
+		route = cfg.Fallback
+	}
+	if params != nil {
+		ctx.Request = r.WithContext(pathmatch.WithParams(r.Context(), params))
+	}
+	ex.route = route
+	reqctx.Route.Set(r.Context(), route.Name)
+	counters, start := route.counters(), time.Now()
+	counters.begin()
+	ex.stats.ResponseWriter = w
+	w = &ex.stats
+	ctx.ResponseWriter = w
+	defer func() { counters.record(ex.stats.status, time.Since(start)) }()
+	if route.Maintenance != nil {
+		route.Maintenance.ServeHTTP(ctx.ResponseWriter, r)
+		return nil
+	}
+	shedder := cfg.LoadShedder
+	if shedder == nil && degraded.Load() {
+		shedder = degradedShedder
 	}
+	if shedder != nil {
+		done, ok := shedder.Admit(r, route)
+		if !ok {
+			writeOverloaded(w, r)
+			return nil
+		}
+		defer done()
+	}
+	ctx.Rule = &plugin.RuleData{Name: route.Name, Upstream: route.Upstream.ClusterName, Host: r.Host}
+	next()
+	return nil
+}
+
+// routeFiltersPlugin applies the filters of the matched route.
+type routeFiltersPlugin struct{}
 
-	// Apply filters
+func (routeFiltersPlugin) Name() string { return "route_filters" }
+func (routeFiltersPlugin) Order() int   { return routeFiltersOrder }
+
+func (routeFiltersPlugin) Execute(ctx *plugin.GatewayContext, next func()) error {
+	ex := exchangeOf(ctx)
+	if ex == nil || ex.route == nil {
+		next()
+		return nil
+	}
+	route := ex.route
 	for _, f := range route.Filters {
-		if err := f.Apply(r); err != nil {
+		if err := f.Apply(ctx.Request); err != nil {
+			var se *StatusError
+			if errors.As(err, &se) {
+				httperror.Write(ctx.ResponseWriter, ctx.Request, se.Status, httperror.CodeFor(se.Status), se.Message)
+				return nil
+			}
 			slog.Error("filter error",
 				slog.String("route", route.Name),
 				slog.String("error", err.Error()),
 			)
-			http.Error(w, "filter error", http.StatusBadRequest)
-			return
+			httperror.Write(ctx.ResponseWriter, ctx.Request, http.StatusBadRequest, "filter_error", "filter error")
+			return nil
 		}
 	}
+	next()
+	return nil
+}
+
+// upstreamPlugin is the terminal plugin: it answers mock routes and
+// dispatches the others to their cluster.
+type upstreamPlugin struct {
+	dispatcher *UpstreamDispatcher
+}
+
+func (*upstreamPlugin) Name() string { return "upstream" }
+func (*upstreamPlugin) Order() int   { return upstreamOrder }
+
+func (p *upstreamPlugin) Execute(ctx *plugin.GatewayContext, next func()) error {
+	ex := exchangeOf(ctx)
+	if ex == nil {
+		return errors.New("upstream runs only in a gateway")
+	}
+	w, r, route := ctx.ResponseWriter, ctx.Request, ex.route
+	if route == nil {
+		// route_match is disabled
+		ex.cfg.writeNotFound(w, r)
+		return nil
+	}
+
+	if route.Mock != nil {
+		route.Mock.serve(w, r, route)
+		return nil
+	}
 
 	// Find cluster
-	cluster, ok := cfg.Clusters[route.Upstream.ClusterName]
+	name := route.Upstream.Cluster(r)
+	if t, ok := reqctx.Tenant.Get(r.Context()); ok {
+		if c, ok := ex.cfg.TenantClusters[t][name]; ok {
+			name = c
+		}
+	}
+	if ctx.Rule != nil {
+		ctx.Rule.Upstream = name
+	}
+	cluster, ok := ex.cfg.Clusters[name]
 	if !ok {
 		slog.Error("cluster not found",
 			slog.String("route", route.Name),
-			slog.String("cluster", route.Upstream.ClusterName),
+			slog.String("cluster", name),
 		)
-		http.Error(w, "upstream not available", http.StatusBadGateway)
-		return
+		httperror.Write(w, r, http.StatusBadGateway, httperror.CodeUpstreamUnavailable, "upstream not available")
+		return nil
+	}
+
+	if c := route.Cache; c != nil {
+		var done func()
+		if w, done, ok = c.serve(w, r); !ok {
+			return nil
+		}
+		defer done()
+	}
+
+	if c := route.Idempotency; c != nil {
+		var done func()
+		if w, done, ok = c.serve(w, r, route.Name); !ok {
+			return nil
+		}
+		defer done()
+	}
+
+	if m := route.Upstream.Mirror; m != nil {
+		if shadow, ok := ex.cfg.Clusters[m.Cluster]; ok {
+			m.send(r, route, shadow)
+		}
+	}
+
+	if o := route.Upstream.Outcomes; o != nil {
+		ow := &outcomeWriter{ResponseWriter: w}
+		w = ow
+		defer func() { o.record(ow.status) }()
 	}
 
 	// Dispatch to upstream
-	if err := g.dispatcher.Dispatch(w, r, route, cluster); err != nil {
+	if err := p.dispatcher.Dispatch(w, r, route, cluster); err != nil {
 		slog.Error("upstream dispatch error",
 			slog.String("route", route.Name),
 			slog.String("cluster", cluster.Name),
@@ -66,4 +270,22 @@ func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		)
 		// The HTTP error response is written by the upstream's ErrorHandler
 	}
+	// Terminal plugin — do not call next().
+	return nil
+}
+
+// writeNotFound answers a request no route matches, with the fallback
+// response when one is configured.
+func (c *CompiledConfig) writeNotFound(w http.ResponseWriter, r *http.Request) {
+	if c.NotFoundBody == nil {
+		status := c.NotFoundStatus
+		if status == 0 {
+			status = http.StatusNotFound
+		}
+		httperror.Write(w, r, status, httperror.CodeNoRoute, "no matching route")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(c.NotFoundStatus)
+	w.Write(c.NotFoundBody)
 }
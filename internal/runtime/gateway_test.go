@@ -0,0 +1,245 @@
+package runtime
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/oriys/nexus/internal/config"
+	"github.com/oriys/nexus/internal/middleware"
+	"github.com/oriys/nexus/internal/plugin"
+	"github.com/oriys/nexus/internal/reqctx"
+)
+
+// denyPlugin answers requests carrying X-Deny with 403 and records the rule,
+// configuration and original path of the others.
+type denyPlugin struct {
+	rules   []string
+	configs []map[string]string
+	paths   []string
+}
+
+func (p *denyPlugin) Name() string { return "deny" }
+func (p *denyPlugin) Order() int   { return 50 }
+func (p *denyPlugin) Execute(ctx *plugin.GatewayContext, next func()) error {
+	if ctx.Request.Header.Get("X-Deny") != "" {
+		http.Error(ctx.ResponseWriter, "denied", http.StatusForbidden)
+		return nil
+	}
+	if route, _ := reqctx.Route.Get(ctx.Request.Context()); route != ctx.Rule.Name {
+		return errors.New("reqctx route " + route + " differs from rule " + ctx.Rule.Name)
+	}
+	p.rules = append(p.rules, ctx.Rule.Name)
+	p.configs = append(p.configs, ctx.Config)
+	p.paths = append(p.paths, reqctx.OriginalPathOf(ctx.Request))
+	next()
+	return nil
+}
+
+func TestGateway_Plugins(t *testing.T) {
+	var gotPath string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+	}))
+	defer backend.Close()
+
+	cfg := &config.Config{
+		Clusters: []config.Cluster{
+			{Name: "backend", Type: "http", Endpoints: []config.ClusterEndpoint{{URL: backend.URL}}},
+		},
+		RoutesV2: []config.RouteV2{
+			{
+				Name:     "orders",
+				Match:    config.RouteMatch{PathPrefix: "/orders"},
+				Filters:  []config.RouteFilter{{Type: "strip_prefix", Args: config.FilterArgs{"prefix": "/orders"}}},
+				Upstream: config.RouteUpstream{Cluster: "backend"},
+			},
+			{
+				Name:     "users",
+				Match:    config.RouteMatch{PathPrefix: "/users"},
+				Upstream: config.RouteUpstream{Cluster: "backend"},
+			},
+		},
+	}
+	compiled, err := Compile(cfg, 1)
+	if err != nil {
+		t.Fatalf("compile error: %v", err)
+	}
+	store := NewConfigStore()
+	store.Store(compiled)
+	deny := &denyPlugin{}
+	gw := NewGateway(store, deny)
+
+	serve := func(path string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		rec := httptest.NewRecorder()
+		gw.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// The plugin runs between the route filters and the upstream.
+	if rec := serve("/orders/7", nil); rec.Code != http.StatusOK || gotPath != "/7" {
+		t.Fatalf("status %d, backend path %q", rec.Code, gotPath)
+	}
+	if len(deny.rules) != 1 || deny.rules[0] != "orders" {
+		t.Errorf("plugin saw rules %v", deny.rules)
+	}
+	// Past strip_prefix, the plugin still sees the path the client sent.
+	if deny.paths[0] != "/orders/7" {
+		t.Errorf("plugin saw original path %q", deny.paths[0])
+	}
+	if rec := serve("/orders/7", http.Header{"X-Deny": {"1"}}); rec.Code != http.StatusForbidden {
+		t.Errorf("expected 403, got %d", rec.Code)
+	}
+	// Unmatched requests do not reach it.
+	if rec := serve("/missing", nil); rec.Code != http.StatusNotFound || len(deny.rules) != 1 {
+		t.Errorf("status %d, plugin calls %d", rec.Code, len(deny.rules))
+	}
+
+	// Bound to a route, it runs only there, with the bound configuration.
+	gw.Chain().Configure([]config.PluginSetting{{
+		Name:   "deny",
+		Routes: []config.PluginBinding{{Route: "users", Config: map[string]string{"mode": "strict"}}},
+	}})
+	if rec := serve("/orders/7", http.Header{"X-Deny": {"1"}}); rec.Code != http.StatusOK {
+		t.Errorf("unbound route: expected 200, got %d", rec.Code)
+	}
+	if rec := serve("/users/1", http.Header{"X-Deny": {"1"}}); rec.Code != http.StatusForbidden {
+		t.Errorf("bound route: expected 403, got %d", rec.Code)
+	}
+	serve("/users/1", nil)
+	if n := len(deny.configs); n != 2 || deny.configs[n-1]["mode"] != "strict" {
+		t.Errorf("plugin saw configs %v", deny.configs)
+	}
+}
+
+func TestGateway_TenantClusters(t *testing.T) {
+	backend := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(name))
+		}))
+	}
+	shared, dedicated := backend("shared"), backend("dedicated")
+	defer shared.Close()
+	defer dedicated.Close()
+
+	cfg := &config.Config{
+		Clusters: []config.Cluster{
+			{Name: "orders", Type: "http", Endpoints: []config.ClusterEndpoint{{URL: shared.URL}}},
+			{Name: "orders-acme", Type: "http", Endpoints: []config.ClusterEndpoint{{URL: dedicated.URL}}},
+		},
+		RoutesV2: []config.RouteV2{{Name: "orders", Match: config.RouteMatch{PathPrefix: "/orders"}, Upstream: config.RouteUpstream{Cluster: "orders"}}},
+		Tenancy: &config.Tenancy{
+			Header:  "X-Tenant",
+			Tenants: []config.Tenant{{Name: "acme", Clusters: map[string]string{"orders": "orders-acme"}}},
+		},
+	}
+	compiled, err := Compile(cfg, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := NewConfigStore()
+	store.Store(compiled)
+	gw := NewGateway(store)
+
+	for tenant, want := range map[string]string{"acme": "dedicated", "globex": "shared", "": "shared"} {
+		req := httptest.NewRequest("GET", "/orders", nil)
+		req, _ = reqctx.AttachRequest(req)
+		if tenant != "" {
+			reqctx.Tenant.Set(req.Context(), tenant)
+		}
+		rec := httptest.NewRecorder()
+		gw.ServeHTTP(rec, req)
+		if rec.Body.String() != want {
+			t.Errorf("tenant %q served by %q, want %q", tenant, rec.Body.String(), want)
+		}
+	}
+}
+
+func TestGateway_ErrorResponses(t *testing.T) {
+	down := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	down.Close()
+	cfg := &config.Config{
+		Clusters: []config.Cluster{{Name: "down", Type: "http", Endpoints: []config.ClusterEndpoint{{URL: down.URL}}}},
+		RoutesV2: []config.RouteV2{{Name: "orders", Match: config.RouteMatch{PathPrefix: "/orders", Methods: []string{"GET"}}, Upstream: config.RouteUpstream{Cluster: "down"}}},
+	}
+	compiled, err := Compile(cfg, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := NewConfigStore()
+	store.Store(compiled)
+	gw := NewGateway(store)
+
+	for _, tt := range []struct {
+		method, path string
+		status       int
+		body         string
+	}{
+		{"GET", "/orders", http.StatusBadGateway, `{"error":"bad_gateway","message":"bad gateway","request_id":"req-1","route":"orders"}`},
+		{"POST", "/orders", http.StatusMethodNotAllowed, `{"error":"method_not_allowed","message":"method not allowed","request_id":"req-1"}`},
+		{"GET", "/users", http.StatusNotFound, `{"error":"no_route","message":"no matching route","request_id":"req-1"}`},
+	} {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		req.Header.Set("X-Request-ID", "req-1")
+		rec := httptest.NewRecorder()
+		gw.ServeHTTP(rec, req)
+		if rec.Code != tt.status || rec.Header().Get("Content-Type") != "application/json" || rec.Body.String() != tt.body+"\n" {
+			t.Errorf("%s %s: got %d %q %s", tt.method, tt.path, rec.Code, rec.Header().Get("Content-Type"), rec.Body)
+		}
+	}
+}
+
+func TestGateway_AccessLog(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("order 7"))
+	}))
+	defer backend.Close()
+
+	cfg := &config.Config{
+		Clusters: []config.Cluster{{Name: "backend", Type: "http", Endpoints: []config.ClusterEndpoint{{URL: backend.URL}}}},
+		RoutesV2: []config.RouteV2{{
+			Name:     "orders",
+			Match:    config.RouteMatch{PathPrefix: "/orders"},
+			Filters:  []config.RouteFilter{{Type: "strip_prefix", Args: config.FilterArgs{"prefix": "/orders"}}},
+			Upstream: config.RouteUpstream{Cluster: "backend"},
+		}},
+	}
+	compiled, err := Compile(cfg, 1)
+	if err != nil {
+		t.Fatalf("compile error: %v", err)
+	}
+	store := NewConfigStore()
+	store.Store(compiled)
+
+	var buf bytes.Buffer
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+	h := middleware.Chain(NewGateway(store), middleware.RequestID(), middleware.Logging())
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/orders/7", nil))
+
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("log entry %q: %v", buf.String(), err)
+	}
+	want := map[string]any{
+		"path":          "/orders/7",
+		"upstream_path": "/7",
+		"route":         "orders",
+		"upstream":      backend.URL,
+		"status":        float64(http.StatusOK),
+		"bytes":         float64(len("order 7")),
+	}
+	for k, v := range want {
+		if entry[k] != v {
+			t.Errorf("%s = %v, want %v", k, entry[k], v)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package runtime
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/oriys/nexus/internal/config"
+	"github.com/oriys/nexus/internal/graphql"
+)
+
+// GraphQLAllowlist is the set of operation names and query hashes a GraphQL
+// route executes.
+type GraphQLAllowlist struct {
+	operations map[string]struct{}
+	hashes     map[string]struct{} // lowercase hex SHA-256
+}
+
+func newGraphQLAllowlist(cfg *config.GraphQLAllowlist) *GraphQLAllowlist {
+	a := &GraphQLAllowlist{
+		operations: make(map[string]struct{}, len(cfg.Operations)),
+		hashes:     make(map[string]struct{}, len(cfg.Hashes)),
+	}
+	for _, name := range cfg.Operations {
+		a.operations[name] = struct{}{}
+	}
+	for _, h := range cfg.Hashes {
+		a.hashes[strings.ToLower(h)] = struct{}{}
+	}
+	return a
+}
+
+// Allows reports whether the operation named name, or the query document
+// with the given hash, may be executed. A nil allowlist allows everything.
+func (a *GraphQLAllowlist) Allows(name, hash string) bool {
+	if a == nil {
+		return true
+	}
+	if _, ok := a.hashes[hash]; ok && hash != "" {
+		return true
+	}
+	_, ok := a.operations[name]
+	return ok && name != ""
+}
+
+// AllowsHash reports whether the query document with the given hash may be
+// executed without inspecting it.
+func (a *GraphQLAllowlist) AllowsHash(hash string) bool {
+	if a == nil {
+		return true
+	}
+	_, ok := a.hashes[hash]
+	return ok && hash != ""
+}
+
+// graphqlCall is the GraphQL request carried by an HTTP request.
+type graphqlCall struct {
+	req *graphql.Request
+	// doc is the parsed query; nil along with op.
+	doc *graphql.Document
+	// op is the operation to execute; nil for a persisted query whose text
+	// the gateway does not know.
+	op *graphql.Operation
+}
+
+// prepareGraphQLRequest validates the GraphQL request carried by r and
+// applies the persisted query, introspection and allowlist settings of a
+// GraphQL route to it before it is proxied. The body must fit the cluster's
+// max_body_bytes and hold a well-formed GraphQL request. It writes a GraphQL
+// error response and returns false when the request is rejected. The request
+// body is left readable for proxying.
+func prepareGraphQLRequest(w http.ResponseWriter, r *http.Request, route *CompiledRoute, cluster *CompiledCluster) (*graphqlCall, bool) {
+	var maxBytes int64
+	if cluster.GraphQL != nil {
+		maxBytes = cluster.GraphQL.MaxBodyLimit()
+	}
+	req, err := graphql.ReadRequest(r, maxBytes)
+	if err != nil {
+		if reqErr, ok := err.(*graphql.RequestError); ok {
+			graphql.WriteError(w, reqErr.Status, reqErr.Code, reqErr.Message)
+		} else {
+			graphql.WriteError(w, http.StatusBadRequest, graphql.CodeBadRequest, err.Error())
+		}
+		return nil, false
+	}
+	if hash := req.PersistedQueryHash(); hash != "" && req.Query != "" && graphql.QueryHash(req.Query) != hash {
+		graphql.WriteError(w, http.StatusBadRequest, graphql.CodeBadRequest, "provided sha256Hash does not match query")
+		return nil, false
+	}
+	if route.PersistedQueries != nil && !resolvePersistedQuery(w, r, req, route) {
+		return nil, false
+	}
+	call := &graphqlCall{req: req}
+	if req.Query == "" {
+		// A hash-only persisted query the gateway does not resolve cannot
+		// be inspected here; only hashes the allowlist names are trusted.
+		if !route.GraphQLAllow.AllowsHash(req.PersistedQueryHash()) {
+			graphql.WriteError(w, http.StatusForbidden, graphql.CodeOperationNotAllowed, "persisted query is not allowed on this route")
+			return nil, false
+		}
+		return call, true
+	}
+	return call, checkGraphQLPolicy(w, r, call, route)
+}
+
+// resolvePersistedQuery implements Automatic Persisted Queries. A hash-only
+// request is completed with the stored query text, or answered with
+// PERSISTED_QUERY_NOT_FOUND so the client retries with the full query; a
+// request carrying both is verified and its query learned.
+func resolvePersistedQuery(w http.ResponseWriter, r *http.Request, req *graphql.Request, route *CompiledRoute) bool {
+	hash := req.PersistedQueryHash()
+	if hash == "" {
+		return true
+	}
+	manifestOnly := route.Upstream.GraphQL.PersistedQueries.ManifestOnly
+	if req.Query == "" {
+		query, ok := route.PersistedQueries.Lookup(hash, manifestOnly)
+		if !ok {
+			// Clients expect a 200 so they retry with the full query.
+			graphql.WriteError(w, http.StatusOK, graphql.CodePersistedQueryNotFound, "PersistedQueryNotFound")
+			return false
+		}
+		req.Query = query
+		if err := graphql.SetRequest(r, req); err != nil {
+			graphql.WriteError(w, http.StatusBadRequest, graphql.CodeBadRequest, err.Error())
+			return false
+		}
+		return true
+	}
+	if !manifestOnly {
+		route.PersistedQueries.Register(req.Query)
+	}
+	return true
+}
+
+// checkGraphQLPolicy parses the query of call and enforces the operation
+// method, introspection and allowlist settings of a GraphQL route on it,
+// recording the parsed operation.
+func checkGraphQLPolicy(w http.ResponseWriter, r *http.Request, call *graphqlCall, route *CompiledRoute) bool {
+	req := call.req
+	doc, err := graphql.Parse(req.Query)
+	if err != nil {
+		graphql.WriteError(w, http.StatusBadRequest, graphql.CodeParseFailed, err.Error())
+		return false
+	}
+	op, err := doc.Operation(req.OperationName)
+	if err != nil {
+		graphql.WriteError(w, http.StatusBadRequest, graphql.CodeValidationFailed, err.Error())
+		return false
+	}
+	if r.Method == http.MethodGet && op.Type != graphql.Query {
+		// GET must be safe, so only queries may use it.
+		w.Header().Set("Allow", http.MethodPost)
+		graphql.WriteError(w, http.StatusMethodNotAllowed, graphql.CodeMethodNotAllowed, op.Type+" operations must be sent with POST")
+		return false
+	}
+	if gqlCfg := route.Upstream.GraphQL; gqlCfg != nil && gqlCfg.BlockIntrospection && doc.Introspects(op) {
+		graphql.WriteError(w, http.StatusForbidden, graphql.CodeIntrospectionBlocked, "introspection is disabled on this route")
+		return false
+	}
+	if !route.GraphQLAllow.Allows(op.Name, graphql.QueryHash(req.Query)) {
+		msg := "operation is not allowed on this route"
+		if op.Name != "" {
+			msg = "operation " + op.Name + " is not allowed on this route"
+		}
+		graphql.WriteError(w, http.StatusForbidden, graphql.CodeOperationNotAllowed, msg)
+		return false
+	}
+	call.doc, call.op = doc, op
+	return true
+}
@@ -0,0 +1,429 @@
+package runtime
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/oriys/nexus/internal/config"
+	"github.com/oriys/nexus/internal/graphql"
+)
+
+// newGraphQLBackend starts a GraphQL server that echoes the request body and
+// counts the requests it receives.
+func newGraphQLBackend(t *testing.T, hits *int) *CompiledCluster {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*hits++
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]string{"echo": string(body)}})
+	}))
+	t.Cleanup(backend.Close)
+	return &CompiledCluster{
+		Name:      "graphql-svc",
+		Type:      "graphql",
+		Endpoints: []config.ClusterEndpoint{{URL: backend.URL}},
+	}
+}
+
+func graphqlErrorCode(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+	var resp struct {
+		Errors []graphql.Error `json:"errors"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || len(resp.Errors) != 1 {
+		t.Fatalf("expected a GraphQL error response, got %s", w.Body)
+	}
+	code, _ := resp.Errors[0].Extensions["code"].(string)
+	return code
+}
+
+func TestGraphQLUpstream_BlockIntrospection(t *testing.T) {
+	var hits int
+	cluster := newGraphQLBackend(t, &hits)
+	route := &CompiledRoute{
+		Name: "graphql",
+		Upstream: RouteUpstreamConfig{
+			ClusterName: "graphql-svc",
+			GraphQL:     &config.RouteUpstreamGraphQL{BlockIntrospection: true},
+		},
+	}
+
+	tests := []struct {
+		name   string
+		req    *http.Request
+		status int
+		code   string
+	}{
+		{
+			name:   "schema",
+			req:    httptest.NewRequest("POST", "/graphql", strings.NewReader(`{"query":"{ __schema { types { name } } }"}`)),
+			status: http.StatusForbidden,
+			code:   graphql.CodeIntrospectionBlocked,
+		},
+		{
+			name:   "type via fragment on GET",
+			req:    httptest.NewRequest("GET", "/graphql?"+url.Values{"query": {`query Q { ...F } fragment F on Query { __type(name: "User") { name } }`}}.Encode(), nil),
+			status: http.StatusForbidden,
+			code:   graphql.CodeIntrospectionBlocked,
+		},
+		{
+			name:   "selected operation only",
+			req:    httptest.NewRequest("POST", "/graphql", strings.NewReader(`{"query":"query A { user { __typename } } query B { __schema { types { name } } }","operationName":"A"}`)),
+			status: http.StatusOK,
+		},
+		{
+			name:   "syntax error",
+			req:    httptest.NewRequest("POST", "/graphql", strings.NewReader(`{"query":"{ user "}`)),
+			status: http.StatusBadRequest,
+			code:   graphql.CodeParseFailed,
+		},
+		{
+			name:   "invalid body",
+			req:    httptest.NewRequest("POST", "/graphql", strings.NewReader(`not json`)),
+			status: http.StatusBadRequest,
+			code:   graphql.CodeBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hits = 0
+			w := httptest.NewRecorder()
+			if err := (&GraphQLUpstream{}).Handle(w, tt.req, route, cluster); err != nil {
+				t.Fatalf("Handle: %v", err)
+			}
+			if w.Code != tt.status {
+				t.Fatalf("expected %d, got %d: %s", tt.status, w.Code, w.Body)
+			}
+			if tt.code != "" {
+				if code := graphqlErrorCode(t, w); code != tt.code {
+					t.Errorf("expected code %s, got %s", tt.code, code)
+				}
+				if hits != 0 {
+					t.Error("rejected request reached the upstream")
+				}
+			} else if !strings.Contains(w.Body.String(), "__typename") {
+				t.Errorf("expected the original body to be forwarded, got %s", w.Body)
+			}
+		})
+	}
+}
+
+func TestGraphQLUpstream_Validation(t *testing.T) {
+	var hits int
+	cluster := newGraphQLBackend(t, &hits)
+	cluster.GraphQL = &config.ClusterGraphQL{MaxBodyBytes: 64}
+	route := &CompiledRoute{
+		Name:     "graphql",
+		Upstream: RouteUpstreamConfig{ClusterName: "graphql-svc"},
+	}
+	post := func(body string) *http.Request {
+		return httptest.NewRequest("POST", "/graphql", strings.NewReader(body))
+	}
+
+	tests := []struct {
+		name   string
+		req    *http.Request
+		status int
+		code   string
+	}{
+		{name: "valid", req: post(`{"query":"{ a }","variables":{"n":1}}`), status: http.StatusOK},
+		{name: "too large", req: post(`{"query":"{ ` + strings.Repeat("a ", 40) + `}"}`), status: http.StatusRequestEntityTooLarge, code: graphql.CodePayloadTooLarge},
+		{name: "missing query", req: post(`{"variables":{}}`), status: http.StatusBadRequest, code: graphql.CodeBadRequest},
+		{name: "variables not an object", req: post(`{"query":"{ a }","variables":"x"}`), status: http.StatusBadRequest, code: graphql.CodeBadRequest},
+		{name: "syntax error", req: post(`{"query":"{ a "}`), status: http.StatusBadRequest, code: graphql.CodeParseFailed},
+		{name: "unknown operation", req: post(`{"query":"query A { a }","operationName":"B"}`), status: http.StatusBadRequest, code: graphql.CodeValidationFailed},
+		{
+			name:   "mutation over GET",
+			req:    httptest.NewRequest("GET", "/graphql?"+url.Values{"query": {"mutation { a }"}}.Encode(), nil),
+			status: http.StatusMethodNotAllowed,
+			code:   graphql.CodeMethodNotAllowed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hits = 0
+			w := httptest.NewRecorder()
+			if err := (&GraphQLUpstream{}).Handle(w, tt.req, route, cluster); err != nil {
+				t.Fatalf("Handle: %v", err)
+			}
+			if w.Code != tt.status {
+				t.Fatalf("expected %d, got %d: %s", tt.status, w.Code, w.Body)
+			}
+			if tt.code == "" {
+				if hits != 1 {
+					t.Errorf("expected the request to be forwarded, got %d upstream requests", hits)
+				}
+				return
+			}
+			if code := graphqlErrorCode(t, w); code != tt.code {
+				t.Errorf("expected code %s, got %s", tt.code, code)
+			}
+			if hits != 0 {
+				t.Error("rejected request reached the upstream")
+			}
+		})
+	}
+}
+
+func TestGraphQLUpstream_Allowlist(t *testing.T) {
+	var hits int
+	cluster := newGraphQLBackend(t, &hits)
+	pinned := `query Pinned { me { id } }`
+	gqlCfg := &config.RouteUpstreamGraphQL{
+		Allowlist: &config.GraphQLAllowlist{
+			Operations: []string{"GetUser"},
+			Hashes:     []string{strings.ToUpper(graphql.QueryHash(pinned))},
+		},
+	}
+	route := &CompiledRoute{
+		Name:         "graphql",
+		Upstream:     RouteUpstreamConfig{ClusterName: "graphql-svc", GraphQL: gqlCfg},
+		GraphQLAllow: newGraphQLAllowlist(gqlCfg.Allowlist),
+	}
+
+	body := func(v map[string]interface{}) io.Reader {
+		b, _ := json.Marshal(v)
+		return strings.NewReader(string(b))
+	}
+	pq := func(hash string) map[string]interface{} {
+		return map[string]interface{}{"persistedQuery": map[string]interface{}{"version": 1, "sha256Hash": hash}}
+	}
+
+	tests := []struct {
+		name   string
+		body   map[string]interface{}
+		status int
+		code   string
+	}{
+		{"named operation", map[string]interface{}{"query": "query GetUser { user { id } }"}, http.StatusOK, ""},
+		{"unlisted operation", map[string]interface{}{"query": "query DropAll { a }"}, http.StatusForbidden, graphql.CodeOperationNotAllowed},
+		{"anonymous operation", map[string]interface{}{"query": "{ a }"}, http.StatusForbidden, graphql.CodeOperationNotAllowed},
+		{"pinned query text", map[string]interface{}{"query": pinned}, http.StatusOK, ""},
+		{"pinned hash only", map[string]interface{}{"extensions": pq(graphql.QueryHash(pinned))}, http.StatusOK, ""},
+		{"unknown hash only", map[string]interface{}{"extensions": pq(graphql.QueryHash("{ a }"))}, http.StatusForbidden, graphql.CodeOperationNotAllowed},
+		{"hash mismatch", map[string]interface{}{"query": "query Other { a }", "extensions": pq(graphql.QueryHash(pinned))}, http.StatusBadRequest, graphql.CodeBadRequest},
+		{"unknown operation name", map[string]interface{}{"query": "query GetUser { a }", "operationName": "Nope"}, http.StatusBadRequest, graphql.CodeValidationFailed},
+		{"missing query", map[string]interface{}{}, http.StatusBadRequest, graphql.CodeBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hits = 0
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/graphql", body(tt.body))
+			if err := (&GraphQLUpstream{}).Handle(w, req, route, cluster); err != nil {
+				t.Fatalf("Handle: %v", err)
+			}
+			if w.Code != tt.status {
+				t.Fatalf("expected %d, got %d: %s", tt.status, w.Code, w.Body)
+			}
+			if tt.code != "" {
+				if code := graphqlErrorCode(t, w); code != tt.code {
+					t.Errorf("expected code %s, got %s", tt.code, code)
+				}
+			}
+			wantHits := 1
+			if tt.code != "" {
+				wantHits = 0
+			}
+			if hits != wantHits {
+				t.Errorf("expected %d upstream requests, got %d", wantHits, hits)
+			}
+		})
+	}
+}
+
+func TestCompile_GraphQLAllowlist(t *testing.T) {
+	cfg := &config.Config{
+		Clusters: []config.Cluster{
+			{Name: "gql", Type: "graphql", Endpoints: []config.ClusterEndpoint{{URL: "http://gql:8080"}}},
+		},
+		RoutesV2: []config.RouteV2{
+			{
+				Name:  "open",
+				Match: config.RouteMatch{Path: "/open"},
+				Upstream: config.RouteUpstream{
+					Cluster: "gql",
+					GraphQL: &config.RouteUpstreamGraphQL{},
+				},
+			},
+			{
+				Name:  "locked",
+				Match: config.RouteMatch{Path: "/locked"},
+				Upstream: config.RouteUpstream{
+					Cluster: "gql",
+					GraphQL: &config.RouteUpstreamGraphQL{Allowlist: &config.GraphQLAllowlist{Operations: []string{"A"}}},
+				},
+			},
+		},
+	}
+	cc, err := Compile(cfg, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	open, ok1 := cc.Router.Match(httptest.NewRequest("POST", "/open", nil))
+	locked, ok2 := cc.Router.Match(httptest.NewRequest("POST", "/locked", nil))
+	if !ok1 || !ok2 {
+		t.Fatal("routes not found")
+	}
+	if open.GraphQLAllow != nil {
+		t.Error("expected no allowlist on the open route")
+	}
+	if !locked.GraphQLAllow.Allows("A", "") || locked.GraphQLAllow.Allows("B", "") || locked.GraphQLAllow.Allows("", "") {
+		t.Error("unexpected allowlist decisions")
+	}
+}
+
+func TestGraphQLUpstream_PersistedQueries(t *testing.T) {
+	var received []string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req, err := graphql.ReadRequest(r, 0)
+		if err != nil {
+			t.Errorf("upstream: %v", err)
+			return
+		}
+		received = append(received, req.Query)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{}}`))
+	}))
+	defer backend.Close()
+	cluster := &CompiledCluster{Name: "graphql-svc", Type: "graphql", Endpoints: []config.ClusterEndpoint{{URL: backend.URL}}}
+
+	store := graphql.NewPersistedQueryStore(0)
+	gqlCfg := &config.RouteUpstreamGraphQL{
+		BlockIntrospection: true,
+		PersistedQueries:   &config.GraphQLPersistedQueries{},
+	}
+	route := &CompiledRoute{
+		Name:             "graphql",
+		Upstream:         RouteUpstreamConfig{ClusterName: "graphql-svc", GraphQL: gqlCfg},
+		PersistedQueries: store,
+	}
+
+	query := "query GetUser { user { id } }"
+	hash := graphql.QueryHash(query)
+	ext := `{"persistedQuery":{"version":1,"sha256Hash":"` + hash + `"}}`
+	do := func(req *http.Request) *httptest.ResponseRecorder {
+		t.Helper()
+		w := httptest.NewRecorder()
+		if err := (&GraphQLUpstream{}).Handle(w, req, route, cluster); err != nil {
+			t.Fatalf("Handle: %v", err)
+		}
+		return w
+	}
+
+	// Hash-only before registration: the client is asked for the query.
+	w := do(httptest.NewRequest("GET", "/graphql?"+url.Values{"extensions": {ext}}.Encode(), nil))
+	if w.Code != http.StatusOK || graphqlErrorCode(t, w) != graphql.CodePersistedQueryNotFound {
+		t.Fatalf("expected PERSISTED_QUERY_NOT_FOUND, got %d: %s", w.Code, w.Body)
+	}
+
+	// Query with hash registers it.
+	w = do(httptest.NewRequest("POST", "/graphql", strings.NewReader(`{"query":"`+query+`","extensions":`+ext+`}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+
+	// Hash-only requests are now completed with the query, on GET and POST.
+	do(httptest.NewRequest("GET", "/graphql?"+url.Values{"extensions": {ext}}.Encode(), nil))
+	do(httptest.NewRequest("POST", "/graphql", strings.NewReader(`{"variables":{"big":12345678901234567890},"extensions":`+ext+`}`)))
+	if len(received) != 3 || received[1] != query || received[2] != query {
+		t.Fatalf("unexpected upstream queries %q", received)
+	}
+
+	// Mismatched hashes are rejected and never learned.
+	w = do(httptest.NewRequest("POST", "/graphql", strings.NewReader(`{"query":"{ other }","extensions":`+ext+`}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", w.Code)
+	}
+
+	// Resolved queries are still subject to the route policy.
+	introspection := "{ __schema { types { name } } }"
+	store.Register(introspection)
+	w = do(httptest.NewRequest("POST", "/graphql", strings.NewReader(`{"extensions":{"persistedQuery":{"version":1,"sha256Hash":"`+graphql.QueryHash(introspection)+`"}}}`)))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for persisted introspection query, got %d", w.Code)
+	}
+
+	// Manifest-only routes ignore learned queries.
+	gqlCfg.PersistedQueries.ManifestOnly = true
+	w = do(httptest.NewRequest("POST", "/graphql", strings.NewReader(`{"extensions":`+ext+`}`)))
+	if graphqlErrorCode(t, w) != graphql.CodePersistedQueryNotFound {
+		t.Errorf("expected learned query to be ignored, got %s", w.Body)
+	}
+}
+
+func TestCompileAndStore_PersistedQueries(t *testing.T) {
+	cfg := &config.Config{
+		Clusters: []config.Cluster{
+			{Name: "gql", Type: "graphql", Endpoints: []config.ClusterEndpoint{{URL: "http://gql:8080"}}},
+		},
+		RoutesV2: []config.RouteV2{{
+			Name:  "apq",
+			Match: config.RouteMatch{Path: "/graphql"},
+			Upstream: config.RouteUpstream{
+				Cluster: "gql",
+				GraphQL: &config.RouteUpstreamGraphQL{PersistedQueries: &config.GraphQLPersistedQueries{}},
+			},
+		}},
+	}
+	store := NewConfigStore()
+	for i := 0; i < 2; i++ {
+		compiled, err := CompileAndStore(cfg, store)
+		if err != nil {
+			t.Fatal(err)
+		}
+		route, _ := compiled.Router.Match(httptest.NewRequest("POST", "/graphql", nil))
+		if route == nil || route.PersistedQueries != store.PersistedQueries() {
+			t.Fatal("expected the route to share the store's persisted queries across compilations")
+		}
+	}
+}
+
+func TestGraphQLUpstream_GetAsPost(t *testing.T) {
+	var method, rawQuery, body string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		method, rawQuery, body = r.Method, r.URL.RawQuery, string(b)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{}}`))
+	}))
+	defer backend.Close()
+	cluster := &CompiledCluster{Name: "graphql-svc", Type: "graphql", Endpoints: []config.ClusterEndpoint{{URL: backend.URL}}}
+	gqlCfg := &config.RouteUpstreamGraphQL{}
+	route := &CompiledRoute{Name: "graphql", Upstream: RouteUpstreamConfig{ClusterName: "graphql-svc", GraphQL: gqlCfg}}
+	q := url.Values{"query": {"query Q($id: ID) { user(id: $id) { id } }"}, "variables": {`{"id":"7"}`}, "operationName": {"Q"}, "locale": {"en"}}
+
+	get := func() {
+		t.Helper()
+		w := httptest.NewRecorder()
+		if err := (&GraphQLUpstream{}).Handle(w, httptest.NewRequest("GET", "/graphql?"+q.Encode(), nil), route, cluster); err != nil {
+			t.Fatalf("Handle: %v", err)
+		}
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+		}
+	}
+
+	get()
+	if method != "GET" || rawQuery != q.Encode() || body != "" {
+		t.Errorf("expected the GET to be forwarded as is, got %s ?%s %q", method, rawQuery, body)
+	}
+
+	gqlCfg.GetAsPost = true
+	get()
+	if method != "POST" || rawQuery != "locale=en" {
+		t.Fatalf("expected a POST keeping other parameters, got %s ?%s", method, rawQuery)
+	}
+	var req graphql.Request
+	if err := json.Unmarshal([]byte(body), &req); err != nil {
+		t.Fatalf("invalid body %q: %v", body, err)
+	}
+	if req.Query != q.Get("query") || req.OperationName != "Q" || req.Variables["id"] != "7" {
+		t.Errorf("unexpected body %s", body)
+	}
+}
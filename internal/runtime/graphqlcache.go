@@ -0,0 +1,225 @@
+package runtime
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/oriys/nexus/internal/auth"
+	"github.com/oriys/nexus/internal/config"
+	"github.com/oriys/nexus/internal/graphql"
+)
+
+const (
+	defaultGraphQLCacheEntries       = 1000
+	defaultGraphQLCacheResponseBytes = 1 << 20
+)
+
+// GraphQLCache caches the responses of a GraphQL route's query operations.
+// It is safe for concurrent use.
+type GraphQLCache struct {
+	ttl           time.Duration
+	maxEntries    int
+	maxBytes      int64
+	varySubject   bool
+	varyHeaders   []string
+	now           func() time.Time
+	mu            sync.Mutex
+	entries       map[string]*list.Element // values are *graphqlCacheEntry
+	lru           *list.List
+	hits, misses  uint64
+	stores, skips uint64
+}
+
+type graphqlCacheEntry struct {
+	key     string
+	status  int
+	header  http.Header
+	body    []byte
+	stored  time.Time
+	expires time.Time
+}
+
+func newGraphQLCache(cfg *config.GraphQLCache) *GraphQLCache {
+	c := &GraphQLCache{
+		ttl:         cfg.TTLDuration(),
+		maxEntries:  cfg.MaxEntries,
+		maxBytes:    cfg.MaxResponseLimit(),
+		varySubject: cfg.VaryOnSubject,
+		now:         time.Now,
+		entries:     make(map[string]*list.Element),
+		lru:         list.New(),
+	}
+	if c.maxEntries <= 0 {
+		c.maxEntries = defaultGraphQLCacheEntries
+	}
+	if c.maxBytes <= 0 {
+		c.maxBytes = defaultGraphQLCacheResponseBytes
+	}
+	for _, h := range cfg.VaryHeaders {
+		c.varyHeaders = append(c.varyHeaders, http.CanonicalHeaderKey(h))
+	}
+	return c
+}
+
+// key returns the cache key of a GraphQL call, or false when the call must
+// not be cached: it is not a query operation, or the cache varies on the
+// subject and the request is not authenticated.
+func (c *GraphQLCache) key(r *http.Request, call *graphqlCall) (string, bool) {
+	if call == nil || call.op == nil || call.op.Type != graphql.Query {
+		return "", false
+	}
+	vars, err := json.Marshal(call.req.Variables) // map keys are sorted
+	if err != nil {
+		return "", false
+	}
+	h := sha256.New()
+	for _, part := range []string{call.req.OperationName, call.req.Query, string(vars)} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	if c.varySubject {
+		id := auth.GetIdentity(r.Context())
+		if id == nil || id.Subject == "" {
+			return "", false
+		}
+		h.Write([]byte(id.Source + ":" + id.Subject))
+		h.Write([]byte{0})
+	}
+	for _, name := range c.varyHeaders {
+		h.Write([]byte(strings.Join(r.Header.Values(name), ",")))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil)), true
+}
+
+// serve writes the cached response for key, reporting whether one was found.
+// Responses to GET requests, which HTTP caches may store, carry a max-age of
+// the cache TTL when the upstream did not set a Cache-Control; along with
+// Age this leaves them fresh downstream for as long as they are here.
+func (c *GraphQLCache) serve(w http.ResponseWriter, key string, get bool) bool {
+	now := c.now()
+	c.mu.Lock()
+	el, ok := c.entries[key]
+	if ok && now.After(el.Value.(*graphqlCacheEntry).expires) {
+		c.remove(el)
+		ok = false
+	}
+	if !ok {
+		c.misses++
+		c.mu.Unlock()
+		return false
+	}
+	c.lru.MoveToFront(el)
+	c.hits++
+	e := el.Value.(*graphqlCacheEntry)
+	c.mu.Unlock()
+
+	for name, values := range e.header {
+		w.Header()[name] = values
+	}
+	w.Header().Set("Age", strconv.Itoa(int(now.Sub(e.stored)/time.Second)))
+	if get && w.Header().Get("Cache-Control") == "" {
+		scope := "public"
+		if c.varySubject {
+			scope = "private"
+		}
+		w.Header().Set("Cache-Control", scope+", max-age="+strconv.Itoa(int(c.ttl/time.Second)))
+	}
+	w.Header().Set("X-Cache", "HIT")
+	w.WriteHeader(e.status)
+	w.Write(e.body)
+	return true
+}
+
+// store caches the response captured by rec under key if it is cacheable:
+// a complete 200 response without GraphQL errors that the upstream did not
+// mark private.
+func (c *GraphQLCache) store(key string, rec *bodyRecorder) {
+	if !graphqlCacheable(rec) {
+		c.mu.Lock()
+		c.skips++
+		c.mu.Unlock()
+		return
+	}
+	header := rec.Header().Clone()
+	for _, name := range []string{"Content-Length", "Date", "X-Cache"} {
+		header.Del(name)
+	}
+	now := c.now()
+	e := &graphqlCacheEntry{key: key, status: rec.status, header: header, body: rec.body.Bytes(), stored: now, expires: now.Add(c.ttl)}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		c.remove(el)
+	}
+	c.entries[key] = c.lru.PushFront(e)
+	c.stores++
+	for c.lru.Len() > c.maxEntries {
+		c.remove(c.lru.Back())
+	}
+}
+
+func (c *GraphQLCache) remove(el *list.Element) {
+	c.lru.Remove(el)
+	delete(c.entries, el.Value.(*graphqlCacheEntry).key)
+}
+
+// GraphQLCacheStats reports the size and effectiveness of a response cache.
+type GraphQLCacheStats struct {
+	Entries int    `json:"entries"`
+	Hits    uint64 `json:"hits"`
+	Misses  uint64 `json:"misses"`
+	Stores  uint64 `json:"stores"`
+	// Skips counts responses that were not cacheable.
+	Skips uint64 `json:"skips"`
+}
+
+// Stats returns the cache counters.
+func (c *GraphQLCache) Stats() GraphQLCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return GraphQLCacheStats{Entries: c.lru.Len(), Hits: c.hits, Misses: c.misses, Stores: c.stores, Skips: c.skips}
+}
+
+// newGraphQLCacheRecorder records a response for c. The response varies on
+// the headers c is keyed by, so HTTP caches keep them apart too.
+func newGraphQLCacheRecorder(w http.ResponseWriter, c *GraphQLCache) *bodyRecorder {
+	w.Header().Set("X-Cache", "MISS")
+	for _, name := range c.varyHeaders {
+		w.Header().Add("Vary", name)
+	}
+	return &bodyRecorder{ResponseWriter: w, limit: c.maxBytes}
+}
+
+// graphqlCacheable reports whether the response recorded by rec may be
+// cached: a complete 200 response with data and without errors that the
+// upstream did not mark private.
+func graphqlCacheable(rec *bodyRecorder) bool {
+	if rec.status != http.StatusOK || rec.overflow {
+		return false
+	}
+	h := rec.Header()
+	if h.Get("Set-Cookie") != "" || h.Get("Content-Encoding") != "" {
+		return false
+	}
+	cc := strings.ToLower(h.Get("Cache-Control"))
+	if strings.Contains(cc, "no-store") || strings.Contains(cc, "private") {
+		return false
+	}
+	var resp struct {
+		Data   json.RawMessage `json:"data"`
+		Errors json.RawMessage `json:"errors"`
+	}
+	if err := json.Unmarshal(rec.body.Bytes(), &resp); err != nil {
+		return false
+	}
+	return len(resp.Data) > 0 && (len(resp.Errors) == 0 || string(resp.Errors) == "null")
+}
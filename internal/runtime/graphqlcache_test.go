@@ -0,0 +1,215 @@
+package runtime
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oriys/nexus/internal/auth"
+	"github.com/oriys/nexus/internal/config"
+)
+
+// newCachingGraphQLRoute starts a GraphQL backend answering with respond and
+// returns a route caching its responses with cfg.
+func newCachingGraphQLRoute(t *testing.T, cfg *config.GraphQLCache, respond func(w http.ResponseWriter, r *http.Request)) (*CompiledRoute, *CompiledCluster, *int) {
+	hits := new(int)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*hits++
+		w.Header().Set("Content-Type", "application/json")
+		respond(w, r)
+	}))
+	t.Cleanup(backend.Close)
+	route := &CompiledRoute{
+		Name:         "graphql",
+		Upstream:     RouteUpstreamConfig{ClusterName: "graphql-svc", GraphQL: &config.RouteUpstreamGraphQL{Cache: cfg}},
+		GraphQLCache: newGraphQLCache(cfg),
+	}
+	cluster := &CompiledCluster{Name: "graphql-svc", Type: "graphql", Endpoints: []config.ClusterEndpoint{{URL: backend.URL}}}
+	return route, cluster, hits
+}
+
+func doGraphQL(t *testing.T, route *CompiledRoute, cluster *CompiledCluster, req *http.Request) *httptest.ResponseRecorder {
+	t.Helper()
+	w := httptest.NewRecorder()
+	if err := (&GraphQLUpstream{}).Handle(w, req, route, cluster); err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+	return w
+}
+
+func postGraphQL(body string) *http.Request {
+	return httptest.NewRequest("POST", "/graphql", strings.NewReader(body))
+}
+
+func TestGraphQLCache_Queries(t *testing.T) {
+	route, cluster, hits := newCachingGraphQLRoute(t, &config.GraphQLCache{TTLMs: 60000}, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data":{"n":1}}`))
+	})
+
+	w := doGraphQL(t, route, cluster, postGraphQL(`{"query":"query Q($id: ID) { user(id: $id) { id } }","variables":{"id":"1","x":2}}`))
+	if w.Header().Get("X-Cache") != "MISS" || w.Body.String() != `{"data":{"n":1}}` {
+		t.Fatalf("unexpected first response %v %s", w.Header(), w.Body)
+	}
+
+	// The same query and variables, sent as GET with reordered variables.
+	get := httptest.NewRequest("GET", "/graphql?"+url.Values{
+		"query":     {"query Q($id: ID) { user(id: $id) { id } }"},
+		"variables": {`{"x":2,"id":"1"}`},
+	}.Encode(), nil)
+	w = doGraphQL(t, route, cluster, get)
+	if w.Header().Get("X-Cache") != "HIT" || w.Body.String() != `{"data":{"n":1}}` || w.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("expected a cache hit, got %v %s", w.Header(), w.Body)
+	}
+	if *hits != 1 {
+		t.Fatalf("expected 1 upstream request, got %d", *hits)
+	}
+
+	doGraphQL(t, route, cluster, postGraphQL(`{"query":"query Q($id: ID) { user(id: $id) { id } }","variables":{"id":"2"}}`))
+	if *hits != 2 {
+		t.Errorf("different variables must miss, got %d upstream requests", *hits)
+	}
+
+	for i := 0; i < 2; i++ {
+		w = doGraphQL(t, route, cluster, postGraphQL(`{"query":"mutation { touch }"}`))
+	}
+	if *hits != 4 || w.Header().Get("X-Cache") != "" {
+		t.Errorf("mutations must not be cached, got %d upstream requests", *hits)
+	}
+
+	st := route.GraphQLCache.Stats()
+	if st.Entries != 2 || st.Hits != 1 || st.Misses != 2 || st.Stores != 2 {
+		t.Errorf("unexpected stats %+v", st)
+	}
+}
+
+func TestGraphQLCache_Expiry(t *testing.T) {
+	route, cluster, hits := newCachingGraphQLRoute(t, &config.GraphQLCache{TTLMs: 1000, MaxEntries: 1}, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data":{}}`))
+	})
+	now := time.Unix(1000, 0)
+	route.GraphQLCache.now = func() time.Time { return now }
+
+	doGraphQL(t, route, cluster, postGraphQL(`{"query":"{ a }"}`))
+	now = now.Add(900 * time.Millisecond)
+	if w := doGraphQL(t, route, cluster, postGraphQL(`{"query":"{ a }"}`)); w.Header().Get("X-Cache") != "HIT" || w.Header().Get("Age") != "0" {
+		t.Fatalf("expected a hit within the TTL, got %v", w.Header())
+	}
+	now = now.Add(200 * time.Millisecond)
+	doGraphQL(t, route, cluster, postGraphQL(`{"query":"{ a }"}`))
+	if *hits != 2 {
+		t.Fatalf("expected the entry to expire, got %d upstream requests", *hits)
+	}
+
+	// A second query evicts the first from the single-entry cache.
+	doGraphQL(t, route, cluster, postGraphQL(`{"query":"{ b }"}`))
+	doGraphQL(t, route, cluster, postGraphQL(`{"query":"{ a }"}`))
+	if *hits != 4 {
+		t.Errorf("expected the first entry to be evicted, got %d upstream requests", *hits)
+	}
+}
+
+func TestGraphQLCache_Uncacheable(t *testing.T) {
+	responses := map[string]func(w http.ResponseWriter){
+		"errors": func(w http.ResponseWriter) { w.Write([]byte(`{"data":null,"errors":[{"message":"boom"}]}`)) },
+		"status": func(w http.ResponseWriter) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"data":{}}`))
+		},
+		"private": func(w http.ResponseWriter) {
+			w.Header().Set("Cache-Control", "private")
+			w.Write([]byte(`{"data":{}}`))
+		},
+		"cookie":   func(w http.ResponseWriter) { w.Header().Set("Set-Cookie", "a=b"); w.Write([]byte(`{"data":{}}`)) },
+		"oversize": func(w http.ResponseWriter) { w.Write([]byte(`{"data":{"s":"` + strings.Repeat("x", 64) + `"}}`)) },
+	}
+	for name, respond := range responses {
+		t.Run(name, func(t *testing.T) {
+			route, cluster, hits := newCachingGraphQLRoute(t, &config.GraphQLCache{TTLMs: 60000, MaxResponseBytes: 64}, func(w http.ResponseWriter, r *http.Request) {
+				respond(w)
+			})
+			doGraphQL(t, route, cluster, postGraphQL(`{"query":"{ a }"}`))
+			doGraphQL(t, route, cluster, postGraphQL(`{"query":"{ a }"}`))
+			if *hits != 2 {
+				t.Errorf("expected the response not to be cached, got %d upstream requests", *hits)
+			}
+		})
+	}
+}
+
+func TestGraphQLCache_Vary(t *testing.T) {
+	route, cluster, hits := newCachingGraphQLRoute(t, &config.GraphQLCache{TTLMs: 60000, VaryOnSubject: true, VaryHeaders: []string{"accept-language"}}, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept-Encoding") != "gzip" {
+			t.Errorf("expected the transport to negotiate compression, got %q", r.Header.Get("Accept-Encoding"))
+		}
+		w.Write([]byte(`{"data":{}}`))
+	})
+	as := func(subject, lang string) *http.Request {
+		req := postGraphQL(`{"query":"{ me { id } }"}`)
+		req.Header.Set("Accept-Language", lang)
+		if subject != "" {
+			req.Header.Set("Accept-Encoding", "br")
+			req = req.WithContext(auth.IdentityToContext(req.Context(), &auth.Identity{Subject: subject, Source: "apikey"}))
+		}
+		return req
+	}
+
+	doGraphQL(t, route, cluster, as("alice", "en"))
+	if w := doGraphQL(t, route, cluster, as("alice", "en")); w.Header().Get("X-Cache") != "HIT" {
+		t.Fatal("expected a hit for the same subject")
+	}
+	doGraphQL(t, route, cluster, as("bob", "en"))
+	doGraphQL(t, route, cluster, as("alice", "fr"))
+	if *hits != 3 {
+		t.Fatalf("expected other subjects and languages to miss, got %d upstream requests", *hits)
+	}
+	for i := 0; i < 2; i++ {
+		doGraphQL(t, route, cluster, as("", "en"))
+	}
+	if *hits != 5 {
+		t.Errorf("expected anonymous requests to bypass the cache, got %d upstream requests", *hits)
+	}
+}
+
+func TestGraphQLCache_HTTPCacheHeaders(t *testing.T) {
+	upstreamCC := ""
+	route, cluster, _ := newCachingGraphQLRoute(t, &config.GraphQLCache{TTLMs: 60000, VaryHeaders: []string{"Accept-Language"}}, func(w http.ResponseWriter, r *http.Request) {
+		if upstreamCC != "" {
+			w.Header().Set("Cache-Control", upstreamCC)
+		}
+		w.Write([]byte(`{"data":{}}`))
+	})
+	get := func(query string) *http.Request {
+		return httptest.NewRequest("GET", "/graphql?"+url.Values{"query": {query}}.Encode(), nil)
+	}
+
+	w := doGraphQL(t, route, cluster, get("{ a }"))
+	if w.Header().Get("Cache-Control") != "" || w.Header().Get("Vary") != "Accept-Language" {
+		t.Errorf("unexpected miss headers %v", w.Header())
+	}
+	w = doGraphQL(t, route, cluster, get("{ a }"))
+	if w.Header().Get("X-Cache") != "HIT" || w.Header().Get("Cache-Control") != "public, max-age=60" || len(w.Header().Values("Vary")) != 1 {
+		t.Errorf("unexpected GET hit headers %v", w.Header())
+	}
+	w = doGraphQL(t, route, cluster, postGraphQL(`{"query":"{ a }"}`))
+	if w.Header().Get("X-Cache") != "HIT" || w.Header().Get("Cache-Control") != "" {
+		t.Errorf("POST hits must not be marked cacheable, got %v", w.Header())
+	}
+
+	upstreamCC = "max-age=5"
+	doGraphQL(t, route, cluster, get("{ b }"))
+	if w = doGraphQL(t, route, cluster, get("{ b }")); w.Header().Get("Cache-Control") != "max-age=5" {
+		t.Errorf("expected the upstream Cache-Control to be kept, got %v", w.Header())
+	}
+
+	route.GraphQLCache.varySubject = true
+	upstreamCC = ""
+	id := auth.IdentityToContext(context.Background(), &auth.Identity{Subject: "alice", Source: "jwt"})
+	doGraphQL(t, route, cluster, get("{ c }").WithContext(id))
+	if w = doGraphQL(t, route, cluster, get("{ c }").WithContext(id)); w.Header().Get("Cache-Control") != "private, max-age=60" {
+		t.Errorf("expected per-subject hits to be private, got %v", w.Header())
+	}
+}
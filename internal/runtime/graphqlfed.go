@@ -0,0 +1,257 @@
+package runtime
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/oriys/nexus/internal/config"
+	"github.com/oriys/nexus/internal/graphql"
+)
+
+// maxSubgraphResponseSize bounds the response body read from a subgraph.
+const maxSubgraphResponseSize = 64 << 20
+
+// GraphQLFederation executes the operations of a federated GraphQL route: it
+// fetches their root fields from the subgraphs of a supergraph and merges the
+// results into one response.
+type GraphQLFederation struct {
+	supergraph *graphql.Supergraph
+	subgraphs  map[string]*federatedSubgraph // by subgraph name
+}
+
+type federatedSubgraph struct {
+	cluster  *CompiledCluster
+	endpoint string
+}
+
+// compileGraphQLFederation loads the supergraph of a federated route and
+// resolves the clusters of its subgraphs.
+func compileGraphQLFederation(cfg *config.GraphQLFederation, clusters map[string]*CompiledCluster) (*GraphQLFederation, error) {
+	src, err := os.ReadFile(cfg.Supergraph)
+	if err != nil {
+		return nil, fmt.Errorf("federation: read supergraph: %w", err)
+	}
+	sg, err := graphql.ParseSupergraph(string(src))
+	if err != nil {
+		return nil, fmt.Errorf("federation: supergraph %s: %w", cfg.Supergraph, err)
+	}
+	fed := &GraphQLFederation{supergraph: sg, subgraphs: make(map[string]*federatedSubgraph, len(cfg.Subgraphs))}
+	for _, s := range cfg.Subgraphs {
+		cc := clusters[s.Cluster]
+		if cc == nil || cc.Type != "graphql" {
+			return nil, fmt.Errorf("federation: subgraph %q: cluster %q is not a graphql cluster", s.Name, s.Cluster)
+		}
+		endpoint := s.Endpoint
+		if endpoint == "" {
+			endpoint = "/graphql"
+		}
+		fed.subgraphs[s.Name] = &federatedSubgraph{cluster: cc, endpoint: endpoint}
+	}
+	for _, g := range sg.Graphs {
+		if fed.subgraphs[g.Name] == nil {
+			return nil, fmt.Errorf("federation: subgraph %q of the supergraph is not mapped to a cluster", g.Name)
+		}
+	}
+	for name := range fed.subgraphs {
+		if !supergraphDefines(sg, name) {
+			return nil, fmt.Errorf("federation: subgraph %q is not defined by the supergraph", name)
+		}
+	}
+	return fed, nil
+}
+
+func supergraphDefines(sg *graphql.Supergraph, name string) bool {
+	for _, g := range sg.Graphs {
+		if g.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// subgraphResult is the outcome of one fetch.
+type subgraphResult struct {
+	data   map[string]json.RawMessage // nil when the subgraph returned no data
+	errors []json.RawMessage
+	err    error
+}
+
+// serve plans call across the subgraphs, executes the fetches and writes the
+// merged response. Query fetches run concurrently; mutation fetches run in
+// order.
+func (f *GraphQLFederation) serve(w http.ResponseWriter, r *http.Request, route *CompiledRoute, call *graphqlCall) {
+	if call.op == nil {
+		graphql.WriteError(w, http.StatusBadRequest, graphql.CodeBadRequest, "federated routes require the query text")
+		return
+	}
+	plan, err := f.supergraph.Plan(call.doc, call.op)
+	if err != nil {
+		graphql.WriteError(w, http.StatusBadRequest, graphql.CodeValidationFailed, err.Error())
+		return
+	}
+
+	ctx := r.Context()
+	if route.TimeoutMs > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(route.TimeoutMs)*time.Millisecond)
+		defer cancel()
+	}
+	results := make([]subgraphResult, len(plan.Fetches))
+	if call.op.Type == graphql.Mutation {
+		for i, fetch := range plan.Fetches {
+			results[i] = f.fetch(ctx, r, call.req, fetch)
+		}
+	} else {
+		var wg sync.WaitGroup
+		for i, fetch := range plan.Fetches {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				results[i] = f.fetch(ctx, r, call.req, fetch)
+			}()
+		}
+		wg.Wait()
+	}
+
+	for i, res := range results {
+		if res.err != nil {
+			slog.Warn("graphql subgraph fetch failed",
+				slog.String("route", route.Name),
+				slog.String("subgraph", plan.Fetches[i].Graph),
+				slog.String("error", res.err.Error()),
+			)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(mergeSubgraphResults(plan, results))
+}
+
+// fetch sends one fetch of a plan to its subgraph.
+func (f *GraphQLFederation) fetch(ctx context.Context, r *http.Request, req *graphql.Request, fetch *graphql.Fetch) subgraphResult {
+	sg := f.subgraphs[fetch.Graph]
+	target, _, err := graphqlTarget(sg.cluster)
+	if err != nil {
+		return subgraphResult{err: err}
+	}
+	body := &graphql.Request{Query: graphql.Print(fetch.Document), OperationName: req.OperationName}
+	for _, name := range fetch.Variables {
+		if v, ok := req.Variables[name]; ok {
+			if body.Variables == nil {
+				body.Variables = make(map[string]interface{}, len(fetch.Variables))
+			}
+			body.Variables[name] = v
+		}
+	}
+	b, err := json.Marshal(body)
+	if err != nil {
+		return subgraphResult{err: err}
+	}
+	out, err := http.NewRequestWithContext(ctx, http.MethodPost, target.JoinPath(sg.endpoint).String(), bytes.NewReader(b))
+	if err != nil {
+		return subgraphResult{err: err}
+	}
+	for name, values := range r.Header {
+		switch name {
+		case "Connection", "Keep-Alive", "Proxy-Connection", "Te", "Trailer", "Transfer-Encoding", "Upgrade",
+			"Content-Length", "Content-Type", "Accept", "Accept-Encoding":
+			continue
+		}
+		out.Header[name] = values
+	}
+	out.Header.Set("Content-Type", "application/json")
+	out.Header.Set("Accept", "application/json")
+
+	resp, err := http.DefaultClient.Do(out)
+	if err != nil {
+		return subgraphResult{err: err}
+	}
+	defer resp.Body.Close()
+	var payload struct {
+		Data   json.RawMessage   `json:"data"`
+		Errors []json.RawMessage `json:"errors"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxSubgraphResponseSize)).Decode(&payload); err != nil {
+		return subgraphResult{err: fmt.Errorf("subgraph responded with status %d and an invalid GraphQL response", resp.StatusCode)}
+	}
+	res := subgraphResult{errors: payload.Errors}
+	if len(payload.Data) > 0 && string(payload.Data) != "null" {
+		if err := json.Unmarshal(payload.Data, &res.data); err != nil {
+			return subgraphResult{err: fmt.Errorf("subgraph returned data that is not an object")}
+		}
+	} else if len(payload.Errors) == 0 {
+		return subgraphResult{err: fmt.Errorf("subgraph responded with status %d and no data", resp.StatusCode)}
+	}
+	return res
+}
+
+// mergeSubgraphResults builds the response to a planned operation: the data
+// of every fetch, in the order the document selects the root fields, and the
+// errors of all of them. The fields of a failed fetch are null.
+func mergeSubgraphResults(plan *graphql.QueryPlan, results []subgraphResult) []byte {
+	data := make(map[string]json.RawMessage)
+	var errs []json.RawMessage
+	for i, res := range results {
+		fetch := plan.Fetches[i]
+		for k, v := range res.data {
+			if _, ok := data[k]; !ok {
+				data[k] = v
+			}
+		}
+		if res.data == nil {
+			for _, k := range fetch.Keys {
+				if _, ok := data[k]; !ok {
+					data[k] = json.RawMessage("null")
+				}
+			}
+		}
+		errs = append(errs, res.errors...)
+		if res.err != nil {
+			e, _ := json.Marshal(graphql.Error{
+				Message:    fmt.Sprintf("subgraph %s: %v", fetch.Graph, res.err),
+				Extensions: map[string]interface{}{"code": graphql.CodeSubgraphFailed, "subgraph": fetch.Graph},
+			})
+			errs = append(errs, e)
+		}
+	}
+
+	var b bytes.Buffer
+	b.WriteString(`{"data":{`)
+	n := 0
+	for _, k := range plan.Keys {
+		v, ok := data[k]
+		if !ok {
+			continue
+		}
+		if n > 0 {
+			b.WriteByte(',')
+		}
+		key, _ := json.Marshal(k)
+		b.Write(key)
+		b.WriteByte(':')
+		b.Write(v)
+		n++
+	}
+	b.WriteByte('}')
+	if len(errs) > 0 {
+		e, _ := json.Marshal(errs)
+		b.WriteString(`,"errors":`)
+		b.Write(e)
+	}
+	b.WriteString("}\n")
+	return b.Bytes()
+}
+
+// graphqlTarget picks the next endpoint of a GraphQL cluster and returns its
+// base URL and address.
+func graphqlTarget(cluster *CompiledCluster) (*url.URL, string, error) {
+	return cluster.NextTarget()
+}
@@ -0,0 +1,235 @@
+package runtime
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/oriys/nexus/internal/config"
+	"github.com/oriys/nexus/internal/graphql"
+)
+
+const testSupergraph = `
+enum join__Graph {
+  ACCOUNTS @join__graph(name: "accounts", url: "http://accounts/graphql")
+  PRODUCTS @join__graph(name: "products", url: "http://products/graphql")
+}
+
+type Query @join__type(graph: ACCOUNTS) @join__type(graph: PRODUCTS) {
+  me: User @join__field(graph: ACCOUNTS)
+  topProducts(first: Int): [Product] @join__field(graph: PRODUCTS)
+}
+
+type Mutation @join__type(graph: ACCOUNTS) @join__type(graph: PRODUCTS) {
+  login(name: String!): User @join__field(graph: ACCOUNTS)
+  addProduct(upc: String!): Product @join__field(graph: PRODUCTS)
+}
+
+type User @join__type(graph: ACCOUNTS) { id: ID! }
+type Product @join__type(graph: PRODUCTS) { upc: String! }
+`
+
+// subgraphRequest is a request received by a test subgraph.
+type subgraphRequest struct {
+	graph  string
+	body   graphql.Request
+	header http.Header
+}
+
+// newFederatedRoute compiles a federated route over two test subgraphs
+// answering with respond.
+func newFederatedRoute(t *testing.T, respond func(graph string, req *graphql.Request) string) (*CompiledRoute, *CompiledCluster, func() []subgraphRequest) {
+	t.Helper()
+	var mu sync.Mutex
+	var received []subgraphRequest
+	subgraph := func(graph string) *httptest.Server {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/gql" {
+				t.Errorf("%s: unexpected path %s", graph, r.URL.Path)
+			}
+			var req graphql.Request
+			json.NewDecoder(r.Body).Decode(&req)
+			mu.Lock()
+			received = append(received, subgraphRequest{graph: graph, body: req, header: r.Header.Clone()})
+			mu.Unlock()
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(respond(graph, &req)))
+		}))
+		t.Cleanup(srv.Close)
+		return srv
+	}
+	accounts, products := subgraph("accounts"), subgraph("products")
+
+	path := filepath.Join(t.TempDir(), "supergraph.graphql")
+	if err := os.WriteFile(path, []byte(testSupergraph), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{
+		Clusters: []config.Cluster{
+			{Name: "accounts", Type: "graphql", Endpoints: []config.ClusterEndpoint{{URL: accounts.URL}}},
+			{Name: "products", Type: "graphql", Endpoints: []config.ClusterEndpoint{{URL: products.URL}}},
+		},
+		RoutesV2: []config.RouteV2{{
+			Name:  "federated",
+			Match: config.RouteMatch{Path: "/graphql"},
+			Upstream: config.RouteUpstream{
+				Cluster: "accounts",
+				GraphQL: &config.RouteUpstreamGraphQL{Federation: &config.GraphQLFederation{
+					Supergraph: path,
+					Subgraphs: []config.GraphQLSubgraph{
+						{Name: "accounts", Cluster: "accounts", Endpoint: "/gql"},
+						{Name: "products", Cluster: "products", Endpoint: "/gql"},
+					},
+				}},
+			},
+		}},
+	}
+	cc, err := Compile(cfg, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	route, ok := cc.Router.Match(httptest.NewRequest("POST", "/graphql", nil))
+	if !ok || route.GraphQLFederation == nil {
+		t.Fatal("federated route not compiled")
+	}
+	return route, cc.Clusters["accounts"], func() []subgraphRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]subgraphRequest(nil), received...)
+	}
+}
+
+func TestGraphQLFederation_Query(t *testing.T) {
+	route, cluster, received := newFederatedRoute(t, func(graph string, req *graphql.Request) string {
+		if graph == "accounts" {
+			return `{"data":{"me":{"id":"1"}}}`
+		}
+		return `{"data":{"top":[{"upc":"a"}],"__typename":"Query"},"errors":[{"message":"partial"}]}`
+	})
+
+	req := postGraphQL(`{"query":"query Home($n: Int) { __typename top: topProducts(first: $n) { upc } me { id } }","operationName":"Home","variables":{"n":2}}`)
+	req.Header.Set("Authorization", "Bearer t")
+	w := doGraphQL(t, route, cluster, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+	want := `{"data":{"__typename":"Query","top":[{"upc":"a"}],"me":{"id":"1"}},"errors":[{"message":"partial"}]}` + "\n"
+	if w.Body.String() != want {
+		t.Errorf("unexpected response\n got %s\nwant %s", w.Body, want)
+	}
+
+	reqs := received()
+	if len(reqs) != 2 {
+		t.Fatalf("expected 2 subgraph requests, got %d", len(reqs))
+	}
+	for _, r := range reqs {
+		if r.header.Get("Authorization") != "Bearer t" || r.body.OperationName != "Home" {
+			t.Errorf("%s: headers or operation name not forwarded: %v %q", r.graph, r.header, r.body.OperationName)
+		}
+		switch r.graph {
+		case "accounts":
+			if r.body.Query != "query Home { me { id } }" || r.body.Variables != nil {
+				t.Errorf("unexpected accounts request %+v", r.body)
+			}
+		case "products":
+			if r.body.Query != "query Home($n: Int) { top: topProducts(first: $n) { upc } __typename }" || r.body.Variables["n"] != float64(2) {
+				t.Errorf("unexpected products request %+v", r.body)
+			}
+		}
+	}
+}
+
+func TestGraphQLFederation_Mutation(t *testing.T) {
+	route, cluster, received := newFederatedRoute(t, func(graph string, req *graphql.Request) string {
+		if graph == "products" {
+			return `not json`
+		}
+		if strings.Contains(req.Query, "a: login") {
+			return `{"data":{"a":{"id":"a"}}}`
+		}
+		return `{"data":{"c":{"id":"c"}}}`
+	})
+
+	w := doGraphQL(t, route, cluster, postGraphQL(`{"query":"mutation { a: login(name: \"a\") { id } addProduct(upc: \"1\") { upc } c: login(name: \"c\") { id } }"}`))
+	var resp struct {
+		Data   map[string]json.RawMessage `json:"data"`
+		Errors []graphql.Error            `json:"errors"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if string(resp.Data["a"]) != `{"id":"a"}` || string(resp.Data["addProduct"]) != "null" || string(resp.Data["c"]) != `{"id":"c"}` {
+		t.Errorf("unexpected data %s", w.Body)
+	}
+	if len(resp.Errors) != 1 || resp.Errors[0].Extensions["code"] != graphql.CodeSubgraphFailed || resp.Errors[0].Extensions["subgraph"] != "products" {
+		t.Errorf("unexpected errors %s", w.Body)
+	}
+
+	// Mutation root fields run in order, one subgraph at a time.
+	var order []string
+	for _, r := range received() {
+		order = append(order, r.graph)
+	}
+	if got := strings.Join(order, ","); got != "accounts,products,accounts" {
+		t.Errorf("subgraph order = %s", got)
+	}
+}
+
+func TestGraphQLFederation_Rejects(t *testing.T) {
+	route, cluster, received := newFederatedRoute(t, func(string, *graphql.Request) string { return `{"data":{}}` })
+	for _, body := range []string{
+		`{"query":"{ unknown }"}`,
+		`{"query":"{ __schema { types { name } } }"}`,
+		`{"extensions":{"persistedQuery":{"version":1,"sha256Hash":"` + graphql.QueryHash("{ me { id } }") + `"}}}`,
+	} {
+		w := doGraphQL(t, route, cluster, postGraphQL(body))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d: %s", body, w.Code, w.Body)
+		}
+	}
+	if n := len(received()); n != 0 {
+		t.Errorf("rejected requests reached %d subgraphs", n)
+	}
+}
+
+func TestCompile_GraphQLFederationErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "supergraph.graphql")
+	if err := os.WriteFile(path, []byte(testSupergraph), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for name, tt := range map[string]struct {
+		supergraph string
+		subgraphs  []config.GraphQLSubgraph
+		want       string
+	}{
+		"missing file":  {filepath.Join(t.TempDir(), "none"), nil, "read supergraph"},
+		"unmapped":      {path, []config.GraphQLSubgraph{{Name: "accounts", Cluster: "gql"}}, `subgraph "products" of the supergraph is not mapped`},
+		"unknown graph": {path, []config.GraphQLSubgraph{{Name: "accounts", Cluster: "gql"}, {Name: "products", Cluster: "gql"}, {Name: "reviews", Cluster: "gql"}}, `subgraph "reviews" is not defined`},
+		"http cluster":  {path, []config.GraphQLSubgraph{{Name: "accounts", Cluster: "web"}, {Name: "products", Cluster: "gql"}}, "not a graphql cluster"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			cfg := &config.Config{
+				Clusters: []config.Cluster{
+					{Name: "gql", Type: "graphql", Endpoints: []config.ClusterEndpoint{{URL: "http://gql:8080"}}},
+					{Name: "web", Type: "http", Endpoints: []config.ClusterEndpoint{{URL: "http://web:8080"}}},
+				},
+				RoutesV2: []config.RouteV2{{
+					Name:  "federated",
+					Match: config.RouteMatch{Path: "/graphql"},
+					Upstream: config.RouteUpstream{
+						Cluster: "gql",
+						GraphQL: &config.RouteUpstreamGraphQL{Federation: &config.GraphQLFederation{Supergraph: tt.supergraph, Subgraphs: tt.subgraphs}},
+					},
+				}},
+			}
+			if _, err := Compile(cfg, 1); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
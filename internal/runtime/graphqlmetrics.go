@@ -0,0 +1,156 @@
+package runtime
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/oriys/nexus/internal/config"
+	"github.com/oriys/nexus/internal/promtext"
+)
+
+const (
+	// maxGraphQLMetricSeries bounds the series each GraphQL metric keeps,
+	// since operation names and field paths come from clients.
+	maxGraphQLMetricSeries = 10000
+	// maxGraphQLMetricsResponseBytes is the largest response body inspected
+	// for errors.
+	maxGraphQLMetricsResponseBytes = 1 << 20
+)
+
+// graphqlMetrics holds the GraphQL metrics of all routes. It lives as long
+// as the process so that counters survive config reloads.
+var graphqlMetrics = &graphqlMetricSet{
+	operations: newGraphQLCounter("nexus_graphql_operations_total", "GraphQL operations executed, by route, operation name and operation type.", "type"),
+	errors:     newGraphQLCounter("nexus_graphql_errors_total", "Errors in GraphQL responses, by route, operation name and error code.", "code"),
+	fields:     newGraphQLCounter("nexus_graphql_field_usage_total", "Fields selected by sampled GraphQL operations, by route, operation name and field path.", "field"),
+}
+
+type graphqlMetricSet struct {
+	operations, errors, fields *graphqlCounter
+}
+
+// graphqlSeries identifies one series of a GraphQL counter. label holds the
+// value of the counter's own label.
+type graphqlSeries struct {
+	route, operation, label string
+}
+
+// graphqlCounter is a counter labelled by route, operation and one label of
+// its own.
+type graphqlCounter struct {
+	name, help, label string
+	mu                sync.Mutex
+	values            map[graphqlSeries]uint64
+	dropped           uint64
+}
+
+func newGraphQLCounter(name, help, label string) *graphqlCounter {
+	return &graphqlCounter{name: name, help: help, label: label, values: make(map[graphqlSeries]uint64)}
+}
+
+func (c *graphqlCounter) inc(s graphqlSeries) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.values[s]; !ok && len(c.values) >= maxGraphQLMetricSeries {
+		c.dropped++
+		return
+	}
+	c.values[s]++
+}
+
+// observeGraphQL records the metrics of one request to a GraphQL route: its
+// operation, the errors of the response rec captured and, when sampled, the
+// fields the operation selects. call is nil when the gateway rejected the
+// request before reading an operation.
+func observeGraphQL(route *CompiledRoute, cfg *config.GraphQLMetrics, call *graphqlCall, rec *bodyRecorder) {
+	var opName, opType string
+	if call != nil {
+		opName = call.req.OperationName
+		if call.op != nil {
+			opName, opType = call.op.Name, call.op.Type
+		}
+		graphqlMetrics.operations.inc(graphqlSeries{route.Name, opName, opType})
+	}
+	for _, code := range responseErrorCodes(rec) {
+		graphqlMetrics.errors.inc(graphqlSeries{route.Name, opName, code})
+	}
+	if call != nil && call.op != nil && cfg.FieldSampleRate > 0 && rand.Float64() < cfg.FieldSampleRate {
+		for _, path := range call.doc.FieldUsage(call.op) {
+			graphqlMetrics.fields.inc(graphqlSeries{route.Name, opName, path})
+		}
+	}
+}
+
+// responseErrorCodes returns the code of each error in the GraphQL response
+// rec captured: the "code" extension, or UNKNOWN. A failed response that is
+// not a GraphQL response counts as one HTTP_<status> error.
+func responseErrorCodes(rec *bodyRecorder) []string {
+	var resp struct {
+		Errors []struct {
+			Extensions struct {
+				Code interface{} `json:"code"`
+			} `json:"extensions"`
+		} `json:"errors"`
+	}
+	if rec.overflow || json.Unmarshal(rec.body.Bytes(), &resp) != nil {
+		if rec.status >= http.StatusBadRequest {
+			return []string{"HTTP_" + strconv.Itoa(rec.status)}
+		}
+		return nil
+	}
+	codes := make([]string, 0, len(resp.Errors))
+	for _, e := range resp.Errors {
+		code, _ := e.Extensions.Code.(string)
+		if code == "" {
+			code = "UNKNOWN"
+		}
+		codes = append(codes, code)
+	}
+	return codes
+}
+
+// WriteGraphQLMetrics writes the GraphQL metrics in the Prometheus text
+// exposition format.
+func WriteGraphQLMetrics(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	var dropped uint64
+	for _, c := range []*graphqlCounter{graphqlMetrics.operations, graphqlMetrics.errors, graphqlMetrics.fields} {
+		c.mu.Lock()
+		series := make([]graphqlSeries, 0, len(c.values))
+		for s := range c.values {
+			series = append(series, s)
+		}
+		values := make([]uint64, len(series))
+		sort.Slice(series, func(i, j int) bool {
+			a, b := series[i], series[j]
+			if a.route != b.route {
+				return a.route < b.route
+			}
+			if a.operation != b.operation {
+				return a.operation < b.operation
+			}
+			return a.label < b.label
+		})
+		for i, s := range series {
+			values[i] = c.values[s]
+		}
+		dropped += c.dropped
+		c.mu.Unlock()
+
+		fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+		for i, s := range series {
+			fmt.Fprintf(bw, "%s{route=%s,operation=%s,%s=%s} %d\n", c.name,
+				promtext.QuoteLabel(s.route), promtext.QuoteLabel(s.operation), c.label, promtext.QuoteLabel(s.label), values[i])
+		}
+	}
+	fmt.Fprintf(bw, "# HELP nexus_graphql_metrics_dropped_total Observations not recorded because a GraphQL metric reached its series limit.\n")
+	fmt.Fprintf(bw, "# TYPE nexus_graphql_metrics_dropped_total counter\nnexus_graphql_metrics_dropped_total %d\n", dropped)
+	return bw.Flush()
+}
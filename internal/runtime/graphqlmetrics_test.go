@@ -0,0 +1,78 @@
+package runtime
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/oriys/nexus/internal/config"
+)
+
+func TestGraphQLMetrics(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept-Encoding") != "gzip" {
+			t.Errorf("expected the transport to negotiate compression, got %q", r.Header.Get("Accept-Encoding"))
+		}
+		body := new(bytes.Buffer)
+		body.ReadFrom(r.Body)
+		switch {
+		case strings.Contains(body.String(), "Broken"):
+			http.Error(w, "boom", http.StatusBadGateway)
+		case strings.Contains(body.String(), "Partial"):
+			w.Write([]byte(`{"data":{"user":null},"errors":[{"message":"denied","extensions":{"code":"FORBIDDEN"}},{"message":"?"}]}`))
+		default:
+			w.Write([]byte(`{"data":{}}`))
+		}
+	}))
+	defer backend.Close()
+	cluster := &CompiledCluster{Name: "graphql-svc", Type: "graphql", Endpoints: []config.ClusterEndpoint{{URL: backend.URL}}}
+	route := &CompiledRoute{
+		Name: "metrics-test",
+		Upstream: RouteUpstreamConfig{ClusterName: "graphql-svc", GraphQL: &config.RouteUpstreamGraphQL{
+			Metrics: &config.GraphQLMetrics{FieldSampleRate: 1},
+		}},
+	}
+
+	for _, body := range []string{
+		`{"query":"query Partial { user(id: 1) { legacyName: name email } }"}`,
+		`{"query":"query Partial { user(id: 2) { name } }"}`,
+		`{"query":"query Broken { a }"}`,
+		`{"query":"{ a "}`,
+	} {
+		req := postGraphQL(body)
+		req.Header.Set("Accept-Encoding", "br")
+		doGraphQL(t, route, cluster, req)
+	}
+
+	var out bytes.Buffer
+	if err := WriteGraphQLMetrics(&out); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		`nexus_graphql_operations_total{route="metrics-test",operation="Partial",type="query"} 2`,
+		`nexus_graphql_operations_total{route="metrics-test",operation="Broken",type="query"} 1`,
+		`nexus_graphql_errors_total{route="metrics-test",operation="Partial",code="FORBIDDEN"} 2`,
+		`nexus_graphql_errors_total{route="metrics-test",operation="Partial",code="UNKNOWN"} 2`,
+		`nexus_graphql_errors_total{route="metrics-test",operation="Broken",code="HTTP_502"} 1`,
+		`nexus_graphql_errors_total{route="metrics-test",operation="",code="GRAPHQL_PARSE_FAILED"} 1`,
+		`nexus_graphql_field_usage_total{route="metrics-test",operation="Partial",field="user.name"} 2`,
+		`nexus_graphql_field_usage_total{route="metrics-test",operation="Partial",field="user.email"} 1`,
+	} {
+		if !strings.Contains(out.String(), line+"\n") {
+			t.Errorf("missing %s in\n%s", line, out.String())
+		}
+	}
+}
+
+func TestGraphQLCounter_SeriesLimit(t *testing.T) {
+	c := newGraphQLCounter("test_total", "Test.", "label")
+	for i := 0; i <= maxGraphQLMetricSeries; i++ {
+		c.inc(graphqlSeries{route: "r", label: strings.Repeat("x", i%100) + string(rune('a'+i/100))})
+	}
+	c.inc(graphqlSeries{route: "r", label: "a"})
+	if len(c.values) != maxGraphQLMetricSeries || c.dropped != 1 || c.values[graphqlSeries{route: "r", label: "a"}] != 2 {
+		t.Errorf("unexpected counter state: %d series, %d dropped", len(c.values), c.dropped)
+	}
+}
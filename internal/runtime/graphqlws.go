@@ -0,0 +1,387 @@
+package runtime
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/oriys/nexus/internal/auth"
+	"github.com/oriys/nexus/internal/config"
+	"github.com/oriys/nexus/internal/graphql"
+	"github.com/oriys/nexus/internal/httperror"
+)
+
+// WebSocket subprotocols of GraphQL subscriptions.
+const (
+	// graphqlTransportWS is the protocol of the graphql-ws library.
+	graphqlTransportWS = "graphql-transport-ws"
+	// graphqlWS is the legacy protocol of subscriptions-transport-ws.
+	graphqlWS = "graphql-ws"
+)
+
+const (
+	defaultGraphQLInitTimeout = 10 * time.Second
+	// graphqlWSDialTimeout bounds connecting to the upstream and completing
+	// the WebSocket handshake.
+	graphqlWSDialTimeout = 10 * time.Second
+	// maxGraphQLInitFrameBytes is the largest connection_init message read
+	// when authenticating connections.
+	maxGraphQLInitFrameBytes = 64 << 10
+)
+
+// Close codes of graphql-transport-ws.
+const (
+	wsCloseBadRequest  = 4400
+	wsCloseForbidden   = 4403
+	wsCloseInitTimeout = 4408
+)
+
+// graphqlSubscriptionConns counts the open subscription connections of each
+// route. It lives as long as the process so that limits hold across config
+// reloads while connections stay open.
+var graphqlSubscriptionConns sync.Map // route name → *atomic.Int64
+
+// GraphQLSubscriptions proxies the WebSocket subscription connections of a
+// GraphQL route.
+type GraphQLSubscriptions struct {
+	conns          *atomic.Int64
+	maxConnections int64
+	initTimeout    time.Duration
+	// authenticator checks connection_init payloads; nil forwards them
+	// unchecked.
+	authenticator auth.Authenticator
+}
+
+func newGraphQLSubscriptions(route string, cfg *config.GraphQLSubscriptions, authenticator auth.Authenticator) *GraphQLSubscriptions {
+	conns, _ := graphqlSubscriptionConns.LoadOrStore(route, new(atomic.Int64))
+	s := &GraphQLSubscriptions{
+		conns:          conns.(*atomic.Int64),
+		maxConnections: int64(cfg.MaxConnections),
+		initTimeout:    defaultGraphQLInitTimeout,
+		authenticator:  authenticator,
+	}
+	if d := cfg.InitTimeoutDuration(); d > 0 {
+		s.initTimeout = d
+	}
+	return s
+}
+
+// compileGraphQLSubscriptions builds the subscription proxy of a route,
+// authenticating connections with the gateway API keys when asked to. A dry
+// run only checks the settings and returns nil, leaving the connection
+// counters alone.
+func compileGraphQLSubscriptions(route string, cfg *config.GraphQLSubscriptions, authCfg config.AuthConfig, dry bool) (*GraphQLSubscriptions, error) {
+	var authenticator auth.Authenticator
+	if cfg.AuthenticateInit {
+		if !authCfg.APIKey.Enabled || len(authCfg.APIKey.Keys) == 0 {
+			return nil, errors.New("subscriptions.authenticate_init requires auth.api_key to be enabled")
+		}
+		authenticator = auth.NewAPIKeyAuthenticator(authCfg.APIKey.Keys)
+	}
+	if dry {
+		return nil, nil
+	}
+	return newGraphQLSubscriptions(route, cfg, authenticator), nil
+}
+
+// isWebSocketUpgrade reports whether r asks to switch to WebSocket.
+func isWebSocketUpgrade(r *http.Request) bool {
+	return r.Method == http.MethodGet && headerHasToken(r.Header, "Connection", "upgrade") &&
+		strings.EqualFold(r.Header.Get("Upgrade"), "websocket")
+}
+
+func headerHasToken(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// graphqlSubprotocol returns the first GraphQL subprotocol offered by r, or "".
+func graphqlSubprotocol(r *http.Request) string {
+	for _, v := range r.Header.Values("Sec-WebSocket-Protocol") {
+		for _, p := range strings.Split(v, ",") {
+			if p = strings.TrimSpace(p); p == graphqlTransportWS || p == graphqlWS {
+				return p
+			}
+		}
+	}
+	return ""
+}
+
+// serve proxies the WebSocket connection r asks for to the GraphQL endpoint
+// of target. The upstream performs the handshake; when authenticating, the
+// client's connection_init is checked before it is forwarded.
+func (s *GraphQLSubscriptions) serve(w http.ResponseWriter, r *http.Request, route *CompiledRoute, target *url.URL, addr string) {
+	protocol := graphqlSubprotocol(r)
+	if protocol == "" {
+		graphql.WriteError(w, http.StatusBadRequest, graphql.CodeBadRequest,
+			"unsupported WebSocket subprotocol, expected "+graphqlTransportWS+" or "+graphqlWS)
+		return
+	}
+	if n := s.conns.Add(1); s.maxConnections > 0 && n > s.maxConnections {
+		s.conns.Add(-1)
+		graphql.WriteError(w, http.StatusServiceUnavailable, graphql.CodeSubscriptionLimit, "too many subscription connections")
+		return
+	}
+	defer s.conns.Add(-1)
+
+	endpoint := "/graphql"
+	if g := route.Upstream.GraphQL; g != nil && g.Endpoint != "" {
+		endpoint = g.Endpoint
+	}
+	upstream, resp, err := dialGraphQLWS(r, target, endpoint)
+	if err != nil {
+		slog.Error("graphql subscription proxy error",
+			slog.String("route", route.Name),
+			slog.String("target", addr),
+			slog.String("error", err.Error()),
+		)
+		httperror.Write(w, r, http.StatusBadGateway, httperror.CodeBadGateway, "bad gateway")
+		return
+	}
+	defer upstream.Close()
+
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		// The upstream refused the upgrade; relay its answer.
+		defer resp.Body.Close()
+		for k, vv := range resp.Header {
+			w.Header()[k] = vv
+		}
+		w.WriteHeader(resp.StatusCode)
+		io.Copy(w, resp.Body)
+		return
+	}
+
+	client, clientBuf, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		slog.Error("graphql subscription hijack failed", slog.String("route", route.Name), slog.String("error", err.Error()))
+		httperror.Write(w, r, http.StatusInternalServerError, httperror.CodeInternal, "websocket not supported")
+		return
+	}
+	defer client.Close()
+	// Subscriptions outlive the server's request timeouts.
+	client.SetDeadline(time.Time{})
+	fmt.Fprintf(clientBuf, "HTTP/1.1 %s\r\n", resp.Status)
+	resp.Header.Write(clientBuf)
+	clientBuf.WriteString("\r\n")
+	if err := clientBuf.Flush(); err != nil {
+		return
+	}
+
+	if s.authenticator != nil {
+		if !s.authenticate(client, clientBuf.Reader, upstream.conn, r, resp.Header.Get("Sec-WebSocket-Protocol")) {
+			return
+		}
+	}
+
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(upstream.conn, clientBuf.Reader)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(client, upstream.br)
+		done <- struct{}{}
+	}()
+	<-done
+}
+
+// authenticate reads the client's connection_init from br and checks its
+// payload. An accepted message is forwarded upstream; otherwise the client
+// connection is closed with the graphql-transport-ws code for the failure.
+func (s *GraphQLSubscriptions) authenticate(client net.Conn, br *bufio.Reader, upstream net.Conn, r *http.Request, protocol string) bool {
+	client.SetReadDeadline(time.Now().Add(s.initTimeout))
+	raw, payload, err := readWSFrame(br, maxGraphQLInitFrameBytes)
+	if err != nil {
+		var ne net.Error
+		if errors.As(err, &ne) && ne.Timeout() {
+			writeWSClose(client, wsCloseInitTimeout, "Connection initialisation timeout")
+		} else {
+			writeWSClose(client, wsCloseBadRequest, "Invalid connection_init message")
+		}
+		return false
+	}
+	client.SetReadDeadline(time.Time{})
+
+	var msg struct {
+		Type    string                 `json:"type"`
+		Payload map[string]interface{} `json:"payload"`
+	}
+	if json.Unmarshal(payload, &msg) != nil || msg.Type != "connection_init" {
+		writeWSClose(client, wsCloseBadRequest, "Invalid connection_init message")
+		return false
+	}
+	authReq := &http.Request{Header: r.Header.Clone(), URL: r.URL}
+	for k, v := range msg.Payload {
+		if str, ok := v.(string); ok {
+			authReq.Header.Set(k, str)
+		}
+	}
+	if _, err := s.authenticator.Authenticate(authReq); err != nil {
+		if protocol == graphqlWS {
+			// The legacy protocol reports the failure before closing.
+			b, _ := json.Marshal(map[string]interface{}{
+				"type":    "connection_error",
+				"payload": map[string]string{"message": err.Error()},
+			})
+			writeWSFrame(client, wsOpText, b)
+		}
+		writeWSClose(client, wsCloseForbidden, "Forbidden")
+		return false
+	}
+	_, err = upstream.Write(raw)
+	return err == nil
+}
+
+// graphqlWSConn is a WebSocket connection to the upstream. br holds the
+// bytes read past the handshake response.
+type graphqlWSConn struct {
+	conn net.Conn
+	br   *bufio.Reader
+}
+
+func (c *graphqlWSConn) Close() error { return c.conn.Close() }
+
+// dialGraphQLWS connects to target and sends r's upgrade request to
+// endpoint, returning the connection and the upstream's handshake response.
+func dialGraphQLWS(r *http.Request, target *url.URL, endpoint string) (*graphqlWSConn, *http.Response, error) {
+	ctx, cancel := context.WithTimeout(r.Context(), graphqlWSDialTimeout)
+	defer cancel()
+
+	host, port := target.Hostname(), target.Port()
+	dialer := &net.Dialer{}
+	var conn net.Conn
+	var err error
+	if target.Scheme == "https" || target.Scheme == "wss" {
+		if port == "" {
+			port = "443"
+		}
+		td := &tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: host, NextProtos: []string{"http/1.1"}}}
+		conn, err = td.DialContext(ctx, "tcp", net.JoinHostPort(host, port))
+	} else {
+		if port == "" {
+			port = "80"
+		}
+		conn, err = dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, port))
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+
+	out := r.Clone(ctx)
+	out.URL = &url.URL{Scheme: target.Scheme, Host: target.Host, Path: path.Join("/", target.Path, endpoint), RawQuery: r.URL.RawQuery}
+	out.Host = r.Host
+	out.RequestURI = ""
+	out.Body = nil
+	out.ContentLength = 0
+	for _, h := range []string{"Keep-Alive", "Proxy-Connection", "Te", "Trailer", "Transfer-Encoding"} {
+		out.Header.Del(h)
+	}
+	out.Header.Set("Connection", "Upgrade")
+	out.Header.Set("Upgrade", "websocket")
+	if err := out.Write(conn); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, out)
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	return &graphqlWSConn{conn: conn, br: br}, resp, nil
+}
+
+// WebSocket frame opcodes.
+const (
+	wsOpText  = 0x1
+	wsOpClose = 0x8
+)
+
+// readWSFrame reads one masked client frame holding a complete text
+// message, returning the frame as received and its unmasked payload.
+func readWSFrame(br *bufio.Reader, maxPayload int) (raw, payload []byte, err error) {
+	head := make([]byte, 2, 14)
+	if _, err := io.ReadFull(br, head); err != nil {
+		return nil, nil, err
+	}
+	if head[0] != 0x80|wsOpText {
+		return nil, nil, errors.New("expected a complete text frame")
+	}
+	if head[1]&0x80 == 0 {
+		return nil, nil, errors.New("client frame is not masked")
+	}
+	n := uint64(head[1] & 0x7f)
+	switch n {
+	case 126:
+		head = head[:4]
+		if _, err := io.ReadFull(br, head[2:]); err != nil {
+			return nil, nil, err
+		}
+		n = uint64(binary.BigEndian.Uint16(head[2:]))
+	case 127:
+		head = head[:10]
+		if _, err := io.ReadFull(br, head[2:]); err != nil {
+			return nil, nil, err
+		}
+		n = binary.BigEndian.Uint64(head[2:])
+	}
+	if n > uint64(maxPayload) {
+		return nil, nil, fmt.Errorf("frame of %d bytes exceeds %d bytes", n, maxPayload)
+	}
+	raw = make([]byte, len(head)+4+int(n))
+	copy(raw, head)
+	if _, err := io.ReadFull(br, raw[len(head):]); err != nil {
+		return nil, nil, err
+	}
+	mask := raw[len(head) : len(head)+4]
+	payload = make([]byte, n)
+	for i, b := range raw[len(head)+4:] {
+		payload[i] = b ^ mask[i%4]
+	}
+	return raw, payload, nil
+}
+
+// writeWSFrame writes an unmasked server frame.
+func writeWSFrame(w io.Writer, opcode byte, payload []byte) error {
+	frame := []byte{0x80 | opcode}
+	switch n := len(payload); {
+	case n < 126:
+		frame = append(frame, byte(n))
+	case n <= 0xffff:
+		frame = append(frame, 126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(n))
+	default:
+		frame = append(frame, 127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(n))
+	}
+	_, err := w.Write(append(frame, payload...))
+	return err
+}
+
+// writeWSClose writes a close frame with the given code and reason.
+func writeWSClose(w io.Writer, code int, reason string) error {
+	return writeWSFrame(w, wsOpClose, append(binary.BigEndian.AppendUint16(nil, uint16(code)), reason...))
+}
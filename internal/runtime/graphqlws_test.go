@@ -0,0 +1,266 @@
+package runtime
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oriys/nexus/internal/auth"
+	"github.com/oriys/nexus/internal/config"
+	"github.com/oriys/nexus/internal/graphql"
+)
+
+// newSubscriptionRoute serves a GraphQL route with subscriptions in front of
+// a WebSocket upstream that acknowledges connection_init. Messages received
+// by the upstream are sent on the returned channel.
+func newSubscriptionRoute(t *testing.T, name string, subs *GraphQLSubscriptions) (gateway string, received <-chan string) {
+	t.Helper()
+	msgs := make(chan string, 10)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/subscriptions" || !isWebSocketUpgrade(r) {
+			t.Errorf("unexpected upstream request %s %s %v", r.Method, r.URL.Path, r.Header)
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer t" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		conn, buf, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		buf.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n")
+		buf.WriteString("Sec-WebSocket-Protocol: " + graphqlSubprotocol(r) + "\r\n\r\n")
+		buf.Flush()
+		for {
+			_, payload, err := readWSFrame(buf.Reader, 1<<20)
+			if err != nil {
+				return
+			}
+			msgs <- string(payload)
+			if strings.Contains(string(payload), "connection_init") {
+				writeWSFrame(conn, wsOpText, []byte(`{"type":"connection_ack"}`))
+			}
+		}
+	}))
+	t.Cleanup(upstream.Close)
+
+	cluster := &CompiledCluster{Name: "graphql-svc", Type: "graphql", Endpoints: []config.ClusterEndpoint{{URL: upstream.URL}}}
+	route := &CompiledRoute{
+		Name: name,
+		Upstream: RouteUpstreamConfig{ClusterName: "graphql-svc", GraphQL: &config.RouteUpstreamGraphQL{
+			Endpoint: "/subscriptions",
+		}},
+		GraphQLSubscriptions: subs,
+	}
+	gw := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := (&GraphQLUpstream{}).Handle(w, r, route, cluster); err != nil {
+			t.Errorf("Handle: %v", err)
+		}
+	}))
+	t.Cleanup(gw.Close)
+	return gw.Listener.Addr().String(), msgs
+}
+
+// dialSubscription opens a WebSocket connection to the gateway at addr,
+// offering protocol, and returns the handshake response.
+func dialSubscription(t *testing.T, addr, protocol string) (net.Conn, *bufio.Reader, *http.Response) {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	req, _ := http.NewRequest("GET", "http://"+addr+"/graphql", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	req.Header.Set("Sec-WebSocket-Protocol", protocol)
+	req.Header.Set("Authorization", "Bearer t")
+	if err := req.Write(conn); err != nil {
+		t.Fatal(err)
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn, br, resp
+}
+
+// sendMessage writes msg as a masked client text frame.
+func sendMessage(t *testing.T, conn net.Conn, msg string) {
+	t.Helper()
+	mask := []byte{1, 2, 3, 4}
+	frame := []byte{0x80 | wsOpText, 0x80 | byte(len(msg))}
+	frame = append(frame, mask...)
+	for i := 0; i < len(msg); i++ {
+		frame = append(frame, msg[i]^mask[i%4])
+	}
+	if _, err := conn.Write(frame); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readServerFrame reads an unmasked server frame.
+func readServerFrame(t *testing.T, conn net.Conn, br *bufio.Reader) (opcode byte, payload []byte) {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	head := make([]byte, 2)
+	if _, err := io.ReadFull(br, head); err != nil {
+		t.Fatal(err)
+	}
+	payload = make([]byte, head[1]&0x7f)
+	if _, err := io.ReadFull(br, payload); err != nil {
+		t.Fatal(err)
+	}
+	return head[0] & 0x0f, payload
+}
+
+func expectClose(t *testing.T, conn net.Conn, br *bufio.Reader, code int) {
+	t.Helper()
+	op, payload := readServerFrame(t, conn, br)
+	if op != wsOpClose || len(payload) < 2 || int(binary.BigEndian.Uint16(payload)) != code {
+		t.Fatalf("expected close %d, got opcode %d %q", code, op, payload)
+	}
+}
+
+func TestGraphQLSubscriptions_Proxy(t *testing.T) {
+	addr, received := newSubscriptionRoute(t, "subs-proxy", newGraphQLSubscriptions("subs-proxy", &config.GraphQLSubscriptions{}, nil))
+	conn, br, resp := dialSubscription(t, addr, "graphql-transport-ws")
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Sec-WebSocket-Protocol") != "graphql-transport-ws" {
+		t.Fatalf("unexpected handshake %d %v", resp.StatusCode, resp.Header)
+	}
+	sendMessage(t, conn, `{"type":"connection_init"}`)
+	if op, payload := readServerFrame(t, conn, br); op != wsOpText || string(payload) != `{"type":"connection_ack"}` {
+		t.Errorf("unexpected frame %d %s", op, payload)
+	}
+	sendMessage(t, conn, `{"id":"1","type":"subscribe","payload":{"query":"subscription { ping }"}}`)
+	<-received
+	if msg := <-received; !strings.Contains(msg, `"subscribe"`) {
+		t.Errorf("unexpected upstream message %s", msg)
+	}
+}
+
+func TestGraphQLSubscriptions_Rejects(t *testing.T) {
+	addr, _ := newSubscriptionRoute(t, "subs-rejects", newGraphQLSubscriptions("subs-rejects", &config.GraphQLSubscriptions{}, nil))
+	if _, _, resp := dialSubscription(t, addr, "chat"); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown subprotocol, got %d", resp.StatusCode)
+	}
+	// Plain GraphQL requests are still served over HTTP.
+	resp, err := http.Get("http://" + addr + "/graphql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 for a GET without query, got %d", resp.StatusCode)
+	}
+}
+
+func TestGraphQLSubscriptions_ConnectionLimit(t *testing.T) {
+	addr, _ := newSubscriptionRoute(t, "subs-limit", newGraphQLSubscriptions("subs-limit", &config.GraphQLSubscriptions{MaxConnections: 1}, nil))
+	conn, br, resp := dialSubscription(t, addr, "graphql-ws")
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("expected 101, got %d", resp.StatusCode)
+	}
+	_, _, resp = dialSubscription(t, addr, "graphql-ws")
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 beyond the limit, got %d", resp.StatusCode)
+	}
+	var body struct{ Errors []graphql.Error }
+	json.NewDecoder(resp.Body).Decode(&body)
+	if len(body.Errors) != 1 || body.Errors[0].Extensions["code"] != graphql.CodeSubscriptionLimit {
+		t.Errorf("unexpected body %+v", body)
+	}
+
+	// Closing the connection frees its slot.
+	conn.Close()
+	io.Copy(io.Discard, br)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		_, _, resp = dialSubscription(t, addr, "graphql-ws")
+		if resp.StatusCode == http.StatusSwitchingProtocols || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Errorf("expected the slot to be released, got %d", resp.StatusCode)
+	}
+}
+
+func TestGraphQLSubscriptions_AuthenticateInit(t *testing.T) {
+	authenticator := auth.NewAPIKeyAuthenticator(map[string]string{"secret": "alice"})
+	subs := newGraphQLSubscriptions("subs-auth", &config.GraphQLSubscriptions{InitTimeoutMs: 100}, authenticator)
+	addr, received := newSubscriptionRoute(t, "subs-auth", subs)
+
+	conn, br, _ := dialSubscription(t, addr, "graphql-transport-ws")
+	sendMessage(t, conn, `{"type":"connection_init","payload":{"X-API-Key":"wrong"}}`)
+	expectClose(t, conn, br, wsCloseForbidden)
+
+	conn, br, _ = dialSubscription(t, addr, "graphql-ws")
+	sendMessage(t, conn, `{"type":"connection_init","payload":{}}`)
+	if op, payload := readServerFrame(t, conn, br); op != wsOpText || !strings.Contains(string(payload), `"connection_error"`) {
+		t.Errorf("expected a connection_error message, got %d %s", op, payload)
+	}
+	expectClose(t, conn, br, wsCloseForbidden)
+
+	conn, br, _ = dialSubscription(t, addr, "graphql-transport-ws")
+	sendMessage(t, conn, `{"type":"subscribe"}`)
+	expectClose(t, conn, br, wsCloseBadRequest)
+
+	conn, br, _ = dialSubscription(t, addr, "graphql-transport-ws")
+	expectClose(t, conn, br, wsCloseInitTimeout)
+
+	select {
+	case msg := <-received:
+		t.Fatalf("rejected connection reached the upstream: %s", msg)
+	default:
+	}
+
+	conn, br, _ = dialSubscription(t, addr, "graphql-transport-ws")
+	sendMessage(t, conn, `{"type":"connection_init","payload":{"X-API-Key":"secret"}}`)
+	if op, payload := readServerFrame(t, conn, br); op != wsOpText || string(payload) != `{"type":"connection_ack"}` {
+		t.Errorf("unexpected frame %d %s", op, payload)
+	}
+	if msg := <-received; !strings.Contains(msg, "secret") {
+		t.Errorf("connection_init not forwarded: %s", msg)
+	}
+}
+
+func TestCompile_GraphQLSubscriptionsAuth(t *testing.T) {
+	cfg := &config.Config{
+		Clusters: []config.Cluster{{Name: "gql", Type: "graphql", Endpoints: []config.ClusterEndpoint{{URL: "http://gql:8080"}}}},
+		RoutesV2: []config.RouteV2{{
+			Name:  "subs",
+			Match: config.RouteMatch{Path: "/graphql"},
+			Upstream: config.RouteUpstream{Cluster: "gql", GraphQL: &config.RouteUpstreamGraphQL{
+				Subscriptions: &config.GraphQLSubscriptions{AuthenticateInit: true},
+			}},
+		}},
+	}
+	if _, err := Compile(cfg, 1); err == nil || !strings.Contains(err.Error(), "requires auth.api_key") {
+		t.Errorf("expected auth error, got %v", err)
+	}
+	cfg.Auth.APIKey = config.APIKeyConfig{Enabled: true, Keys: map[string]string{"k": "svc"}}
+	cc, err := Compile(cfg, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	route, ok := cc.Router.Match(httptest.NewRequest("GET", "/graphql", nil))
+	if !ok || route.GraphQLSubscriptions == nil || route.GraphQLSubscriptions.authenticator == nil {
+		t.Error("subscriptions not compiled")
+	}
+}
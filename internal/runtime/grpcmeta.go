@@ -0,0 +1,171 @@
+package runtime
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// grpcAlwaysForwarded lists headers kept regardless of a route's metadata
+// allowlist: protocol headers the upstream needs, the deadline and trace
+// context.
+var grpcAlwaysForwarded = map[string]struct{}{
+	"Content-Type":         {},
+	"Te":                   {},
+	"User-Agent":           {},
+	"Grpc-Timeout":         {},
+	"Grpc-Encoding":        {},
+	"Grpc-Accept-Encoding": {},
+	"Traceparent":          {},
+	"Tracestate":           {},
+	"Grpc-Trace-Bin":       {},
+	"X-Request-Id":         {},
+}
+
+// filterGRPCMetadata removes headers that are neither allowed nor always
+// forwarded. A nil allowlist keeps every header.
+func filterGRPCMetadata(h http.Header, allow map[string]struct{}) {
+	if allow == nil {
+		return
+	}
+	for name := range h {
+		if _, ok := grpcAlwaysForwarded[name]; ok {
+			continue
+		}
+		if _, ok := allow[name]; ok {
+			continue
+		}
+		h.Del(name)
+	}
+}
+
+// grpcDeadline returns the timeout to apply to a gRPC call: the route timeout,
+// or the caller's own grpc-timeout if that is shorter. Zero means no deadline.
+func grpcDeadline(r *http.Request, timeoutMs int) time.Duration {
+	d := time.Duration(timeoutMs) * time.Millisecond
+	if inbound, ok := parseGRPCTimeout(r.Header.Get("Grpc-Timeout")); ok && (d == 0 || inbound < d) {
+		d = inbound
+	}
+	return d
+}
+
+// grpcTimeoutUnits are the grpc-timeout units, finest first.
+var grpcTimeoutUnits = []struct {
+	unit byte
+	d    time.Duration
+}{
+	{'n', time.Nanosecond},
+	{'u', time.Microsecond},
+	{'m', time.Millisecond},
+	{'S', time.Second},
+	{'M', time.Minute},
+	{'H', time.Hour},
+}
+
+// encodeGRPCTimeout formats d as a grpc-timeout header value, using the
+// finest unit whose value fits in the protocol's eight digits.
+func encodeGRPCTimeout(d time.Duration) string {
+	const maxValue = 99999999
+	for _, u := range grpcTimeoutUnits {
+		// Round up so the upstream never sees a longer deadline than ours.
+		v := (d + u.d - 1) / u.d
+		if v <= maxValue {
+			return strconv.FormatInt(int64(v), 10) + string(u.unit)
+		}
+	}
+	return strconv.Itoa(maxValue) + "H"
+}
+
+// parseGRPCTimeout parses a grpc-timeout header value such as "250m".
+func parseGRPCTimeout(v string) (time.Duration, bool) {
+	if len(v) < 2 || len(v) > 9 {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(v[:len(v)-1], 10, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	unit := v[len(v)-1]
+	for _, u := range grpcTimeoutUnits {
+		if u.unit == unit {
+			return time.Duration(n) * u.d, true
+		}
+	}
+	return 0, false
+}
+
+// grpcTraceBin converts a W3C traceparent header into the binary
+// grpc-trace-bin metadata understood by OpenCensus-instrumented gRPC servers.
+// The value is base64-encoded as required for "-bin" metadata keys.
+func grpcTraceBin(traceparent string) (string, bool) {
+	parts := strings.Split(traceparent, "-")
+	if len(parts) != 4 || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return "", false
+	}
+	traceID, err1 := hex.DecodeString(parts[1])
+	spanID, err2 := hex.DecodeString(parts[2])
+	flags, err3 := hex.DecodeString(parts[3])
+	if err1 != nil || err2 != nil || err3 != nil {
+		return "", false
+	}
+
+	// version 0, then field 0 (trace ID), field 1 (span ID), field 2 (options).
+	b := make([]byte, 0, 29)
+	b = append(b, 0, 0)
+	b = append(b, traceID...)
+	b = append(b, 1)
+	b = append(b, spanID...)
+	b = append(b, 2, flags[0]&1)
+	return base64.RawStdEncoding.EncodeToString(b), true
+}
+
+// GRPCAllowlist is the set of services and methods a passthrough gRPC route
+// forwards.
+type GRPCAllowlist struct {
+	services map[string]struct{} // "pkg.Service"
+	methods  map[string]struct{} // "pkg.Service/Method"
+}
+
+func newGRPCAllowlist(entries []string) *GRPCAllowlist {
+	a := &GRPCAllowlist{
+		services: make(map[string]struct{}),
+		methods:  make(map[string]struct{}),
+	}
+	for _, e := range entries {
+		if strings.Contains(e, "/") {
+			a.methods[e] = struct{}{}
+		} else {
+			a.services[e] = struct{}{}
+		}
+	}
+	return a
+}
+
+// Allows reports whether service/method may be called. A nil allowlist
+// allows everything.
+func (a *GRPCAllowlist) Allows(service, method string) bool {
+	if a == nil {
+		return true
+	}
+	if _, ok := a.services[service]; ok {
+		return true
+	}
+	_, ok := a.methods[service+"/"+method]
+	return ok
+}
+
+// parseGRPCPath splits a gRPC request path of the form /pkg.Service/Method.
+func parseGRPCPath(path string) (service, method string, ok bool) {
+	rest, found := strings.CutPrefix(path, "/")
+	if !found {
+		return "", "", false
+	}
+	service, method, found = strings.Cut(rest, "/")
+	if !found || service == "" || method == "" || strings.Contains(method, "/") {
+		return "", "", false
+	}
+	return service, method, true
+}
@@ -0,0 +1,335 @@
+package runtime
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oriys/nexus/internal/config"
+	"github.com/oriys/nexus/internal/transcode"
+)
+
+func TestEncodeGRPCTimeout(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{1500 * time.Nanosecond, "1500n"},
+		{250 * time.Millisecond, "250000u"},
+		{2 * time.Second, "2000000u"},
+		{30 * time.Minute, "1800000m"},
+		{1000 * time.Hour, "3600000S"},
+	}
+	for _, tt := range tests {
+		if got := encodeGRPCTimeout(tt.d); got != tt.want {
+			t.Errorf("encodeGRPCTimeout(%v) = %s, want %s", tt.d, got, tt.want)
+		}
+		if back, ok := parseGRPCTimeout(tt.want); !ok || back != tt.d {
+			t.Errorf("parseGRPCTimeout(%s) = %v, %v", tt.want, back, ok)
+		}
+	}
+}
+
+func TestParseGRPCTimeout_Invalid(t *testing.T) {
+	for _, v := range []string{"", "m", "10", "10x", "-5m", "123456789m"} {
+		if _, ok := parseGRPCTimeout(v); ok {
+			t.Errorf("expected %q to be rejected", v)
+		}
+	}
+}
+
+func TestGRPCDeadline(t *testing.T) {
+	r := httptest.NewRequest("POST", "/", nil)
+	if d := grpcDeadline(r, 0); d != 0 {
+		t.Errorf("expected no deadline, got %v", d)
+	}
+	if d := grpcDeadline(r, 500); d != 500*time.Millisecond {
+		t.Errorf("expected route timeout, got %v", d)
+	}
+	r.Header.Set("Grpc-Timeout", "100m")
+	if d := grpcDeadline(r, 500); d != 100*time.Millisecond {
+		t.Errorf("expected shorter inbound timeout, got %v", d)
+	}
+	r.Header.Set("Grpc-Timeout", "2S")
+	if d := grpcDeadline(r, 500); d != 500*time.Millisecond {
+		t.Errorf("expected shorter route timeout, got %v", d)
+	}
+}
+
+func TestGRPCTraceBin(t *testing.T) {
+	bin, ok := grpcTraceBin("00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	if !ok {
+		t.Fatal("expected valid traceparent")
+	}
+	raw, err := base64.RawStdEncoding.DecodeString(bin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(raw) != 29 || raw[0] != 0 || raw[1] != 0 || raw[18] != 1 || raw[27] != 2 || raw[28] != 1 {
+		t.Errorf("unexpected grpc-trace-bin layout %x", raw)
+	}
+	if raw[2] != 0x0a || raw[19] != 0xb7 {
+		t.Errorf("unexpected trace/span bytes %x", raw)
+	}
+
+	for _, bad := range []string{"", "00-abc-def-01", "00-zzf7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"} {
+		if _, ok := grpcTraceBin(bad); ok {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestFilterGRPCMetadata(t *testing.T) {
+	h := http.Header{}
+	h.Set("Content-Type", "application/grpc")
+	h.Set("Authorization", "Bearer x")
+	h.Set("X-Tenant", "acme")
+	h.Set("Cookie", "session=1")
+	h.Set("Traceparent", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+
+	filterGRPCMetadata(h, map[string]struct{}{"X-Tenant": {}})
+
+	for _, keep := range []string{"Content-Type", "X-Tenant", "Traceparent"} {
+		if h.Get(keep) == "" {
+			t.Errorf("expected %s to be forwarded", keep)
+		}
+	}
+	for _, drop := range []string{"Authorization", "Cookie"} {
+		if h.Get(drop) != "" {
+			t.Errorf("expected %s to be dropped", drop)
+		}
+	}
+
+	all := http.Header{"Cookie": {"a"}}
+	filterGRPCMetadata(all, nil)
+	if all.Get("Cookie") == "" {
+		t.Error("expected nil allowlist to keep all headers")
+	}
+}
+
+func TestGRPCUpstream_PropagatesDeadlineAndMetadata(t *testing.T) {
+	var got http.Header
+	backend := newGRPCBackend(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		w.Header().Set("Content-Type", "application/grpc")
+		w.Header().Set("Grpc-Status", "0")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	cfg := &config.Config{
+		Clusters: []config.Cluster{
+			{Name: "svc", Type: "grpc", Endpoints: []config.ClusterEndpoint{{URL: backend.URL}}},
+		},
+		RoutesV2: []config.RouteV2{{
+			Name:  "get-user",
+			Match: config.RouteMatch{Path: "/api/user"},
+			Upstream: config.RouteUpstream{
+				Cluster:   "svc",
+				TimeoutMs: 1500,
+				GRPC: &config.RouteUpstreamGRPC{
+					Service:  "user.v1.UserService",
+					Method:   "GetUser",
+					Metadata: &config.GRPCMetadata{Allow: []string{"x-tenant"}},
+				},
+			},
+		}},
+	}
+	compiled, err := Compile(cfg, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest("POST", "/api/user", strings.NewReader(`{}`))
+	route, ok := compiled.Router.Match(req)
+	if !ok {
+		t.Fatal("expected route to match")
+	}
+	req.Header.Set("X-Tenant", "acme")
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("Traceparent", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	w := httptest.NewRecorder()
+	if err := (&GRPCUpstream{}).Handle(w, req, route, compiled.Clusters["svc"]); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got == nil {
+		t.Fatal("backend was not called")
+	}
+	if v := got.Get("Grpc-Timeout"); v == "" {
+		t.Error("expected grpc-timeout header")
+	} else if d, ok := parseGRPCTimeout(v); !ok || d != 1500*time.Millisecond {
+		t.Errorf("expected 1.5s grpc-timeout, got %s", v)
+	}
+	if got.Get("X-Tenant") != "acme" {
+		t.Error("expected allowlisted header to be forwarded")
+	}
+	if got.Get("Authorization") != "" {
+		t.Error("expected non-allowlisted header to be dropped")
+	}
+	if got.Get("Traceparent") == "" || got.Get("Grpc-Trace-Bin") == "" {
+		t.Error("expected trace context to be forwarded")
+	}
+}
+
+func TestGRPCUpstream_DeadlineExceeded(t *testing.T) {
+	release := make(chan struct{})
+	backend := newGRPCBackend(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer backend.Close()
+	defer close(release)
+
+	route, cluster := grpcPassthroughRoute(backend.URL)
+	route.TimeoutMs = 50
+	req := httptest.NewRequest("POST", "/api/user", strings.NewReader(`{}`))
+	w := httptest.NewRecorder()
+	if err := (&GRPCUpstream{}).Handle(w, req, route, cluster); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if w.Code != http.StatusGatewayTimeout {
+		t.Fatalf("expected 504, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "DEADLINE_EXCEEDED") {
+		t.Errorf("expected DEADLINE_EXCEEDED body, got %s", w.Body.String())
+	}
+}
+
+func TestParseGRPCPath(t *testing.T) {
+	service, method, ok := parseGRPCPath("/user.v1.UserService/GetUser")
+	if !ok || service != "user.v1.UserService" || method != "GetUser" {
+		t.Errorf("unexpected parse result %q %q %v", service, method, ok)
+	}
+	for _, bad := range []string{"", "/", "/svc", "/svc/", "//m", "/svc/m/extra", "svc/m"} {
+		if _, _, ok := parseGRPCPath(bad); ok {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestGRPCAllowlist(t *testing.T) {
+	var all *GRPCAllowlist
+	if !all.Allows("any.Service", "Any") {
+		t.Error("expected nil allowlist to allow everything")
+	}
+
+	a := newGRPCAllowlist([]string{"user.v1.UserService", "order.v1.OrderService/GetOrder"})
+	tests := []struct {
+		service, method string
+		want            bool
+	}{
+		{"user.v1.UserService", "GetUser", true},
+		{"user.v1.UserService", "DeleteUser", true},
+		{"order.v1.OrderService", "GetOrder", true},
+		{"order.v1.OrderService", "CancelOrder", false},
+		{"admin.v1.AdminService", "Reset", false},
+	}
+	for _, tt := range tests {
+		if got := a.Allows(tt.service, tt.method); got != tt.want {
+			t.Errorf("Allows(%s, %s) = %v, want %v", tt.service, tt.method, got, tt.want)
+		}
+	}
+}
+
+func passthroughConfig(backendURL string, allow []string) *config.Config {
+	return &config.Config{
+		Clusters: []config.Cluster{
+			{Name: "svc", Type: "grpc", Endpoints: []config.ClusterEndpoint{{URL: backendURL}}},
+		},
+		RoutesV2: []config.RouteV2{{
+			Name:  "grpc-passthrough",
+			Match: config.RouteMatch{PathPrefix: "/"},
+			Upstream: config.RouteUpstream{
+				Cluster: "svc",
+				GRPC:    &config.RouteUpstreamGRPC{Passthrough: true, Allow: allow},
+			},
+		}},
+	}
+}
+
+func TestGRPCUpstream_Passthrough(t *testing.T) {
+	backend := newGRPCBackend(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/user.v1.UserService/GetUser" {
+			t.Errorf("expected request path to pass through, got %s", r.URL.Path)
+		}
+		if ct := r.Header.Get("Content-Type"); ct != "application/grpc+proto" {
+			t.Errorf("expected client content type to be kept, got %s", ct)
+		}
+		msg, err := transcode.ReadFrame(r.Body)
+		if err != nil || string(msg) != "raw-proto" {
+			t.Errorf("expected single unmodified frame, got %q, %v", msg, err)
+		}
+		w.Header().Set("Content-Type", "application/grpc+proto")
+		w.Header().Set("Trailer", "Grpc-Status")
+		w.WriteHeader(http.StatusOK)
+		w.Write(transcode.Frame([]byte("reply")))
+		w.Header().Set("Grpc-Status", "0")
+	})
+	defer backend.Close()
+
+	compiled, err := Compile(passthroughConfig(backend.URL, []string{"user.v1.UserService"}), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest("POST", "/user.v1.UserService/GetUser", bytes.NewReader(transcode.Frame([]byte("raw-proto"))))
+	req.Header.Set("Content-Type", "application/grpc+proto")
+	route, ok := compiled.Router.Match(req)
+	if !ok {
+		t.Fatal("expected passthrough route to match")
+	}
+	w := httptest.NewRecorder()
+	if err := (&GRPCUpstream{}).Handle(w, req, route, compiled.Clusters["svc"]); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	msg, err := transcode.ReadFrame(w.Body)
+	if err != nil || string(msg) != "reply" {
+		t.Errorf("expected framed reply, got %q, %v", msg, err)
+	}
+}
+
+func TestGRPCUpstream_PassthroughRejectsUnlistedMethod(t *testing.T) {
+	backend := newGRPCBackend(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("backend should not be called for a method outside the allowlist")
+	})
+	defer backend.Close()
+
+	compiled, err := Compile(passthroughConfig(backend.URL, []string{"user.v1.UserService/GetUser"}), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	route, cluster := compiled.Router, compiled.Clusters["svc"]
+
+	// Native gRPC clients get a trailers-only UNIMPLEMENTED status.
+	req := httptest.NewRequest("POST", "/user.v1.UserService/DeleteUser", nil)
+	req.Header.Set("Content-Type", "application/grpc")
+	r, _ := route.Match(req)
+	w := httptest.NewRecorder()
+	if err := (&GRPCUpstream{}).Handle(w, req, r, cluster); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusOK || w.Header().Get("Grpc-Status") != "12" {
+		t.Errorf("expected grpc-status 12, got HTTP %d grpc-status %q", w.Code, w.Header().Get("Grpc-Status"))
+	}
+
+	// Other clients get an HTTP error.
+	req = httptest.NewRequest("POST", "/not-a-grpc-path", strings.NewReader(`{}`))
+	r, _ = route.Match(req)
+	w = httptest.NewRecorder()
+	if err := (&GRPCUpstream{}).Handle(w, req, r, cluster); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusNotImplemented {
+		t.Errorf("expected 501, got %d", w.Code)
+	}
+}
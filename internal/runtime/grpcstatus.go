@@ -0,0 +1,142 @@
+package runtime
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/oriys/nexus/internal/transcode"
+)
+
+// grpcCodeNames maps gRPC status codes to their canonical names.
+var grpcCodeNames = [...]string{
+	0:  "OK",
+	1:  "CANCELLED",
+	2:  "UNKNOWN",
+	3:  "INVALID_ARGUMENT",
+	4:  "DEADLINE_EXCEEDED",
+	5:  "NOT_FOUND",
+	6:  "ALREADY_EXISTS",
+	7:  "PERMISSION_DENIED",
+	8:  "RESOURCE_EXHAUSTED",
+	9:  "FAILED_PRECONDITION",
+	10: "ABORTED",
+	11: "OUT_OF_RANGE",
+	12: "UNIMPLEMENTED",
+	13: "INTERNAL",
+	14: "UNAVAILABLE",
+	15: "DATA_LOSS",
+	16: "UNAUTHENTICATED",
+}
+
+// grpcHTTPStatus maps gRPC status codes to HTTP status codes following the
+// google.rpc.Code documentation.
+var grpcHTTPStatus = [...]int{
+	0:  http.StatusOK,
+	1:  499, // Client Closed Request
+	2:  http.StatusInternalServerError,
+	3:  http.StatusBadRequest,
+	4:  http.StatusGatewayTimeout,
+	5:  http.StatusNotFound,
+	6:  http.StatusConflict,
+	7:  http.StatusForbidden,
+	8:  http.StatusTooManyRequests,
+	9:  http.StatusBadRequest,
+	10: http.StatusConflict,
+	11: http.StatusBadRequest,
+	12: http.StatusNotImplemented,
+	13: http.StatusInternalServerError,
+	14: http.StatusServiceUnavailable,
+	15: http.StatusInternalServerError,
+	16: http.StatusUnauthorized,
+}
+
+// GRPCStatus is a gRPC status read from response headers or trailers.
+type GRPCStatus struct {
+	Code    int
+	Message string
+	// Violations carries field-level errors for INVALID_ARGUMENT statuses
+	// raised by the gateway's own request validation.
+	Violations []transcode.FieldViolation
+}
+
+// HTTPStatus returns the HTTP status code corresponding to the gRPC code.
+// Unknown codes map to 500.
+func (s GRPCStatus) HTTPStatus() int {
+	if s.Code >= 0 && s.Code < len(grpcHTTPStatus) {
+		return grpcHTTPStatus[s.Code]
+	}
+	return http.StatusInternalServerError
+}
+
+// Name returns the canonical name of the gRPC code, e.g. "NOT_FOUND".
+func (s GRPCStatus) Name() string {
+	if s.Code >= 0 && s.Code < len(grpcCodeNames) {
+		return grpcCodeNames[s.Code]
+	}
+	return grpcCodeNames[2]
+}
+
+// grpcErrorBody is the JSON error document returned to HTTP clients, using
+// the Google API error format.
+type grpcErrorBody struct {
+	Error grpcErrorDetail `json:"error"`
+}
+
+type grpcErrorDetail struct {
+	Code    int                 `json:"code"`
+	Message string              `json:"message"`
+	Status  string              `json:"status"`
+	Details []grpcBadRequestErr `json:"details,omitempty"`
+}
+
+// grpcBadRequestErr is the JSON form of a google.rpc.BadRequest detail.
+type grpcBadRequestErr struct {
+	Type            string                     `json:"@type"`
+	FieldViolations []transcode.FieldViolation `json:"fieldViolations"`
+}
+
+// JSON returns the JSON error body for the status.
+func (s GRPCStatus) JSON() []byte {
+	detail := grpcErrorDetail{
+		Code:    s.HTTPStatus(),
+		Message: s.Message,
+		Status:  s.Name(),
+	}
+	if len(s.Violations) > 0 {
+		detail.Details = []grpcBadRequestErr{{
+			Type:            "type.googleapis.com/google.rpc.BadRequest",
+			FieldViolations: s.Violations,
+		}}
+	}
+	b, _ := json.Marshal(grpcErrorBody{Error: detail})
+	return b
+}
+
+// parseGRPCStatus reads grpc-status and grpc-message from h. It returns false
+// when no status is present. grpc-message is percent-encoded on the wire.
+func parseGRPCStatus(h http.Header) (GRPCStatus, bool) {
+	raw := h.Get("Grpc-Status")
+	if raw == "" {
+		return GRPCStatus{}, false
+	}
+	code, err := strconv.Atoi(raw)
+	if err != nil {
+		code = 2 // UNKNOWN
+	}
+	msg := h.Get("Grpc-Message")
+	if decoded, err := url.PathUnescape(msg); err == nil {
+		msg = decoded
+	}
+	return GRPCStatus{Code: code, Message: msg}, true
+}
+
+// responseGRPCStatus returns the status of a completed response, preferring
+// trailers and falling back to headers for trailers-only responses.
+func responseGRPCStatus(resp *http.Response) (GRPCStatus, bool) {
+	if st, ok := parseGRPCStatus(resp.Trailer); ok {
+		return st, true
+	}
+	return parseGRPCStatus(resp.Header)
+}
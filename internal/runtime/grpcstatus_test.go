@@ -0,0 +1,157 @@
+package runtime
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/oriys/nexus/internal/config"
+	"github.com/oriys/nexus/internal/transcode"
+)
+
+func TestGRPCStatus_HTTPStatus(t *testing.T) {
+	tests := []struct {
+		code int
+		want int
+		name string
+	}{
+		{0, http.StatusOK, "OK"},
+		{1, 499, "CANCELLED"},
+		{3, http.StatusBadRequest, "INVALID_ARGUMENT"},
+		{4, http.StatusGatewayTimeout, "DEADLINE_EXCEEDED"},
+		{5, http.StatusNotFound, "NOT_FOUND"},
+		{7, http.StatusForbidden, "PERMISSION_DENIED"},
+		{8, http.StatusTooManyRequests, "RESOURCE_EXHAUSTED"},
+		{12, http.StatusNotImplemented, "UNIMPLEMENTED"},
+		{14, http.StatusServiceUnavailable, "UNAVAILABLE"},
+		{16, http.StatusUnauthorized, "UNAUTHENTICATED"},
+		{99, http.StatusInternalServerError, "UNKNOWN"},
+	}
+	for _, tt := range tests {
+		st := GRPCStatus{Code: tt.code}
+		if got := st.HTTPStatus(); got != tt.want {
+			t.Errorf("code %d: expected HTTP %d, got %d", tt.code, tt.want, got)
+		}
+		if got := st.Name(); got != tt.name {
+			t.Errorf("code %d: expected name %s, got %s", tt.code, tt.name, got)
+		}
+	}
+}
+
+func TestParseGRPCStatus(t *testing.T) {
+	h := http.Header{}
+	if _, ok := parseGRPCStatus(h); ok {
+		t.Error("expected no status in empty header")
+	}
+
+	h.Set("Grpc-Status", "5")
+	h.Set("Grpc-Message", "user%2042%20not%20found")
+	st, ok := parseGRPCStatus(h)
+	if !ok {
+		t.Fatal("expected status")
+	}
+	if st.Code != 5 || st.Message != "user 42 not found" {
+		t.Errorf("unexpected status %+v", st)
+	}
+}
+
+func grpcPassthroughRoute(backendURL string) (*CompiledRoute, *CompiledCluster) {
+	route := &CompiledRoute{
+		Name: "grpc-json",
+		Upstream: RouteUpstreamConfig{
+			ClusterName: "svc",
+			GRPC:        &config.RouteUpstreamGRPC{Service: "user.v1.UserService", Method: "GetUser"},
+		},
+	}
+	cluster := &CompiledCluster{Name: "svc", Type: "grpc", Endpoints: []config.ClusterEndpoint{{URL: backendURL}}}
+	return route, cluster
+}
+
+func TestGRPCUpstream_TrailersOnlyError(t *testing.T) {
+	backend := newGRPCBackend(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/grpc")
+		w.Header().Set("Grpc-Status", "5")
+		w.Header().Set("Grpc-Message", "user%20not%20found")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	route, cluster := grpcPassthroughRoute(backend.URL)
+	req := httptest.NewRequest("POST", "/api/user", strings.NewReader(`{"id":1}`))
+	w := httptest.NewRecorder()
+	if err := (&GRPCUpstream{}).Handle(w, req, route, cluster); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", w.Code)
+	}
+	var body struct {
+		Error struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+			Status  string `json:"status"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("expected JSON error body, got %q: %v", w.Body.String(), err)
+	}
+	if body.Error.Code != 404 || body.Error.Status != "NOT_FOUND" || body.Error.Message != "user not found" {
+		t.Errorf("unexpected error body %+v", body.Error)
+	}
+}
+
+func TestGRPCUpstream_TrailerError(t *testing.T) {
+	backend := newGRPCBackend(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/grpc")
+		w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+		w.WriteHeader(http.StatusOK)
+		w.Write(transcode.Frame([]byte("partial")))
+		w.Header().Set("Grpc-Status", "14")
+		w.Header().Set("Grpc-Message", "backend draining")
+	}))
+	defer backend.Close()
+
+	route, cluster := grpcPassthroughRoute(backend.URL)
+	req := httptest.NewRequest("POST", "/api/user", strings.NewReader(`{"id":1}`))
+	w := httptest.NewRecorder()
+	if err := (&GRPCUpstream{}).Handle(w, req, route, cluster); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), `"UNAVAILABLE"`) {
+		t.Errorf("expected UNAVAILABLE status in body, got %s", w.Body.String())
+	}
+	if len(w.Result().Trailer) != 0 {
+		t.Errorf("expected trailers to be dropped, got %v", w.Result().Trailer)
+	}
+}
+
+func TestGRPCUpstream_NativeClientKeepsTrailers(t *testing.T) {
+	backend := newGRPCBackend(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/grpc")
+		w.Header().Set("Grpc-Status", "5")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	route, cluster := grpcPassthroughRoute(backend.URL)
+	req := httptest.NewRequest("POST", "/user.v1.UserService/GetUser", strings.NewReader(""))
+	req.Header.Set("Content-Type", "application/grpc")
+	w := httptest.NewRecorder()
+	if err := (&GRPCUpstream{}).Handle(w, req, route, cluster); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected gRPC status to pass through with HTTP 200, got %d", w.Code)
+	}
+	if w.Header().Get("Grpc-Status") != "5" {
+		t.Errorf("expected grpc-status header to be preserved")
+	}
+}
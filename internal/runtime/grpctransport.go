@@ -0,0 +1,137 @@
+package runtime
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Defaults for gRPC cluster transports.
+const (
+	defaultGRPCKeepaliveTimeout = 20 * time.Second
+	defaultGRPCIdleConnTimeout  = 90 * time.Second
+	defaultGRPCMaxIdleConns     = 16
+)
+
+// grpcTransportSettings captures everything that affects how a gRPC cluster's
+// transport is built. Clusters whose settings are unchanged across a reload
+// keep their transport, and with it their open connections.
+type grpcTransportSettings struct {
+	keepaliveTime        time.Duration
+	keepaliveTimeout     time.Duration
+	maxConcurrentStreams int
+	maxIdleConns         int
+	idleConnTimeout      time.Duration
+}
+
+func grpcSettingsOf(cc *CompiledCluster) grpcTransportSettings {
+	s := grpcTransportSettings{
+		keepaliveTimeout: defaultGRPCKeepaliveTimeout,
+		maxIdleConns:     defaultGRPCMaxIdleConns,
+		idleConnTimeout:  defaultGRPCIdleConnTimeout,
+	}
+	if g := cc.GRPC; g != nil {
+		s.keepaliveTime = g.KeepaliveTimeDuration()
+		if d := g.KeepaliveTimeoutDuration(); d > 0 {
+			s.keepaliveTimeout = d
+		}
+		s.maxConcurrentStreams = g.MaxConcurrentStreams
+	}
+	if ka := cc.Keepalive; ka != nil {
+		if ka.MaxIdleConns > 0 {
+			s.maxIdleConns = ka.MaxIdleConns
+		}
+		if d := ka.IdleConnTimeoutDuration(); d > 0 {
+			s.idleConnTimeout = d
+		}
+	}
+	return s
+}
+
+// grpcTransport is the managed HTTP/2 transport shared by all calls to one
+// gRPC cluster. Connections to each endpoint are reused across requests and
+// health-checked with HTTP/2 PINGs.
+type grpcTransport struct {
+	settings grpcTransportSettings
+	base     *http.Transport
+
+	mu      sync.Mutex
+	streams map[string]chan struct{} // endpoint host → in-flight call slots
+}
+
+func newGRPCTransport(s grpcTransportSettings) *grpcTransport {
+	base := newH2Transport()
+	base.MaxIdleConnsPerHost = s.maxIdleConns
+	base.IdleConnTimeout = s.idleConnTimeout
+	base.HTTP2 = &http.HTTP2Config{
+		SendPingTimeout: s.keepaliveTime,
+		PingTimeout:     s.keepaliveTimeout,
+	}
+	return &grpcTransport{
+		settings: s,
+		base:     base,
+		streams:  make(map[string]chan struct{}),
+	}
+}
+
+// RoundTrip implements http.RoundTripper. When max_concurrent_streams is set,
+// calls beyond the limit wait for a free slot on that endpoint or for their
+// deadline, whichever comes first.
+func (t *grpcTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.settings.maxConcurrentStreams <= 0 {
+		return t.base.RoundTrip(req)
+	}
+
+	slots := t.slots(req.URL.Host)
+	select {
+	case slots <- struct{}{}:
+	case <-req.Context().Done():
+		return nil, context.Cause(req.Context())
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		<-slots
+		return nil, err
+	}
+	// The stream stays open until the response body is consumed.
+	resp.Body = &releaseOnClose{ReadCloser: resp.Body, release: func() { <-slots }}
+	return resp, nil
+}
+
+// CloseIdleConnections closes the idle connections of t.
+func (t *grpcTransport) CloseIdleConnections() {
+	t.base.CloseIdleConnections()
+}
+
+func (t *grpcTransport) slots(host string) chan struct{} {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	ch, ok := t.streams[host]
+	if !ok {
+		ch = make(chan struct{}, t.settings.maxConcurrentStreams)
+		t.streams[host] = ch
+	}
+	return ch
+}
+
+// releaseOnClose runs release exactly once when the body is closed.
+type releaseOnClose struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *releaseOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
+
+// grpcTransports hands out one transport per gRPC cluster.
+var grpcTransports = newGRPCTransportPool()
+
+func newGRPCTransportPool() *transportPool[grpcTransportSettings, *grpcTransport] {
+	return newTransportPool(grpcSettingsOf, newGRPCTransport, func(cc *CompiledCluster) *grpcTransport { return cc.transport })
+}
@@ -0,0 +1,204 @@
+package runtime
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/oriys/nexus/internal/config"
+	"github.com/oriys/nexus/internal/transcode"
+)
+
+func TestGRPCTransportPool_ReusesUnchangedClusters(t *testing.T) {
+	pool := newGRPCTransportPool()
+	cc := &CompiledCluster{Name: "svc", Type: "grpc", GRPC: &config.ClusterGRPC{KeepaliveTimeMs: 10000}}
+
+	first := pool.forCluster(cc)
+	if again := pool.forCluster(&CompiledCluster{Name: "svc", Type: "grpc", GRPC: &config.ClusterGRPC{KeepaliveTimeMs: 10000}}); again != first {
+		t.Error("expected unchanged settings to reuse the transport")
+	}
+	if first.base.HTTP2.SendPingTimeout != 10*time.Second || first.base.HTTP2.PingTimeout != defaultGRPCKeepaliveTimeout {
+		t.Errorf("unexpected keepalive settings %+v", first.base.HTTP2)
+	}
+
+	changed := pool.forCluster(&CompiledCluster{Name: "svc", Type: "grpc", GRPC: &config.ClusterGRPC{KeepaliveTimeMs: 5000}})
+	if changed == first {
+		t.Error("expected changed settings to create a new transport")
+	}
+
+	cc.transport = changed
+	pool.retain(map[string]*CompiledCluster{"svc": cc})
+	if len(pool.transports) != 1 {
+		t.Errorf("expected live cluster transport to be retained")
+	}
+	pool.retain(map[string]*CompiledCluster{})
+	if len(pool.transports) != 0 {
+		t.Errorf("expected removed cluster transport to be dropped")
+	}
+}
+
+func TestGRPCUpstream_ReusesConnections(t *testing.T) {
+	var conns atomic.Int32
+	backend := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/grpc")
+		w.Header().Set("Grpc-Status", "0")
+		w.WriteHeader(http.StatusOK)
+	}))
+	backend.Config.Protocols = new(http.Protocols)
+	backend.Config.Protocols.SetUnencryptedHTTP2(true)
+	backend.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	backend.Start()
+	defer backend.Close()
+
+	route, cluster := grpcPassthroughRoute(backend.URL)
+	cluster.Name = "conn-reuse"
+	for i := 0; i < 5; i++ {
+		req := httptest.NewRequest("POST", "/api/user", strings.NewReader(`{}`))
+		w := httptest.NewRecorder()
+		if err := (&GRPCUpstream{}).Handle(w, req, route, cluster); err != nil {
+			t.Fatal(err)
+		}
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", w.Code)
+		}
+	}
+	if n := conns.Load(); n != 1 {
+		t.Errorf("expected a single reused connection, got %d", n)
+	}
+}
+
+func TestGRPCTransport_MaxConcurrentStreams(t *testing.T) {
+	release := make(chan struct{})
+	var inFlight, peak atomic.Int32
+	backend := newGRPCBackend(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		<-release
+		inFlight.Add(-1)
+		w.WriteHeader(http.StatusOK)
+	})
+	defer backend.Close()
+
+	tr := newGRPCTransport(grpcTransportSettings{maxConcurrentStreams: 1, keepaliveTimeout: time.Second})
+	client := &http.Client{Transport: tr}
+
+	done := make(chan error, 1)
+	go func() {
+		resp, err := client.Post(backend.URL, "application/grpc", nil)
+		if err == nil {
+			resp.Body.Close()
+		}
+		done <- err
+	}()
+	for inFlight.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// The second call cannot get a slot until the first completes.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "POST", backend.URL, nil)
+	if _, err := client.Do(req); err == nil {
+		t.Fatal("expected second call to wait for a free stream slot")
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("first call failed: %v", err)
+	}
+	resp, err := client.Post(backend.URL, "application/grpc", nil)
+	if err != nil {
+		t.Fatalf("expected slot to be released: %v", err)
+	}
+	resp.Body.Close()
+	if peak.Load() != 1 {
+		t.Errorf("expected at most 1 concurrent stream, got %d", peak.Load())
+	}
+}
+
+func TestGRPCUpstream_MaxRecvMsgSize(t *testing.T) {
+	backend := newGRPCBackend(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/grpc")
+		w.Header().Set("Trailer", "Grpc-Status")
+		w.WriteHeader(http.StatusOK)
+		w.Write(transcode.Frame(make([]byte, 2<<20)))
+		w.Header().Set("Grpc-Status", "0")
+	})
+	defer backend.Close()
+
+	route, cluster := grpcPassthroughRoute(backend.URL)
+	cluster.Name = "max-recv"
+	cluster.GRPC = &config.ClusterGRPC{MaxRecvMsgMB: 1}
+	req := httptest.NewRequest("POST", "/api/user", strings.NewReader(`{}`))
+	w := httptest.NewRecorder()
+	if err := (&GRPCUpstream{}).Handle(w, req, route, cluster); err != nil {
+		t.Fatal(err)
+	}
+
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "RESOURCE_EXHAUSTED") {
+		t.Errorf("expected RESOURCE_EXHAUSTED body, got %s", w.Body.String())
+	}
+}
+
+func TestGRPCUpstream_MaxSendMsgSize(t *testing.T) {
+	var got atomic.Value
+	backend := newGRPCBackend(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got.Store(body)
+		w.Header().Set("Content-Type", "application/grpc")
+		w.Header().Set("Trailer", "Grpc-Status")
+		w.WriteHeader(http.StatusOK)
+		w.Header().Set("Grpc-Status", "0")
+	})
+	defer backend.Close()
+
+	route, cluster := grpcPassthroughRoute(backend.URL)
+	cluster.Name = "max-send"
+	cluster.GRPC = &config.ClusterGRPC{MaxSendMsg: "16B"}
+	for _, tt := range []struct {
+		body   string
+		length int64
+		want   int
+	}{
+		{`{"id":1}`, 8, http.StatusOK},
+		{`{"id":1}`, -1, http.StatusOK},
+		{`{"id":"0123456789"}`, 19, http.StatusTooManyRequests},
+		{`{"id":"0123456789"}`, -1, http.StatusTooManyRequests},
+	} {
+		got.Store([]byte(nil))
+		req := httptest.NewRequest("POST", "/api/user", strings.NewReader(tt.body))
+		req.ContentLength = tt.length
+		w := httptest.NewRecorder()
+		(&GRPCUpstream{}).Handle(w, req, route, cluster)
+		if w.Code != tt.want {
+			t.Errorf("%d-byte body of length %d: expected %d, got %d: %s", len(tt.body), tt.length, tt.want, w.Code, w.Body)
+			continue
+		}
+		sent := got.Load().([]byte)
+		if tt.want == http.StatusOK && !bytes.Equal(sent, transcode.Frame([]byte(tt.body))) {
+			t.Errorf("length %d: upstream received %q", tt.length, sent)
+		}
+		if tt.want != http.StatusOK && (sent != nil || !strings.Contains(w.Body.String(), "RESOURCE_EXHAUSTED")) {
+			t.Errorf("length %d: oversized body sent or not reported: %s", tt.length, w.Body)
+		}
+	}
+}
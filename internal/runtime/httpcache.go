@@ -0,0 +1,513 @@
+package runtime
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/oriys/nexus/internal/auth"
+	"github.com/oriys/nexus/internal/config"
+	"github.com/oriys/nexus/internal/pathmatch"
+	"github.com/oriys/nexus/internal/reqctx"
+)
+
+const defaultResponseCacheEntries = 10000
+
+// cachedResponse is a response kept by the response cache. A response that
+// varies on request headers is kept under a key of its own, and the key of
+// the request holds only the header names.
+type cachedResponse struct {
+	Status  int         `json:"status,omitempty"`
+	Header  http.Header `json:"header,omitempty"`
+	Body    []byte      `json:"body,omitempty"`
+	Stored  time.Time   `json:"stored"`
+	Expires time.Time   `json:"expires"`
+	Vary    []string    `json:"vary,omitempty"`
+}
+
+// cacheStore keeps cached responses. Keys start with the route name and a
+// NUL byte, so a route's responses can be purged together.
+type cacheStore interface {
+	get(key string) (*cachedResponse, bool)
+	set(key string, e *cachedResponse, ttl time.Duration)
+	// purge removes the responses whose keys start with prefix and returns
+	// how many were removed.
+	purge(prefix string) int
+	close()
+}
+
+// responseCache holds the store of the response cache. It lives as long as
+// the process so that cached responses survive config reloads that leave
+// the store's settings alone.
+var responseCache = struct {
+	sync.Mutex
+	cfg   config.ResponseCache
+	store cacheStore
+}{}
+
+// responseStoreFor returns the store configured by cfg, keeping the current
+// one when its settings are unchanged.
+func responseStoreFor(cfg *config.ResponseCache) cacheStore {
+	var c config.ResponseCache
+	if cfg != nil {
+		c = *cfg
+		if cfg.Redis != nil {
+			redis := *cfg.Redis
+			c.Redis = &redis
+		}
+	}
+	responseCache.Lock()
+	defer responseCache.Unlock()
+	if responseCache.store != nil && reflect.DeepEqual(responseCache.cfg, c) {
+		return responseCache.store
+	}
+	if responseCache.store != nil {
+		responseCache.store.close()
+	}
+	if c.Store == "redis" {
+		responseCache.store = &redisCacheStore{
+			client: newRedisClient(c.Redis.Address, c.Redis.Password, c.Redis.DB, c.Redis.TimeoutDuration()),
+			prefix: c.Redis.RedisPrefix(),
+		}
+	} else {
+		responseCache.store = newMemoryCacheStore(c.MaxEntries)
+	}
+	responseCache.cfg = c
+	return responseCache.store
+}
+
+// PurgeResponseCache removes the cached responses of route, or of every
+// route when route is empty, and returns how many were removed.
+func PurgeResponseCache(route string) int {
+	responseCache.Lock()
+	store := responseCache.store
+	responseCache.Unlock()
+	if store == nil {
+		return 0
+	}
+	prefix := ""
+	if route != "" {
+		prefix = route + "\x00"
+	}
+	return store.purge(prefix)
+}
+
+// RouteCache serves a route's GET and HEAD requests from the response
+// cache.
+type RouteCache struct {
+	backend  cacheStore
+	route    string
+	ttl      time.Duration
+	keyTmpl  *template.Template
+	maxBytes int64
+	now      func() time.Time
+}
+
+// cacheKeyData is the data the key template of a route renders.
+type cacheKeyData struct {
+	Method   string
+	Host     string
+	Path     string
+	Query    url.Values
+	RawQuery string
+	Headers  http.Header
+	Params   map[string]string
+	Subject  string
+	Tenant   string
+}
+
+// cacheableStatus lists the statuses cached without explicit freshness in
+// RFC 9111; other statuses are not cached at all.
+var cacheableStatus = map[int]bool{
+	200: true, 203: true, 204: true, 300: true, 301: true, 308: true, 404: true, 410: true,
+}
+
+func newRouteCache(route string, c *config.RouteCache, cfg *config.ResponseCache, backend cacheStore) (*RouteCache, error) {
+	key, err := template.New("key").Parse(c.KeyTemplate())
+	if err != nil {
+		return nil, fmt.Errorf("cache.key: %w", err)
+	}
+	var maxBytes int64 = 1 << 20
+	if cfg != nil {
+		maxBytes = cfg.MaxResponseLimit()
+	}
+	return &RouteCache{
+		backend:  backend,
+		route:    route,
+		ttl:      c.TTLDuration(),
+		keyTmpl:  key,
+		maxBytes: maxBytes,
+		now:      time.Now,
+	}, nil
+}
+
+// key renders the cache key of r, scoped to the route and tenant.
+func (c *RouteCache) key(r *http.Request) (string, error) {
+	data := cacheKeyData{
+		Method:   r.Method,
+		Host:     r.Host,
+		Path:     r.URL.Path,
+		Query:    r.URL.Query(),
+		RawQuery: r.URL.RawQuery,
+		Headers:  r.Header,
+		Params:   pathmatch.Params(r.Context()),
+	}
+	if id := auth.GetIdentity(r.Context()); id != nil {
+		data.Subject = id.Subject
+	}
+	data.Tenant, _ = reqctx.Tenant.Get(r.Context())
+	var b strings.Builder
+	b.WriteString(c.route + "\x00" + data.Tenant + "\x00")
+	if err := c.keyTmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// serve answers r from the cache when it holds a fresh response. Otherwise
+// it returns the writer to serve r with and a function to call once it is
+// served, which caches the response when it may be.
+func (c *RouteCache) serve(w http.ResponseWriter, r *http.Request) (http.ResponseWriter, func(), bool) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return w, func() {}, true
+	}
+	reqCC := parseCacheControl(r.Header.Values("Cache-Control"))
+	if _, ok := reqCC["no-store"]; ok {
+		return w, func() {}, true
+	}
+	key, err := c.key(r)
+	if err != nil {
+		slog.Debug("cache key error", slog.String("route", c.route), slog.String("error", err.Error()))
+		return w, func() {}, true
+	}
+	_, noCache := reqCC["no-cache"]
+	if !noCache && r.Header.Get("Pragma") != "no-cache" {
+		if e, ok := c.lookup(key, r); ok {
+			c.write(w, r, e)
+			return nil, nil, false
+		}
+	}
+	w.Header().Set("X-Cache", "MISS")
+	// Responses to HEAD have no body to cache, and degraded gateways do
+	// not buffer responses to cache them.
+	if r.Method == http.MethodHead || degraded.Load() {
+		return w, func() {}, true
+	}
+	rec := &bodyRecorder{ResponseWriter: w, limit: c.maxBytes}
+	return rec, func() { c.store(key, r, rec) }, true
+}
+
+// lookup returns the fresh response kept for r under key.
+func (c *RouteCache) lookup(key string, r *http.Request) (*cachedResponse, bool) {
+	e, ok := c.backend.get(key)
+	if ok && e.Vary != nil {
+		e, ok = c.backend.get(variantKey(key, e.Vary, r))
+	}
+	if !ok || e.Status == 0 || !c.now().Before(e.Expires) {
+		return nil, false
+	}
+	return e, true
+}
+
+// write answers r with e, or with 304 when e matches its conditions.
+func (c *RouteCache) write(w http.ResponseWriter, r *http.Request, e *cachedResponse) {
+	for name, values := range e.Header {
+		w.Header()[name] = values
+	}
+	age := max(c.now().Sub(e.Stored)/time.Second, 0)
+	w.Header().Set("Age", strconv.FormatInt(int64(age), 10))
+	w.Header().Set("X-Cache", "HIT")
+	if e.Status == http.StatusOK && notModified(r, e.Header) {
+		w.Header().Del("Content-Type")
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.WriteHeader(e.Status)
+	if r.Method != http.MethodHead {
+		w.Write(e.Body)
+	}
+}
+
+// notModified reports whether the conditions of r hold for a response with
+// header h.
+func notModified(r *http.Request, h http.Header) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		etag := strings.TrimPrefix(h.Get("ETag"), "W/")
+		if etag == "" {
+			return false
+		}
+		for _, t := range strings.Split(inm, ",") {
+			t = strings.TrimSpace(t)
+			if t == "*" || strings.TrimPrefix(t, "W/") == etag {
+				return true
+			}
+		}
+		return false
+	}
+	ims, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	lm, err := http.ParseTime(h.Get("Last-Modified"))
+	return err == nil && !lm.After(ims)
+}
+
+// store caches the response recorded by rec under key when it may be.
+func (c *RouteCache) store(key string, r *http.Request, rec *bodyRecorder) {
+	if !cacheableStatus[rec.status] || rec.overflow {
+		return
+	}
+	h := rec.Header()
+	cc := parseCacheControl(h.Values("Cache-Control"))
+	for _, d := range []string{"no-store", "no-cache", "private"} {
+		if _, ok := cc[d]; ok {
+			return
+		}
+	}
+	if h.Get("Set-Cookie") != "" {
+		return
+	}
+	// Responses to authorized requests, or to callers the gateway
+	// authenticated such as by API key, are personal unless the upstream
+	// says they may be shared.
+	if r.Header.Get("Authorization") != "" || auth.GetIdentity(r.Context()) != nil {
+		_, public := cc["public"]
+		_, shared := cc["s-maxage"]
+		if !public && !shared {
+			return
+		}
+	}
+	now := c.now()
+	ttl := c.ttl
+	if ttl <= 0 {
+		ttl = freshness(cc, h, now)
+	}
+	if ttl <= 0 {
+		return
+	}
+	var vary []string
+	for _, v := range h.Values("Vary") {
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name == "*" {
+				return
+			} else if name != "" {
+				vary = append(vary, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+
+	header := h.Clone()
+	for _, name := range []string{"Content-Length", "Date", "X-Cache", "Age"} {
+		header.Del(name)
+	}
+	e := &cachedResponse{
+		Status:  rec.status,
+		Header:  header,
+		Body:    rec.body.Bytes(),
+		Stored:  now,
+		Expires: now.Add(ttl),
+	}
+	if vary != nil {
+		c.backend.set(key, &cachedResponse{Stored: now, Expires: e.Expires, Vary: vary}, ttl)
+		key = variantKey(key, vary, r)
+	}
+	c.backend.set(key, e, ttl)
+}
+
+// freshness returns how long a response with Cache-Control directives cc
+// and header h stays fresh: s-maxage, max-age, or the time until Expires.
+func freshness(cc map[string]string, h http.Header, now time.Time) time.Duration {
+	for _, d := range []string{"s-maxage", "max-age"} {
+		if v, ok := cc[d]; ok {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return 0
+			}
+			return time.Duration(n) * time.Second
+		}
+	}
+	expires, err := http.ParseTime(h.Get("Expires"))
+	if err != nil {
+		return 0
+	}
+	if date, err := http.ParseTime(h.Get("Date")); err == nil {
+		return expires.Sub(date)
+	}
+	return expires.Sub(now)
+}
+
+// parseCacheControl returns the directives of Cache-Control header values
+// with their arguments.
+func parseCacheControl(values []string) map[string]string {
+	cc := make(map[string]string)
+	for _, v := range values {
+		for _, d := range strings.Split(v, ",") {
+			name, arg, _ := strings.Cut(strings.TrimSpace(d), "=")
+			if name != "" {
+				cc[strings.ToLower(name)] = strings.Trim(arg, `"`)
+			}
+		}
+	}
+	return cc
+}
+
+// variantKey returns the key of the response to r among those varying on
+// the headers vary.
+func variantKey(key string, vary []string, r *http.Request) string {
+	sum := sha256.New()
+	for _, name := range vary {
+		fmt.Fprintf(sum, "%s:%s\x00", name, strings.Join(r.Header.Values(name), ","))
+	}
+	return key + "\x00" + hex.EncodeToString(sum.Sum(nil)[:16])
+}
+
+// memoryCacheStore keeps responses in memory, evicting the least recently
+// used beyond its limit.
+type memoryCacheStore struct {
+	maxEntries int
+	mu         sync.Mutex
+	entries    map[string]*list.Element // values are *memoryCacheEntry
+	lru        *list.List
+}
+
+type memoryCacheEntry struct {
+	key      string
+	response *cachedResponse
+}
+
+func newMemoryCacheStore(maxEntries int) *memoryCacheStore {
+	if maxEntries <= 0 {
+		maxEntries = defaultResponseCacheEntries
+	}
+	return &memoryCacheStore{maxEntries: maxEntries, entries: make(map[string]*list.Element), lru: list.New()}
+}
+
+func (s *memoryCacheStore) get(key string) (*cachedResponse, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	el, ok := s.entries[key]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*memoryCacheEntry).response
+	if !time.Now().Before(e.Expires) {
+		s.remove(el)
+		return nil, false
+	}
+	s.lru.MoveToFront(el)
+	return e, true
+}
+
+func (s *memoryCacheStore) set(key string, e *cachedResponse, _ time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if el, ok := s.entries[key]; ok {
+		s.remove(el)
+	}
+	s.entries[key] = s.lru.PushFront(&memoryCacheEntry{key: key, response: e})
+	for s.lru.Len() > s.maxEntries {
+		s.remove(s.lru.Back())
+	}
+}
+
+func (s *memoryCacheStore) purge(prefix string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for key, el := range s.entries {
+		if strings.HasPrefix(key, prefix) {
+			s.remove(el)
+			n++
+		}
+	}
+	return n
+}
+
+func (s *memoryCacheStore) remove(el *list.Element) {
+	s.lru.Remove(el)
+	delete(s.entries, el.Value.(*memoryCacheEntry).key)
+}
+
+func (*memoryCacheStore) close() {}
+
+// redisCacheStore keeps responses in Redis, encoded as JSON, so gateway
+// instances share them. Redis errors count as misses.
+type redisCacheStore struct {
+	client *redisClient
+	prefix string
+}
+
+func (s *redisCacheStore) get(key string) (*cachedResponse, bool) {
+	reply, err := s.client.do("GET", s.prefix+key)
+	if err != nil {
+		slog.Debug("response cache redis error", slog.String("error", err.Error()))
+		return nil, false
+	}
+	data, ok := reply.(string)
+	if !ok {
+		return nil, false
+	}
+	var e cachedResponse
+	if err := json.Unmarshal([]byte(data), &e); err != nil {
+		return nil, false
+	}
+	return &e, true
+}
+
+func (s *redisCacheStore) set(key string, e *cachedResponse, ttl time.Duration) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	ms := max(ttl.Milliseconds(), 1)
+	if _, err := s.client.do("SET", s.prefix+key, string(data), "PX", strconv.FormatInt(ms, 10)); err != nil {
+		slog.Debug("response cache redis error", slog.String("error", err.Error()))
+	}
+}
+
+func (s *redisCacheStore) purge(prefix string) int {
+	pattern := escapeRedisGlob(s.prefix+prefix) + "*"
+	n, cursor := 0, "0"
+	for {
+		reply, err := s.client.do("SCAN", cursor, "MATCH", pattern, "COUNT", "100")
+		if err != nil {
+			slog.Warn("response cache purge error", slog.String("error", err.Error()))
+			return n
+		}
+		page, ok := reply.([]any)
+		if !ok || len(page) != 2 {
+			return n
+		}
+		cursor, _ = page[0].(string)
+		keys, _ := page[1].([]any)
+		if len(keys) > 0 {
+			args := []string{"DEL"}
+			for _, k := range keys {
+				if k, ok := k.(string); ok {
+					args = append(args, k)
+				}
+			}
+			if reply, err := s.client.do(args...); err == nil {
+				if deleted, ok := reply.(int64); ok {
+					n += int(deleted)
+				}
+			}
+		}
+		if cursor == "0" || cursor == "" {
+			return n
+		}
+	}
+}
+
+func (s *redisCacheStore) close() { s.client.close() }
@@ -0,0 +1,368 @@
+package runtime
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/oriys/nexus/internal/auth"
+	"github.com/oriys/nexus/internal/config"
+	"github.com/oriys/nexus/internal/middleware"
+)
+
+func TestGateway_ResponseCache(t *testing.T) {
+	var hits atomic.Int64
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := hits.Add(1)
+		switch r.URL.Path {
+		case "/items/fresh":
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.Header().Set("ETag", `"v1"`)
+		case "/items/lang":
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.Header().Set("Vary", "Accept-Language")
+		case "/items/private":
+			w.Header().Set("Cache-Control", "private, max-age=60")
+		case "/items/cookie":
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.Header().Set("Set-Cookie", "s=1")
+		case "/items/shared":
+			w.Header().Set("Cache-Control", "public, max-age=60")
+		}
+		fmt.Fprintf(w, "%s %s %d", r.URL.Path, r.Header.Get("Accept-Language"), n)
+	}))
+	defer backend.Close()
+
+	cfg := &config.Config{
+		Clusters: []config.Cluster{{Name: "items", Type: "http", Endpoints: []config.ClusterEndpoint{{URL: backend.URL}}}},
+		RoutesV2: []config.RouteV2{
+			{
+				Name:     "items",
+				Match:    config.RouteMatch{PathPrefix: "/items"},
+				Upstream: config.RouteUpstream{Cluster: "items"},
+				Cache:    &config.RouteCache{},
+			},
+			{
+				Name:     "pinned",
+				Match:    config.RouteMatch{PathPrefix: "/pinned"},
+				Upstream: config.RouteUpstream{Cluster: "items"},
+				Cache:    &config.RouteCache{TTL: "1m", Key: "{{.Path}}"},
+			},
+		},
+	}
+	compiled, err := Compile(cfg, 1)
+	if err != nil {
+		t.Fatalf("compile error: %v", err)
+	}
+	store := NewConfigStore()
+	store.Store(compiled)
+	gw := NewGateway(store)
+	defer PurgeResponseCache("")
+
+	serve := func(method, path string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		rec := httptest.NewRecorder()
+		gw.ServeHTTP(rec, req)
+		return rec
+	}
+
+	first := serve("GET", "/items/fresh")
+	if first.Header().Get("X-Cache") != "MISS" {
+		t.Fatalf("first request X-Cache %q", first.Header().Get("X-Cache"))
+	}
+	second := serve("GET", "/items/fresh")
+	if second.Header().Get("X-Cache") != "HIT" || second.Body.String() != first.Body.String() {
+		t.Fatalf("second request X-Cache %q, body %q", second.Header().Get("X-Cache"), second.Body.String())
+	}
+	if second.Header().Get("Age") == "" || second.Header().Get("ETag") != `"v1"` {
+		t.Errorf("cached headers %v", second.Header())
+	}
+	if rec := serve("HEAD", "/items/fresh"); rec.Header().Get("X-Cache") != "HIT" || rec.Body.Len() != 0 {
+		t.Errorf("HEAD X-Cache %q, body %q", rec.Header().Get("X-Cache"), rec.Body.String())
+	}
+	if rec := serve("GET", "/items/fresh", "If-None-Match", `W/"v1"`); rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+		t.Errorf("conditional request status %d, body %q", rec.Code, rec.Body.String())
+	}
+	if rec := serve("GET", "/items/fresh", "Cache-Control", "no-cache"); rec.Header().Get("X-Cache") != "MISS" {
+		t.Errorf("no-cache request X-Cache %q", rec.Header().Get("X-Cache"))
+	}
+	if got := hits.Load(); got != 2 {
+		t.Errorf("expected 2 upstream requests, got %d", got)
+	}
+
+	// Responses varying on a header are kept per value of the header.
+	en := serve("GET", "/items/lang", "Accept-Language", "en").Body.String()
+	de := serve("GET", "/items/lang", "Accept-Language", "de").Body.String()
+	if en == de {
+		t.Fatalf("variants share a response: %q", en)
+	}
+	if rec := serve("GET", "/items/lang", "Accept-Language", "de"); rec.Body.String() != de || rec.Header().Get("X-Cache") != "HIT" {
+		t.Errorf("variant body %q, X-Cache %q", rec.Body.String(), rec.Header().Get("X-Cache"))
+	}
+
+	for _, tt := range []struct {
+		path   string
+		header []string
+	}{
+		{"/items/private", nil},
+		{"/items/cookie", nil},
+		{"/items/none", nil},
+		{"/items/fresh", []string{"Authorization", "Bearer x"}},
+	} {
+		path := tt.path
+		if tt.header != nil {
+			path += "?auth"
+		}
+		serve("GET", path, tt.header...)
+		if rec := serve("GET", path, tt.header...); rec.Header().Get("X-Cache") != "MISS" {
+			t.Errorf("%s %v: cached", path, tt.header)
+		}
+	}
+	serve("GET", "/items/shared", "Authorization", "Bearer x")
+	if rec := serve("GET", "/items/shared", "Authorization", "Bearer x"); rec.Header().Get("X-Cache") != "HIT" {
+		t.Errorf("public response to an authorized request not cached")
+	}
+
+	// A route ttl caches responses without freshness, under the key the
+	// template renders.
+	serve("GET", "/pinned/a?x=1")
+	if rec := serve("GET", "/pinned/a?x=2"); rec.Header().Get("X-Cache") != "HIT" {
+		t.Errorf("pinned route X-Cache %q", rec.Header().Get("X-Cache"))
+	}
+
+	if n := PurgeResponseCache("pinned"); n != 1 {
+		t.Errorf("purged %d responses of pinned", n)
+	}
+	if rec := serve("GET", "/pinned/a"); rec.Header().Get("X-Cache") != "MISS" {
+		t.Errorf("purged response served")
+	}
+	if rec := serve("GET", "/items/fresh"); rec.Header().Get("X-Cache") != "HIT" {
+		t.Errorf("other route purged")
+	}
+}
+
+func TestRouteCache_Expiry(t *testing.T) {
+	c, err := newRouteCache("expiry", &config.RouteCache{TTL: "10s"}, nil, responseStoreFor(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer PurgeResponseCache("expiry")
+	now := time.Now()
+	c.now = func() time.Time { return now }
+	req := httptest.NewRequest("GET", "/a", nil)
+	w, done, ok := c.serve(httptest.NewRecorder(), req)
+	if !ok {
+		t.Fatal("empty cache answered")
+	}
+	w.Write([]byte("a"))
+	done()
+	now = now.Add(5 * time.Second)
+	rec := httptest.NewRecorder()
+	if _, _, ok := c.serve(rec, req); ok || rec.Header().Get("Age") != "5" {
+		t.Fatalf("fresh response not served, Age %q", rec.Header().Get("Age"))
+	}
+	now = now.Add(5 * time.Second)
+	if _, _, ok := c.serve(httptest.NewRecorder(), req); !ok {
+		t.Error("expired response served")
+	}
+}
+
+func TestFreshness(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		cc, expires string
+		want        time.Duration
+	}{
+		{"max-age=30", "", 30 * time.Second},
+		{"max-age=30, s-maxage=90", "", 90 * time.Second},
+		{"", now.Add(time.Minute).Format(http.TimeFormat), time.Minute},
+		{"max-age=abc", "", 0},
+		{"", "", 0},
+	} {
+		h := http.Header{}
+		if tt.expires != "" {
+			h.Set("Expires", tt.expires)
+		}
+		if got := freshness(parseCacheControl([]string{tt.cc}), h, now); got != tt.want {
+			t.Errorf("freshness(%q, %q) = %v, want %v", tt.cc, tt.expires, got, tt.want)
+		}
+	}
+}
+
+// fakeRedis serves the commands of the response cache from a map.
+type fakeRedis struct {
+	mu   sync.Mutex
+	keys map[string]string
+	auth []string
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		cmd, err := readRESP(r)
+		if err != nil {
+			return
+		}
+		var args []string
+		for _, a := range cmd.([]any) {
+			args = append(args, a.(string))
+		}
+		f.mu.Lock()
+		fmt.Fprint(conn, f.reply(args))
+		f.mu.Unlock()
+	}
+}
+
+func (f *fakeRedis) reply(args []string) string {
+	bulk := func(s string) string { return fmt.Sprintf("$%d\r\n%s\r\n", len(s), s) }
+	switch strings.ToUpper(args[0]) {
+	case "AUTH", "SELECT":
+		f.auth = append(f.auth, strings.Join(args, " "))
+		return "+OK\r\n"
+	case "GET":
+		v, ok := f.keys[args[1]]
+		if !ok {
+			return "$-1\r\n"
+		}
+		return bulk(v)
+	case "SET":
+		f.keys[args[1]] = args[2]
+		return "+OK\r\n"
+	case "SCAN":
+		prefix := strings.TrimSuffix(strings.ReplaceAll(args[3], `\`, ""), "*")
+		var matched []string
+		for k := range f.keys {
+			if strings.HasPrefix(k, prefix) {
+				matched = append(matched, bulk(k))
+			}
+		}
+		return fmt.Sprintf("*2\r\n%s*%d\r\n%s", bulk("0"), len(matched), strings.Join(matched, ""))
+	case "DEL":
+		n := 0
+		for _, k := range args[1:] {
+			if _, ok := f.keys[k]; ok {
+				delete(f.keys, k)
+				n++
+			}
+		}
+		return fmt.Sprintf(":%d\r\n", n)
+	}
+	return "-ERR unknown command\r\n"
+}
+
+func TestRedisCacheStore(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	f := &fakeRedis{keys: make(map[string]string)}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+
+	cfg := &config.ResponseCache{Store: "redis", Redis: &config.CacheRedis{Address: ln.Addr().String(), Password: "secret", DB: 2, Prefix: "t*:"}}
+	c, err := newRouteCache("redis", &config.RouteCache{TTL: "1m"}, cfg, responseStoreFor(cfg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer responseStoreFor(nil)
+
+	req := httptest.NewRequest("GET", "/a", nil)
+	w, done, _ := c.serve(httptest.NewRecorder(), req)
+	w.Header().Set("Content-Type", "text/plain")
+	w.Write([]byte("cached"))
+	done()
+	rec := httptest.NewRecorder()
+	if _, _, ok := c.serve(rec, req); ok || rec.Body.String() != "cached" || rec.Header().Get("Content-Type") != "text/plain" {
+		t.Fatalf("redis hit: body %q, header %v", rec.Body.String(), rec.Header())
+	}
+	f.mu.Lock()
+	if len(f.auth) != 2 || f.auth[0] != "AUTH secret" || f.auth[1] != "SELECT 2" {
+		t.Errorf("connection setup %q", f.auth)
+	}
+	f.keys["other"] = "x"
+	f.mu.Unlock()
+	if n := PurgeResponseCache("redis"); n != 1 {
+		t.Errorf("purged %d keys", n)
+	}
+	f.mu.Lock()
+	if len(f.keys) != 1 {
+		t.Errorf("keys left: %v", f.keys)
+	}
+	f.mu.Unlock()
+
+	// An unreachable server is a miss.
+	ln.Close()
+	c.backend.(*redisCacheStore).client.close()
+	if _, _, ok := c.serve(httptest.NewRecorder(), req); !ok {
+		t.Error("unreachable redis answered")
+	}
+}
+
+func TestGateway_ResponseCacheAuthenticated(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/me/shared" {
+			w.Header().Set("Cache-Control", "public, max-age=60")
+		} else {
+			w.Header().Set("Cache-Control", "max-age=60")
+		}
+		fmt.Fprintf(w, "profile of %s", r.Header.Get("X-API-Key"))
+	}))
+	defer backend.Close()
+
+	cfg := &config.Config{
+		Clusters: []config.Cluster{{Name: "me", Type: "http", Endpoints: []config.ClusterEndpoint{{URL: backend.URL}}}},
+		RoutesV2: []config.RouteV2{{
+			Name:     "me",
+			Match:    config.RouteMatch{PathPrefix: "/me"},
+			Upstream: config.RouteUpstream{Cluster: "me"},
+			Cache:    &config.RouteCache{},
+		}},
+	}
+	compiled, err := Compile(cfg, 1)
+	if err != nil {
+		t.Fatalf("compile error: %v", err)
+	}
+	store := NewConfigStore()
+	store.Store(compiled)
+	authenticator := auth.NewAPIKeyAuthenticator(map[string]string{"key-a": "alice", "key-b": "bob"})
+	h := middleware.Auth(authenticator)(NewGateway(store))
+	defer PurgeResponseCache("")
+
+	serve := func(path, key string) string {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("X-API-Key", key)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Body.String()
+	}
+
+	// A response to one consumer is not served to another.
+	serve("/me", "key-a")
+	if body := serve("/me", "key-b"); body != "profile of key-b" {
+		t.Errorf("second consumer got %q", body)
+	}
+	// Unless the upstream marks it public.
+	serve("/me/shared", "key-a")
+	if body := serve("/me/shared", "key-b"); body != "profile of key-a" {
+		t.Errorf("expected the shared response from the cache, got %q", body)
+	}
+}
@@ -0,0 +1,70 @@
+package runtime
+
+import (
+	"net/http"
+	"time"
+)
+
+// Defaults for HTTP cluster transports.
+const (
+	defaultHTTPMaxIdleConns    = 64
+	defaultHTTPIdleConnTimeout = 90 * time.Second
+)
+
+// httpTransportSettings captures everything that affects how an HTTP
+// cluster's transport is built. Clusters whose settings are unchanged across
+// a reload keep their transport, and with it their open connections.
+type httpTransportSettings struct {
+	maxIdleConns    int
+	idleConnTimeout time.Duration
+}
+
+func httpSettingsOf(cc *CompiledCluster) httpTransportSettings {
+	s := httpTransportSettings{
+		maxIdleConns:    defaultHTTPMaxIdleConns,
+		idleConnTimeout: defaultHTTPIdleConnTimeout,
+	}
+	if ka := cc.Keepalive; ka != nil {
+		if ka.MaxIdleConns > 0 {
+			s.maxIdleConns = ka.MaxIdleConns
+		}
+		if d := ka.IdleConnTimeoutDuration(); d > 0 {
+			s.idleConnTimeout = d
+		}
+	}
+	return s
+}
+
+// httpTransport is the transport shared by all requests proxied to one
+// HTTP, GraphQL or HTTP-based Dubbo cluster. Up to maxIdleConns idle
+// connections to each endpoint are kept for reuse.
+type httpTransport struct {
+	settings httpTransportSettings
+	base     *http.Transport
+}
+
+func newHTTPTransport(s httpTransportSettings) *httpTransport {
+	base := http.DefaultTransport.(*http.Transport).Clone()
+	// The per-endpoint limit bounds the pool; endpoints are few.
+	base.MaxIdleConns = 0
+	base.MaxIdleConnsPerHost = s.maxIdleConns
+	base.IdleConnTimeout = s.idleConnTimeout
+	return &httpTransport{settings: s, base: base}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *httpTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.base.RoundTrip(req)
+}
+
+// CloseIdleConnections closes the idle connections of t.
+func (t *httpTransport) CloseIdleConnections() {
+	t.base.CloseIdleConnections()
+}
+
+// httpTransports hands out one transport per HTTP cluster.
+var httpTransports = newHTTPTransportPool()
+
+func newHTTPTransportPool() *transportPool[httpTransportSettings, *httpTransport] {
+	return newTransportPool(httpSettingsOf, newHTTPTransport, func(cc *CompiledCluster) *httpTransport { return cc.proxyTransport })
+}
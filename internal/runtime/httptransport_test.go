@@ -0,0 +1,79 @@
+package runtime
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/oriys/nexus/internal/config"
+)
+
+func TestHTTPTransportPool_ReusesUnchangedClusters(t *testing.T) {
+	pool := newHTTPTransportPool()
+	cc := &CompiledCluster{Name: "svc", Type: "http", Keepalive: &config.KeepaliveConfig{MaxIdleConns: 8, IdleConnTimeout: "30s"}}
+
+	first := pool.forCluster(cc)
+	if again := pool.forCluster(&CompiledCluster{Name: "svc", Type: "http", Keepalive: &config.KeepaliveConfig{MaxIdleConns: 8, IdleConnTimeout: "30s"}}); again != first {
+		t.Error("expected unchanged settings to reuse the transport")
+	}
+	if first.base.MaxIdleConnsPerHost != 8 || first.base.IdleConnTimeout != 30*time.Second {
+		t.Errorf("unexpected keepalive settings: %d idle conns, %v timeout", first.base.MaxIdleConnsPerHost, first.base.IdleConnTimeout)
+	}
+	if d := pool.forCluster(&CompiledCluster{Name: "other", Type: "http"}); d.base.MaxIdleConnsPerHost != defaultHTTPMaxIdleConns || d.base.IdleConnTimeout != defaultHTTPIdleConnTimeout {
+		t.Errorf("unexpected default settings: %d idle conns, %v timeout", d.base.MaxIdleConnsPerHost, d.base.IdleConnTimeout)
+	}
+
+	changed := pool.forCluster(&CompiledCluster{Name: "svc", Type: "http", Keepalive: &config.KeepaliveConfig{MaxIdleConns: 16}})
+	if changed == first {
+		t.Error("expected changed settings to create a new transport")
+	}
+
+	cc.proxyTransport = changed
+	pool.retain(map[string]*CompiledCluster{"svc": cc})
+	if len(pool.transports) != 1 {
+		t.Errorf("expected only the live cluster transport to be retained, got %d", len(pool.transports))
+	}
+	pool.retain(map[string]*CompiledCluster{})
+	if len(pool.transports) != 0 {
+		t.Errorf("expected removed cluster transport to be dropped")
+	}
+}
+
+func TestHTTPUpstream_ReusesConnections(t *testing.T) {
+	var conns atomic.Int32
+	backend := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Host))
+	}))
+	backend.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	backend.Start()
+	defer backend.Close()
+
+	cfg := &config.Config{
+		Clusters: []config.Cluster{{Name: "web", Type: "http", Endpoints: []config.ClusterEndpoint{{URL: backend.URL}}}},
+		RoutesV2: []config.RouteV2{{Name: "web", Match: config.RouteMatch{PathPrefix: "/"}, Upstream: config.RouteUpstream{Cluster: "web"}}},
+	}
+	compiled, err := Compile(cfg, 1)
+	if err != nil {
+		t.Fatalf("compile error: %v", err)
+	}
+	store := NewConfigStore()
+	Activate(compiled, store)
+	gw := NewGateway(store)
+	for range 5 {
+		rec := httptest.NewRecorder()
+		gw.ServeHTTP(rec, httptest.NewRequest("GET", "http://api.example.com/", nil))
+		if rec.Code != http.StatusOK || rec.Body.String() != "api.example.com" {
+			t.Fatalf("status %d, body %q", rec.Code, rec.Body.String())
+		}
+	}
+	if n := conns.Load(); n != 1 {
+		t.Errorf("expected one upstream connection, got %d", n)
+	}
+}
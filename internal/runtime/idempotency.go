@@ -0,0 +1,214 @@
+package runtime
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"hash"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/oriys/nexus/internal/auth"
+	"github.com/oriys/nexus/internal/config"
+	"github.com/oriys/nexus/internal/httperror"
+	"github.com/oriys/nexus/internal/reqctx"
+)
+
+const defaultIdempotencyEntries = 10000
+
+// IdempotencyCache replays the first response to an idempotency key to the
+// retries of a route's requests. It is safe for concurrent use.
+type IdempotencyCache struct {
+	cfg        config.Idempotency
+	header     string
+	methods    map[string]bool
+	window     time.Duration
+	maxEntries int
+	maxBytes   int64
+	now        func() time.Time
+	mu         sync.Mutex
+	entries    map[string]*list.Element // values are *idempotencyEntry
+	lru        *list.List
+	// inFlight holds the keys whose first request is being served.
+	inFlight map[string]bool
+}
+
+type idempotencyEntry struct {
+	key     string
+	digest  [sha256.Size]byte // of the request body
+	status  int
+	header  http.Header
+	body    []byte
+	expires time.Time
+}
+
+// idempotencyCaches holds the idempotency cache of each route. It lives as
+// long as the process so that kept responses survive config reloads that
+// leave the route's settings alone.
+var idempotencyCaches = struct {
+	sync.Mutex
+	routes map[string]*IdempotencyCache
+}{routes: make(map[string]*IdempotencyCache)}
+
+// idempotencyCacheFor returns the idempotency cache of route, keeping the
+// current one when its settings are unchanged.
+func idempotencyCacheFor(route string, cfg *config.Idempotency) *IdempotencyCache {
+	idempotencyCaches.Lock()
+	defer idempotencyCaches.Unlock()
+	if c := idempotencyCaches.routes[route]; c != nil && reflect.DeepEqual(c.cfg, *cfg) {
+		return c
+	}
+	c := newIdempotencyCache(cfg)
+	idempotencyCaches.routes[route] = c
+	return c
+}
+
+func newIdempotencyCache(cfg *config.Idempotency) *IdempotencyCache {
+	c := &IdempotencyCache{
+		cfg:        *cfg,
+		header:     cfg.HeaderName(),
+		methods:    make(map[string]bool),
+		window:     cfg.WindowDuration(),
+		maxEntries: cfg.MaxEntries,
+		maxBytes:   cfg.MaxResponseLimit(),
+		now:        time.Now,
+		entries:    make(map[string]*list.Element),
+		lru:        list.New(),
+		inFlight:   make(map[string]bool),
+	}
+	if c.maxEntries <= 0 {
+		c.maxEntries = defaultIdempotencyEntries
+	}
+	for _, m := range cfg.MethodList() {
+		c.methods[m] = true
+	}
+	return c
+}
+
+// key returns the scoped idempotency key of r on route, or false when r
+// carries none or its method is not deduplicated.
+func (c *IdempotencyCache) key(r *http.Request, route string) (string, bool) {
+	v := r.Header.Get(c.header)
+	if v == "" || !c.methods[r.Method] {
+		return "", false
+	}
+	parts := []string{route, r.Method, r.URL.Path}
+	if id := auth.GetIdentity(r.Context()); id != nil {
+		parts = append(parts, id.Source+":"+id.Subject)
+	} else {
+		parts = append(parts, "")
+	}
+	tenant, _ := reqctx.Tenant.Get(r.Context())
+	return strings.Join(append(parts, tenant, v), "\x00"), true
+}
+
+// serve deduplicates r on route. It answers duplicates itself and returns
+// false; for the first request of a key it returns the writer to serve it
+// with and a function to call once it is served.
+func (c *IdempotencyCache) serve(w http.ResponseWriter, r *http.Request, route string) (http.ResponseWriter, func(), bool) {
+	key, ok := c.key(r, route)
+	if !ok {
+		return w, func() {}, true
+	}
+	now := c.now()
+	c.mu.Lock()
+	if el, ok := c.entries[key]; ok {
+		e := el.Value.(*idempotencyEntry)
+		if now.Before(e.expires) {
+			c.lru.MoveToFront(el)
+			c.mu.Unlock()
+			c.replay(w, r, e)
+			return nil, nil, false
+		}
+		c.remove(el)
+	}
+	if c.inFlight[key] {
+		c.mu.Unlock()
+		writeIdempotencyError(w, r, http.StatusConflict, "idempotency_key_in_use", "a request with this idempotency key is in progress")
+		return nil, nil, false
+	}
+	c.inFlight[key] = true
+	c.mu.Unlock()
+
+	digest := sha256.New()
+	if r.Body != nil && r.Body != http.NoBody {
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.TeeReader(r.Body, digest), r.Body}
+	}
+	rec := &bodyRecorder{ResponseWriter: w, limit: c.maxBytes}
+	return rec, func() { c.store(key, r, digest, rec) }, true
+}
+
+// replay writes the response kept in e, unless r reuses its key for a
+// different body.
+func (c *IdempotencyCache) replay(w http.ResponseWriter, r *http.Request, e *idempotencyEntry) {
+	digest := sha256.New()
+	if r.Body != nil {
+		io.Copy(digest, r.Body)
+	}
+	if [sha256.Size]byte(digest.Sum(nil)) != e.digest {
+		writeIdempotencyError(w, r, http.StatusUnprocessableEntity, "idempotency_key_reused", "the idempotency key was used with a different request body")
+		return
+	}
+	for name, values := range e.header {
+		w.Header()[name] = values
+	}
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(e.status)
+	if r.Method != http.MethodHead {
+		w.Write(e.body)
+	}
+}
+
+// store keeps the response captured by rec for key. Server errors and
+// responses too large to keep are not replayed, so the request can be
+// retried.
+func (c *IdempotencyCache) store(key string, r *http.Request, digest hash.Hash, rec *bodyRecorder) {
+	// The digest covers the whole body, including what the upstream did
+	// not read.
+	if r.Body != nil {
+		io.Copy(io.Discard, r.Body)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.inFlight, key)
+	if rec.status == 0 || rec.status >= 500 || rec.overflow {
+		return
+	}
+	header := rec.Header().Clone()
+	for _, name := range []string{"Content-Length", "Date"} {
+		header.Del(name)
+	}
+	e := &idempotencyEntry{
+		key:     key,
+		digest:  [sha256.Size]byte(digest.Sum(nil)),
+		status:  rec.status,
+		header:  header,
+		body:    rec.body.Bytes(),
+		expires: c.now().Add(c.window),
+	}
+	if el, ok := c.entries[key]; ok {
+		c.remove(el)
+	}
+	c.entries[key] = c.lru.PushFront(e)
+	for c.lru.Len() > c.maxEntries {
+		c.remove(c.lru.Back())
+	}
+}
+
+func (c *IdempotencyCache) remove(el *list.Element) {
+	c.lru.Remove(el)
+	delete(c.entries, el.Value.(*idempotencyEntry).key)
+}
+
+func writeIdempotencyError(w http.ResponseWriter, r *http.Request, status int, code, message string) {
+	if status == http.StatusConflict {
+		w.Header().Set("Retry-After", "1")
+	}
+	httperror.Write(w, r, status, code, message)
+}
@@ -0,0 +1,113 @@
+package runtime
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/oriys/nexus/internal/config"
+)
+
+func TestGateway_Idempotency(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		switch r.URL.Path {
+		case "/orders/fail":
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		case "/orders/slow":
+			<-release
+		}
+		w.Header().Set("Location", fmt.Sprintf("/orders/%d", n))
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, "order %d", n)
+	}))
+	defer backend.Close()
+
+	cfg := &config.Config{
+		Clusters: []config.Cluster{{Name: "orders", Type: "http", Endpoints: []config.ClusterEndpoint{{URL: backend.URL}}}},
+		RoutesV2: []config.RouteV2{{
+			Name:        "idempotent-orders",
+			Match:       config.RouteMatch{PathPrefix: "/orders"},
+			Upstream:    config.RouteUpstream{Cluster: "orders"},
+			Idempotency: &config.Idempotency{Window: "1h"},
+		}},
+	}
+	compiled, err := Compile(cfg, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := NewConfigStore()
+	store.Store(compiled)
+	gw := NewGateway(store)
+	serve := func(path, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, strings.NewReader(body))
+		if key != "" {
+			req.Header.Set("Idempotency-Key", key)
+		}
+		rec := httptest.NewRecorder()
+		gw.ServeHTTP(rec, req)
+		return rec
+	}
+
+	tests := []struct {
+		path, key, body string
+		status          int
+		want            string
+		calls           int32
+	}{
+		{"/orders", "a", `{"sku":1}`, 201, "order 1", 1},
+		{"/orders", "a", `{"sku":1}`, 201, "order 1", 1},
+		{"/orders", "a", `{"sku":2}`, 422, "idempotency_key_reused", 1},
+		{"/orders", "b", `{"sku":1}`, 201, "order 2", 2},
+		{"/orders", "", `{"sku":1}`, 201, "order 3", 3},
+		{"/orders", "", `{"sku":1}`, 201, "order 4", 4},
+		{"/orders/fail", "c", "", 500, "", 5},
+		{"/orders/fail", "c", "", 500, "", 6},
+	}
+	for i, tt := range tests {
+		rec := serve(tt.path, tt.key, tt.body)
+		if rec.Code != tt.status || !strings.Contains(rec.Body.String(), tt.want) || calls.Load() != tt.calls {
+			t.Fatalf("step %d: status %d, body %q, %d calls", i, rec.Code, rec.Body.String(), calls.Load())
+		}
+	}
+	if rec := serve("/orders", "a", `{"sku":1}`); rec.Header().Get("Idempotent-Replayed") != "true" || rec.Header().Get("Location") != "/orders/1" {
+		t.Errorf("replay headers %v", rec.Header())
+	}
+
+	// A retry while the first request is in flight is turned away.
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- serve("/orders/slow", "d", "") }()
+	for calls.Load() != 7 {
+		time.Sleep(time.Millisecond)
+	}
+	if rec := serve("/orders/slow", "d", ""); rec.Code != http.StatusConflict {
+		t.Errorf("expected 409 in flight, got %d", rec.Code)
+	}
+	close(release)
+	if rec := <-done; rec.Code != http.StatusCreated {
+		t.Fatalf("status %d", rec.Code)
+	}
+
+	// A reload with the same settings keeps the responses, until the window
+	// ends.
+	reloaded, err := Compile(cfg, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before, _ := compiled.Router.Match(httptest.NewRequest("POST", "/orders", nil))
+	after, _ := reloaded.Router.Match(httptest.NewRequest("POST", "/orders", nil))
+	if after.Idempotency != before.Idempotency {
+		t.Fatal("cache was not kept across the reload")
+	}
+	after.Idempotency.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	if rec := serve("/orders", "a", `{"sku":1}`); rec.Body.String() != "order 8" {
+		t.Errorf("after the window got %q", rec.Body.String())
+	}
+}
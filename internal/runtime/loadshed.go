@@ -0,0 +1,189 @@
+package runtime
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/oriys/nexus/internal/config"
+	"github.com/oriys/nexus/internal/httperror"
+	"github.com/oriys/nexus/internal/promtext"
+)
+
+// Priority tiers of load shedding, from the first shed to the never shed.
+var tierNames = [...]string{"low", "normal", "high", "critical"}
+
+const tierNormal = 1
+
+// tierShedAt is the load from which the requests of each tier are shed.
+var tierShedAt = [...]float64{0.6, 0.8, 1, math.Inf(1)}
+
+// latencyHalfLife is how quickly the average latency decays while no
+// requests complete, so a gateway shedding everything recovers.
+const latencyHalfLife = time.Second
+
+// latencyWeight is the weight of a new sample in the average latency.
+const latencyWeight = 0.1
+
+// gatewayLoad tracks the requests in flight and the recent latency of the
+// gateway. It lives as long as the process, so requests in flight are
+// counted across configuration reloads.
+type gatewayLoad struct {
+	inFlight atomic.Int64
+	shed     [len(tierNames)]atomic.Uint64
+	// latency is the average latency as of the last sample; it is replaced
+	// rather than locked, as every request reads and updates it.
+	latency atomic.Pointer[latencySample]
+}
+
+type latencySample struct {
+	seconds float64
+	at      time.Time
+}
+
+var loadState = &gatewayLoad{}
+
+// degraded is set while the watchdog holds the gateway in degraded mode.
+var degraded atomic.Bool
+
+// SetDegraded switches the degraded mode of the gateway. While it is on,
+// requests are shed as if the gateway were fully loaded, and mirroring and
+// storing cache entries, which buffer bodies, are skipped.
+func SetDegraded(on bool) {
+	degraded.Store(on)
+}
+
+// degradedShedder sheds the requests of degraded gateways without load
+// shedding configured.
+var degradedShedder = &LoadShedder{defaultTier: tierNormal}
+
+// averageLatency returns the recent average latency in seconds, decayed by
+// the time since the last sample.
+func (l *gatewayLoad) averageLatency(now time.Time) float64 {
+	return l.latency.Load().decayed(now)
+}
+
+func (s *latencySample) decayed(now time.Time) float64 {
+	if s == nil {
+		return 0
+	}
+	return s.seconds * math.Exp2(-now.Sub(s.at).Seconds()/latencyHalfLife.Seconds())
+}
+
+func (l *gatewayLoad) observe(d time.Duration) {
+	now := time.Now()
+	for {
+		prev := l.latency.Load()
+		avg := prev.decayed(now)
+		next := &latencySample{seconds: avg + (d.Seconds()-avg)*latencyWeight, at: now}
+		if l.latency.CompareAndSwap(prev, next) {
+			return
+		}
+	}
+}
+
+// LoadShedder rejects requests of low priority tiers while the gateway is
+// overloaded.
+type LoadShedder struct {
+	maxInFlight int64
+	maxLatency  float64 // seconds
+	header      string
+	defaultTier int
+}
+
+// NewLoadShedder compiles ls, returning nil when load shedding is not
+// configured.
+func NewLoadShedder(ls *config.LoadShedding) *LoadShedder {
+	if ls == nil {
+		return nil
+	}
+	s := &LoadShedder{maxInFlight: int64(ls.MaxInFlight), header: ls.Header, defaultTier: tierNormal}
+	if d, _ := ls.MaxLatency.Parse(); d > 0 {
+		s.maxLatency = d.Seconds()
+	}
+	if t, ok := tierIndex(ls.DefaultTier); ok {
+		s.defaultTier = t
+	}
+	return s
+}
+
+func tierIndex(name string) (int, bool) {
+	for i, n := range tierNames {
+		if n == name {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// load returns the load of the gateway relative to the thresholds of s,
+// where 1 is fully loaded.
+func (s *LoadShedder) load() float64 {
+	var load float64
+	if s.maxInFlight > 0 {
+		load = float64(loadState.inFlight.Load()) / float64(s.maxInFlight)
+	}
+	if s.maxLatency > 0 {
+		load = max(load, loadState.averageLatency(time.Now())/s.maxLatency)
+	}
+	if degraded.Load() {
+		load = max(load, 1)
+	}
+	return load
+}
+
+// tier returns the tier of r on route: the tier of the route, or the
+// default tier, lowered by the tier header. Clients can send the header
+// too, so it never raises a request above the tier of its route.
+func (s *LoadShedder) tier(r *http.Request, route *CompiledRoute) int {
+	tier := s.defaultTier
+	if t, ok := tierIndex(route.Tier); ok {
+		tier = t
+	}
+	if s.header != "" {
+		if t, ok := tierIndex(r.Header.Get(s.header)); ok && t < tier {
+			return t
+		}
+	}
+	return tier
+}
+
+// Admit reports whether r is served under the current load. An admitted
+// request is counted in flight until done is called.
+func (s *LoadShedder) Admit(r *http.Request, route *CompiledRoute) (done func(), ok bool) {
+	tier := s.tier(r, route)
+	if s.load() >= tierShedAt[tier] {
+		loadState.shed[tier].Add(1)
+		return nil, false
+	}
+	loadState.inFlight.Add(1)
+	start := time.Now()
+	return func() {
+		loadState.inFlight.Add(-1)
+		loadState.observe(time.Since(start))
+	}, true
+}
+
+// writeOverloaded answers a request shed by the gateway.
+func writeOverloaded(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Retry-After", "1")
+	httperror.Write(w, r, http.StatusServiceUnavailable, httperror.CodeOverloaded, "the gateway is overloaded, retry later")
+}
+
+// WriteLoadSheddingMetrics writes the load shedding metrics in the
+// Prometheus text exposition format.
+func WriteLoadSheddingMetrics(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "# HELP nexus_load_shed_total Requests shed by the gateway under load, by priority tier.\n# TYPE nexus_load_shed_total counter\n")
+	for i, name := range tierNames {
+		fmt.Fprintf(bw, "nexus_load_shed_total{tier=%s} %s\n", promtext.QuoteLabel(name), strconv.FormatUint(loadState.shed[i].Load(), 10))
+	}
+	fmt.Fprintf(bw, "# HELP nexus_load_in_flight Requests in flight on routes subject to load shedding.\n# TYPE nexus_load_in_flight gauge\n")
+	fmt.Fprintf(bw, "nexus_load_in_flight %d\n", loadState.inFlight.Load())
+	return bw.Flush()
+}
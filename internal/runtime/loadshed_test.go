@@ -0,0 +1,192 @@
+package runtime
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/oriys/nexus/internal/config"
+)
+
+func TestLoadShedder_InFlight(t *testing.T) {
+	s := NewLoadShedder(&config.LoadShedding{MaxInFlight: 5, Header: "X-Priority"})
+	request := func(tier string) *http.Request {
+		r := httptest.NewRequest("GET", "/", nil)
+		if tier != "" {
+			r.Header.Set("X-Priority", tier)
+		}
+		return r
+	}
+	route := &CompiledRoute{Name: "reports", Tier: "low"}
+	var shedBefore [len(tierNames)]uint64
+	for i := range shedBefore {
+		shedBefore[i] = loadState.shed[i].Load()
+	}
+
+	var dones []func()
+	admit := func(r *http.Request, route *CompiledRoute) bool {
+		done, ok := s.Admit(r, route)
+		if ok {
+			dones = append(dones, done)
+		}
+		return ok
+	}
+	for i := 0; i < 3; i++ {
+		if !admit(request(""), route) {
+			t.Fatalf("request %d shed", i)
+		}
+	}
+	// At 3 of 5 in flight the low route is shed; the header can lower the
+	// tier of a request but not raise it.
+	high := &CompiledRoute{Name: "search", Tier: "high"}
+	steps := []struct {
+		tier  string
+		route *CompiledRoute
+		want  bool
+	}{
+		{"", route, false},
+		{"critical", route, false},
+		{"", &CompiledRoute{Name: "orders"}, true},
+		{"", &CompiledRoute{Name: "orders"}, false},
+		{"low", high, false},
+		{"", high, true},
+		{"critical", high, false},
+		{"", &CompiledRoute{Name: "payments", Tier: "critical"}, true},
+	}
+	for i, st := range steps {
+		if got := admit(request(st.tier), st.route); got != st.want {
+			t.Errorf("step %d (%q on %s): admitted %v, want %v", i, st.tier, st.route.Name, got, st.want)
+		}
+	}
+	for _, done := range dones {
+		done()
+	}
+	if n := loadState.inFlight.Load(); n != 0 {
+		t.Errorf("%d requests still in flight", n)
+	}
+	for tier, want := range map[int]uint64{0: 3, 1: 1, 2: 1, 3: 0} {
+		if got := loadState.shed[tier].Load() - shedBefore[tier]; got != want {
+			t.Errorf("%s: shed %d, want %d", tierNames[tier], got, want)
+		}
+	}
+
+	var b strings.Builder
+	if err := WriteLoadSheddingMetrics(&b); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), `nexus_load_shed_total{tier="low"} `) || !strings.Contains(b.String(), "nexus_load_in_flight 0\n") {
+		t.Errorf("metrics:\n%s", b.String())
+	}
+}
+
+func TestLoadShedder_Latency(t *testing.T) {
+	s := NewLoadShedder(&config.LoadShedding{MaxLatency: "100ms", DefaultTier: "high"})
+	route := &CompiledRoute{Name: "orders"}
+	defer loadState.latency.Store(nil)
+
+	loadState.latency.Store(&latencySample{seconds: 0.2, at: time.Now()})
+	if _, ok := s.Admit(httptest.NewRequest("GET", "/", nil), route); ok {
+		t.Error("admitted at twice the latency limit")
+	}
+	// The average decays while everything is shed, so traffic resumes.
+	loadState.latency.Store(&latencySample{seconds: 0.2, at: time.Now().Add(-3 * latencyHalfLife)})
+	done, ok := s.Admit(httptest.NewRequest("GET", "/", nil), route)
+	if !ok {
+		t.Fatal("shed after the latency decayed")
+	}
+	done()
+}
+
+func TestGateway_LoadShedding(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer backend.Close()
+	cfg := &config.Config{
+		Clusters:     []config.Cluster{{Name: "backend", Type: "http", Endpoints: []config.ClusterEndpoint{{URL: backend.URL}}}},
+		RoutesV2:     []config.RouteV2{{Name: "orders", Match: config.RouteMatch{PathPrefix: "/"}, Upstream: config.RouteUpstream{Cluster: "backend"}, Tier: "low"}},
+		LoadShedding: &config.LoadShedding{MaxInFlight: 1},
+	}
+	compiled, err := Compile(cfg, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := NewConfigStore()
+	store.Store(compiled)
+	gw := NewGateway(store)
+
+	rec := httptest.NewRecorder()
+	gw.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d", rec.Code)
+	}
+	done, _ := compiled.LoadShedder.Admit(httptest.NewRequest("GET", "/", nil), &CompiledRoute{Tier: "critical"})
+	defer done()
+	rec = httptest.NewRecorder()
+	gw.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") != "1" || !strings.Contains(rec.Body.String(), `"overloaded"`) {
+		t.Errorf("got %d retry %q: %s", rec.Code, rec.Header().Get("Retry-After"), rec.Body.String())
+	}
+}
+
+func TestGateway_Degraded(t *testing.T) {
+	var mirrored atomic.Int64
+	shadow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { mirrored.Add(1) }))
+	defer shadow.Close()
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+	}))
+	defer backend.Close()
+	cfg := &config.Config{
+		Clusters: []config.Cluster{
+			{Name: "backend", Type: "http", Endpoints: []config.ClusterEndpoint{{URL: backend.URL}}},
+			{Name: "shadow", Type: "http", Endpoints: []config.ClusterEndpoint{{URL: shadow.URL}}},
+		},
+		RoutesV2: []config.RouteV2{
+			{Name: "degraded-reports", Match: config.RouteMatch{PathPrefix: "/reports"}, Upstream: config.RouteUpstream{Cluster: "backend"}},
+			{
+				Name:     "degraded-payments",
+				Match:    config.RouteMatch{PathPrefix: "/payments"},
+				Upstream: config.RouteUpstream{Cluster: "backend", Mirror: &config.RouteMirror{Cluster: "shadow", Percent: 100}},
+				Cache:    &config.RouteCache{},
+				Tier:     "critical",
+			},
+		},
+	}
+	compiled, err := Compile(cfg, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := NewConfigStore()
+	store.Store(compiled)
+	gw := NewGateway(store)
+	defer PurgeResponseCache("degraded-payments")
+
+	SetDegraded(true)
+	defer SetDegraded(false)
+	// Without load shedding configured, all but critical requests are shed.
+	rec := httptest.NewRecorder()
+	gw.ServeHTTP(rec, httptest.NewRequest("GET", "/reports", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("normal request to a degraded gateway: status %d", rec.Code)
+	}
+	for range 2 {
+		rec = httptest.NewRecorder()
+		gw.ServeHTTP(rec, httptest.NewRequest("GET", "/payments", nil))
+		if rec.Code != http.StatusOK || rec.Header().Get("X-Cache") != "MISS" {
+			t.Errorf("critical request to a degraded gateway: status %d, X-Cache %q", rec.Code, rec.Header().Get("X-Cache"))
+		}
+	}
+	time.Sleep(50 * time.Millisecond)
+	if n := mirrored.Load(); n != 0 {
+		t.Errorf("degraded gateway mirrored %d requests", n)
+	}
+
+	SetDegraded(false)
+	rec = httptest.NewRecorder()
+	gw.ServeHTTP(rec, httptest.NewRequest("GET", "/reports", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("request after recovery: status %d", rec.Code)
+	}
+}
@@ -0,0 +1,43 @@
+package runtime
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/oriys/nexus/internal/config"
+)
+
+// MaintenancePage answers requests with a maintenance response.
+type MaintenancePage struct {
+	status      int
+	contentType string
+	body        []byte
+	retryAfter  string // seconds; empty leaves Retry-After unset
+}
+
+// NewMaintenancePage compiles the response of m, returning nil when m is
+// not enabled.
+func NewMaintenancePage(m *config.Maintenance) *MaintenancePage {
+	if m == nil || !m.Enabled {
+		return nil
+	}
+	p := &MaintenancePage{}
+	p.status, p.contentType, p.body = m.Response()
+	if d, _ := m.RetryAfter.Parse(); d > 0 {
+		p.retryAfter = strconv.Itoa(int((d + time.Second - 1) / time.Second))
+	}
+	return p
+}
+
+func (p *MaintenancePage) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", p.contentType)
+	w.Header().Set("Cache-Control", "no-store")
+	if p.retryAfter != "" {
+		w.Header().Set("Retry-After", p.retryAfter)
+	}
+	w.WriteHeader(p.status)
+	if r.Method != http.MethodHead {
+		w.Write(p.body)
+	}
+}
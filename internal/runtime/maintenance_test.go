@@ -0,0 +1,76 @@
+package runtime
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/oriys/nexus/internal/config"
+)
+
+func TestMaintenancePage(t *testing.T) {
+	if NewMaintenancePage(&config.Maintenance{Status: 503}) != nil {
+		t.Error("disabled switch compiled to a page")
+	}
+	tests := []struct {
+		name                     string
+		m                        config.Maintenance
+		status                   int
+		contentType, retry, body string
+	}{
+		{"default", config.Maintenance{Enabled: true}, 503, "application/json", "",
+			`{"error":"maintenance","message":"service under maintenance"}`},
+		{"html", config.Maintenance{Enabled: true, Body: "<h1>Back soon</h1>", RetryAfter: "90s"}, 503, "text/html; charset=utf-8", "90", "<h1>Back soon</h1>"},
+		{"custom", config.Maintenance{Enabled: true, Status: 502, ContentType: "text/plain", Body: "down", RetryAfter: "1500ms"}, 502, "text/plain", "2", "down"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			NewMaintenancePage(&tt.m).ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+			if rec.Code != tt.status || rec.Header().Get("Content-Type") != tt.contentType ||
+				rec.Header().Get("Retry-After") != tt.retry || rec.Body.String() != tt.body {
+				t.Errorf("got %d %q retry %q body %q", rec.Code, rec.Header().Get("Content-Type"), rec.Header().Get("Retry-After"), rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestGateway_RouteMaintenance(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer backend.Close()
+
+	cfg := &config.Config{
+		Clusters: []config.Cluster{
+			{Name: "backend", Type: "http", Endpoints: []config.ClusterEndpoint{{URL: backend.URL}}},
+		},
+		RoutesV2: []config.RouteV2{
+			{
+				Name:        "orders",
+				Match:       config.RouteMatch{PathPrefix: "/orders"},
+				Upstream:    config.RouteUpstream{Cluster: "backend"},
+				Maintenance: &config.Maintenance{Enabled: true},
+			},
+			{
+				Name:        "users",
+				Match:       config.RouteMatch{PathPrefix: "/users"},
+				Upstream:    config.RouteUpstream{Cluster: "backend"},
+				Maintenance: &config.Maintenance{Enabled: false},
+			},
+		},
+	}
+	compiled, err := Compile(cfg, 1)
+	if err != nil {
+		t.Fatalf("compile error: %v", err)
+	}
+	store := NewConfigStore()
+	store.Store(compiled)
+	gw := NewGateway(store)
+
+	for path, want := range map[string]int{"/orders/1": 503, "/users/1": 200} {
+		rec := httptest.NewRecorder()
+		gw.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		if rec.Code != want {
+			t.Errorf("%s: expected %d, got %d", path, want, rec.Code)
+		}
+	}
+}
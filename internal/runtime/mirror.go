@@ -0,0 +1,119 @@
+package runtime
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httputil"
+	"time"
+
+	"github.com/oriys/nexus/internal/config"
+)
+
+// mirrorSlots bounds the mirrored requests in flight; copies beyond it are
+// dropped rather than queued, so a slow shadow cluster cannot pile up work
+// in the gateway.
+var mirrorSlots = make(chan struct{}, 256)
+
+// hopHeaders are the connection-specific headers not copied to mirrored
+// requests.
+var hopHeaders = []string{
+	"Connection", "Proxy-Connection", "Keep-Alive", "Proxy-Authenticate",
+	"Proxy-Authorization", "Te", "Trailer", "Transfer-Encoding", "Upgrade",
+}
+
+// RouteMirror sends copies of a route's requests to a shadow cluster.
+type RouteMirror struct {
+	Cluster string
+	percent float64
+	maxBody int64
+	timeout time.Duration
+}
+
+// defaultMirrorTimeout bounds mirrored requests of routes without a timeout.
+const defaultMirrorTimeout = 30 * time.Second
+
+func newRouteMirror(m *config.RouteMirror, timeout time.Duration) *RouteMirror {
+	if timeout <= 0 {
+		timeout = defaultMirrorTimeout
+	}
+	return &RouteMirror{
+		Cluster: m.Cluster,
+		percent: m.Percent,
+		maxBody: m.MaxBodyLimit(),
+		timeout: timeout,
+	}
+}
+
+// send copies r to cluster in the background when r is sampled. The body
+// of r is buffered for the copy and remains readable for the primary
+// upstream; requests with larger bodies than the mirror allows, protocol
+// upgrades and requests to a degraded gateway are not copied.
+func (m *RouteMirror) send(r *http.Request, route *CompiledRoute, cluster *CompiledCluster) {
+	if degraded.Load() {
+		return
+	}
+	if m.percent < 100 && rand.Float64()*100 >= m.percent {
+		return
+	}
+	if r.Header.Get("Upgrade") != "" || r.ContentLength > m.maxBody {
+		return
+	}
+	var body []byte
+	if r.Body != nil && r.Body != http.NoBody {
+		var err error
+		body, err = io.ReadAll(io.LimitReader(r.Body, m.maxBody+1))
+		if err != nil || int64(len(body)) > m.maxBody {
+			r.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	target, _, err := cluster.NextTarget()
+	if err != nil {
+		return
+	}
+
+	select {
+	case mirrorSlots <- struct{}{}:
+	default:
+		slog.Debug("mirror dropped", slog.String("cluster", cluster.Name))
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), m.timeout)
+	out := r.Clone(ctx)
+	out.RequestURI = ""
+	// The target URL is built as for the primary upstream, keeping the
+	// base path and query of the endpoint.
+	(&httputil.ProxyRequest{In: r, Out: out}).SetURL(target)
+	out.Host = r.Host
+	signOutbound(route, out)
+	out.Body = io.NopCloser(bytes.NewReader(body))
+	out.ContentLength = int64(len(body))
+	if body == nil {
+		out.Body = http.NoBody
+	}
+	for _, h := range hopHeaders {
+		out.Header.Del(h)
+	}
+	go func() {
+		defer func() { <-mirrorSlots }()
+		defer cancel()
+		resp, err := cluster.roundTripper().RoundTrip(out)
+		if err != nil {
+			slog.Debug("mirror error",
+				slog.String("cluster", cluster.Name),
+				slog.String("error", err.Error()),
+			)
+			return
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}()
+}
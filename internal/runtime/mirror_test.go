@@ -0,0 +1,103 @@
+package runtime
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oriys/nexus/internal/config"
+)
+
+func TestGateway_Mirror(t *testing.T) {
+	type copied struct{ method, path, body, header string }
+	shadowed := make(chan copied, 10)
+	release := make(chan struct{})
+	shadow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		shadowed <- copied{r.Method, r.URL.Path, string(body), r.Header.Get("X-Gw")}
+		// A slow shadow cluster does not hold up the client.
+		<-release
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer shadow.Close()
+	defer close(release)
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Write(body)
+	}))
+	defer primary.Close()
+
+	cfg := &config.Config{
+		Clusters: []config.Cluster{
+			{Name: "primary", Type: "http", Endpoints: []config.ClusterEndpoint{{URL: primary.URL}}},
+			// The base path of the shadow endpoint is kept, as for the primary.
+			{Name: "shadow", Type: "http", Endpoints: []config.ClusterEndpoint{{URL: shadow.URL + "/v2"}}},
+		},
+		RoutesV2: []config.RouteV2{{
+			Name:    "orders",
+			Match:   config.RouteMatch{PathPrefix: "/orders"},
+			Filters: []config.RouteFilter{{Type: "header_set", Args: config.FilterArgs{"key": "X-Gw", "value": "nexus"}}},
+			Upstream: config.RouteUpstream{
+				Cluster: "primary",
+				Mirror:  &config.RouteMirror{Cluster: "shadow", Percent: 100, MaxBody: "16"},
+			},
+		}},
+	}
+	compiled, err := Compile(cfg, 1)
+	if err != nil {
+		t.Fatalf("compile error: %v", err)
+	}
+	store := NewConfigStore()
+	store.Store(compiled)
+	gw := NewGateway(store)
+
+	serve := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/orders/7", strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		gw.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := serve(`{"id":7}`); rec.Code != http.StatusOK || rec.Body.String() != `{"id":7}` {
+		t.Fatalf("status %d, body %q", rec.Code, rec.Body.String())
+	}
+	select {
+	case c := <-shadowed:
+		if c != (copied{"POST", "/v2/orders/7", `{"id":7}`, "nexus"}) {
+			t.Errorf("shadow got %+v", c)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("request was not mirrored")
+	}
+
+	// Bodies above max_body reach the primary whole and are not copied.
+	large := strings.Repeat("x", 64)
+	if rec := serve(large); rec.Code != http.StatusOK || rec.Body.String() != large {
+		t.Fatalf("status %d, body %q", rec.Code, rec.Body.String())
+	}
+	select {
+	case c := <-shadowed:
+		t.Errorf("large body mirrored: %+v", c)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestCompile_MirrorClusterType(t *testing.T) {
+	cfg := &config.Config{
+		Clusters: []config.Cluster{
+			{Name: "primary", Type: "http", Endpoints: []config.ClusterEndpoint{{URL: "http://primary"}}},
+			{Name: "shadow", Type: "grpc", Endpoints: []config.ClusterEndpoint{{Target: "shadow:50051"}}},
+		},
+		RoutesV2: []config.RouteV2{{
+			Name:     "orders",
+			Match:    config.RouteMatch{PathPrefix: "/orders"},
+			Upstream: config.RouteUpstream{Cluster: "primary", Mirror: &config.RouteMirror{Cluster: "shadow", Percent: 100}},
+		}},
+	}
+	if _, err := Compile(cfg, 1); err == nil || !strings.Contains(err.Error(), `mirror cluster "shadow" is not an HTTP cluster`) {
+		t.Errorf("expected mirror cluster error, got %v", err)
+	}
+}
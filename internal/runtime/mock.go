@@ -0,0 +1,139 @@
+package runtime
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"text/template"
+
+	"github.com/oriys/nexus/internal/config"
+	"github.com/oriys/nexus/internal/httperror"
+	"github.com/oriys/nexus/internal/pathmatch"
+)
+
+// maxMockRequestBody bounds the request body a mock route reads into its
+// template data.
+const maxMockRequestBody = 1 << 20
+
+// MockResponse renders the response of a route with upstream.mock.
+type MockResponse struct {
+	status  int
+	headers map[string]*template.Template
+	body    *template.Template
+}
+
+// mockData is the data the templates of a mock route render.
+type mockData struct {
+	Method  string
+	Path    string
+	Query   url.Values
+	Headers http.Header
+	Params  map[string]string
+	Body    string
+	JSON    any
+}
+
+func compileMock(m *config.RouteUpstreamMock) (*MockResponse, error) {
+	mr := &MockResponse{status: m.Status, headers: make(map[string]*template.Template, len(m.Headers))}
+	if mr.status == 0 {
+		mr.status = http.StatusOK
+	}
+	var err error
+	if mr.body, err = template.New("body").Funcs(bodyTemplateFuncs).Parse(m.Body); err != nil {
+		return nil, fmt.Errorf("upstream.mock.body: %w", err)
+	}
+	for name, v := range m.Headers {
+		if mr.headers[name], err = template.New(name).Funcs(bodyTemplateFuncs).Parse(v); err != nil {
+			return nil, fmt.Errorf("upstream.mock.headers[%s]: %w", name, err)
+		}
+	}
+	return mr, nil
+}
+
+// serve renders the mock response to r. The response filters of route run
+// on it as they would on an upstream response.
+func (m *MockResponse) serve(w http.ResponseWriter, r *http.Request, route *CompiledRoute) {
+	resp, err := m.render(r)
+	if err == nil {
+		if modify := modifyResponse(route); modify != nil {
+			err = modify(resp)
+		}
+	}
+	if err != nil {
+		slog.Error("mock response error",
+			slog.String("route", route.Name),
+			slog.String("error", err.Error()),
+		)
+		httperror.Write(w, r, http.StatusInternalServerError, httperror.CodeInternal, "mock response error")
+		return
+	}
+	defer resp.Body.Close()
+	for k, vv := range resp.Header {
+		w.Header()[k] = vv
+	}
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
+
+// render builds the mock response to r.
+func (m *MockResponse) render(r *http.Request) (*http.Response, error) {
+	data := mockData{
+		Method:  r.Method,
+		Path:    r.URL.Path,
+		Query:   r.URL.Query(),
+		Headers: r.Header,
+		Params:  pathmatch.Params(r.Context()),
+		// Fields of a missing or non-JSON body render as null
+		JSON: map[string]any{},
+	}
+	if r.Body != nil {
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxMockRequestBody))
+		if err != nil {
+			return nil, fmt.Errorf("read request body: %w", err)
+		}
+		data.Body = string(body)
+		dec := json.NewDecoder(bytes.NewReader(body))
+		dec.UseNumber()
+		var v any
+		if dec.Decode(&v) == nil && v != nil {
+			data.JSON = v
+		}
+	}
+
+	var body bytes.Buffer
+	if err := m.body.Execute(&body, data); err != nil {
+		return nil, err
+	}
+	header := make(http.Header, len(m.headers)+2)
+	for name, tmpl := range m.headers {
+		var v bytes.Buffer
+		if err := tmpl.Execute(&v, data); err != nil {
+			return nil, err
+		}
+		header.Set(name, v.String())
+	}
+	if header.Get("Content-Type") == "" && body.Len() > 0 {
+		if json.Valid(body.Bytes()) {
+			header.Set("Content-Type", "application/json")
+		} else {
+			header.Set("Content-Type", http.DetectContentType(body.Bytes()))
+		}
+	}
+	header.Set("Content-Length", strconv.Itoa(body.Len()))
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", m.status, http.StatusText(m.status)),
+		StatusCode:    m.status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(&body),
+		ContentLength: int64(body.Len()),
+		Request:       r,
+	}, nil
+}
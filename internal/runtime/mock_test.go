@@ -0,0 +1,79 @@
+package runtime
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/oriys/nexus/internal/config"
+)
+
+func TestGateway_Mock(t *testing.T) {
+	cfg := &config.Config{
+		RoutesV2: []config.RouteV2{
+			{
+				Name:  "user",
+				Match: config.RouteMatch{Path: "/users/{id}"},
+				Filters: []config.RouteFilter{
+					{Type: "response_header_set", Args: config.FilterArgs{"key": "X-Mocked", "value": "true"}},
+				},
+				Upstream: config.RouteUpstream{Mock: &config.RouteUpstreamMock{
+					Status:  201,
+					Headers: map[string]string{"X-Request-Method": "{{.Method}}"},
+					Body:    `{"id": {{json .Params.id}}, "name": {{json .JSON.name}}, "tenant": {{json (.Headers.Get "X-Tenant")}}, "page": {{json (.Query.Get "page")}}}`,
+				}},
+			},
+			{
+				Name:     "text",
+				Match:    config.RouteMatch{Path: "/hello"},
+				Upstream: config.RouteUpstream{Mock: &config.RouteUpstreamMock{Body: "hello {{.Headers.Get \"X-Name\"}}"}},
+			},
+		},
+	}
+	compiled, err := Compile(cfg, 1)
+	if err != nil {
+		t.Fatalf("compile error: %v", err)
+	}
+	store := NewConfigStore()
+	store.Store(compiled)
+	gw := NewGateway(store)
+
+	req := httptest.NewRequest("POST", "/users/42?page=3", strings.NewReader(`{"name": "Ann"}`))
+	req.Header.Set("X-Tenant", "acme")
+	rec := httptest.NewRecorder()
+	gw.ServeHTTP(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	if got := rec.Body.String(); got != `{"id": "42", "name": "Ann", "tenant": "acme", "page": "3"}` {
+		t.Errorf("body = %s", got)
+	}
+	if rec.Header().Get("Content-Type") != "application/json" || rec.Header().Get("X-Request-Method") != "POST" || rec.Header().Get("X-Mocked") != "true" {
+		t.Errorf("headers = %v", rec.Header())
+	}
+
+	// Without a JSON body, fields of .JSON render as null
+	rec = httptest.NewRecorder()
+	gw.ServeHTTP(rec, httptest.NewRequest("GET", "/users/7", nil))
+	if got := rec.Body.String(); got != `{"id": "7", "name": null, "tenant": "", "page": ""}` {
+		t.Errorf("body = %s", got)
+	}
+
+	req = httptest.NewRequest("GET", "/hello", nil)
+	req.Header.Set("X-Name", "nexus")
+	rec = httptest.NewRecorder()
+	gw.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Body.String() != "hello nexus" || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain") {
+		t.Errorf("status %d, body %q, headers %v", rec.Code, rec.Body, rec.Header())
+	}
+}
+
+func TestCompileMock_Invalid(t *testing.T) {
+	if _, err := compileMock(&config.RouteUpstreamMock{Body: "{{.Method"}); err == nil {
+		t.Error("expected error for unparsable body template")
+	}
+	if _, err := compileMock(&config.RouteUpstreamMock{Headers: map[string]string{"X-A": "{{"}}); err == nil {
+		t.Error("expected error for unparsable header template")
+	}
+}
@@ -0,0 +1,63 @@
+package runtime
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
+
+// upstreamOutcomes holds the response counts of the routes that track them,
+// by route and cluster. It lives as long as the process so that the counts
+// survive config reloads.
+var upstreamOutcomes sync.Map // outcomeKey → *UpstreamOutcomes
+
+type outcomeKey struct{ route, cluster string }
+
+// UpstreamOutcomes counts the responses a route got from one cluster.
+type UpstreamOutcomes struct {
+	responses atomic.Uint64
+	errors    atomic.Uint64 // 5xx responses
+}
+
+// Outcomes returns the response counts of route on cluster.
+func Outcomes(route, cluster string) *UpstreamOutcomes {
+	o, _ := upstreamOutcomes.LoadOrStore(outcomeKey{route, cluster}, new(UpstreamOutcomes))
+	return o.(*UpstreamOutcomes)
+}
+
+// Counts returns the number of responses and of 5xx responses so far.
+func (o *UpstreamOutcomes) Counts() (responses, errors uint64) {
+	return o.responses.Load(), o.errors.Load()
+}
+
+func (o *UpstreamOutcomes) record(status int) {
+	o.responses.Add(1)
+	if status >= 500 {
+		o.errors.Add(1)
+	}
+}
+
+// outcomeWriter captures the response status for UpstreamOutcomes.
+type outcomeWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *outcomeWriter) WriteHeader(code int) {
+	if w.status == 0 && code >= 200 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *outcomeWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *outcomeWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
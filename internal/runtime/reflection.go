@@ -0,0 +1,73 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/oriys/nexus/internal/transcode"
+)
+
+// defaultReflectionTimeout bounds reflection discovery when the cluster does
+// not configure reflection_timeout_ms.
+const defaultReflectionTimeout = 5 * time.Second
+
+// newH2Transport returns a transport that only uses HTTP/2: over TLS for
+// https endpoints and with prior knowledge (h2c) for http endpoints.
+func newH2Transport() *http.Transport {
+	var protocols http.Protocols
+	protocols.SetHTTP2(true)
+	protocols.SetUnencryptedHTTP2(true)
+	return &http.Transport{Protocols: &protocols}
+}
+
+// discoverCluster queries the reflection service of a gRPC cluster, adds the
+// returned descriptors to protos, and records the discovered services on the
+// cluster. Endpoints are tried in order until one answers.
+func discoverCluster(cc *CompiledCluster, protos *transcode.Registry) error {
+	timeout := defaultReflectionTimeout
+	if d := cc.GRPC.ReflectionTimeoutDuration(); d > 0 {
+		timeout = d
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	client := &http.Client{Transport: cc.grpcTransport()}
+	var lastErr error
+	for _, ep := range cc.Endpoints {
+		target, err := GRPCTargetURL(EndpointAddress(ep))
+		if err != nil {
+			return err
+		}
+		d, err := transcode.Discover(ctx, client, target.String())
+		if err != nil {
+			lastErr = fmt.Errorf("%s: %w", target, err)
+			continue
+		}
+		if err := protos.AddFiles(d.Files...); err != nil {
+			return err
+		}
+		cc.Services = describeServices(d.Services, protos)
+		return nil
+	}
+	return fmt.Errorf("grpc reflection failed: %w", lastErr)
+}
+
+// describeServices resolves method names for each discovered service.
+func describeServices(names []string, protos *transcode.Registry) []GRPCService {
+	services := make([]GRPCService, 0, len(names))
+	for _, name := range names {
+		svc := GRPCService{Name: name}
+		if sd, err := protos.FindService(name); err == nil {
+			methods := sd.Methods()
+			for i := 0; i < methods.Len(); i++ {
+				svc.Methods = append(svc.Methods, string(methods.Get(i).Name()))
+			}
+			sort.Strings(svc.Methods)
+		}
+		services = append(services, svc)
+	}
+	return services
+}
@@ -0,0 +1,172 @@
+package runtime
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+
+	"github.com/oriys/nexus/internal/config"
+	"github.com/oriys/nexus/internal/transcode"
+)
+
+// newFakeReflectionServer starts an h2c gRPC reflection server that
+// advertises the services in the descriptor set at descPath.
+func newFakeReflectionServer(t *testing.T, descPath string) *httptest.Server {
+	t.Helper()
+	data, err := os.ReadFile(descPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var set descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(data, &set); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/grpc+proto")
+		w.WriteHeader(http.StatusOK)
+		for {
+			req, err := transcode.ReadFrame(r.Body)
+			if err != nil {
+				return
+			}
+			num, _, _ := protowire.ConsumeTag(req)
+
+			var resp []byte
+			if num == 7 { // list_services
+				var list []byte
+				for _, fd := range set.File {
+					for _, svc := range fd.Service {
+						entry := protowire.AppendString(protowire.AppendTag(nil, 1, protowire.BytesType), fd.GetPackage()+"."+svc.GetName())
+						list = protowire.AppendBytes(protowire.AppendTag(list, 1, protowire.BytesType), entry)
+					}
+				}
+				resp = protowire.AppendBytes(protowire.AppendTag(nil, 6, protowire.BytesType), list)
+			} else { // file_containing_symbol
+				var fdr []byte
+				for _, fd := range set.File {
+					b, _ := proto.Marshal(fd)
+					fdr = protowire.AppendBytes(protowire.AppendTag(fdr, 1, protowire.BytesType), b)
+				}
+				resp = protowire.AppendBytes(protowire.AppendTag(nil, 4, protowire.BytesType), fdr)
+			}
+			w.Write(transcode.Frame(resp))
+			w.(http.Flusher).Flush()
+		}
+	}))
+	srv.Config.Protocols = new(http.Protocols)
+	srv.Config.Protocols.SetUnencryptedHTTP2(true)
+	srv.Start()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func reflectionConfig(target, method string) *config.Config {
+	return &config.Config{
+		Clusters: []config.Cluster{
+			{
+				Name:      "user-grpc",
+				Type:      "grpc",
+				Endpoints: []config.ClusterEndpoint{{Target: target}},
+				GRPC:      &config.ClusterGRPC{Reflection: true, ReflectionTimeoutMs: 2000},
+			},
+		},
+		RoutesV2: []config.RouteV2{
+			{
+				Name:  "get-user",
+				Match: config.RouteMatch{Path: "/api/v1/user/get"},
+				Upstream: config.RouteUpstream{
+					Cluster: "user-grpc",
+					GRPC: &config.RouteUpstreamGRPC{
+						Service:  "user.v1.UserService",
+						Method:   method,
+						Request:  &config.TranscodeMode{Mode: "json_to_proto"},
+						Response: &config.TranscodeMode{Mode: "proto_to_json"},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestCompile_GRPCReflection(t *testing.T) {
+	srv := newFakeReflectionServer(t, writeUserDescriptorSet(t))
+	target := "dns:///" + strings.TrimPrefix(srv.URL, "http://")
+
+	compiled, err := Compile(reflectionConfig(target, "GetUser"), 1)
+	if err != nil {
+		t.Fatalf("compile error: %v", err)
+	}
+
+	cluster := compiled.Clusters["user-grpc"]
+	if len(cluster.Services) != 1 || cluster.Services[0].Name != "user.v1.UserService" {
+		t.Fatalf("expected discovered user.v1.UserService, got %+v", cluster.Services)
+	}
+	if len(cluster.Services[0].Methods) != 2 || cluster.Services[0].Methods[0] != "GetUser" {
+		t.Errorf("expected [GetUser SyncUsers], got %v", cluster.Services[0].Methods)
+	}
+
+	// Descriptors from reflection are enough to transcode without
+	// proto_descriptors in the config.
+	route, _ := compiled.Router.Match(httptest.NewRequest("POST", "/api/v1/user/get", nil))
+	if route == nil || route.GRPCTranscode == nil || route.GRPCTranscode.Request == nil {
+		t.Fatal("expected transcoding route resolved from reflection")
+	}
+}
+
+func TestCompile_GRPCReflectionUnknownMethod(t *testing.T) {
+	srv := newFakeReflectionServer(t, writeUserDescriptorSet(t))
+	cfg := reflectionConfig(srv.URL, "DeleteUser")
+	cfg.RoutesV2[0].Upstream.GRPC.Request = nil
+	cfg.RoutesV2[0].Upstream.GRPC.Response = nil
+
+	_, err := Compile(cfg, 1)
+	if err == nil {
+		t.Fatal("expected error for method not served by backend")
+	}
+	if !strings.Contains(err.Error(), "DeleteUser") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestCompile_GRPCReflectionUnreachable(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	addr := srv.URL
+	srv.Close()
+
+	if _, err := Compile(reflectionConfig(addr, "GetUser"), 1); err == nil {
+		t.Fatal("expected error when reflection endpoint is unreachable")
+	}
+}
+
+func TestGRPCTargetURL(t *testing.T) {
+	tests := []struct {
+		addr string
+		want string
+	}{
+		{"dns:///user-grpc:9090", "http://user-grpc:9090"},
+		{"user-grpc:9090", "http://user-grpc:9090"},
+		{"https://user-grpc:443", "https://user-grpc:443"},
+		{"http://127.0.0.1:50051", "http://127.0.0.1:50051"},
+	}
+	for _, tt := range tests {
+		u, err := GRPCTargetURL(tt.addr)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.addr, err)
+			continue
+		}
+		if u.String() != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.addr, tt.want, u)
+		}
+	}
+
+	if _, err := GRPCTargetURL("dns:///"); err == nil {
+		t.Error("expected error for empty target")
+	}
+}
@@ -0,0 +1,289 @@
+package runtime
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/oriys/nexus/internal/config"
+)
+
+// ResponseFilter is a filter that rewrites the upstream response. Its Apply
+// leaves the request untouched; ApplyResponse runs from the reverse proxy's
+// ModifyResponse hook before the response is written to the client.
+type ResponseFilter interface {
+	Filter
+	ApplyResponse(resp *http.Response) error
+}
+
+// responseFilters returns the filters of filters that rewrite responses.
+func responseFilters(filters []Filter) []ResponseFilter {
+	var out []ResponseFilter
+	for _, f := range filters {
+		if rf, ok := f.(ResponseFilter); ok {
+			out = append(out, rf)
+		}
+	}
+	return out
+}
+
+// modifyResponse returns the ModifyResponse hook applying the response
+// filters of route, or nil when it has none.
+func modifyResponse(route *CompiledRoute) func(*http.Response) error {
+	if len(route.ResponseFilters) == 0 {
+		return nil
+	}
+	return func(resp *http.Response) error { return applyResponseFilters(route, resp) }
+}
+
+// applyResponseFilters runs the response filters of route on resp.
+func applyResponseFilters(route *CompiledRoute, resp *http.Response) error {
+	for _, f := range route.ResponseFilters {
+		if err := f.ApplyResponse(resp); err != nil {
+			return fmt.Errorf("route %q response filter: %w", route.Name, err)
+		}
+	}
+	return nil
+}
+
+// responseHeaderFilter adds, sets or removes a response header.
+type responseHeaderFilter struct {
+	op    string
+	key   string
+	value string
+}
+
+func newResponseHeaderAddFilter(args config.FilterArgs) (Filter, error) {
+	return newResponseHeaderFilter("add", args)
+}
+
+func newResponseHeaderSetFilter(args config.FilterArgs) (Filter, error) {
+	return newResponseHeaderFilter("set", args)
+}
+
+func newResponseHeaderRemoveFilter(args config.FilterArgs) (Filter, error) {
+	return newResponseHeaderFilter("remove", args)
+}
+
+func newResponseHeaderFilter(op string, args config.FilterArgs) (Filter, error) {
+	known := []string{"value"}
+	if op == "remove" {
+		known = nil
+	}
+	key, err := requiredArg(args, "key", known...)
+	if err != nil {
+		return nil, fmt.Errorf("response_header_%s filter: %w", op, err)
+	}
+	value, err := args.String("value")
+	if err != nil {
+		return nil, fmt.Errorf("response_header_%s filter: %w", op, err)
+	}
+	return &responseHeaderFilter{op: op, key: key, value: value}, nil
+}
+
+func (f *responseHeaderFilter) Apply(r *http.Request) error { return nil }
+
+func (f *responseHeaderFilter) ApplyResponse(resp *http.Response) error {
+	switch f.op {
+	case "add":
+		resp.Header.Add(f.key, f.value)
+	case "set":
+		resp.Header.Set(f.key, f.value)
+	default:
+		resp.Header.Del(f.key)
+	}
+	return nil
+}
+
+// responseStatusFilter rewrites the response status, optionally only when
+// the upstream answered with a given status.
+type responseStatusFilter struct {
+	from   int // 0 rewrites every status
+	status int
+}
+
+func newResponseStatusFilter(args config.FilterArgs) (Filter, error) {
+	if err := args.Check("status", "from"); err != nil {
+		return nil, fmt.Errorf("response_status filter: %w", err)
+	}
+	if !args.Has("status") {
+		return nil, fmt.Errorf("response_status filter: 'status' argument is required")
+	}
+	status, err := statusArg(args, "status")
+	if err != nil {
+		return nil, fmt.Errorf("response_status filter: %w", err)
+	}
+	from, err := statusArg(args, "from")
+	if err != nil {
+		return nil, fmt.Errorf("response_status filter: %w", err)
+	}
+	return &responseStatusFilter{from: from, status: status}, nil
+}
+
+// statusArg returns the argument name as an HTTP status code between 100
+// and 599; an absent argument is zero.
+func statusArg(args config.FilterArgs, name string) (int, error) {
+	code, err := args.Int(name, 0)
+	if err != nil {
+		return 0, err
+	}
+	if args.Has(name) && (code < 100 || code > 599) {
+		return 0, fmt.Errorf("'%s' argument: invalid status %d", name, code)
+	}
+	return code, nil
+}
+
+func (f *responseStatusFilter) Apply(r *http.Request) error { return nil }
+
+func (f *responseStatusFilter) ApplyResponse(resp *http.Response) error {
+	if f.from != 0 && resp.StatusCode != f.from {
+		return nil
+	}
+	resp.StatusCode = f.status
+	resp.Status = fmt.Sprintf("%d %s", f.status, http.StatusText(f.status))
+	return nil
+}
+
+// responseBodyReplaceFilter replaces every occurrence of a string in the
+// response body.
+type responseBodyReplaceFilter struct {
+	find    []byte
+	replace []byte
+}
+
+func newResponseBodyReplaceFilter(args config.FilterArgs) (Filter, error) {
+	find, err := requiredArg(args, "find", "replace")
+	if err != nil {
+		return nil, fmt.Errorf("response_body_replace filter: %w", err)
+	}
+	replace, err := args.String("replace")
+	if err != nil {
+		return nil, fmt.Errorf("response_body_replace filter: %w", err)
+	}
+	return &responseBodyReplaceFilter{find: []byte(find), replace: []byte(replace)}, nil
+}
+
+func (f *responseBodyReplaceFilter) Apply(r *http.Request) error { return nil }
+
+func (f *responseBodyReplaceFilter) ApplyResponse(resp *http.Response) error {
+	if !rewritableBody(resp) {
+		return nil
+	}
+	body, err := readResponseBody(resp)
+	if err != nil {
+		return err
+	}
+	setResponseBody(resp, bytes.ReplaceAll(body, f.find, f.replace))
+	return nil
+}
+
+// responseJSONFieldsFilter projects a JSON response body onto a set of
+// fields. Nested fields are written as dotted paths, e.g. "user.name"; an
+// array body is projected element by element.
+type responseJSONFieldsFilter struct {
+	fields [][]string
+}
+
+func newResponseJSONFieldsFilter(args config.FilterArgs) (Filter, error) {
+	if err := args.Check("fields"); err != nil {
+		return nil, fmt.Errorf("response_json_fields filter: %w", err)
+	}
+	list, err := args.Strings("fields")
+	if err != nil {
+		return nil, fmt.Errorf("response_json_fields filter: %w", err)
+	}
+	if len(list) == 0 {
+		return nil, fmt.Errorf("response_json_fields filter: 'fields' argument is required")
+	}
+	fields := make([][]string, len(list))
+	for i, field := range list {
+		fields[i] = strings.Split(field, ".")
+	}
+	return &responseJSONFieldsFilter{fields: fields}, nil
+}
+
+func (f *responseJSONFieldsFilter) Apply(r *http.Request) error { return nil }
+
+func (f *responseJSONFieldsFilter) ApplyResponse(resp *http.Response) error {
+	if !rewritableBody(resp) {
+		return nil
+	}
+	if !isJSONContent(resp.Header) {
+		return nil
+	}
+	body, err := readResponseBody(resp)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		// Not valid JSON: forward the body unchanged
+		setResponseBody(resp, body)
+		return nil
+	}
+	out, err := json.Marshal(projectJSON(v, f.fields))
+	if err != nil {
+		return err
+	}
+	setResponseBody(resp, out)
+	return nil
+}
+
+// projectJSON keeps the fields of v named by fields.
+func projectJSON(v any, fields [][]string) any {
+	switch t := v.(type) {
+	case []any:
+		out := make([]any, len(t))
+		for i, e := range t {
+			out[i] = projectJSON(e, fields)
+		}
+		return out
+	case map[string]any:
+		whole := make(map[string]bool)
+		nested := make(map[string][][]string)
+		for _, f := range fields {
+			if len(f) == 1 {
+				whole[f[0]] = true
+			} else {
+				nested[f[0]] = append(nested[f[0]], f[1:])
+			}
+		}
+		out := make(map[string]any)
+		for k := range whole {
+			if e, ok := t[k]; ok {
+				out[k] = e
+			}
+		}
+		for k, sub := range nested {
+			if e, ok := t[k]; ok && !whole[k] {
+				out[k] = projectJSON(e, sub)
+			}
+		}
+		return out
+	}
+	return v
+}
+
+// rewritableBody reports whether a filter may rewrite the body of resp.
+// Encoded bodies are left alone, as are responses without one.
+func rewritableBody(resp *http.Response) bool {
+	if resp.Body == nil || resp.Body == http.NoBody {
+		return false
+	}
+	ce := resp.Header.Get("Content-Encoding")
+	return ce == "" || strings.EqualFold(ce, "identity")
+}
+
+func readResponseBody(resp *http.Response) ([]byte, error) {
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("read response body: %w", err)
+	}
+	return body, nil
+}
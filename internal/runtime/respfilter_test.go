@@ -0,0 +1,171 @@
+package runtime
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/oriys/nexus/internal/config"
+)
+
+func newTestResponse(status int, contentType, body string) *http.Response {
+	resp := &http.Response{
+		StatusCode:    status,
+		Header:        http.Header{},
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+	}
+	if contentType != "" {
+		resp.Header.Set("Content-Type", contentType)
+	}
+	return resp
+}
+
+func applyResponseFilter(t *testing.T, rf config.RouteFilter, resp *http.Response) string {
+	t.Helper()
+	f, err := NewFilterRegistry().Compile(rf)
+	if err != nil {
+		t.Fatalf("compile %s: %v", rf.Type, err)
+	}
+	if err := f.(ResponseFilter).ApplyResponse(resp); err != nil {
+		t.Fatalf("apply %s: %v", rf.Type, err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	return string(body)
+}
+
+func TestResponseHeaderFilters(t *testing.T) {
+	resp := newTestResponse(200, "text/plain", "")
+	resp.Header.Set("Server", "backend")
+	resp.Header.Set("X-Tag", "a")
+
+	applyResponseFilter(t, config.RouteFilter{Type: "response_header_add", Args: config.FilterArgs{"key": "X-Tag", "value": "b"}}, resp)
+	applyResponseFilter(t, config.RouteFilter{Type: "response_header_set", Args: config.FilterArgs{"key": "X-Served-By", "value": "nexus"}}, resp)
+	applyResponseFilter(t, config.RouteFilter{Type: "response_header_remove", Args: config.FilterArgs{"key": "Server"}}, resp)
+
+	if got := resp.Header.Values("X-Tag"); len(got) != 2 || got[1] != "b" {
+		t.Errorf("X-Tag = %v", got)
+	}
+	if got := resp.Header.Get("X-Served-By"); got != "nexus" {
+		t.Errorf("X-Served-By = %q", got)
+	}
+	if _, ok := resp.Header["Server"]; ok {
+		t.Error("Server header was not removed")
+	}
+}
+
+func TestResponseStatusFilter(t *testing.T) {
+	rf := config.RouteFilter{Type: "response_status", Args: config.FilterArgs{"from": "404", "status": "204"}}
+
+	resp := newTestResponse(404, "", "")
+	applyResponseFilter(t, rf, resp)
+	if resp.StatusCode != 204 || resp.Status != "204 No Content" {
+		t.Errorf("status = %d %q", resp.StatusCode, resp.Status)
+	}
+
+	resp = newTestResponse(500, "", "")
+	applyResponseFilter(t, rf, resp)
+	if resp.StatusCode != 500 {
+		t.Errorf("unmatched status rewritten to %d", resp.StatusCode)
+	}
+
+	if _, err := newResponseStatusFilter(config.FilterArgs{"status": "99"}); err == nil {
+		t.Error("expected error for invalid status")
+	}
+}
+
+func TestResponseBodyReplaceFilter(t *testing.T) {
+	rf := config.RouteFilter{Type: "response_body_replace", Args: config.FilterArgs{"find": "internal.local", "replace": "api.example.com"}}
+
+	resp := newTestResponse(200, "text/html", `<a href="http://internal.local/a">internal.local</a>`)
+	body := applyResponseFilter(t, rf, resp)
+	want := `<a href="http://api.example.com/a">api.example.com</a>`
+	if body != want {
+		t.Errorf("body = %q", body)
+	}
+	if resp.ContentLength != int64(len(want)) || resp.Header.Get("Content-Length") != strconv.Itoa(len(want)) {
+		t.Errorf("content length = %d / %q", resp.ContentLength, resp.Header.Get("Content-Length"))
+	}
+
+	// Compressed bodies are forwarded untouched
+	resp = newTestResponse(200, "text/html", "internal.local")
+	resp.Header.Set("Content-Encoding", "gzip")
+	if body := applyResponseFilter(t, rf, resp); body != "internal.local" {
+		t.Errorf("encoded body rewritten: %q", body)
+	}
+}
+
+func TestResponseJSONFieldsFilter(t *testing.T) {
+	rf := config.RouteFilter{Type: "response_json_fields", Args: config.FilterArgs{"fields": "id, user.name, missing"}}
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        string
+	}{
+		{"object", "application/json", `{"id":1,"secret":"x","user":{"name":"ann","email":"a@x"}}`, `{"id":1,"user":{"name":"ann"}}`},
+		{"array", "application/json; charset=utf-8", `[{"id":1,"n":2},{"id":12345678901234567890}]`, `[{"id":1},{"id":12345678901234567890}]`},
+		{"problem json", "application/problem+json", `{"id":"a","title":"t"}`, `{"id":"a"}`},
+		{"not json", "text/plain", `{"id":1,"secret":"x"}`, `{"id":1,"secret":"x"}`},
+		{"invalid json", "application/json", `{"id":`, `{"id":`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := applyResponseFilter(t, rf, newTestResponse(200, tt.contentType, tt.body)); got != tt.want {
+				t.Errorf("body = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGateway_ResponseFilters(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Powered-By", "backend")
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, `{"id":7,"password":"hunter2"}`)
+	}))
+	defer backend.Close()
+
+	cfg := &config.Config{
+		Clusters: []config.Cluster{
+			{Name: "backend", Type: "http", Endpoints: []config.ClusterEndpoint{{URL: backend.URL}}},
+		},
+		RoutesV2: []config.RouteV2{{
+			Name:  "users",
+			Match: config.RouteMatch{PathPrefix: "/users"},
+			Filters: []config.RouteFilter{
+				{Type: "header_set", Args: config.FilterArgs{"key": "X-Gateway", "value": "nexus"}},
+				{Type: "response_header_remove", Args: config.FilterArgs{"key": "X-Powered-By"}},
+				{Type: "response_status", Args: config.FilterArgs{"from": "201", "status": "200"}},
+				{Type: "response_json_fields", Args: config.FilterArgs{"fields": "id"}},
+			},
+			Upstream: config.RouteUpstream{Cluster: "backend"},
+		}},
+	}
+	compiled, err := Compile(cfg, 1)
+	if err != nil {
+		t.Fatalf("compile error: %v", err)
+	}
+	store := NewConfigStore()
+	store.Store(compiled)
+
+	rec := httptest.NewRecorder()
+	NewGateway(store).ServeHTTP(rec, httptest.NewRequest("GET", "/users/7", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	if rec.Body.String() != `{"id":7}` {
+		t.Errorf("body = %s", rec.Body)
+	}
+	if rec.Header().Get("X-Powered-By") != "" {
+		t.Error("X-Powered-By was not removed")
+	}
+	if rec.Header().Get("Content-Length") != "8" {
+		t.Errorf("Content-Length = %q", rec.Header().Get("Content-Length"))
+	}
+}
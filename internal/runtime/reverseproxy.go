@@ -0,0 +1,141 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"time"
+
+	"github.com/oriys/nexus/internal/httperror"
+	"github.com/oriys/nexus/internal/reqctx"
+	"github.com/oriys/nexus/internal/transcode"
+)
+
+// proxyCall is the state of one proxied request. The reverse proxies are
+// shared by all requests and read it from the request context, so that
+// proxying a request allocates no proxy of its own.
+type proxyCall struct {
+	route   *CompiledRoute
+	cluster *CompiledCluster
+	target  *url.URL
+	addr    string
+	// host is the Host header sent upstream; empty sends the target's.
+	host string
+	// grpcClient is set when a native gRPC client made the request.
+	grpcClient bool
+}
+
+type proxyCallKey struct{}
+
+// serve proxies r as c with p.
+func (c *proxyCall) serve(p *httputil.ReverseProxy, w http.ResponseWriter, r *http.Request) {
+	reqctx.Upstream.Set(r.Context(), c.addr)
+	p.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), proxyCallKey{}, c)))
+}
+
+func proxyCallOf(r *http.Request) *proxyCall {
+	c, _ := r.Context().Value(proxyCallKey{}).(*proxyCall)
+	return c
+}
+
+// clusterTransport sends a proxied request through the pooled transport of
+// its cluster.
+type clusterTransport struct{}
+
+func (clusterTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return proxyCallOf(req).cluster.roundTripper().RoundTrip(req)
+}
+
+// The shared reverse proxies of the upstream handlers.
+var (
+	httpProxy      = newUpstreamProxy("proxy error", 0)
+	httpFlushProxy = newUpstreamProxy("proxy error", 100*time.Millisecond)
+	dubboProxy     = newUpstreamProxy("dubbo proxy error", 0)
+	graphqlProxy   = newUpstreamProxy("graphql proxy error", 0)
+	grpcProxy      = &httputil.ReverseProxy{
+		Transport:      clusterTransport{},
+		Rewrite:        rewriteGRPC,
+		ModifyResponse: modifyGRPCResponse,
+		ErrorHandler:   grpcProxyError,
+	}
+)
+
+// newUpstreamProxy returns a reverse proxy applying the response filters of
+// the route, which logs failures as message and answers them with 502.
+func newUpstreamProxy(message string, flush time.Duration) *httputil.ReverseProxy {
+	return &httputil.ReverseProxy{
+		Transport: clusterTransport{},
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			c := proxyCallOf(pr.In)
+			pr.SetURL(c.target)
+			if c.host != "" {
+				pr.Out.Host = c.host
+			}
+			signOutbound(c.route, pr.Out)
+		},
+		ModifyResponse: func(resp *http.Response) error {
+			return applyResponseFilters(proxyCallOf(resp.Request).route, resp)
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			c := proxyCallOf(r)
+			slog.Error(message,
+				slog.String("cluster", c.cluster.Name),
+				slog.String("target", c.addr),
+				slog.String("error", err.Error()),
+			)
+			httperror.Write(w, r, http.StatusBadGateway, httperror.CodeBadGateway, "bad gateway")
+		},
+		FlushInterval: flush,
+	}
+}
+
+func rewriteGRPC(pr *httputil.ProxyRequest) {
+	c := proxyCallOf(pr.In)
+	pr.SetURL(c.target)
+	if g := c.cluster.GRPC; g != nil && g.Authority != "" {
+		pr.Out.Host = g.Authority
+	}
+	filterGRPCMetadata(pr.Out.Header, c.route.GRPCMetadata)
+	signOutbound(c.route, pr.Out)
+}
+
+func modifyGRPCResponse(resp *http.Response) error {
+	c := proxyCallOf(resp.Request)
+	if g := c.cluster.GRPC; g != nil && g.MaxRecvMsgLimit() > 0 {
+		resp.Body = transcode.LimitFrames(resp.Body, int(g.MaxRecvMsgLimit()))
+	}
+	if c.grpcClient || resp.StatusCode != http.StatusOK {
+		return nil
+	}
+	var codec *transcode.Codec
+	tc := c.route.GRPCTranscode
+	if tc != nil {
+		codec = tc.Response
+	}
+	if tc != nil && tc.ServerStreaming && codec != nil {
+		streamGRPCResponse(resp, codec)
+		return nil
+	}
+	return finishGRPCResponse(resp, codec)
+}
+
+func grpcProxyError(w http.ResponseWriter, r *http.Request, err error) {
+	c := proxyCallOf(r)
+	slog.Error("grpc proxy error",
+		slog.String("cluster", c.cluster.Name),
+		slog.String("target", c.addr),
+		slog.String("error", err.Error()),
+	)
+	if errors.Is(err, context.DeadlineExceeded) {
+		writeGRPCProxyError(w, GRPCStatus{Code: 4, Message: "deadline exceeded"}, c.grpcClient)
+		return
+	}
+	if errors.Is(err, transcode.ErrMessageTooLarge) {
+		writeGRPCProxyError(w, GRPCStatus{Code: 8, Message: err.Error()}, c.grpcClient)
+		return
+	}
+	httperror.Write(w, r, http.StatusBadGateway, httperror.CodeBadGateway, "bad gateway")
+}
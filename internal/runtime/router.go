@@ -0,0 +1,565 @@
+package runtime
+
+import (
+	"net"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/oriys/nexus/internal/config"
+	"github.com/oriys/nexus/internal/pathmatch"
+	"github.com/oriys/nexus/internal/prefixtree"
+)
+
+// RouterIndex provides O(1)/O(logN) route matching. Routes are grouped by
+// host: the routes of the request host are tried first, then those of the
+// matching wildcard hosts, longest suffix first, then the routes matching
+// any host.
+type RouterIndex struct {
+	// mergeSlashes collapses runs of slashes in request paths; see
+	// Normalize.
+	mergeSlashes bool
+	// hosts maps an exact host to its routes.
+	hosts map[string]*routeTable
+	// wildcards holds the routes of "*.example.com" hosts, sorted by suffix
+	// length (longest first).
+	wildcards []*wildcardTable
+	// any holds the routes without a host criterion.
+	any *routeTable
+}
+
+type wildcardTable struct {
+	host   string // as configured, e.g. "*.example.com"
+	suffix string // e.g. ".example.com"
+	table  *routeTable
+}
+
+// routeTable indexes the routes of one host by path.
+type routeTable struct {
+	// exactRoutes maps "METHOD|path" → routes for O(1) exact lookups. Routes
+	// with a higher priority, then those matching GraphQL operations, come
+	// first.
+	exactRoutes map[string][]*CompiledRoute
+	// methodRoutes maps a path to the exact routes restricted to methods,
+	// to find the methods allowed on it.
+	methodRoutes map[string][]*CompiledRoute
+	// norms lists the distinct path normalizations of the exact routes,
+	// each giving a form of the request path to look up.
+	norms []pathNorm
+	// patternRoutes holds template and regex routes by priority, then in
+	// configuration order. They are tried after exact routes and before
+	// prefix routes.
+	patternRoutes []*patternRouteEntry
+	// prefixRoutes is sorted by priority, then by prefix length (longest
+	// first) for longest-prefix matching.
+	prefixRoutes []*prefixRouteEntry
+	// prefixLevels indexes prefixRoutes in a prefix tree per priority,
+	// highest first, so matching does not scan every prefix.
+	prefixLevels []prefixLevel
+}
+
+type prefixLevel struct {
+	priority int
+	tree     prefixtree.Tree[*CompiledRoute]
+	// folded holds the case-insensitive prefixes, lower-cased.
+	folded prefixtree.Tree[*CompiledRoute]
+}
+
+type prefixRouteEntry struct {
+	prefix string
+	route  *CompiledRoute
+}
+
+type patternRouteEntry struct {
+	kind   string // "template" or "regex"
+	source string // the template or regex as configured
+	route  *CompiledRoute
+}
+
+// pathNorm is the path normalization of a route, resolved at compile time.
+type pathNorm struct {
+	strip bool // exact paths and templates ignore trailing slashes
+	fold  bool // paths match regardless of case
+}
+
+func newPathNorm(n config.PathNormalization) pathNorm {
+	return pathNorm{strip: n.StripTrailingSlash, fold: n.CaseInsensitive}
+}
+
+// exact returns path as compared with exact paths.
+func (n pathNorm) exact(path string) string {
+	if n.strip {
+		path = pathmatch.StripTrailingSlash(path)
+	}
+	if n.fold {
+		path = strings.ToLower(path)
+	}
+	return path
+}
+
+// prefix returns path as compared with prefixes.
+func (n pathNorm) prefix(path string) string {
+	if n.fold {
+		path = strings.ToLower(path)
+	}
+	return path
+}
+
+// pattern returns path as matched against templates and regexes, which
+// ignore case themselves when folding.
+func (n pathNorm) pattern(path string) string {
+	if n.strip {
+		path = pathmatch.StripTrailingSlash(path)
+	}
+	return path
+}
+
+// newRouterIndex returns an empty index for path_normalization n.
+func newRouterIndex(n *config.PathNormalization) *RouterIndex {
+	return &RouterIndex{
+		mergeSlashes: n != nil && n.MergeSlashes,
+		hosts:        make(map[string]*routeTable),
+		any:          newRouteTable(),
+	}
+}
+
+func newRouteTable() *routeTable {
+	return &routeTable{
+		exactRoutes:  make(map[string][]*CompiledRoute),
+		methodRoutes: make(map[string][]*CompiledRoute),
+	}
+}
+
+// add indexes cr, compiled from rv2, under each of its hosts.
+func (ri *RouterIndex) add(cr *CompiledRoute, rv2 *config.RouteV2) {
+	hosts := rv2.Match.HostNames()
+	if len(hosts) == 0 {
+		ri.any.add(cr, rv2)
+		return
+	}
+	for _, host := range hosts {
+		if suffix, ok := strings.CutPrefix(host, "*"); ok {
+			ri.wildcard(host, suffix).add(cr, rv2)
+			continue
+		}
+		t, ok := ri.hosts[host]
+		if !ok {
+			t = newRouteTable()
+			ri.hosts[host] = t
+		}
+		t.add(cr, rv2)
+	}
+}
+
+// wildcard returns the table of wildcard host, creating it if needed.
+func (ri *RouterIndex) wildcard(host, suffix string) *routeTable {
+	for _, w := range ri.wildcards {
+		if w.host == host {
+			return w.table
+		}
+	}
+	w := &wildcardTable{host: host, suffix: suffix, table: newRouteTable()}
+	ri.wildcards = append(ri.wildcards, w)
+	return w.table
+}
+
+// sort orders the tables for matching once all routes are added.
+func (ri *RouterIndex) sort() {
+	for _, t := range ri.hosts {
+		t.sort()
+	}
+	sort.SliceStable(ri.wildcards, func(i, j int) bool {
+		if len(ri.wildcards[i].suffix) != len(ri.wildcards[j].suffix) {
+			return len(ri.wildcards[i].suffix) > len(ri.wildcards[j].suffix)
+		}
+		return ri.wildcards[i].suffix < ri.wildcards[j].suffix
+	})
+	for _, w := range ri.wildcards {
+		w.table.sort()
+	}
+	ri.any.sort()
+}
+
+func (t *routeTable) add(cr *CompiledRoute, rv2 *config.RouteV2) {
+	cm := &cr.Match
+	if cm.Pattern != nil {
+		// Template and regex routes are tried by priority, then in
+		// configuration order
+		pe := &patternRouteEntry{route: cr}
+		switch {
+		case rv2.Match.PathRegex != "":
+			pe.kind, pe.source = "regex", rv2.Match.PathRegex
+		case rv2.Match.Path != "":
+			pe.kind, pe.source = "template", rv2.Match.Path
+		default:
+			pe.kind, pe.source = "template", rv2.Match.PathPrefix
+		}
+		t.patternRoutes = append(t.patternRoutes, pe)
+		return
+	}
+
+	if cm.Path != "" {
+		if !slices.Contains(t.norms, cm.norm) {
+			t.norms = append(t.norms, cm.norm)
+		}
+		// Exact path routes go into the exact map
+		if cm.Methods != nil {
+			for m := range cm.Methods {
+				key := m + "|" + cm.Path
+				t.exactRoutes[key] = append(t.exactRoutes[key], cr)
+			}
+			t.methodRoutes[cm.Path] = append(t.methodRoutes[cm.Path], cr)
+		} else {
+			key := "|" + cm.Path
+			t.exactRoutes[key] = append(t.exactRoutes[key], cr)
+		}
+	}
+
+	if cm.PathPrefix != "" {
+		// Prefix routes go into the prefix list
+		t.prefixRoutes = append(t.prefixRoutes, &prefixRouteEntry{
+			prefix: cm.PathPrefix,
+			route:  cr,
+		})
+	}
+
+	// If neither path nor prefix is set, this is a catch-all (treated as "/" prefix)
+	if cm.Path == "" && cm.PathPrefix == "" {
+		t.prefixRoutes = append(t.prefixRoutes, &prefixRouteEntry{
+			prefix: "/",
+			route:  cr,
+		})
+	}
+}
+
+func (t *routeTable) sort() {
+	// Routes with a higher priority come first. Among equal priorities,
+	// routes matching GraphQL operations are tried before the other routes
+	// on the same path, in configuration order.
+	for _, routes := range t.exactRoutes {
+		sort.SliceStable(routes, func(i, j int) bool {
+			return routeBefore(routes[i], routes[j])
+		})
+	}
+	patternRoutes := t.patternRoutes
+	sort.SliceStable(patternRoutes, func(i, j int) bool {
+		return patternRoutes[i].route.Priority > patternRoutes[j].route.Priority
+	})
+
+	// Sort prefix routes by priority, then by length descending (longest
+	// match first). Use lexicographic ordering as tiebreaker for
+	// deterministic matching.
+	prefixRoutes := t.prefixRoutes
+	sort.SliceStable(prefixRoutes, func(i, j int) bool {
+		if prefixRoutes[i].route.Priority != prefixRoutes[j].route.Priority {
+			return prefixRoutes[i].route.Priority > prefixRoutes[j].route.Priority
+		}
+		if len(prefixRoutes[i].prefix) != len(prefixRoutes[j].prefix) {
+			return len(prefixRoutes[i].prefix) > len(prefixRoutes[j].prefix)
+		}
+		if prefixRoutes[i].prefix != prefixRoutes[j].prefix {
+			return prefixRoutes[i].prefix < prefixRoutes[j].prefix
+		}
+		return routeBefore(prefixRoutes[i].route, prefixRoutes[j].route)
+	})
+
+	t.prefixLevels = nil
+	for _, pe := range prefixRoutes {
+		n := len(t.prefixLevels)
+		if n == 0 || t.prefixLevels[n-1].priority != pe.route.Priority {
+			t.prefixLevels = append(t.prefixLevels, prefixLevel{priority: pe.route.Priority})
+			n++
+		}
+		if pe.route.Match.norm.fold {
+			t.prefixLevels[n-1].folded.Insert(pe.prefix, pe.route)
+		} else {
+			t.prefixLevels[n-1].tree.Insert(pe.prefix, pe.route)
+		}
+	}
+}
+
+// routeBefore orders routes on the same path: higher priority first, then
+// those matching GraphQL operations.
+func routeBefore(a, b *CompiledRoute) bool {
+	if a.Priority != b.Priority {
+		return a.Priority > b.Priority
+	}
+	return a.Match.GraphQL != nil && b.Match.GraphQL == nil
+}
+
+// RouteIndexEntry is one entry of a RouterIndex.
+type RouteIndexEntry struct {
+	// Host is the exact or wildcard host of the entry; empty for routes
+	// matching any host.
+	Host string
+	Kind string // "exact", "template", "regex" or "prefix"
+	// Method is the method an exact entry is keyed by; empty for routes
+	// matching any method and for the other entries.
+	Method string
+	Path   string // the exact path, template, regex or prefix
+	Route  *CompiledRoute
+}
+
+// Entries returns the contents of the index grouped by host: exact hosts by
+// name, then wildcard hosts longest suffix first, then the routes matching
+// any host. Within a host, entries are in the order Match tries them: exact
+// entries by path, those keyed by a method before those matching any
+// method, then template and regex entries, then prefix entries, each by
+// priority first.
+func (ri *RouterIndex) Entries() []RouteIndexEntry {
+	if ri == nil {
+		return nil
+	}
+	hosts := make([]string, 0, len(ri.hosts))
+	for host := range ri.hosts {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	var entries []RouteIndexEntry
+	for _, host := range hosts {
+		entries = ri.hosts[host].appendEntries(entries, host)
+	}
+	for _, w := range ri.wildcards {
+		entries = w.table.appendEntries(entries, w.host)
+	}
+	return ri.any.appendEntries(entries, "")
+}
+
+func (t *routeTable) appendEntries(entries []RouteIndexEntry, host string) []RouteIndexEntry {
+	keys := make([]string, 0, len(t.exactRoutes))
+	for key := range t.exactRoutes {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		mi, pi, _ := strings.Cut(keys[i], "|")
+		mj, pj, _ := strings.Cut(keys[j], "|")
+		if pi != pj {
+			return pi < pj
+		}
+		if (mi == "") != (mj == "") {
+			return mj == ""
+		}
+		return mi < mj
+	})
+	for _, key := range keys {
+		method, path, _ := strings.Cut(key, "|")
+		for _, route := range t.exactRoutes[key] {
+			entries = append(entries, RouteIndexEntry{Host: host, Kind: "exact", Method: method, Path: path, Route: route})
+		}
+	}
+	for _, pe := range t.patternRoutes {
+		entries = append(entries, RouteIndexEntry{Host: host, Kind: pe.kind, Path: pe.source, Route: pe.route})
+	}
+	for _, pe := range t.prefixRoutes {
+		entries = append(entries, RouteIndexEntry{Host: host, Kind: "prefix", Path: pe.prefix, Route: pe.route})
+	}
+	return entries
+}
+
+// Match finds the best matching route for the request.
+func (ri *RouterIndex) Match(r *http.Request) (*CompiledRoute, bool) {
+	route, _, ok := ri.MatchParams(r)
+	return route, ok
+}
+
+// MatchParams is like Match, and also returns the path parameters captured
+// by a template or regex route.
+func (ri *RouterIndex) MatchParams(r *http.Request) (*CompiledRoute, map[string]string, bool) {
+	if ri == nil {
+		return nil, nil, false
+	}
+
+	var op requestOperation
+	host := requestHost(r)
+
+	if t, ok := ri.hosts[host]; ok {
+		if route, params, ok := t.match(r, &op); ok {
+			return route, params, true
+		}
+	}
+	for _, w := range ri.wildcards {
+		if strings.HasSuffix(host, w.suffix) && len(host) > len(w.suffix) {
+			if route, params, ok := w.table.match(r, &op); ok {
+				return route, params, true
+			}
+		}
+	}
+	return ri.any.match(r, &op)
+}
+
+func (t *routeTable) match(r *http.Request, op *requestOperation) (*CompiledRoute, map[string]string, bool) {
+	// Try exact match first, for each normalized form of the path
+	var matched *CompiledRoute
+	for _, norm := range t.norms {
+		path := norm.exact(r.URL.Path)
+		// "METHOD|path", then without method for wildcard method routes
+		route := firstMatch(t.exactRoutes[r.Method+"|"+path], r, op)
+		if route == nil {
+			route = firstMatch(t.exactRoutes["|"+path], r, op)
+		}
+		if route != nil && (matched == nil || routeBefore(route, matched)) {
+			matched = route
+		}
+	}
+	if matched != nil {
+		return matched, nil, true
+	}
+
+	// Try templates and regexes in configuration order
+	for _, pe := range t.patternRoutes {
+		if _, params, ok := pe.route.Match.Pattern.Match(pe.route.Match.norm.pattern(r.URL.Path)); ok {
+			if pe.route.Match.matches(r, op) {
+				return pe.route, params, true
+			}
+		}
+	}
+
+	// Try prefix match (highest priority, then longest prefix wins)
+	for i := range t.prefixLevels {
+		if route := t.prefixLevels[i].match(r, op); route != nil {
+			return route, nil, true
+		}
+	}
+
+	return nil, nil, false
+}
+
+// firstMatch returns the first of routes matching r.
+func firstMatch(routes []*CompiledRoute, r *http.Request, op *requestOperation) *CompiledRoute {
+	for _, route := range routes {
+		if route.Match.matches(r, op) {
+			return route
+		}
+	}
+	return nil
+}
+
+// match returns the route of the longest prefix of the path of r matching
+// r. Case-sensitive prefixes win over case-insensitive ones of the same
+// length.
+func (l *prefixLevel) match(r *http.Request, op *requestOperation) *CompiledRoute {
+	var matched *CompiledRoute
+	length := -1
+	l.tree.Match(r.URL.Path, func(prefix string, routes []*CompiledRoute) bool {
+		if matched = firstMatch(routes, r, op); matched != nil {
+			length = len(prefix)
+			return true
+		}
+		return false
+	})
+	if l.folded.Len() > 0 {
+		l.folded.Match(strings.ToLower(r.URL.Path), func(prefix string, routes []*CompiledRoute) bool {
+			if len(prefix) <= length {
+				return true
+			}
+			if route := firstMatch(routes, r, op); route != nil {
+				matched = route
+				return true
+			}
+			return false
+		})
+	}
+	return matched
+}
+
+// AllowedMethods returns the methods allowed on the path of r, sorted, when
+// some routes match r except for its method and all of them are restricted
+// to methods. Otherwise, when no route matches the path or one matches any
+// method, it returns nil. A request Match rejects and AllowedMethods
+// allows should be answered with 405 Method Not Allowed.
+func (ri *RouterIndex) AllowedMethods(r *http.Request) []string {
+	if ri == nil {
+		return nil
+	}
+	var op requestOperation
+	allowed := make(map[string]struct{})
+	host := requestHost(r)
+
+	tables := []*routeTable{ri.hosts[host]}
+	for _, w := range ri.wildcards {
+		if strings.HasSuffix(host, w.suffix) && len(host) > len(w.suffix) {
+			tables = append(tables, w.table)
+		}
+	}
+	tables = append(tables, ri.any)
+	for _, t := range tables {
+		if t != nil && !t.allowedMethods(r, &op, allowed) {
+			return nil
+		}
+	}
+	if len(allowed) == 0 {
+		return nil
+	}
+	methods := make([]string, 0, len(allowed))
+	for m := range allowed {
+		methods = append(methods, m)
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// allowedMethods adds the methods of the routes of t matching r except for
+// its method to allowed. It returns false when such a route matches any
+// method.
+func (t *routeTable) allowedMethods(r *http.Request, op *requestOperation, allowed map[string]struct{}) bool {
+	ok := true
+	add := func(route *CompiledRoute) {
+		if !route.Match.matchesAnyMethod(r, op) {
+			return
+		}
+		if route.Match.Methods == nil {
+			ok = false
+			return
+		}
+		for m := range route.Match.Methods {
+			allowed[m] = struct{}{}
+		}
+	}
+
+	for _, norm := range t.norms {
+		path := norm.exact(r.URL.Path)
+		for _, route := range t.methodRoutes[path] {
+			add(route)
+		}
+		for _, route := range t.exactRoutes["|"+path] {
+			add(route)
+		}
+	}
+	for _, pe := range t.patternRoutes {
+		add(pe.route)
+	}
+	addAll := func(_ string, routes []*CompiledRoute) bool {
+		for _, route := range routes {
+			add(route)
+		}
+		return false
+	}
+	for i := range t.prefixLevels {
+		t.prefixLevels[i].tree.Match(r.URL.Path, addAll)
+		t.prefixLevels[i].folded.Match(strings.ToLower(r.URL.Path), addAll)
+	}
+	return ok
+}
+
+// Normalize merges runs of slashes in the path of r in place when
+// path_normalization.merge_slashes is set, so that the merged path is both
+// matched and forwarded.
+func (ri *RouterIndex) Normalize(r *http.Request) {
+	if ri == nil || !ri.mergeSlashes {
+		return
+	}
+	if path := pathmatch.MergeSlashes(r.URL.Path); path != r.URL.Path {
+		r.URL.Path = path
+		r.URL.RawPath = ""
+	}
+}
+
+// requestHost returns the host of r, lower-cased and without a port.
+func requestHost(r *http.Request) string {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
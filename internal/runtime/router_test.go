@@ -0,0 +1,234 @@
+package runtime
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/oriys/nexus/internal/config"
+)
+
+func TestRouterIndex_HostMatch(t *testing.T) {
+	backend := config.RouteUpstream{Cluster: "backend"}
+	cfg := &config.Config{
+		Clusters: []config.Cluster{
+			{Name: "backend", Type: "http", Endpoints: []config.ClusterEndpoint{{URL: "http://backend:8080"}}},
+		},
+		RoutesV2: []config.RouteV2{
+			{Name: "default", Match: config.RouteMatch{PathPrefix: "/"}, Upstream: backend},
+			{Name: "default-api", Match: config.RouteMatch{PathPrefix: "/api"}, Upstream: backend},
+			{Name: "shop", Match: config.RouteMatch{Hosts: []string{"shop.example.com", "Store.example.com"}, PathPrefix: "/"}, Upstream: backend},
+			{Name: "tenants", Match: config.RouteMatch{Host: "*.example.com", PathPrefix: "/"}, Upstream: backend},
+			{Name: "eu-tenants", Match: config.RouteMatch{Host: "*.eu.example.com", Path: "/status"}, Upstream: backend},
+		},
+	}
+	compiled, err := Compile(cfg, 1)
+	if err != nil {
+		t.Fatalf("compile error: %v", err)
+	}
+
+	tests := []struct {
+		host, path, route string
+	}{
+		{"shop.example.com", "/api/cart", "shop"},
+		{"STORE.example.com:8443", "/", "shop"},
+		{"acme.example.com", "/api", "tenants"},
+		{"acme.eu.example.com", "/status", "eu-tenants"},
+		// The wildcard table of the longer suffix has no route for the
+		// path, so the shorter one is tried next.
+		{"acme.eu.example.com", "/orders", "tenants"},
+		// A wildcard does not match the bare domain.
+		{"example.com", "/api/x", "default-api"},
+		{"other.org", "/", "default"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.path, nil)
+		req.Host = tt.host
+		route, ok := compiled.Router.Match(req)
+		if !ok || route.Name != tt.route {
+			t.Errorf("%s%s: got %v, want %s", tt.host, tt.path, route, tt.route)
+		}
+	}
+
+	var got []string
+	for _, e := range compiled.Router.Entries() {
+		got = append(got, e.Host+" "+e.Kind+" "+e.Path+" "+e.Route.Name)
+	}
+	want := []string{
+		"shop.example.com prefix / shop",
+		"store.example.com prefix / shop",
+		"*.eu.example.com exact /status eu-tenants",
+		"*.example.com prefix / tenants",
+		" prefix /api default-api",
+		" prefix / default",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("unexpected entries:\n%s", strings.Join(got, "\n"))
+	}
+}
+
+func TestRouterIndex_Priority(t *testing.T) {
+	backend := config.RouteUpstream{Cluster: "backend"}
+	cfg := &config.Config{
+		Clusters: []config.Cluster{
+			{Name: "backend", Type: "http", Endpoints: []config.ClusterEndpoint{{URL: "http://backend:8080"}}},
+		},
+		RoutesV2: []config.RouteV2{
+			{Name: "api", Match: config.RouteMatch{PathPrefix: "/api"}, Upstream: backend, Priority: 10},
+			{Name: "api-v1", Match: config.RouteMatch{PathPrefix: "/api/v1"}, Upstream: backend},
+			{Name: "status", Match: config.RouteMatch{Path: "/status"}, Upstream: backend},
+			{Name: "status-beta", Match: config.RouteMatch{Path: "/status", Headers: []config.HeaderMatch{{Name: "X-Beta", Exact: "1"}}}, Upstream: backend, Priority: 1},
+			{Name: "user", Match: config.RouteMatch{Path: "/users/{id}"}, Upstream: backend},
+			{Name: "me", Match: config.RouteMatch{PathRegex: "/users/me"}, Upstream: backend, Priority: 1},
+		},
+	}
+	compiled, err := Compile(cfg, 1)
+	if err != nil {
+		t.Fatalf("compile error: %v", err)
+	}
+
+	tests := []struct {
+		path, beta, route string
+	}{
+		{"/api/v1/users", "", "api"},
+		{"/status", "", "status"},
+		{"/status", "1", "status-beta"},
+		{"/users/me", "", "me"},
+		{"/users/42", "", "user"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.path, nil)
+		if tt.beta != "" {
+			req.Header.Set("X-Beta", tt.beta)
+		}
+		route, ok := compiled.Router.Match(req)
+		if !ok || route.Name != tt.route {
+			t.Errorf("%s (beta %q): got %v, want %s", tt.path, tt.beta, route, tt.route)
+		}
+	}
+}
+
+// BenchmarkRouterIndex_Prefix matches against a table of 5000 prefix
+// routes, the worst case for a linear scan.
+func BenchmarkRouterIndex_Prefix(b *testing.B) {
+	cfg := &config.Config{
+		Clusters: []config.Cluster{
+			{Name: "backend", Type: "http", Endpoints: []config.ClusterEndpoint{{URL: "http://backend:8080"}}},
+		},
+	}
+	for i := range 5000 {
+		cfg.RoutesV2 = append(cfg.RoutesV2, config.RouteV2{
+			Name:     fmt.Sprintf("svc-%d", i),
+			Match:    config.RouteMatch{PathPrefix: fmt.Sprintf("/api/v1/svc-%d/", i)},
+			Upstream: config.RouteUpstream{Cluster: "backend"},
+		})
+	}
+	compiled, err := Compile(cfg, 1)
+	if err != nil {
+		b.Fatalf("compile error: %v", err)
+	}
+	req := httptest.NewRequest("GET", "/api/v1/svc-42/users/7", nil)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		if route, ok := compiled.Router.Match(req); !ok || route.Name != "svc-42" {
+			b.Fatalf("got %v", route)
+		}
+	}
+}
+
+func TestRouterIndex_AllowedMethods(t *testing.T) {
+	backend := config.RouteUpstream{Cluster: "backend"}
+	cfg := &config.Config{
+		Clusters: []config.Cluster{
+			{Name: "backend", Type: "http", Endpoints: []config.ClusterEndpoint{{URL: "http://backend:8080"}}},
+		},
+		RoutesV2: []config.RouteV2{
+			{Name: "get-user", Match: config.RouteMatch{Methods: []string{"GET", "HEAD"}, Path: "/users/{id}"}, Upstream: backend},
+			{Name: "put-user", Match: config.RouteMatch{Methods: []string{"PUT"}, PathPrefix: "/users/"}, Upstream: backend},
+			{Name: "orders", Match: config.RouteMatch{Methods: []string{"POST"}, Path: "/orders"}, Upstream: backend},
+			{Name: "orders-beta", Match: config.RouteMatch{Path: "/orders", Headers: []config.HeaderMatch{{Name: "X-Beta", Exact: "1"}}}, Upstream: backend},
+			{Name: "shop", Match: config.RouteMatch{Host: "shop.example.com", Methods: []string{"GET"}, PathPrefix: "/cart"}, Upstream: backend},
+		},
+	}
+	compiled, err := Compile(cfg, 1)
+	if err != nil {
+		t.Fatalf("compile error: %v", err)
+	}
+
+	tests := []struct {
+		method, host, path string
+		beta               bool
+		want               []string
+	}{
+		{"DELETE", "", "/users/42", false, []string{"GET", "HEAD", "PUT"}},
+		{"GET", "", "/orders", false, []string{"POST"}},
+		// A route matching any method would take the request with the header.
+		{"GET", "", "/orders", true, nil},
+		{"POST", "shop.example.com", "/cart/1", false, []string{"GET"}},
+		{"POST", "other.example.com", "/cart/1", false, nil},
+		{"GET", "", "/missing", false, nil},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		req.Host = tt.host
+		if tt.beta {
+			req.Header.Set("X-Beta", "1")
+		}
+		if got := compiled.Router.AllowedMethods(req); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s %s%s: got %v, want %v", tt.method, tt.host, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestRouterIndex_PathNormalization(t *testing.T) {
+	backend := config.RouteUpstream{Cluster: "backend"}
+	off := false
+	cfg := &config.Config{
+		Clusters: []config.Cluster{
+			{Name: "backend", Type: "http", Endpoints: []config.ClusterEndpoint{{URL: "http://backend:8080"}}},
+		},
+		PathNormalization: &config.PathNormalization{MergeSlashes: true, StripTrailingSlash: true, CaseInsensitive: true},
+		RoutesV2: []config.RouteV2{
+			{Name: "users", Match: config.RouteMatch{Path: "/API/v1/users/"}, Upstream: backend},
+			{Name: "user", Match: config.RouteMatch{Path: "/api/v1/users/{id}"}, Upstream: backend},
+			{Name: "docs", Match: config.RouteMatch{PathPrefix: "/Docs"}, Upstream: backend},
+			{Name: "strict", Match: config.RouteMatch{Path: "/Strict/", StripTrailingSlash: &off, CaseInsensitive: &off}, Upstream: backend},
+			{Name: "admin", Match: config.RouteMatch{PathPrefix: "/admin", CaseInsensitive: &off}, Upstream: backend},
+		},
+	}
+	compiled, err := Compile(cfg, 1)
+	if err != nil {
+		t.Fatalf("compile error: %v", err)
+	}
+
+	tests := []struct {
+		path, route, forwarded string
+		params                 map[string]string
+	}{
+		{"//api///v1//users/", "users", "/api/v1/users/", nil},
+		{"/API/V1/USERS", "users", "/API/V1/USERS", nil},
+		{"/Api/v1/users/42/", "user", "/Api/v1/users/42/", map[string]string{"id": "42"}},
+		{"/DOCS/intro", "docs", "/DOCS/intro", nil},
+		{"/Strict/", "strict", "/Strict/", nil},
+		{"/Strict", "", "/Strict", nil},
+		{"/strict/", "", "/strict/", nil},
+		{"/admin/x", "admin", "/admin/x", nil},
+		{"/ADMIN/x", "", "/ADMIN/x", nil},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "http://example.com"+tt.path, nil)
+		compiled.Router.Normalize(req)
+		route, params, ok := compiled.Router.MatchParams(req)
+		name := ""
+		if ok {
+			name = route.Name
+		}
+		if name != tt.route || !reflect.DeepEqual(params, tt.params) || req.URL.Path != tt.forwarded {
+			t.Errorf("%s: got %q %v %s, want %q %v %s", tt.path, name, params, req.URL.Path, tt.route, tt.params, tt.forwarded)
+		}
+	}
+}
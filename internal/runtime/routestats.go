@@ -0,0 +1,173 @@
+package runtime
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/oriys/nexus/internal/promtext"
+)
+
+// routeCounters holds the request counters of all routes, by route name. It
+// lives as long as the process so that counters survive config reloads.
+// Compile looks the counters of each route up, so that requests only add to
+// atomics: the request path takes no lock.
+var routeCounters sync.Map // string → *RouteCounters
+
+// statusClasses names the response classes of RouteCounters.
+var statusClasses = [5]string{"1xx", "2xx", "3xx", "4xx", "5xx"}
+
+// RouteCounters counts the requests of one route.
+type RouteCounters struct {
+	inFlight  atomic.Int64
+	responses [5]atomic.Uint64 // by status class, 1xx to 5xx
+	nanos     atomic.Uint64    // time spent serving the requests
+}
+
+// routeCountersFor returns the counters of the route named name.
+func routeCountersFor(name string) *RouteCounters {
+	if c, ok := routeCounters.Load(name); ok {
+		return c.(*RouteCounters)
+	}
+	c, _ := routeCounters.LoadOrStore(name, new(RouteCounters))
+	return c.(*RouteCounters)
+}
+
+// counters returns the request counters of r, looking them up for routes
+// not built by Compile.
+func (r *CompiledRoute) counters() *RouteCounters {
+	if r.stats != nil {
+		return r.stats
+	}
+	return routeCountersFor(r.Name)
+}
+
+func (c *RouteCounters) begin() {
+	c.inFlight.Add(1)
+}
+
+func (c *RouteCounters) record(status int, elapsed time.Duration) {
+	class := status/100 - 1
+	if class < 0 || class >= len(c.responses) {
+		// Nothing was written; net/http answers 200.
+		class = 1
+	}
+	c.responses[class].Add(1)
+	c.nanos.Add(uint64(elapsed))
+	c.inFlight.Add(-1)
+}
+
+// routeStatsWriter captures the response status of a request for the
+// counters of its route. It is kept in the exchange, so that counting a
+// request does not allocate.
+type routeStatsWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *routeStatsWriter) WriteHeader(code int) {
+	if w.status == 0 && (code >= 200 || code == http.StatusSwitchingProtocols) {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *routeStatsWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *routeStatsWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// RouteStats is a snapshot of the request counters of a route.
+type RouteStats struct {
+	Route string `json:"route"`
+	// Requests is the number of requests answered.
+	Requests uint64 `json:"requests"`
+	// Responses counts the answered requests by status class, "2xx" for
+	// instance.
+	Responses map[string]uint64 `json:"responses"`
+	InFlight  int64             `json:"in_flight"`
+	// Seconds is the time spent serving the answered requests.
+	Seconds float64 `json:"seconds"`
+}
+
+// CompressionStats is a snapshot of the compression counters of a route
+// and encoding.
+type CompressionStats struct {
+	Route     string  `json:"route"`
+	Encoding  string  `json:"encoding"`
+	Responses uint64  `json:"responses"`
+	BytesIn   uint64  `json:"bytes_in"`
+	BytesOut  uint64  `json:"bytes_out"`
+	Seconds   float64 `json:"seconds"`
+}
+
+// Stats is a snapshot of the gateway's request counters. The counters are
+// read one at a time while requests go on, so that counters of a snapshot
+// may be a request apart.
+type Stats struct {
+	Routes      []RouteStats       `json:"routes"`
+	Compression []CompressionStats `json:"compression"`
+}
+
+// Snapshot returns the current request counters, sorted by route. The
+// metrics endpoint and the admin status both render it.
+func Snapshot() *Stats {
+	return &Stats{Routes: routeSnapshot(), Compression: compressionSnapshot()}
+}
+
+func routeSnapshot() []RouteStats {
+	routes := []RouteStats{}
+	routeCounters.Range(func(k, v any) bool {
+		c := v.(*RouteCounters)
+		s := RouteStats{
+			Route:     k.(string),
+			Responses: make(map[string]uint64, len(statusClasses)),
+			InFlight:  c.inFlight.Load(),
+			Seconds:   time.Duration(c.nanos.Load()).Seconds(),
+		}
+		for i, class := range statusClasses {
+			n := c.responses[i].Load()
+			s.Responses[class] = n
+			s.Requests += n
+		}
+		routes = append(routes, s)
+		return true
+	})
+	sort.Slice(routes, func(i, j int) bool { return routes[i].Route < routes[j].Route })
+	return routes
+}
+
+// WriteRouteMetrics writes the per-route request metrics in the Prometheus
+// text exposition format.
+func WriteRouteMetrics(w io.Writer) error {
+	routes := routeSnapshot()
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "# HELP nexus_route_requests_total Requests answered by the gateway, by route and status class.\n# TYPE nexus_route_requests_total counter\n")
+	for _, s := range routes {
+		for _, class := range statusClasses {
+			fmt.Fprintf(bw, "nexus_route_requests_total{route=%s,code=%s} %s\n", promtext.QuoteLabel(s.Route), promtext.QuoteLabel(class), strconv.FormatUint(s.Responses[class], 10))
+		}
+	}
+	fmt.Fprintf(bw, "# HELP nexus_route_request_seconds_total Time spent serving requests, by route.\n# TYPE nexus_route_request_seconds_total counter\n")
+	for _, s := range routes {
+		fmt.Fprintf(bw, "nexus_route_request_seconds_total{route=%s} %s\n", promtext.QuoteLabel(s.Route), strconv.FormatFloat(s.Seconds, 'g', -1, 64))
+	}
+	fmt.Fprintf(bw, "# HELP nexus_route_requests_in_flight Requests being served, by route.\n# TYPE nexus_route_requests_in_flight gauge\n")
+	for _, s := range routes {
+		fmt.Fprintf(bw, "nexus_route_requests_in_flight{route=%s} %d\n", promtext.QuoteLabel(s.Route), s.InFlight)
+	}
+	return bw.Flush()
+}
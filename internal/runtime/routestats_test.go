@@ -0,0 +1,79 @@
+package runtime
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/oriys/nexus/internal/config"
+)
+
+func TestGateway_RouteStats(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/stats/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer backend.Close()
+
+	cfg := &config.Config{
+		Clusters: []config.Cluster{{Name: "stats", Type: "http", Endpoints: []config.ClusterEndpoint{{URL: backend.URL}}}},
+		RoutesV2: []config.RouteV2{{
+			Name:     "route-stats",
+			Match:    config.RouteMatch{PathPrefix: "/stats"},
+			Upstream: config.RouteUpstream{Cluster: "stats"},
+		}},
+	}
+	compiled, err := Compile(cfg, 1)
+	if err != nil {
+		t.Fatalf("compile error: %v", err)
+	}
+	store := NewConfigStore()
+	store.Store(compiled)
+	gw := NewGateway(store)
+
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			path := "/stats/ok"
+			if i%4 == 0 {
+				path = "/stats/missing"
+			}
+			gw.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+		}()
+	}
+	wg.Wait()
+
+	var got *RouteStats
+	for _, s := range Snapshot().Routes {
+		if s.Route == "route-stats" {
+			got = &s
+		}
+	}
+	if got == nil {
+		t.Fatal("route missing from the snapshot")
+	}
+	if got.Requests != 20 || got.Responses["2xx"] != 15 || got.Responses["4xx"] != 5 || got.InFlight != 0 || got.Seconds <= 0 {
+		t.Errorf("route stats %+v", *got)
+	}
+
+	var b strings.Builder
+	WriteRouteMetrics(&b)
+	for _, want := range []string{
+		"# TYPE nexus_route_requests_total counter",
+		`nexus_route_requests_total{route="route-stats",code="2xx"} 15`,
+		`nexus_route_requests_total{route="route-stats",code="4xx"} 5`,
+		`nexus_route_requests_in_flight{route="route-stats"} 0`,
+		`nexus_route_request_seconds_total{route="route-stats"} `,
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("metrics lack %q:\n%s", want, b.String())
+		}
+	}
+}
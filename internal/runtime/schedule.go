@@ -0,0 +1,65 @@
+package runtime
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/oriys/nexus/internal/config"
+)
+
+// CompiledSchedule holds when a route is active.
+type CompiledSchedule struct {
+	start, end time.Time // zero when unbounded
+	loc        *time.Location
+	windows    []scheduleWindow
+}
+
+type scheduleWindow struct {
+	days     uint8 // bit set indexed by time.Weekday
+	from, to int   // minutes since midnight
+}
+
+func compileSchedule(s *config.RouteSchedule) (*CompiledSchedule, error) {
+	cs := &CompiledSchedule{}
+	var err error
+	if cs.start, cs.end, err = s.Bounds(); err != nil {
+		return nil, err
+	}
+	if cs.loc, err = s.Location(); err != nil {
+		return nil, err
+	}
+	for i := range s.Windows {
+		days, from, to, err := s.Windows[i].Parse()
+		if err != nil {
+			return nil, fmt.Errorf("window %d: %w", i, err)
+		}
+		cs.windows = append(cs.windows, scheduleWindow{days, from, to})
+	}
+	return cs, nil
+}
+
+// Active reports whether the schedule is active at t.
+func (s *CompiledSchedule) Active(t time.Time) bool {
+	if !s.start.IsZero() && t.Before(s.start) || !s.end.IsZero() && !t.Before(s.end) {
+		return false
+	}
+	if len(s.windows) == 0 {
+		return true
+	}
+	t = t.In(s.loc)
+	day, minute := t.Weekday(), t.Hour()*60+t.Minute()
+	yesterday := (day + 6) % 7
+	for _, w := range s.windows {
+		if w.from < w.to {
+			if w.days&(1<<day) != 0 && minute >= w.from && minute < w.to {
+				return true
+			}
+			continue
+		}
+		// The window runs past midnight.
+		if w.days&(1<<day) != 0 && minute >= w.from || w.days&(1<<yesterday) != 0 && minute < w.to {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,94 @@
+package runtime
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/oriys/nexus/internal/config"
+)
+
+func TestCompiledSchedule_Active(t *testing.T) {
+	s, err := compileSchedule(&config.RouteSchedule{
+		Start:    "2026-11-01T00:00:00Z",
+		End:      "2026-12-01T00:00:00Z",
+		Timezone: "Asia/Shanghai",
+		Windows: []config.ScheduleWindow{
+			{Days: []string{"mon-fri"}, From: "09:00", To: "12:00"},
+			{Days: []string{"sat"}, From: "22:00", To: "02:00"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	shanghai := time.FixedZone("CST", 8*3600)
+	tests := []struct {
+		at   time.Time
+		want bool
+	}{
+		{time.Date(2026, 11, 2, 9, 0, 0, 0, shanghai), true},    // Monday
+		{time.Date(2026, 11, 2, 12, 0, 0, 0, shanghai), false},  // window end
+		{time.Date(2026, 11, 2, 1, 30, 0, 0, time.UTC), true},   // 09:30 in Shanghai
+		{time.Date(2026, 11, 7, 10, 0, 0, 0, shanghai), false},  // Saturday morning
+		{time.Date(2026, 11, 7, 23, 0, 0, 0, shanghai), true},   // Saturday night
+		{time.Date(2026, 11, 8, 1, 59, 0, 0, shanghai), true},   // past midnight
+		{time.Date(2026, 11, 9, 1, 0, 0, 0, shanghai), false},   // Monday, after Sunday
+		{time.Date(2026, 10, 30, 10, 0, 0, 0, shanghai), false}, // before start
+		{time.Date(2026, 12, 1, 10, 0, 0, 0, shanghai), false},  // after end
+	}
+	for _, tt := range tests {
+		if got := s.Active(tt.at); got != tt.want {
+			t.Errorf("Active(%s) = %v, want %v", tt.at, got, tt.want)
+		}
+	}
+}
+
+func TestGateway_RouteSchedule(t *testing.T) {
+	backend := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(name))
+		}))
+	}
+	sale, regular := backend("sale"), backend("regular")
+	defer sale.Close()
+	defer regular.Close()
+
+	now := time.Now()
+	cfg := &config.Config{
+		Clusters: []config.Cluster{
+			{Name: "sale", Type: "http", Endpoints: []config.ClusterEndpoint{{URL: sale.URL}}},
+			{Name: "regular", Type: "http", Endpoints: []config.ClusterEndpoint{{URL: regular.URL}}},
+		},
+		RoutesV2: []config.RouteV2{
+			{
+				Name:     "flash-sale",
+				Match:    config.RouteMatch{PathPrefix: "/products"},
+				Priority: 10,
+				Upstream: config.RouteUpstream{Cluster: "sale"},
+				Schedule: &config.RouteSchedule{End: now.Add(-time.Hour).Format(time.RFC3339)},
+			},
+			{
+				Name:     "launch",
+				Match:    config.RouteMatch{Path: "/launch"},
+				Upstream: config.RouteUpstream{Cluster: "sale"},
+				Schedule: &config.RouteSchedule{Start: now.Add(-time.Hour).Format(time.RFC3339)},
+			},
+			{Name: "products", Match: config.RouteMatch{PathPrefix: "/products"}, Upstream: config.RouteUpstream{Cluster: "regular"}},
+		},
+	}
+	compiled, err := Compile(cfg, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := NewConfigStore()
+	store.Store(compiled)
+	gw := NewGateway(store)
+	for path, want := range map[string]string{"/products/1": "regular", "/launch": "sale"} {
+		rec := httptest.NewRecorder()
+		gw.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		if rec.Code != http.StatusOK || rec.Body.String() != want {
+			t.Errorf("%s: got %d %q, want %q", path, rec.Code, rec.Body.String(), want)
+		}
+	}
+}
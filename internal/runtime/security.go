@@ -0,0 +1,246 @@
+package runtime
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/oriys/nexus/internal/clientip"
+	"github.com/oriys/nexus/internal/config"
+	"github.com/oriys/nexus/internal/reqctx"
+)
+
+// securityHeadersFilter adds standard security headers to responses that do
+// not set them already. X-Content-Type-Options and Referrer-Policy have
+// defaults; HSTS, CSP and X-Frame-Options are sent only when configured.
+type securityHeadersFilter struct {
+	headers [][2]string
+}
+
+func newSecurityHeadersFilter(args config.FilterArgs) (Filter, error) {
+	if err := args.Check("hsts", "content_type_options", "csp", "referrer_policy", "frame_options"); err != nil {
+		return nil, fmt.Errorf("security_headers filter: %w", err)
+	}
+	f := &securityHeadersFilter{}
+	for _, h := range []struct{ arg, name, def string }{
+		{"hsts", "Strict-Transport-Security", ""},
+		{"content_type_options", "X-Content-Type-Options", "nosniff"},
+		{"csp", "Content-Security-Policy", ""},
+		{"referrer_policy", "Referrer-Policy", "strict-origin-when-cross-origin"},
+		{"frame_options", "X-Frame-Options", ""},
+	} {
+		v, err := args.String(h.arg)
+		if err != nil {
+			return nil, fmt.Errorf("security_headers filter: %w", err)
+		}
+		if _, ok := args[h.arg]; !ok {
+			v = h.def
+		}
+		// An argument set to "off" suppresses a default header
+		if v != "" && v != "off" {
+			f.headers = append(f.headers, [2]string{h.name, v})
+		}
+	}
+	return f, nil
+}
+
+func (f *securityHeadersFilter) Apply(r *http.Request) error { return nil }
+
+func (f *securityHeadersFilter) ApplyResponse(resp *http.Response) error {
+	for _, h := range f.headers {
+		if resp.Header.Get(h[0]) == "" {
+			resp.Header.Set(h[0], h[1])
+		}
+	}
+	return nil
+}
+
+// csrfFilter enforces double-submit CSRF tokens: requests with an unsafe
+// method must echo the value of the token cookie in the token header. A
+// response to a request without the cookie issues a fresh token; the cookie
+// is not HttpOnly, since browser scripts read it to set the header.
+type csrfFilter struct {
+	cookie string
+	header string
+	secure bool
+}
+
+func newCSRFFilter(args config.FilterArgs) (Filter, error) {
+	if err := args.Check("cookie", "header", "secure"); err != nil {
+		return nil, fmt.Errorf("csrf filter: %w", err)
+	}
+	f := &csrfFilter{}
+	var err error
+	if f.cookie, err = args.String("cookie"); err == nil {
+		if f.header, err = args.String("header"); err == nil {
+			f.secure, err = args.Bool("secure", true)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("csrf filter: %w", err)
+	}
+	if f.cookie == "" {
+		f.cookie = "csrf_token"
+	}
+	if f.header == "" {
+		f.header = "X-CSRF-Token"
+	}
+	return f, nil
+}
+
+func (f *csrfFilter) Apply(r *http.Request) error {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return nil
+	}
+	c, err := r.Cookie(f.cookie)
+	token := r.Header.Get(f.header)
+	if err != nil || c.Value == "" || token == "" || subtle.ConstantTimeCompare([]byte(c.Value), []byte(token)) != 1 {
+		return &StatusError{Status: http.StatusForbidden, Message: "invalid CSRF token"}
+	}
+	return nil
+}
+
+func (f *csrfFilter) ApplyResponse(resp *http.Response) error {
+	if resp.Request == nil {
+		return nil
+	}
+	if c, err := resp.Request.Cookie(f.cookie); err == nil && c.Value != "" {
+		return nil
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	cookie := &http.Cookie{
+		Name:     f.cookie,
+		Value:    base64.RawURLEncoding.EncodeToString(b),
+		Path:     "/",
+		Secure:   f.secure,
+		SameSite: http.SameSiteStrictMode,
+	}
+	resp.Header.Add("Set-Cookie", cookie.String())
+	return nil
+}
+
+// ipACLFilter answers requests from client addresses its allow and deny
+// lists reject with 403. The client address is the one resolved from
+// trusted_proxies.
+type ipACLFilter struct {
+	acl *clientip.ACL
+}
+
+func newIPACLFilter(args config.FilterArgs) (Filter, error) {
+	if err := args.Check("allow", "deny"); err != nil {
+		return nil, fmt.Errorf("ip_acl filter: %w", err)
+	}
+	allow, err := args.Strings("allow")
+	if err != nil {
+		return nil, fmt.Errorf("ip_acl filter: %w", err)
+	}
+	deny, err := args.Strings("deny")
+	if err != nil {
+		return nil, fmt.Errorf("ip_acl filter: %w", err)
+	}
+	if len(allow) == 0 && len(deny) == 0 {
+		return nil, fmt.Errorf("ip_acl filter: 'allow' or 'deny' argument is required")
+	}
+	acl, err := clientip.NewACL(allow, deny)
+	if err != nil {
+		return nil, fmt.Errorf("ip_acl filter: %w", err)
+	}
+	return &ipACLFilter{acl: acl}, nil
+}
+
+func (f *ipACLFilter) Apply(r *http.Request) error {
+	if !f.acl.Allows(clientip.FromRequest(r)) {
+		return &StatusError{Status: http.StatusForbidden, Message: "client address not allowed"}
+	}
+	return nil
+}
+
+// Headers set by the sign_request filter.
+const (
+	signRouteHeader     = "X-Nexus-Route"
+	signConsumerHeader  = "X-Nexus-Consumer"
+	signTimestampHeader = "X-Nexus-Timestamp"
+)
+
+// signRequestFilter lets backends verify that a request went through the
+// gateway: it sets the matched route, the authenticated consumer and the
+// current Unix time as headers, and signs them with HMAC-SHA256 under a key
+// shared with the backends. The signed string is
+//
+//	route "\n" consumer "\n" timestamp "\n" method "\n" path
+//
+// with the method as forwarded and the path the backend receives, including
+// the base path of the cluster endpoint, so the filter belongs last in the
+// route's filters. Copies of these headers sent by the client are dropped.
+type signRequestFilter struct {
+	key    []byte
+	header string
+}
+
+func newSignRequestFilter(args config.FilterArgs) (Filter, error) {
+	key, err := requiredArg(args, "key", "header")
+	if err != nil {
+		return nil, fmt.Errorf("sign_request filter: %w", err)
+	}
+	header, err := args.String("header")
+	if err != nil {
+		return nil, fmt.Errorf("sign_request filter: %w", err)
+	}
+	if header == "" {
+		header = "X-Nexus-Signature"
+	}
+	return &signRequestFilter{key: []byte(key), header: header}, nil
+}
+
+func (f *signRequestFilter) Apply(r *http.Request) error {
+	route, _ := reqctx.Route.Get(r.Context())
+	var consumer string
+	if id, ok := reqctx.Identity.Get(r.Context()); ok && id != nil {
+		consumer = id.Subject
+	}
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	r.Header.Del(signConsumerHeader)
+	r.Header.Set(signRouteHeader, route)
+	if consumer != "" {
+		r.Header.Set(signConsumerHeader, consumer)
+	}
+	r.Header.Set(signTimestampHeader, ts)
+	f.sign(r)
+	return nil
+}
+
+// sign sets the signature of r from the headers Apply set and the path of r.
+func (f *signRequestFilter) sign(r *http.Request) {
+	h := r.Header
+	h.Set(f.header, SignRequest(f.key, h.Get(signRouteHeader), h.Get(signConsumerHeader),
+		h.Get(signTimestampHeader), r.Method, r.URL.Path))
+}
+
+// signOutbound signs out again for the sign_request filters of route, once
+// its URL has been joined with the endpoint it is sent to.
+func signOutbound(route *CompiledRoute, out *http.Request) {
+	for _, f := range route.Filters {
+		if f, ok := f.(*signRequestFilter); ok {
+			f.sign(out)
+		}
+	}
+}
+
+// SignRequest returns the hex HMAC-SHA256 signature the sign_request
+// filter sends, for backends written in Go to verify.
+func SignRequest(key []byte, route, consumer, timestamp, method, path string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(route + "\n" + consumer + "\n" + timestamp + "\n" + method + "\n" + path))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,253 @@
+package runtime
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/oriys/nexus/internal/auth"
+	"github.com/oriys/nexus/internal/config"
+	"github.com/oriys/nexus/internal/reqctx"
+)
+
+func TestSecurityHeadersFilter(t *testing.T) {
+	f, err := newSecurityHeadersFilter(config.FilterArgs{
+		"hsts":            "max-age=31536000; includeSubDomains",
+		"csp":             "default-src 'self'",
+		"referrer_policy": "off",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	resp := newTestResponse(200, "text/html", "")
+	resp.Header.Set("Content-Security-Policy", "default-src 'none'")
+	if err := f.(ResponseFilter).ApplyResponse(resp); err != nil {
+		t.Fatalf("apply error: %v", err)
+	}
+	want := map[string]string{
+		"Strict-Transport-Security": "max-age=31536000; includeSubDomains",
+		"X-Content-Type-Options":    "nosniff",
+		"Content-Security-Policy":   "default-src 'none'", // the upstream's own policy wins
+		"Referrer-Policy":           "",
+		"X-Frame-Options":           "",
+	}
+	for name, v := range want {
+		if got := resp.Header.Get(name); got != v {
+			t.Errorf("%s = %q, want %q", name, got, v)
+		}
+	}
+}
+
+func TestCSRFFilter(t *testing.T) {
+	f, err := newCSRFFilter(config.FilterArgs{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		cookie string
+		header string
+		ok     bool
+	}{
+		{"safe method", "GET", "", "", true},
+		{"matching token", "POST", "abc", "abc", true},
+		{"missing header", "POST", "abc", "", false},
+		{"missing cookie", "DELETE", "", "abc", false},
+		{"mismatch", "PUT", "abc", "abd", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/", nil)
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: "csrf_token", Value: tt.cookie})
+			}
+			if tt.header != "" {
+				req.Header.Set("X-CSRF-Token", tt.header)
+			}
+			err := f.Apply(req)
+			var se *StatusError
+			if tt.ok && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if !tt.ok && (!errors.As(err, &se) || se.Status != http.StatusForbidden) {
+				t.Errorf("expected 403, got %v", err)
+			}
+		})
+	}
+
+	// A token cookie is issued only to clients without one
+	resp := newTestResponse(200, "text/html", "")
+	resp.Request = httptest.NewRequest("GET", "/", nil)
+	f.(ResponseFilter).ApplyResponse(resp)
+	set := resp.Header.Get("Set-Cookie")
+	if !strings.HasPrefix(set, "csrf_token=") || !strings.Contains(set, "Secure") || !strings.Contains(set, "SameSite=Strict") {
+		t.Errorf("Set-Cookie = %q", set)
+	}
+	resp = newTestResponse(200, "text/html", "")
+	resp.Request = httptest.NewRequest("GET", "/", nil)
+	resp.Request.AddCookie(&http.Cookie{Name: "csrf_token", Value: "abc"})
+	f.(ResponseFilter).ApplyResponse(resp)
+	if set := resp.Header.Get("Set-Cookie"); set != "" {
+		t.Errorf("token reissued: %q", set)
+	}
+}
+
+func TestGateway_CSRF(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer backend.Close()
+
+	cfg := &config.Config{
+		Clusters: []config.Cluster{
+			{Name: "backend", Type: "http", Endpoints: []config.ClusterEndpoint{{URL: backend.URL}}},
+		},
+		RoutesV2: []config.RouteV2{{
+			Name:  "app",
+			Match: config.RouteMatch{PathPrefix: "/"},
+			Filters: []config.RouteFilter{
+				{Type: "security_headers"},
+				{Type: "csrf"},
+			},
+			Upstream: config.RouteUpstream{Cluster: "backend"},
+		}},
+	}
+	compiled, err := Compile(cfg, 1)
+	if err != nil {
+		t.Fatalf("compile error: %v", err)
+	}
+	store := NewConfigStore()
+	store.Store(compiled)
+	gw := NewGateway(store)
+
+	rec := httptest.NewRecorder()
+	gw.ServeHTTP(rec, httptest.NewRequest("POST", "/form", nil))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("POST without token: status = %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	gw.ServeHTTP(rec, httptest.NewRequest("GET", "/form", nil))
+	cookies := rec.Result().Cookies()
+	if rec.Code != http.StatusOK || len(cookies) != 1 || rec.Header().Get("X-Content-Type-Options") != "nosniff" {
+		t.Fatalf("GET: status %d, cookies %v, headers %v", rec.Code, cookies, rec.Header())
+	}
+
+	req := httptest.NewRequest("POST", "/form", nil)
+	req.AddCookie(cookies[0])
+	req.Header.Set("X-CSRF-Token", cookies[0].Value)
+	rec = httptest.NewRecorder()
+	gw.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("POST with token: status = %d", rec.Code)
+	}
+}
+
+func TestIPACLFilter(t *testing.T) {
+	f, err := newIPACLFilter(config.FilterArgs{"allow": []any{"10.0.0.0/8"}, "deny": "10.0.0.66"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for remote, ok := range map[string]bool{
+		"10.1.2.3:4000":    true,
+		"10.0.0.66:4000":   false,
+		"203.0.113.9:4000": false,
+	} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = remote
+		err := f.Apply(req)
+		var se *StatusError
+		if ok && err != nil {
+			t.Errorf("%s: unexpected error: %v", remote, err)
+		}
+		if !ok && (!errors.As(err, &se) || se.Status != http.StatusForbidden) {
+			t.Errorf("%s: expected 403, got %v", remote, err)
+		}
+	}
+
+	for _, args := range []config.FilterArgs{{}, {"allow": "10.0.0.0/33"}, {"deny": "x"}, {"allowed": "10.0.0.1"}} {
+		if _, err := newIPACLFilter(args); err == nil {
+			t.Errorf("expected an error for %v", args)
+		}
+	}
+}
+
+func TestSignRequestFilter(t *testing.T) {
+	f, err := newSignRequestFilter(config.FilterArgs{"key": "s3cret"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	req, _ := reqctx.AttachRequest(httptest.NewRequest("POST", "/orders/7", nil))
+	reqctx.Route.Set(req.Context(), "orders")
+	reqctx.Identity.Set(req.Context(), &auth.Identity{Subject: "billing"})
+	req.Header.Set("X-Nexus-Route", "forged")
+	req.Header.Set("X-Nexus-Signature", "forged")
+	if err := f.Apply(req); err != nil {
+		t.Fatalf("apply error: %v", err)
+	}
+	ts := req.Header.Get("X-Nexus-Timestamp")
+	if req.Header.Get("X-Nexus-Route") != "orders" || req.Header.Get("X-Nexus-Consumer") != "billing" || ts == "" {
+		t.Fatalf("unexpected headers %v", req.Header)
+	}
+	want := SignRequest([]byte("s3cret"), "orders", "billing", ts, "POST", "/orders/7")
+	if got := req.Header.Get("X-Nexus-Signature"); got != want {
+		t.Errorf("signature = %s, want %s", got, want)
+	}
+	if SignRequest([]byte("other"), "orders", "billing", ts, "POST", "/orders/7") == want {
+		t.Error("signature does not depend on the key")
+	}
+
+	// Anonymous requests carry no consumer, even a forged one
+	f, _ = newSignRequestFilter(config.FilterArgs{"key": "s3cret", "header": "X-Gateway-Sig"})
+	req = httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Nexus-Consumer", "admin")
+	f.Apply(req)
+	if req.Header.Get("X-Nexus-Consumer") != "" || req.Header.Get("X-Gateway-Sig") == "" {
+		t.Errorf("unexpected headers %v", req.Header)
+	}
+	if _, err := newSignRequestFilter(config.FilterArgs{}); err == nil {
+		t.Error("expected an error without a key")
+	}
+}
+
+func TestGateway_SignRequestBasePath(t *testing.T) {
+	verified := make(chan bool, 1)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The backend verifies the path it received, base path included.
+		want := SignRequest([]byte("s3cret"), r.Header.Get("X-Nexus-Route"), r.Header.Get("X-Nexus-Consumer"),
+			r.Header.Get("X-Nexus-Timestamp"), r.Method, r.URL.Path)
+		verified <- r.URL.Path == "/api/orders/7" && r.Header.Get("X-Nexus-Signature") == want
+	}))
+	defer backend.Close()
+
+	cfg := &config.Config{
+		Clusters: []config.Cluster{
+			{Name: "backend", Type: "http", Endpoints: []config.ClusterEndpoint{{URL: backend.URL + "/api"}}},
+		},
+		RoutesV2: []config.RouteV2{{
+			Name:     "orders",
+			Match:    config.RouteMatch{PathPrefix: "/orders"},
+			Filters:  []config.RouteFilter{{Type: "sign_request", Args: config.FilterArgs{"key": "s3cret"}}},
+			Upstream: config.RouteUpstream{Cluster: "backend"},
+		}},
+	}
+	compiled, err := Compile(cfg, 1)
+	if err != nil {
+		t.Fatalf("compile error: %v", err)
+	}
+	store := NewConfigStore()
+	store.Store(compiled)
+	gw := NewGateway(store)
+
+	rec := httptest.NewRecorder()
+	gw.ServeHTTP(rec, httptest.NewRequest("POST", "/orders/7", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	if !<-verified {
+		t.Error("signature does not match the path the backend received")
+	}
+}
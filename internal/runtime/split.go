@@ -0,0 +1,83 @@
+package runtime
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"math/rand/v2"
+	"net/http"
+
+	"github.com/oriys/nexus/internal/auth"
+	"github.com/oriys/nexus/internal/config"
+)
+
+// ClusterSplit divides the traffic of a route between weighted clusters.
+type ClusterSplit struct {
+	Clusters []string
+	Weights  []int
+	total    int
+	// sticky hashes the header, the cookie or, with neither set, the
+	// authenticated subject of a request to choose its cluster.
+	sticky bool
+	header string
+	cookie string
+}
+
+func newClusterSplit(u *config.RouteUpstream) *ClusterSplit {
+	s := &ClusterSplit{}
+	for _, c := range u.Clusters {
+		if c.Weight <= 0 {
+			continue
+		}
+		s.Clusters = append(s.Clusters, c.Name)
+		s.Weights = append(s.Weights, c.Weight)
+		s.total += c.Weight
+	}
+	if u.Sticky != nil {
+		s.sticky = true
+		s.header = u.Sticky.Header
+		s.cookie = u.Sticky.Cookie
+	}
+	return s
+}
+
+// Pick returns the cluster serving r. A sticky split maps the hashed user
+// to a fixed fraction of the summed weights, so shifting weight to the
+// canary of a two-cluster split only moves users into it.
+func (s *ClusterSplit) Pick(r *http.Request) string {
+	var n int
+	if key, ok := s.key(r); ok {
+		sum := sha256.Sum256([]byte(key))
+		n = int(float64(binary.BigEndian.Uint64(sum[:])>>11) / (1 << 53) * float64(s.total))
+	} else {
+		n = rand.IntN(s.total)
+	}
+	for i, w := range s.Weights {
+		if n < w {
+			return s.Clusters[i]
+		}
+		n -= w
+	}
+	return s.Clusters[len(s.Clusters)-1]
+}
+
+// key returns the value identifying the user of r to a sticky split.
+func (s *ClusterSplit) key(r *http.Request) (string, bool) {
+	switch {
+	case !s.sticky:
+		return "", false
+	case s.header != "":
+		v := r.Header.Get(s.header)
+		return v, v != ""
+	case s.cookie != "":
+		c, err := r.Cookie(s.cookie)
+		if err != nil || c.Value == "" {
+			return "", false
+		}
+		return c.Value, true
+	}
+	id := auth.GetIdentity(r.Context())
+	if id == nil || id.Subject == "" {
+		return "", false
+	}
+	return id.Source + ":" + id.Subject, true
+}
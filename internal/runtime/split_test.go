@@ -0,0 +1,134 @@
+package runtime
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/oriys/nexus/internal/auth"
+	"github.com/oriys/nexus/internal/config"
+)
+
+func TestClusterSplit_Weights(t *testing.T) {
+	s := newClusterSplit(&config.RouteUpstream{Clusters: []config.WeightedCluster{
+		{Name: "stable", Weight: 90}, {Name: "canary", Weight: 10}, {Name: "off", Weight: 0},
+	}})
+	counts := map[string]int{}
+	for i := 0; i < 10000; i++ {
+		counts[s.Pick(httptest.NewRequest("GET", "/", nil))]++
+	}
+	if counts["off"] != 0 {
+		t.Errorf("cluster with weight 0 picked %d times", counts["off"])
+	}
+	if c := counts["canary"]; c < 800 || c > 1200 {
+		t.Errorf("canary picked %d of 10000 times, expected about 1000", c)
+	}
+}
+
+func TestClusterSplit_Sticky(t *testing.T) {
+	split := func(canary int, sticky *config.StickySplit) *ClusterSplit {
+		return newClusterSplit(&config.RouteUpstream{
+			Clusters: []config.WeightedCluster{{Name: "canary", Weight: canary}, {Name: "stable", Weight: 100 - canary}},
+			Sticky:   sticky,
+		})
+	}
+	s := split(10, &config.StickySplit{Header: "X-User-ID"})
+	wider := split(30, &config.StickySplit{Header: "X-User-ID"})
+	counts := map[string]int{}
+	for i := 0; i < 2000; i++ {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("X-User-ID", fmt.Sprint("user-", i))
+		got := s.Pick(r)
+		for j := 0; j < 3; j++ {
+			if again := s.Pick(r); again != got {
+				t.Fatalf("user %d moved from %s to %s", i, got, again)
+			}
+		}
+		// Raising the canary weight keeps its users there.
+		if got == "canary" && wider.Pick(r) != "canary" {
+			t.Errorf("user %d left the canary when its weight grew", i)
+		}
+		counts[got]++
+	}
+	if c := counts["canary"]; c < 100 || c > 300 {
+		t.Errorf("canary got %d of 2000 users, expected about 200", c)
+	}
+
+	// Cookies and subjects are hashed the same way.
+	s = split(50, &config.StickySplit{Cookie: "uid"})
+	r := httptest.NewRequest("GET", "/", nil)
+	r.AddCookie(&http.Cookie{Name: "uid", Value: "42"})
+	want := s.Pick(r)
+	for i := 0; i < 20; i++ {
+		if got := s.Pick(r); got != want {
+			t.Fatalf("cookie user moved from %s to %s", want, got)
+		}
+	}
+	s = split(50, &config.StickySplit{})
+	r = httptest.NewRequest("GET", "/", nil)
+	r = r.WithContext(auth.IdentityToContext(r.Context(), &auth.Identity{Source: "jwt", Subject: "alice"}))
+	want = s.Pick(r)
+	for i := 0; i < 20; i++ {
+		if got := s.Pick(r); got != want {
+			t.Fatalf("subject moved from %s to %s", want, got)
+		}
+	}
+}
+
+func TestGateway_ClusterSplit(t *testing.T) {
+	backend := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(name))
+		}))
+	}
+	stable, canary := backend("stable"), backend("canary")
+	defer stable.Close()
+	defer canary.Close()
+
+	cfg := &config.Config{
+		Clusters: []config.Cluster{
+			{Name: "stable", Type: "http", Endpoints: []config.ClusterEndpoint{{URL: stable.URL}}},
+			{Name: "canary", Type: "http", Endpoints: []config.ClusterEndpoint{{URL: canary.URL}}},
+		},
+		RoutesV2: []config.RouteV2{{
+			Name:  "api",
+			Match: config.RouteMatch{PathPrefix: "/"},
+			Upstream: config.RouteUpstream{
+				Clusters: []config.WeightedCluster{{Name: "stable", Weight: 50}, {Name: "canary", Weight: 50}},
+				Sticky:   &config.StickySplit{Header: "X-User-ID"},
+			},
+		}},
+	}
+	compiled, err := Compile(cfg, 1)
+	if err != nil {
+		t.Fatalf("compile error: %v", err)
+	}
+	store := NewConfigStore()
+	store.Store(compiled)
+	gw := NewGateway(store)
+
+	seen := map[string]bool{}
+	for i := 0; i < 20; i++ {
+		user := fmt.Sprint("user-", i)
+		var first string
+		for j := 0; j < 3; j++ {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("X-User-ID", user)
+			rec := httptest.NewRecorder()
+			gw.ServeHTTP(rec, req)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status %d", rec.Code)
+			}
+			if j == 0 {
+				first = rec.Body.String()
+			} else if rec.Body.String() != first {
+				t.Fatalf("%s served by %s, then %s", user, first, rec.Body.String())
+			}
+		}
+		seen[first] = true
+	}
+	if !seen["stable"] || !seen["canary"] {
+		t.Errorf("20 users all served by %v", seen)
+	}
+}
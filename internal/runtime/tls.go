@@ -0,0 +1,341 @@
+package runtime
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/oriys/nexus/internal/config"
+)
+
+// certReloadDebounce is how long the certificate watcher waits for further
+// file events before reloading, since a certificate and its key are
+// usually replaced one after the other.
+var certReloadDebounce = 200 * time.Millisecond
+
+// CertStore holds the certificates of the gateway listener and selects one
+// for each handshake by the requested server name. The certificates are
+// reloaded from their files with Reload, or on file changes with Watch;
+// handshakes in progress keep the certificate they selected.
+type CertStore struct {
+	cfg     *config.ServerTLSConfig
+	certs   atomic.Pointer[certSet]
+	tickets *ticketKeys // nil without session_ticket_rotation
+}
+
+// certSet is a loaded set of certificates.
+type certSet struct {
+	exact    map[string]*tls.Certificate // by lower-case server name
+	wildcard map[string]*tls.Certificate // "*.example.com" by "example.com"
+	fallback *tls.Certificate
+}
+
+// NewCertStore loads the certificates of cfg.
+func NewCertStore(cfg *config.ServerTLSConfig) (*CertStore, error) {
+	s := &CertStore{cfg: cfg}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	rotation, err := cfg.SessionTicketRotation.Parse()
+	if err != nil {
+		return nil, fmt.Errorf("session ticket rotation: %w", err)
+	}
+	if rotation > 0 {
+		if s.tickets, err = newTicketKeys(rotation); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Reload loads the certificates again. When one fails to load, the store
+// keeps serving the previous ones.
+func (s *CertStore) Reload() error {
+	set := &certSet{exact: make(map[string]*tls.Certificate), wildcard: make(map[string]*tls.Certificate)}
+	cfg := s.cfg
+	if cfg.CertFile != "" || cfg.Cert != "" {
+		cert, err := loadKeyPair(cfg.CertFile, cfg.Cert, cfg.KeyFile, cfg.Key, cfg.OCSPStapleFile)
+		if err != nil {
+			return fmt.Errorf("server certificate: %w", err)
+		}
+		set.fallback = cert
+		set.add(cert, nil)
+	}
+	for i, c := range cfg.Certificates {
+		cert, err := loadKeyPair(c.CertFile, c.Cert, c.KeyFile, c.Key, c.OCSPStapleFile)
+		if err != nil {
+			return fmt.Errorf("server certificate %d: %w", i, err)
+		}
+		if set.fallback == nil {
+			set.fallback = cert
+		}
+		set.add(cert, c.ServerNames)
+	}
+	s.certs.Store(set)
+	return nil
+}
+
+// add indexes cert under names, or under the DNS names of the certificate
+// when names is empty. Earlier certificates win for the same name.
+func (set *certSet) add(cert *tls.Certificate, names []string) {
+	if len(names) == 0 {
+		names = cert.Leaf.DNSNames
+		if len(names) == 0 && cert.Leaf.Subject.CommonName != "" {
+			names = []string{cert.Leaf.Subject.CommonName}
+		}
+	}
+	for _, name := range names {
+		name = strings.ToLower(name)
+		index := set.exact
+		if suffix, ok := strings.CutPrefix(name, "*."); ok {
+			name, index = suffix, set.wildcard
+		}
+		if _, ok := index[name]; !ok {
+			index[name] = cert
+		}
+	}
+}
+
+// loadKeyPair loads a certificate and key, each given as a file or as PEM,
+// with the OCSP response in stapleFile, if set.
+func loadKeyPair(certFile, certPEM, keyFile, keyPEM, stapleFile string) (*tls.Certificate, error) {
+	certData, keyData := []byte(certPEM), []byte(keyPEM)
+	var err error
+	if certFile != "" {
+		if certData, err = os.ReadFile(certFile); err != nil {
+			return nil, fmt.Errorf("read certificate: %w", err)
+		}
+	}
+	if keyFile != "" {
+		if keyData, err = os.ReadFile(keyFile); err != nil {
+			return nil, fmt.Errorf("read key: %w", err)
+		}
+	}
+	cert, err := tls.X509KeyPair(certData, keyData)
+	if err != nil {
+		return nil, fmt.Errorf("load certificate: %w", err)
+	}
+	if stapleFile != "" {
+		if cert.OCSPStaple, err = os.ReadFile(stapleFile); err != nil {
+			return nil, fmt.Errorf("read OCSP staple: %w", err)
+		}
+	}
+	return &cert, nil
+}
+
+// GetCertificate selects the certificate for a handshake: the certificate
+// serving the exact server name, else one serving it with a wildcard, else
+// the default certificate.
+func (s *CertStore) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	set := s.certs.Load()
+	name := strings.ToLower(strings.TrimSuffix(hello.ServerName, "."))
+	if cert, ok := set.exact[name]; ok {
+		return cert, nil
+	}
+	if _, parent, ok := strings.Cut(name, "."); ok {
+		if cert, ok := set.wildcard[parent]; ok {
+			return cert, nil
+		}
+	}
+	if set.fallback == nil {
+		return nil, errors.New("no server certificate")
+	}
+	return set.fallback, nil
+}
+
+// files returns the certificate, key and OCSP staple files of the store.
+func (s *CertStore) files() []string {
+	var files []string
+	for _, f := range []string{s.cfg.CertFile, s.cfg.KeyFile, s.cfg.OCSPStapleFile} {
+		if f != "" {
+			files = append(files, f)
+		}
+	}
+	for _, c := range s.cfg.Certificates {
+		for _, f := range []string{c.CertFile, c.KeyFile, c.OCSPStapleFile} {
+			if f != "" {
+				files = append(files, f)
+			}
+		}
+	}
+	return files
+}
+
+// Watch reloads the certificates when their files change, until done is
+// closed. It watches the directories of the files, so that replacements by
+// rename, as Kubernetes does for mounted secrets, are seen too. It returns
+// at once when all certificates are given as PEM.
+func (s *CertStore) Watch(done <-chan struct{}) error {
+	files := s.files()
+	if len(files) == 0 {
+		return nil
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("create certificate watcher: %w", err)
+	}
+	defer watcher.Close()
+	dirs := make(map[string]bool)
+	for _, f := range files {
+		dir := filepath.Dir(f)
+		if dirs[dir] {
+			continue
+		}
+		dirs[dir] = true
+		if err := watcher.Add(dir); err != nil {
+			return fmt.Errorf("watch certificate directory: %w", err)
+		}
+	}
+
+	var pending <-chan time.Time // fires once events have settled
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if event.Has(fsnotify.Chmod) && !event.Has(fsnotify.Write) {
+				continue
+			}
+			pending = time.After(certReloadDebounce)
+		case <-pending:
+			pending = nil
+			if err := s.Reload(); err != nil {
+				slog.Error("failed to reload server certificates, keeping current", slog.String("error", err.Error()))
+				continue
+			}
+			slog.Info("server certificates reloaded")
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			slog.Error("certificate watcher error", slog.String("error", err.Error()))
+		case <-done:
+			return nil
+		}
+	}
+}
+
+// TLSConfig builds the TLS configuration of the gateway listener, serving
+// the certificates of the store with the protocol settings of its config. With a client CA, client certificates are
+// verified when presented, and required with require_client_cert; routes
+// match their attributes with match.tls.client_cert.
+func (s *CertStore) TLSConfig() (*tls.Config, error) {
+	cfg := s.cfg
+	tc := &tls.Config{GetCertificate: s.GetCertificate, NextProtos: cfg.ALPN, SessionTicketsDisabled: cfg.DisableSessionTickets}
+	var err error
+	if tc.MinVersion, tc.MaxVersion, err = cfg.TLSVersions(); err != nil {
+		return nil, err
+	}
+	if tc.CipherSuites, err = cfg.CipherSuiteIDs(); err != nil {
+		return nil, err
+	}
+	if tc.CurvePreferences, err = cfg.CurveIDs(); err != nil {
+		return nil, err
+	}
+	if s.tickets != nil {
+		// The ticket keys live outside tc, which http.Server clones
+		tc.WrapSession = s.tickets.wrap
+		tc.UnwrapSession = s.tickets.unwrap
+	}
+	if cfg.ClientCAFile != "" {
+		pem, err := os.ReadFile(cfg.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read server client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("server client CA %s holds no PEM certificates", cfg.ClientCAFile)
+		}
+		tc.ClientCAs = pool
+		tc.ClientAuth = tls.VerifyClientCertIfGiven
+		if cfg.RequireClientCert {
+			tc.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	}
+	return tc, nil
+}
+
+// ticketKeys encrypts session tickets under keys rotated at a fixed
+// interval. The keys are set on a config of their own, since http.Server
+// serves a clone of the listener config, which later key changes would
+// not reach.
+type ticketKeys struct {
+	every time.Duration
+	cfg   *tls.Config
+	keys  [][32]byte // newest first
+}
+
+// ticketKeysKept is the number of keys tickets are accepted under.
+const ticketKeysKept = 3
+
+func newTicketKeys(every time.Duration) (*ticketKeys, error) {
+	t := &ticketKeys{every: every, cfg: &tls.Config{}}
+	if err := t.rotate(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// rotate issues new tickets under a fresh key, dropping the oldest.
+func (t *ticketKeys) rotate() error {
+	var key [32]byte
+	if _, err := rand.Read(key[:]); err != nil {
+		return fmt.Errorf("generate session ticket key: %w", err)
+	}
+	t.keys = append([][32]byte{key}, t.keys...)
+	if len(t.keys) > ticketKeysKept {
+		t.keys = t.keys[:ticketKeysKept]
+	}
+	t.cfg.SetSessionTicketKeys(t.keys)
+	return nil
+}
+
+func (t *ticketKeys) wrap(cs tls.ConnectionState, ss *tls.SessionState) ([]byte, error) {
+	return t.cfg.EncryptTicket(cs, ss)
+}
+
+func (t *ticketKeys) unwrap(identity []byte, cs tls.ConnectionState) (*tls.SessionState, error) {
+	return t.cfg.DecryptTicket(identity, cs)
+}
+
+// RotateSessionTickets rotates the session ticket keys at the interval of
+// session_ticket_rotation until done is closed. It returns at once when no
+// rotation is configured.
+func (s *CertStore) RotateSessionTickets(done <-chan struct{}) {
+	if s.tickets == nil {
+		return
+	}
+	ticker := time.NewTicker(s.tickets.every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.tickets.rotate(); err != nil {
+				slog.Error("failed to rotate session ticket keys", slog.String("error", err.Error()))
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+// ServerTLSConfig builds the TLS configuration of the gateway listener with
+// a new CertStore; use NewCertStore to reload the certificates.
+func ServerTLSConfig(cfg *config.ServerTLSConfig) (*tls.Config, error) {
+	s, err := NewCertStore(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return s.TLSConfig()
+}
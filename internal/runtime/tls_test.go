@@ -0,0 +1,329 @@
+package runtime
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/oriys/nexus/internal/config"
+)
+
+func TestServerTLSConfig(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "nexus"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IsCA:         true,
+		KeyUsage:     x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	keyPEM := string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+	caFile := filepath.Join(t.TempDir(), "ca.crt")
+	os.WriteFile(caFile, []byte(certPEM), 0o600)
+
+	tc, err := ServerTLSConfig(&config.ServerTLSConfig{Cert: certPEM, Key: keyPEM})
+	if err != nil {
+		t.Fatalf("ServerTLSConfig: %v", err)
+	}
+	if tc.GetCertificate == nil || tc.ClientCAs != nil || tc.ClientAuth != tls.NoClientCert {
+		t.Errorf("unexpected TLS config %+v", tc)
+	}
+	tc, err = ServerTLSConfig(&config.ServerTLSConfig{Cert: certPEM, Key: keyPEM, ClientCAFile: caFile, RequireClientCert: true})
+	if err != nil {
+		t.Fatalf("ServerTLSConfig: %v", err)
+	}
+	if tc.ClientCAs == nil || tc.ClientAuth != tls.RequireAndVerifyClientCert {
+		t.Errorf("unexpected TLS config %+v", tc)
+	}
+	if _, err := ServerTLSConfig(&config.ServerTLSConfig{Cert: certPEM, Key: certPEM}); err == nil {
+		t.Error("expected an error for an invalid key")
+	}
+}
+
+// testKeyPair returns a self-signed certificate and key in PEM for names.
+func testKeyPair(t *testing.T, cn string, names ...string) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: cn},
+		DNSNames:     names,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+}
+
+func TestCertStore_SNI(t *testing.T) {
+	defCert, defKey := testKeyPair(t, "default")
+	apiCert, apiKey := testKeyPair(t, "api", "api.example.com")
+	wildCert, wildKey := testKeyPair(t, "wildcard", "*.example.com")
+	namedCert, namedKey := testKeyPair(t, "named")
+	store, err := NewCertStore(&config.ServerTLSConfig{
+		Cert: defCert, Key: defKey,
+		Certificates: []config.TLSCertificate{
+			{Cert: apiCert, Key: apiKey},
+			{Cert: wildCert, Key: wildKey},
+			{Cert: namedCert, Key: namedKey, ServerNames: []string{"Legacy.Example.org"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewCertStore: %v", err)
+	}
+	tests := []struct{ sni, want string }{
+		{"api.example.com", "api"},
+		{"API.example.com.", "api"},
+		{"www.example.com", "wildcard"},
+		{"a.b.example.com", "default"},
+		{"legacy.example.org", "named"},
+		{"", "default"},
+	}
+	for _, tt := range tests {
+		cert, err := store.GetCertificate(&tls.ClientHelloInfo{ServerName: tt.sni})
+		if err != nil || cert.Leaf.Subject.CommonName != tt.want {
+			t.Errorf("%q: got %v (%v), want %s", tt.sni, cert, err, tt.want)
+		}
+	}
+}
+
+func TestCertStore_Reload(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	writePair := func(cn string) {
+		cert, key := testKeyPair(t, cn)
+		os.WriteFile(certFile, []byte(cert), 0o600)
+		os.WriteFile(keyFile, []byte(key), 0o600)
+	}
+	served := func(store *CertStore) string {
+		cert, err := store.GetCertificate(&tls.ClientHelloInfo{})
+		if err != nil {
+			t.Fatalf("GetCertificate: %v", err)
+		}
+		return cert.Leaf.Subject.CommonName
+	}
+	writePair("first")
+	store, err := NewCertStore(&config.ServerTLSConfig{CertFile: certFile, KeyFile: keyFile})
+	if err != nil {
+		t.Fatalf("NewCertStore: %v", err)
+	}
+
+	writePair("second")
+	if err := store.Reload(); err != nil || served(store) != "second" {
+		t.Fatalf("after reload: %v, serving %s", err, served(store))
+	}
+	os.WriteFile(keyFile, []byte("garbage"), 0o600)
+	if err := store.Reload(); err == nil {
+		t.Error("expected an error for an invalid key")
+	}
+	if got := served(store); got != "second" {
+		t.Errorf("failed reload replaced the certificate with %s", got)
+	}
+
+	old := certReloadDebounce
+	certReloadDebounce = 10 * time.Millisecond
+	defer func() { certReloadDebounce = old }()
+	done := make(chan struct{})
+	watchErr := make(chan error, 1)
+	go func() { watchErr <- store.Watch(done) }()
+	time.Sleep(50 * time.Millisecond)
+	writePair("third")
+	deadline := time.Now().Add(2 * time.Second)
+	for served(store) != "third" && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	close(done)
+	if err := <-watchErr; err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+	if got := served(store); got != "third" {
+		t.Errorf("watcher did not reload, serving %s", got)
+	}
+}
+
+func TestCertStore_Tuning(t *testing.T) {
+	cert, key := testKeyPair(t, "nexus", "nexus.test")
+	stapleFile := filepath.Join(t.TempDir(), "ocsp.der")
+	os.WriteFile(stapleFile, []byte("ocsp-response"), 0o600)
+	store, err := NewCertStore(&config.ServerTLSConfig{
+		Cert: cert, Key: key, OCSPStapleFile: stapleFile,
+		MinVersion: "1.2", MaxVersion: "1.2",
+		CipherSuites:     []string{"TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384"},
+		CurvePreferences: []string{"P384"},
+		ALPN:             []string{"http/1.1"},
+	})
+	if err != nil {
+		t.Fatalf("NewCertStore: %v", err)
+	}
+	tc, err := store.TLSConfig()
+	if err != nil {
+		t.Fatalf("TLSConfig: %v", err)
+	}
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", tc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+
+	conn, err := tls.Dial("tcp", ln.Addr().String(), &tls.Config{InsecureSkipVerify: true, NextProtos: []string{"h2", "http/1.1"}})
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	state := conn.ConnectionState()
+	conn.Close()
+	if state.Version != tls.VersionTLS12 || state.CipherSuite != tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384 ||
+		state.NegotiatedProtocol != "http/1.1" || string(state.OCSPResponse) != "ocsp-response" {
+		t.Errorf("unexpected connection state: version %x, suite %x, protocol %q, OCSP %q",
+			state.Version, state.CipherSuite, state.NegotiatedProtocol, state.OCSPResponse)
+	}
+}
+
+func TestCertStore_SessionTicketRotation(t *testing.T) {
+	cert, key := testKeyPair(t, "nexus", "nexus.test")
+	store, err := NewCertStore(&config.ServerTLSConfig{Cert: cert, Key: key, SessionTicketRotation: "1h"})
+	if err != nil {
+		t.Fatalf("NewCertStore: %v", err)
+	}
+	tc, err := store.TLSConfig()
+	if err != nil {
+		t.Fatalf("TLSConfig: %v", err)
+	}
+	// Serve a clone, as http.Server does
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", tc.Clone())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			// TLS 1.3 tickets are sent after the handshake; a read lets
+			// the client receive them before the connection closes
+			conn.Read(make([]byte, 1))
+			conn.Close()
+		}
+	}()
+
+	client := &tls.Config{InsecureSkipVerify: true, ClientSessionCache: tls.NewLRUClientSessionCache(1)}
+	resumed := func() bool {
+		conn, err := tls.Dial("tcp", ln.Addr().String(), client)
+		if err != nil {
+			t.Fatalf("dial: %v", err)
+		}
+		defer conn.Close()
+		conn.Write([]byte("x"))
+		conn.Read(make([]byte, 1))
+		return conn.ConnectionState().DidResume
+	}
+	resumed()
+	store.tickets.rotate()
+	if !resumed() {
+		t.Error("ticket under the previous key was not accepted")
+	}
+	for i := 0; i < ticketKeysKept; i++ {
+		store.tickets.rotate()
+	}
+	if resumed() {
+		t.Error("ticket under a dropped key was accepted")
+	}
+}
+
+func TestRouterIndex_TLSMatch(t *testing.T) {
+	backend := config.RouteUpstream{Cluster: "backend"}
+	cfg := &config.Config{
+		Clusters: []config.Cluster{
+			{Name: "backend", Type: "http", Endpoints: []config.ClusterEndpoint{{URL: "http://backend:8080"}}},
+		},
+		RoutesV2: []config.RouteV2{
+			{Name: "acme-billing", Match: config.RouteMatch{PathPrefix: "/", TLS: &config.TLSMatch{
+				SNI:        []string{"*.acme.example.com"},
+				ClientCert: &config.ClientCertMatch{OrganizationalUnits: []string{"billing"}},
+			}}, Upstream: backend},
+			{Name: "spiffe", Match: config.RouteMatch{PathPrefix: "/", TLS: &config.TLSMatch{
+				ClientCert: &config.ClientCertMatch{SANs: []string{"spiffe://example.org/ns/payments", "10.0.0.7"}},
+			}}, Upstream: backend},
+			{Name: "acme", Match: config.RouteMatch{PathPrefix: "/", TLS: &config.TLSMatch{SNI: []string{"API.acme.example.com"}}}, Upstream: backend},
+			{Name: "default", Match: config.RouteMatch{PathPrefix: "/"}, Upstream: backend},
+		},
+	}
+	compiled, err := Compile(cfg, 1)
+	if err != nil {
+		t.Fatalf("compile error: %v", err)
+	}
+
+	spiffe, _ := url.Parse("spiffe://example.org/ns/payments")
+	tests := []struct {
+		name  string
+		state *tls.ConnectionState
+		want  string
+	}{
+		{"plaintext", nil, "default"},
+		{"sni", &tls.ConnectionState{ServerName: "api.acme.example.com"}, "acme"},
+		{"sni without certificate", &tls.ConnectionState{ServerName: "eu.acme.example.com"}, "default"},
+		{"ou", &tls.ConnectionState{ServerName: "eu.acme.example.com", VerifiedChains: [][]*x509.Certificate{{
+			{Subject: pkix.Name{CommonName: "svc", OrganizationalUnit: []string{"ops", "billing"}}},
+		}}}, "acme-billing"},
+		{"unverified ou", &tls.ConnectionState{ServerName: "eu.acme.example.com", PeerCertificates: []*x509.Certificate{
+			{Subject: pkix.Name{OrganizationalUnit: []string{"billing"}}},
+		}}, "default"},
+		{"uri san", &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{URIs: []*url.URL{spiffe}}}}}, "spiffe"},
+		{"ip san", &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{IPAddresses: []net.IP{net.ParseIP("10.0.0.7")}}}}}, "spiffe"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.TLS = tt.state
+		route, ok := compiled.Router.Match(req)
+		if !ok || route.Name != tt.want {
+			t.Errorf("%s: got %v, want %s", tt.name, route, tt.want)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package runtime
+
+import "sync"
+
+// poolable is a cluster transport a transportPool can drain.
+type poolable interface {
+	comparable
+	CloseIdleConnections()
+}
+
+// transportPool hands out one transport per cluster, keeping it across
+// config reloads as long as the cluster's settings do not change. The HTTP
+// and gRPC pools share it so that they keep transports by the same rules.
+type transportPool[S comparable, T poolable] struct {
+	settingsOf func(*CompiledCluster) S
+	build      func(S) T
+	// inUse returns the transport a compiled cluster holds.
+	inUse func(*CompiledCluster) T
+
+	mu         sync.Mutex
+	transports map[string]pooledTransport[S, T] // cluster name → transport
+}
+
+type pooledTransport[S comparable, T poolable] struct {
+	settings  S
+	transport T
+}
+
+func newTransportPool[S comparable, T poolable](settingsOf func(*CompiledCluster) S, build func(S) T, inUse func(*CompiledCluster) T) *transportPool[S, T] {
+	return &transportPool[S, T]{
+		settingsOf: settingsOf,
+		build:      build,
+		inUse:      inUse,
+		transports: make(map[string]pooledTransport[S, T]),
+	}
+}
+
+// forCluster returns the transport for cc, replacing and draining the
+// previous one if the cluster's settings changed.
+func (p *transportPool[S, T]) forCluster(cc *CompiledCluster) T {
+	s := p.settingsOf(cc)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if pt, ok := p.transports[cc.Name]; ok {
+		if pt.settings == s {
+			return pt.transport
+		}
+		// In-flight requests finish on their existing connections.
+		pt.transport.CloseIdleConnections()
+	}
+	t := p.build(s)
+	p.transports[cc.Name] = pooledTransport[S, T]{settings: s, transport: t}
+	return t
+}
+
+// retain drops transports for clusters that no longer exist or no longer
+// use them, closing their idle connections.
+func (p *transportPool[S, T]) retain(clusters map[string]*CompiledCluster) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for name, pt := range p.transports {
+		if cc, ok := clusters[name]; ok && p.inUse(cc) == pt.transport {
+			continue
+		}
+		pt.transport.CloseIdleConnections()
+		delete(p.transports, name)
+	}
+}
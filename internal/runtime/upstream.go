@@ -2,15 +2,20 @@ package runtime
 
 import (
 	"bytes"
-	"encoding/binary"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
-	"net/http/httputil"
 	"net/url"
-	"time"
+	"strconv"
+	"strings"
+
+	"github.com/oriys/nexus/internal/config"
+	"github.com/oriys/nexus/internal/graphql"
+	"github.com/oriys/nexus/internal/transcode"
 )
 
 // Upstream is the interface for protocol-specific upstream handlers.
@@ -23,45 +28,17 @@ type HTTPUpstream struct{}
 
 // Handle proxies the request to the HTTP upstream using streaming reverse proxy.
 func (u *HTTPUpstream) Handle(w http.ResponseWriter, r *http.Request, route *CompiledRoute, cluster *CompiledCluster) error {
-	ep, ok := cluster.NextEndpoint()
-	if !ok {
-		return fmt.Errorf("no endpoints available for cluster %s", cluster.Name)
-	}
-
-	addr := EndpointAddress(ep)
-	target, err := url.Parse(addr)
+	target, addr, err := cluster.NextTarget()
 	if err != nil {
-		return fmt.Errorf("invalid upstream target %s: %w", addr, err)
-	}
-
-	// If no scheme, default to http
-	if target.Scheme == "" {
-		target, err = url.Parse("http://" + addr)
-		if err != nil {
-			return fmt.Errorf("invalid upstream target %s: %w", addr, err)
-		}
-	}
-
-	proxy := &httputil.ReverseProxy{
-		Rewrite: func(pr *httputil.ProxyRequest) {
-			pr.SetURL(target)
-			pr.Out.Host = r.Host
-		},
-		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
-			slog.Error("proxy error",
-				slog.String("cluster", cluster.Name),
-				slog.String("target", addr),
-				slog.String("error", err.Error()),
-			)
-			http.Error(w, "bad gateway", http.StatusBadGateway)
-		},
+		return err
 	}
 
+	proxy := httpProxy
 	if route.TimeoutMs > 0 {
-		proxy.FlushInterval = 100 * time.Millisecond
+		proxy = httpFlushProxy
 	}
-
-	proxy.ServeHTTP(w, r)
+	call := &proxyCall{route: route, cluster: cluster, target: target, addr: addr, host: r.Host}
+	call.serve(proxy, w, r)
 	return nil
 }
 
@@ -75,29 +52,38 @@ func (u *GRPCUpstream) Handle(w http.ResponseWriter, r *http.Request, route *Com
 		return fmt.Errorf("route %s missing gRPC upstream config", route.Name)
 	}
 
-	ep, ok := cluster.NextEndpoint()
-	if !ok {
-		return fmt.Errorf("no endpoints available for cluster %s", cluster.Name)
-	}
-
-	addr := EndpointAddress(ep)
-	target, err := url.Parse(addr)
+	target, addr, err := cluster.NextTarget()
 	if err != nil {
-		return fmt.Errorf("invalid upstream target %s: %w", addr, err)
+		return err
 	}
-	if target.Scheme == "" {
-		target, err = url.Parse("http://" + addr)
-		if err != nil {
-			return fmt.Errorf("invalid upstream target %s: %w", addr, err)
+
+	tc := route.GRPCTranscode
+
+	// Native gRPC clients understand trailers; everyone else gets the status
+	// translated into an HTTP status code and JSON error body.
+	grpcClient := strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc")
+
+	if grpcCfg.Passthrough {
+		// The request path already names the method: /<service>/<method>
+		service, method, ok := parseGRPCPath(r.URL.Path)
+		if !ok || !route.GRPCAllow.Allows(service, method) {
+			writeGRPCProxyError(w, GRPCStatus{Code: 12, Message: "method " + r.URL.Path + " is not available"}, grpcClient)
+			return nil
 		}
+	} else {
+		// Set gRPC path: /<service>/<method>
+		r.URL.Path = "/" + grpcCfg.Service + "/" + grpcCfg.Method
+		r.URL.RawPath = ""
 	}
 
-	// Set gRPC path: /<service>/<method>
-	r.URL.Path = "/" + grpcCfg.Service + "/" + grpcCfg.Method
-	r.URL.RawPath = ""
-
-	// Set gRPC content-type
-	r.Header.Set("Content-Type", "application/grpc+json")
+	// Set gRPC content-type. Native gRPC requests keep their own.
+	switch {
+	case grpcClient:
+	case tc != nil && tc.Request != nil:
+		r.Header.Set("Content-Type", "application/grpc+proto")
+	default:
+		r.Header.Set("Content-Type", "application/grpc+json")
+	}
 
 	// Note: HTTP/2 is negotiated by the transport layer; setting ProtoMajor is
 	// informational for gRPC framing. The reverse proxy transport handles the
@@ -105,48 +91,228 @@ func (u *GRPCUpstream) Handle(w http.ResponseWriter, r *http.Request, route *Com
 	r.ProtoMajor = 2
 	r.ProtoMinor = 0
 
-	// Wrap body in gRPC length-prefixed framing if body exists
-	if r.Body != nil {
-		bodyBytes, err := io.ReadAll(r.Body)
-		r.Body.Close()
+	limit := grpcMaxSendMsg(cluster)
+	if grpcClient {
+		// Native gRPC bodies are already framed and may be streaming in both
+		// directions, so they are forwarded as they arrive.
+		http.NewResponseController(w).EnableFullDuplex()
+		if r.Body != nil {
+			r.Body = transcode.LimitFrames(r.Body, int(limit))
+		}
+	} else if tc != nil && tc.Request != nil && tc.ClientStreaming {
+		// Client-streaming RPC: each JSON document in the request body becomes
+		// one message, forwarded as soon as it is read. Full duplex lets
+		// HTTP/1.1 clients keep sending while responses stream back.
+		http.NewResponseController(w).EnableFullDuplex()
+		r.Body = transcode.LimitFrames(transcode.NewStreamEncoder(r.Body, tc.Request), int(limit))
+		r.ContentLength = -1
+		r.Header.Del("Content-Length")
+	} else if tc != nil && tc.Request != nil {
+		// Unary RPC with a JSON body: the body is read into a pooled buffer
+		// and the encoded message framed as it is sent.
+		var body io.Reader = http.NoBody
+		if r.Body != nil {
+			body = http.MaxBytesReader(w, r.Body, limit)
+		}
+		buf, err := readPooled(body)
 		if err != nil {
-			return fmt.Errorf("failed to read request body: %w", err)
+			return writeGRPCBodyError(w, err)
 		}
+		// protojson stops at the first problem; on failure the body is
+		// re-checked against the schema to report every bad field.
+		msg, err := tc.Request.FromJSON(buf.Bytes())
+		if err != nil {
+			violations := tc.Request.Validate(buf.Bytes())
+			putBuffer(buf)
+			if len(violations) == 0 {
+				violations = []transcode.FieldViolation{{Description: err.Error()}}
+			}
+			writeGRPCProxyError(w, GRPCStatus{Code: 3, Message: "invalid request body", Violations: violations}, false)
+			return err
+		}
+		putBuffer(buf)
+		r.Body = io.NopCloser(transcode.NewFrameReader(bytes.NewReader(msg), len(msg)))
+		r.ContentLength = int64(transcode.FrameHeaderLen + len(msg))
+	} else if r.Body != nil {
+		// The body is the message. Its framing needs its length: a body of
+		// known length is streamed behind the frame header, others are read
+		// into a pooled buffer first.
+		if r.ContentLength > limit {
+			return writeGRPCBodyError(w, &http.MaxBytesError{Limit: limit})
+		}
+		if r.ContentLength >= 0 {
+			r.Body = struct {
+				io.Reader
+				io.Closer
+			}{transcode.NewFrameReader(r.Body, int(r.ContentLength)), r.Body}
+			r.ContentLength += transcode.FrameHeaderLen
+		} else {
+			buf, err := readPooled(http.MaxBytesReader(w, r.Body, limit))
+			if err != nil {
+				return writeGRPCBodyError(w, err)
+			}
+			r.Body = newPooledBody(transcode.NewFrameReader(bytes.NewReader(buf.Bytes()), buf.Len()), buf)
+			r.ContentLength = int64(transcode.FrameHeaderLen + buf.Len())
+		}
+	}
 
-		var framedBuf bytes.Buffer
-		framedBuf.WriteByte(0) // not compressed
-		msgLen := make([]byte, 4)
-		binary.BigEndian.PutUint32(msgLen, uint32(len(bodyBytes)))
-		framedBuf.Write(msgLen)
-		framedBuf.Write(bodyBytes)
+	r.Header.Set("TE", "trailers")
 
-		r.Body = io.NopCloser(&framedBuf)
-		r.ContentLength = int64(framedBuf.Len())
+	// Propagate the deadline so the upstream stops work the gateway has
+	// already given up on.
+	if d := grpcDeadline(r, route.TimeoutMs); d > 0 {
+		ctx, cancel := context.WithTimeout(r.Context(), d)
+		defer cancel()
+		r = r.WithContext(ctx)
+		r.Header.Set("Grpc-Timeout", encodeGRPCTimeout(d))
+	}
+	if r.Header.Get("Grpc-Trace-Bin") == "" {
+		if bin, ok := grpcTraceBin(r.Header.Get("Traceparent")); ok {
+			r.Header.Set("Grpc-Trace-Bin", bin)
+		}
 	}
 
-	r.Header.Set("TE", "trailers")
+	call := &proxyCall{route: route, cluster: cluster, target: target, addr: addr, grpcClient: grpcClient}
+	call.serve(grpcProxy, w, r)
+	return nil
+}
 
-	proxy := &httputil.ReverseProxy{
-		Rewrite: func(pr *httputil.ProxyRequest) {
-			pr.SetURL(target)
-			if cluster.GRPC != nil && cluster.GRPC.Authority != "" {
-				pr.Out.Host = cluster.GRPC.Authority
-			}
-		},
-		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
-			slog.Error("grpc proxy error",
-				slog.String("cluster", cluster.Name),
-				slog.String("target", addr),
-				slog.String("error", err.Error()),
-			)
-			http.Error(w, "bad gateway", http.StatusBadGateway)
-		},
-	}
-
-	proxy.ServeHTTP(w, r)
+// finishGRPCResponse buffers a unary gRPC response so its status trailer is
+// known before headers are sent. Failed calls are rewritten into an HTTP error
+// with a JSON body; successful ones have their message transcoded to JSON when
+// codec is set.
+func finishGRPCResponse(resp *http.Response, codec *transcode.Codec) error {
+	buf, err := readPooled(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return fmt.Errorf("read grpc response: %w", err)
+	}
+
+	if st, ok := responseGRPCStatus(resp); ok && st.Code != 0 {
+		putBuffer(buf)
+		writeGRPCError(resp, st)
+		return nil
+	}
+
+	if codec == nil || buf.Len() == 0 {
+		resp.Body = newPooledBody(bytes.NewReader(buf.Bytes()), buf)
+		resp.ContentLength = int64(buf.Len())
+		resp.Header.Set("Content-Length", strconv.Itoa(buf.Len()))
+		return nil
+	}
+	defer putBuffer(buf)
+	msg, err := transcode.SplitFrame(buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("read grpc response frame: %w", err)
+	}
+	body, err := codec.ToJSON(msg)
+	if err != nil {
+		return err
+	}
+	resp.Header.Set("Content-Type", "application/json")
+	setResponseBody(resp, body)
 	return nil
 }
 
+// grpcMaxSendMsg returns the largest request message sent to cluster.
+func grpcMaxSendMsg(cluster *CompiledCluster) int64 {
+	if cluster.GRPC == nil {
+		return config.DefaultGRPCMaxSendMsg
+	}
+	return cluster.GRPC.MaxSendMsgLimit()
+}
+
+// writeGRPCBodyError answers a request whose body could not be read, with
+// RESOURCE_EXHAUSTED when it is larger than the cluster accepts.
+func writeGRPCBodyError(w http.ResponseWriter, err error) error {
+	if tooLarge := (*http.MaxBytesError)(nil); errors.As(err, &tooLarge) {
+		writeGRPCProxyError(w, GRPCStatus{Code: 8, Message: fmt.Sprintf("request message larger than %d bytes", tooLarge.Limit)}, false)
+		return err
+	}
+	return fmt.Errorf("failed to read request body: %w", err)
+}
+
+// writeGRPCError replaces the response with the HTTP translation of st.
+func writeGRPCError(resp *http.Response, st GRPCStatus) {
+	resp.StatusCode = st.HTTPStatus()
+	resp.Status = ""
+	resp.Trailer = nil
+	resp.Header.Del("Trailer")
+	resp.Header.Set("Content-Type", "application/json")
+	setResponseBody(resp, st.JSON())
+}
+
+// writeGRPCProxyError reports a call the gateway failed itself, such as one
+// that ran past its deadline, as a trailers-only response for gRPC clients
+// and a JSON error for everyone else.
+func writeGRPCProxyError(w http.ResponseWriter, st GRPCStatus, grpcClient bool) {
+	if grpcClient {
+		w.Header().Set("Content-Type", "application/grpc")
+		w.Header().Set("Grpc-Status", strconv.Itoa(st.Code))
+		w.Header().Set("Grpc-Message", url.PathEscape(st.Message))
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(st.HTTPStatus())
+	w.Write(st.JSON())
+}
+
+func setResponseBody(resp *http.Response, body []byte) {
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+}
+
+// streamGRPCResponse converts a server-streaming response into
+// newline-delimited JSON, one line per upstream message. The unknown content
+// length makes the reverse proxy flush each line immediately. A failing
+// status that arrives in the trailers is reported as a final
+// {"error": {...}} line, since the HTTP status has already been sent.
+func streamGRPCResponse(resp *http.Response, codec *transcode.Codec) {
+	if st, ok := parseGRPCStatus(resp.Header); ok && st.Code != 0 {
+		// Trailers-only response: the call failed before any message.
+		resp.Body.Close()
+		writeGRPCError(resp, st)
+		return
+	}
+
+	src := resp.Body
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{
+		Reader: io.MultiReader(
+			transcode.NewStreamDecoder(src, codec),
+			&lazyReader{fn: func() []byte {
+				if st, ok := parseGRPCStatus(resp.Trailer); ok && st.Code != 0 {
+					return append(st.JSON(), '\n')
+				}
+				return nil
+			}},
+		),
+		Closer: src,
+	}
+	resp.Trailer = nil
+	resp.Header.Del("Trailer")
+	resp.ContentLength = -1
+	resp.Header.Del("Content-Length")
+	resp.Header.Set("Content-Type", "application/x-ndjson")
+}
+
+// lazyReader produces its content on first read.
+type lazyReader struct {
+	fn  func() []byte
+	buf *bytes.Reader
+}
+
+func (l *lazyReader) Read(p []byte) (int, error) {
+	if l.buf == nil {
+		l.buf = bytes.NewReader(l.fn())
+	}
+	return l.buf.Read(p)
+}
+
 // dubboInvocation represents a Dubbo invocation request.
 type dubboInvocation struct {
 	Interface  string      `json:"interface"`
@@ -155,7 +321,9 @@ type dubboInvocation struct {
 	Args       interface{} `json:"args"`
 }
 
-// DubboUpstream handles HTTP-to-Dubbo proxying.
+// DubboUpstream handles HTTP-to-Dubbo proxying. Triple clusters and clusters
+// using hessian2 serialization are called natively; others receive the
+// invocation as JSON over HTTP.
 type DubboUpstream struct{}
 
 // Handle proxies the request to the Dubbo upstream.
@@ -164,39 +332,46 @@ func (u *DubboUpstream) Handle(w http.ResponseWriter, r *http.Request, route *Co
 	if dubboCfg == nil {
 		return fmt.Errorf("route %s missing Dubbo upstream config", route.Name)
 	}
-
-	ep, ok := cluster.NextEndpoint()
-	if !ok {
-		return fmt.Errorf("no endpoints available for cluster %s", cluster.Name)
+	if r.Body != nil {
+		r.Body = http.MaxBytesReader(w, r.Body, dubboMaxBody(cluster))
+	}
+	if nativeDubbo(cluster) || tripleDubbo(cluster) {
+		return u.invokeNative(w, r, route, cluster)
 	}
 
-	addr := EndpointAddress(ep)
-	target, err := url.Parse(addr)
+	target, addr, err := cluster.NextTarget()
 	if err != nil {
-		return fmt.Errorf("invalid upstream target %s: %w", addr, err)
-	}
-	if target.Scheme == "" {
-		target, err = url.Parse("http://" + addr)
-		if err != nil {
-			return fmt.Errorf("invalid upstream target %s: %w", addr, err)
-		}
+		return err
 	}
 
-	// Read original body as the method arguments
+	// Read the method arguments from the mapped inputs, or use the original
+	// body as the arguments.
 	var args interface{}
-	if r.Body != nil {
-		bodyBytes, err := io.ReadAll(r.Body)
+	var body *bytes.Buffer
+	paramTypes := dubboCfg.ParamTypes
+	if p := route.DubboParams; p != nil {
+		list, err := p.Args(r)
+		if err != nil {
+			writeDubboBodyTooLarge(w, err)
+			return fmt.Errorf("invalid dubbo arguments: %w", err)
+		}
+		args, paramTypes = list, p.Types
+	} else if r.Body != nil {
+		var err error
+		body, err = readPooled(r.Body)
 		r.Body.Close()
 		if err != nil {
+			writeDubboBodyTooLarge(w, err)
 			return fmt.Errorf("failed to read request body: %w", err)
 		}
-		if len(bodyBytes) > 0 {
-			if err := json.Unmarshal(bodyBytes, &args); err != nil {
-				slog.Warn("dubbo request body is not valid JSON, passing as raw string",
-					slog.String("error", err.Error()),
-				)
-				args = string(bodyBytes)
-			}
+		// A JSON body is embedded without being decoded.
+		switch data := body.Bytes(); {
+		case len(data) == 0:
+		case json.Valid(data):
+			args = json.RawMessage(data)
+		default:
+			slog.Warn("dubbo request body is not valid JSON, passing as raw string")
+			args = string(data)
 		}
 	}
 
@@ -204,49 +379,40 @@ func (u *DubboUpstream) Handle(w http.ResponseWriter, r *http.Request, route *Co
 	inv := dubboInvocation{
 		Interface:  dubboCfg.Interface,
 		Method:     dubboCfg.Method,
-		ParamTypes: dubboCfg.ParamTypes,
+		ParamTypes: paramTypes,
 		Args:       args,
 	}
 
-	encoded, err := json.Marshal(inv)
+	encoded := getBuffer()
+	err = json.NewEncoder(encoded).Encode(inv)
+	if body != nil {
+		putBuffer(body)
+	}
 	if err != nil {
+		putBuffer(encoded)
 		return fmt.Errorf("failed to encode dubbo invocation: %w", err)
 	}
+	encoded.Truncate(encoded.Len() - 1) // Encode ends the value with a newline
 
 	// Set the path for Dubbo triple protocol
 	r.URL.Path = "/" + dubboCfg.Interface + "/" + dubboCfg.Method
 	r.URL.RawPath = ""
 
-	r.Body = io.NopCloser(bytes.NewReader(encoded))
-	r.ContentLength = int64(len(encoded))
+	r.ContentLength = int64(encoded.Len())
+	r.Body = newPooledBody(bytes.NewReader(encoded.Bytes()), encoded)
 	r.Header.Set("Content-Type", "application/json")
 	r.Method = http.MethodPost
 
-	// Set Dubbo-specific headers from cluster config
-	if cluster.Dubbo != nil {
-		if cluster.Dubbo.Group != "" {
-			r.Header.Set("Dubbo-Group", cluster.Dubbo.Group)
-		}
-		if cluster.Dubbo.Version != "" {
-			r.Header.Set("Dubbo-Version", cluster.Dubbo.Version)
-		}
+	// Set Dubbo-specific headers from the route or cluster config
+	if group := dubboServiceGroup(dubboCfg, cluster); group != "" {
+		r.Header.Set("Dubbo-Group", group)
 	}
-
-	proxy := &httputil.ReverseProxy{
-		Rewrite: func(pr *httputil.ProxyRequest) {
-			pr.SetURL(target)
-		},
-		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
-			slog.Error("dubbo proxy error",
-				slog.String("cluster", cluster.Name),
-				slog.String("target", addr),
-				slog.String("error", err.Error()),
-			)
-			http.Error(w, "bad gateway", http.StatusBadGateway)
-		},
+	if version := dubboServiceVersion(dubboCfg, cluster); version != "" {
+		r.Header.Set("Dubbo-Version", version)
 	}
 
-	proxy.ServeHTTP(w, r)
+	call := &proxyCall{route: route, cluster: cluster, target: target, addr: addr}
+	call.serve(dubboProxy, w, r)
 	return nil
 }
 
@@ -257,59 +423,90 @@ type GraphQLUpstream struct{}
 
 // Handle proxies the request to the GraphQL upstream.
 func (u *GraphQLUpstream) Handle(w http.ResponseWriter, r *http.Request, route *CompiledRoute, cluster *CompiledCluster) error {
-	ep, ok := cluster.NextEndpoint()
-	if !ok {
-		return fmt.Errorf("no endpoints available for cluster %s", cluster.Name)
-	}
-
-	addr := EndpointAddress(ep)
-	target, err := url.Parse(addr)
-	if err != nil {
-		return fmt.Errorf("invalid upstream target %s: %w", addr, err)
-	}
-	if target.Scheme == "" {
-		target, err = url.Parse("http://" + addr)
-		if err != nil {
-			return fmt.Errorf("invalid upstream target %s: %w", addr, err)
+	fed := route.GraphQLFederation
+	var target *url.URL
+	var addr string
+	if fed == nil {
+		var err error
+		if target, addr, err = graphqlTarget(cluster); err != nil {
+			return err
 		}
 	}
 
-	// Determine the GraphQL endpoint path
-	gqlPath := "/graphql"
-	if gqlCfg := route.Upstream.GraphQL; gqlCfg != nil && gqlCfg.Endpoint != "" {
-		gqlPath = gqlCfg.Endpoint
+	if subs := route.GraphQLSubscriptions; subs != nil && fed == nil && isWebSocketUpgrade(r) {
+		subs.serve(w, r, route, target, addr)
+		return nil
 	}
 
-	// Rewrite the request path to the GraphQL endpoint
-	r.URL.Path = gqlPath
-	r.URL.RawPath = ""
-
 	// GraphQL over HTTP only supports GET and POST methods
 	if r.Method != http.MethodPost && r.Method != http.MethodGet {
 		return fmt.Errorf("unsupported HTTP method %s for GraphQL upstream (only GET and POST are allowed)", r.Method)
 	}
 
-	// Ensure Content-Type is set for GraphQL
-	if ct := r.Header.Get("Content-Type"); ct == "" {
-		r.Header.Set("Content-Type", "application/json")
+	var call *graphqlCall
+	if gqlCfg := route.Upstream.GraphQL; gqlCfg != nil && gqlCfg.Metrics != nil {
+		rec := &bodyRecorder{ResponseWriter: w, limit: maxGraphQLMetricsResponseBytes}
+		defer func() { observeGraphQL(route, gqlCfg.Metrics, call, rec) }()
+		w = rec
+		// Let the transport negotiate compression so that the response
+		// errors can be inspected.
+		r.Header.Del("Accept-Encoding")
+	}
+
+	call, ok := prepareGraphQLRequest(w, r, route, cluster)
+	if !ok {
+		return nil
+	}
+
+	get := r.Method == http.MethodGet
+	serve := func(w http.ResponseWriter) { fed.serve(w, r, route, call) }
+	if fed == nil {
+		if gqlCfg := route.Upstream.GraphQL; get && gqlCfg != nil && gqlCfg.GetAsPost {
+			if err := graphql.ConvertToPost(r, call.req); err != nil {
+				graphql.WriteError(w, http.StatusBadRequest, graphql.CodeBadRequest, err.Error())
+				return nil
+			}
+		}
+
+		// Determine the GraphQL endpoint path
+		gqlPath := "/graphql"
+		if gqlCfg := route.Upstream.GraphQL; gqlCfg != nil && gqlCfg.Endpoint != "" {
+			gqlPath = gqlCfg.Endpoint
+		}
+
+		// Rewrite the request path to the GraphQL endpoint
+		r.URL.Path = gqlPath
+		r.URL.RawPath = ""
+
+		// Ensure Content-Type is set for GraphQL
+		if ct := r.Header.Get("Content-Type"); ct == "" {
+			r.Header.Set("Content-Type", "application/json")
+		}
+
+		pc := &proxyCall{route: route, cluster: cluster, target: target, addr: addr, host: r.Host}
+		serve = func(w http.ResponseWriter) { pc.serve(graphqlProxy, w, r) }
 	}
 
-	proxy := &httputil.ReverseProxy{
-		Rewrite: func(pr *httputil.ProxyRequest) {
-			pr.SetURL(target)
-			pr.Out.Host = r.Host
-		},
-		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
-			slog.Error("graphql proxy error",
-				slog.String("cluster", cluster.Name),
-				slog.String("target", addr),
-				slog.String("error", err.Error()),
-			)
-			http.Error(w, "bad gateway", http.StatusBadGateway)
-		},
+	if c := route.GraphQLCache; c != nil {
+		if key, ok := c.key(r, call); ok {
+			if c.serve(w, key, get) {
+				return nil
+			}
+			if degraded.Load() {
+				serve(w)
+				return nil
+			}
+			// Let the transport negotiate compression so the cached body
+			// is plain JSON that can be inspected and served to anyone.
+			r.Header.Del("Accept-Encoding")
+			rec := newGraphQLCacheRecorder(w, c)
+			serve(rec)
+			c.store(key, rec)
+			return nil
+		}
 	}
 
-	proxy.ServeHTTP(w, r)
+	serve(w)
 	return nil
 }
 
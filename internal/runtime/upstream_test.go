@@ -1,13 +1,20 @@
 package runtime
 
 import (
+	"encoding/json"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+
 	"github.com/oriys/nexus/internal/config"
+	"github.com/oriys/nexus/internal/transcode"
 )
 
 func TestGraphQLUpstream_Handle(t *testing.T) {
@@ -274,3 +281,287 @@ func TestGraphQLUpstream_SetsContentType(t *testing.T) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 }
+
+// writeUserDescriptorSet writes a FileDescriptorSet for a minimal
+// user.v1.UserService to a temp file and returns its path.
+// newGRPCBackend starts an h2c server, the protocol the gateway's gRPC
+// transport speaks to plaintext endpoints.
+func newGRPCBackend(h http.HandlerFunc) *httptest.Server {
+	srv := httptest.NewUnstartedServer(h)
+	srv.Config.Protocols = new(http.Protocols)
+	srv.Config.Protocols.SetUnencryptedHTTP2(true)
+	srv.Start()
+	return srv
+}
+
+func writeUserDescriptorSet(t *testing.T) string {
+	t.Helper()
+	optional := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum()
+	fd := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("user/v1/user.proto"),
+		Package: proto.String("user.v1"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{
+			{
+				Name: proto.String("GetUserRequest"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					{Name: proto.String("id"), JsonName: proto.String("id"), Number: proto.Int32(1),
+						Type: descriptorpb.FieldDescriptorProto_TYPE_INT64.Enum(), Label: optional},
+				},
+			},
+			{
+				Name: proto.String("User"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					{Name: proto.String("id"), JsonName: proto.String("id"), Number: proto.Int32(1),
+						Type: descriptorpb.FieldDescriptorProto_TYPE_INT64.Enum(), Label: optional},
+					{Name: proto.String("display_name"), JsonName: proto.String("displayName"), Number: proto.Int32(2),
+						Type: descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(), Label: optional},
+				},
+			},
+		},
+		Service: []*descriptorpb.ServiceDescriptorProto{
+			{
+				Name: proto.String("UserService"),
+				Method: []*descriptorpb.MethodDescriptorProto{
+					{Name: proto.String("GetUser"), InputType: proto.String(".user.v1.GetUserRequest"), OutputType: proto.String(".user.v1.User")},
+					{Name: proto.String("SyncUsers"), InputType: proto.String(".user.v1.GetUserRequest"), OutputType: proto.String(".user.v1.User"),
+						ClientStreaming: proto.Bool(true), ServerStreaming: proto.Bool(true)},
+				},
+			},
+		},
+	}
+	data, err := proto.Marshal(&descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{fd}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "user.pb")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func transcodingGRPCConfig(descPath, backendURL string) *config.Config {
+	return &config.Config{
+		ProtoDescriptors: []string{descPath},
+		Clusters: []config.Cluster{
+			{Name: "user-grpc", Type: "grpc", Endpoints: []config.ClusterEndpoint{{URL: backendURL}}},
+		},
+		RoutesV2: []config.RouteV2{
+			{
+				Name:  "get-user",
+				Match: config.RouteMatch{Path: "/api/v1/user/get"},
+				Upstream: config.RouteUpstream{
+					Cluster: "user-grpc",
+					GRPC: &config.RouteUpstreamGRPC{
+						Service:  "user.v1.UserService",
+						Method:   "GetUser",
+						Request:  &config.TranscodeMode{Mode: "json_to_proto", Proto: "user.v1.GetUserRequest"},
+						Response: &config.TranscodeMode{Mode: "proto_to_json"},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestGRPCUpstream_Transcoding(t *testing.T) {
+	descPath := writeUserDescriptorSet(t)
+	reg, err := transcode.LoadRegistry([]string{descPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reqDesc, _ := reg.FindMessage("user.v1.GetUserRequest")
+	respDesc, _ := reg.FindMessage("user.v1.User")
+
+	backend := newGRPCBackend(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/user.v1.UserService/GetUser" {
+			t.Errorf("expected gRPC path, got %s", r.URL.Path)
+		}
+		if ct := r.Header.Get("Content-Type"); ct != "application/grpc+proto" {
+			t.Errorf("expected application/grpc+proto, got %s", ct)
+		}
+		msg, err := transcode.ReadFrame(r.Body)
+		if err != nil {
+			t.Fatalf("read frame: %v", err)
+		}
+		got, err := reg.Codec(reqDesc).ToJSON(msg)
+		if err != nil {
+			t.Fatalf("decode request: %v", err)
+		}
+		if !strings.Contains(string(got), `"id":"42"`) {
+			t.Errorf("unexpected request message %s", got)
+		}
+
+		resp, _ := reg.Codec(respDesc).FromJSON([]byte(`{"id":"42","displayName":"Ada"}`))
+		w.Header().Set("Content-Type", "application/grpc+proto")
+		w.Header().Set("Trailer", "Grpc-Status")
+		w.Write(transcode.Frame(resp))
+		w.Header().Set("Grpc-Status", "0")
+	}))
+	defer backend.Close()
+
+	compiled, err := Compile(transcodingGRPCConfig(descPath, backend.URL), 1)
+	if err != nil {
+		t.Fatalf("compile error: %v", err)
+	}
+	route, _ := compiled.Router.Match(httptest.NewRequest("POST", "/api/v1/user/get", nil))
+	if route == nil || route.GRPCTranscode == nil {
+		t.Fatal("expected transcoding route")
+	}
+
+	req := httptest.NewRequest("POST", "/api/v1/user/get", strings.NewReader(`{"id":42}`))
+	w := httptest.NewRecorder()
+	if err := (&GRPCUpstream{}).Handle(w, req, route, compiled.Clusters["user-grpc"]); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("expected application/json, got %s", ct)
+	}
+	if body := w.Body.String(); !strings.Contains(body, `"displayName":"Ada"`) {
+		t.Errorf("expected JSON response, got %s", body)
+	}
+}
+
+func TestGRPCUpstream_TranscodingRejectsInvalidJSON(t *testing.T) {
+	descPath := writeUserDescriptorSet(t)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("backend should not be called for invalid body")
+	}))
+	defer backend.Close()
+
+	compiled, err := Compile(transcodingGRPCConfig(descPath, backend.URL), 1)
+	if err != nil {
+		t.Fatalf("compile error: %v", err)
+	}
+	route, _ := compiled.Router.Match(httptest.NewRequest("POST", "/api/v1/user/get", nil))
+
+	req := httptest.NewRequest("POST", "/api/v1/user/get", strings.NewReader(`{"id":"abc","nickname":"x"}`))
+	w := httptest.NewRecorder()
+	if err := (&GRPCUpstream{}).Handle(w, req, route, compiled.Clusters["user-grpc"]); err == nil {
+		t.Fatal("expected transcoding error")
+	}
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", w.Code)
+	}
+
+	var body struct {
+		Error struct {
+			Status  string `json:"status"`
+			Details []struct {
+				Type            string `json:"@type"`
+				FieldViolations []struct {
+					Field       string `json:"field"`
+					Description string `json:"description"`
+				} `json:"fieldViolations"`
+			} `json:"details"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("expected JSON error body, got %q", w.Body.String())
+	}
+	if body.Error.Status != "INVALID_ARGUMENT" || len(body.Error.Details) != 1 {
+		t.Fatalf("unexpected error body %s", w.Body.String())
+	}
+	violations := body.Error.Details[0].FieldViolations
+	if len(violations) != 2 || violations[0].Field != "id" || violations[1].Field != "nickname" {
+		t.Errorf("expected violations for id and nickname, got %+v", violations)
+	}
+}
+
+func TestGRPCUpstream_StreamingTranscoding(t *testing.T) {
+	descPath := writeUserDescriptorSet(t)
+	reg, err := transcode.LoadRegistry([]string{descPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reqDesc, _ := reg.FindMessage("user.v1.GetUserRequest")
+	respDesc, _ := reg.FindMessage("user.v1.User")
+
+	// The backend echoes every request message as a User.
+	backend := newGRPCBackend(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/user.v1.UserService/SyncUsers" {
+			t.Errorf("expected SyncUsers path, got %s", r.URL.Path)
+		}
+		http.NewResponseController(w).EnableFullDuplex()
+		w.Header().Set("Content-Type", "application/grpc+proto")
+		for {
+			msg, err := transcode.ReadFrame(r.Body)
+			if err != nil {
+				return
+			}
+			in, _ := reg.Codec(reqDesc).ToJSON(msg)
+			var id string
+			if strings.Contains(string(in), `"2"`) {
+				id = "2"
+			} else {
+				id = "1"
+			}
+			out, _ := reg.Codec(respDesc).FromJSON([]byte(`{"id":"` + id + `","displayName":"user-` + id + `"}`))
+			w.Write(transcode.Frame(out))
+			w.(http.Flusher).Flush()
+		}
+	}))
+	defer backend.Close()
+
+	cfg := transcodingGRPCConfig(descPath, backend.URL)
+	cfg.RoutesV2[0].Upstream.GRPC.Method = "SyncUsers"
+	cfg.RoutesV2[0].Upstream.GRPC.Request.Proto = ""
+	compiled, err := Compile(cfg, 1)
+	if err != nil {
+		t.Fatalf("compile error: %v", err)
+	}
+	route, _ := compiled.Router.Match(httptest.NewRequest("POST", "/api/v1/user/get", nil))
+	if !route.GRPCTranscode.ClientStreaming || !route.GRPCTranscode.ServerStreaming {
+		t.Fatal("expected bidi streaming route")
+	}
+
+	req := httptest.NewRequest("POST", "/api/v1/user/get", strings.NewReader("{\"id\":1}\n{\"id\":2}\n"))
+	w := httptest.NewRecorder()
+	if err := (&GRPCUpstream{}).Handle(w, req, route, compiled.Clusters["user-grpc"]); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if ct := w.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Errorf("expected application/x-ndjson, got %s", ct)
+	}
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 streamed messages, got %d: %q", len(lines), w.Body.String())
+	}
+	if !strings.Contains(lines[1], "user-2") {
+		t.Errorf("unexpected second message %s", lines[1])
+	}
+}
+
+func TestCompile_GRPCTranscodingErrors(t *testing.T) {
+	descPath := writeUserDescriptorSet(t)
+
+	cfg := transcodingGRPCConfig(descPath, "http://localhost:9090")
+	cfg.ProtoDescriptors = nil
+	if _, err := Compile(cfg, 1); err == nil || !strings.Contains(err.Error(), "proto_descriptors") {
+		t.Errorf("expected missing descriptors error, got %v", err)
+	}
+
+	cfg = transcodingGRPCConfig(descPath, "http://localhost:9090")
+	cfg.RoutesV2[0].Upstream.GRPC.Method = "DeleteUser"
+	if _, err := Compile(cfg, 1); err == nil || !strings.Contains(err.Error(), "DeleteUser") {
+		t.Errorf("expected unknown method error, got %v", err)
+	}
+
+	cfg = transcodingGRPCConfig(descPath, "http://localhost:9090")
+	cfg.RoutesV2[0].Upstream.GRPC.Request.Proto = "user.v1.User"
+	if _, err := Compile(cfg, 1); err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Errorf("expected input type mismatch error, got %v", err)
+	}
+
+	cfg = transcodingGRPCConfig(descPath, "http://localhost:9090")
+	cfg.ProtoDescriptors = []string{filepath.Join(t.TempDir(), "missing.pb")}
+	if _, err := Compile(cfg, 1); err == nil {
+		t.Error("expected error for missing descriptor file")
+	}
+}
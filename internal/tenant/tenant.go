@@ -0,0 +1,226 @@
+// Package tenant labels requests with the tenant they belong to, from a
+// claim of the authenticated caller or a request header, and partitions
+// rate limits and metrics by tenant.
+package tenant
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/oriys/nexus/internal/auth"
+	"github.com/oriys/nexus/internal/config"
+	"github.com/oriys/nexus/internal/promtext"
+	"github.com/oriys/nexus/internal/ratelimit"
+)
+
+// Other is the metrics label of the tenants not configured, when some are.
+const Other = "other"
+
+// Tenancy resolves and limits the tenants of requests.
+type Tenancy struct {
+	header, claim, def string
+	// known holds the configured tenants; nil labels every tenant in
+	// metrics.
+	known  map[string]bool
+	limit  *limit
+	limits map[string]*limit
+}
+
+type limit struct {
+	limiter *ratelimit.ShardedSlidingWindowLimiter
+	window  time.Duration
+}
+
+func newLimit(l *config.TenantRateLimit) *limit {
+	if l == nil {
+		return nil
+	}
+	return &limit{ratelimit.NewLimiter(l.Rate, l.WindowDuration()), l.WindowDuration()}
+}
+
+// New compiles the tenancy settings cfg.
+func New(cfg *config.Tenancy) *Tenancy {
+	t := &Tenancy{
+		header: cfg.Header,
+		claim:  cfg.Claim,
+		def:    cfg.Default,
+		limit:  newLimit(cfg.RateLimit),
+		limits: make(map[string]*limit),
+	}
+	if len(cfg.Tenants) > 0 {
+		t.known = make(map[string]bool, len(cfg.Tenants))
+	}
+	for _, tn := range cfg.Tenants {
+		t.known[tn.Name] = true
+		if l := newLimit(tn.RateLimit); l != nil {
+			t.limits[tn.Name] = l
+		}
+	}
+	return t
+}
+
+// Settings keeps the Tenancy compiled from the current configuration. It
+// is compiled again only when the settings change, so that rate limit
+// windows survive reloads that leave them alone.
+type Settings struct {
+	current atomic.Pointer[compiled]
+}
+
+type compiled struct {
+	cfg *config.Tenancy
+	t   *Tenancy
+}
+
+// For returns the Tenancy of cfg, or nil when cfg is nil. It takes no lock
+// while cfg is the settings it was last called with, as it runs for every
+// request.
+func (s *Settings) For(cfg *config.Tenancy) *Tenancy {
+	if cfg == nil {
+		return nil
+	}
+	cur := s.current.Load()
+	if cur != nil && cur.cfg == cfg {
+		return cur.t
+	}
+	next := &compiled{cfg: cfg}
+	if cur != nil && reflect.DeepEqual(cur.cfg, cfg) {
+		next.t = cur.t
+	} else {
+		next.t = New(cfg)
+	}
+	if !s.current.CompareAndSwap(cur, next) {
+		// Another request compiled the settings first; share its limits.
+		if won := s.current.Load(); won.cfg == cfg {
+			return won.t
+		}
+	}
+	return next.t
+}
+
+// Resolve returns the tenant of r: the claim of its authenticated caller,
+// then the header, then the default. It is empty when r has none.
+func (t *Tenancy) Resolve(r *http.Request) string {
+	if t.claim != "" {
+		if id := auth.GetIdentity(r.Context()); id != nil {
+			if v, ok := id.Claims[t.claim].(string); ok && v != "" {
+				return v
+			}
+		}
+	}
+	if t.header != "" {
+		if v := r.Header.Get(t.header); v != "" {
+			return v
+		}
+	}
+	return t.def
+}
+
+// Allow reports whether another request of tenant is within its rate
+// limit, and otherwise how long its window is.
+func (t *Tenancy) Allow(tenant string) (bool, time.Duration) {
+	l := t.limits[tenant]
+	if l == nil {
+		l = t.limit
+	}
+	if l == nil || l.limiter.Allow(tenant) {
+		return true, 0
+	}
+	metrics.observe(t.label(tenant), func(s *stats) { s.limited++ })
+	return false, l.window
+}
+
+// Observe records a response of tenant with status, served in d.
+func (t *Tenancy) Observe(tenant string, status int, d time.Duration) {
+	class := strconv.Itoa(status/100) + "xx"
+	metrics.observe(t.label(tenant), func(s *stats) {
+		s.responses[class]++
+		s.seconds += d.Seconds()
+	})
+}
+
+// label returns the metrics label of tenant, bounding the series of
+// gateways whose tenants are configured.
+func (t *Tenancy) label(tenant string) string {
+	if t.known != nil && !t.known[tenant] {
+		return Other
+	}
+	return tenant
+}
+
+// metrics holds the metrics of all tenants. It lives as long as the process
+// so that counters survive config reloads.
+var metrics = &metricSet{values: make(map[string]*stats)}
+
+type metricSet struct {
+	mu     sync.Mutex
+	values map[string]*stats
+}
+
+type stats struct {
+	responses map[string]uint64 // by status class
+	seconds   float64
+	limited   uint64
+}
+
+func (m *metricSet) observe(tenant string, update func(*stats)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	st, ok := m.values[tenant]
+	if !ok {
+		st = &stats{responses: make(map[string]uint64)}
+		m.values[tenant] = st
+	}
+	update(st)
+}
+
+// WriteMetrics writes the tenant metrics in the Prometheus text exposition
+// format.
+func WriteMetrics(w io.Writer) error {
+	m := metrics
+	m.mu.Lock()
+	tenants := make([]string, 0, len(m.values))
+	for tenant := range m.values {
+		tenants = append(tenants, tenant)
+	}
+	sort.Strings(tenants)
+	all := make([]stats, len(tenants))
+	for i, tenant := range tenants {
+		st := *m.values[tenant]
+		st.responses = make(map[string]uint64, len(st.responses))
+		for class, n := range m.values[tenant].responses {
+			st.responses[class] = n
+		}
+		all[i] = st
+	}
+	m.mu.Unlock()
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "# HELP nexus_tenant_responses_total Responses to tenants, by tenant and status class.\n# TYPE nexus_tenant_responses_total counter\n")
+	for i, tenant := range tenants {
+		classes := make([]string, 0, len(all[i].responses))
+		for class := range all[i].responses {
+			classes = append(classes, class)
+		}
+		sort.Strings(classes)
+		for _, class := range classes {
+			fmt.Fprintf(bw, "nexus_tenant_responses_total{tenant=%s,code=%s} %d\n", promtext.QuoteLabel(tenant), promtext.QuoteLabel(class), all[i].responses[class])
+		}
+	}
+	fmt.Fprintf(bw, "# HELP nexus_tenant_seconds_total Time spent serving tenants, by tenant.\n# TYPE nexus_tenant_seconds_total counter\n")
+	for i, tenant := range tenants {
+		fmt.Fprintf(bw, "nexus_tenant_seconds_total{tenant=%s} %s\n", promtext.QuoteLabel(tenant), strconv.FormatFloat(all[i].seconds, 'g', -1, 64))
+	}
+	fmt.Fprintf(bw, "# HELP nexus_tenant_rate_limited_total Requests rejected by the rate limit of their tenant, by tenant.\n# TYPE nexus_tenant_rate_limited_total counter\n")
+	for i, tenant := range tenants {
+		fmt.Fprintf(bw, "nexus_tenant_rate_limited_total{tenant=%s} %d\n", promtext.QuoteLabel(tenant), all[i].limited)
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,117 @@
+package tenant
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oriys/nexus/internal/auth"
+	"github.com/oriys/nexus/internal/config"
+)
+
+func TestTenancy_Resolve(t *testing.T) {
+	tn := New(&config.Tenancy{Header: "X-Tenant", Claim: "tenant", Default: "public"})
+	tests := []struct {
+		name   string
+		header string
+		claims map[string]any
+		want   string
+	}{
+		{"header", "acme", nil, "acme"},
+		{"claim over header", "acme", map[string]any{"tenant": "globex"}, "globex"},
+		{"non-string claim", "acme", map[string]any{"tenant": 7}, "acme"},
+		{"default", "", map[string]any{}, "public"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			if tt.header != "" {
+				r.Header.Set("X-Tenant", tt.header)
+			}
+			if tt.claims != nil {
+				r = r.WithContext(auth.IdentityToContext(r.Context(), &auth.Identity{Subject: "u", Claims: tt.claims}))
+			}
+			if got := tn.Resolve(r); got != tt.want {
+				t.Errorf("Resolve = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTenancy_Allow(t *testing.T) {
+	tn := New(&config.Tenancy{
+		Header:    "X-Tenant",
+		RateLimit: &config.TenantRateLimit{Rate: 1, Window: "30s"},
+		Tenants: []config.Tenant{
+			{Name: "acme", RateLimit: &config.TenantRateLimit{Rate: 3}},
+			{Name: "initech"},
+		},
+	})
+	allowed := func(tenant string, n int) int {
+		var ok int
+		for i := 0; i < n; i++ {
+			if allow, _ := tn.Allow(tenant); allow {
+				ok++
+			}
+		}
+		return ok
+	}
+	if n := allowed("acme", 5); n != 3 {
+		t.Errorf("acme: %d allowed, want its own limit of 3", n)
+	}
+	if n := allowed("initech", 5); n != 1 {
+		t.Errorf("initech: %d allowed, want the default limit of 1", n)
+	}
+	// Limits are kept per tenant, not shared.
+	if n := allowed("umbrella", 2); n != 1 {
+		t.Errorf("umbrella: %d allowed, want 1", n)
+	}
+	if _, window := tn.Allow("initech"); window != 30*time.Second {
+		t.Errorf("window %s", window)
+	}
+
+	tn.Observe("acme", 201, time.Second)
+	var b strings.Builder
+	if err := WriteMetrics(&b); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`nexus_tenant_responses_total{tenant="acme",code="2xx"} 1`,
+		`nexus_tenant_rate_limited_total{tenant="acme"} 2`,
+		`nexus_tenant_rate_limited_total{tenant="initech"} 5`,
+		`nexus_tenant_rate_limited_total{tenant="other"} 1`,
+	} {
+		if !strings.Contains(b.String(), want+"\n") {
+			t.Errorf("metrics lack %s:\n%s", want, b.String())
+		}
+	}
+}
+
+func TestSettings_For(t *testing.T) {
+	var s Settings
+	if s.For(nil) != nil {
+		t.Fatal("tenancy without settings")
+	}
+	cfg := &config.Tenancy{Header: "X-Tenant", RateLimit: &config.TenantRateLimit{Rate: 1}}
+	first := s.For(cfg)
+	if first == nil || s.For(cfg) != first {
+		t.Fatal("same settings compiled again")
+	}
+	// A reload that leaves the settings alone keeps the rate limit windows.
+	first.Allow("globex")
+	same := &config.Tenancy{Header: "X-Tenant", RateLimit: &config.TenantRateLimit{Rate: 1}}
+	if s.For(same) != first {
+		t.Fatal("equal settings compiled again")
+	}
+	if allow, _ := s.For(same).Allow("globex"); allow {
+		t.Error("rate limit window reset by an unchanged reload")
+	}
+	changed := &config.Tenancy{Header: "X-Tenant", RateLimit: &config.TenantRateLimit{Rate: 2}}
+	if s.For(changed) == first {
+		t.Fatal("changed settings not compiled")
+	}
+	if s.For(nil) != nil {
+		t.Error("tenancy kept after it was removed")
+	}
+}
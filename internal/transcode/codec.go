@@ -0,0 +1,54 @@
+package transcode
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// Codec converts a single protobuf message type between its JSON mapping and
+// the binary wire format.
+type Codec struct {
+	desc  protoreflect.MessageDescriptor
+	types *dynamicpb.Types
+}
+
+// Descriptor returns the message descriptor handled by the codec.
+func (c *Codec) Descriptor() protoreflect.MessageDescriptor {
+	return c.desc
+}
+
+// FromJSON decodes a JSON document into the message and returns its binary
+// encoding. An empty body is treated as the empty message.
+func (c *Codec) FromJSON(data []byte) ([]byte, error) {
+	msg := dynamicpb.NewMessage(c.desc)
+	if len(data) > 0 {
+		opts := protojson.UnmarshalOptions{Resolver: c.types}
+		if err := opts.Unmarshal(data, msg); err != nil {
+			return nil, fmt.Errorf("decode %s from JSON: %w", c.desc.FullName(), err)
+		}
+	}
+	out, err := proto.Marshal(msg)
+	if err != nil {
+		return nil, fmt.Errorf("encode %s: %w", c.desc.FullName(), err)
+	}
+	return out, nil
+}
+
+// ToJSON decodes a binary message and returns its JSON encoding. Field names
+// use the proto JSON (lowerCamelCase) mapping.
+func (c *Codec) ToJSON(data []byte) ([]byte, error) {
+	msg := dynamicpb.NewMessage(c.desc)
+	opts := proto.UnmarshalOptions{Resolver: c.types}
+	if err := opts.Unmarshal(data, msg); err != nil {
+		return nil, fmt.Errorf("decode %s: %w", c.desc.FullName(), err)
+	}
+	out, err := protojson.MarshalOptions{Resolver: c.types}.Marshal(msg)
+	if err != nil {
+		return nil, fmt.Errorf("encode %s to JSON: %w", c.desc.FullName(), err)
+	}
+	return out, nil
+}
@@ -0,0 +1,131 @@
+package transcode
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// FrameHeaderLen is the size of the gRPC length-prefixed message header:
+// 1 byte compressed flag + 4 bytes big-endian message length.
+const FrameHeaderLen = 5
+
+// Frame wraps msg in gRPC length-prefixed message framing.
+func Frame(msg []byte) []byte {
+	out := make([]byte, FrameHeaderLen+len(msg))
+	out[0] = 0 // not compressed
+	binary.BigEndian.PutUint32(out[1:FrameHeaderLen], uint32(len(msg)))
+	copy(out[FrameHeaderLen:], msg)
+	return out
+}
+
+// NewFrameReader returns a reader of msg, a message of n bytes, in gRPC
+// length-prefixed message framing. The message is streamed rather than
+// copied into a framed buffer.
+func NewFrameReader(msg io.Reader, n int) io.Reader {
+	fr := &frameReader{msg: msg}
+	binary.BigEndian.PutUint32(fr.hdr[1:], uint32(n))
+	return fr
+}
+
+type frameReader struct {
+	hdr  [FrameHeaderLen]byte
+	sent int // header bytes read so far
+	msg  io.Reader
+}
+
+func (f *frameReader) Read(p []byte) (int, error) {
+	if f.sent < FrameHeaderLen {
+		n := copy(p, f.hdr[f.sent:])
+		f.sent += n
+		return n, nil
+	}
+	return f.msg.Read(p)
+}
+
+// SplitFrame returns the message of the length-prefixed frame at the start
+// of b without copying it.
+func SplitFrame(b []byte) ([]byte, error) {
+	if len(b) < FrameHeaderLen {
+		return nil, fmt.Errorf("truncated gRPC frame header")
+	}
+	if b[0] != 0 {
+		return nil, fmt.Errorf("compressed gRPC frames are not supported")
+	}
+	n := binary.BigEndian.Uint32(b[1:FrameHeaderLen])
+	if uint64(len(b)-FrameHeaderLen) < uint64(n) {
+		return nil, fmt.Errorf("truncated gRPC frame: %w", io.ErrUnexpectedEOF)
+	}
+	return b[FrameHeaderLen : FrameHeaderLen+int(n)], nil
+}
+
+// ReadFrame reads one length-prefixed message from r. It returns io.EOF when
+// r is exhausted before a new frame starts. Compressed frames are rejected
+// because the gateway does not negotiate a grpc-encoding with upstreams.
+func ReadFrame(r io.Reader) ([]byte, error) {
+	var hdr [FrameHeaderLen]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, fmt.Errorf("truncated gRPC frame header")
+		}
+		return nil, err
+	}
+	if hdr[0] != 0 {
+		return nil, fmt.Errorf("compressed gRPC frames are not supported")
+	}
+	n := binary.BigEndian.Uint32(hdr[1:])
+	msg := make([]byte, n)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, fmt.Errorf("truncated gRPC frame: %w", err)
+	}
+	return msg, nil
+}
+
+// ErrMessageTooLarge is returned by a LimitFrames reader when a message
+// exceeds the configured maximum size.
+var ErrMessageTooLarge = errors.New("gRPC message exceeds maximum size")
+
+// LimitFrames returns a reader that passes framed gRPC messages through
+// unchanged but fails with ErrMessageTooLarge as soon as a frame header
+// announces a message longer than max bytes.
+func LimitFrames(rc io.ReadCloser, max int) io.ReadCloser {
+	return &frameLimiter{ReadCloser: rc, max: uint32(max)}
+}
+
+type frameLimiter struct {
+	io.ReadCloser
+	max       uint32
+	hdr       [FrameHeaderLen]byte
+	hdrLen    int    // bytes of the current header seen so far
+	remaining uint32 // message bytes left in the current frame
+	err       error
+}
+
+func (l *frameLimiter) Read(p []byte) (int, error) {
+	if l.err != nil {
+		return 0, l.err
+	}
+	n, err := l.ReadCloser.Read(p)
+	for b := p[:n]; len(b) > 0; {
+		if l.remaining > 0 {
+			skip := min(uint32(len(b)), l.remaining)
+			l.remaining -= skip
+			b = b[skip:]
+			continue
+		}
+		c := copy(l.hdr[l.hdrLen:], b)
+		l.hdrLen += c
+		b = b[c:]
+		if l.hdrLen < FrameHeaderLen {
+			continue
+		}
+		l.hdrLen = 0
+		l.remaining = binary.BigEndian.Uint32(l.hdr[1:])
+		if l.remaining > l.max {
+			l.err = fmt.Errorf("%w: %d > %d bytes", ErrMessageTooLarge, l.remaining, l.max)
+			return 0, l.err
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,351 @@
+package transcode
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// Reflection service paths, newest first. Servers that only register the
+// v1alpha service answer the v1 path with Unimplemented.
+var reflectionPaths = []string{
+	"/grpc.reflection.v1.ServerReflection/ServerReflectionInfo",
+	"/grpc.reflection.v1alpha.ServerReflection/ServerReflectionInfo",
+}
+
+// ServerReflectionRequest / ServerReflectionResponse field numbers. The
+// message layout is identical between v1 and v1alpha.
+const (
+	reflReqFileByFilename       protowire.Number = 3
+	reflReqFileContainingSymbol protowire.Number = 4
+	reflReqListServices         protowire.Number = 7
+
+	reflRespFileDescriptor protowire.Number = 4
+	reflRespListServices   protowire.Number = 6
+	reflRespError          protowire.Number = 7
+)
+
+// errReflectionUnimplemented is returned when the server does not implement
+// the requested reflection service version.
+var errReflectionUnimplemented = errors.New("reflection service unimplemented")
+
+// Discovery is the result of querying a server's reflection service.
+type Discovery struct {
+	// Services lists the fully qualified service names advertised by the server,
+	// excluding the reflection service itself.
+	Services []string
+	// Files holds the file descriptors describing those services and all of
+	// their transitive imports.
+	Files []*descriptorpb.FileDescriptorProto
+}
+
+// Discover queries the gRPC server reflection service at baseURL and returns
+// the advertised services along with the descriptors needed to describe them.
+// client must be able to speak HTTP/2 to the target.
+func Discover(ctx context.Context, client *http.Client, baseURL string) (*Discovery, error) {
+	var lastErr error
+	for _, path := range reflectionPaths {
+		d, err := discover(ctx, client, strings.TrimSuffix(baseURL, "/")+path)
+		if errors.Is(err, errReflectionUnimplemented) {
+			lastErr = err
+			continue
+		}
+		return d, err
+	}
+	return nil, lastErr
+}
+
+func discover(ctx context.Context, client *http.Client, url string) (*Discovery, error) {
+	s, err := openReflectionStream(ctx, client, url)
+	if err != nil {
+		return nil, err
+	}
+	defer s.close()
+
+	services, err := s.listServices()
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]*descriptorpb.FileDescriptorProto)
+	for _, svc := range services {
+		fds, err := s.query(reflReqFileContainingSymbol, svc)
+		if err != nil {
+			return nil, fmt.Errorf("resolve service %s: %w", svc, err)
+		}
+		for _, fd := range fds {
+			files[fd.GetName()] = fd
+		}
+	}
+
+	// Servers are expected to send transitive dependencies along with the
+	// requested file, but some only send the file itself. Fetch anything missing.
+	for {
+		var missing []string
+		for _, fd := range files {
+			for _, dep := range fd.GetDependency() {
+				if _, ok := files[dep]; !ok {
+					missing = append(missing, dep)
+				}
+			}
+		}
+		if len(missing) == 0 {
+			break
+		}
+		for _, name := range missing {
+			if _, ok := files[name]; ok {
+				continue
+			}
+			fds, err := s.query(reflReqFileByFilename, name)
+			if err != nil {
+				return nil, fmt.Errorf("resolve file %s: %w", name, err)
+			}
+			for _, fd := range fds {
+				files[fd.GetName()] = fd
+			}
+			if _, ok := files[name]; !ok {
+				return nil, fmt.Errorf("server did not return file %s", name)
+			}
+		}
+	}
+
+	d := &Discovery{Services: services}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		d.Files = append(d.Files, files[name])
+	}
+	return d, nil
+}
+
+// reflectionStream is a bidirectional ServerReflectionInfo stream. Requests
+// and responses are exchanged strictly one at a time.
+type reflectionStream struct {
+	pw     *io.PipeWriter
+	resp   *http.Response
+	cancel context.CancelFunc
+}
+
+func openReflectionStream(ctx context.Context, client *http.Client, url string) (*reflectionStream, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	pr, pw := io.Pipe()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, pr)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/grpc+proto")
+	req.Header.Set("TE", "trailers")
+
+	s := &reflectionStream{pw: pw, cancel: cancel}
+
+	// The server may hold its response headers until it sees the first
+	// request, so the list_services request is written before Do returns.
+	first := make(chan error, 1)
+	go func() {
+		_, err := pw.Write(Frame(reflectionRequest(reflReqListServices, "*")))
+		first <- err
+	}()
+
+	resp, err := client.Do(req)
+	if err != nil {
+		pw.Close()
+		cancel()
+		return nil, fmt.Errorf("open reflection stream: %w", err)
+	}
+	s.resp = resp
+	if resp.StatusCode == http.StatusNotFound || grpcStatus(resp.Header) == "12" {
+		s.close()
+		return nil, errReflectionUnimplemented
+	}
+	if resp.StatusCode != http.StatusOK {
+		s.close()
+		return nil, fmt.Errorf("reflection stream: unexpected HTTP status %d", resp.StatusCode)
+	}
+	if err := <-first; err != nil {
+		s.close()
+		return nil, fmt.Errorf("write reflection request: %w", err)
+	}
+	return s, nil
+}
+
+func (s *reflectionStream) close() {
+	s.pw.Close()
+	if s.resp != nil {
+		s.resp.Body.Close()
+	}
+	s.cancel()
+}
+
+// recv reads the next response message.
+func (s *reflectionStream) recv() ([]byte, error) {
+	msg, err := ReadFrame(s.resp.Body)
+	if err == io.EOF {
+		// The stream ended; report the gRPC status from the trailers.
+		if grpcStatus(s.resp.Trailer) == "12" {
+			return nil, errReflectionUnimplemented
+		}
+		return nil, fmt.Errorf("reflection stream closed: grpc-status %s %s",
+			grpcStatus(s.resp.Trailer), s.resp.Trailer.Get("Grpc-Message"))
+	}
+	return msg, err
+}
+
+// listServices reads the response to the list_services request sent when the
+// stream was opened.
+func (s *reflectionStream) listServices() ([]string, error) {
+	msg, err := s.recv()
+	if err != nil {
+		return nil, err
+	}
+	body, err := reflectionResponseField(msg, reflRespListServices)
+	if err != nil {
+		return nil, err
+	}
+
+	var services []string
+	err = rangeFields(body, func(num protowire.Number, v []byte) error {
+		if num != 1 { // ListServiceResponse.service
+			return nil
+		}
+		return rangeFields(v, func(num protowire.Number, name []byte) error {
+			if num == 1 && !strings.HasPrefix(string(name), "grpc.reflection.") {
+				services = append(services, string(name))
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(services)
+	return services, nil
+}
+
+// query sends a file descriptor request and returns the files in the response.
+func (s *reflectionStream) query(field protowire.Number, value string) ([]*descriptorpb.FileDescriptorProto, error) {
+	if _, err := s.pw.Write(Frame(reflectionRequest(field, value))); err != nil {
+		return nil, fmt.Errorf("write reflection request: %w", err)
+	}
+	msg, err := s.recv()
+	if err != nil {
+		return nil, err
+	}
+	body, err := reflectionResponseField(msg, reflRespFileDescriptor)
+	if err != nil {
+		return nil, err
+	}
+
+	var fds []*descriptorpb.FileDescriptorProto
+	err = rangeFields(body, func(num protowire.Number, v []byte) error {
+		if num != 1 { // FileDescriptorResponse.file_descriptor_proto
+			return nil
+		}
+		fd := &descriptorpb.FileDescriptorProto{}
+		if err := proto.Unmarshal(v, fd); err != nil {
+			return fmt.Errorf("parse file descriptor: %w", err)
+		}
+		fds = append(fds, fd)
+		return nil
+	})
+	return fds, err
+}
+
+func reflectionRequest(field protowire.Number, value string) []byte {
+	b := protowire.AppendTag(nil, field, protowire.BytesType)
+	return protowire.AppendString(b, value)
+}
+
+// reflectionResponseField extracts the expected oneof field from a
+// ServerReflectionResponse, converting an error_response into an error.
+func reflectionResponseField(msg []byte, want protowire.Number) ([]byte, error) {
+	var found []byte
+	var errResp []byte
+	err := rangeFields(msg, func(num protowire.Number, v []byte) error {
+		switch num {
+		case want:
+			found = v
+		case reflRespError:
+			errResp = v
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if errResp != nil {
+		var code uint64
+		var message string
+		for len(errResp) > 0 {
+			num, typ, n := protowire.ConsumeTag(errResp)
+			if n < 0 {
+				break
+			}
+			errResp = errResp[n:]
+			switch {
+			case num == 1 && typ == protowire.VarintType:
+				code, n = protowire.ConsumeVarint(errResp)
+			case num == 2 && typ == protowire.BytesType:
+				var b []byte
+				b, n = protowire.ConsumeBytes(errResp)
+				message = string(b)
+			default:
+				n = protowire.ConsumeFieldValue(num, typ, errResp)
+			}
+			if n < 0 {
+				break
+			}
+			errResp = errResp[n:]
+		}
+		return nil, fmt.Errorf("reflection error %d: %s", code, message)
+	}
+	if found == nil {
+		return nil, fmt.Errorf("reflection response missing field %d", want)
+	}
+	return found, nil
+}
+
+// rangeFields calls fn for every length-delimited field in a message,
+// skipping fields of other wire types.
+func rangeFields(b []byte, fn func(num protowire.Number, v []byte) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return fmt.Errorf("malformed reflection message: %w", protowire.ParseError(n))
+		}
+		b = b[n:]
+		if typ != protowire.BytesType {
+			n = protowire.ConsumeFieldValue(num, typ, b)
+			if n < 0 {
+				return fmt.Errorf("malformed reflection message: %w", protowire.ParseError(n))
+			}
+			b = b[n:]
+			continue
+		}
+		v, n := protowire.ConsumeBytes(b)
+		if n < 0 {
+			return fmt.Errorf("malformed reflection message: %w", protowire.ParseError(n))
+		}
+		b = b[n:]
+		if err := fn(num, bytes.Clone(v)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func grpcStatus(h http.Header) string {
+	return h.Get("Grpc-Status")
+}
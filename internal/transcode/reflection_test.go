@@ -0,0 +1,158 @@
+package transcode
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// newReflectionServer starts an h2c server implementing the v1alpha
+// reflection service for the given files. The v1 service answers
+// Unimplemented so the fallback path is exercised.
+func newReflectionServer(t *testing.T, services []string, files ...*descriptorpb.FileDescriptorProto) *httptest.Server {
+	t.Helper()
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, ".v1.") {
+			w.Header().Set("Grpc-Status", "12")
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.Header().Set("Content-Type", "application/grpc+proto")
+		w.Header().Set("Trailer", "Grpc-Status")
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+
+		for {
+			req, err := ReadFrame(r.Body)
+			if err != nil {
+				break
+			}
+			num, _, n := protowire.ConsumeTag(req)
+			value, _ := protowire.ConsumeString(req[n:])
+
+			var resp []byte
+			switch num {
+			case reflReqListServices:
+				var list []byte
+				for _, svc := range append(services, "grpc.reflection.v1alpha.ServerReflection") {
+					entry := protowire.AppendTag(nil, 1, protowire.BytesType)
+					entry = protowire.AppendString(entry, svc)
+					list = protowire.AppendTag(list, 1, protowire.BytesType)
+					list = protowire.AppendBytes(list, entry)
+				}
+				resp = protowire.AppendTag(resp, reflRespListServices, protowire.BytesType)
+				resp = protowire.AppendBytes(resp, list)
+			case reflReqFileContainingSymbol, reflReqFileByFilename:
+				var match *descriptorpb.FileDescriptorProto
+				for _, fd := range files {
+					if fd.GetName() == value || strings.HasPrefix(value, fd.GetPackage()+".") {
+						match = fd
+					}
+				}
+				if match == nil {
+					var e []byte
+					e = protowire.AppendTag(e, 1, protowire.VarintType)
+					e = protowire.AppendVarint(e, 5)
+					e = protowire.AppendTag(e, 2, protowire.BytesType)
+					e = protowire.AppendString(e, "not found: "+value)
+					resp = protowire.AppendTag(resp, reflRespError, protowire.BytesType)
+					resp = protowire.AppendBytes(resp, e)
+					break
+				}
+				// Only the file itself is returned; dependencies are
+				// fetched by name.
+				b, _ := proto.Marshal(match)
+				fdr := protowire.AppendTag(nil, 1, protowire.BytesType)
+				fdr = protowire.AppendBytes(fdr, b)
+				resp = protowire.AppendTag(resp, reflRespFileDescriptor, protowire.BytesType)
+				resp = protowire.AppendBytes(resp, fdr)
+			}
+			w.Write(Frame(resp))
+			w.(http.Flusher).Flush()
+		}
+		w.Header().Set("Grpc-Status", "0")
+	})
+
+	srv := httptest.NewUnstartedServer(h)
+	srv.Config.Protocols = new(http.Protocols)
+	srv.Config.Protocols.SetUnencryptedHTTP2(true)
+	srv.Start()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func h2cClient() *http.Client {
+	var p http.Protocols
+	p.SetUnencryptedHTTP2(true)
+	return &http.Client{Transport: &http.Transport{Protocols: &p}}
+}
+
+func TestDiscover(t *testing.T) {
+	common := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("common/v1/common.proto"),
+		Package: proto.String("common.v1"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{
+			{Name: proto.String("Empty")},
+		},
+	}
+	user := testFileDescriptor()
+	user.Dependency = []string{"common/v1/common.proto"}
+
+	srv := newReflectionServer(t, []string{"user.v1.UserService"}, user, common)
+
+	d, err := Discover(context.Background(), h2cClient(), srv.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(d.Services) != 1 || d.Services[0] != "user.v1.UserService" {
+		t.Errorf("expected [user.v1.UserService], got %v", d.Services)
+	}
+	if len(d.Files) != 2 {
+		t.Fatalf("expected service file and its dependency, got %d files", len(d.Files))
+	}
+
+	reg := NewRegistry()
+	if err := reg.AddFiles(d.Files...); err != nil {
+		t.Fatalf("discovered files should link: %v", err)
+	}
+	if _, err := reg.FindMethod("user.v1.UserService", "GetUser"); err != nil {
+		t.Errorf("expected discovered method to resolve: %v", err)
+	}
+}
+
+func TestDiscover_ErrorResponse(t *testing.T) {
+	// The service is advertised but the server cannot describe it.
+	srv := newReflectionServer(t, []string{"billing.v1.Billing"})
+
+	_, err := Discover(context.Background(), h2cClient(), srv.URL)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if !strings.Contains(err.Error(), "not found: billing.v1.Billing") {
+		t.Errorf("expected reflection error message, got %v", err)
+	}
+}
+
+func TestDiscover_Unimplemented(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Grpc-Status", "12")
+		w.WriteHeader(http.StatusOK)
+	}))
+	srv.Config.Protocols = new(http.Protocols)
+	srv.Config.Protocols.SetUnencryptedHTTP2(true)
+	srv.Start()
+	defer srv.Close()
+
+	_, err := Discover(context.Background(), h2cClient(), srv.URL)
+	if !errors.Is(err, errReflectionUnimplemented) {
+		t.Fatalf("expected unimplemented error, got %v", err)
+	}
+}
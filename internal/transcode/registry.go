@@ -0,0 +1,165 @@
+// Package transcode converts between JSON bodies and binary protobuf messages
+// using descriptors loaded from compiled FileDescriptorSet files.
+package transcode
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// Registry holds protobuf file descriptors used to resolve gRPC methods and
+// messages. Descriptor sets may be added incrementally; files are linked
+// together so imports across sets resolve correctly.
+//
+// A Registry is not safe for concurrent mutation. It is built once during
+// config compilation and only read at request time.
+type Registry struct {
+	protos map[string]*descriptorpb.FileDescriptorProto // file name → proto
+	files  *protoregistry.Files
+	types  *dynamicpb.Types
+}
+
+// NewRegistry creates an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		protos: make(map[string]*descriptorpb.FileDescriptorProto),
+		files:  new(protoregistry.Files),
+		types:  dynamicpb.NewTypes(new(protoregistry.Files)),
+	}
+}
+
+// LoadRegistry creates a Registry from the FileDescriptorSet files at paths.
+// Files are produced by `protoc --include_imports --descriptor_set_out=...`.
+func LoadRegistry(paths []string) (*Registry, error) {
+	reg := NewRegistry()
+	for _, p := range paths {
+		if err := reg.LoadFile(p); err != nil {
+			return nil, err
+		}
+	}
+	return reg, nil
+}
+
+// LoadFile reads a serialized FileDescriptorSet from disk and adds it.
+func (r *Registry) LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read descriptor set %s: %w", path, err)
+	}
+	if err := r.AddDescriptorSet(data); err != nil {
+		return fmt.Errorf("descriptor set %s: %w", path, err)
+	}
+	return nil
+}
+
+// AddDescriptorSet parses a serialized FileDescriptorSet and links its files
+// into the registry. Files already present under the same name are replaced.
+func (r *Registry) AddDescriptorSet(data []byte) error {
+	var set descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(data, &set); err != nil {
+		return fmt.Errorf("parse FileDescriptorSet: %w", err)
+	}
+	return r.AddFiles(set.GetFile()...)
+}
+
+// AddFiles links the given file descriptor protos into the registry.
+// On error the registry is left unchanged.
+func (r *Registry) AddFiles(fds ...*descriptorpb.FileDescriptorProto) error {
+	merged := make(map[string]*descriptorpb.FileDescriptorProto, len(r.protos)+len(fds))
+	for name, fd := range r.protos {
+		merged[name] = fd
+	}
+	for _, fd := range fds {
+		if fd.GetName() == "" {
+			return fmt.Errorf("file descriptor without a name")
+		}
+		merged[fd.GetName()] = fd
+	}
+
+	files, err := buildFiles(merged)
+	if err != nil {
+		return err
+	}
+	r.protos = merged
+	r.files = files
+	r.types = dynamicpb.NewTypes(files)
+	return nil
+}
+
+// buildFiles links a set of file descriptor protos. Files are passed in name
+// order so errors are reported deterministically.
+func buildFiles(protos map[string]*descriptorpb.FileDescriptorProto) (*protoregistry.Files, error) {
+	names := make([]string, 0, len(protos))
+	for name := range protos {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	set := &descriptorpb.FileDescriptorSet{File: make([]*descriptorpb.FileDescriptorProto, 0, len(names))}
+	for _, name := range names {
+		set.File = append(set.File, protos[name])
+	}
+	files, err := protodesc.NewFiles(set)
+	if err != nil {
+		return nil, fmt.Errorf("link descriptors: %w", err)
+	}
+	return files, nil
+}
+
+// Len returns the number of files in the registry.
+func (r *Registry) Len() int {
+	return len(r.protos)
+}
+
+// FindService resolves a gRPC service by its fully qualified name.
+func (r *Registry) FindService(service string) (protoreflect.ServiceDescriptor, error) {
+	d, err := r.files.FindDescriptorByName(protoreflect.FullName(service))
+	if err != nil {
+		return nil, fmt.Errorf("service %q not found in descriptors", service)
+	}
+	sd, ok := d.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%q is not a service", service)
+	}
+	return sd, nil
+}
+
+// FindMethod resolves a gRPC method by fully qualified service name and method name.
+func (r *Registry) FindMethod(service, method string) (protoreflect.MethodDescriptor, error) {
+	sd, err := r.FindService(service)
+	if err != nil {
+		return nil, err
+	}
+	md := sd.Methods().ByName(protoreflect.Name(method))
+	if md == nil {
+		return nil, fmt.Errorf("method %q not found in service %q", method, service)
+	}
+	return md, nil
+}
+
+// FindMessage resolves a message by its fully qualified name.
+func (r *Registry) FindMessage(name string) (protoreflect.MessageDescriptor, error) {
+	d, err := r.files.FindDescriptorByName(protoreflect.FullName(name))
+	if err != nil {
+		return nil, fmt.Errorf("message %q not found in descriptors", name)
+	}
+	md, ok := d.(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%q is not a message", name)
+	}
+	return md, nil
+}
+
+// Codec returns a Codec for the given message that resolves google.protobuf.Any
+// and extension types against this registry.
+func (r *Registry) Codec(md protoreflect.MessageDescriptor) *Codec {
+	return &Codec{desc: md, types: r.types}
+}
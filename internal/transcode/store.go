@@ -0,0 +1,184 @@
+package transcode
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// maxDescriptorVersions is the number of versions retained per descriptor set.
+const maxDescriptorVersions = 10
+
+// DescriptorSet is one uploaded version of a named FileDescriptorSet.
+type DescriptorSet struct {
+	Name       string    `json:"name"`
+	Version    int       `json:"version"`
+	Files      []string  `json:"files"`
+	Services   []string  `json:"services"`
+	Size       int       `json:"size"`
+	UploadedAt time.Time `json:"uploaded_at"`
+	fds        []*descriptorpb.FileDescriptorProto
+}
+
+// DescriptorStore holds named, versioned descriptor sets uploaded at runtime.
+// The latest version of each set is active and is merged into the registry
+// built at config compilation.
+//
+// A DescriptorStore is not safe for concurrent mutation. Once installed for
+// use by the compiler it must be treated as read-only; callers Clone it,
+// modify the copy and install the copy.
+type DescriptorStore struct {
+	sets map[string][]*DescriptorSet // name → versions, oldest first
+}
+
+// NewDescriptorStore creates an empty DescriptorStore.
+func NewDescriptorStore() *DescriptorStore {
+	return &DescriptorStore{sets: make(map[string][]*DescriptorSet)}
+}
+
+// Clone returns a copy of the store that can be modified independently.
+// Version entries are immutable and shared between copies.
+func (s *DescriptorStore) Clone() *DescriptorStore {
+	c := NewDescriptorStore()
+	if s == nil {
+		return c
+	}
+	for name, versions := range s.sets {
+		c.sets[name] = append([]*DescriptorSet(nil), versions...)
+	}
+	return c
+}
+
+// Put parses data as a serialized FileDescriptorSet and stores it as a new
+// version of the named set.
+func (s *DescriptorStore) Put(name string, data []byte) (*DescriptorSet, error) {
+	if name == "" {
+		return nil, fmt.Errorf("descriptor set name is required")
+	}
+	var set descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("parse FileDescriptorSet: %w", err)
+	}
+	if len(set.GetFile()) == 0 {
+		return nil, fmt.Errorf("descriptor set contains no files")
+	}
+
+	ds := &DescriptorSet{
+		Name:       name,
+		Size:       len(data),
+		UploadedAt: time.Now().UTC(),
+		fds:        set.GetFile(),
+	}
+	for _, fd := range set.GetFile() {
+		ds.Files = append(ds.Files, fd.GetName())
+		for _, svc := range fd.GetService() {
+			full := svc.GetName()
+			if pkg := fd.GetPackage(); pkg != "" {
+				full = pkg + "." + full
+			}
+			ds.Services = append(ds.Services, full)
+		}
+	}
+	sort.Strings(ds.Files)
+	sort.Strings(ds.Services)
+	s.add(ds)
+	return ds, nil
+}
+
+// Rollback re-activates a previous version of the named set by storing it as
+// a new version.
+func (s *DescriptorStore) Rollback(name string, version int) (*DescriptorSet, error) {
+	for _, v := range s.sets[name] {
+		if v.Version == version {
+			ds := *v
+			ds.UploadedAt = time.Now().UTC()
+			s.add(&ds)
+			return &ds, nil
+		}
+	}
+	return nil, fmt.Errorf("descriptor set %q version %d not found", name, version)
+}
+
+func (s *DescriptorStore) add(ds *DescriptorSet) {
+	versions := s.sets[ds.Name]
+	ds.Version = 1
+	if n := len(versions); n > 0 {
+		ds.Version = versions[n-1].Version + 1
+	}
+	versions = append(versions, ds)
+	if len(versions) > maxDescriptorVersions {
+		versions = versions[len(versions)-maxDescriptorVersions:]
+	}
+	s.sets[ds.Name] = versions
+}
+
+// Delete removes the named set and all of its versions.
+func (s *DescriptorStore) Delete(name string) bool {
+	if _, ok := s.sets[name]; !ok {
+		return false
+	}
+	delete(s.sets, name)
+	return true
+}
+
+// Get returns the active version of the named set.
+func (s *DescriptorStore) Get(name string) (*DescriptorSet, bool) {
+	if s == nil {
+		return nil, false
+	}
+	versions := s.sets[name]
+	if len(versions) == 0 {
+		return nil, false
+	}
+	return versions[len(versions)-1], true
+}
+
+// Versions returns the retained versions of the named set, newest first.
+func (s *DescriptorStore) Versions(name string) []*DescriptorSet {
+	if s == nil {
+		return nil
+	}
+	versions := s.sets[name]
+	result := make([]*DescriptorSet, 0, len(versions))
+	for i := len(versions) - 1; i >= 0; i-- {
+		result = append(result, versions[i])
+	}
+	return result
+}
+
+// List returns the active version of every set, sorted by name.
+func (s *DescriptorStore) List() []*DescriptorSet {
+	if s == nil {
+		return []*DescriptorSet{}
+	}
+	names := make([]string, 0, len(s.sets))
+	for name := range s.sets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	result := make([]*DescriptorSet, 0, len(names))
+	for _, name := range names {
+		versions := s.sets[name]
+		result = append(result, versions[len(versions)-1])
+	}
+	return result
+}
+
+// AddTo links the active version of every set into reg. Sets are linked
+// together, so a set may import files provided by another.
+func (s *DescriptorStore) AddTo(reg *Registry) error {
+	var fds []*descriptorpb.FileDescriptorProto
+	for _, ds := range s.List() {
+		fds = append(fds, ds.fds...)
+	}
+	if len(fds) == 0 {
+		return nil
+	}
+	if err := reg.AddFiles(fds...); err != nil {
+		return fmt.Errorf("uploaded descriptor sets: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package transcode
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func testDescriptorSetBytes(t *testing.T, fds ...*descriptorpb.FileDescriptorProto) []byte {
+	t.Helper()
+	data, err := proto.Marshal(&descriptorpb.FileDescriptorSet{File: fds})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestDescriptorStore_PutAndVersions(t *testing.T) {
+	s := NewDescriptorStore()
+	data := testDescriptorSetBytes(t, testFileDescriptor())
+
+	first, err := s.Put("user", data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if first.Version != 1 || first.Size != len(data) {
+		t.Errorf("unexpected first version %+v", first)
+	}
+	if len(first.Services) != 1 || first.Services[0] != "user.v1.UserService" {
+		t.Errorf("unexpected services %v", first.Services)
+	}
+	if len(first.Files) != 1 || first.Files[0] != "user/v1/user.proto" {
+		t.Errorf("unexpected files %v", first.Files)
+	}
+
+	second, _ := s.Put("user", data)
+	if second.Version != 2 {
+		t.Errorf("expected version 2, got %d", second.Version)
+	}
+	active, ok := s.Get("user")
+	if !ok || active.Version != 2 {
+		t.Fatalf("expected active version 2, got %+v", active)
+	}
+	versions := s.Versions("user")
+	if len(versions) != 2 || versions[0].Version != 2 || versions[1].Version != 1 {
+		t.Errorf("expected versions newest first, got %+v", versions)
+	}
+}
+
+func TestDescriptorStore_PutInvalid(t *testing.T) {
+	s := NewDescriptorStore()
+	if _, err := s.Put("user", []byte("not a descriptor set")); err == nil {
+		t.Error("expected parse error")
+	}
+	if _, err := s.Put("user", nil); err == nil {
+		t.Error("expected error for empty descriptor set")
+	}
+	if _, err := s.Put("", testDescriptorSetBytes(t, testFileDescriptor())); err == nil {
+		t.Error("expected error for empty name")
+	}
+	if len(s.List()) != 0 {
+		t.Error("expected store to remain empty")
+	}
+}
+
+func TestDescriptorStore_Rollback(t *testing.T) {
+	s := NewDescriptorStore()
+	s.Put("user", testDescriptorSetBytes(t, testFileDescriptor()))
+	fd := testFileDescriptor()
+	fd.Service = nil
+	s.Put("user", testDescriptorSetBytes(t, fd))
+
+	ds, err := s.Rollback("user", 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ds.Version != 3 || len(ds.Services) != 1 {
+		t.Errorf("expected version 3 with the original services, got %+v", ds)
+	}
+	if _, err := s.Rollback("user", 42); err == nil {
+		t.Error("expected error for unknown version")
+	}
+}
+
+func TestDescriptorStore_RetainsLimitedHistory(t *testing.T) {
+	s := NewDescriptorStore()
+	data := testDescriptorSetBytes(t, testFileDescriptor())
+	for i := 0; i < maxDescriptorVersions+5; i++ {
+		s.Put("user", data)
+	}
+	versions := s.Versions("user")
+	if len(versions) != maxDescriptorVersions {
+		t.Fatalf("expected %d versions, got %d", maxDescriptorVersions, len(versions))
+	}
+	if versions[0].Version != maxDescriptorVersions+5 {
+		t.Errorf("expected newest version %d, got %d", maxDescriptorVersions+5, versions[0].Version)
+	}
+}
+
+func TestDescriptorStore_CloneIsIndependent(t *testing.T) {
+	s := NewDescriptorStore()
+	s.Put("user", testDescriptorSetBytes(t, testFileDescriptor()))
+
+	c := s.Clone()
+	c.Delete("user")
+	c.Put("other", testDescriptorSetBytes(t, testFileDescriptor()))
+
+	if _, ok := s.Get("user"); !ok {
+		t.Error("expected original store to keep user")
+	}
+	if _, ok := s.Get("other"); ok {
+		t.Error("expected original store not to see other")
+	}
+}
+
+func TestDescriptorStore_AddTo(t *testing.T) {
+	common := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("common/v1/id.proto"),
+		Package: proto.String("common.v1"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{
+			{Name: proto.String("ID"), Field: []*descriptorpb.FieldDescriptorProto{
+				{Name: proto.String("value"), JsonName: proto.String("value"), Number: proto.Int32(1),
+					Type: descriptorpb.FieldDescriptorProto_TYPE_INT64.Enum(), Label: descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum()},
+			}},
+		},
+	}
+	user := testFileDescriptor()
+	user.Dependency = []string{"common/v1/id.proto"}
+
+	// "a-user" sorts before "z-common" but imports from it; sets are linked together.
+	s := NewDescriptorStore()
+	if _, err := s.Put("a-user", testDescriptorSetBytes(t, user)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Put("z-common", testDescriptorSetBytes(t, common)); err != nil {
+		t.Fatal(err)
+	}
+
+	reg := NewRegistry()
+	if err := s.AddTo(reg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := reg.FindMethod("user.v1.UserService", "GetUser"); err != nil {
+		t.Errorf("expected method to resolve: %v", err)
+	}
+
+	s.Delete("z-common")
+	if err := s.AddTo(NewRegistry()); err == nil {
+		t.Error("expected link error for missing import")
+	}
+}
@@ -0,0 +1,100 @@
+package transcode
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// StreamEncoder reads a stream of JSON documents (newline-delimited or simply
+// concatenated) and yields each one as a framed binary protobuf message.
+// It is used as the upstream request body for client-streaming RPCs.
+type StreamEncoder struct {
+	dec   *json.Decoder
+	src   io.Closer
+	codec *Codec
+	buf   bytes.Buffer
+	err   error
+}
+
+// NewStreamEncoder returns a StreamEncoder that transcodes JSON documents read
+// from src. Closing the encoder closes src.
+func NewStreamEncoder(src io.ReadCloser, codec *Codec) *StreamEncoder {
+	return &StreamEncoder{dec: json.NewDecoder(src), src: src, codec: codec}
+}
+
+// Read implements io.Reader. Each JSON document is transcoded only when the
+// previous frame has been fully consumed, so messages flow to the upstream as
+// soon as the client sends them.
+func (e *StreamEncoder) Read(p []byte) (int, error) {
+	for e.buf.Len() == 0 {
+		if e.err != nil {
+			return 0, e.err
+		}
+		var raw json.RawMessage
+		if err := e.dec.Decode(&raw); err != nil {
+			if err == io.EOF {
+				e.err = io.EOF
+			} else {
+				e.err = fmt.Errorf("decode stream message: %w", err)
+			}
+			continue
+		}
+		msg, err := e.codec.FromJSON(raw)
+		if err != nil {
+			e.err = err
+			continue
+		}
+		e.buf.Write(Frame(msg))
+	}
+	return e.buf.Read(p)
+}
+
+// Close closes the underlying source.
+func (e *StreamEncoder) Close() error {
+	return e.src.Close()
+}
+
+// StreamDecoder reads framed binary protobuf messages and yields them as
+// newline-delimited JSON. It is used as the client response body for
+// server-streaming RPCs.
+type StreamDecoder struct {
+	src   io.ReadCloser
+	codec *Codec
+	buf   bytes.Buffer
+	err   error
+}
+
+// NewStreamDecoder returns a StreamDecoder reading frames from src. Closing the
+// decoder closes src.
+func NewStreamDecoder(src io.ReadCloser, codec *Codec) *StreamDecoder {
+	return &StreamDecoder{src: src, codec: codec}
+}
+
+// Read implements io.Reader, emitting one JSON line per upstream message.
+func (d *StreamDecoder) Read(p []byte) (int, error) {
+	for d.buf.Len() == 0 {
+		if d.err != nil {
+			return 0, d.err
+		}
+		msg, err := ReadFrame(d.src)
+		if err != nil {
+			d.err = err
+			continue
+		}
+		out, err := d.codec.ToJSON(msg)
+		if err != nil {
+			d.err = err
+			continue
+		}
+		d.buf.Write(out)
+		d.buf.WriteByte('\n')
+	}
+	return d.buf.Read(p)
+}
+
+// Close closes the underlying source.
+func (d *StreamDecoder) Close() error {
+	return d.src.Close()
+}
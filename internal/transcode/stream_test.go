@@ -0,0 +1,81 @@
+package transcode
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestStreamEncoder(t *testing.T) {
+	reg := testRegistry(t)
+	md, _ := reg.FindMessage("user.v1.GetUserRequest")
+	codec := reg.Codec(md)
+
+	src := io.NopCloser(strings.NewReader("{\"id\":1}\n{\"id\":2}\n\n{\"id\":3}"))
+	enc := NewStreamEncoder(src, codec)
+
+	var ids []string
+	for {
+		msg, err := ReadFrame(enc)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		out, err := codec.ToJSON(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, string(out))
+	}
+	if len(ids) != 3 {
+		t.Fatalf("expected 3 messages, got %d: %v", len(ids), ids)
+	}
+	if !strings.Contains(ids[2], `"3"`) {
+		t.Errorf("expected third message id 3, got %s", ids[2])
+	}
+}
+
+func TestStreamEncoder_InvalidMessage(t *testing.T) {
+	reg := testRegistry(t)
+	md, _ := reg.FindMessage("user.v1.GetUserRequest")
+
+	src := io.NopCloser(strings.NewReader("{\"id\":1}\n{\"bogus\":true}\n"))
+	enc := NewStreamEncoder(src, reg.Codec(md))
+
+	if _, err := ReadFrame(enc); err != nil {
+		t.Fatalf("first message should encode: %v", err)
+	}
+	if _, err := ReadFrame(enc); err == nil || err == io.EOF {
+		t.Fatalf("expected decode error for second message, got %v", err)
+	}
+}
+
+func TestStreamDecoder(t *testing.T) {
+	reg := testRegistry(t)
+	md, _ := reg.FindMessage("user.v1.User")
+	codec := reg.Codec(md)
+
+	var frames bytes.Buffer
+	for _, doc := range []string{`{"id":"1","displayName":"a"}`, `{"id":"2","displayName":"b"}`} {
+		msg, err := codec.FromJSON([]byte(doc))
+		if err != nil {
+			t.Fatal(err)
+		}
+		frames.Write(Frame(msg))
+	}
+
+	out, err := io.ReadAll(NewStreamDecoder(io.NopCloser(&frames), codec))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(out), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d: %q", len(lines), out)
+	}
+	if !strings.Contains(lines[1], `"displayName":"b"`) {
+		t.Errorf("unexpected second line %s", lines[1])
+	}
+}
@@ -0,0 +1,264 @@
+package transcode
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// testFileDescriptor describes:
+//
+//	package user.v1;
+//	message GetUserRequest { int64 id = 1; repeated string fields = 2; }
+//	message User { int64 id = 1; string display_name = 2; }
+//	service UserService { rpc GetUser(GetUserRequest) returns (User); }
+func testFileDescriptor() *descriptorpb.FileDescriptorProto {
+	return &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("user/v1/user.proto"),
+		Package: proto.String("user.v1"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{
+			{
+				Name: proto.String("GetUserRequest"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					{Name: proto.String("id"), JsonName: proto.String("id"), Number: proto.Int32(1),
+						Type: descriptorpb.FieldDescriptorProto_TYPE_INT64.Enum(), Label: descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum()},
+					{Name: proto.String("fields"), JsonName: proto.String("fields"), Number: proto.Int32(2),
+						Type: descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(), Label: descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()},
+				},
+			},
+			{
+				Name: proto.String("User"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					{Name: proto.String("id"), JsonName: proto.String("id"), Number: proto.Int32(1),
+						Type: descriptorpb.FieldDescriptorProto_TYPE_INT64.Enum(), Label: descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum()},
+					{Name: proto.String("display_name"), JsonName: proto.String("displayName"), Number: proto.Int32(2),
+						Type: descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(), Label: descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum()},
+				},
+			},
+		},
+		Service: []*descriptorpb.ServiceDescriptorProto{
+			{
+				Name: proto.String("UserService"),
+				Method: []*descriptorpb.MethodDescriptorProto{
+					{Name: proto.String("GetUser"), InputType: proto.String(".user.v1.GetUserRequest"), OutputType: proto.String(".user.v1.User")},
+				},
+			},
+		},
+	}
+}
+
+func testRegistry(t *testing.T) *Registry {
+	t.Helper()
+	set := &descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{testFileDescriptor()}}
+	data, err := proto.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg := NewRegistry()
+	if err := reg.AddDescriptorSet(data); err != nil {
+		t.Fatalf("add descriptor set: %v", err)
+	}
+	return reg
+}
+
+func TestRegistry_FindMethod(t *testing.T) {
+	reg := testRegistry(t)
+
+	md, err := reg.FindMethod("user.v1.UserService", "GetUser")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if md.Input().FullName() != "user.v1.GetUserRequest" {
+		t.Errorf("expected input user.v1.GetUserRequest, got %s", md.Input().FullName())
+	}
+	if md.Output().FullName() != "user.v1.User" {
+		t.Errorf("expected output user.v1.User, got %s", md.Output().FullName())
+	}
+
+	if _, err := reg.FindMethod("user.v1.UserService", "DeleteUser"); err == nil {
+		t.Error("expected error for unknown method")
+	}
+	if _, err := reg.FindMethod("user.v1.Missing", "GetUser"); err == nil {
+		t.Error("expected error for unknown service")
+	}
+	if _, err := reg.FindMethod("user.v1.User", "GetUser"); err == nil {
+		t.Error("expected error when name is a message, not a service")
+	}
+}
+
+func TestRegistry_InvalidDescriptorSetLeavesRegistryUnchanged(t *testing.T) {
+	reg := testRegistry(t)
+
+	broken := &descriptorpb.FileDescriptorProto{
+		Name:       proto.String("broken.proto"),
+		Package:    proto.String("broken"),
+		Dependency: []string{"missing.proto"},
+	}
+	if err := reg.AddFiles(broken); err == nil {
+		t.Fatal("expected link error for missing dependency")
+	}
+	if reg.Len() != 1 {
+		t.Errorf("expected registry to keep 1 file, got %d", reg.Len())
+	}
+	if _, err := reg.FindMethod("user.v1.UserService", "GetUser"); err != nil {
+		t.Errorf("expected existing method to resolve, got %v", err)
+	}
+}
+
+func TestCodec_RoundTrip(t *testing.T) {
+	reg := testRegistry(t)
+	md, err := reg.FindMethod("user.v1.UserService", "GetUser")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bin, err := reg.Codec(md.Input()).FromJSON([]byte(`{"id":"42","fields":["name","email"]}`))
+	if err != nil {
+		t.Fatalf("FromJSON: %v", err)
+	}
+	if len(bin) == 0 {
+		t.Fatal("expected non-empty binary message")
+	}
+
+	// Re-encode the request message to JSON to verify the wire bytes.
+	out, err := reg.Codec(md.Input()).ToJSON(bin)
+	if err != nil {
+		t.Fatalf("ToJSON: %v", err)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(out, &got); err != nil {
+		t.Fatalf("invalid JSON %s: %v", out, err)
+	}
+	if got["id"] != "42" {
+		t.Errorf("expected id \"42\", got %v", got["id"])
+	}
+	if fields, ok := got["fields"].([]interface{}); !ok || len(fields) != 2 {
+		t.Errorf("expected 2 fields, got %v", got["fields"])
+	}
+}
+
+func TestCodec_JSONNames(t *testing.T) {
+	reg := testRegistry(t)
+	md, _ := reg.FindMessage("user.v1.User")
+	codec := reg.Codec(md)
+
+	// Both the proto name and the JSON name are accepted on input.
+	bin, err := codec.FromJSON([]byte(`{"id":7,"display_name":"Ada"}`))
+	if err != nil {
+		t.Fatalf("FromJSON: %v", err)
+	}
+	out, err := codec.ToJSON(bin)
+	if err != nil {
+		t.Fatalf("ToJSON: %v", err)
+	}
+	if !strings.Contains(string(out), `"displayName"`) {
+		t.Errorf("expected lowerCamelCase JSON name in %s", out)
+	}
+}
+
+func TestCodec_InvalidJSON(t *testing.T) {
+	reg := testRegistry(t)
+	md, _ := reg.FindMessage("user.v1.GetUserRequest")
+
+	if _, err := reg.Codec(md).FromJSON([]byte(`{"unknown_field":1}`)); err == nil {
+		t.Error("expected error for unknown field")
+	}
+	if _, err := reg.Codec(md).FromJSON([]byte(`{"id":"not-a-number"}`)); err == nil {
+		t.Error("expected error for mistyped field")
+	}
+}
+
+func TestCodec_EmptyBody(t *testing.T) {
+	reg := testRegistry(t)
+	md, _ := reg.FindMessage("user.v1.GetUserRequest")
+
+	bin, err := reg.Codec(md).FromJSON(nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(bin) != 0 {
+		t.Errorf("expected empty message encoding, got %d bytes", len(bin))
+	}
+}
+
+func TestFrame_RoundTrip(t *testing.T) {
+	framed := Frame([]byte("hello"))
+	if len(framed) != 10 {
+		t.Fatalf("expected 10 bytes, got %d", len(framed))
+	}
+
+	r := bytes.NewReader(append(framed, Frame(nil)...))
+	msg, err := ReadFrame(r)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(msg) != "hello" {
+		t.Errorf("expected hello, got %q", msg)
+	}
+	msg, err = ReadFrame(r)
+	if err != nil || len(msg) != 0 {
+		t.Fatalf("expected empty second frame, got %q, %v", msg, err)
+	}
+	if _, err := ReadFrame(r); err != io.EOF {
+		t.Errorf("expected io.EOF, got %v", err)
+	}
+}
+
+func TestReadFrame_Errors(t *testing.T) {
+	if _, err := ReadFrame(bytes.NewReader([]byte{0, 0, 0})); err == nil {
+		t.Error("expected error for truncated header")
+	}
+	if _, err := ReadFrame(bytes.NewReader([]byte{0, 0, 0, 0, 5, 'a'})); err == nil {
+		t.Error("expected error for truncated message")
+	}
+	if _, err := ReadFrame(bytes.NewReader([]byte{1, 0, 0, 0, 0})); err == nil {
+		t.Error("expected error for compressed frame")
+	}
+}
+
+func TestFrameReader(t *testing.T) {
+	framed, err := io.ReadAll(byteReader{NewFrameReader(strings.NewReader("hello"), 5)})
+	if err != nil || !bytes.Equal(framed, Frame([]byte("hello"))) {
+		t.Fatalf("expected the framed message, got %q, %v", framed, err)
+	}
+	msg, err := SplitFrame(append(framed, "trailing"...))
+	if err != nil || string(msg) != "hello" {
+		t.Errorf("expected hello, got %q, %v", msg, err)
+	}
+	for _, b := range [][]byte{{0, 0, 0}, {0, 0, 0, 0, 5, 'a'}, {1, 0, 0, 0, 0}} {
+		if _, err := SplitFrame(b); err == nil {
+			t.Errorf("expected error splitting %v", b)
+		}
+	}
+}
+
+// byteReader returns one byte per Read so frame headers straddle reads.
+type byteReader struct{ r io.Reader }
+
+func (b byteReader) Read(p []byte) (int, error) {
+	if len(p) > 1 {
+		p = p[:1]
+	}
+	return b.r.Read(p)
+}
+
+func TestLimitFrames(t *testing.T) {
+	stream := append(Frame([]byte("ok")), Frame([]byte("0123456789"))...)
+
+	all, err := io.ReadAll(LimitFrames(io.NopCloser(bytes.NewReader(stream)), 16))
+	if err != nil || !bytes.Equal(all, stream) {
+		t.Fatalf("expected stream to pass through unchanged, got %q, %v", all, err)
+	}
+
+	_, err = io.ReadAll(LimitFrames(io.NopCloser(byteReader{bytes.NewReader(stream)}), 4))
+	if !errors.Is(err, ErrMessageTooLarge) {
+		t.Errorf("expected ErrMessageTooLarge, got %v", err)
+	}
+}
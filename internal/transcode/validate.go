@@ -0,0 +1,323 @@
+package transcode
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// FieldViolation describes one problem with a field of a JSON request body.
+// Field is a dotted path using the JSON field names, with [i] for list
+// elements and ["key"] for map entries.
+type FieldViolation struct {
+	Field       string `json:"field"`
+	Description string `json:"description"`
+}
+
+// Validate checks a JSON document against the codec's message schema and
+// returns every violation found. Unlike FromJSON, which stops at the first
+// error, Validate walks the whole document so clients can fix all fields at
+// once. An empty body is valid.
+//
+// Well-known types (google.protobuf.*) have special JSON forms and are only
+// checked by FromJSON.
+func (c *Codec) Validate(data []byte) []FieldViolation {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return []FieldViolation{{Description: "malformed JSON: " + err.Error()}}
+	}
+	if dec.More() {
+		return []FieldViolation{{Description: "malformed JSON: unexpected data after top-level value"}}
+	}
+	var vs []FieldViolation
+	validateMessage(c.desc, v, "", &vs)
+	return vs
+}
+
+func validateMessage(md protoreflect.MessageDescriptor, v interface{}, path string, vs *[]FieldViolation) {
+	if strings.HasPrefix(string(md.FullName()), "google.protobuf.") {
+		return
+	}
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		addViolation(vs, path, "expected object for message %s, got %s", md.FullName(), jsonKind(v))
+		return
+	}
+
+	oneofs := make(map[protoreflect.Name]string)
+	for _, key := range sortedKeys(obj) {
+		val := obj[key]
+		fieldPath := joinPath(path, key)
+		fd := findField(md, key)
+		if fd == nil {
+			addViolation(vs, fieldPath, "unknown field")
+			continue
+		}
+		if val == nil {
+			continue
+		}
+		if od := fd.ContainingOneof(); od != nil && !od.IsSynthetic() {
+			if prev, ok := oneofs[od.Name()]; ok {
+				addViolation(vs, fieldPath, "only one field of oneof %s may be set, %s is already set", od.Name(), prev)
+				continue
+			}
+			oneofs[od.Name()] = key
+		}
+		validateField(fd, val, fieldPath, vs)
+	}
+}
+
+func validateField(fd protoreflect.FieldDescriptor, v interface{}, path string, vs *[]FieldViolation) {
+	switch {
+	case fd.IsMap():
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			addViolation(vs, path, "expected object for map field, got %s", jsonKind(v))
+			return
+		}
+		for _, key := range sortedKeys(obj) {
+			entryPath := path + "[" + strconv.Quote(key) + "]"
+			if msg := checkMapKey(fd.MapKey(), key); msg != "" {
+				addViolation(vs, entryPath, "%s", msg)
+				continue
+			}
+			if obj[key] != nil {
+				validateSingular(fd.MapValue(), obj[key], entryPath, vs)
+			}
+		}
+	case fd.IsList():
+		arr, ok := v.([]interface{})
+		if !ok {
+			addViolation(vs, path, "expected array for repeated field, got %s", jsonKind(v))
+			return
+		}
+		for i, elem := range arr {
+			elemPath := path + "[" + strconv.Itoa(i) + "]"
+			if elem == nil {
+				addViolation(vs, elemPath, "null is not allowed in a repeated field")
+				continue
+			}
+			validateSingular(fd, elem, elemPath, vs)
+		}
+	default:
+		validateSingular(fd, v, path, vs)
+	}
+}
+
+func validateSingular(fd protoreflect.FieldDescriptor, v interface{}, path string, vs *[]FieldViolation) {
+	switch fd.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		validateMessage(fd.Message(), v, path, vs)
+	case protoreflect.EnumKind:
+		validateEnum(fd.Enum(), v, path, vs)
+	case protoreflect.BoolKind:
+		if _, ok := v.(bool); !ok {
+			addViolation(vs, path, "expected boolean, got %s", jsonKind(v))
+		}
+	case protoreflect.StringKind:
+		if _, ok := v.(string); !ok {
+			addViolation(vs, path, "expected string, got %s", jsonKind(v))
+		}
+	case protoreflect.BytesKind:
+		s, ok := v.(string)
+		if !ok {
+			addViolation(vs, path, "expected base64 string, got %s", jsonKind(v))
+			return
+		}
+		if !isBase64(s) {
+			addViolation(vs, path, "invalid base64 data")
+		}
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		validateFloat(fd.Kind(), v, path, vs)
+	default:
+		validateInt(fd.Kind(), v, path, vs)
+	}
+}
+
+func validateEnum(ed protoreflect.EnumDescriptor, v interface{}, path string, vs *[]FieldViolation) {
+	switch x := v.(type) {
+	case string:
+		if ed.Values().ByName(protoreflect.Name(x)) == nil {
+			addViolation(vs, path, "invalid value %q for enum %s", x, ed.FullName())
+		}
+	case json.Number:
+		if _, err := strconv.ParseInt(string(x), 10, 32); err != nil {
+			addViolation(vs, path, "invalid value %s for enum %s", x, ed.FullName())
+		}
+	default:
+		addViolation(vs, path, "expected enum name or number, got %s", jsonKind(v))
+	}
+}
+
+// validateInt accepts JSON numbers and, as in the proto3 JSON mapping,
+// strings holding an integer.
+func validateInt(kind protoreflect.Kind, v interface{}, path string, vs *[]FieldViolation) {
+	var s string
+	switch x := v.(type) {
+	case json.Number:
+		s = string(x)
+	case string:
+		s = x
+	default:
+		addViolation(vs, path, "expected integer, got %s", jsonKind(v))
+		return
+	}
+
+	bits, signed := intRange(kind)
+	var err error
+	if signed {
+		_, err = strconv.ParseInt(s, 10, bits)
+	} else {
+		_, err = strconv.ParseUint(s, 10, bits)
+	}
+	if err == nil {
+		return
+	}
+	// Integral floats such as 1e3 are allowed by the JSON mapping.
+	if f, ferr := strconv.ParseFloat(s, 64); ferr == nil && f == math.Trunc(f) && inIntRange(f, bits, signed) {
+		return
+	}
+	if numErr, ok := err.(*strconv.NumError); ok && numErr.Err == strconv.ErrRange {
+		addViolation(vs, path, "value %s out of range for %s", s, kind)
+		return
+	}
+	addViolation(vs, path, "invalid %s value %q", kind, s)
+}
+
+func validateFloat(kind protoreflect.Kind, v interface{}, path string, vs *[]FieldViolation) {
+	var s string
+	switch x := v.(type) {
+	case json.Number:
+		s = string(x)
+	case string:
+		switch x {
+		case "NaN", "Infinity", "-Infinity":
+			return
+		}
+		s = x
+	default:
+		addViolation(vs, path, "expected number, got %s", jsonKind(v))
+		return
+	}
+	bits := 64
+	if kind == protoreflect.FloatKind {
+		bits = 32
+	}
+	if _, err := strconv.ParseFloat(s, bits); err != nil {
+		addViolation(vs, path, "invalid %s value %q", kind, s)
+	}
+}
+
+func intRange(kind protoreflect.Kind) (bits int, signed bool) {
+	switch kind {
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return 32, true
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return 32, false
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return 64, false
+	default:
+		return 64, true
+	}
+}
+
+func inIntRange(f float64, bits int, signed bool) bool {
+	if signed {
+		return f >= -math.Pow(2, float64(bits-1)) && f < math.Pow(2, float64(bits-1))
+	}
+	return f >= 0 && f < math.Pow(2, float64(bits))
+}
+
+func checkMapKey(fd protoreflect.FieldDescriptor, key string) string {
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		return ""
+	case protoreflect.BoolKind:
+		if key != "true" && key != "false" {
+			return fmt.Sprintf("invalid bool map key %q", key)
+		}
+		return ""
+	default:
+		bits, signed := intRange(fd.Kind())
+		var err error
+		if signed {
+			_, err = strconv.ParseInt(key, 10, bits)
+		} else {
+			_, err = strconv.ParseUint(key, 10, bits)
+		}
+		if err != nil {
+			return fmt.Sprintf("invalid %s map key %q", fd.Kind(), key)
+		}
+		return ""
+	}
+}
+
+// findField resolves a JSON key by its JSON name or its original proto name,
+// both of which the proto3 JSON mapping accepts.
+func findField(md protoreflect.MessageDescriptor, key string) protoreflect.FieldDescriptor {
+	fields := md.Fields()
+	if fd := fields.ByJSONName(key); fd != nil {
+		return fd
+	}
+	return fields.ByTextName(key)
+}
+
+func isBase64(s string) bool {
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.URLEncoding, base64.RawStdEncoding, base64.RawURLEncoding} {
+		if _, err := enc.DecodeString(s); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func jsonKind(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func addViolation(vs *[]FieldViolation, path, format string, args ...interface{}) {
+	*vs = append(*vs, FieldViolation{Field: path, Description: fmt.Sprintf(format, args...)})
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
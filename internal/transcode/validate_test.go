@@ -0,0 +1,170 @@
+package transcode
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// validationCodec returns a codec for:
+//
+//	package shop.v1;
+//	enum Status { STATUS_UNSPECIFIED = 0; ACTIVE = 1; }
+//	message Item { string sku = 1; uint32 quantity = 2; }
+//	message Order {
+//	  int32 id = 1; bool paid = 2; double total = 3; bytes token = 4;
+//	  Status status = 5; repeated Item items = 6; map<int32, string> notes = 7;
+//	  oneof contact { string email = 8; string phone = 9; }
+//	}
+func validationCodec(t *testing.T) *Codec {
+	t.Helper()
+	opt := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum()
+	rep := descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+	field := func(name, json string, num int32, typ descriptorpb.FieldDescriptorProto_Type, label *descriptorpb.FieldDescriptorProto_Label) *descriptorpb.FieldDescriptorProto {
+		return &descriptorpb.FieldDescriptorProto{Name: proto.String(name), JsonName: proto.String(json),
+			Number: proto.Int32(num), Type: typ.Enum(), Label: label}
+	}
+	withType := func(fd *descriptorpb.FieldDescriptorProto, typeName string) *descriptorpb.FieldDescriptorProto {
+		fd.TypeName = proto.String(typeName)
+		return fd
+	}
+	inOneof := func(fd *descriptorpb.FieldDescriptorProto) *descriptorpb.FieldDescriptorProto {
+		fd.OneofIndex = proto.Int32(0)
+		return fd
+	}
+
+	fd := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("shop/v1/order.proto"),
+		Package: proto.String("shop.v1"),
+		Syntax:  proto.String("proto3"),
+		EnumType: []*descriptorpb.EnumDescriptorProto{{
+			Name: proto.String("Status"),
+			Value: []*descriptorpb.EnumValueDescriptorProto{
+				{Name: proto.String("STATUS_UNSPECIFIED"), Number: proto.Int32(0)},
+				{Name: proto.String("ACTIVE"), Number: proto.Int32(1)},
+			},
+		}},
+		MessageType: []*descriptorpb.DescriptorProto{
+			{
+				Name: proto.String("Item"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("sku", "sku", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, opt),
+					field("quantity", "quantity", 2, descriptorpb.FieldDescriptorProto_TYPE_UINT32, opt),
+				},
+			},
+			{
+				Name: proto.String("Order"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("id", "id", 1, descriptorpb.FieldDescriptorProto_TYPE_INT32, opt),
+					field("paid", "paid", 2, descriptorpb.FieldDescriptorProto_TYPE_BOOL, opt),
+					field("total", "total", 3, descriptorpb.FieldDescriptorProto_TYPE_DOUBLE, opt),
+					field("token", "token", 4, descriptorpb.FieldDescriptorProto_TYPE_BYTES, opt),
+					withType(field("status", "status", 5, descriptorpb.FieldDescriptorProto_TYPE_ENUM, opt), ".shop.v1.Status"),
+					withType(field("items", "items", 6, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, rep), ".shop.v1.Item"),
+					withType(field("notes", "notes", 7, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, rep), ".shop.v1.Order.NotesEntry"),
+					inOneof(field("email", "email", 8, descriptorpb.FieldDescriptorProto_TYPE_STRING, opt)),
+					inOneof(field("phone", "phone", 9, descriptorpb.FieldDescriptorProto_TYPE_STRING, opt)),
+				},
+				NestedType: []*descriptorpb.DescriptorProto{{
+					Name: proto.String("NotesEntry"),
+					Field: []*descriptorpb.FieldDescriptorProto{
+						field("key", "key", 1, descriptorpb.FieldDescriptorProto_TYPE_INT32, opt),
+						field("value", "value", 2, descriptorpb.FieldDescriptorProto_TYPE_STRING, opt),
+					},
+					Options: &descriptorpb.MessageOptions{MapEntry: proto.Bool(true)},
+				}},
+				OneofDecl: []*descriptorpb.OneofDescriptorProto{{Name: proto.String("contact")}},
+			},
+		},
+	}
+	reg := NewRegistry()
+	if err := reg.AddFiles(fd); err != nil {
+		t.Fatal(err)
+	}
+	md, err := reg.FindMessage("shop.v1.Order")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return reg.Codec(md)
+}
+
+func TestValidate_Valid(t *testing.T) {
+	c := validationCodec(t)
+	bodies := []string{
+		``,
+		`{}`,
+		`{"id":1,"paid":true,"total":9.5,"token":"aGk=","status":"ACTIVE"}`,
+		`{"id":"42","total":"NaN","status":1,"items":[{"sku":"a","quantity":2}],"notes":{"1":"x"},"email":"a@b.c"}`,
+		`{"id":1e3,"email":"a@b.c","phone":null}`,
+	}
+	for _, body := range bodies {
+		if vs := c.Validate([]byte(body)); len(vs) != 0 {
+			t.Errorf("expected %s to be valid, got %+v", body, vs)
+		}
+		if _, err := c.FromJSON([]byte(body)); err != nil {
+			t.Errorf("expected %s to transcode, got %v", body, err)
+		}
+	}
+}
+
+func TestValidate_Violations(t *testing.T) {
+	c := validationCodec(t)
+	tests := []struct {
+		body  string
+		field string
+	}{
+		{`{"id":"abc"}`, "id"},
+		{`{"id":3000000000}`, "id"},
+		{`{"id":1.5}`, "id"},
+		{`{"paid":"yes"}`, "paid"},
+		{`{"total":true}`, "total"},
+		{`{"token":"!!"}`, "token"},
+		{`{"status":"DELETED"}`, "status"},
+		{`{"items":{}}`, "items"},
+		{`{"items":[{"quantity":-1}]}`, "items[0].quantity"},
+		{`{"items":[null]}`, "items[0]"},
+		{`{"notes":{"x":"y"}}`, `notes["x"]`},
+		{`{"notes":{"1":2}}`, `notes["1"]`},
+		{`{"email":"a","phone":"b"}`, "phone"},
+		{`{"unknown":1}`, "unknown"},
+		{`[]`, ""},
+	}
+	for _, tt := range tests {
+		vs := c.Validate([]byte(tt.body))
+		if len(vs) != 1 {
+			t.Errorf("%s: expected 1 violation, got %+v", tt.body, vs)
+			continue
+		}
+		if vs[0].Field != tt.field {
+			t.Errorf("%s: expected field %q, got %q (%s)", tt.body, tt.field, vs[0].Field, vs[0].Description)
+		}
+		if _, err := c.FromJSON([]byte(tt.body)); err == nil {
+			t.Errorf("%s: expected FromJSON to reject the body as well", tt.body)
+		}
+	}
+}
+
+func TestValidate_ReportsAllViolations(t *testing.T) {
+	c := validationCodec(t)
+	vs := c.Validate([]byte(`{"id":"x","paid":1,"items":[{"sku":5}]}`))
+	if len(vs) != 3 {
+		t.Fatalf("expected 3 violations, got %+v", vs)
+	}
+	want := []string{"id", "items[0].sku", "paid"}
+	for i, v := range vs {
+		if v.Field != want[i] {
+			t.Errorf("violation %d: expected field %s, got %s", i, want[i], v.Field)
+		}
+	}
+}
+
+func TestValidate_MalformedJSON(t *testing.T) {
+	c := validationCodec(t)
+	for _, body := range []string{`{"id":`, `{} {}`} {
+		vs := c.Validate([]byte(body))
+		if len(vs) != 1 || vs[0].Field != "" {
+			t.Errorf("%s: expected a single body-level violation, got %+v", body, vs)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+// Package watchdog samples the heap, goroutines and scheduling latency of
+// the gateway process and switches the gateway to a degraded mode while
+// any of them is past its threshold, so that an overloaded gateway sheds
+// work before it runs out of memory or stalls.
+package watchdog
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log/slog"
+	goruntime "runtime"
+	"runtime/metrics"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/oriys/nexus/internal/config"
+)
+
+// recoverFraction is the share of its threshold every sample must be under
+// for the gateway to leave degraded mode, so that it does not flap around
+// a threshold.
+const recoverFraction = 0.8
+
+// heapMetric is the runtime metric of the bytes of heap objects, live and
+// not yet swept.
+const heapMetric = "/memory/classes/heap/objects:bytes"
+
+// sample is a measure of the process.
+type sample struct {
+	HeapBytes  uint64
+	Goroutines int
+	// Lag is how late the watchdog's timer fired.
+	Lag time.Duration
+}
+
+// stats holds the last sample and the state of the watchdog for the
+// metrics. One watchdog runs per process.
+var stats struct {
+	heap       atomic.Uint64
+	goroutines atomic.Int64
+	lag        atomic.Int64 // nanoseconds
+	degraded   atomic.Bool
+	entered    atomic.Uint64
+}
+
+// Watchdog degrades the gateway while its samples are past the thresholds.
+type Watchdog struct {
+	interval      time.Duration
+	maxHeap       uint64
+	maxGoroutines int
+	maxLag        time.Duration
+
+	mu       sync.Mutex
+	reason   string // the exceeded thresholds, empty when healthy
+	onChange []func(reason string)
+}
+
+// New returns a watchdog enforcing cfg.
+func New(cfg *config.Watchdog) *Watchdog {
+	return &Watchdog{
+		interval:      cfg.SampleInterval(),
+		maxHeap:       uint64(cfg.HeapLimit()),
+		maxGoroutines: cfg.MaxGoroutines,
+		maxLag:        cfg.LagLimit(),
+	}
+}
+
+// OnChange registers f to be called as the gateway enters degraded mode,
+// with the exceeded thresholds, and as it recovers, with "". Register
+// callbacks before Run.
+func (w *Watchdog) OnChange(f func(reason string)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.onChange = append(w.onChange, f)
+}
+
+// Run samples the process every interval until stop is closed.
+func (w *Watchdog) Run(stop <-chan struct{}) {
+	heap := []metrics.Sample{{Name: heapMetric}}
+	timer := time.NewTimer(w.interval)
+	defer timer.Stop()
+	for {
+		due := time.Now().Add(w.interval)
+		select {
+		case <-stop:
+			return
+		case <-timer.C:
+		}
+		lag := max(time.Since(due), 0)
+		metrics.Read(heap)
+		var heapBytes uint64
+		if heap[0].Value.Kind() == metrics.KindUint64 {
+			heapBytes = heap[0].Value.Uint64()
+		}
+		w.check(sample{HeapBytes: heapBytes, Goroutines: goruntime.NumGoroutine(), Lag: lag})
+		timer.Reset(w.interval)
+	}
+}
+
+// check records s, entering or leaving degraded mode.
+func (w *Watchdog) check(s sample) {
+	stats.heap.Store(s.HeapBytes)
+	stats.goroutines.Store(int64(s.Goroutines))
+	stats.lag.Store(int64(s.Lag))
+
+	w.mu.Lock()
+	reason := w.reason
+	if exceeded := w.exceeded(s, 1); len(exceeded) > 0 {
+		reason = strings.Join(exceeded, ", ")
+	} else if len(w.exceeded(s, recoverFraction)) == 0 {
+		reason = ""
+	}
+	changed := reason != w.reason
+	entered := changed && w.reason == ""
+	w.reason = reason
+	callbacks := w.onChange
+	w.mu.Unlock()
+	if !changed {
+		return
+	}
+
+	stats.degraded.Store(reason != "")
+	if entered {
+		stats.entered.Add(1)
+		slog.Warn("gateway degraded",
+			slog.String("exceeded", reason),
+			slog.Uint64("heap_bytes", s.HeapBytes),
+			slog.Int("goroutines", s.Goroutines),
+			slog.Duration("lag", s.Lag),
+		)
+	} else if reason == "" {
+		slog.Info("gateway recovered from degraded mode")
+	}
+	for _, f := range callbacks {
+		f(reason)
+	}
+}
+
+// exceeded returns the names of the thresholds s is past, each scaled by
+// fraction.
+func (w *Watchdog) exceeded(s sample, fraction float64) []string {
+	var names []string
+	if w.maxHeap > 0 && float64(s.HeapBytes) > float64(w.maxHeap)*fraction {
+		names = append(names, "max_heap")
+	}
+	if w.maxGoroutines > 0 && float64(s.Goroutines) > float64(w.maxGoroutines)*fraction {
+		names = append(names, "max_goroutines")
+	}
+	if w.maxLag > 0 && float64(s.Lag) > float64(w.maxLag)*fraction {
+		names = append(names, "max_lag")
+	}
+	return names
+}
+
+// WriteMetrics writes the watchdog metrics in the Prometheus text
+// exposition format.
+func WriteMetrics(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "# HELP nexus_watchdog_heap_bytes Bytes of heap objects at the last watchdog sample.\n# TYPE nexus_watchdog_heap_bytes gauge\n")
+	fmt.Fprintf(bw, "nexus_watchdog_heap_bytes %s\n", strconv.FormatUint(stats.heap.Load(), 10))
+	fmt.Fprintf(bw, "# HELP nexus_watchdog_goroutines Goroutines at the last watchdog sample.\n# TYPE nexus_watchdog_goroutines gauge\n")
+	fmt.Fprintf(bw, "nexus_watchdog_goroutines %d\n", stats.goroutines.Load())
+	fmt.Fprintf(bw, "# HELP nexus_watchdog_lag_seconds How late the watchdog timer fired at the last sample.\n# TYPE nexus_watchdog_lag_seconds gauge\n")
+	fmt.Fprintf(bw, "nexus_watchdog_lag_seconds %s\n", strconv.FormatFloat(time.Duration(stats.lag.Load()).Seconds(), 'g', -1, 64))
+	degraded := 0
+	if stats.degraded.Load() {
+		degraded = 1
+	}
+	fmt.Fprintf(bw, "# HELP nexus_watchdog_degraded Whether the gateway is in degraded mode.\n# TYPE nexus_watchdog_degraded gauge\n")
+	fmt.Fprintf(bw, "nexus_watchdog_degraded %d\n", degraded)
+	fmt.Fprintf(bw, "# HELP nexus_watchdog_degraded_total Times the gateway entered degraded mode.\n# TYPE nexus_watchdog_degraded_total counter\n")
+	fmt.Fprintf(bw, "nexus_watchdog_degraded_total %s\n", strconv.FormatUint(stats.entered.Load(), 10))
+	return bw.Flush()
+}
@@ -0,0 +1,78 @@
+package watchdog
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oriys/nexus/internal/config"
+)
+
+func TestWatchdog_Check(t *testing.T) {
+	w := New(&config.Watchdog{MaxHeap: "100MiB", MaxGoroutines: 1000, MaxLag: "100ms"})
+	var changes []string
+	w.OnChange(func(reason string) { changes = append(changes, reason) })
+	entered := stats.entered.Load()
+
+	steps := []struct {
+		sample sample
+		want   string
+	}{
+		{sample{HeapBytes: 50 << 20, Goroutines: 100}, ""},
+		{sample{HeapBytes: 120 << 20, Goroutines: 100}, "max_heap"},
+		{sample{HeapBytes: 120 << 20, Goroutines: 2000, Lag: time.Second}, "max_heap, max_goroutines, max_lag"},
+		// Under the thresholds but not under 80% of them: still degraded.
+		{sample{HeapBytes: 90 << 20, Goroutines: 100}, "max_heap, max_goroutines, max_lag"},
+		{sample{HeapBytes: 70 << 20, Goroutines: 100}, ""},
+	}
+	for i, st := range steps {
+		w.check(st.sample)
+		if w.reason != st.want {
+			t.Errorf("step %d: degraded for %q, want %q", i, w.reason, st.want)
+		}
+	}
+	if want := []string{"max_heap", "max_heap, max_goroutines, max_lag", ""}; strings.Join(changes, "|") != strings.Join(want, "|") {
+		t.Errorf("changes %q, want %q", changes, want)
+	}
+	if n := stats.entered.Load() - entered; n != 1 {
+		t.Errorf("entered degraded mode %d times", n)
+	}
+}
+
+func TestWatchdog_Run(t *testing.T) {
+	w := New(&config.Watchdog{Interval: "1ms", MaxGoroutines: 1})
+	degraded := make(chan string, 1)
+	w.OnChange(func(reason string) { degraded <- reason })
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		w.Run(stop)
+		close(stopped)
+	}()
+	select {
+	case reason := <-degraded:
+		if reason != "max_goroutines" {
+			t.Errorf("degraded for %q", reason)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("watchdog did not degrade the gateway")
+	}
+	close(stop)
+	<-stopped
+
+	var b strings.Builder
+	WriteMetrics(&b)
+	for _, want := range []string{
+		"# TYPE nexus_watchdog_heap_bytes gauge",
+		"nexus_watchdog_degraded 1\n",
+		"nexus_watchdog_lag_seconds ",
+		"nexus_watchdog_degraded_total ",
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("metrics lack %q:\n%s", want, b.String())
+		}
+	}
+	if strings.Contains(b.String(), "nexus_watchdog_heap_bytes 0\n") {
+		t.Errorf("heap not sampled:\n%s", b.String())
+	}
+}
@@ -0,0 +1,269 @@
+// Package adminclient is a client for the Nexus admin API, for use by CI/CD
+// pipelines that publish configuration changes and roll them back. The API
+// is described by the OpenAPI document served at /api/v1/openapi.json.
+package adminclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Resource is a kind of configuration object managed through the admin API.
+type Resource string
+
+const (
+	Routes    Resource = "routes"    // legacy routes
+	RoutesV2  Resource = "routes-v2" // routes_v2
+	Upstreams Resource = "upstreams"
+	Clusters  Resource = "clusters"
+)
+
+// Client calls the admin API of one gateway. The zero value is not usable;
+// set BaseURL at least.
+type Client struct {
+	// BaseURL is the admin listener, e.g. "https://nexus-admin:9090".
+	BaseURL string
+	// Token is sent as a bearer token when set.
+	Token string
+	// HTTPClient is used for requests; nil uses http.DefaultClient.
+	// Configure client certificates on its transport.
+	HTTPClient *http.Client
+}
+
+// New returns a client for the admin API at baseURL, authenticating with
+// token when it is not empty.
+func New(baseURL, token string) *Client {
+	return &Client{BaseURL: baseURL, Token: token}
+}
+
+// Error is returned when the admin API answers with a non-2xx status.
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("nexus admin API: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// Version is a recorded configuration version.
+type Version struct {
+	Version   int       `json:"version"`
+	Hash      string    `json:"hash"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Change is one difference between the active and a candidate
+// configuration.
+type Change struct {
+	Path string      `json:"path"`
+	Op   string      `json:"op"`
+	Old  interface{} `json:"old,omitempty"`
+	New  interface{} `json:"new,omitempty"`
+}
+
+// ValidationError is a problem found in a candidate configuration. Stage is
+// "parse", "validate" or "compile".
+type ValidationError struct {
+	Stage   string `json:"stage"`
+	Message string `json:"message"`
+}
+
+// ValidationResult reports the validation of a candidate configuration.
+type ValidationResult struct {
+	Valid       bool              `json:"valid"`
+	Errors      []ValidationError `json:"errors"`
+	BaseVersion int               `json:"base_version"`
+	Diff        []Change          `json:"diff"`
+}
+
+// ImportResult reports an applied import.
+type ImportResult struct {
+	Mode    string   `json:"mode"`
+	Version int      `json:"version"`
+	Diff    []Change `json:"diff"`
+}
+
+// ReloadResult reports a reload of the configuration.
+type ReloadResult struct {
+	Version int      `json:"version"`
+	Diff    []Change `json:"diff"`
+}
+
+// Status is the gateway status.
+type Status struct {
+	Status         string `json:"status"`
+	ConfigVersions int    `json:"config_versions"`
+}
+
+// Config returns the active configuration as JSON.
+func (c *Client) Config(ctx context.Context) (json.RawMessage, error) {
+	var out json.RawMessage
+	return out, c.do(ctx, http.MethodGet, "/api/v1/config", nil, "", &out)
+}
+
+// Versions returns the recorded configuration versions, oldest first.
+func (c *Client) Versions(ctx context.Context) ([]Version, error) {
+	var out []Version
+	return out, c.do(ctx, http.MethodGet, "/api/v1/config/versions", nil, "", &out)
+}
+
+// Rollback re-applies the previous configuration version.
+func (c *Client) Rollback(ctx context.Context) error {
+	return c.do(ctx, http.MethodPost, "/api/v1/config/rollback", nil, "", nil)
+}
+
+// Reload makes the gateway read its configuration file, directory or
+// source again and apply it, as on SIGHUP. The diff is empty when nothing
+// changed.
+func (c *Client) Reload(ctx context.Context) (*ReloadResult, error) {
+	var out ReloadResult
+	if err := c.do(ctx, http.MethodPost, "/api/v1/config/reload", nil, "", &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Validate checks a complete candidate configuration, YAML or JSON, without
+// applying it. An invalid configuration is reported in the result, not as
+// an error.
+func (c *Client) Validate(ctx context.Context, candidate []byte) (*ValidationResult, error) {
+	var out ValidationResult
+	if err := c.do(ctx, http.MethodPost, "/api/v1/config/validate", candidate, "application/yaml", &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Export returns the active configuration as a YAML document, secrets
+// included, that Import accepts.
+func (c *Client) Export(ctx context.Context) ([]byte, error) {
+	var out []byte
+	return out, c.do(ctx, http.MethodGet, "/api/v1/config/export?format=yaml", nil, "", &out)
+}
+
+// Import applies a configuration document, YAML or JSON. With replace the
+// document becomes the whole configuration; otherwise its named objects are
+// added or replace those of the same name and the other sections it sets
+// replace the active ones.
+func (c *Client) Import(ctx context.Context, doc []byte, replace bool) (*ImportResult, error) {
+	mode := "merge"
+	if replace {
+		mode = "replace"
+	}
+	var out ImportResult
+	if err := c.do(ctx, http.MethodPost, "/api/v1/config/import?mode="+mode, doc, "application/yaml", &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// List returns the objects of a resource as JSON.
+func (c *Client) List(ctx context.Context, res Resource) (json.RawMessage, error) {
+	var out json.RawMessage
+	return out, c.do(ctx, http.MethodGet, "/api/v1/"+string(res), nil, "", &out)
+}
+
+// Create adds an object. def uses the field names of the configuration file:
+// a []byte is sent as is, as YAML or JSON, anything else is encoded as JSON.
+func (c *Client) Create(ctx context.Context, res Resource, def interface{}) error {
+	body, contentType, err := encodeDefinition(def)
+	if err != nil {
+		return err
+	}
+	return c.do(ctx, http.MethodPost, "/api/v1/"+string(res), body, contentType, nil)
+}
+
+// Update replaces the named object with def, encoded as for Create.
+func (c *Client) Update(ctx context.Context, res Resource, name string, def interface{}) error {
+	body, contentType, err := encodeDefinition(def)
+	if err != nil {
+		return err
+	}
+	return c.do(ctx, http.MethodPut, "/api/v1/"+string(res)+"/"+url.PathEscape(name), body, contentType, nil)
+}
+
+// Delete removes the named object.
+func (c *Client) Delete(ctx context.Context, res Resource, name string) error {
+	return c.do(ctx, http.MethodDelete, "/api/v1/"+string(res)+"/"+url.PathEscape(name), nil, "", nil)
+}
+
+// Status returns the gateway status.
+func (c *Client) Status(ctx context.Context) (*Status, error) {
+	var out Status
+	if err := c.do(ctx, http.MethodGet, "/api/v1/status", nil, "", &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func encodeDefinition(def interface{}) ([]byte, string, error) {
+	if raw, ok := def.([]byte); ok {
+		return raw, "application/yaml", nil
+	}
+	body, err := json.Marshal(def)
+	if err != nil {
+		return nil, "", fmt.Errorf("encode definition: %w", err)
+	}
+	return body, "application/json", nil
+}
+
+// do sends a request and decodes a successful JSON response into out, when
+// out is not nil. A *[]byte out receives the response body as is.
+func (c *Client) do(ctx context.Context, method, path string, body []byte, contentType string, out interface{}) error {
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(c.BaseURL, "/")+path, r)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	hc := c.HTTPClient
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		var e struct {
+			Error string `json:"error"`
+		}
+		msg := strings.TrimSpace(string(data))
+		if json.Unmarshal(data, &e) == nil && e.Error != "" {
+			msg = e.Error
+		}
+		return &Error{StatusCode: resp.StatusCode, Message: msg}
+	}
+	if out == nil {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	if raw, ok := out.(*[]byte); ok {
+		*raw, err = io.ReadAll(resp.Body)
+		return err
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode %s %s response: %w", method, path, err)
+	}
+	return nil
+}
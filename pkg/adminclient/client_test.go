@@ -0,0 +1,177 @@
+package adminclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/oriys/nexus/internal/admin"
+	"github.com/oriys/nexus/internal/config"
+	"github.com/oriys/nexus/internal/proxy"
+	"github.com/oriys/nexus/internal/runtime"
+)
+
+const testConfig = `server:
+  listen: ":8080"
+upstreams:
+  - name: backend
+    targets:
+      - address: "127.0.0.1:9001"
+routes:
+  - name: api
+    paths:
+      - path: /
+        type: prefix
+    upstream: backend
+clusters:
+  - name: web
+    endpoints:
+      - url: "http://127.0.0.1:9001"
+routes_v2:
+  - name: web-api
+    match:
+      path_prefix: /web
+    upstream:
+      cluster: web
+`
+
+// newGateway starts an admin API over testConfig, accepting token as an
+// operator token.
+func newGateway(t *testing.T, token string) *httptest.Server {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "nexus.yaml")
+	if err := os.WriteFile(path, []byte(testConfig), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	loader := config.NewLoader(path)
+	cfg, err := loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := runtime.NewConfigStore()
+	if _, err := runtime.CompileAndStore(cfg, store); err != nil {
+		t.Fatal(err)
+	}
+	um := proxy.NewUpstreamManager()
+	um.Reload(cfg.Upstreams)
+	s := admin.New(loader, config.NewVersionManager(10), proxy.NewRouter(), um)
+	s.SetConfigStore(store)
+	s.SetAuth(&config.AdminAuthConfig{Tokens: []config.AdminToken{{Name: "ci", Token: token, Role: config.AdminRoleOperator}}})
+	srv := httptest.NewServer(s.Handler())
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestClient_PublishAndRollback(t *testing.T) {
+	ctx := context.Background()
+	srv := newGateway(t, "ci-token")
+	c := New(srv.URL+"/", "ci-token")
+
+	route := map[string]interface{}{
+		"name":     "orders",
+		"match":    map[string]interface{}{"path_prefix": "/orders"},
+		"upstream": map[string]interface{}{"cluster": "web", "timeout_ms": 500},
+	}
+	if err := c.Create(ctx, RoutesV2, route); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Update(ctx, RoutesV2, "orders", []byte("match:\n  path_prefix: /purchases\nupstream:\n  cluster: web\n")); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := c.List(ctx, RoutesV2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var routes []struct{ Name string }
+	if err := json.Unmarshal(raw, &routes); err != nil || len(routes) != 2 || routes[1].Name != "orders" {
+		t.Errorf("unexpected routes %s", raw)
+	}
+
+	versions, err := c.Versions(ctx)
+	if err != nil || len(versions) != 2 || versions[1].Timestamp.IsZero() {
+		t.Fatalf("unexpected versions %+v, %v", versions, err)
+	}
+	if err := c.Rollback(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Delete(ctx, RoutesV2, "orders"); err != nil {
+		t.Fatal(err)
+	}
+
+	status, err := c.Status(ctx)
+	if err != nil || status.Status != "running" || status.ConfigVersions != 4 {
+		t.Errorf("unexpected status %+v, %v", status, err)
+	}
+}
+
+func TestClient_Validate(t *testing.T) {
+	c := New(newGateway(t, "ci-token").URL, "ci-token")
+	candidate := strings.Replace(testConfig, "/web", "/site", 1)
+	result, err := c.Validate(context.Background(), []byte(candidate))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Valid || len(result.Diff) != 1 || result.Diff[0].Path != "routes_v2[web-api].match.path_prefix" {
+		t.Errorf("unexpected result %+v", result)
+	}
+
+	result, err = c.Validate(context.Background(), []byte(candidate+"bogus: true\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Valid || len(result.Errors) != 1 || result.Errors[0].Stage != "parse" {
+		t.Errorf("unexpected result %+v", result)
+	}
+}
+
+func TestClient_ExportImport(t *testing.T) {
+	ctx := context.Background()
+	c := New(newGateway(t, "ci-token").URL, "ci-token")
+	doc, err := c.Export(ctx)
+	if err != nil || !strings.Contains(string(doc), "routes_v2:") {
+		t.Fatalf("unexpected export %q, %v", doc, err)
+	}
+
+	target := New(newGateway(t, "ci-token").URL, "ci-token")
+	result, err := target.Import(ctx, doc, true)
+	if err != nil || result.Mode != "replace" || len(result.Diff) != 0 {
+		t.Fatalf("unexpected result %+v, %v", result, err)
+	}
+	result, err = target.Import(ctx, []byte("logging:\n  level: debug\n"), false)
+	if err != nil || result.Mode != "merge" || len(result.Diff) != 1 || result.Diff[0].Path != "logging.level" {
+		t.Errorf("unexpected result %+v, %v", result, err)
+	}
+}
+
+func TestClient_Reload(t *testing.T) {
+	result, err := New(newGateway(t, "ci-token").URL, "ci-token").Reload(context.Background())
+	if err != nil || len(result.Diff) != 0 {
+		t.Errorf("expected an unchanged reload, got %+v, %v", result, err)
+	}
+}
+
+func TestClient_Errors(t *testing.T) {
+	ctx := context.Background()
+	srv := newGateway(t, "ci-token")
+
+	var apiErr *Error
+	err := New(srv.URL, "wrong").Rollback(ctx)
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected 401, got %v", err)
+	}
+
+	c := New(srv.URL, "ci-token")
+	err = c.Delete(ctx, Clusters, "web")
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusConflict || !strings.Contains(apiErr.Message, "web-api") {
+		t.Errorf("expected 409 naming the route, got %v", err)
+	}
+	if err := c.Create(ctx, Upstreams, func() {}); err == nil {
+		t.Error("expected an encoding error")
+	}
+}